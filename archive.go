@@ -0,0 +1,345 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: archive.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the introspection of archives (zip and rar, e.g. comics and some
+//    music releases), whose media are listed as if they were files beneath
+//    the archive, at the virtual path ARCHIVE!/PATH. media inside an archive
+//    are extracted (into a directory of the system's temp directory) when
+//    they are played. listing archives can be slow, so it is enabled per
+//    library and per archive format.
+//
+// =============================================================================
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// local unexported constants for archive introspection.
+const (
+	archiveSep        = "!/"            // separates an archive's path from the path of a file inside it
+	archiveZip        = "zip"           // format of zip archives
+	archiveRar        = "rar"           // format of rar archives
+	archiveUnrar      = "unrar"         // command listing and extracting rar archives
+	archiveExtractDir = "pimmp-extract" // directory of the system's temp dir holding extracted media
+)
+
+var (
+	// variable archiveFormat maps each archive format to the file name
+	// extensions (lower case) of its archives.
+	archiveFormat = map[string][]string{
+		archiveZip: {".zip", ".cbz"},
+		archiveRar: {".rar", ".cbr"},
+	}
+)
+
+// type ArchiveRule is a parsed archive introspection declaration of the form
+// "FORMAT[,FORMAT...][@LIBRARY]".
+type ArchiveRule struct {
+	format []string      // archive formats listed
+	scope  *LibraryScope // only library affected (empty = all)
+}
+
+// function parseArchiveRule() parses an archive introspection declaration of
+// the form "FORMAT[,FORMAT...][@LIBRARY]", where FORMAT is zip or rar.
+func parseArchiveRule(spec string) (*ArchiveRule, error) {
+
+	decl, scope := parseLibraryScope(spec)
+	rule := &ArchiveRule{format: []string{}, scope: scope}
+
+	for _, f := range strings.Split(decl, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if _, ok := archiveFormat[f]; !ok {
+			return nil, fmt.Errorf("archive %q: unknown format %q (expected %s or %s)",
+				spec, f, archiveZip, archiveRar)
+		}
+		rule.format = append(rule.format, f)
+	}
+	return rule, nil
+}
+
+// function appliesTo() returns true if this rule was declared for the given
+// library.
+func (r *ArchiveRule) appliesTo(lib *Library) bool {
+	return r.scope.appliesTo(lib)
+}
+
+// function archiveListed() returns true if archives of the given format are
+// listed in any library.
+func archiveListed(opt *Options, format string) bool {
+	for _, spec := range opt.Archives.StringList {
+		if rule, err := parseArchiveRule(spec); nil == err {
+			for _, f := range rule.format {
+				if f == format {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// function archiveFormatOf() returns the format of archives with the given file
+// name extension, or an empty string if it is not an archive.
+func archiveFormatOf(ext string) string {
+	ext = strings.ToLower(ext)
+	for format, list := range archiveFormat {
+		for _, e := range list {
+			if e == ext {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// function splitArchivePath() splits the given virtual path of a file inside an
+// archive into the path of the archive and the path inside it. returns false
+// if the path is not inside an archive.
+func splitArchivePath(p string) (string, string, bool) {
+	i := strings.Index(p, archiveSep)
+	if i < 0 || "" == archiveFormatOf(path.Ext(p[:i])) {
+		return "", "", false
+	}
+	return p[:i], p[i+len(archiveSep):], true
+}
+
+// type ArchiveEntry describes a file inside an archive, implementing the
+// os.FileInfo interface so that entities may be created from it.
+type ArchiveEntry struct {
+	path string // path inside the archive, always separated by '/'
+	size int64
+	mode os.FileMode
+	time time.Time
+}
+
+func (e *ArchiveEntry) Name() string       { return path.Base(e.path) }
+func (e *ArchiveEntry) Size() int64        { return e.size }
+func (e *ArchiveEntry) Mode() os.FileMode  { return e.mode }
+func (e *ArchiveEntry) ModTime() time.Time { return e.time }
+func (e *ArchiveEntry) IsDir() bool        { return e.mode.IsDir() }
+func (e *ArchiveEntry) Sys() interface{}   { return nil }
+
+// function listArchive() returns the regular files inside the given archive of
+// the given format. rar archives are listed with unrar, which doesn't report
+// sizes or times in its bare listing, so the archive's own time is used.
+func listArchive(format, absPath string, info os.FileInfo) ([]*ArchiveEntry, error) {
+
+	entry := []*ArchiveEntry{}
+	switch format {
+	case archiveZip:
+		r, err := zip.OpenReader(absPath)
+		if nil != err {
+			return nil, err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if fi := f.FileInfo(); fi.Mode().IsRegular() {
+				entry = append(entry, &ArchiveEntry{
+					path: f.Name, size: fi.Size(), mode: fi.Mode(), time: fi.ModTime()})
+			}
+		}
+
+	case archiveRar:
+		out, err := exec.Command(archiveUnrar, "lb", "-p-", absPath).Output()
+		if nil != err {
+			return nil, fmt.Errorf("%s: %s", archiveUnrar, err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			if name := strings.TrimSpace(scanner.Text()); "" != name {
+				entry = append(entry, &ArchiveEntry{
+					path: filepath.ToSlash(name), size: 0, mode: 0444, time: info.ModTime()})
+			}
+		}
+	}
+	return entry, nil
+}
+
+// function scanArchive() inserts each media file inside the given archive into
+// the library's database (if not already known), exactly as function
+// scanDive() inserts media files.
+func (l *Library) scanArchive(ph *PathHandler, format, absPath, relPath string, info os.FileInfo) *ReturnCode {
+
+	entry, err := listArchive(format, absPath, info)
+	if nil != err {
+		return rcInvalidFile.specf(
+			"scanArchive(%q): cannot list archive: %s (skipping)", relPath, err).
+			at("scanArchive", l.name, absPath)
+	}
+
+	for _, e := range entry {
+		ext := path.Ext(e.path)
+		kind, extName := mediaKindOfFile(e.path, ext)
+		if mkUnknown == kind {
+			continue
+		}
+		virtAbs, virtRel := absPath+archiveSep+e.path, relPath+archiveSep+e.path
+
+		// skip media already known, as function scanDive() does.
+		result := make(map[int]struct{})
+		if err := evalQuery(map[string]interface{}{
+			"eq": virtAbs,
+			"in": []interface{}{(*l.db.index[ecMedia][mxPath])[0]},
+		}, l.db.col[ecMedia][kind], &result); nil != err {
+			return rcInvalidFile.specf(
+				"scanArchive(%q): failed to evaluate query: %s (skipping)", virtRel, err)
+		}
+		if len(result) > 0 {
+			continue
+		}
+
+		var media interface{}
+		var rec *EntityRecord
+		var recErr *ReturnCode
+		switch kind {
+		case mkAudio:
+			audio := newAudioMedia(l, virtAbs, virtRel, ext, extName, e)
+			media = audio
+			rec, recErr = audio.toRecord()
+		case mkVideo:
+			video := newVideoMedia(l, virtAbs, virtRel, ext, extName, e)
+			media = video
+			rec, recErr = video.toRecord()
+		}
+		if nil != recErr {
+			return recErr
+		}
+		id, insErr := l.db.col[ecMedia][kind].Insert(*rec)
+		if nil != insErr {
+			return rcDatabaseError.specf(
+				"scanArchive(%q): failed to insert record: %s (skipping)", virtRel, insErr)
+		}
+		l.db.countScanned(ecMedia, int(kind))
+		l.addIndexedSize(e.size)
+		infoLog.tracef("discovered %s in archive (ID={%q,%X}): %s",
+			strings.ToLower(mediaColName[kind]), l.name, id, virtRel)
+		if nil != ph && nil != ph.handleMedia {
+			ph.handleMedia(l, virtAbs, media, id)
+		}
+	}
+	return nil
+}
+
+// function extractedPath() returns the path at which the given file inside the
+// given archive is extracted, or an error if the file would be extracted
+// outside of the extraction directory.
+func extractedPath(archive, inner string) (string, error) {
+	clean := path.Clean(inner)
+	if path.IsAbs(clean) || ".." == clean || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("unsafe path inside archive: %q", inner)
+	}
+	return filepath.Join(extractDir(archive), filepath.FromSlash(clean)), nil
+}
+
+// function extractDir() returns the directory into which files of the given
+// archive are extracted.
+func extractDir(archive string) string {
+	sum := sha1.Sum([]byte(archive))
+	return filepath.Join(os.TempDir(), archiveExtractDir, fmt.Sprintf("%x", sum[:8]))
+}
+
+// function playablePath() returns the path of the file played for the media at
+// the given path, which is the path of its extracted copy if it is inside an
+// archive (see function extractMedia()).
+func playablePath(absPath string) string {
+	if archive, inner, ok := splitArchivePath(absPath); ok {
+		if p, err := extractedPath(archive, inner); nil == err {
+			return p
+		}
+	}
+	return absPath
+}
+
+// function extractMedia() extracts the media at the given path, if it is inside
+// an archive, unless it has already been extracted. this must be done before
+// the media is played.
+func extractMedia(absPath string) *ReturnCode {
+
+	archive, inner, ok := splitArchivePath(absPath)
+	if !ok {
+		return nil
+	}
+	dest, err := extractedPath(archive, inner)
+	if nil != err {
+		return rcInvalidFile.specf("extractMedia(%q): %s", absPath, err)
+	}
+	if info, err := os.Stat(dest); nil == err && info.Mode().IsRegular() {
+		return nil
+	}
+	if target := currentTarget(); "" != target.host {
+		warnLog.logf("media inside archives are extracted on this host, not on %s", target.desc())
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); nil != err {
+		return rcInvalidPath.specf("extractMedia(%q): os.MkdirAll(): %s", absPath, err)
+	}
+
+	infoLog.logf("extracting from archive: %s", inner)
+	switch archiveFormatOf(path.Ext(archive)) {
+	case archiveZip:
+		err = extractZip(archive, inner, dest)
+	case archiveRar:
+		// unrar recreates the path inside the archive beneath the given dir.
+		err = exec.Command(archiveUnrar, "x", "-o+", "-inul", "-p-",
+			archive, filepath.FromSlash(inner), extractDir(archive)+string(filepath.Separator)).Run()
+	}
+	if nil != err {
+		return rcInvalidFile.specf("extractMedia(%q): %s", absPath, err)
+	}
+	return nil
+}
+
+// function extractZip() copies the given file inside the given zip archive to
+// the given destination. the copy is renamed into place once complete, so that
+// an interrupted extraction is never mistaken for a complete one.
+func extractZip(archive, inner, dest string) error {
+
+	r, err := zip.OpenReader(archive)
+	if nil != err {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != inner {
+			continue
+		}
+		src, err := f.Open()
+		if nil != err {
+			return err
+		}
+		defer src.Close()
+		part := dest + ".part"
+		out, err := os.Create(part)
+		if nil != err {
+			return err
+		}
+		if _, err := io.Copy(out, src); nil != err {
+			out.Close()
+			os.Remove(part)
+			return err
+		}
+		if err := out.Close(); nil != err {
+			os.Remove(part)
+			return err
+		}
+		return os.Rename(part, dest)
+	}
+	return fmt.Errorf("not found in archive: %q", inner)
+}
@@ -0,0 +1,139 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: assoc.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the configuration of the heuristics used to associate subtitles
+//    with video media (see function findCandidates()). each heuristic may be
+//    disabled for all libraries or for individual libraries, since the default
+//    behavior produces false positives in some collections.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// type SubsHeuristic is an enum identifying one of the methods used to
+// associate subtitles with video media.
+type SubsHeuristic int
+
+const (
+	shUnknown  SubsHeuristic = iota - 1 // = -1
+	shBaseName                          // =  0
+	shDirName                           // =  1
+	shSubdir                            // =  2
+	shFewMedia                          // =  3
+	shCOUNT                             // =  4
+)
+
+var (
+	// variable subsHeuristicName maps the SubsHeuristic enum values to the
+	// names used to disable them on the command line.
+	subsHeuristicName = [shCOUNT]string{
+		"basename", // 0 = shBaseName
+		"dirname",  // 1 = shDirName
+		"subdir",   // 2 = shSubdir
+		"fewmedia", // 3 = shFewMedia
+	}
+
+	// variable defaultSubsSubdir lists the common names of directories used to
+	// store subtitles relative to the location of a video media file.
+	defaultSubsSubdir = []string{
+		"sub", "subs", "subtitle", "subtitles", "vobsub", "srt",
+	}
+)
+
+// type SubsAssoc holds the configuration of the subtitles association
+// heuristics in effect for a single library.
+type SubsAssoc struct {
+	maxMedia uint            // max number of media in a dir with subtitles for shFewMedia
+	subdir   map[string]bool // lowercase names of directories recognized by shSubdir
+	disabled [shCOUNT]bool   // heuristics that are never applied
+}
+
+// type subsDisable is a parsed heuristic disable declaration of the form
+// "HEURISTIC[@LIBRARY]".
+type subsDisable struct {
+	heuristic SubsHeuristic
+	scope     *LibraryScope // only library affected (empty = all)
+}
+
+// function parseSubsDisable() parses a heuristic disable declaration of the
+// form "HEURISTIC[@LIBRARY]", where HEURISTIC is one of the names in
+// subsHeuristicName.
+func parseSubsDisable(spec string) (*subsDisable, error) {
+
+	decl, scope := parseLibraryScope(spec)
+	dis := &subsDisable{heuristic: shUnknown, scope: scope}
+
+	name := strings.ToLower(strings.TrimSpace(decl))
+	for h, n := range subsHeuristicName {
+		if n == name {
+			dis.heuristic = SubsHeuristic(h)
+			break
+		}
+	}
+	if shUnknown == dis.heuristic {
+		return nil, fmt.Errorf("subtitles heuristic %q: unknown heuristic: %q (expected one of: %s)",
+			spec, name, strings.Join(subsHeuristicName[:], ", "))
+	}
+	return dis, nil
+}
+
+// function newSubsAssoc() creates the subtitles association configuration for
+// the given library from the command line options. if lib is nil, only those
+// declarations applying to all libraries are used.
+func newSubsAssoc(opt *Options, lib *Library) *SubsAssoc {
+
+	assoc := &SubsAssoc{
+		maxMedia: opt.SubsMaxMedia.uint,
+		subdir:   map[string]bool{},
+	}
+
+	subdir := defaultSubsSubdir
+	if len(opt.SubsSubdir.StringList) > 0 {
+		subdir = opt.SubsSubdir.StringList
+	}
+	for _, name := range subdir {
+		assoc.subdir[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	for _, spec := range opt.SubsDisable.StringList {
+		if dis, err := parseSubsDisable(spec); nil == err {
+			if "" == dis.scope.library || (nil != lib && dis.scope.appliesTo(lib)) {
+				assoc.disabled[dis.heuristic] = true
+			}
+		}
+	}
+	return assoc
+}
+
+// function enabled() returns true if the given heuristic should be applied.
+func (a *SubsAssoc) enabled(h SubsHeuristic) bool {
+	return !a.disabled[h]
+}
+
+// function isSubsSubdir() returns true if the given directory name is one of
+// the names recognized as a subtitles subdirectory.
+func (a *SubsAssoc) isSubsSubdir(name string) bool {
+	return a.subdir[strings.ToLower(name)]
+}
+
+// function String() creates a string representation of the SubsAssoc for easy
+// identification in logs.
+func (a *SubsAssoc) String() string {
+	on := []string{}
+	for h, name := range subsHeuristicName {
+		if a.enabled(SubsHeuristic(h)) {
+			on = append(on, name)
+		}
+	}
+	return fmt.Sprintf("{heuristics:[%s],maxmedia:%d}", strings.Join(on, ","), a.maxMedia)
+}
@@ -0,0 +1,165 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: audiocheck.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the quick sanity check of audio files performed while scanning
+//    (if enabled with option -checkaudio): the first bytes of each new audio
+//    file must be the header of the format claimed by its file name extension,
+//    so that junk merely named like audio (e.g. an HTML error page saved as
+//    "file.mp3", or a file of zeros left by a failed copy) is flagged as an
+//    issue rather than added to the library as playable media.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// local unexported constants for the sanity check of audio files.
+const (
+	audioProbeSize = 4096 // bytes read from the beginning of each audio file
+)
+
+// type ContentMagic checks if the given bytes, read from the beginning of a
+// file (after any ID3v2 tag, for audio), begin with the header of a format.
+type ContentMagic func(b []byte) bool
+
+// function magicAt() returns a ContentMagic matching any of the given
+// signatures at the given offset.
+func magicAt(offset int, sig ...string) ContentMagic {
+	return func(b []byte) bool {
+		for _, s := range sig {
+			if len(b) >= offset+len(s) && s == string(b[offset:offset+len(s)]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// function magicAll() returns a ContentMagic matching only if all given
+// ContentMagic match.
+func magicAll(magic ...ContentMagic) ContentMagic {
+	return func(b []byte) bool {
+		for _, m := range magic {
+			if !m(b) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// function mpegAudioSync() returns true if an MPEG audio frame header (of any
+// version and layer) is found among the given bytes. some encoders pad the
+// beginning of the file, so the header need not be first.
+func mpegAudioSync(b []byte) bool {
+	for i := 0; i+2 < len(b); i++ {
+		if 0xFF == b[i] && 0xE0 == b[i+1]&0xE0 &&
+			0x08 != b[i+1]&0x18 && // version: reserved
+			0x00 != b[i+1]&0x06 && // layer: reserved
+			0xF0 != b[i+2]&0xF0 && // bit rate: invalid
+			0x0C != b[i+2]&0x0C { // sample rate: reserved
+			return true
+		}
+	}
+	return false
+}
+
+// function adtsSync() returns true if the given bytes begin with the header of
+// an AAC ADTS frame.
+func adtsSync(b []byte) bool {
+	return len(b) >= 2 && 0xFF == b[0] && 0xF0 == b[1]&0xF6
+}
+
+var (
+	// variable audioMagic maps the file name extensions (lower case) of audio
+	// formats with a well-known header to the check of their header. files of
+	// other formats are not checked.
+	audioMagic = map[string]ContentMagic{
+		".mp3":  mpegAudioSync,
+		".aac":  func(b []byte) bool { return adtsSync(b) || magicAt(0, "ADIF")(b) },
+		".flac": magicAt(0, "fLaC"),
+		".ogg":  magicAt(0, "OggS"),
+		".oga":  magicAt(0, "OggS"),
+		".mogg": magicAt(0, "OggS"),
+		".opus": magicAt(0, "OggS"),
+		".wav":  magicAll(magicAt(0, "RIFF", "RF64"), magicAt(8, "WAVE")),
+		".aiff": magicAll(magicAt(0, "FORM"), magicAt(8, "AIFF", "AIFC")),
+		".8svx": magicAll(magicAt(0, "FORM"), magicAt(8, "8SVX")),
+		".m4a":  magicAt(4, "ftyp"),
+		".m4b":  magicAt(4, "ftyp"),
+		".wma":  magicAt(0, "\x30\x26\xB2\x75\x8E\x66\xCF\x11"),
+		".ape":  magicAt(0, "MAC "),
+		".wv":   magicAt(0, "wvpk"),
+		".tta":  magicAt(0, "TTA1"),
+		".au":   magicAt(0, ".snd"),
+		".mpc":  magicAt(0, "MPCK", "MP+"),
+		".amr":  magicAt(0, "#!AMR"),
+		".awb":  magicAt(0, "#!AMR-WB"),
+		".ra":   magicAt(0, ".ra\xFD", ".RMF"),
+	}
+)
+
+// function checkAudioHeader() reads the beginning of the given audio file and
+// returns an error if it is not the header of the format claimed by the file
+// name extension.
+func checkAudioHeader(absPath, ext string) *ReturnCode {
+
+	magic, ok := audioMagic[strings.ToLower(ext)]
+	if !ok {
+		return nil
+	}
+
+	f, err := os.Open(absPath)
+	if nil != err {
+		return rcInvalidFile.wrap(err, "checkAudioHeader(%q)", absPath)
+	}
+	defer f.Close()
+
+	b := make([]byte, audioProbeSize)
+	n, err := io.ReadFull(f, b)
+	if nil != err && io.ErrUnexpectedEOF != err && io.EOF != err {
+		return rcInvalidFile.wrap(err, "checkAudioHeader(%q)", absPath)
+	}
+	b = b[:n]
+
+	// an ID3v2 tag may precede the audio of most formats; the audio following
+	// a tag larger than the bytes read is not checked.
+	head := b
+	if bytes.HasPrefix(head, []byte("ID3")) && len(head) >= 10 {
+		size := 10 + syncsafe(head[6:10])
+		if size >= int64(len(head)) {
+			return nil
+		}
+		head = head[size:]
+	}
+	if magic(head) {
+		return nil
+	}
+
+	// describe what the file appears to be instead.
+	looks := "unrecognized data"
+	switch {
+	case 0 == n:
+		looks = "empty"
+	case 0 == len(bytes.Trim(b, "\x00")):
+		looks = "all zeros"
+	default:
+		if kind := http.DetectContentType(b); "application/octet-stream" != kind {
+			looks = strings.SplitN(kind, ";", 2)[0]
+		}
+	}
+	return rcInvalidMedia.specf("checkAudioHeader(%q): not a valid %s file (content is %s)",
+		absPath, strings.TrimPrefix(strings.ToLower(ext), "."), looks)
+}
@@ -0,0 +1,396 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: batch.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines batch edits, which apply a single metadata change to all media
+//    items marked in the media browser at once, and the dialog used to preview
+//    and apply them. the most recently applied batch edit can be undone.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// type BatchOp is an enum identifying the kind of change made by a batch edit.
+type BatchOp int
+
+const (
+	boUnknown     BatchOp = iota - 1 // = -1
+	boSetAlbum                       // =  0
+	boAddTag                         // =  1
+	boSetYear                        // =  2
+	boAppendTitle                    // =  3
+	boCOUNT                          // =  4
+)
+
+var (
+	// variable batchOpName maps the BatchOp enum values to the names displayed
+	// in the batch edit dialog.
+	batchOpName = [boCOUNT]string{
+		"Set album",        // 0 = boSetAlbum
+		"Add tag",          // 1 = boAddTag
+		"Set release year", // 2 = boSetYear
+		"Append to title",  // 3 = boAppendTitle
+	}
+)
+
+// type batchChange is the change made by a batch edit to a single media item,
+// along with the means of reverting it.
+type batchChange struct {
+	item   *mediaItem
+	record *RecordID
+	desc   string // human-readable description of the change for previews
+	apply  func() // modifies the in-memory media object
+	revert func() // restores the in-memory media object
+}
+
+// type BatchEdit is a single change applied to a group of media items.
+type BatchEdit struct {
+	op      BatchOp
+	value   string
+	change  []*batchChange
+	skipped int // number of items not affected by this edit
+}
+
+// function newBatchEdit() prepares a batch edit applying the given change to
+// each of the given media items. items to which the change does not apply, or
+// whose database record is unknown, are skipped. nothing is modified until the
+// batch edit is committed.
+func newBatchEdit(op BatchOp, value string, item []*mediaItem, record map[*Media]*RecordID) (*BatchEdit, *ReturnCode) {
+
+	value = strings.TrimSpace(value)
+	if "" == value {
+		return nil, rcInvalidArgs.specf(
+			"newBatchEdit(%q): value must not be empty", batchOpName[op])
+	}
+
+	var year int
+	if boSetYear == op {
+		var err error
+		if year, err = strconv.Atoi(value); nil != err || year < 1 || year > 9999 {
+			return nil, rcInvalidArgs.specf(
+				"newBatchEdit(%q): invalid year: %q", batchOpName[op], value)
+		}
+	}
+
+	edit := &BatchEdit{op: op, value: value, change: []*batchChange{}, skipped: 0}
+
+	for _, m := range item {
+		rec, ok := record[m.Media]
+		if !ok || nil == rec {
+			edit.skipped++
+			continue
+		}
+		media := m.Media
+		change := &batchChange{item: m, record: rec}
+
+		switch op {
+		case boSetAlbum:
+			audio, isAudio := rec.rec.(*AudioMedia)
+			if !isAudio {
+				edit.skipped++
+				continue
+			}
+			prev := audio.Album
+			change.desc = fmt.Sprintf("%s: album %q -> %q", media.AbsName, prev, value)
+			change.apply = func() { audio.Album = value }
+			change.revert = func() { audio.Album = prev }
+
+		case boAddTag:
+			if containsString(media.Tags, value) {
+				edit.skipped++
+				continue
+			}
+			prev := media.Tags
+			change.desc = fmt.Sprintf("%s: tags %q -> %q", media.AbsName, prev, append(append([]string{}, prev...), value))
+			change.apply = func() { media.Tags = append(append([]string{}, prev...), value) }
+			change.revert = func() { media.Tags = prev }
+
+		case boSetYear:
+			prev := media.ReleaseDate
+			next := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+			change.desc = fmt.Sprintf("%s: release year %s -> %d", media.AbsName, releaseYear(prev), year)
+			change.apply = func() { media.ReleaseDate = next }
+			change.revert = func() { media.ReleaseDate = prev }
+
+		case boAppendTitle:
+			prev := media.Title
+			change.desc = fmt.Sprintf("%s: title %q -> %q", media.AbsName, prev, prev+value)
+			change.apply = func() { media.Title = prev + value }
+			change.revert = func() { media.Title = prev }
+
+		default:
+			return nil, rcInvalidArgs.specf("newBatchEdit(%d): unknown operation", op)
+		}
+		edit.change = append(edit.change, change)
+	}
+
+	return edit, nil
+}
+
+// function containsString() returns true if the given slice contains the given
+// string.
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// function releaseYear() formats the year of the given release date, or "--"
+// if the release date is unknown.
+func releaseYear(t time.Time) string {
+	if t.IsZero() {
+		return "--"
+	}
+	return strconv.Itoa(t.Year())
+}
+
+// function String() creates a string representation of the BatchEdit for easy
+// identification in logs.
+func (b *BatchEdit) String() string {
+	return fmt.Sprintf("%s %q (%d items)", strings.ToLower(batchOpName[b.op]), b.value, len(b.change))
+}
+
+// function preview() describes each change the BatchEdit will make.
+func (b *BatchEdit) preview() []string {
+	desc := []string{}
+	for _, c := range b.change {
+		desc = append(desc, c.desc)
+	}
+	if b.skipped > 0 {
+		desc = append(desc, fmt.Sprintf("(%d marked items not affected)", b.skipped))
+	}
+	return desc
+}
+
+// function writeChange() writes the current state of a changed media item to
+// its database record.
+func writeChange(c *batchChange) *ReturnCode {
+
+	entity, ok := c.record.rec.(StorableEntity)
+	if !ok {
+		return rcInvalidArgs.specf(
+			"writeChange(%q): media is not storable", c.item.AbsName)
+	}
+	rec, ret := entity.toRecord()
+	if nil != ret {
+		return ret
+	}
+	col := c.item.SourceLibrary.db.col[ecMedia][c.item.Kind]
+	if err := col.Update(c.record.id, *rec); nil != err {
+		return rcDatabaseError.specf(
+			"writeChange(%q, %d): failed to update record: %s", c.item.AbsName, c.record.id, err)
+	}
+	return nil
+}
+
+// function commit() applies every change of the BatchEdit and writes them to
+// the database. the batch is all-or-nothing: if any record cannot be written,
+// every change is reverted and the records already written are restored.
+func (b *BatchEdit) commit() *ReturnCode {
+
+	for i, c := range b.change {
+		c.apply()
+		if ret := writeChange(c); nil != ret {
+			// roll back all changes made so far, including this one.
+			for _, r := range b.change[:i+1] {
+				r.revert()
+				writeChange(r)
+			}
+			return ret
+		}
+	}
+	return nil
+}
+
+// function undo() reverts every change of a committed BatchEdit and writes them
+// to the database.
+func (b *BatchEdit) undo() *ReturnCode {
+
+	var failed *ReturnCode = nil
+	for _, c := range b.change {
+		c.revert()
+		if ret := writeChange(c); nil != ret {
+			failed = ret
+		}
+	}
+	return failed
+}
+
+//------------------------------------------------------------------------------
+
+// type BatchEditView is the dialog used to choose, preview, and apply a batch
+// edit to the items marked in the media browser.
+type BatchEditView struct {
+	*tview.Flex
+	form       *tview.Form
+	opDropDown *tview.DropDown
+	valueInput *tview.InputField
+	preview    *tview.TextView
+	layout     *Layout
+	focusPage  string
+	focusNext  FocusDelegator
+	focusPrev  FocusDelegator
+}
+
+// function newBatchEditView() allocates and initializes the dialog widgets.
+func newBatchEditView(ui *tview.Application, page string, lib []*Library) *BatchEditView {
+
+	v := BatchEditView{
+		Flex:       tview.NewFlex().SetDirection(tview.FlexRow),
+		form:       nil,
+		opDropDown: nil,
+		valueInput: nil,
+		preview:    nil,
+		layout:     nil,
+		focusPage:  page,
+		focusNext:  nil,
+		focusPrev:  nil,
+	}
+
+	form := tview.NewForm().
+		AddDropDown("Change:", batchOpName[:], 0, nil).
+		AddInputField("Value:", "", 40, nil, nil).
+		AddButton("Preview", v.showPreview).
+		AddButton("Apply", v.apply).
+		AddButton("Cancel", v.cancel).
+		SetLabelColor(colorScheme.inactiveMenuText).
+		SetFieldTextColor(colorScheme.inactiveMenuText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	preview := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetTextColor(colorScheme.activeText)
+
+	v.Flex.
+		AddItem(form, 7, 0, true).
+		AddItem(preview, 0, 1, false).
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.form = form
+	v.opDropDown = form.GetFormItem(0).(*tview.DropDown)
+	v.valueInput = form.GetFormItem(1).(*tview.InputField)
+	v.preview = preview
+
+	return &v
+}
+
+func (v *BatchEditView) desc() string { return "" }
+func (v *BatchEditView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *BatchEditView) page() string         { return v.focusPage }
+func (v *BatchEditView) next() FocusDelegator { return v.focusNext }
+func (v *BatchEditView) prev() FocusDelegator { return v.focusPrev }
+func (v *BatchEditView) focus() {
+	v.SetTitle(fmt.Sprintf(" Batch edit: [#%06x]%d marked items ",
+		colorScheme.highlightPrimary.Hex(), len(v.layout.browseView.markedItems())))
+	v.preview.Clear()
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.form)
+}
+func (v *BatchEditView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function prepare() creates the batch edit described by the dialog's current
+// selections for all marked items.
+func (v *BatchEditView) prepare() (*BatchEdit, *ReturnCode) {
+	op, _ := v.opDropDown.GetCurrentOption()
+	browse := v.layout.browseView
+	return newBatchEdit(BatchOp(op), v.valueInput.GetText(), browse.markedItems(), browse.record)
+}
+
+// function showPreview() lists each change the batch edit would make without
+// modifying anything.
+func (v *BatchEditView) showPreview() {
+	v.preview.Clear()
+	edit, ret := v.prepare()
+	if nil != ret {
+		fmt.Fprint(v.preview, ret.Error())
+		return
+	}
+	fmt.Fprint(v.preview, strings.Join(edit.preview(), "\n"))
+}
+
+// function apply() commits the batch edit, keeping it so that it can be undone.
+func (v *BatchEditView) apply() {
+	edit, ret := v.prepare()
+	if nil != ret {
+		v.preview.Clear()
+		fmt.Fprint(v.preview, ret.Error())
+		return
+	}
+	if ret := edit.commit(); nil != ret {
+		warnLog.logf("batch edit failed (no changes made): %s", ret)
+	} else {
+		v.layout.lastBatch = edit
+		infoLog.logf("batch edit applied (press 'Z' to undo): %s", edit)
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function cancel() closes the dialog without modifying anything.
+func (v *BatchEditView) cancel() {
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function batchEvent() handles the keys opening the batch edit dialog and
+// undoing the last batch edit from the media browser. returns true if the key
+// was handled.
+func (l *Layout) batchEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek {
+		return false
+	}
+	switch er {
+	case 'E':
+		if busy {
+			warnLog.logf(busyMessage("edit marked items"))
+		} else if 0 == len(l.browseView.markedItems()) {
+			warnLog.logf("(ignored) no items marked (press space to mark items)")
+		} else {
+			l.focusQueue <- l.batchEdit
+		}
+		return true
+	case 'Z':
+		if busy {
+			warnLog.logf(busyMessage("undo the last batch edit"))
+		} else if nil == l.lastBatch {
+			warnLog.logf("(ignored) no batch edit to undo")
+		} else {
+			if ret := l.lastBatch.undo(); nil != ret {
+				warnLog.logf("batch edit partially undone: %s", ret)
+			} else {
+				infoLog.logf("batch edit undone: %s", l.lastBatch)
+			}
+			l.lastBatch = nil
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,346 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: bench.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "bench" command, which measures the throughput of each stage
+//    of a library scan on a given path: traversing the file system, classifying
+//    the files found, and inserting their records into a database. the latter
+//    two stages are repeated with each combination of the given numbers of
+//    workers and batch sizes, so that the performance options can be tuned for
+//    the hardware at hand. nothing is written to the library or data directory.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for the benchmark.
+const (
+	// default max number of records inserted by each run of the insert stage.
+	defaultBenchLimit = 10000
+)
+
+// type BenchSpec describes the runs performed by the "bench" command.
+type BenchSpec struct {
+	workers []uint // numbers of concurrent workers to try
+	batch   []uint // numbers of files handed to a worker at once to try
+	limit   uint   // max number of records inserted by each run
+}
+
+// type BenchResult is the measured throughput of a single run of some stage.
+type BenchResult struct {
+	stage   string
+	workers uint
+	batch   uint
+	count   uint
+	elapsed time.Duration
+}
+
+// function rate() returns the number of items processed per second.
+func (r *BenchResult) rate() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(r.count) / r.elapsed.Seconds()
+}
+
+// function String() creates a human-readable summary of the run.
+func (r *BenchResult) String() string {
+	return fmt.Sprintf("%s: workers=%d batch=%d: %d in %s (%.0f/s)",
+		r.stage, r.workers, r.batch, r.count, r.elapsed.Round(time.Microsecond), r.rate())
+}
+
+// type benchFile is a regular file found by the traversal stage, along with
+// the record a scan would insert for it (if any).
+type benchFile struct {
+	absPath string
+	info    os.FileInfo
+	kind    MediaKind
+	record  *EntityRecord
+}
+
+// function parseBenchList() parses a comma-separated list of positive integers.
+func parseBenchList(key, val string) ([]uint, *ReturnCode) {
+	list := []uint{}
+	for _, s := range strings.Split(val, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if nil != err || 0 == n {
+			return nil, rcInvalidArgs.specf("bench: invalid %s: %q", key, s)
+		}
+		list = append(list, uint(n))
+	}
+	return list, nil
+}
+
+// function newBenchSpec() creates a BenchSpec with the default values, modified
+// by the given KEY=VALUE arguments.
+func newBenchSpec(args []string) (*BenchSpec, *ReturnCode) {
+
+	spec := &BenchSpec{
+		workers: []uint{1},
+		batch:   []uint{1, 64},
+		limit:   defaultBenchLimit,
+	}
+	if defaultNumCPU > 1 {
+		spec.workers = append(spec.workers, uint(defaultNumCPU))
+	}
+
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if 2 != len(kv) {
+			return nil, rcInvalidArgs.specf("bench: expected KEY=VALUE: %q", arg)
+		}
+		var ret *ReturnCode
+		switch key, val := strings.ToLower(kv[0]), kv[1]; key {
+		case "workers":
+			spec.workers, ret = parseBenchList(key, val)
+		case "batch":
+			spec.batch, ret = parseBenchList(key, val)
+		case "limit":
+			var list []uint
+			if list, ret = parseBenchList(key, val); nil == ret {
+				spec.limit = list[len(list)-1]
+			}
+		default:
+			ret = rcInvalidArgs.specf("bench: unknown key: %q", key)
+		}
+		if nil != ret {
+			return nil, ret
+		}
+	}
+	return spec, nil
+}
+
+// function benchParallel() calls the given function with each of the given
+// files, in batches of the given size distributed to the given number of
+// concurrent workers. returns the time elapsed.
+func benchParallel(file []*benchFile, workers, batch uint, fn func(*benchFile)) time.Duration {
+
+	queue := make(chan []*benchFile, workers)
+	var wait sync.WaitGroup
+
+	start := time.Now()
+	for w := uint(0); w < workers; w++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for b := range queue {
+				for _, f := range b {
+					fn(f)
+				}
+			}
+		}()
+	}
+	for i := 0; i < len(file); i += int(batch) {
+		end := i + int(batch)
+		if end > len(file) {
+			end = len(file)
+		}
+		queue <- file[i:end]
+	}
+	close(queue)
+	wait.Wait()
+	return time.Since(start)
+}
+
+// function benchWalk() traverses the given path exactly as a scan would,
+// returning every regular file found.
+func benchWalk(abs string) ([]*benchFile, *BenchResult) {
+
+	file := []*benchFile{}
+	result := &BenchResult{stage: "walk", workers: 1, batch: 1}
+
+	start := time.Now()
+	filepath.Walk(abs,
+		func(p string, info os.FileInfo, err error) error {
+			result.count++
+			if nil == err && info.Mode().IsRegular() {
+				file = append(file, &benchFile{absPath: p, info: info, kind: mkUnknown})
+			}
+			return nil
+		})
+	result.elapsed = time.Since(start)
+
+	return file, result
+}
+
+// function benchClassify() classifies each of the given files, and constructs
+// the record a scan would insert for each media file, exactly as a scan would.
+func benchClassify(abs string, file []*benchFile, workers, batch uint) *BenchResult {
+
+	elapsed := benchParallel(file, workers, batch, func(f *benchFile) {
+		rel, err := filepath.Rel(abs, f.absPath)
+		if nil != err {
+			rel = f.absPath
+		}
+		ext := path.Ext(f.absPath)
+		var entity StorableEntity
+		kind, extName := mediaKindOfFile(f.absPath, ext)
+		switch kind {
+		case mkAudio:
+			entity = newAudioMedia(nil, f.absPath, rel, ext, extName, f.info)
+		case mkVideo:
+			entity = newVideoMedia(nil, f.absPath, rel, ext, extName, f.info)
+		default:
+			supportKindOfFile(f.absPath, ext)
+			return
+		}
+		if rec, ret := entity.toRecord(); nil == ret {
+			f.kind, f.record = kind, rec
+		}
+	})
+
+	return &BenchResult{
+		stage:   "classify",
+		workers: workers,
+		batch:   batch,
+		count:   uint(len(file)),
+		elapsed: elapsed,
+	}
+}
+
+// function benchInsert() inserts the records of the given media files into a
+// new, temporary database created in the given directory.
+func benchInsert(options *Options, dir string, file []*benchFile, workers, batch uint) (*BenchResult, *ReturnCode) {
+
+	dat, err := ioutil.TempDir(dir, identity+"-bench-")
+	if nil != err {
+		return nil, rcInvalidDatabase.wrap(err, "benchInsert(): ioutil.TempDir(%q)", dir)
+	}
+	defer os.RemoveAll(dat)
+
+	// the database is configured the same as any library's, except it is
+	// always created in the temporary data directory.
+	opt := *options
+	opt.LibData = &Option{name: options.LibData.name, kind: okString, string: dat}
+	opt.Portable = &Option{name: options.Portable.name, kind: okBool, bool: false}
+	opt.DBBackend = &Option{name: options.DBBackend.name, kind: okEnum, string: dbBackendDisk}
+
+	d, ret := newDatabase(&opt, filepath.Join(dat, "library"))
+	if nil != ret {
+		return nil, ret
+	}
+	defer d.close()
+
+	var mutex sync.Mutex
+	var failed error
+	elapsed := benchParallel(file, workers, batch, func(f *benchFile) {
+		if _, err := d.col[ecMedia][f.kind].Insert(*f.record); nil != err {
+			mutex.Lock()
+			failed = err
+			mutex.Unlock()
+		}
+	})
+	if nil != failed {
+		return nil, rcDatabaseError.wrap(failed, "benchInsert(): Insert()")
+	}
+
+	return &BenchResult{
+		stage:   "insert",
+		workers: workers,
+		batch:   batch,
+		count:   uint(len(file)),
+		elapsed: elapsed,
+	}, nil
+}
+
+// function benchDataDir() returns the directory in which the temporary
+// databases of the insert stage are created: the same file system as the
+// library databases, if possible.
+func benchDataDir(options *Options) string {
+	if options.isMemoryBackend() {
+		return ramDir()
+	}
+	if dir := filepath.Dir(options.LibData.string); "" != dir {
+		if exists, _ := goutil.PathExists(dir); exists {
+			return dir
+		}
+	}
+	return os.TempDir()
+}
+
+// function benchCommand() implements the "bench" command.
+func benchCommand(options *Options, args []string) *ReturnCode {
+
+	if len(args) < 1 {
+		return rcInvalidArgs.spec("bench: expected PATH [KEY=VALUE...]")
+	}
+	spec, ret := newBenchSpec(args[1:])
+	if nil != ret {
+		return ret
+	}
+	abs, err := filepath.Abs(args[0])
+	if nil != err {
+		return rcInvalidPath.specf("bench(%q): filepath.Abs(): %s", args[0], err)
+	}
+	if info, err := os.Stat(abs); nil != err || !info.IsDir() {
+		return rcInvalidPath.specf("bench(%q): not a directory", abs)
+	}
+
+	rawLog.logf("%s", abs)
+
+	// the file system cache is cold for the first traversal only, so it is
+	// performed only once; the later stages do not touch the file system.
+	file, walk := benchWalk(abs)
+	rawLog.logf("  walk: %d entries, %d regular files in %s (%.0f/s)",
+		walk.count, len(file), walk.elapsed.Round(time.Microsecond), walk.rate())
+
+	var best [2]*BenchResult
+	keep := func(i int, r *BenchResult) {
+		rawLog.logf("  %s", r)
+		if nil == best[i] || r.rate() > best[i].rate() {
+			best[i] = r
+		}
+	}
+
+	for _, w := range spec.workers {
+		for _, b := range spec.batch {
+			keep(0, benchClassify(abs, file, w, b))
+		}
+	}
+
+	media := []*benchFile{}
+	for _, f := range file {
+		if nil != f.record && uint(len(media)) < spec.limit {
+			media = append(media, f)
+		}
+	}
+	if 0 == len(media) {
+		rawLog.log("  insert: (no media found)")
+	} else {
+		dir := benchDataDir(options)
+		for _, w := range spec.workers {
+			for _, b := range spec.batch {
+				r, ret := benchInsert(options, dir, media, w, b)
+				if nil != ret {
+					return ret
+				}
+				keep(1, r)
+			}
+		}
+	}
+
+	for _, r := range best {
+		if nil != r {
+			rawLog.logf("  fastest %s: workers=%d batch=%d (%.0f/s)", r.stage, r.workers, r.batch, r.rate())
+		}
+	}
+	return nil
+}
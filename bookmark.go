@@ -0,0 +1,245 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: bookmark.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the bookmarks dialog, used to save and remove the named playback
+//    positions of a single audiobook, and to resume its playback from one of
+//    them. the bookmarks are stored in the audio's database record.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for the bookmarks dialog.
+const (
+	// option listed first in the bookmarks drop-down, for adding a new one.
+	bookmarkNew = "(new bookmark)"
+)
+
+// type BookmarkView is the dialog used to manage the bookmarks of the audiobook
+// currently selected in the media browser.
+type BookmarkView struct {
+	*tview.Form
+	markDropDown *tview.DropDown
+	nameInput    *tview.InputField
+	posInput     *tview.InputField
+	audio        *AudioMedia
+	item         *mediaItem
+	record       *RecordID
+	layout       *Layout
+	focusPage    string
+	focusNext    FocusDelegator
+	focusPrev    FocusDelegator
+}
+
+// function newBookmarkView() allocates and initializes the dialog widgets.
+func newBookmarkView(ui *tview.Application, page string, lib []*Library) *BookmarkView {
+
+	v := BookmarkView{
+		Form:         nil,
+		markDropDown: nil,
+		nameInput:    nil,
+		posInput:     nil,
+		audio:        nil,
+		item:         nil,
+		record:       nil,
+		layout:       nil,
+		focusPage:    page,
+		focusNext:    nil,
+		focusPrev:    nil,
+	}
+
+	form := tview.NewForm().
+		AddDropDown("Bookmark:", []string{bookmarkNew}, 0, nil).
+		AddInputField("Name:", "", 30, nil, nil).
+		AddInputField("Position (H:MM:SS):", "", 10, nil, nil).
+		AddButton("Resume", v.resume).
+		AddButton("Save", v.save).
+		AddButton("Remove", v.remove).
+		AddButton("Cancel", v.cancel).
+		SetLabelColor(colorScheme.inactiveMenuText).
+		SetFieldTextColor(colorScheme.inactiveMenuText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	form.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.Form = form
+	v.markDropDown = form.GetFormItem(0).(*tview.DropDown)
+	v.nameInput = form.GetFormItem(1).(*tview.InputField)
+	v.posInput = form.GetFormItem(2).(*tview.InputField)
+
+	return &v
+}
+
+func (v *BookmarkView) desc() string { return "" }
+func (v *BookmarkView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *BookmarkView) page() string         { return v.focusPage }
+func (v *BookmarkView) next() FocusDelegator { return v.focusNext }
+func (v *BookmarkView) prev() FocusDelegator { return v.focusPrev }
+func (v *BookmarkView) focus() {
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.Form)
+}
+func (v *BookmarkView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function setAudio() populates the dialog with the bookmarks of the given
+// audio, initially choosing the first of them (if any).
+func (v *BookmarkView) setAudio(item *mediaItem, audio *AudioMedia, record *RecordID) {
+
+	v.item = item
+	v.audio = audio
+	v.record = record
+
+	mark := []string{bookmarkNew}
+	for _, b := range audio.Bookmarks {
+		mark = append(mark, b.String())
+	}
+	curr := 0
+	if len(audio.Bookmarks) > 0 {
+		curr = 1
+	}
+	v.markDropDown.SetOptions(mark, v.chooseBookmark).SetCurrentOption(curr)
+	v.chooseBookmark("", curr)
+
+	v.SetTitle(fmt.Sprintf(" Bookmarks: [#%06x]%s ",
+		colorScheme.highlightPrimary.Hex(), audio.Name))
+}
+
+// function chooseBookmark() fills the name and position fields with those of
+// the bookmark chosen from the drop-down, or clears them for a new bookmark.
+func (v *BookmarkView) chooseBookmark(text string, index int) {
+	if index < 1 || index > len(v.audio.Bookmarks) {
+		v.nameInput.SetText("")
+		v.posInput.SetText("")
+		return
+	}
+	b := v.audio.Bookmarks[index-1]
+	v.nameInput.SetText(b.Name)
+	v.posInput.SetText(b.timestamp())
+}
+
+// function name() returns the bookmark name entered in the dialog.
+func (v *BookmarkView) name() string {
+	return strings.TrimSpace(v.nameInput.GetText())
+}
+
+// function save() stores the bookmark entered in the dialog in the audio's
+// database record, replacing any bookmark of the same name.
+func (v *BookmarkView) save() {
+
+	name := v.name()
+	if "" == name {
+		warnLog.logf("(ignored) bookmark name is required")
+		return
+	}
+	pos, err := parsePosition(v.posInput.GetText())
+	if nil != err {
+		warnLog.logf("(ignored) %s", err)
+		return
+	}
+	col := v.item.SourceLibrary.db.col[ecMedia][mkAudio]
+	if mark, ret := v.audio.addBookmark(col, v.record.id, true, name, pos); nil != ret {
+		warnLog.log(ret)
+	} else {
+		infoLog.logf("saved bookmark %s: %s", mark, v.audio.Name)
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function remove() deletes the bookmark named in the dialog from the audio's
+// database record.
+func (v *BookmarkView) remove() {
+
+	name := v.name()
+	col := v.item.SourceLibrary.db.col[ecMedia][mkAudio]
+	if found, ret := v.audio.removeBookmark(col, v.record.id, true, name); nil != ret {
+		warnLog.log(ret)
+	} else if !found {
+		warnLog.logf("(ignored) no such bookmark: %q", name)
+		return
+	} else {
+		infoLog.logf("removed bookmark %q: %s", name, v.audio.Name)
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function resume() plays the audio from the position of the bookmark named in
+// the dialog.
+func (v *BookmarkView) resume() {
+
+	name := v.name()
+	if v.audio.findBookmark(name) < 0 {
+		warnLog.logf("(ignored) no such bookmark: %q (save it first)", name)
+		return
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+	v.layout.resume(v.item, v.audio, name)
+}
+
+// function cancel() closes the dialog without modifying anything.
+func (v *BookmarkView) cancel() {
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function bookmarkEvent() handles the key opening the bookmarks dialog for the
+// audiobook currently selected in the media browser. returns true if the key
+// was handled.
+func (l *Layout) bookmarkEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'b' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("edit bookmarks"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item || mkAudio != item.Kind {
+		warnLog.logf("(ignored) bookmarks may only be kept for audio")
+		return true
+	}
+	record, ok := l.browseView.record[item.Media]
+	if !ok {
+		warnLog.logf("(ignored) database record unknown: %s", item.AbsName)
+		return true
+	}
+	audio, ok := record.rec.(*AudioMedia)
+	if !ok {
+		warnLog.logf("(ignored) database record is not audio: %s", item.AbsName)
+		return true
+	}
+	// bookmarks are only useful for long-form audio, but any already saved
+	// remain available even if the audio no longer looks like an audiobook.
+	if !audio.isAudiobook() && 0 == len(audio.Bookmarks) {
+		warnLog.logf("(ignored) bookmarks may only be kept for audiobooks (%s files, or at least %s): %s",
+			strings.Join(audiobookExt, ", "), formatSizeApprox(uint64(minAudiobookSize)), item.AbsName)
+		return true
+	}
+	l.bookmarks.setAudio(item, audio, record)
+	l.focusQueue <- l.bookmarks
+	return true
+}
@@ -16,7 +16,10 @@
 package main
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell"
 	"github.com/rivo/tview"
@@ -35,6 +38,13 @@ type mediaItem struct {
 	MainText      string   // The main text of the list item.
 	SecondaryText string   // A secondary text to be shown underneath the main text.
 	Selected      func()   // The optional function which is called when the item is selected.
+	Marked        bool     // whether or not the item is marked for batch operations.
+
+	// the films of the item's collection collapsed beneath it, if any, along
+	// with the name of the collection (see collection.go).
+	Collapsed   []*mediaItem
+	Collection  string
+	CollapsedIn *mediaItem // the item beneath which this item is collapsed.
 }
 
 // function isValidIndex() checks if a given index is valid (in-range) for the
@@ -130,12 +140,24 @@ type Browser struct {
 	// The hidden items of the list.
 	hiddenItem []*mediaItem
 
+	// The database record of each item's media, required to modify it.
+	record map[*Media]*RecordID
+
 	// The index of the currently selected item.
 	currentItem int
 
 	// The offset to ensure our currently selected item remains in view.
 	viewOffset int
 
+	// The order in which items are sorted.
+	sortOrder BrowseSort
+
+	// The name of the user-defined field items are sorted by (bsField only).
+	sortField string
+
+	// The search currently filtering the list, if any (see search.go).
+	search *BrowserSearch
+
 	// Whether or not to show the secondary item texts.
 	showSecondaryText bool
 
@@ -164,6 +186,9 @@ type Browser struct {
 
 	// An optional function which is called when the user presses the Escape key.
 	done func()
+
+	// An optional function returning the text appended to an item's main text.
+	itemText func(item *mediaItem) string
 }
 
 // newBrowser returns a new form.
@@ -172,6 +197,10 @@ func newBrowser() *Browser {
 		Box:                     tview.NewBox(),
 		visibleItem:             []*mediaItem{},
 		hiddenItem:              []*mediaItem{},
+		record:                  map[*Media]*RecordID{},
+		sortOrder:               bsName,
+		sortField:               "",
+		search:                  nil,
 		showSecondaryText:       true,
 		mainTextColor:           colorScheme.activeText,
 		secondaryTextColor:      colorScheme.inactiveText,
@@ -199,7 +228,9 @@ func (l *Browser) showLibrary(library *Library) {
 		// a nil library means no filtering, display all data items from all
 		// libraries.
 		for _, m := range allItems {
-			m.showItem()
+			if nil == m.CollapsedIn {
+				m.showItem()
+			}
 		}
 	} else {
 		//
@@ -227,7 +258,7 @@ func (l *Browser) showLibrary(library *Library) {
 		//
 		for i := len(allItems) - 1; i >= 0; i-- {
 			m := allItems[i]
-			if m.SourceLibrary != library {
+			if m.SourceLibrary != library || nil != m.CollapsedIn {
 				m.hideItem()
 			} else {
 				m.showItem()
@@ -247,6 +278,106 @@ func (l *Browser) setCurrentItem(index int) *Browser {
 	return l
 }
 
+// function moveCurrentItem() moves the current selection by the given number
+// of items, wrapping around either end of the list. this triggers a "changed"
+// event if the selection moved.
+func (l *Browser) moveCurrentItem(delta int) *Browser {
+	if 0 == len(l.visibleItem) {
+		return l
+	}
+	previousItem := l.currentItem
+	l.currentItem += delta
+	if l.currentItem < 0 {
+		l.currentItem = len(l.visibleItem) - 1
+	} else if l.currentItem >= len(l.visibleItem) {
+		l.currentItem = 0
+	}
+	if l.currentItem != previousItem && l.changed != nil {
+		item := l.visibleItem[l.currentItem]
+		l.changed(l.currentItem, item.MainText, item.SecondaryText)
+	}
+	return l
+}
+
+// function activateCurrentItem() invokes the "selected" callbacks of the
+// currently selected item, exactly as if the user had pressed Enter.
+func (l *Browser) activateCurrentItem() *Browser {
+	if isValidIndex(l.visibleItem, l.currentItem) {
+		item := l.visibleItem[l.currentItem]
+		if item.Selected != nil {
+			item.Selected()
+		}
+		if l.selected != nil {
+			l.selected(l.currentItem, item.MainText, item.SecondaryText)
+		}
+	}
+	return l
+}
+
+// function toggleMarkCurrentItem() toggles the mark on the currently selected
+// item.
+func (l *Browser) toggleMarkCurrentItem() *Browser {
+	if isValidIndex(l.visibleItem, l.currentItem) {
+		item := l.visibleItem[l.currentItem]
+		item.Marked = !item.Marked
+	}
+	return l
+}
+
+// function unmarkAll() clears the mark on all items, visible or hidden.
+func (l *Browser) unmarkAll() *Browser {
+	for _, m := range l.visibleItem {
+		m.Marked = false
+	}
+	for _, m := range l.hiddenItem {
+		m.Marked = false
+	}
+	return l
+}
+
+// function markedItems() returns all marked items that are currently visible.
+// items hidden by a filter are never affected by batch operations, even if
+// they were marked before they were hidden.
+func (l *Browser) markedItems() []*mediaItem {
+	marked := []*mediaItem{}
+	for _, m := range l.visibleItem {
+		if m.Marked {
+			marked = append(marked, m)
+		}
+	}
+	return marked
+}
+
+// function setRecord() associates the given media with its database record.
+func (l *Browser) setRecord(media *Media, record *RecordID) *Browser {
+	l.record[media] = record
+	return l
+}
+
+// function currentMediaItem() returns the currently selected item, or nil if
+// there are no visible items.
+func (l *Browser) currentMediaItem() *mediaItem {
+	if isValidIndex(l.visibleItem, l.currentItem) {
+		return l.visibleItem[l.currentItem]
+	}
+	return nil
+}
+
+// function selectPath() selects the first visible item of the given library
+// located at the given path, or anywhere beneath it if the path is a directory.
+// returns true if such an item was found.
+func (l *Browser) selectPath(library *Library, absPath string) bool {
+	dir := strings.TrimSuffix(absPath, "/") + "/"
+	for i, item := range l.visibleItem {
+		if item.SourceLibrary == library &&
+			(item.AbsPath == absPath || strings.HasPrefix(item.AbsPath, dir)) {
+			l.setCurrentItem(i)
+			return true
+		}
+	}
+	return false
+}
+
 // getCurrentItem returns the index of the currently selected list item.
 func (l *Browser) getCurrentItem() int {
 	return l.currentItem
@@ -317,6 +448,13 @@ func (l *Browser) setDoneFunc(handler func()) *Browser {
 	return l
 }
 
+// setItemTextFunc sets a function returning the text appended to the main text
+// of each item when it is drawn, e.g. its status.
+func (l *Browser) setItemTextFunc(handler func(item *mediaItem) string) *Browser {
+	l.itemText = handler
+	return l
+}
+
 // removeItem removes the item with the given index (starting at 0) from the
 // list. Does nothing if the index is out of range. This triggers a "changed"
 // event if and only if the currently selected item is changed because of the
@@ -365,23 +503,13 @@ func (l *Browser) removeItem(index int) *Browser {
 // the media item library.
 func (l *Browser) positionForMediaItem(media *Media) (int, string, string) {
 
-	// determines WHEN the discovered item (discoName, discoPath) should be
-	// inserted based on the current item (currName, currPath) iteration.
-	shouldInsert := func(discoName, discoPath, currName, currPath string) bool {
-
-		// sorted by name
-		return (currName == discoName && currPath >= discoPath) || (currName >= discoName)
-
-		// sorted by path
-		//return (currPath == discoPath && currName >= discoName) || (currPath >= discoPath)
-	}
-
 	// the formatting/appearance to use for the item's displayed text.
 	fmtPrimary := func(m *Media) string { return m.AbsName }
 	fmtSecondary := func(m *Media) string { return m.AbsPath }
 
 	primary := fmtPrimary(media)
 	secondary := fmtSecondary(media)
+	disco := l.sortKey(media, primary, secondary)
 
 	// append by default, because we did not find an item that already exists in
 	// our list which should appear after our new item we are trying to insert
@@ -390,15 +518,9 @@ func (l *Browser) positionForMediaItem(media *Media) (int, string, string) {
 	if numItems := position; numItems > 0 {
 		for i := 0; i < numItems; i++ {
 
-			itemName, itemPath := l.getItemText(i)
-
-			insert := shouldInsert(
-				strings.ToUpper(primary),
-				strings.ToUpper(secondary),
-				strings.ToUpper(itemName),
-				strings.ToUpper(itemPath))
-
-			if insert {
+			// insert before the first item that should not be listed before the
+			// discovered item.
+			if !l.itemLess(l.itemSortKey(l.visibleItem[i]), disco) {
 				position = i
 				break
 			}
@@ -407,6 +529,102 @@ func (l *Browser) positionForMediaItem(media *Media) (int, string, string) {
 	return position, primary, secondary
 }
 
+// type itemKey holds the upper-case strings by which a media item is sorted.
+type itemKey struct {
+	album string // album and track number (see function sortKey())
+	field string // value of the user-defined field (see function fieldSortKey())
+	name  string
+	path  string
+}
+
+// function sortKey() returns the key by which the given media, listed with the
+// given main and secondary text, is sorted. the album is only determined when
+// sorting by album, since it requires the media's database record. audio
+// without an album, and video, are listed after all albums. likewise, media
+// without a value of the user-defined field sorted by are listed last.
+func (l *Browser) sortKey(media *Media, mainText, secondaryText string) itemKey {
+	key := itemKey{
+		album: "",
+		field: "",
+		name:  strings.ToUpper(mainText),
+		path:  strings.ToUpper(secondaryText),
+	}
+	if bsAlbum == l.sortOrder {
+		key.album = "\xff"
+		if record, ok := l.record[media]; ok {
+			if audio, ok := record.rec.(*AudioMedia); ok && "" != audio.Album {
+				track := audio.Track
+				if track < 0 {
+					track = 0
+				}
+				key.album = fmt.Sprintf("%s\x00%09d", strings.ToUpper(audio.Album), track)
+			}
+		}
+	}
+	if bsField == l.sortOrder {
+		key.field = "\xff"
+		if val, ok := media.Fields[l.sortField]; ok {
+			key.field = fieldSortKey(val)
+		}
+	}
+	return key
+}
+
+// function itemSortKey() returns the key by which the given media item is
+// sorted.
+func (l *Browser) itemSortKey(item *mediaItem) itemKey {
+	return l.sortKey(item.Media, item.MainText, item.SecondaryText)
+}
+
+// function itemLess() returns true if an item with the given sort key should be
+// listed before another item with the other given sort key, according to the
+// Browser's current sort order. comparison is case-insensitive.
+func (l *Browser) itemLess(a, b itemKey) bool {
+	switch l.sortOrder {
+	case bsPath:
+		// sorted by path
+		return a.path < b.path || (a.path == b.path && a.name < b.name)
+	case bsAlbum:
+		// sorted by album and track, then by name
+		return a.album < b.album || (a.album == b.album &&
+			(a.name < b.name || (a.name == b.name && a.path < b.path)))
+	case bsField:
+		// sorted by user-defined field, then by name
+		return a.field < b.field || (a.field == b.field &&
+			(a.name < b.name || (a.name == b.name && a.path < b.path)))
+	default:
+		// sorted by name
+		return a.name < b.name || (a.name == b.name && a.path < b.path)
+	}
+}
+
+// function setSortOrder() changes the order in which items are listed, and
+// immediately re-sorts all visible items. the given field name is only used
+// when sorting by a user-defined field.
+func (l *Browser) setSortOrder(order BrowseSort, field string) *Browser {
+	if bsField != order {
+		field = ""
+	}
+	if order == l.sortOrder && field == l.sortField {
+		return l
+	}
+	l.sortOrder, l.sortField = order, field
+	l.sortItems(l.visibleItem)
+	return l
+}
+
+// function sortItems() sorts the given items according to the Browser's
+// current sort order.
+func (l *Browser) sortItems(item []*mediaItem) {
+	key := make(map[*mediaItem]itemKey, len(item))
+	for _, m := range item {
+		key[m] = l.itemSortKey(m)
+	}
+	sort.SliceStable(item, func(i, j int) bool {
+		return l.itemLess(key[item[i]], key[item[j]])
+	})
+}
+
 // addMediaItem adds a new item to the list. An item has a main text which will
 // be highlighted when selected. It also has a secondary text which is shown
 // underneath the main text (if it is set to visible) but which may remain
@@ -504,6 +722,8 @@ func (l *Browser) setItemText(index int, main, secondary string) *Browser {
 func (l *Browser) clear() *Browser {
 	l.visibleItem = nil
 	l.hiddenItem = nil
+	l.record = map[*Media]*RecordID{}
+	l.search = nil
 	l.currentItem = 0
 	return l
 }
@@ -582,11 +802,42 @@ func (l *Browser) Draw(screen tcell.Screen) {
 			break
 		}
 
-		// Main text.
-		tview.Print(screen, item.MainText, x, y, width, tview.AlignLeft, l.mainTextColor)
+		// Main text, with an indicator in front of marked items (and of items
+		// from offline libraries or whose files are missing, if states are
+		// marked by glyphs).
+		isCurrent := index == l.currentItem && (!l.selectedFocusOnly || l.HasFocus())
+		isGlyph, glyph := indicators()
+		mainText := item.MainText + collectionText(item)
+		if nil != l.search {
+			if match, ok := l.search.match[item.Media]; ok {
+				mainText = match.mainText(item) + collectionText(item)
+			}
+		}
+		if nil != l.itemText {
+			mainText += l.itemText(item)
+		}
+		if nil != item.SourceLibrary && item.SourceLibrary.isOffline() {
+			mainText = indicator(ikOffline) + mainText
+		} else if nil != item.SourceLibrary && item.SourceLibrary.isMissing(item.AbsPath) {
+			mainText = indicator(ikError) + mainText
+		}
+		if item.Marked {
+			mainText = fmt.Sprintf("[#%06x]%s[-] %s", colorScheme.highlightPrimary.Hex(),
+				glyph[ikMarked], mainText)
+		}
+		// the current item is marked in a column of its own, so that the items
+		// do not shift as the cursor moves.
+		if isGlyph {
+			if isCurrent {
+				mainText = indicator(ikCurrent) + mainText
+			} else {
+				mainText = strings.Repeat(" ", utf8.RuneCountInString(indicator(ikCurrent))) + mainText
+			}
+		}
+		tview.Print(screen, mainText, x, y, width, tview.AlignLeft, l.mainTextColor)
 
 		// Background color of selected text.
-		if index == l.currentItem && (!l.selectedFocusOnly || l.HasFocus()) {
+		if isCurrent {
 			// we have to color each individual cell of the current row, so we
 			// iterate over each column.
 			for bx := 0; bx < width; bx++ {
@@ -0,0 +1,260 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: cache.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the artwork cache, a directory in the data directory holding all
+//    images generated or downloaded for media (thumbnails, posters, and
+//    waveforms). each image is identified by its kind and a key (e.g. the
+//    path of its media file). the total size of the cache is limited by
+//    option -cachesize; once exceeded, the least recently used images are
+//    evicted. the "cache" command reports the size of the cache, and
+//    "cache clear" removes every image.
+//
+// =============================================================================
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// local unexported constants for the artwork cache.
+const (
+	cacheDirName    = "cache" // directory of the cache in the data directory
+	cacheTempSuffix = ".tmp"  // suffix of images being written
+)
+
+// type ArtworkKind identifies the kind of an image in the artwork cache.
+type ArtworkKind int
+
+const (
+	akUnknown   ArtworkKind = iota - 1 // = -1
+	akThumbnail                        // =  0
+	akPoster                           // =  1
+	akWaveform                         // =  2
+	akCOUNT                            // =  3
+)
+
+var (
+	// variable artworkKindName maps the ArtworkKind enum values to the name of
+	// the cache subdirectory holding images of that kind.
+	artworkKindName = [akCOUNT]string{
+		"thumbnails", "posters", "waveforms",
+	}
+
+	// variable artworkCache is the artwork cache of the data directory, or nil
+	// if it has not been opened.
+	artworkCache *ArtworkCache
+)
+
+// type ArtworkCache is a size-limited directory of images with least recently
+// used eviction. it is safe for concurrent use.
+type ArtworkCache struct {
+	sync.Mutex
+	path  string // directory of the cache
+	limit uint64 // max total size (bytes) of all images (0 = unlimited)
+}
+
+// type ArtworkFile is a single image in the artwork cache.
+type ArtworkFile struct {
+	path string
+	size uint64
+	used time.Time // time of last use, i.e. the modification time
+}
+
+// function newArtworkCache() creates the artwork cache of the data directory
+// given by the options. the cache directory is not created until an image is
+// stored.
+func newArtworkCache(opt *Options) *ArtworkCache {
+	return &ArtworkCache{
+		path:  filepath.Join(opt.LibData.string, cacheDirName),
+		limit: opt.CacheSize.uint64,
+	}
+}
+
+// function file() returns the path of the image of the given kind and key,
+// which has the given file name extension (e.g. ".jpg").
+func (c *ArtworkCache) file(kind ArtworkKind, key, ext string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(c.path, artworkKindName[kind], hex.EncodeToString(sum[:])+ext)
+}
+
+// function get() returns the path of the image of the given kind and key, and
+// whether or not it is cached. the image is marked as used.
+func (c *ArtworkCache) get(kind ArtworkKind, key, ext string) (string, bool) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	p := c.file(kind, key, ext)
+	if _, err := os.Stat(p); nil != err {
+		return "", false
+	}
+	now := time.Now()
+	if err := os.Chtimes(p, now, now); nil != err {
+		warnLog.tracef("cannot mark cached image as used: %s", err)
+	}
+	return p, true
+}
+
+// function find() returns the path of the image of the given kind and key with
+// any file name extension, and whether or not it is cached. the image is not
+// marked as used.
+func (c *ArtworkCache) find(kind ArtworkKind, key string) (string, bool) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	match, _ := filepath.Glob(c.file(kind, key, ".*"))
+	for _, p := range match {
+		if !strings.HasSuffix(p, cacheTempSuffix) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// function put() stores the given image of the given kind and key, replacing
+// any image already cached, and then evicts the least recently used images if
+// the cache is over its size limit. returns the path of the stored image.
+func (c *ArtworkCache) put(kind ArtworkKind, key, ext string, data []byte) (string, *ReturnCode) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	p := c.file(kind, key, ext)
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); nil != err {
+		return "", rcInvalidPath.specf("put(%q): os.MkdirAll(): %s", key, err)
+	}
+	// write a temporary file first so that no partial image is ever cached.
+	tmp := p + cacheTempSuffix
+	if err := ioutil.WriteFile(tmp, data, 0644); nil != err {
+		os.Remove(tmp)
+		return "", rcInvalidFile.specf("put(%q): ioutil.WriteFile(): %s", key, err)
+	}
+	if err := os.Rename(tmp, p); nil != err {
+		os.Remove(tmp)
+		return "", rcInvalidFile.specf("put(%q): os.Rename(): %s", key, err)
+	}
+	if ret := c.evict(p); nil != ret {
+		warnLog.log(ret)
+	}
+	return p, nil
+}
+
+// function list() returns every image in the cache, along with their total
+// size. the lock must be held by the caller.
+func (c *ArtworkCache) list() ([]*ArtworkFile, uint64) {
+	list, total := []*ArtworkFile{}, uint64(0)
+	filepath.Walk(c.path,
+		func(p string, info os.FileInfo, err error) error {
+			if nil == err && info.Mode().IsRegular() {
+				f := &ArtworkFile{path: p, size: uint64(info.Size()), used: info.ModTime()}
+				list = append(list, f)
+				total += f.size
+			}
+			return nil
+		})
+	return list, total
+}
+
+// function evict() removes the least recently used images until the cache is
+// within its size limit, never removing the given image (the one most
+// recently stored). the lock must be held by the caller.
+func (c *ArtworkCache) evict(keep string) *ReturnCode {
+
+	if 0 == c.limit {
+		return nil
+	}
+	list, total := c.list()
+	if total <= c.limit {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].used.Before(list[j].used) })
+
+	count, freed := 0, uint64(0)
+	for _, f := range list {
+		if total <= c.limit {
+			break
+		}
+		if f.path == keep {
+			continue
+		}
+		if err := os.Remove(f.path); nil != err {
+			return rcInvalidFile.specf("evict(%q): os.Remove(): %s", f.path, err)
+		}
+		count++
+		freed += f.size
+		total -= f.size
+	}
+	infoLog.tracef("evicted %d cached images (%s)", count, formatSizeApprox(freed))
+	return nil
+}
+
+// function clear() removes every image in the cache. returns the number of
+// images removed and their total size.
+func (c *ArtworkCache) clear() (int, uint64, *ReturnCode) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	list, total := c.list()
+	if err := os.RemoveAll(c.path); nil != err {
+		return 0, 0, rcInvalidPath.specf("clear(%q): os.RemoveAll(): %s", c.path, err)
+	}
+	return len(list), total, nil
+}
+
+// function cacheCommand() implements the "cache" command. with no arguments,
+// the size of the cache is reported by kind of image. with argument "clear",
+// every image is removed.
+func cacheCommand(options *Options, args []string) *ReturnCode {
+
+	c := newArtworkCache(options)
+
+	if len(args) > 0 {
+		if "clear" != args[0] || len(args) > 1 {
+			return rcInvalidArgs.specf("cache: unknown argument: %q", args)
+		}
+		count, size, ret := c.clear()
+		if nil != ret {
+			return ret
+		}
+		rawLog.logf("%s: removed %d images, reclaimed %s",
+			c.path, count, formatSizeApprox(size))
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	limit := "unlimited"
+	if 0 != c.limit {
+		limit = formatSizeApprox(c.limit)
+	}
+	list, total := c.list()
+	rawLog.logf("%s: %d images, %s of %s", c.path, len(list), formatSizeApprox(total), limit)
+	for _, name := range artworkKindName {
+		count, size := 0, uint64(0)
+		for _, f := range list {
+			if filepath.Dir(f.path) == filepath.Join(c.path, name) {
+				count++
+				size += f.size
+			}
+		}
+		rawLog.logf("  %-10s  %6d  %10s", name, count, formatSizeApprox(size))
+	}
+	return nil
+}
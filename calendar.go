@@ -0,0 +1,235 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: calendar.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the calendar panel, which lists the media of the selected library
+//    one month at a time, grouped by week, by either the date each item was
+//    added to the library or its release date. this shows at a glance what
+//    landed in the library each week. the months are browsed with the arrow
+//    keys, and each item may be jumped to in the media browser.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// type CalendarDate is an enum identifying the date by which media are listed
+// in the calendar.
+type CalendarDate int
+
+const (
+	cdUnknown  CalendarDate = iota - 1 // = -1
+	cdAdded                            // = 0
+	cdReleased                         // = 1
+	cdCOUNT                            // = 2
+)
+
+var (
+	// variable calendarDateName maps the CalendarDate enum values to their
+	// description in the calendar's title.
+	calendarDateName = [cdCOUNT]string{
+		"date added", "release date",
+	}
+)
+
+// type CalendarView is the panel listing the media of one month by week.
+type CalendarView struct {
+	*tview.TreeView
+	month     time.Time    // first day of the month listed
+	date      CalendarDate // date by which media are listed
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// function newCalendarView() allocates and initializes the calendar panel.
+func newCalendarView(ui *tview.Application, page string, lib []*Library) *CalendarView {
+
+	v := CalendarView{
+		TreeView:  tview.NewTreeView(),
+		month:     time.Time{},
+		date:      cdAdded,
+		layout:    nil,
+		focusPage: page,
+		focusNext: nil,
+		focusPrev: nil,
+	}
+
+	v.TreeView.
+		SetGraphicsColor(colorScheme.inactiveText).
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.SetSelectedFunc(v.jump)
+	v.SetInputCapture(v.inputEvent)
+
+	return &v
+}
+
+func (v *CalendarView) desc() string { return "" }
+func (v *CalendarView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *CalendarView) page() string         { return v.focusPage }
+func (v *CalendarView) next() FocusDelegator { return v.focusNext }
+func (v *CalendarView) prev() FocusDelegator { return v.focusPrev }
+func (v *CalendarView) focus() {
+	if v.month.IsZero() {
+		now := time.Now()
+		v.month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	page := v.page()
+	v.refresh()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.TreeView)
+}
+func (v *CalendarView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function dateOf() returns the date by which the given media is listed, in
+// local time.
+func (v *CalendarView) dateOf(media *Media) time.Time {
+	if cdReleased == v.date {
+		return media.ReleaseDate.Local()
+	}
+	return media.TimeAdded.Local()
+}
+
+// function weekOf() returns the first day (Monday) of the week containing the
+// given date.
+func weekOf(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// function items() returns the media items of the library selected in the
+// library selection view (or of all libraries) dated within the current
+// month, in order of their date.
+func (v *CalendarView) items() []*mediaItem {
+
+	var lib *Library
+	if selected := v.layout.libSelect.selectedLibrary; selected != selectedLibraryAll {
+		lib = v.layout.lib[selected-1]
+	}
+	end := v.month.AddDate(0, 1, 0)
+
+	browser := v.layout.browseView.Browser
+	item := []*mediaItem{}
+	for _, list := range [][]*mediaItem{browser.visibleItem, browser.hiddenItem} {
+		for _, m := range list {
+			if nil != lib && m.SourceLibrary != lib {
+				continue
+			}
+			if t := v.dateOf(m.Media); !t.Before(v.month) && t.Before(end) {
+				item = append(item, m)
+			}
+		}
+	}
+	sort.SliceStable(item, func(i, j int) bool {
+		return v.dateOf(item[i].Media).Before(v.dateOf(item[j].Media))
+	})
+	return item
+}
+
+// function refresh() rebuilds the tree of the current month's media, grouped
+// by week.
+func (v *CalendarView) refresh() {
+
+	item := v.items()
+	root := tview.NewTreeNode(v.month.Format("January 2006")).
+		SetColor(colorScheme.activeText).
+		SetSelectable(false)
+
+	var weekNode *tview.TreeNode
+	var week time.Time
+	count := 0
+	for _, m := range item {
+		t := v.dateOf(m.Media)
+		if w := weekOf(t); nil == weekNode || !w.Equal(week) {
+			if nil != weekNode {
+				weekNode.SetText(fmt.Sprintf("%s (%d)", weekNode.GetText(), count))
+			}
+			week, count = w, 0
+			weekNode = tview.NewTreeNode(fmt.Sprintf("week of %s", week.Format("Mon 02 Jan"))).
+				SetColor(colorScheme.highlightPrimary)
+			root.AddChild(weekNode)
+		}
+		count++
+		weekNode.AddChild(
+			tview.NewTreeNode(fmt.Sprintf("%s  %s", t.Format("Mon 02"), m.Name)).
+				SetReference(m).
+				SetColor(colorScheme.inactiveMenuText))
+	}
+	if nil != weekNode {
+		weekNode.SetText(fmt.Sprintf("%s (%d)", weekNode.GetText(), count))
+	} else {
+		root.AddChild(tview.NewTreeNode("(nothing)").
+			SetColor(colorScheme.inactiveText).
+			SetSelectable(false))
+	}
+
+	v.SetRoot(root).SetCurrentNode(root)
+	if children := root.GetChildren(); len(children) > 0 && nil != weekNode {
+		v.SetCurrentNode(children[0])
+	}
+	v.SetTitle(fmt.Sprintf(" Calendar: %d items by %s (left/right: month, t: %s, enter: jump) ",
+		len(item), calendarDateName[v.date], calendarDateName[(v.date+1)%cdCOUNT]))
+}
+
+// function jump() is the event handler for selecting a tree node. weeks are
+// expanded or collapsed, and items are selected in the media browser.
+func (v *CalendarView) jump(node *tview.TreeNode) {
+
+	item, ok := node.GetReference().(*mediaItem)
+	if !ok {
+		node.SetExpanded(!node.IsExpanded())
+		return
+	}
+	if v.layout.browseView.selectPath(item.SourceLibrary, item.AbsPath) {
+		v.layout.focusQueue <- v.layout.browseView
+	} else {
+		warnLog.logf("(ignored) item is not visible in the media browser: %s", item.Name)
+	}
+}
+
+// function inputEvent() handles the keys specific to the calendar panel.
+func (v *CalendarView) inputEvent(event *tcell.EventKey) *tcell.EventKey {
+
+	switch event.Key() {
+	case tcell.KeyLeft:
+		v.month = v.month.AddDate(0, -1, 0)
+		v.refresh()
+		return nil
+	case tcell.KeyRight:
+		v.month = v.month.AddDate(0, 1, 0)
+		v.refresh()
+		return nil
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 't', 'T':
+			v.date = (v.date + 1) % cdCOUNT
+			v.refresh()
+			return nil
+		}
+	}
+	return event
+}
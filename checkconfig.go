@@ -0,0 +1,155 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: checkconfig.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "check-config" command, a dry run of the configuration: it
+//    validates the config file and every option (given on the command line,
+//    in the environment, or in the config file), verifies the external tools and files required by the
+//    features enabled, and verifies the given library paths are accessible,
+//    reporting every problem found at once rather than stopping at the
+//    first. nothing is written to disk.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for the configuration check.
+const (
+	checkConfigCommandName = "check-config"
+)
+
+// type ConfigCheck collects the problems found by the configuration check,
+// along with notes on features that are unavailable but not misconfigured.
+type ConfigCheck struct {
+	problem []string
+	note    []string
+}
+
+// function fail() records a problem.
+func (c *ConfigCheck) fail(format string, v ...interface{}) {
+	c.problem = append(c.problem, fmt.Sprintf(format, v...))
+}
+
+// function notice() records a note.
+func (c *ConfigCheck) notice(format string, v ...interface{}) {
+	c.note = append(c.note, fmt.Sprintf(format, v...))
+}
+
+// function tool() records a problem if the given external command, required
+// by the given feature, is not found in PATH.
+func (c *ConfigCheck) tool(name, feature string) {
+	if _, err := exec.LookPath(name); nil != err {
+		c.fail("%s requires %q, which was not found in PATH", feature, name)
+	}
+}
+
+// function checkConfigCommand() implements the "check-config" command.
+func checkConfigCommand(options *Options, args []string) *ReturnCode {
+
+	check := &ConfigCheck{problem: []string{}, note: []string{}}
+
+	for _, err := range options.ConfigErrors {
+		check.fail("%s", err)
+	}
+	for _, err := range optionErrors(options.Known) {
+		check.fail("%s", err)
+	}
+
+	if _, ok := options.Provided[options.Config.name]; ok {
+		if exists, _ := goutil.PathExists(options.Config.string); !exists {
+			check.fail("config file %q does not exist (see -%s)", options.Config.string, options.Config.name)
+		}
+	}
+	if info, err := os.Stat(options.LibData.string); nil == err && !info.IsDir() {
+		check.fail("data directory %q is not a directory (see -%s)", options.LibData.string, options.LibData.name)
+	}
+
+	// options of disabled features are ignored.
+	for _, gated := range []struct {
+		feature Feature
+		option  *Option
+	}{
+		{ffPoll, options.PollFreq},
+		{ffTargets, options.Target},
+		{ffSpectrum, options.SpectrumFifo},
+	} {
+		if _, ok := options.Provided[gated.option.name]; ok && !gated.feature.isEnabled() {
+			check.notice("option -%s is ignored unless feature %q is enabled (see -%s)",
+				gated.option.name, featureFlag[gated.feature].name, options.FeatureEnable.name)
+		}
+	}
+
+	// external tools of the features enabled.
+	if len(options.Target.StringList) > 0 && ffTargets.isEnabled() {
+		check.tool(targetSSH, fmt.Sprintf("option -%s", options.Target.name))
+	}
+	if options.TmuxStatus.bool && "" != os.Getenv("TMUX") {
+		check.tool("tmux", fmt.Sprintf("option -%s", options.TmuxStatus.name))
+	}
+	if archiveListed(options, archiveRar) {
+		check.tool(archiveUnrar, fmt.Sprintf("option -%s", options.Archives.name))
+	}
+	if "" != options.AcoustIDKey.string {
+		check.tool(fpcalcCommand, "command \"identify\"")
+	} else {
+		check.notice("command \"identify\" is unavailable without an AcoustID API key (see -%s)",
+			options.AcoustIDKey.name)
+	}
+
+	// files named by options.
+	if path := options.SpectrumFifo.string; "" != path && ffSpectrum.isEnabled() {
+		if info, err := os.Stat(path); nil != err {
+			check.fail("spectrum FIFO %q: %s (see -%s)", path, err, options.SpectrumFifo.name)
+		} else if 0 == info.Mode()&os.ModeNamedPipe {
+			check.fail("spectrum FIFO %q is not a named pipe (see -%s)", path, options.SpectrumFifo.name)
+		}
+	}
+
+	for _, arg := range args {
+		abs, err := libraryPath(arg)
+		if nil != err {
+			check.fail("library %q: %s", arg, err)
+			continue
+		}
+		dir, err := os.Open(abs)
+		if nil != err {
+			check.fail("library %q is not accessible: %s", abs, err)
+			continue
+		}
+		info, err := dir.Stat()
+		if nil == err && info.IsDir() {
+			_, err = dir.Readdirnames(1)
+		} else if nil == err {
+			err = fmt.Errorf("not a directory")
+		}
+		dir.Close()
+		if nil != err && io.EOF != err {
+			check.fail("library %q is not accessible: %s", abs, err)
+		}
+	}
+
+	for _, s := range check.note {
+		rawLog.logf("note: %s", s)
+	}
+	if 0 == len(check.problem) {
+		rawLog.log("no problems found")
+		return nil
+	}
+	for _, s := range check.problem {
+		rawLog.logf("problem: %s", s)
+	}
+	return rcInvalidConfig.specf("%d problem(s) found", len(check.problem))
+}
@@ -0,0 +1,248 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: checksum.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the verification of the checksum files (sidecars) often found
+//    beside media releases: ".sfv" (CRC32), ".md5", ".sha1" and ".sha256"
+//    files listing the expected checksum of each file of the release. every
+//    file listed is hashed (by the workers of the hashing pool), and those
+//    whose checksum differs, or which are missing, are recorded as issues of
+//    the library, so that they show up in the issues panel.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// variable checksumAlgo maps the file name extension (lower case) of each
+	// kind of checksum file to the hash algorithm of its checksums.
+	checksumAlgo = map[string]func() hash.Hash{
+		".sfv":    func() hash.Hash { return crc32.NewIEEE() },
+		".md5":    md5.New,
+		".sha1":   sha1.New,
+		".sha256": sha256.New,
+	}
+
+	// variable checksumBSD matches a line of a checksum file in BSD format,
+	// e.g. "MD5 (file.mkv) = d41d8cd98f00b204e9800998ecf8427e".
+	checksumBSD = regexp.MustCompile(`^\w+ \((.+)\) = ([0-9a-fA-F]+)$`)
+)
+
+// type ChecksumEntry is a file listed by a checksum file, along with its
+// expected checksum.
+type ChecksumEntry struct {
+	sidecar string // absolute path of the checksum file
+	absPath string // absolute path of the file listed
+	sum     string // expected checksum (lower case hex)
+}
+
+// type ChecksumReport summarizes the verification of a library's checksum
+// files.
+type ChecksumReport struct {
+	sidecars   uint // number of checksum files read
+	verified   uint // number of files whose checksum matched
+	mismatched uint // number of files whose checksum differed
+	missing    uint // number of files which could not be read
+}
+
+// function String() creates a string representation of the ChecksumReport for
+// the log.
+func (r *ChecksumReport) String() string {
+	return fmt.Sprintf("%d verified, %d mismatched, %d missing (%d checksum files)",
+		r.verified, r.mismatched, r.missing, r.sidecars)
+}
+
+// function isChecksumFile() returns true if the given file name extension is
+// that of a checksum file.
+func isChecksumFile(ext string) bool {
+	_, ok := checksumAlgo[strings.ToLower(ext)]
+	return ok
+}
+
+// function parseChecksumFile() returns the files listed by the given checksum
+// file. lines of sfv files are "FILE CRC32", and lines of the other kinds are
+// "CHECKSUM  FILE" (as written by md5sum, etc., optionally with a '*' before
+// the file) or in BSD format. blank lines and comments are skipped.
+func parseChecksumFile(sidecar string) ([]*ChecksumEntry, error) {
+
+	f, err := os.Open(sidecar)
+	if nil != err {
+		return nil, err
+	}
+	defer f.Close()
+
+	sfv := ".sfv" == strings.ToLower(filepath.Ext(sidecar))
+	dir := filepath.Dir(sidecar)
+	entry := []*ChecksumEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if "" == line || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var name, sum string
+		if m := checksumBSD.FindStringSubmatch(line); nil != m {
+			name, sum = m[1], m[2]
+		} else if sfv {
+			i := strings.LastIndexAny(line, " \t")
+			if i < 0 {
+				continue
+			}
+			name, sum = strings.TrimSpace(line[:i]), line[i+1:]
+		} else {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				continue
+			}
+			sum, name = line[:i], strings.TrimPrefix(strings.TrimLeft(line[i:], " \t"), "*")
+		}
+		if _, err := hex.DecodeString(sum); nil != err || "" == name {
+			continue
+		}
+		entry = append(entry, &ChecksumEntry{
+			sidecar: sidecar,
+			absPath: filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(name, "\\", "/"))),
+			sum:     strings.ToLower(sum),
+		})
+	}
+	return entry, scanner.Err()
+}
+
+// function verify() hashes the listed file and compares it with its expected
+// checksum. returns nil if they agree.
+func (e *ChecksumEntry) verify() *ReturnCode {
+
+	f, err := os.Open(e.absPath)
+	if nil != err {
+		return rcInvalidFile.wrap(err, "verify(%q): listed by %q", e.absPath, filepath.Base(e.sidecar))
+	}
+	defer f.Close()
+
+	h := checksumAlgo[strings.ToLower(filepath.Ext(e.sidecar))]()
+	if _, err := io.Copy(h, f); nil != err {
+		return rcInvalidFile.wrap(err, "verify(%q): listed by %q", e.absPath, filepath.Base(e.sidecar))
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != e.sum {
+		return rcChecksumMismatch.specf("verify(%q): checksum %s, expected %s by %q",
+			e.absPath, sum, e.sum, filepath.Base(e.sidecar))
+	}
+	return nil
+}
+
+// function verifyChecksumEntry() verifies only the given file listed by the
+// given checksum file, e.g. when retrying an issue.
+func verifyChecksumEntry(sidecar, absPath string) *ReturnCode {
+	entry, err := parseChecksumFile(sidecar)
+	if nil != err {
+		return rcInvalidFile.wrap(err, "verifyChecksumEntry(%q)", sidecar)
+	}
+	for _, e := range entry {
+		if e.absPath == absPath {
+			return e.verify()
+		}
+	}
+	return rcInvalidFile.specf("verifyChecksumEntry(%q): no longer listed: %q", sidecar, absPath)
+}
+
+// function verifyChecksums() verifies every file listed by the checksum files
+// of the library, recording each mismatched or missing file as an issue. the
+// library may not be scanned meanwhile, since a scan discards the issues.
+func (l *Library) verifyChecksums() (*ChecksumReport, *ReturnCode) {
+
+	select {
+	case l.scanStart <- time.Now():
+		defer func() { <-l.scanStart }()
+	default:
+		return nil, rcLibraryBusy.specf("verifyChecksums(): library is scanning: %q", l.name)
+	}
+	ctx, end := l.beginOperation(context.Background(), "verify")
+	defer end()
+	if !isCLIMode {
+		l.busyState.inc()
+		defer l.busyState.dec()
+	}
+
+	start := time.Now()
+	report := &ChecksumReport{}
+	entry := []*ChecksumEntry{}
+	filepath.Walk(l.absPath, func(p string, info os.FileInfo, err error) error {
+		if nil != err {
+			return nil
+		}
+		if info.IsDir() {
+			if portableDataDirName == info.Name() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isChecksumFile(filepath.Ext(p)) {
+			return nil
+		}
+		list, err := parseChecksumFile(p)
+		if nil != err {
+			l.recordIssue(p, rcInvalidFile.wrap(err, "verifyChecksums(%q)", p))
+			return nil
+		}
+		report.sidecars++
+		entry = append(entry, list...)
+		return nil
+	})
+	if 0 == len(entry) {
+		infoLog.in(ctx).logf("no checksum files found: %q", l.name)
+		return report, nil
+	}
+	infoLog.in(ctx).logf("verifying %d files listed by %d checksum files: %q", len(entry), report.sidecars, l.name)
+
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	for _, e := range entry {
+		wait.Add(1)
+		go func(e *ChecksumEntry) {
+			defer wait.Done()
+			hashWorkers.acquire(l)
+			ret := e.verify()
+			hashWorkers.release()
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch {
+			case nil == ret:
+				report.verified++
+			case ret.is(rcChecksumMismatch):
+				report.mismatched++
+				l.recordChecksumIssue(e.sidecar, e.absPath, ret)
+			default:
+				report.missing++
+				l.recordChecksumIssue(e.sidecar, e.absPath, ret)
+			}
+		}(e)
+	}
+	wait.Wait()
+
+	infoLog.in(ctx).logf("finished verifying checksums: %q: %s in %s",
+		l.name, report, time.Since(start).Round(time.Millisecond))
+	return report, nil
+}
@@ -0,0 +1,283 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: classify.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "classify" command, which explains how a library scan would
+//    treat individual files: the file name extension table entry matched, the
+//    resulting media/support kind, the title and episode info parsed from the
+//    file name, and the subtitles/video associations the scan would consider.
+//
+// =============================================================================
+
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// variable episodePattern matches the season and episode numbers commonly
+	// embedded in the file names of TV series, e.g. "S01E02" or "1x02".
+	episodePattern = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bs(\d{1,2})[ ._-]?e(\d{1,3})\b`),
+		regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`),
+	}
+)
+
+// function parseEpisode() extracts the season and episode numbers from the
+// given file base name, if present.
+func parseEpisode(base string) (int, int, bool) {
+	for _, re := range episodePattern {
+		if m := re.FindStringSubmatch(base); nil != m {
+			season, _ := strconv.Atoi(m[1])
+			episode, _ := strconv.Atoi(m[2])
+			return season, episode, true
+		}
+	}
+	return 0, 0, false
+}
+
+// type classifyMatch is a candidate association found by one of the subtitles
+// heuristics of function findCandidates().
+type classifyMatch struct {
+	path      string
+	heuristic string
+}
+
+// function listDir() returns the absolute paths of all files in the given
+// directory having the given kind of media or support, which is determined by
+// the given classifier.
+func listDir(dir string, is func(absPath string) bool) []string {
+	found := []string{}
+	fds, err := os.Open(dir)
+	if nil != err {
+		return found
+	}
+	name, _ := fds.Readdirnames(0)
+	fds.Close()
+	for _, n := range name {
+		if p := filepath.Join(dir, n); is(p) {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// function isVideoFile() returns true if the given file would be classified
+// as video.
+func isVideoFile(absPath string) bool {
+	kind, _ := mediaKindOfFile(absPath, path.Ext(absPath))
+	return mkVideo == kind
+}
+
+// function isSupportFile() returns a classifier returning true if a given file
+// would be classified as the given kind of support.
+func isSupportFile(kind SupportKind) func(string) bool {
+	return func(absPath string) bool {
+		k, _ := supportKindOfFile(absPath, path.Ext(absPath))
+		return kind == k
+	}
+}
+
+// function fileBase() returns the file name of the given path without its file
+// name extension.
+func fileBase(p string) string {
+	name := filepath.Base(p)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// function videoCandidates() applies the same heuristics as function
+// findCandidates() to the file system surrounding the given support file,
+// rather than to a library database, returning the videos it would associate.
+// only the directories near the support file are inspected, so videos
+// elsewhere in the library with a matching base name are not reported.
+func videoCandidates(assoc *SubsAssoc, supportPath string) []classifyMatch {
+
+	dir := filepath.Dir(supportPath)
+	base := fileBase(supportPath)
+	match := []classifyMatch{}
+	seen := map[string]bool{}
+	add := func(p, h string) {
+		if !seen[p] {
+			seen[p] = true
+			match = append(match, classifyMatch{p, h})
+		}
+	}
+
+	video := listDir(dir, isVideoFile)
+	for _, v := range video {
+		// first: base name of support file matches base name of video.
+		if assoc.enabled(shBaseName) && fileBase(v) == base {
+			add(v, "same base name")
+		}
+		// second: support file is in a directory named after the video.
+		if assoc.enabled(shDirName) && fileBase(v) == filepath.Base(dir) {
+			add(v, "directory named after video")
+		}
+	}
+
+	// third: support file is in a common subtitles subdirectory next to videos.
+	probe := &Support{Entity: &Entity{AbsDir: dir}}
+	if found, parent := probe.isInSubtitlesSubdir(assoc); found && assoc.enabled(shSubdir) {
+		for _, v := range listDir(parent, isVideoFile) {
+			add(v, "subtitles subdirectory")
+		}
+	}
+
+	// fourth: only if nothing else matched, the directory has few videos.
+	if 0 == len(match) && assoc.enabled(shFewMedia) && uint(len(video)) <= assoc.maxMedia {
+		for _, v := range video {
+			add(v, "few videos in directory")
+		}
+	}
+
+	return match
+}
+
+// function supportCandidates() finds the support files of the given kind near
+// the given video that function videoCandidates() would associate with it.
+func supportCandidates(assoc *SubsAssoc, kind SupportKind, videoPath string) []classifyMatch {
+
+	dir := filepath.Dir(videoPath)
+	nearby := listDir(dir, isSupportFile(kind))
+
+	// include the support files in any subdirectory having one of the common
+	// names for subtitles directories (or named after the video itself).
+	fds, err := os.Open(dir)
+	if nil == err {
+		info, _ := fds.Readdir(0)
+		fds.Close()
+		for _, i := range info {
+			if !i.IsDir() {
+				continue
+			}
+			sub := filepath.Join(dir, i.Name())
+			probe := &Support{Entity: &Entity{AbsDir: sub}}
+			if found, _ := probe.isInSubtitlesSubdir(assoc); found || i.Name() == fileBase(videoPath) {
+				nearby = append(nearby, listDir(sub, isSupportFile(kind))...)
+			}
+		}
+	}
+
+	match := []classifyMatch{}
+	for _, s := range nearby {
+		for _, m := range videoCandidates(assoc, s) {
+			if m.path == videoPath {
+				match = append(match, classifyMatch{s, m.heuristic})
+			}
+		}
+	}
+	return match
+}
+
+// function classifyFile() prints how a library scan would treat the file at the
+// given path, using the given subtitles association heuristics.
+func classifyFile(assoc *SubsAssoc, p string) *ReturnCode {
+
+	abs, err := filepath.Abs(p)
+	if nil != err {
+		return rcInvalidPath.specf("classifyFile(%q): filepath.Abs(): %s", p, err)
+	}
+	info, err := os.Lstat(abs)
+	if nil != err {
+		return rcInvalidStat.specf("classifyFile(%q): os.Lstat(): %s", p, err)
+	}
+
+	rawLog.logf("%s", abs)
+
+	mode := info.Mode()
+	switch {
+	case (mode & os.ModeDir) > 0:
+		rawLog.log("  directory: traversed by scans, never indexed itself")
+		return nil
+	case (mode & os.ModeSymlink) > 0:
+		rawLog.log("  symlink: skipped by scans (unless option -followsymlinks is given)")
+		return nil
+	case (mode & (os.ModeDevice | os.ModeNamedPipe | os.ModeSocket | os.ModeCharDevice)) > 0:
+		rawLog.log("  special file: skipped by scans (not a regular file)")
+		return nil
+	}
+
+	ext := path.Ext(abs)
+	base := fileBase(abs)
+	if "" == ext {
+		rawLog.log("  extension: (none)")
+	} else {
+		rawLog.logf("  extension: %q", strings.ToLower(ext))
+	}
+
+	if kind, extName := mediaKindOfFile(abs, ext); mkUnknown != kind {
+		rawLog.logf("  matched: %s (media: %s)", extName, strings.ToLower(mediaColName[kind]))
+		if mkVideo == kind {
+			title, year := parseTitle(base)
+			rawLog.logf("  title: %q", title)
+			if year > 0 {
+				rawLog.logf("  year: %d", year)
+			}
+		} else {
+			rawLog.logf("  title: %q", base)
+		}
+		if season, episode, ok := parseEpisode(base); ok {
+			rawLog.logf("  episode: season %d, episode %d", season, episode)
+		}
+		if mkVideo == kind {
+			for _, sk := range []SupportKind{skSubtitles, skAudioTrack} {
+				desc := strings.ToLower(supportColName[sk])
+				match := supportCandidates(assoc, sk, abs)
+				if 0 == len(match) {
+					rawLog.logf("  %s: (none found nearby)", desc)
+				}
+				for _, m := range match {
+					rawLog.logf("  %s: %s [%s]", desc, m.path, m.heuristic)
+				}
+			}
+		}
+		return nil
+	}
+
+	if kind, extName := supportKindOfFile(abs, ext); skUnknown != kind {
+		rawLog.logf("  matched: %s (support: %s)", extName, strings.ToLower(supportColName[kind]))
+		if skAudioTrack == kind && isCommentaryName(base) {
+			rawLog.log("  commentary: yes")
+		}
+		match := videoCandidates(assoc, abs)
+		if 0 == len(match) {
+			rawLog.log("  video: (none found nearby; would remain an orphan)")
+		}
+		for _, m := range match {
+			rawLog.logf("  video: %s [%s]", m.path, m.heuristic)
+		}
+		return nil
+	}
+
+	rawLog.log("  matched: (nothing) -- ignored by scans")
+	return nil
+}
+
+// function classifyCommand() implements the "classify" command. no library is
+// opened, so only the subtitles heuristics configured for all libraries apply.
+func classifyCommand(options *Options, args []string) *ReturnCode {
+
+	if 0 == len(args) {
+		return rcInvalidArgs.spec("classify: no file paths provided")
+	}
+	assoc := newSubsAssoc(options, nil)
+	for i, p := range args {
+		if i > 0 {
+			rawLog.log()
+		}
+		if ret := classifyFile(assoc, p); nil != ret {
+			warnLog.log(ret)
+		}
+	}
+	return nil
+}
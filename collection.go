@@ -0,0 +1,253 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: collection.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the collections of films grouped in the media browser, e.g. a
+//    film and its sequels. a film belongs to the collection named by its tag
+//    "collection:NAME" (e.g. imported from a media server's export), or else
+//    to the collection named by its title without a trailing sequel number,
+//    e.g. "Toy Story 2" and "Toy Story 3" (and "Toy Story" itself) belong to
+//    collection "Toy Story". a collection has at least two films of the same
+//    library, one of which is tagged or numbered. episodes of a series are
+//    never collected. a collection may be collapsed in the media browser
+//    beneath its first film, and all of its films may be played in order.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// local unexported constants for film collections.
+const (
+	collectionTagPrefix = "collection:"
+)
+
+var (
+	// variable collectionSequel matches a title ending in a sequel number, e.g.
+	// "Toy Story 2", "Rocky IV", or "Kill Bill Vol 2".
+	collectionSequel = regexp.MustCompile(
+		`(?i)^(.*\S)\s+(?:part\s+|chapter\s+|vol\.?\s+|volume\s+)?(\d{1,2}|[ivx]{1,4})$`)
+
+	// variable romanNumeral maps the roman numerals recognized as sequel
+	// numbers to their value.
+	romanNumeral = map[string]int{
+		"i": 1, "ii": 2, "iii": 3, "iv": 4, "v": 5, "vi": 6, "vii": 7,
+		"viii": 8, "ix": 9, "x": 10, "xi": 11, "xii": 12,
+	}
+)
+
+// type CollectionMember is a film of a collection, in order of its sequel
+// number.
+type CollectionMember struct {
+	item   *mediaItem
+	order  int  // sequel number (1 = the original)
+	marked bool // true if tagged or numbered, rather than only sharing a title
+}
+
+// function collectionOf() returns the name of the collection to which the
+// given film belongs, along with its sequel number and whether it is tagged or
+// numbered. returns an empty name if the media cannot belong to a collection.
+func collectionOf(media *Media) (string, int, bool) {
+
+	if mkVideo != media.Kind {
+		return "", 0, false
+	}
+	if _, _, ok := seriesOf(media.AbsBase); ok {
+		return "", 0, false
+	}
+	// titles of media scanned before titles were parsed are their file name.
+	title := media.Title
+	if "" == title || media.AbsName == title {
+		title, _ = parseTitle(media.AbsBase)
+	}
+
+	name, order, numbered := title, 1, false
+	if m := collectionSequel.FindStringSubmatch(title); nil != m {
+		n, err := strconv.Atoi(m[2])
+		if nil != err {
+			n = romanNumeral[strings.ToLower(m[2])]
+		}
+		if n > 0 && n <= 20 {
+			name, order, numbered = m[1], n, true
+		}
+	}
+	for _, t := range media.Tags {
+		if strings.HasPrefix(strings.ToLower(t), collectionTagPrefix) {
+			if n := strings.TrimSpace(t[len(collectionTagPrefix):]); "" != n {
+				return n, order, true
+			}
+		}
+	}
+	return name, order, numbered
+}
+
+// function collection() returns the name and films of the collection of the
+// given item, in order of their sequel numbers, including any films currently
+// hidden in the browser. returns no films if the item is not in a collection.
+func (l *Browser) collection(item *mediaItem) (string, []*mediaItem) {
+
+	name, _, _ := collectionOf(item.Media)
+	if "" == name {
+		return "", nil
+	}
+	key := strings.ToLower(name)
+
+	member := []*CollectionMember{}
+	numbered := false
+	for _, list := range [][]*mediaItem{l.visibleItem, l.hiddenItem} {
+		for _, m := range list {
+			if m.SourceLibrary != item.SourceLibrary {
+				continue
+			}
+			n, order, marked := collectionOf(m.Media)
+			if strings.ToLower(n) != key {
+				continue
+			}
+			member = append(member, &CollectionMember{m, order, marked})
+			numbered = numbered || marked
+		}
+	}
+	if len(member) < 2 || !numbered {
+		return "", nil
+	}
+	sort.SliceStable(member, func(i, j int) bool {
+		if member[i].order != member[j].order {
+			return member[i].order < member[j].order
+		}
+		return strings.ToUpper(member[i].item.AbsName) < strings.ToUpper(member[j].item.AbsName)
+	})
+	film := make([]*mediaItem, len(member))
+	for i, m := range member {
+		film[i] = m.item
+	}
+	return name, film
+}
+
+// function toggleCollection() collapses the collection of the given item
+// beneath its first film, or expands it if already collapsed. returns false if
+// the item is not in a collection.
+func (l *Browser) toggleCollection(item *mediaItem) bool {
+
+	if len(item.Collapsed) > 0 {
+		for _, m := range item.Collapsed {
+			m.CollapsedIn = nil
+			m.showItem()
+		}
+		item.Collapsed, item.Collection = nil, ""
+		l.selectPath(item.SourceLibrary, item.AbsPath)
+		return true
+	}
+
+	name, film := l.collection(item)
+	if 0 == len(film) {
+		return false
+	}
+	head := film[0]
+	for _, m := range film[1:] {
+		// expand any collection collapsed beneath another film first.
+		for _, c := range m.Collapsed {
+			c.CollapsedIn = nil
+		}
+		m.Collapsed, m.Collection = nil, ""
+		m.CollapsedIn = head
+		m.hideItem()
+	}
+	head.Collapsed, head.Collection = film[1:], name
+	l.selectPath(head.SourceLibrary, head.AbsPath)
+	return true
+}
+
+// function playCollection() plays every film of the collection of the given
+// item in order, suspending the user interface until playback has finished.
+func (l *Layout) playCollection(item *mediaItem) {
+
+	name, film := l.browseView.collection(item)
+	if 0 == len(film) {
+		warnLog.logf("(ignored) not in a collection: %s", item.Name)
+		return
+	}
+
+	cmd, abs := []string{}, []string{}
+	for _, m := range film {
+		c := l.mediaCommand(m)
+		if "" == c {
+			warnLog.logf("(skipped) no playback command is configured: %s", m.Name)
+			continue
+		}
+		cmd = append(cmd, c)
+		abs = append(abs, m.AbsPath)
+	}
+	if 0 == len(cmd) {
+		return
+	}
+
+	infoLog.logf("playing collection %q (%d films)", name, len(cmd))
+	l.ui.Suspend(func() {
+		for i, c := range cmd {
+			if ret := extractMedia(abs[i]); nil != ret {
+				warnLog.log(ret)
+				return
+			}
+			run := playerCommand(c)
+			run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := run.Run(); nil != err {
+				warnLog.logf("playback failed: %s", err)
+				return
+			}
+		}
+	})
+}
+
+// function collectionEvent() handles the keys collapsing or expanding the
+// collection of the item currently selected in the media browser, and playing
+// all of its films. returns true if the key was handled.
+func (l *Layout) collectionEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || ('X' != er && 'A' != er) {
+		return false
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item {
+		warnLog.logf("(ignored) no item selected")
+		return true
+	}
+	switch er {
+	case 'X':
+		if busy {
+			warnLog.logf(busyMessage("collapse or expand a collection"))
+		} else if !l.browseView.toggleCollection(item) {
+			warnLog.logf("(ignored) not in a collection: %s", item.Name)
+		}
+	case 'A':
+		if busy {
+			warnLog.logf(busyMessage("play a collection"))
+		} else {
+			l.playCollection(item)
+		}
+	}
+	return true
+}
+
+// function collectionText() returns the text appended to the given item in the
+// media browser if a collection is collapsed beneath it.
+func collectionText(item *mediaItem) string {
+	if 0 == len(item.Collapsed) {
+		return ""
+	}
+	return fmt.Sprintf(" [#%06x](+%d in %s)[-]",
+		colorScheme.highlightPrimary.Hex(), len(item.Collapsed), item.Collection)
+}
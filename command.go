@@ -0,0 +1,174 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: command.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the subcommands that may be given as the first positional
+//    argument on the command line, which perform some one-off task and exit
+//    rather than opening libraries for browsing.
+//
+// =============================================================================
+
+package main
+
+// type Command describes a subcommand and the function that performs it.
+type Command struct {
+	name  string                               // first positional argument selecting the command
+	args  string                               // synopsis of the command's own arguments
+	usage string                               // description shown in the usage synopsis
+	run   func(*Options, []string) *ReturnCode // performs the command with its own arguments
+}
+
+var (
+	// variable command lists all known subcommands. it is populated by init()
+	// because the commands' functions may themselves refer to this list.
+	command []*Command
+)
+
+// function init() initializes the list of known subcommands.
+func init() {
+	command = []*Command{
+		{
+			name:  "classify",
+			args:  "PATH...",
+			usage: "explain how each file would be classified and associated by a library scan",
+			run:   classifyCommand,
+		},
+		{
+			name:  "changes",
+			args:  "LIBRARY [COUNT]",
+			usage: "list what was added, removed, moved, or modified in a library between its most recent scans",
+			run:   changesCommand,
+		},
+		{
+			name:  "merge",
+			args:  "SRC_LIBDATA",
+			usage: "merge the library databases of another data directory into the current one (see -libdata)",
+			run:   mergeCommand,
+		},
+		{
+			name:  "fixture",
+			args:  "DIR [KEY=VALUE...]",
+			usage: "generate a synthetic library tree and its golden classification (keys: audio, video, subs, junk, depth, unicode, seed)",
+			run:   fixtureCommand,
+		},
+		{
+			name:  "golden",
+			args:  "DIR",
+			usage: "classify the library tree of a fixture again and report any differences from its golden classification",
+			run:   goldenCommand,
+		},
+		{
+			name:  "bench",
+			args:  "PATH [KEY=VALUE...]",
+			usage: "measure the file system traversal, classification, and database insert rates of a library path (keys: workers, batch, limit; lists are comma-separated)",
+			run:   benchCommand,
+		},
+		{
+			name:  "import",
+			args:  "jellyfin|plex|itunes|rekordbox|history EXPORT LIBRARY",
+			usage: "seed the metadata of a library's media from a Jellyfin or Plex export, the playlists and ratings of an iTunes or Rekordbox library, or play counts from a listening history CSV (e.g. Last.fm), matching items by file path (see -remap), name, or title and album",
+			run:   importCommand,
+		},
+		{
+			name:  "export",
+			args:  "kodi LIBRARY [DIR] [-f|--force]",
+			usage: "write a Kodi .nfo file and artwork for each video of a library, next to each video or into a parallel tree at DIR (replaces existing files only if forced)",
+			run:   exportCommand,
+		},
+		{
+			name:  "identify",
+			args:  "LIBRARY [-f|--force]",
+			usage: "identify the title, artist, and album of a library's audio by Chromaprint fingerprint with AcoustID (see -acoustid), skipping audio already identified unless forced",
+			run:   identifyCommand,
+		},
+		{
+			name:  "databases",
+			args:  "",
+			usage: "list the library databases of the data directory (see -libdata) and whether each library still exists",
+			run:   databasesCommand,
+		},
+		{
+			name:  "relink",
+			args:  "UUID|OLD_PATH NEW_PATH",
+			usage: "associate the database of a library that was moved with its new path",
+			run:   relinkCommand,
+		},
+		{
+			name:  "prune",
+			args:  "[UUID...|all]",
+			usage: "list the databases whose library no longer exists, or remove those given (\"all\" removes every orphaned one)",
+			run:   pruneCommand,
+		},
+		{
+			name:  "gc",
+			args:  "[NAME...] [-f|--force]",
+			usage: "remove every database not referenced by an existing library, and the unregistered databases given by name, reporting the space reclaimed (asks first unless forced)",
+			run:   gcCommand,
+		},
+		{
+			name:  "cache",
+			args:  "[clear]",
+			usage: "report the size of the artwork cache (see -cachesize) by kind of image, or remove every image",
+			run:   cacheCommand,
+		},
+		{
+			name:  checkConfigCommandName,
+			args:  "[LIBRARY...]",
+			usage: "verify the options (from the command line and environment), the external tools and files they require, and the given library paths, reporting every problem found",
+			run:   checkConfigCommand,
+		},
+		{
+			name:  "doctor",
+			args:  "",
+			usage: "inspect the data directory, library databases and their lock files, external tools, and terminal, suggesting a fix for each issue found",
+			run:   doctorCommand,
+		},
+		{
+			name:  "errors",
+			args:  "[CODE]",
+			usage: "list the exit status codes and their meanings, or only the given code",
+			run:   errorsCommand,
+		},
+	}
+}
+
+// function findCommand() returns the subcommand with the given name, or nil if
+// there is no such command.
+func findCommand(name string) *Command {
+	for _, c := range command {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// function command() returns the subcommand named by the first positional
+// argument on the command line along with its remaining arguments, or nil if
+// the first positional argument does not name a subcommand (i.e. all positional
+// arguments are library paths). a library path that happens to have the same
+// name as a subcommand can be given as "./NAME".
+func (o *Options) command() (*Command, []string) {
+	args := o.Args()
+	if 0 == len(args) {
+		return nil, nil
+	}
+	if cmd := findCommand(args[0]); nil != cmd {
+		return cmd, args[1:]
+	}
+	return nil, nil
+}
+
+// function printCommands() writes the synopsis of each subcommand to the raw
+// logger, for inclusion in the usage synopsis.
+func printCommands() {
+	rawLog.log("commands:")
+	for _, c := range command {
+		rawLog.logf("  %s %s", c.name, c.args)
+		rawLog.logf("    \t%s", c.usage)
+	}
+}
@@ -0,0 +1,584 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: compare.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines a split-pane view showing the media of two libraries side by
+//    side, highlighting the items found in only one of them, along with the
+//    actions to copy or move media from one library into the other. the
+//    subtitles and audio tracks of a video go along with it. a media moved
+//    keeps its database record, which is moved into the other library's
+//    database, so moves are confirmed first.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/tview"
+)
+
+// type ComparePane identifies the left or right half of a CompareView.
+type ComparePane int
+
+const (
+	cpLeft  ComparePane = iota // = 0
+	cpRight                    // = 1
+	cpCOUNT                    // = 2
+)
+
+// type CompareView displays the media of two libraries in adjacent Browser
+// panes. items whose normalized title does not appear in the opposite pane are
+// highlighted.
+type CompareView struct {
+	*tview.Pages
+	confirm    *tview.Modal
+	confirming bool
+	pane       [cpCOUNT]*Browser
+	lib        [cpCOUNT]*Library
+	active     ComparePane
+	layout     *Layout
+	focusPage  string
+	focusNext  FocusDelegator
+	focusPrev  FocusDelegator
+}
+
+// function normalizeTitle() reduces a media name to a canonical form used to
+// identify the same media in different libraries, regardless of file name
+// extension, letter case, punctuation, or spacing.
+func normalizeTitle(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// function newCompareView() allocates and initializes the tview.Flex widget
+// containing the two library comparison panes.
+func newCompareView(ui *tview.Application, page string, lib []*Library) *CompareView {
+
+	v := CompareView{
+		Pages:      tview.NewPages(),
+		confirm:    tview.NewModal(),
+		confirming: false,
+		pane:       [cpCOUNT]*Browser{newBrowser(), newBrowser()},
+		lib:        [cpCOUNT]*Library{nil, nil},
+		active:     cpLeft,
+		layout:     nil,
+		focusPage:  page,
+		focusNext:  nil,
+		focusPrev:  nil,
+	}
+
+	body := tview.NewFlex().SetDirection(tview.FlexColumn)
+	for _, p := range v.pane {
+		p.SetBorder(true).
+			SetBorderColor(colorScheme.inactiveText).
+			SetTitleColor(colorScheme.activeMenuText).
+			SetTitleAlign(tview.AlignLeft)
+		p.setSelectedFocusOnly(true)
+		body.AddItem(p, 0, 1, false)
+	}
+
+	v.
+		AddPage("body", body, true, true).
+		AddPage("confirm", v.confirm, false, false)
+
+	return &v
+}
+
+func (v *CompareView) desc() string { return "" }
+func (v *CompareView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *CompareView) page() string         { return v.focusPage }
+func (v *CompareView) next() FocusDelegator { return v.focusNext }
+func (v *CompareView) prev() FocusDelegator { return v.focusPrev }
+func (v *CompareView) focus() {
+	// compare the library currently selected in the library selection view
+	// with the one following it.
+	lib := v.layout.lib
+	first := 0
+	if selected := v.layout.libSelect.selectedLibrary; selected != selectedLibraryAll {
+		first = selected - 1
+	}
+	v.lib[cpLeft] = lib[first]
+	v.lib[cpRight] = lib[(first+1)%len(lib)]
+	v.refresh()
+
+	page := v.page()
+	v.confirming = false
+	v.HidePage("confirm")
+	v.layout.pages.ShowPage(page)
+	v.setActive(cpLeft)
+}
+func (v *CompareView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function setActive() moves input focus to the given pane.
+func (v *CompareView) setActive(pane ComparePane) {
+	v.active = pane
+	for p, b := range v.pane {
+		if ComparePane(p) == pane {
+			b.SetBorderColor(colorScheme.activeBorder)
+		} else {
+			b.SetBorderColor(colorScheme.inactiveText)
+		}
+	}
+	v.layout.ui.SetFocus(v.pane[pane])
+}
+
+// function swapActive() moves input focus to the pane not currently focused.
+func (v *CompareView) swapActive() {
+	v.setActive((v.active + 1) % cpCOUNT)
+}
+
+// function refresh() repopulates both panes with the media discovered in their
+// respective libraries, highlighting the items missing from the other pane.
+func (v *CompareView) refresh() {
+
+	// gather all known media items, including those currently hidden by the
+	// library filter in the primary media browser.
+	browse := v.layout.browseView.Browser
+	all := []*mediaItem{}
+	all = append(all, browse.visibleItem...)
+	all = append(all, browse.hiddenItem...)
+
+	title := [cpCOUNT]map[string]bool{{}, {}}
+	for _, m := range all {
+		for p, lib := range v.lib {
+			if m.SourceLibrary == lib {
+				title[p][normalizeTitle(m.AbsName)] = true
+			}
+		}
+	}
+
+	for p, lib := range v.lib {
+		pane, other := v.pane[p], title[(p+1)%int(cpCOUNT)]
+		pane.clear()
+		pane.setSortOrder(browse.sortOrder, browse.sortField)
+		unique := 0
+		for _, m := range all {
+			if m.SourceLibrary != lib {
+				continue
+			}
+			position, primary, secondary := pane.positionForMediaItem(m.Media)
+			if !other[normalizeTitle(m.AbsName)] {
+				primary = fmt.Sprintf("[#%06x]%s%s", colorScheme.highlightTertiary.Hex(), indicator(ikUnique), primary)
+				unique++
+			}
+			pane.insertMediaItem(lib, m.Media, position, primary, secondary, nil)
+		}
+		pane.SetTitle(fmt.Sprintf(" %s: [#%06x]%d unique ",
+			lib.name, colorScheme.highlightPrimary.Hex(), unique))
+	}
+}
+
+// function transferSelected() copies (or moves) the media item currently
+// selected in the active pane into the library of the opposite pane, keeping
+// its path relative to the library root. a move must be confirmed first. the
+// transfer is performed in the background; the destination library discovers
+// a copy on its next scan.
+func (v *CompareView) transferSelected(move bool) {
+
+	pane := v.pane[v.active]
+	index := pane.getCurrentItem()
+	if !isValidIndex(pane.visibleItem, index) {
+		return
+	}
+	item := pane.visibleItem[index]
+	src, dst := v.lib[v.active], v.lib[(v.active+1)%cpCOUNT]
+
+	// the record of the media is needed to move it, along with its support
+	// files, into the destination library's database.
+	id := -1
+	if record, ok := v.layout.browseView.record[item.Media]; ok {
+		id = record.id
+	}
+
+	if !move {
+		go v.transfer(item, id, src, dst, false)
+		return
+	}
+
+	button := []string{"Move", "Cancel"}
+	v.confirm.
+		ClearButtons().
+		SetText(fmt.Sprintf("Move %q from %q into %q?\n(along with its subtitles, audio tracks and database record)",
+			item.AbsName, src.name, dst.name)).
+		AddButtons(button).
+		SetDoneFunc(
+			func(buttonIndex int, buttonLabel string) {
+				v.confirming = false
+				v.HidePage("confirm")
+				v.setActive(v.active)
+				if button[0] == buttonLabel {
+					go v.transfer(item, id, src, dst, true)
+				}
+			})
+
+	v.confirming = true
+	v.ShowPage("confirm")
+	v.layout.ui.SetFocus(v.confirm)
+}
+
+// function isConfirming() returns true if a move is waiting for confirmation.
+func (v *CompareView) isConfirming() bool {
+	return v.confirming
+}
+
+// function transfer() copies (or moves) the given item's media into the
+// destination library. a media moved is removed from the media browser, and
+// listed again in the destination library once its record was moved. this
+// must not be called from the UI goroutine.
+func (v *CompareView) transfer(item *mediaItem, id int, src, dst *Library, move bool) {
+
+	v.layout.busy.inc()
+	defer v.layout.busy.dec()
+
+	action := "copy"
+	if move {
+		action = "move"
+	}
+	if ret := transferMedia(item.Media, id, src, dst, move); nil != ret {
+		warnLog.log(ret)
+		return
+	}
+	if move {
+		removed := map[string]bool{item.AbsPath: true}
+		v.layout.eventQueue <- func() {
+			for _, m := range v.layout.browseView.removeMedia(src, removed) {
+				v.layout.searchIndex.remove(m)
+			}
+			v.refresh()
+		}
+	}
+	infoLog.logf("%s complete: %q -> %q", action, item.AbsName, dst.name)
+}
+
+// type transferSupport is a support file of a video transferred along with it.
+type transferSupport struct {
+	kind     SupportKind
+	id       int    // hash key ID of its record in the source library (-1 if unknown)
+	absPath  string // path in the source library
+	target   string // path in the destination library (empty if not transferred)
+	shared   bool   // associated with other videos too, so only copied
+	selected bool   // selected for playback with the video
+}
+
+// function transferMedia() copies (or moves) the file of the given media, along
+// with the subtitles and audio tracks of a video, from the source library into
+// the destination library, keeping their paths relative to the library root.
+// existing files are never overwritten. the database record of a media moved
+// (with the given hash key ID) is moved too (see function moveRecord()).
+func transferMedia(media *Media, id int, src, dst *Library, move bool) *ReturnCode {
+
+	target, ret := transferTarget(media.AbsPath, src, dst)
+	if nil != ret {
+		return ret
+	}
+
+	support := []*transferSupport{}
+	if mkVideo == media.Kind && id >= 0 {
+		if support, ret = supportOf(src, id); nil != ret {
+			return ret
+		}
+	}
+
+	if ret := transferFile(media.AbsPath, target, move); nil != ret {
+		return ret
+	}
+	// the media itself was transferred, so a support file which cannot be is
+	// only reported.
+	for _, s := range support {
+		t, ret := transferTarget(s.absPath, src, dst)
+		if nil == ret {
+			ret = transferFile(s.absPath, t, move && !s.shared)
+		}
+		if nil != ret {
+			warnLog.log(ret)
+			continue
+		}
+		s.target = t
+	}
+
+	if move && id >= 0 {
+		return moveRecord(media, id, target, support, src, dst)
+	}
+	return nil
+}
+
+// function transferTarget() returns the path in the destination library of the
+// file at the given path in the source library, creating its directory.
+func transferTarget(absPath string, src, dst *Library) (string, *ReturnCode) {
+
+	rel, err := filepath.Rel(src.absPath, absPath)
+	if nil != err {
+		return "", rcInvalidPath.specf(
+			"transferTarget(%q, %q): filepath.Rel(): %s", absPath, dst.absPath, err)
+	}
+	target := filepath.Join(dst.absPath, rel)
+	if _, err := os.Stat(target); nil == err {
+		return "", rcInvalidPath.specf(
+			"transferTarget(%q, %q): destination already exists: %q", absPath, dst.absPath, target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); nil != err {
+		return "", rcInvalidPath.specf(
+			"transferTarget(%q, %q): os.MkdirAll(): %s", absPath, dst.absPath, err)
+	}
+	return target, nil
+}
+
+// function transferFile() copies (or moves) the file at the given path to the
+// given target path.
+func transferFile(absPath, target string, move bool) *ReturnCode {
+
+	// renaming is only possible within the same file system; otherwise fall
+	// back on copying the file and then removing the original.
+	if move {
+		if err := os.Rename(absPath, target); nil == err {
+			return nil
+		}
+	}
+
+	if ret := copyFile(absPath, target); nil != ret {
+		return ret
+	}
+	if move {
+		if err := os.Remove(absPath); nil != err {
+			return rcInvalidFile.specf(
+				"transferFile(%q, %q): os.Remove(): %s", absPath, target, err)
+		}
+	}
+	return nil
+}
+
+// function supportOf() returns the subtitles and audio tracks associated with
+// the video with the given hash key ID in the given library's database.
+func supportOf(lib *Library, id int) ([]*transferSupport, *ReturnCode) {
+
+	video := &VideoMedia{}
+	if ret := video.fromID(lib.db.col[ecMedia][mkVideo], id); nil != ret {
+		return nil, ret
+	}
+
+	support := []*transferSupport{}
+	add := func(kind SupportKind, absPath string, selected bool) {
+		s := &transferSupport{kind: kind, id: -1, absPath: absPath, selected: selected}
+		result := make(map[int]struct{})
+		if err := evalQuery(map[string]interface{}{
+			"eq": absPath,
+			"in": []interface{}{(*lib.db.index[ecSupport][sxPath])[0]},
+		}, lib.db.col[ecSupport][kind], &result); nil != err {
+			warnLog.trace(rcQueryError.specf("supportOf(%q): %s: %s", absPath, lib.db, err))
+		}
+		for sid := range result {
+			s.id = sid
+			if read, err := lib.db.col[ecSupport][kind].Read(sid); nil == err {
+				known, _ := read["KnownVideoMedia"].([]interface{})
+				s.shared = len(known) > 1
+			}
+		}
+		support = append(support, s)
+	}
+	for _, s := range video.KnownSubtitles {
+		if nil != s.Support {
+			add(skSubtitles, s.AbsPath,
+				nil != video.Subtitles.Support && s.AbsPath == video.Subtitles.AbsPath)
+		}
+	}
+	for _, a := range video.KnownAudioTracks {
+		if nil != a.Support {
+			add(skAudioTrack, a.AbsPath,
+				nil != video.AudioTrack.Support && a.AbsPath == video.AudioTrack.AbsPath)
+		}
+	}
+	return support, nil
+}
+
+// function moveRecord() moves the record of a media moved to the given target
+// path, with the given hash key ID, from the source library's database into the
+// destination library's database. every field of the record is kept but those
+// describing the file itself. the records of its support files moved along
+// with it are moved too, and associated with it again. the source records are
+// removed, leaving tombstones (see tombstone.go), and the media is discovered
+// by the destination library.
+func moveRecord(media *Media, id int, target string, support []*transferSupport, src, dst *Library) *ReturnCode {
+
+	read, err := src.db.col[ecMedia][media.Kind].Read(id)
+	if nil != err {
+		return rcDatabaseError.specf(
+			"moveRecord(%q): %s: Read(%d): %s", media.AbsPath, src.db, id, err)
+	}
+	info, err := os.Stat(target)
+	if nil != err {
+		return rcInvalidStat.specf("moveRecord(%q): os.Stat(): %s", target, err)
+	}
+	rel, _ := filepath.Rel(dst.absPath, target)
+	ext := filepath.Ext(target)
+	_, extName := mediaKindOfFile(target, ext)
+
+	// the fields describing the file are those of a new record at its target.
+	var file *EntityRecord
+	var ret *ReturnCode
+	var entity RecordEntity
+	switch media.Kind {
+	case mkAudio:
+		audio := newAudioMedia(dst, target, rel, ext, extName, info)
+		file, ret = audio.toRecord()
+		entity = audio
+	case mkVideo:
+		video := newVideoMedia(dst, target, rel, ext, extName, info)
+		file, ret = video.toRecord()
+		entity = video
+	}
+	if nil != ret {
+		return ret
+	}
+	rec := EntityRecord{}
+	for k, v := range read {
+		rec[k] = v
+	}
+	for _, k := range entityFieldName {
+		if v, ok := (*file)[k]; ok {
+			rec[k] = v
+		}
+	}
+	// the support files are associated again below, by their new paths.
+	for _, k := range []string{"KnownSubtitles", "Subtitles", "KnownAudioTracks", "AudioTrack"} {
+		delete(rec, k)
+	}
+	newID, err := dst.db.col[ecMedia][media.Kind].Insert(rec)
+	if nil != err {
+		return rcDatabaseError.specf(
+			"moveRecord(%q): %s: Insert(): %s", target, dst.db, err)
+	}
+	data, err := json.Marshal(rec)
+	if nil != err {
+		return rcInvalidJSONData.specf("moveRecord(%q): json.Marshal(): %s", target, err)
+	}
+	if ret := entity.fromRecord(data); nil != ret {
+		return ret
+	}
+	dst.addIndexedSize(info.Size())
+
+	if video, ok := entity.(*VideoMedia); ok {
+		for _, s := range support {
+			if "" != s.target {
+				if ret := moveSupportRecord(dst, video, newID, s); nil != ret {
+					warnLog.log(ret)
+				}
+			}
+		}
+	}
+
+	if _, ret := src.buryRecord(ecMedia, int(media.Kind), id, tombstoneMoved); nil != ret {
+		warnLog.log(ret)
+	}
+	for _, s := range support {
+		if "" != s.target && !s.shared && s.id >= 0 {
+			if _, ret := src.buryRecord(ecSupport, int(s.kind), s.id, tombstoneMoved); nil != ret {
+				warnLog.log(ret)
+			}
+		}
+	}
+
+	dst.discover(newDiscovery(entity, newID))
+	return nil
+}
+
+// function moveSupportRecord() inserts a record of the given support file, at
+// its target path, into the given library's database, associating it with the
+// given video (with the given hash key ID) moved along with it.
+func moveSupportRecord(lib *Library, video *VideoMedia, vidID int, s *transferSupport) *ReturnCode {
+
+	info, err := os.Stat(s.target)
+	if nil != err {
+		return rcInvalidStat.specf("moveSupportRecord(%q): os.Stat(): %s", s.target, err)
+	}
+	rel, _ := filepath.Rel(lib.absPath, s.target)
+	ext := filepath.Ext(s.target)
+	_, extName := supportKindOfFile(s.target, ext)
+	vidCol, supCol := lib.db.col[ecMedia][mkVideo], lib.db.col[ecSupport][s.kind]
+
+	switch s.kind {
+	case skSubtitles:
+		subs := newSubtitles(lib, s.target, rel, ext, extName, info)
+		rec, ret := subs.toRecord()
+		if nil != ret {
+			return ret
+		}
+		subID, err := supCol.Insert(*rec)
+		if nil != err {
+			return rcDatabaseError.specf(
+				"moveSupportRecord(%q): %s: Insert(): %s", s.target, lib.db, err)
+		}
+		_, ret = video.addSubtitles(vidCol, supCol, vidID, subID, true, s.selected, subs)
+		return ret
+
+	case skAudioTrack:
+		track := newAudioTrack(lib, s.target, rel, ext, extName, info)
+		rec, ret := track.toRecord()
+		if nil != ret {
+			return ret
+		}
+		audID, err := supCol.Insert(*rec)
+		if nil != err {
+			return rcDatabaseError.specf(
+				"moveSupportRecord(%q): %s: Insert(): %s", s.target, lib.db, err)
+		}
+		_, ret = video.addAudioTrack(vidCol, supCol, vidID, audID, true, s.selected, track)
+		return ret
+	}
+	return nil
+}
+
+// function copyFile() copies the content and permissions of a regular file to
+// a new file at the given path. a partially-written file is removed on error.
+func copyFile(srcPath, dstPath string) *ReturnCode {
+
+	in, err := os.Open(srcPath)
+	if nil != err {
+		return rcInvalidFile.specf("copyFile(%q, %q): os.Open(): %s", srcPath, dstPath, err)
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if nil != err {
+		return rcInvalidStat.specf("copyFile(%q, %q): Stat(): %s", srcPath, dstPath, err)
+	}
+
+	out, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if nil != err {
+		return rcInvalidFile.specf("copyFile(%q, %q): os.OpenFile(): %s", srcPath, dstPath, err)
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); nil == err {
+		err = closeErr
+	}
+	if nil != err {
+		os.Remove(dstPath)
+		return rcInvalidFile.specf("copyFile(%q, %q): io.Copy(): %s", srcPath, dstPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,468 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: config.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the config file, which persists the library paths scanned when
+//    none are given on the command line, along with the default value of any
+//    option (general options, UI preferences, player commands, etc.). the
+//    file is written in (a subset of) TOML: tables, comments, and keys with
+//    string, integer, float, boolean, or array values. options given on the
+//    command line or in the environment always take precedence over those
+//    in the config file. the file is created, with every option commented
+//    out at its default value, the first time libraries are opened.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for the config file.
+const (
+	configLibraries = "libraries" // key of the library paths in the root table
+	configFilePerms = 0600        // the file may contain API keys
+)
+
+// type ConfigTable is a table of the config file grouping related options.
+type ConfigTable struct {
+	name   string
+	desc   string
+	option []string // names of the options written in this table (nil = all others)
+}
+
+var (
+	// variable configTable contains the tables of the config file, in the
+	// order they are written. an option may be given in any table, they only
+	// serve to organize the file.
+	configTable = []ConfigTable{
+		{"options", "general options", nil},
+		{"ui", "user interface preferences", []string{
+			"accessible", "cli", "quiet", "exitmsg", "preset", "presetdef",
+			"macro", "title", "tmux", "glyphs", "glyph", "timefmt", "tz",
+			"spectrum",
+		}},
+		{"player", "playback", []string{"player", "hostargs", "target"}},
+	}
+
+	// variable configExcluded contains the options which may not be given in
+	// the config file.
+	configExcluded = map[string]bool{"help": true, "config": true}
+)
+
+// type ConfigEntry is a key and its value read from the config file.
+type ConfigEntry struct {
+	table string   // name of the enclosing table (empty for the root table)
+	key   string   // name of an option, or configLibraries
+	value []string // the value, or each element of an array value, as text
+	array bool     // whether or not the value is an array
+	line  int      // line number of the key in the file
+}
+
+// function loadConfig() reads the config file (if it exists) and sets each
+// option it gives which was not provided on the command line or in the
+// environment. its library paths are used if none are given on the command
+// line. returns every problem found in the file.
+func loadConfig(options *Options) []error {
+
+	path := options.Config.string
+	if exists, _ := goutil.PathExists(path); !exists {
+		return nil
+	}
+	f, err := os.Open(path)
+	if nil != err {
+		return []error{fmt.Errorf("config file %q: %s", path, err)}
+	}
+	defer f.Close()
+
+	entry, failed := parseConfig(f, path)
+	valid, library, invalid := configOptions(options, entry, path)
+	failed = append(failed, invalid...)
+	for _, e := range valid {
+		o := options.Known[e.key]
+		if _, ok := options.Provided[e.key]; ok {
+			infoLog.tracef("config option %q overridden by command line or environment", e.key)
+			continue
+		}
+		for _, v := range e.value {
+			if err := options.Set(e.key, v); nil != err {
+				failed = append(failed, fmt.Errorf("%s:%d: option %q: %s", path, e.line, e.key, err))
+			}
+		}
+		options.Provided[e.key] = o
+		options.Configured[e.key] = o
+	}
+	options.ConfigLibraries = library
+
+	// the library paths become the command line arguments, so that they are
+	// handled exactly as if they were given there.
+	if 0 == len(options.Args()) && len(library) > 0 {
+		infoLog.tracef("using %d library path(s) from config file: %q", len(library), path)
+		if err := options.Parse(append([]string{"--"}, library...)); nil != err {
+			failed = append(failed, fmt.Errorf("config file %q: %s", path, err))
+		}
+	}
+	infoLog.tracef("loaded configuration: %q", path)
+	return failed
+}
+
+// function configOptions() verifies the entries read from the config file
+// with the given name. returns the entries giving known options (in the order
+// read), the library paths given, and every problem found.
+func configOptions(options *Options, entry []*ConfigEntry, name string) ([]*ConfigEntry, []string, []error) {
+
+	valid := []*ConfigEntry{}
+	library := []string{}
+	failed := []error{}
+	given := map[string]int{}
+	for _, e := range entry {
+		where := fmt.Sprintf("%s:%d", name, e.line)
+		if "" == e.table && configLibraries == e.key {
+			library = append(library, e.value...)
+			continue
+		}
+		o, ok := options.Known[e.key]
+		if !ok || configExcluded[e.key] {
+			failed = append(failed, fmt.Errorf("%s: unknown option %q", where, e.key))
+			continue
+		}
+		if line, ok := given[e.key]; ok {
+			failed = append(failed, fmt.Errorf("%s: option %q already given on line %d", where, e.key, line))
+			continue
+		}
+		given[e.key] = e.line
+		if e.array && okStringList != o.kind {
+			failed = append(failed, fmt.Errorf("%s: option %q takes a single value, not an array", where, e.key))
+			continue
+		}
+		valid = append(valid, e)
+	}
+	return valid, library, failed
+}
+
+// function parseConfig() parses the config file read from the given reader,
+// using the given name in error messages. returns every key read and every
+// syntax error found.
+func parseConfig(r io.Reader, name string) ([]*ConfigEntry, []error) {
+
+	entry := []*ConfigEntry{}
+	failed := []error{}
+	fail := func(line int, format string, v ...interface{}) {
+		failed = append(failed, fmt.Errorf("%s:%d: %s", name, line, fmt.Sprintf(format, v...)))
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, []error{fmt.Errorf("%s: %s", name, err)}
+	}
+
+	table := ""
+	seen := map[string]bool{}
+	for i := 0; i < len(lines); i++ {
+		num := i + 1
+		text := strings.TrimSpace(stripConfigComment(lines[i]))
+		if "" == text {
+			continue
+		}
+
+		// table header
+		if strings.HasPrefix(text, "[") {
+			if strings.HasPrefix(text, "[[") || !strings.HasSuffix(text, "]") {
+				fail(num, "invalid table header: %s", text)
+				continue
+			}
+			table = strings.TrimSpace(text[1 : len(text)-1])
+			if nil == findConfigTable(table) {
+				fail(num, "unknown table %q (expected one of: %s)", table, strings.Join(configTableNames(), ", "))
+			}
+			continue
+		}
+
+		// key = value
+		eq := strings.Index(text, "=")
+		if eq < 0 {
+			fail(num, "expected KEY = VALUE: %s", text)
+			continue
+		}
+		key, value := strings.TrimSpace(text[:eq]), strings.TrimSpace(text[eq+1:])
+		if !isConfigKey(key) {
+			fail(num, "invalid key %q", key)
+			continue
+		}
+		if seen[table+"."+key] {
+			fail(num, "key %q already defined", key)
+			continue
+		}
+		seen[table+"."+key] = true
+
+		e := &ConfigEntry{table: table, key: key, value: nil, array: false, line: num}
+		if strings.HasPrefix(value, "[") {
+			// an array may continue on the following lines.
+			for configBracketDepth(value) > 0 && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(stripConfigComment(lines[i]))
+			}
+			elem, err := parseConfigArray(value)
+			if nil != err {
+				fail(num, "key %q: %s", key, err)
+				continue
+			}
+			e.value, e.array = elem, true
+		} else {
+			v, err := parseConfigScalar(value)
+			if nil != err {
+				fail(num, "key %q: %s", key, err)
+				continue
+			}
+			e.value = []string{v}
+		}
+		entry = append(entry, e)
+	}
+	return entry, failed
+}
+
+// function stripConfigComment() removes the comment (if any) from the given
+// line, ignoring '#' within strings.
+func stripConfigComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case 0 != quote && '\\' == c && '"' == quote:
+			i++ // skip the escaped character
+		case 0 != quote && c == quote:
+			quote = 0
+		case 0 == quote && ('"' == c || '\'' == c):
+			quote = c
+		case 0 == quote && '#' == c:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// function configBracketDepth() returns the number of brackets left open in
+// the given text, ignoring brackets within strings.
+func configBracketDepth(text string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case 0 != quote && '\\' == c && '"' == quote:
+			i++
+		case 0 != quote && c == quote:
+			quote = 0
+		case 0 == quote && ('"' == c || '\'' == c):
+			quote = c
+		case 0 == quote && '[' == c:
+			depth++
+		case 0 == quote && ']' == c:
+			depth--
+		}
+	}
+	return depth
+}
+
+// function parseConfigArray() parses an array of scalar values, e.g. ["a", 'b',]
+// returning each element as text.
+func parseConfigArray(text string) ([]string, error) {
+
+	if !strings.HasSuffix(text, "]") || 0 != configBracketDepth(text) {
+		return nil, fmt.Errorf("unterminated array: %s", text)
+	}
+	inner := text[1 : len(text)-1]
+	elem := []string{}
+	var quote byte
+	start := 0
+	for i := 0; i <= len(inner); i++ {
+		if i < len(inner) {
+			switch c := inner[i]; {
+			case 0 != quote && '\\' == c && '"' == quote:
+				i++
+				continue
+			case 0 != quote && c == quote:
+				quote = 0
+				continue
+			case 0 == quote && ('"' == c || '\'' == c):
+				quote = c
+				continue
+			case 0 == quote && '[' == c:
+				return nil, fmt.Errorf("nested arrays are not supported")
+			case 0 != quote || ',' != c:
+				continue
+			}
+		}
+		// end of an element (a trailing comma leaves an empty one).
+		if s := strings.TrimSpace(inner[start:i]); "" != s {
+			v, err := parseConfigScalar(s)
+			if nil != err {
+				return nil, err
+			}
+			elem = append(elem, v)
+		} else if i < len(inner) {
+			return nil, fmt.Errorf("empty array element: %s", text)
+		}
+		start = i + 1
+	}
+	return elem, nil
+}
+
+// function parseConfigScalar() parses a string, integer, float, or boolean
+// value, returning it as text.
+func parseConfigScalar(text string) (string, error) {
+
+	switch {
+	case strings.HasPrefix(text, `"`):
+		s, err := strconv.Unquote(text)
+		if nil != err {
+			return "", fmt.Errorf("invalid string: %s", text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, `'`):
+		// literal strings have no escape sequences.
+		if len(text) < 2 || !strings.HasSuffix(text, `'`) || strings.Contains(text[1:len(text)-1], `'`) {
+			return "", fmt.Errorf("invalid string: %s", text)
+		}
+		return text[1 : len(text)-1], nil
+	case "true" == text || "false" == text:
+		return text, nil
+	}
+	num := strings.Replace(text, "_", "", -1)
+	if _, err := strconv.ParseFloat(num, 64); nil != err {
+		return "", fmt.Errorf("invalid value (strings must be quoted): %s", text)
+	}
+	return num, nil
+}
+
+// function isConfigKey() returns true if the given key is a bare TOML key.
+func isConfigKey(key string) bool {
+	if "" == key {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || '_' == r || '-' == r) {
+			return false
+		}
+	}
+	return true
+}
+
+// function findConfigTable() returns the config table with the given name, or
+// nil if there is no such table.
+func findConfigTable(name string) *ConfigTable {
+	for i := range configTable {
+		if configTable[i].name == name {
+			return &configTable[i]
+		}
+	}
+	return nil
+}
+
+// function configTableNames() returns the names of the config tables, in order.
+func configTableNames() []string {
+	name := make([]string, len(configTable))
+	for i, t := range configTable {
+		name[i] = t.name
+	}
+	return name
+}
+
+// function configDefault() formats the default value of the given option (i.e.
+// before any was provided) as a TOML value.
+func configDefault(options *Options, o *Option) string {
+
+	def := ""
+	if f := options.Lookup(o.name); nil != f {
+		def = f.DefValue
+	}
+	switch o.kind {
+	case okBool, okInt, okUint, okUint64, okFloat64:
+		return def
+	case okSize:
+		if "" == def {
+			def = "0"
+		}
+	case okStringList:
+		if "" == def {
+			return "[]"
+		}
+		elem := strings.Split(def, ", ")
+		for i, s := range elem {
+			elem[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(elem, ", ") + "]"
+	}
+	return strconv.Quote(def)
+}
+
+// function createConfig() writes a new config file listing the library paths
+// given on the command line, and every option commented out at its default
+// value, grouped into the config tables.
+func createConfig(options *Options) *ReturnCode {
+
+	var b strings.Builder
+	b.WriteString("# pimm configuration file (TOML)\n")
+	b.WriteString("#\n")
+	b.WriteString("# options given on the command line or in the environment always take\n")
+	b.WriteString("# precedence over this file. uncomment an option to change its default\n")
+	b.WriteString("# (see -help for the description of each option).\n\n")
+
+	b.WriteString("# library paths scanned when none are given on the command line.\n")
+	fmt.Fprintf(&b, "%s = [\n", configLibraries)
+	for _, arg := range options.Args() {
+		if abs, err := libraryPath(arg); nil == err {
+			fmt.Fprintf(&b, "  %s,\n", strconv.Quote(abs))
+		}
+	}
+	b.WriteString("]\n")
+
+	// each option is written in the first table listing it, or else in the
+	// table listing no options.
+	placed := map[string]bool{}
+	for _, t := range configTable {
+		for _, n := range t.option {
+			placed[n] = true
+		}
+	}
+	for _, t := range configTable {
+		name := t.option
+		if nil == name {
+			name = []string{}
+			for n := range options.Known {
+				if !placed[n] {
+					name = append(name, n)
+				}
+			}
+			sort.Strings(name)
+		}
+		fmt.Fprintf(&b, "\n# %s\n[%s]\n", t.desc, t.name)
+		for _, n := range name {
+			if o, ok := options.Known[n]; ok && !configExcluded[n] {
+				fmt.Fprintf(&b, "# %s = %s\n", n, configDefault(options, o))
+			}
+		}
+	}
+
+	path := options.Config.string
+	if err := ioutil.WriteFile(path, []byte(b.String()), configFilePerms); nil != err {
+		return rcInvalidConfig.wrap(err, "cannot create configuration: %q", path)
+	}
+	return nil
+}
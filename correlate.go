@@ -0,0 +1,87 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: correlate.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the correlation IDs of loads, scans and other library operations.
+//    each operation is given a short, unique ID when it begins (e.g. "scan-
+//    3fa9c1"), carried by the context.Context of the operation, and every line
+//    it logs through a logger tagged with that context (see function in()) is
+//    tagged with that ID. the lines of operations running concurrently (e.g.
+//    scans of several libraries) may then be told apart, and those of a single
+//    operation filtered out of the log.
+//
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+)
+
+// local unexported constants for correlation IDs.
+const (
+	correlationIDSize = 3 // random bytes of each ID (shown as hex)
+)
+
+// type correlationKey is the key of the correlation ID in the context of an
+// operation.
+type correlationKey struct{}
+
+var (
+	// variable correlationSeq distinguishes the IDs of operations begun if
+	// random bytes cannot be read.
+	correlationSeq uint64
+)
+
+// function newCorrelationID() returns a new correlation ID of an operation of
+// the given kind (e.g. "scan", "load").
+func newCorrelationID(op string) string {
+	b := make([]byte, correlationIDSize)
+	if _, err := rand.Read(b); nil != err {
+		return fmt.Sprintf("%s-%d", op, atomic.AddUint64(&correlationSeq, 1))
+	}
+	return fmt.Sprintf("%s-%s", op, hex.EncodeToString(b))
+}
+
+// function correlationID() returns the correlation ID of the operation whose
+// context is given, or an empty string if none.
+func correlationID(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// function in() returns a logger tagging every line logged with the
+// correlation ID of the operation whose context is given, or the receiver
+// itself if none. the ID is enclosed in angle brackets, since square brackets
+// would be taken for a color tag by the log view.
+func (l *ConsoleLog) in(ctx context.Context) *ConsoleLog {
+	id := correlationID(ctx)
+	if "" == id {
+		return l
+	}
+	t := *l
+	t.tag = fmt.Sprintf("<%s> ", id)
+	t.base = l
+	return &t
+}
+
+// function beginOperation() begins an operation of the given kind on this
+// library, returning a context derived from the given one carrying a new
+// correlation ID, and the function ending the operation.
+func (l *Library) beginOperation(ctx context.Context, op string) (context.Context, func()) {
+	ctx = context.WithValue(ctx, correlationKey{}, newCorrelationID(op))
+	infoLog.in(ctx).tracef("begin %s: %q", op, l.name)
+	return ctx, func() {
+		infoLog.in(ctx).tracef("end %s: %q", op, l.name)
+	}
+}
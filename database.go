@@ -21,12 +21,12 @@ import (
 	"math"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"ardnew.com/goutil"
-	"github.com/HouzuoGuo/tiedot/db"
+	"ardnew.com/pimmp/internal/db"
 	//"github.com/davecgh/go-spew/spew"
 )
 
@@ -35,16 +35,41 @@ const (
 	dataConfigFileName  = "data-config.json"
 	dataConfigFilePerms = 0644
 
+	// portable libraries keep their database in this directory at the library
+	// root, so that it travels with the library (see command line option
+	// "-portable").
+	portableDataDirName  = ".pimm"
+	portableDatabaseName = "library"
+	anchorFileName       = "anchor.json"
+
 	kibiBytes = 1024
 	mebiBytes = 1048576
+
+	// smallest size accepted of the database buffers (see command line options
+	// "-diskbuffersize" and "-hashbuffersize"), one page of memory. the number
+	// of hash table buckets is derived from the hash buffer size in units of
+	// 512 bytes, so it must be at least that.
+	minBufferSize = 4 * kibiBytes
+
+	// storage engines of the library databases (see command line option
+	// "-engine"). each database keeps the engine it was created with until it
+	// is migrated.
+	dbEngineTiedot = db.EngineTiedot // one directory per collection, configured by data-config.json
+	dbEngineBolt   = db.EngineBolt   // a single file
+
+	// suffix of the temporary copy of a database written while migrating it.
+	migrateSuffix = ".migrate"
 )
 
 var (
+	// variable dbEngineName lists the choices of the -engine option.
+	dbEngineName = []string{dbEngineTiedot, dbEngineBolt}
+
 	// see type JSONDataConfig for a description of these items
 	defaultMaxRecordSize  = 64 * kibiBytes
-	defaultDiskBufferSize = 4 * defaultMaxRecordSize / runtime.NumCPU()
+	defaultDiskBufferSize = atLeastBufferSize(4 * defaultMaxRecordSize / defaultNumCPU)
 	defaultHashBucketSize = 16
-	defaultHashBufferSize = defaultDiskBufferSize / 4
+	defaultHashBufferSize = atLeastBufferSize(defaultDiskBufferSize / 4)
 	defaultHashedBitsSize = 13
 	defaultNumHashBuckets = 8192
 )
@@ -68,6 +93,16 @@ func (c *JSONDataConfig) String() string {
 	return r.Replace(fmt.Sprintf("%#v", c))
 }
 
+// function atLeastBufferSize() returns the given size of a database buffer, or
+// the smallest size accepted if it is less, since the default sizes shrink with
+// the number of CPU cores.
+func atLeastBufferSize(size int) int {
+	if size < minBufferSize {
+		return minBufferSize
+	}
+	return size
+}
+
 // function newJSONDataConfig() creates the struct that configures tiedot's
 // index/cache sizing options. this struct is intended to be marshalled into
 // a json string and stored in a file read by the tiedot runtime.
@@ -78,7 +113,15 @@ func newJSONDataConfig(opt *Options) (*JSONDataConfig, *ReturnCode) {
 			"newJSONDataConfig(): cannot encode JSON object: &Options{} is nil")
 	}
 
-	bits := uint(math.Log2(float64(opt.HashBufferSize.int) / 512.0))
+	for _, o := range []*Option{opt.DiskBufferSize, opt.HashBufferSize} {
+		if o.uint64 < minBufferSize {
+			return nil, rcInvalidJSONData.specf(
+				"newJSONDataConfig(): -%s must be at least %s: %s",
+				o.name, formatSize(minBufferSize), formatSize(o.uint64))
+		}
+	}
+
+	bits := uint(math.Log2(float64(opt.HashBufferSize.uint64) / 512.0))
 	buckets := 1 << bits
 	recordSizeMax := defaultMaxRecordSize
 	bucketSize := defaultHashBucketSize
@@ -86,9 +129,9 @@ func newJSONDataConfig(opt *Options) (*JSONDataConfig, *ReturnCode) {
 	return &JSONDataConfig{
 		options:        opt,
 		MaxRecordSize:  int(recordSizeMax),
-		DiskBufferSize: opt.DiskBufferSize.int,
+		DiskBufferSize: int(opt.DiskBufferSize.uint64),
 		HashBucketSize: int(bucketSize),
-		HashBufferSize: opt.HashBufferSize.int,
+		HashBufferSize: int(opt.HashBufferSize.uint64),
 		HashedBitsSize: uint(bits),
 		NumHashBuckets: int(buckets),
 	}, nil
@@ -127,9 +170,11 @@ func (c *JSONDataConfig) unmarshal(data []byte) *ReturnCode {
 
 // function equals() performs a field-by-field logical comparison of two
 // JSONDataConfig{} structs returning true if and only if the fields are equal.
-// a slice of strings containing the corresponding command-line option names of
-// all unequal fields is returned. an empty slice is returned if all fields are
-// equal or the argument references point to the same object.
+// a slice of strings describing the corresponding command-line option of each
+// unequal field, along with both of its values, is returned. an empty slice is
+// returned if all fields are equal or the argument references point to the same
+// object. the receiver is the requested configuration, and the argument is the
+// configuration of the existing database.
 func (c *JSONDataConfig) equals(jdc *JSONDataConfig) (bool, []string) {
 
 	uneq := []string{}
@@ -137,11 +182,17 @@ func (c *JSONDataConfig) equals(jdc *JSONDataConfig) (bool, []string) {
 	if c != jdc {
 		// these fields are the only options the user can specify on the command
 		// line. all other fields are calculated based on these.
+		differ := func(name string, req, cur int) string {
+			return fmt.Sprintf("-%s (database: %s, requested: %s)",
+				name, formatSize(uint64(cur)), formatSize(uint64(req)))
+		}
 		if c.DiskBufferSize != jdc.DiskBufferSize {
-			uneq = append(uneq, c.options.DiskBufferSize.name)
+			uneq = append(uneq, differ(c.options.DiskBufferSize.name,
+				c.DiskBufferSize, jdc.DiskBufferSize))
 		}
 		if c.HashBufferSize != jdc.HashBufferSize {
-			uneq = append(uneq, c.options.HashBufferSize.name)
+			uneq = append(uneq, differ(c.options.HashBufferSize.name,
+				c.HashBufferSize, jdc.HashBufferSize))
 		}
 	}
 	return 0 == len(uneq), uneq
@@ -153,15 +204,21 @@ func (c *JSONDataConfig) equals(jdc *JSONDataConfig) (bool, []string) {
 type Database struct {
 	absPath string // absolute path to database directory
 	libPath string // absolute path to library
-	name    string // libPath checksum (name of database directory)
+	name    string // library UUID (name of database directory)
 	dataDir string // directory containing all known library databases
+	engine  string // storage engine of the database (see dbEngineName)
 
-	store          *db.DB                  // interactive database object
-	col            [ecCOUNT][]*db.Col      // db collections referenced by MediaKind
+	store          Store                   // interactive database object
+	col            [ecCOUNT][]Collection   // db collections referenced by MediaKind
 	colName        [ecCOUNT][]string       // name of each collection
 	index          [ecCOUNT][]*EntityIndex // indices on each collection
 	numRecordsLoad [ecCOUNT][]uint         // number of records in each media collection discovered by load()
 	numRecordsScan [ecCOUNT][]uint         // number of records in each media collection discovered by scan()
+	countMutex     sync.Mutex              // guards numRecordsScan against the workers of a scan
+	series         Collection              // skip markers shared by all episodes of a series (not entities)
+	relations      Collection              // relationships between media records (not entities)
+	playlists      Collection              // ordered lists of media records (not entities)
+	tombstones     Collection              // records removed, kept for a while (not entities)
 	timeCreated    time.Time               // only set if the db was newly created, else IsZero() will return true
 }
 
@@ -173,19 +230,75 @@ type RecordID struct {
 	rec interface{}
 }
 
+// type Store is the storage engine of a library database (see package
+// internal/db, which defines the engines).
+type Store = db.Store
+
+// type Collection is a collection of JSON documents in a Store.
+type Collection = db.Collection
+
+// function evalQuery() evaluates the given query on the given collection (see
+// method Query() of type Collection).
+func evalQuery(q interface{}, col Collection, result *map[int]struct{}) error {
+	return col.Query(q, result)
+}
+
+// function storeEngine() returns the storage engine of the existing database at
+// the given path.
+func storeEngine(path string) string {
+	return db.Engine(path)
+}
+
+// function openStore() opens the database at the given path with the given
+// storage engine, creating it if it doesn't exist.
+func openStore(path, engine string) (Store, error) {
+	return db.Open(path, engine)
+}
+
+// function libraryDatabasePath() returns the data directory, identifying name,
+// and database directory of the library with the given absolute path. portable
+// libraries keep their database at the library root under a fixed name, since
+// their absolute path may differ each time they are opened. with the memory
+// backend, the data directory is the ephemeral one.
+func libraryDatabasePath(opt *Options, abs string) (string, string, string) {
+	if opt.isMemoryBackend() {
+		// see function memoryDataDir(), this is only empty if it failed.
+		sum, path := databasePath(abs, memoryData.path)
+		return memoryData.path, sum, path
+	}
+	if opt.Portable.bool {
+		dat := filepath.Join(abs, portableDataDirName)
+		return dat, portableDatabaseName, filepath.Join(dat, portableDatabaseName)
+	}
+	sum, path := databasePath(abs, opt.LibData.string)
+	return opt.LibData.string, sum, path
+}
+
 // function newDatabase() creates a new high-level database object through
 // which all of the persistent storage operations should be performed.
-func newDatabase(opt *Options, abs string, dat string) (*Database, *ReturnCode) {
+func newDatabase(opt *Options, abs string) (*Database, *ReturnCode) {
 
 	// zeroized Time object is January 1, year 1, 00:00:00.000000000 UTC
 	// calling time.IsZero() with this value will return true, alternatively,
 	// calling our (*Database).isFirstAppearance() will also return true.
 	timeCreated := time.Time{}
 
-	// compute an identifying checksum from the absolute path to the library,
-	// and use that to build a path to the database directory.
-	sum := strings.ToLower(goutil.MD5(abs))
-	path := filepath.Join(dat, sum)
+	// the ephemeral data directory is created on first use.
+	if opt.isMemoryBackend() {
+		if _, ret := memoryDataDir(); nil != ret {
+			return nil, ret
+		}
+	}
+
+	// look up the UUID naming the library's database directory in the data
+	// directory's registry, registering the library if it is new.
+	dat, sum, path := libraryDatabasePath(opt, abs)
+	if !opt.Portable.bool {
+		var ret *ReturnCode
+		if sum, path, ret = registerDatabase(abs, dat); nil != ret {
+			return nil, ret
+		}
+	}
 
 	// verify or create the database directory if it doesn't exist.
 	if exists, _ := goutil.PathExists(path); !exists {
@@ -207,17 +320,24 @@ func newDatabase(opt *Options, abs string, dat string) (*Database, *ReturnCode)
 
 	userDefinedConfig, userOptions := opt.providedDBConfig()
 
+	// new databases are created with the storage engine selected by the user,
+	// while existing ones keep theirs until migrated.
+	engine := opt.Engine.string
+
 	// check if a config file already exists; i.e. if a config file already
 	// exists, then we assume this library's database has already been
 	// configured (and the library possibly even scanned) sometime in the past.
 	configPath := filepath.Join(path, dataConfigFileName)
 	if exists, _ := goutil.PathExists(configPath); exists {
 
+		engine = storeEngine(path)
+
 		// this is a known library, so its database configuration has already
 		// been defined. verify the user isn't trying to change the database
-		// configuration, because our db driver doesn't support reconfiguration
-		// of a populated database.
-		if userDefinedConfig {
+		// configuration, because tiedot doesn't support reconfiguration of a
+		// populated database (the configuration has no effect on other
+		// engines).
+		if userDefinedConfig && dbEngineTiedot == engine {
 
 			// the user has provided database configuration parameters via
 			// command-line, so we need to compare them against the
@@ -248,12 +368,14 @@ func newDatabase(opt *Options, abs string, dat string) (*Database, *ReturnCode)
 			// verbose reason and instructions to remedy the situation.
 			// note that this is a limitation of the current database driver
 			// "tiedot". if another database is used, be sure to revisit this.
-			if equals, _ := jdc.equals(jdcPrev); !equals {
+			if equals, uneq := jdc.equals(jdcPrev); !equals {
 				errLog.logf(
 					"you must delete the current database (%q) and rescan the "+
 						"library to use a different database configuration. "+
 						"otherwise, please remove one or more of the "+
 						"following command-line options: %s", path, csv)
+				errLog.logf("conflicting database configuration: %s",
+					strings.Join(uneq, ", "))
 				return nil, rcDatabaseError.specf(
 					"cannot reconfigure the storage/performance parameters " +
 						"of an existing library database. one or more " +
@@ -304,11 +426,26 @@ func newDatabase(opt *Options, abs string, dat string) (*Database, *ReturnCode)
 		}
 	}
 
+	// make sure no other process has the database open, since concurrent
+	// writers would corrupt it.
+	if ret := lockDatabase(path); nil != ret {
+		return nil, ret
+	}
+
 	// open the actual persistent data store if it exists; otherwise, create it.
-	store, err := db.OpenDB(path)
+	// with the memory backend, nothing persists, so it is always created.
+	var store Store
+	var err error
+	if opt.isMemoryBackend() {
+		engine = dbEngineMemory
+		store = db.NewMemoryStore()
+	} else {
+		store, err = openStore(path, engine)
+	}
 	if nil != err {
+		unlockDatabase(path)
 		return nil, rcDatabaseError.specf(
-			"newDatabase(%q, %q): db.OpenDB(%q): %s", abs, dat, path, err)
+			"newDatabase(%q, %q): openStore(%q, %q): %s", abs, dat, path, engine, err)
 	}
 
 	// initialize the new struct object.
@@ -317,12 +454,17 @@ func newDatabase(opt *Options, abs string, dat string) (*Database, *ReturnCode)
 		libPath:        abs,
 		name:           sum,
 		dataDir:        dat,
+		engine:         engine,
 		store:          store,
-		col:            [ecCOUNT][]*db.Col{},
+		col:            [ecCOUNT][]Collection{},
 		colName:        [ecCOUNT][]string{},
 		index:          [ecCOUNT][]*EntityIndex{},
 		numRecordsLoad: [ecCOUNT][]uint{},
 		numRecordsScan: [ecCOUNT][]uint{},
+		series:         nil,
+		relations:      nil,
+		playlists:      nil,
+		tombstones:     nil,
 		timeCreated:    timeCreated,
 	}
 
@@ -343,6 +485,14 @@ func (d *Database) String() string {
 	return fmt.Sprintf("{%q,%s}", d.dataDir, d.name)
 }
 
+// function countScanned() counts a record of the given class and kind
+// discovered by scan().
+func (d *Database) countScanned(class EntityClass, kind int) {
+	d.countMutex.Lock()
+	defer d.countMutex.Unlock()
+	d.numRecordsScan[class][kind]++
+}
+
 // function totalRecordsString() constructs a human-readable string describing
 // the total number of entity records (as indicated by the Database object's
 // counter fields) of a given class c and kind k. if class and/or kind is a
@@ -359,6 +509,8 @@ func (d *Database) totalRecordsString(m DiscoveryMethod, c int, k int) (uint, st
 	default:
 		return 0, ""
 	}
+	d.countMutex.Lock()
+	defer d.countMutex.Unlock()
 
 	total := uint(0)
 	desc := ""
@@ -387,6 +539,7 @@ func (d *Database) totalRecordsString(m DiscoveryMethod, c int, k int) (uint, st
 func (d *Database) close() (bool, *ReturnCode) {
 
 	err := d.store.Close()
+	unlockDatabase(d.absPath)
 	if nil != err {
 		return false, rcDatabaseError.specf("close(%s): %s", d, err)
 	}
@@ -411,7 +564,7 @@ func (d *Database) initialize() (bool, *ReturnCode) {
 
 		// create each of the collection slices, copying items as needed.
 		numCol := len(entityColName[class])
-		d.col[class] = make([]*db.Col, numCol)
+		d.col[class] = make([]Collection, numCol)
 		d.colName[class] = make([]string, numCol)
 		d.numRecordsLoad[class] = make([]uint, numCol)
 		d.numRecordsScan[class] = make([]uint, numCol)
@@ -449,9 +602,51 @@ func (d *Database) initialize() (bool, *ReturnCode) {
 			}
 		}
 	}
+
+	// the series, relations, playlists and tombstones collections do not
+	// store entities, so they are not included in the per-class collections
+	// above.
+	var ret *ReturnCode
+	if d.series, ret = d.initCollection(seriesColName, seriesIndex); nil != ret {
+		return false, ret
+	}
+	if d.relations, ret = d.initCollection(relationColName, relationIndex...); nil != ret {
+		return false, ret
+	}
+	if d.playlists, ret = d.initCollection(playlistColName, playlistIndex...); nil != ret {
+		return false, ret
+	}
+	if d.tombstones, ret = d.initCollection(tombstoneColName, tombstoneIndex...); nil != ret {
+		return false, ret
+	}
+
 	return true, nil
 }
 
+// function initCollection() creates the named collection, along with the given
+// indices, if it does not already exist. returns a reference to the collection.
+func (d *Database) initCollection(name string, index ...[]string) (Collection, *ReturnCode) {
+
+	existed := d.store.ColExists(name)
+	if !existed {
+		if err := d.store.Create(name); nil != err {
+			return nil, rcDatabaseError.specf(
+				"initialize(): %s: Create(%q): %s", d, name, err)
+		}
+		infoLog.tracef("created database collection: %q (%s)", name, d.name)
+	}
+	col := d.store.Use(name)
+	if !existed {
+		for _, idx := range index {
+			if err := col.Index(idx); nil != err {
+				return nil, rcDatabaseError.specf(
+					"initialize(): %s: Index(%q): %s", d, name, err)
+			}
+		}
+	}
+	return col, nil
+}
+
 // function scrub() fixes corrupt records and defragments disk space used by the
 // database -- performed on all collections in the database.
 func (d *Database) scrub() {
@@ -466,4 +661,283 @@ func (d *Database) scrub() {
 			col[kind] = d.store.Use(name)
 		}
 	}
+	if d.store.ColExists(seriesColName) {
+		d.store.Scrub(seriesColName)
+	}
+	d.series = d.store.Use(seriesColName)
+	if d.store.ColExists(relationColName) {
+		d.store.Scrub(relationColName)
+	}
+	d.relations = d.store.Use(relationColName)
+	if d.store.ColExists(playlistColName) {
+		d.store.Scrub(playlistColName)
+	}
+	d.playlists = d.store.Use(playlistColName)
+	if d.store.ColExists(tombstoneColName) {
+		d.store.Scrub(tombstoneColName)
+	}
+	d.tombstones = d.store.Use(tombstoneColName)
+}
+
+// function allCols() returns the name and reference of every collection in the
+// database, including the series, relations, playlists and tombstones
+// collections.
+func (d *Database) allCols() ([]string, []Collection) {
+
+	name := []string{}
+	col := []Collection{}
+	for class := range d.col {
+		name = append(name, d.colName[class]...)
+		col = append(col, d.col[class]...)
+	}
+	return append(name, seriesColName, relationColName, playlistColName, tombstoneColName),
+		append(col, d.series, d.relations, d.playlists, d.tombstones)
+}
+
+// function config() reads the database configuration actually in effect, i.e.
+// the configuration file written when the database was created, which may
+// differ from the current command-line options.
+func (d *Database) config() (*JSONDataConfig, *ReturnCode) {
+
+	configPath := filepath.Join(d.absPath, dataConfigFileName)
+	data, err := ioutil.ReadFile(configPath)
+	if nil != err {
+		return nil, rcDatabaseError.specf(
+			"config(): %s: ioutil.ReadFile(%q): %s", d, configPath, err)
+	}
+	jdc := &JSONDataConfig{}
+	if ret := jdc.unmarshal(data); nil != ret {
+		return nil, ret
+	}
+	return jdc, nil
+}
+
+// function diskUsage() returns the total size (in bytes) of all files in the
+// database directory.
+func (d *Database) diskUsage() (uint64, *ReturnCode) {
+
+	size := uint64(0)
+	err := filepath.Walk(d.absPath,
+		func(p string, info os.FileInfo, err error) error {
+			if nil != err {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				size += uint64(info.Size())
+			}
+			return nil
+		})
+	if nil != err {
+		return size, rcDatabaseError.specf(
+			"diskUsage(): %s: filepath.Walk(%q): %s", d, d.absPath, err)
+	}
+	return size, nil
+}
+
+// function verify() reads every record of every collection in the database,
+// returning the number of records read and the number of those which could not
+// be decoded. corrupt records may be removed with function scrub().
+func (d *Database) verify() (uint, uint) {
+
+	total, corrupt := uint(0), uint(0)
+	name, col := d.allCols()
+	for i, c := range col {
+		c.ForEachDoc(
+			func(id int, doc []byte) bool {
+				total++
+				var rec map[string]interface{}
+				if err := json.Unmarshal(doc, &rec); nil != err {
+					corrupt++
+					warnLog.tracef("corrupt database record: %q[%d] (%s): %s", name[i], id, d.name, err)
+				}
+				return true
+			})
+	}
+	return total, corrupt
+}
+
+// function rebuild() removes and reinstalls every index of every collection in
+// the database, which recovers from index entries that no longer agree with
+// the records they refer to.
+func (d *Database) rebuild() *ReturnCode {
+
+	name, col := d.allCols()
+	for i, c := range col {
+		for _, idx := range c.AllIndexes() {
+			if err := c.Unindex(idx); nil != err {
+				return rcDatabaseError.specf(
+					"rebuild(): %s: Unindex(%q, %q): %s", d, name[i], idx, err)
+			}
+			if err := c.Index(idx); nil != err {
+				return rcDatabaseError.specf(
+					"rebuild(): %s: Index(%q, %q): %s", d, name[i], idx, err)
+			}
+		}
+	}
+	return nil
+}
+
+// function ensureIndex() installs the given index on every collection of the
+// given entity class that does not already have it. this is used for indices
+// that are not known until runtime (e.g. user-defined metadata fields), and
+// which therefore may be added to a database long after it was created.
+func (d *Database) ensureIndex(class EntityClass, idx EntityIndex) *ReturnCode {
+
+	want := strings.Join(idx, db.IndexPathSep)
+	for kind, col := range d.col[class] {
+		installed := false
+		for _, path := range col.AllIndexes() {
+			if strings.Join(path, db.IndexPathSep) == want {
+				installed = true
+				break
+			}
+		}
+		if !installed {
+			if err := col.Index(idx); nil != err {
+				return rcDatabaseError.specf(
+					"ensureIndex(): %s: Index(%q, %q): %s", d, d.colName[class][kind], want, err)
+			}
+			infoLog.tracef("created database index: %q.%q (%s)", d.colName[class][kind], want, d.name)
+		}
+	}
+	return nil
+}
+
+// function query() performs a simple equality query on the given index of the
+// collection identified by the given class and kind, adding the hash key ID of
+// each matching record to the given result set.
+func (d *Database) query(class EntityClass, kind int, idx EntityIndex, val interface{}, result *map[int]struct{}) *ReturnCode {
+
+	if kind < 0 || kind >= len(d.col[class]) {
+		return rcInvalidArgs.specf(
+			"query(%d, %d): %s: invalid collection", class, kind, d)
+	}
+
+	in := make([]interface{}, len(idx))
+	for i, s := range idx {
+		in[i] = s
+	}
+	if err := evalQuery(map[string]interface{}{
+		"eq": val,
+		"in": in,
+	}, d.col[class][kind], result); nil != err {
+		return rcDatabaseError.specf(
+			"query(%d, %d): %s: EvalQuery(%q = %v): %s", class, kind, d, idx, val, err)
+	}
+	return nil
+}
+
+// function migrate() copies every collection, index and record of the database
+// into a new database of the given storage engine, which then replaces it.
+// record IDs are preserved, since records refer to each other by ID. the new
+// database is written beside the current one, which is left untouched if the
+// copy fails.
+func (d *Database) migrate(engine string) (uint, *ReturnCode) {
+
+	if engine == d.engine {
+		return 0, rcInvalidArgs.specf("migrate(%q): %s: already using this engine", engine, d)
+	}
+	if dbEngineMemory == d.engine {
+		return 0, rcInvalidArgs.specf("migrate(%q): %s: database is discarded on exit", engine, d)
+	}
+
+	tmp := d.absPath + migrateSuffix
+	if err := os.RemoveAll(tmp); nil != err {
+		return 0, rcDatabaseError.specf("migrate(%q): %s: os.RemoveAll(%q): %s", engine, d, tmp, err)
+	}
+	if err := os.MkdirAll(tmp, os.ModePerm); nil != err {
+		return 0, rcDatabaseError.specf("migrate(%q): %s: os.MkdirAll(%q): %s", engine, d, tmp, err)
+	}
+	dst, err := openStore(tmp, engine)
+	if nil != err {
+		os.RemoveAll(tmp)
+		return 0, rcDatabaseError.specf("migrate(%q): %s: openStore(%q): %s", engine, d, tmp, err)
+	}
+
+	// abandon the copy on failure, leaving the current database untouched.
+	fail := func(ret *ReturnCode) (uint, *ReturnCode) {
+		dst.Close()
+		os.RemoveAll(tmp)
+		return 0, ret
+	}
+
+	count := uint(0)
+	colName := d.store.AllCols()
+	for _, name := range colName {
+		src := d.store.Use(name)
+		if err := dst.Create(name); nil != err {
+			return fail(rcDatabaseError.specf("migrate(%q): %s: Create(%q): %s", engine, d, name, err))
+		}
+		col := dst.Use(name)
+		for _, idx := range src.AllIndexes() {
+			if err := col.Index(idx); nil != err {
+				return fail(rcDatabaseError.specf("migrate(%q): %s: Index(%q, %q): %s", engine, d, name, idx, err))
+			}
+		}
+		var insErr error
+		src.ForEachDoc(
+			func(id int, doc []byte) (moveOn bool) {
+				rec := map[string]interface{}{}
+				if err := json.Unmarshal(doc, &rec); nil != err {
+					warnLog.tracef("migrate(%q): skipping corrupt record %d: %s", name, id, err)
+					return true
+				}
+				if insErr = col.InsertRecovery(id, rec); nil != insErr {
+					return false
+				}
+				count++
+				return true
+			})
+		if nil != insErr {
+			return fail(rcDatabaseError.specf("migrate(%q): %s: InsertRecovery(%q): %s", engine, d, name, insErr))
+		}
+	}
+	if err := dst.Close(); nil != err {
+		return fail(rcDatabaseError.specf("migrate(%q): %s: Close(%q): %s", engine, d, tmp, err))
+	}
+
+	// replace the current database. the bolt file is moved in last (or removed
+	// last), so that an interrupted migration is detected as the old engine by
+	// function storeEngine().
+	if err := d.store.Close(); nil != err {
+		warnLog.logf("migrate(%q): %s: Close(): %s", engine, d, err)
+	}
+	boltFile := filepath.Join(d.absPath, db.BoltFileName)
+	switch engine {
+	case dbEngineBolt:
+		err = os.Rename(filepath.Join(tmp, db.BoltFileName), boltFile)
+		if nil == err {
+			for _, name := range colName {
+				os.RemoveAll(filepath.Join(d.absPath, name))
+			}
+		}
+	case dbEngineTiedot:
+		for _, name := range colName {
+			dir := filepath.Join(d.absPath, name)
+			os.RemoveAll(dir)
+			if err = os.Rename(filepath.Join(tmp, name), dir); nil != err {
+				break
+			}
+		}
+		if nil == err {
+			err = os.Remove(boltFile)
+		}
+	}
+	os.RemoveAll(tmp)
+
+	// reopen whichever database is now in place.
+	d.engine = storeEngine(d.absPath)
+	store, openErr := openStore(d.absPath, d.engine)
+	if nil != openErr {
+		return count, rcDatabaseError.specf(
+			"migrate(%q): %s: openStore(%q): %s (restart required)", engine, d, d.absPath, openErr)
+	}
+	d.store = store
+	if ok, ret := d.initialize(); !ok {
+		return count, ret
+	}
+	if nil != err {
+		return count, rcDatabaseError.specf("migrate(%q): %s: %s", engine, d, err)
+	}
+	return count, nil
 }
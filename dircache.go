@@ -0,0 +1,181 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: dircache.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the directory cache, which speeds up rescans of large, mostly
+//    static libraries. each directory's modification time and number of
+//    entries are recorded when it is scanned, and the files of a directory are
+//    not examined again on later scans until either of them changes. the cache
+//    is stored alongside the library database.
+//
+// =============================================================================
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// local unexported constants for the directory cache.
+const (
+	dirCacheFileName = "dircache.json"
+)
+
+// type DirCacheEntry is the state of a single directory when it was last
+// scanned in full.
+type DirCacheEntry struct {
+	ModTime int64    `json:"m"` // modification time (unix nanoseconds)
+	Count   int      `json:"n"` // number of entries (files and subdirectories)
+	Subdir  []string `json:"d"` // names of subdirectories that were scanned
+}
+
+// type DirCache holds the directory states recorded by the previous scan, and
+// those being recorded by the current scan, keyed by path relative to the
+// library root. the states are recorded concurrently by the workers of a scan
+// (see scanpool.go).
+type DirCache struct {
+	mutex sync.Mutex
+	prev  map[string]DirCacheEntry
+	curr  map[string]DirCacheEntry
+}
+
+// function openDirCache() reads the directory states recorded by the previous
+// scan of the library, which is the most recent scan performed by this process
+// if there was one. the previous states are ignored (so every directory is
+// scanned in full) if a full scan was requested or if the database was just
+// created.
+func (l *Library) openDirCache() *DirCache {
+
+	cache := &DirCache{
+		prev: map[string]DirCacheEntry{},
+		curr: map[string]DirCacheEntry{},
+	}
+	if l.fullScan {
+		return cache
+	}
+	if nil != l.dirCache {
+		cache.prev = l.dirCache.curr
+		return cache
+	}
+	if l.db.isFirstAppearance() {
+		return cache
+	}
+	if _, ret := readJSONFile(filepath.Join(l.db.absPath, dirCacheFileName), &cache.prev); nil != ret {
+		// a corrupt cache only costs us one full scan.
+		warnLog.trace(ret)
+		cache.prev = map[string]DirCacheEntry{}
+	}
+	return cache
+}
+
+// function saveDirCache() replaces the directory states recorded by the
+// previous scan with those recorded by the current scan.
+func (l *Library) saveDirCache(cache *DirCache) *ReturnCode {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return writeJSONFile(filepath.Join(l.db.absPath, dirCacheFileName), cache.curr)
+}
+
+// function unchanged() returns the directory state recorded by the previous
+// scan of the given directory if its modification time and number of entries
+// have not changed since then. the state is carried over to the current scan.
+func (c *DirCache) unchanged(rel string, info os.FileInfo, count int) (DirCacheEntry, bool) {
+
+	if nil == c {
+		return DirCacheEntry{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.prev[rel]
+	if !ok || entry.ModTime != info.ModTime().UnixNano() || entry.Count != count {
+		return DirCacheEntry{}, false
+	}
+	c.curr[rel] = entry
+	return entry, true
+}
+
+// function store() records the state of the given directory, with the given
+// number of entries, after all of them have been scanned. the given
+// subdirectories are still traversed while the directory remains unchanged.
+func (c *DirCache) store(rel string, info os.FileInfo, count int, subdir []string) {
+
+	if nil == c {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.curr[rel] = DirCacheEntry{
+		ModTime: info.ModTime().UnixNano(),
+		Count:   count,
+		Subdir:  subdir,
+	}
+}
+
+// function subdirs() returns the entries of the given directory (named by the
+// given list) which were themselves recorded, i.e. its subdirectories, once
+// they have been scanned.
+func (c *DirCache) subdirs(rel string, name []string) []string {
+
+	if nil == c {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	subdir := []string{}
+	for _, n := range name {
+		if _, ok := c.curr[filepath.Join(rel, n)]; ok {
+			subdir = append(subdir, n)
+		}
+	}
+	return subdir
+}
+
+// function carry() carries the states of the given directory and all of its
+// subdirectories recorded by the previous scan over to the current scan,
+// without examining them (e.g., when resuming an interrupted scan).
+func (c *DirCache) carry(rel string) {
+
+	if nil == c {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.carrySubdirs(rel)
+}
+
+// function carrySubdirs() is the recursive step of function carry(). the caller
+// must hold the cache's lock.
+func (c *DirCache) carrySubdirs(rel string) {
+
+	entry, ok := c.prev[rel]
+	if !ok {
+		return
+	}
+	c.curr[rel] = entry
+	for _, name := range entry.Subdir {
+		c.carrySubdirs(filepath.Join(rel, name))
+	}
+}
+
+// function merge() carries the states of all directories recorded by the
+// previous scan, but not by the current scan, over to the current scan. this
+// keeps the states of the directories not reached by an interrupted scan.
+func (c *DirCache) merge() {
+
+	if nil == c {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for rel, entry := range c.prev {
+		if _, ok := c.curr[rel]; !ok {
+			c.curr[rel] = entry
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: dirstream.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines how huge directories (e.g. flat camera dumps of 50k+ files) are
+//    scanned. rather than reading every entry name at once, the entries of a
+//    directory are read (and scanned) a chunk at a time, yielding to the
+//    scheduler between chunks so that the user interface stays responsive,
+//    and the progress through each huge directory is published so that the
+//    scan doesn't appear to hang.
+//
+// =============================================================================
+
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// local unexported constants for reading directories.
+const (
+	dirChunkSize = 1024 // number of entries read from a directory at a time
+)
+
+// type DirProgress is the progress of a scan through the huge directories it
+// is currently in, most recently entered last. the workers of a scan may be in
+// several at once (see scanpool.go).
+type DirProgress struct {
+	mutex sync.Mutex
+	dir   []DirProgressEntry
+}
+
+// type DirProgressEntry is the progress of a scan through a single directory.
+type DirProgressEntry struct {
+	path  string // path relative to the library root
+	count int    // number of entries scanned so far
+	total int    // number of entries when the scan began
+}
+
+// function begin() publishes that the scan entered the given huge directory
+// with the given number of entries.
+func (p *DirProgress) begin(path string, total int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.dir = append(p.dir, DirProgressEntry{path: path, count: 0, total: total})
+}
+
+// function advance() publishes that the given number of entries of the given
+// huge directory have been scanned.
+func (p *DirProgress) advance(path string, n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i := len(p.dir) - 1; i >= 0; i-- {
+		if path == p.dir[i].path {
+			p.dir[i].count += n
+			return
+		}
+	}
+}
+
+// function end() publishes that the scan left the given huge directory.
+func (p *DirProgress) end(path string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i := len(p.dir) - 1; i >= 0; i-- {
+		if path == p.dir[i].path {
+			p.dir = append(p.dir[:i], p.dir[i+1:]...)
+			return
+		}
+	}
+}
+
+// function current() returns the progress through the huge directory most
+// recently entered by the scan, and true if the scan is in one. this is intended for polling
+// by UI status indicators.
+func (p *DirProgress) current() (DirProgressEntry, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if 0 == len(p.dir) {
+		return DirProgressEntry{}, false
+	}
+	return p.dir[len(p.dir)-1], true
+}
+
+// function countDir() reads the names of the entries in the given directory a
+// chunk at a time. if the directory has at most dirChunkSize entries, their
+// names are returned; otherwise, only their number is returned, so that the
+// names of a huge directory are never all held at once (see streamDir()).
+func countDir(absPath string) ([]string, int, error) {
+
+	dir, err := os.Open(absPath)
+	if nil != err {
+		return nil, 0, err
+	}
+	defer dir.Close()
+
+	var name []string
+	count := 0
+	for {
+		chunk, err := dir.Readdirnames(dirChunkSize)
+		count += len(chunk)
+		if count <= dirChunkSize {
+			name = append(name, chunk...)
+		} else {
+			name = nil
+		}
+		// Readdirnames(n > 0) returns io.EOF once every entry has been read,
+		// including an empty directory.
+		if io.EOF == err {
+			return name, count, nil
+		}
+		if nil != err {
+			return nil, 0, err
+		}
+	}
+}
+
+// function streamDir() calls the given function with the names of the entries
+// in the given directory, a chunk of at most dirChunkSize at a time. returns
+// the number of entries read.
+func (l *Library) streamDir(absPath string, fn func(name []string)) (int, error) {
+
+	var dir *os.File
+	err := l.retryFS(func() (err error) {
+		dir, err = os.Open(absPath)
+		return err
+	})
+	if nil != err {
+		return 0, err
+	}
+	defer dir.Close()
+
+	count := 0
+	for {
+		chunk, err := dir.Readdirnames(dirChunkSize)
+		if len(chunk) > 0 {
+			count += len(chunk)
+			fn(chunk)
+		}
+		if io.EOF == err {
+			return count, nil
+		}
+		if nil != err {
+			return count, err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: discovery.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the discovery feed, which keeps a bounded history of everything a
+//    library's loader and scanner discovered. the loader and scanner start
+//    before any view exists, so a view attached to the feed later is first
+//    replayed the history it missed, and then receives new discoveries as they
+//    occur.
+//
+// =============================================================================
+
+package main
+
+import (
+	"sync"
+)
+
+// local unexported constants for the discovery feed.
+const (
+	// max number of discoveries retained for replay per library. once full,
+	// the oldest discoveries are discarded.
+	maxDiscoveryHistory = 1 << 16
+)
+
+// type DiscoverySink is a function receiving the discoveries of a library,
+// e.g. function (*Layout).addDiscovery().
+type DiscoverySink func(*Library, *Discovery) *ReturnCode
+
+// type DiscoveryFeed is the history of a library's discoveries, along with the
+// sinks currently receiving them. it is safe for concurrent use.
+type DiscoveryFeed struct {
+	*sync.Mutex
+	history []*Discovery    // discoveries retained for replay (circular once full)
+	oldest  int             // index of the oldest discovery once history is full
+	dropped uint            // number of discoveries discarded from history
+	sink    []DiscoverySink // attached receivers of new discoveries
+}
+
+// function newDiscoveryFeed() creates an empty DiscoveryFeed with no sinks.
+func newDiscoveryFeed() *DiscoveryFeed {
+	return &DiscoveryFeed{
+		Mutex:   new(sync.Mutex),
+		history: []*Discovery{},
+		oldest:  0,
+		dropped: 0,
+		sink:    []DiscoverySink{},
+	}
+}
+
+// function discover() records a new discovery of the library, and forwards it
+// to all attached sinks.
+func (l *Library) discover(disco *Discovery) {
+
+	f := l.feed
+	f.Lock()
+	defer f.Unlock()
+
+	if len(f.history) < maxDiscoveryHistory {
+		f.history = append(f.history, disco)
+	} else {
+		if 0 == f.dropped {
+			warnLog.tracef("discovery history full, discarding oldest: %q (max = %d)",
+				l.name, maxDiscoveryHistory)
+		}
+		f.history[f.oldest] = disco
+		f.oldest = (f.oldest + 1) % maxDiscoveryHistory
+		f.dropped++
+	}
+
+	for _, sink := range f.sink {
+		if ret := sink(l, disco); nil != ret {
+			warnLog.trace(ret)
+		}
+	}
+}
+
+// function attach() replays every discovery retained in the library's history
+// to the given sink, and then attaches it to receive all new discoveries. no
+// discovery is missed nor received twice, since the library cannot discover
+// anything while the history is being replayed.
+func (l *Library) attach(sink DiscoverySink) {
+
+	f := l.feed
+	f.Lock()
+	defer f.Unlock()
+
+	if f.dropped > 0 {
+		warnLog.logf("%d of the earliest items discovered in %q are not shown; "+
+			"they will appear once the library is reloaded", f.dropped, l.name)
+	}
+	for i := range f.history {
+		disco := f.history[(f.oldest+i)%len(f.history)]
+		if ret := sink(l, disco); nil != ret {
+			warnLog.trace(ret)
+		}
+	}
+	f.sink = append(f.sink, sink)
+}
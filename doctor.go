@@ -0,0 +1,203 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: doctor.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "doctor" command, which inspects the environment pimm runs
+//    in -- the data directory and its library databases, their lock files,
+//    the external tools used by optional features, and the capabilities of
+//    the terminal -- and prints an actionable fix for each issue found.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// type DoctorReport collects the issues found by the doctor command.
+type DoctorReport struct {
+	issues int
+}
+
+// function pass() reports a check that passed.
+func (r *DoctorReport) pass(format string, v ...interface{}) {
+	rawLog.logf("ok:      %s", fmt.Sprintf(format, v...))
+}
+
+// function note() reports something worth knowing that is not an issue.
+func (r *DoctorReport) note(format string, v ...interface{}) {
+	rawLog.logf("note:    %s", fmt.Sprintf(format, v...))
+}
+
+// function fail() reports an issue along with the given fix.
+func (r *DoctorReport) fail(fix string, format string, v ...interface{}) {
+	r.issues++
+	rawLog.logf("warning: %s", fmt.Sprintf(format, v...))
+	rawLog.logf("         fix: %s", fix)
+}
+
+// function doctorCommand() implements the "doctor" command.
+func doctorCommand(options *Options, args []string) *ReturnCode {
+
+	if 0 != len(args) {
+		return rcInvalidArgs.spec("doctor: expected no arguments")
+	}
+
+	r := &DoctorReport{issues: 0}
+	r.databases(options)
+	r.tools(options)
+	r.terminal()
+
+	if 0 == r.issues {
+		rawLog.log("your system is ready to pimm.")
+		return nil
+	}
+	return rcDoctorIssues.specf("%d issue(s) found", r.issues)
+}
+
+// function databases() inspects the data directory and each library database
+// in it, including their lock files.
+func (r *DoctorReport) databases(options *Options) {
+
+	dat := options.LibData.string
+	if info, err := os.Stat(dat); nil != err {
+		if os.IsNotExist(err) {
+			r.note("data directory does not exist yet (created when a library is first opened): %q", dat)
+		} else {
+			r.fail("check the permissions of the data directory, or choose another with -"+options.LibData.name,
+				"data directory cannot be read: %s", err)
+		}
+		return
+	} else if !info.IsDir() {
+		r.fail("move the file away, or choose another data directory with -"+options.LibData.name,
+			"data directory is not a directory: %q", dat)
+		return
+	}
+
+	_, list, ret := listDatabases(dat)
+	if nil != ret {
+		r.fail(fmt.Sprintf("restore %q from a backup, or remove it and reopen each library", registryFileName),
+			"library registry cannot be read: %s", ret)
+		return
+	}
+	r.pass("data directory %q has %d database(s)", dat, len(list))
+
+	for _, d := range list {
+		switch d.state {
+		case dsOrphaned:
+			r.fail(fmt.Sprintf("command \"relink %s NEW_PATH\" if the library moved, or command \"prune %s\" if it was removed", d.name, d.name),
+				"database %s is orphaned: library no longer exists: %q", d.name, d.path)
+		case dsMissing:
+			r.fail(fmt.Sprintf("command \"prune %s\" (the database is recreated when the library is next opened)", d.name),
+				"database %s of library %q is missing", d.name, d.path)
+		case dsUnregistered:
+			r.fail(fmt.Sprintf("open its library to register it, or command \"gc %s\" if it has none", d.name),
+				"database directory %s is not registered to any library", d.name)
+		}
+		if dsMissing == d.state {
+			continue
+		}
+		dir := filepath.Join(dat, d.name)
+		var conf map[string]interface{}
+		if _, ret := readJSONFile(filepath.Join(dir, dataConfigFileName), &conf); nil != ret {
+			r.fail(fmt.Sprintf("command \"prune %s\" and rescan the library", d.name),
+				"database %s is damaged: %s", d.name, ret)
+		}
+		r.lock(dir, d)
+	}
+}
+
+// function lock() inspects the lock file of the given database directory.
+func (r *DoctorReport) lock(dir string, d *DatabaseInfo) {
+
+	p := filepath.Join(dir, databaseLockFileName)
+	if _, err := os.Stat(p); os.IsNotExist(err) {
+		return
+	}
+	pid, ok := lockOwner(p)
+	switch {
+	case !ok:
+		r.fail(fmt.Sprintf("if pimm is not running, remove %q", p),
+			"database %s has an unreadable lock file", d.name)
+	case processExists(pid):
+		r.note("database %s is in use by process %d", d.name, pid)
+	default:
+		r.fail(fmt.Sprintf("remove %q (it is also taken over when the library is next opened)", p),
+			"database %s has a stale lock of process %d, which is no longer running", d.name, pid)
+	}
+}
+
+// function tools() looks for the external tools used by optional features.
+// missing tools are only an issue if their feature is configured.
+func (r *DoctorReport) tools(options *Options) {
+
+	player := ""
+	if f := strings.Fields(mediaPlayerCommand()); len(f) > 0 {
+		player = f[0]
+	}
+	for _, t := range []struct {
+		name    string
+		purpose string
+		needed  bool
+		fix     string
+	}{
+		{player, "playing media selected in the media browser", "" != player, "install " + player + ", or choose another player with -" + options.Player.name},
+		{fpcalcCommand, "command \"identify\"", "" != options.AcoustIDKey.string, "install Chromaprint (e.g. package libchromaprint-tools)"},
+		{targetSSH, "remote playback targets", len(options.Target.StringList) > 0 && ffTargets.isEnabled(), "install an OpenSSH client"},
+		{archiveUnrar, "listing rar archives (option -" + options.Archives.name + ")", archiveListed(options, archiveRar), "install unrar, or drop format rar from option -" + options.Archives.name},
+		{"tmux", "option -" + options.TmuxStatus.name, options.TmuxStatus.bool, "install tmux, or drop option -" + options.TmuxStatus.name},
+	} {
+		if "" == t.name {
+			continue
+		}
+		if path, err := exec.LookPath(t.name); nil == err {
+			r.pass("%s found: %s", t.name, path)
+		} else if t.needed {
+			r.fail(t.fix, "%s (needed by %s) was not found in PATH", t.name, t.purpose)
+		} else {
+			r.note("%s (used by %s) was not found in PATH", t.name, t.purpose)
+		}
+	}
+}
+
+// function terminal() inspects the capabilities of the terminal declared by
+// the environment.
+func (r *DoctorReport) terminal() {
+
+	term := os.Getenv("TERM")
+	if "" == term || "dumb" == term {
+		r.fail("run pimm in a terminal emulator, or use the line-oriented mode (-accessible)",
+			"terminal %q cannot draw the user interface", term)
+	} else {
+		r.pass("terminal: %s", term)
+	}
+
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		r.pass("terminal supports true color")
+	default:
+		r.note("terminal does not declare true color support (COLORTERM); colors are approximated")
+	}
+
+	locale := ""
+	for _, v := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale = os.Getenv(v); "" != locale {
+			break
+		}
+	}
+	if l := strings.ToLower(locale); strings.Contains(l, "utf-8") || strings.Contains(l, "utf8") {
+		r.pass("locale supports unicode: %s", locale)
+	} else {
+		r.fail("set LANG to a UTF-8 locale (e.g. LANG=en_US.UTF-8)",
+			"locale %q may not draw unicode glyphs (indicators, spectrum, etc.)", locale)
+	}
+}
@@ -0,0 +1,293 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: dryrun.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the scan dry-run, which traverses a library path and classifies
+//    its files exactly as a scan would, reporting what would be indexed and
+//    what would be ignored without creating or modifying any database. the
+//    library is configured by the same options as for a scan (see function
+//    configureLibrary()), so that ignored names, symlinks, archives, sniffing
+//    and the quarantine of files in progress all apply.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// local unexported constants for the scan dry-run.
+const (
+	// max number of file paths listed as a sample of each dry-run category.
+	dryRunSampleSize = 10
+)
+
+// type DryRunReport summarizes the classification of every file found in a
+// library path by a scan dry-run.
+type DryRunReport struct {
+	absPath        string
+	numMedia       [mkCOUNT]uint
+	numSupport     [skCOUNT]uint
+	numIgnored     uint // regular files of no known kind
+	numExcluded    uint // files and directories ignored by name
+	numHeld        uint // files held in quarantine (see quarantine.go)
+	numSkipped     uint // symlinks, devices, unreadable files, etc.
+	sampleIndexed  []string
+	sampleIgnored  []string
+	sampleExcluded []string
+	sampleHeld     []string
+	sampleSkipped  []string
+}
+
+// function dryRunScan() traverses the given library path, classifying each file
+// as a scan would, without touching any database.
+func dryRunScan(opt *Options, lib string) (*DryRunReport, *ReturnCode) {
+
+	dir, err := os.Getwd()
+	if nil != err {
+		return nil, rcInvalidLibrary.specf(
+			"dryRunScan(%q): os.Getwd(): %s", lib, err)
+	}
+	abs, err := libraryPath(lib)
+	if nil != err {
+		return nil, rcInvalidLibrary.specf(
+			"dryRunScan(%q): libraryPath(): %s", lib, err)
+	}
+	if info, err := os.Stat(abs); nil != err {
+		return nil, rcInvalidLibrary.specf(
+			"dryRunScan(%q): os.Stat(): %s", lib, err)
+	} else if !info.IsDir() {
+		return nil, rcInvalidLibrary.specf(
+			"dryRunScan(%q): not a directory", lib)
+	}
+
+	// the library is configured exactly as it would be for a scan, but its
+	// database is never opened. a portable library's own database is still
+	// never examined.
+	l := configureLibrary(opt, nil, abs, dir, depthUnlimited)
+	_, _, dbPath := libraryDatabasePath(opt, abs)
+
+	return l.dryRun(dbPath), nil
+}
+
+// function dryRun() classifies each file of the library as a scan would,
+// never examining the database directory at the given path.
+func (l *Library) dryRun(dbPath string) *DryRunReport {
+
+	report := &DryRunReport{absPath: l.absPath}
+	l.resetSymlinks()
+	l.quarantine.torrent.refresh()
+	l.dryRunDive(report, dbPath, l.absPath, 1)
+
+	return report
+}
+
+// function dryRunDive() is the recursive step of the scan dry-run, classifying
+// the file at the given path by the same rules as function scanDive().
+func (l *Library) dryRunDive(r *DryRunReport, dbPath, absPath string, depth uint) {
+
+	// operating system metadata, trash and the names given with option -ignore
+	// are never examined (see ignore.go).
+	if absPath != l.absPath && l.isIgnored(absPath) {
+		r.numExcluded++
+		r.sample(&r.sampleExcluded, absPath)
+		return
+	}
+
+	info, err := os.Lstat(absPath)
+	if nil != err {
+		r.numSkipped++
+		r.sample(&r.sampleSkipped, absPath)
+		return
+	}
+	mode := info.Mode()
+
+	// symlinks are scanned as the file or directory they point to, if enabled
+	// (see symlink.go).
+	if 0 != mode&os.ModeSymlink && l.followLinks {
+		target, ret := l.followSymlink(absPath)
+		if nil != ret {
+			r.numSkipped++
+			r.sample(&r.sampleSkipped, absPath)
+			return
+		}
+		if nil == target {
+			return
+		}
+		info, mode = target, target.Mode()
+	}
+
+	switch {
+	case (mode & os.ModeDir) > 0:
+		if filepath.Clean(absPath) == dbPath {
+			return
+		}
+		if depthUnlimited != l.maxDepth && depth > l.maxDepth {
+			r.numSkipped++
+			r.sample(&r.sampleSkipped, absPath)
+			return
+		}
+		dir, err := os.Open(absPath)
+		if nil != err {
+			r.numSkipped++
+			r.sample(&r.sampleSkipped, absPath)
+			return
+		}
+		name, err := dir.Readdirnames(-1)
+		dir.Close()
+		if nil != err {
+			r.numSkipped++
+			r.sample(&r.sampleSkipped, absPath)
+			return
+		}
+		sort.Strings(name)
+		for _, n := range name {
+			l.dryRunDive(r, dbPath, path.Join(absPath, n), depth+1)
+		}
+
+	case (mode & os.ModeSymlink) > 0,
+		(mode & (os.ModeDevice | os.ModeNamedPipe | os.ModeSocket | os.ModeCharDevice)) > 0:
+		r.numSkipped++
+		r.sample(&r.sampleSkipped, absPath)
+
+	default:
+		ext := path.Ext(absPath)
+
+		// files still being downloaded or written are held back.
+		if _, held := l.quarantine.check(absPath, ext, info); held {
+			r.numHeld++
+			r.sample(&r.sampleHeld, absPath)
+			return
+		}
+
+		// the media inside archives are listed, if enabled for this library.
+		if format, ok := l.archiveExt[strings.ToLower(ext)]; ok {
+			entry, err := listArchive(format, absPath, info)
+			if nil != err {
+				r.numSkipped++
+				r.sample(&r.sampleSkipped, absPath)
+				return
+			}
+			for _, e := range entry {
+				if kind, _ := mediaKindOfFile(e.path, path.Ext(e.path)); mkUnknown != kind {
+					r.numMedia[kind]++
+					r.sample(&r.sampleIndexed, absPath+archiveSep+e.path)
+				}
+			}
+			return
+		}
+
+		// files of unknown extensions may be classified by their content.
+		kindExt := l.sniffExt(absPath, ext)
+		if kind, _ := mediaKindOfFile(absPath, kindExt); mkUnknown != kind {
+			// junk merely named like audio is flagged rather than indexed.
+			if mkAudio == kind && l.checkAudio && nil != checkAudioHeader(absPath, kindExt) {
+				r.numSkipped++
+				r.sample(&r.sampleSkipped, absPath)
+				return
+			}
+			r.numMedia[kind]++
+			r.sample(&r.sampleIndexed, absPath)
+		} else if kind, _ := supportKindOfFile(absPath, kindExt); skUnknown != kind {
+			r.numSupport[kind]++
+			r.sample(&r.sampleIndexed, absPath)
+		} else {
+			r.numIgnored++
+			r.sample(&r.sampleIgnored, absPath)
+		}
+	}
+}
+
+// function sample() appends the given path, relative to the library, to the
+// given sample list, unless it is already full.
+func (r *DryRunReport) sample(list *[]string, p string) {
+	if len(*list) < dryRunSampleSize {
+		rel, err := filepath.Rel(r.absPath, p)
+		if nil != err {
+			rel = p
+		}
+		*list = append(*list, rel)
+	}
+}
+
+// function numIndexed() returns the total number of files the scan would
+// index, of any kind.
+func (r *DryRunReport) numIndexed() uint {
+	total := uint(0)
+	for _, n := range r.numMedia {
+		total += n
+	}
+	for _, n := range r.numSupport {
+		total += n
+	}
+	return total
+}
+
+// function print() writes the report to the raw logger.
+func (r *DryRunReport) print() {
+
+	counts := []string{}
+	for kind, n := range r.numMedia {
+		counts = append(counts, fmt.Sprintf("%d %s", n, strings.ToLower(mediaColName[kind])))
+	}
+	for kind, n := range r.numSupport {
+		counts = append(counts, fmt.Sprintf("%d %s", n, strings.ToLower(supportColName[kind])))
+	}
+
+	rawLog.logf("%s (dry run, nothing written)", r.absPath)
+	rawLog.logf("  would index: %d (%s)", r.numIndexed(), strings.Join(counts, ", "))
+	rawLog.logf("  would ignore: %d", r.numIgnored)
+	rawLog.logf("  would exclude: %d (ignored by name)", r.numExcluded)
+	rawLog.logf("  would hold: %d (downloads and torrents in progress, files still being written)", r.numHeld)
+	rawLog.logf("  would skip: %d (symlinks, special, unreadable or invalid files)", r.numSkipped)
+
+	for _, s := range []struct {
+		desc string
+		list []string
+	}{
+		{"indexed", r.sampleIndexed},
+		{"ignored", r.sampleIgnored},
+		{"excluded", r.sampleExcluded},
+		{"held", r.sampleHeld},
+		{"skipped", r.sampleSkipped},
+	} {
+		if len(s.list) > 0 {
+			rawLog.logf("  sample %s:", s.desc)
+			for _, p := range s.list {
+				rawLog.logf("    %s", p)
+			}
+		}
+	}
+}
+
+// function dryRun() performs a scan dry-run on every library path provided on
+// the command line, printing a report for each.
+func dryRun(options *Options) *ReturnCode {
+
+	libArgs := options.Args()
+	if 0 == len(libArgs) {
+		return rcInvalidConfig.spec("no library paths provided")
+	}
+	for i, lib := range libArgs {
+		report, ret := dryRunScan(options, lib)
+		if nil != ret {
+			warnLog.log(ret)
+			continue
+		}
+		if i > 0 {
+			rawLog.log()
+		}
+		report.print()
+	}
+	return nil
+}
@@ -20,7 +20,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/HouzuoGuo/tiedot/db"
 	//"github.com/davecgh/go-spew/spew"
 )
 
@@ -45,7 +44,7 @@ type Entity struct {
 	AbsDir       string      // directory portion of AbsPath
 	AbsName      string      // file name portion of AbsPath
 	AbsBase      string      // AbsName without file name extension
-	RelPath      string      // CWD-relative path to media file
+	RelPath      string      // library-relative path to media file
 	Size         int64       // length in bytes for regular files; system-dependent for others
 	Mode         os.FileMode // file mode bits
 	TimeModified time.Time   // modification time
@@ -69,7 +68,7 @@ type EntityIndex []string
 type StorableEntity interface {
 	toRecord() (*EntityRecord, *ReturnCode)
 	fromRecord([]byte) *ReturnCode
-	fromID(*db.Col, int) *ReturnCode
+	fromID(Collection, int) *ReturnCode
 }
 
 // storage for the names and database indices for each enum ID of the various
@@ -100,7 +99,7 @@ func newEntity(lib *Library, class EntityClass, absPath, relPath, ext, extName s
 		AbsDir:       path.Dir(absPath), // (string)      directory portion of AbsPath
 		AbsName:      info.Name(),       // (string)      file name portion of AbsPath
 		AbsBase:      absBase,           // (string)      AbsName without file name extension
-		RelPath:      relPath,           // (string)      CWD-relative path to media file
+		RelPath:      relPath,           // (string)      library-relative path to media file
 		Size:         info.Size(),       // (int64)       length in bytes for regular files; system-dependent for others
 		Mode:         info.Mode(),       // (os.FileMode) file mode bits
 		TimeModified: info.ModTime(),    // (time.Time)   modification time
@@ -14,7 +14,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -32,11 +34,24 @@ const (
 
 // type ReturnCode contains information describing the reason for program exit,
 // including potential runtime errors with detailed diagnostic info.
+//
+// each of the general purpose return codes defined below is never modified.
+// instead, functions spec(), wrap(), etc. derive a new ReturnCode from it which
+// carries the details of a specific event. use errors.Is() to test if a given
+// error was derived from one of the general purpose return codes, and
+// errors.As() to recover the ReturnCode from an error that wraps one.
 type ReturnCode struct {
 	kind ReturnCodeKind // type of return code; affects how the message is displayed
 	code int            // value between 0 and 255 (inclusive) for portability
 	desc string         // built-in description of this general purpose return code
 	info string         // additional detail elaborating the return event
+
+	base *ReturnCode // general purpose return code this was derived from (nil if it is one)
+	err  error       // underlying error causing the return event (may be nil)
+
+	op      string // operation that failed, e.g. "scanDive"
+	library string // name of the library on which it was performed
+	path    string // path of the file or directory on which it was performed
 }
 
 // private constants
@@ -66,23 +81,50 @@ var (
 	rcInvalidJSONData  = newReturnCode(rkWarn, errorOffset+12, "invalid JSON data", "")          // cannot handle some JSON-related data object
 	rcQueryError       = newReturnCode(rkWarn, errorOffset+13, "failed to query database", "")   // couldn't perform query on database collection
 	rcTUIError         = newReturnCode(rkError, errorOffset+14, "error drawing screen", "")      // some sort of error when drawing screen buffer
+	rcGoldenMismatch   = newReturnCode(rkError, errorOffset+15, "golden output mismatch", "")    // classification differs from a fixture's golden output
+	rcScanErrors       = newReturnCode(rkError, errorOffset+16, "scan errors present", "")       // some library had errors while loading or scanning
+	rcPartialSuccess   = newReturnCode(rkWarn, errorOffset+17, "partial success", "")            // some libraries could not be opened, the others were handled
+	rcLockHeld         = newReturnCode(rkError, errorOffset+18, "database in use", "")           // library database is held open by another process
+	rcNoNetwork        = newReturnCode(rkWarn, errorOffset+19, "network unavailable", "")        // a network service could not be reached
+	rcDoctorIssues     = newReturnCode(rkWarn, errorOffset+20, "environment issues", "")         // command "doctor" found issues with the environment
+	rcChecksumMismatch = newReturnCode(rkWarn, errorOffset+21, "checksum mismatch", "")          // a file differs from the checksum listed by a checksum file
+	rcInvalidMedia     = newReturnCode(rkWarn, errorOffset+22, "invalid media content", "")      // a media file's content is not of the format of its extension
+	rcInterrupted      = newReturnCode(rkWarn, errorOffset+23, "interrupted", "")                // a load or scan was interrupted before it finished
 	rcUnknown          = newReturnCode(rkError, maxReturnCode, "unknown error", "")              // unanticipated error encountered
 )
 
 // function newReturnCode() constructs a new ReturnCode object with a specified
 // return code, description, and info.
 func newReturnCode(kind ReturnCodeKind, code int, desc string, info string) *ReturnCode {
-	return &ReturnCode{kind, code, desc, info}
+	return &ReturnCode{kind: kind, code: code, desc: desc, info: info}
 }
 
-// function spec() replaces the info string of an existing ReturnCode object
-// with the specified string and returns the updated ReturnCode object. the
-// existing return code and description fields are left unchanged.
-func (c *ReturnCode) spec(info string) *ReturnCode {
-	c.info = info
+// function origin() returns the general purpose return code from which the
+// receiver was derived, or the receiver itself if it is one.
+func (c *ReturnCode) origin() *ReturnCode {
+	if nil != c.base {
+		return c.base
+	}
 	return c
 }
 
+// function derive() returns a copy of the receiver, which may be modified
+// without affecting the receiver. the copy is derived from the same general
+// purpose return code as the receiver (see function origin()).
+func (c *ReturnCode) derive() *ReturnCode {
+	d := *c
+	d.base = c.origin()
+	return &d
+}
+
+// function spec() derives a new ReturnCode object with the specified info
+// string. the return code and description fields are those of the receiver.
+func (c *ReturnCode) spec(info string) *ReturnCode {
+	d := c.derive()
+	d.info = info
+	return d
+}
+
 // function specf() is a wrapper for function spec() that constructs the
 // string using the specified printf-style format strings + arguments.
 func (c *ReturnCode) specf(format string, v ...interface{}) *ReturnCode {
@@ -93,8 +135,98 @@ func (c *ReturnCode) specf(format string, v ...interface{}) *ReturnCode {
 // function kspecf() is a wrapper for function specf() that changes the kind of
 // ReturnCode from the default.
 func (c *ReturnCode) kspecf(kind ReturnCodeKind, format string, v ...interface{}) *ReturnCode {
-	c.kind = kind
-	return c.specf(format, v...)
+	d := c.specf(format, v...)
+	d.kind = kind
+	return d
+}
+
+// function wrap() is a wrapper for function specf() that also records the
+// given underlying error, which is appended to the info string (or is the info
+// string, if the format string is empty). the error is returned by function
+// Unwrap(), so that it may be matched with errors.Is() and errors.As().
+func (c *ReturnCode) wrap(err error, format string, v ...interface{}) *ReturnCode {
+	d := c.specf(format, v...)
+	if nil != err {
+		if "" == d.info {
+			d.info = err.Error()
+		} else {
+			d.info = fmt.Sprintf("%s: %s", d.info, err)
+		}
+		d.err = err
+	}
+	return d
+}
+
+// function at() records the operation, library name, and path associated
+// with the return event. any empty argument leaves the corresponding field
+// unchanged.
+func (c *ReturnCode) at(op, library, path string) *ReturnCode {
+	d := c.derive()
+	if "" != op {
+		d.op = op
+	}
+	if "" != library {
+		d.library = library
+	}
+	if "" != path {
+		d.path = path
+	}
+	return d
+}
+
+// function Unwrap() returns the underlying error, if any.
+func (c *ReturnCode) Unwrap() error {
+	return c.err
+}
+
+// function Is() returns true if the target is a ReturnCode derived from the
+// same general purpose return code as the receiver.
+func (c *ReturnCode) Is(target error) bool {
+	t, ok := target.(*ReturnCode)
+	return ok && nil != t && c.origin() == t.origin()
+}
+
+// function is() returns true if the receiver was derived from any of the given
+// general purpose return codes. it is safe to call on a nil receiver.
+func (c *ReturnCode) is(rc ...*ReturnCode) bool {
+	if nil == c {
+		return false
+	}
+	for _, r := range rc {
+		if errors.Is(c, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// function returnCodeOf() returns the ReturnCode wrapped by the given error, or
+// rcUnknown if it doesn't wrap one. returns nil if the error is nil.
+func returnCodeOf(err error) *ReturnCode {
+	if nil == err {
+		return nil
+	}
+	var c *ReturnCode
+	if errors.As(err, &c) {
+		return c
+	}
+	return rcUnknown.wrap(err, "unrecognized error")
+}
+
+// function fields() describes the operation, library, and path associated with
+// the return event, or an empty string if none were recorded.
+func (c *ReturnCode) fields() string {
+	f := []string{}
+	if "" != c.op {
+		f = append(f, fmt.Sprintf("op=%s", c.op))
+	}
+	if "" != c.library {
+		f = append(f, fmt.Sprintf("library=%q", c.library))
+	}
+	if "" != c.path {
+		f = append(f, fmt.Sprintf("path=%q", c.path))
+	}
+	return strings.Join(f, " ")
 }
 
 // function Error() constructs an error message using the current fields of a
@@ -118,3 +250,80 @@ func (c *ReturnCode) Error() string {
 	}
 	return s
 }
+
+// type ReturnCodeHelp describes when a general purpose return code is used as
+// the program's exit status, for the "errors" command.
+type ReturnCodeHelp struct {
+	code *ReturnCode
+	help string
+}
+
+// function returnCodeTable() lists every general purpose return code, in order
+// of exit status. scripts wrapping the program can rely on these values never
+// changing.
+func returnCodeTable() []*ReturnCodeHelp {
+	return []*ReturnCodeHelp{
+		{rcOK, "no errors"},
+		{rcUsage, "usage or version information was requested"},
+		{rcInvalidArgs, "invalid command line arguments or subcommand arguments"},
+		{rcInvalidLibrary, "a library path is not a readable directory"},
+		{rcLibraryBusy, "a library is busy with some other task"},
+		{rcInvalidPath, "a path could not be resolved or is not a directory"},
+		{rcInvalidStat, "a file's status could not be read"},
+		{rcDirDepth, "the directory traversal depth limit was exceeded"},
+		{rcDirOpen, "a directory could not be opened for reading"},
+		{rcInvalidFile, "a file could not be created, read, or written"},
+		{rcInvalidConfig, "the configuration is invalid, or no valid libraries were given"},
+		{rcInvalidDatabase, "a library database could not be created or opened"},
+		{rcDatabaseError, "an operation on a library database failed"},
+		{rcDuplicateLibrary, "a library path was given more than once"},
+		{rcInvalidJSONData, "some JSON data could not be encoded or decoded"},
+		{rcQueryError, "a library database could not be queried"},
+		{rcTUIError, "the screen could not be drawn"},
+		{rcGoldenMismatch, "a fixture's classification differs from its golden output"},
+		{rcScanErrors, "some library had errors while loading or scanning"},
+		{rcPartialSuccess, "some libraries could not be opened, but the others were loaded and scanned without errors"},
+		{rcLockHeld, "a library database is in use by another running process"},
+		{rcNoNetwork, "a network service could not be reached"},
+		{rcDoctorIssues, "command \"doctor\" found issues with the environment"},
+		{rcChecksumMismatch, "a file differs from the checksum listed for it by a checksum file (.sfv, .md5, etc.)"},
+		{rcInvalidMedia, "a media file does not begin with the header of the format of its file name extension"},
+		{rcInterrupted, "a load or scan was interrupted (by the user or on exit) before it finished"},
+		{rcUnknown, "an unanticipated error"},
+	}
+}
+
+// function errorsCommand() implements the "errors" command, which prints the
+// table of exit status codes, or only the entry of the given code.
+func errorsCommand(options *Options, args []string) *ReturnCode {
+
+	if len(args) > 1 {
+		return rcInvalidArgs.spec("errors: expected [CODE]")
+	}
+
+	table := returnCodeTable()
+	if 1 == len(args) {
+		code, err := strconv.Atoi(args[0])
+		if nil != err {
+			return rcInvalidArgs.specf("errors: invalid code: %q", args[0])
+		}
+		found := []*ReturnCodeHelp{}
+		for _, h := range table {
+			if code == h.code.code {
+				found = append(found, h)
+			}
+		}
+		if 0 == len(found) {
+			return rcInvalidArgs.specf("errors: unknown code: %d", code)
+		}
+		table = found
+	}
+
+	kind := map[ReturnCodeKind]string{rkInfo: "info", rkWarn: "warning", rkError: "error"}
+	row := "  %4v  %-7s  %-27s  %s"
+	rawLog.logf(row, "CODE", "KIND", "DESCRIPTION", "MEANING")
+	for _, h := range table {
+		rawLog.logf(row, h.code.code, kind[h.code.kind], h.code.desc, h.help)
+	}
+	return nil
+}
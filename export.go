@@ -0,0 +1,226 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: export.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "export" command, which writes the metadata of a library's
+//    media in a form another media frontend can consume. the only supported
+//    format is Kodi's: an .nfo file for each video (a movie, or an episode if
+//    its name identifies one), along with its poster and thumbnail from the
+//    artwork cache, named as Kodi expects. the files are written next to the
+//    videos, or into a parallel tree rooted at a given directory so that the
+//    library itself is left untouched. audio is not exported, since Kodi
+//    reads music metadata from the files' own tags.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for the export command.
+const (
+	kodiNFOExt     = ".nfo"
+	kodiDateFormat = "2006-01-02"
+)
+
+var (
+	// variable kodiArtworkSuffix maps the ArtworkKind enum values to the suffix
+	// of the file name base Kodi expects for images of that kind (empty if not
+	// exported).
+	kodiArtworkSuffix = [akCOUNT]string{
+		"-thumb", "-poster", "",
+	}
+)
+
+// type KodiNFO is the contents of a Kodi .nfo file of a movie (root element
+// "movie") or an episode (root element "episodedetails").
+type KodiNFO struct {
+	XMLName   xml.Name
+	Title     string   `xml:"title"`
+	ShowTitle string   `xml:"showtitle,omitempty"`
+	Season    int      `xml:"season,omitempty"`
+	Episode   int      `xml:"episode,omitempty"`
+	Plot      string   `xml:"plot,omitempty"`
+	Premiered string   `xml:"premiered,omitempty"`
+	Aired     string   `xml:"aired,omitempty"`
+	Tag       []string `xml:"tag"`
+	Notes     string   `xml:"notes,omitempty"` // not read by Kodi, kept for reference
+}
+
+// function newKodiNFO() constructs the Kodi .nfo contents of the given video.
+func newKodiNFO(video *VideoMedia) *KodiNFO {
+
+	nfo := &KodiNFO{
+		XMLName: xml.Name{Local: "movie"},
+		Title:   video.Title,
+		Tag:     video.Tags,
+		Notes:   video.Notes,
+	}
+	if "" != strings.Trim(video.Description, "- ") {
+		nfo.Plot = video.Description
+	}
+	released := ""
+	if !video.ReleaseDate.IsZero() {
+		released = video.ReleaseDate.Format(kodiDateFormat)
+	}
+	if series, _, ok := seriesOf(video.AbsBase); ok {
+		season, episode, _ := parseEpisode(video.AbsBase)
+		nfo.XMLName.Local = "episodedetails"
+		nfo.ShowTitle = strings.Title(series)
+		nfo.Season, nfo.Episode = season, episode
+		nfo.Aired = released
+	} else {
+		nfo.Premiered = released
+	}
+	return nfo
+}
+
+// function writeKodiFile() writes the given data to the given path, creating
+// its directory if needed. an existing file is only replaced if forced.
+// returns true if the file was written.
+func writeKodiFile(path string, data []byte, force bool) (bool, *ReturnCode) {
+
+	if exists, _ := goutil.PathExists(path); exists && !force {
+		infoLog.verbosef("export: not replacing existing file: %q", path)
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); nil != err {
+		return false, rcInvalidPath.specf("export(%q): os.MkdirAll(): %s", path, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); nil != err {
+		return false, rcInvalidFile.specf("export(%q): ioutil.WriteFile(): %s", path, err)
+	}
+	return true, nil
+}
+
+// function exportKodi() writes the .nfo file and artwork of the given video
+// into the given directory. returns the number of files written.
+func exportKodi(cache *ArtworkCache, video *VideoMedia, dir string, force bool) (int, *ReturnCode) {
+
+	data, err := xml.MarshalIndent(newKodiNFO(video), "", "  ")
+	if nil != err {
+		return 0, rcInvalidArgs.specf("export(%q): xml.MarshalIndent(): %s", video.AbsPath, err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+
+	count := 0
+	base := filepath.Join(dir, video.AbsBase)
+	if ok, ret := writeKodiFile(base+kodiNFOExt, data, force); nil != ret {
+		return count, ret
+	} else if ok {
+		count++
+	}
+	for kind, suffix := range kodiArtworkSuffix {
+		if "" == suffix {
+			continue
+		}
+		src, ok := cache.find(ArtworkKind(kind), video.AbsPath)
+		if !ok {
+			continue
+		}
+		img, err := ioutil.ReadFile(src)
+		if nil != err {
+			warnLog.tracef("export: cannot read cached artwork: %s", err)
+			continue
+		}
+		if ok, ret := writeKodiFile(base+suffix+filepath.Ext(src), img, force); nil != ret {
+			return count, ret
+		} else if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// function exportCommand() implements the "export" command, writing the Kodi
+// metadata of every video in the given library next to each video, or into the
+// given directory in the same layout as the library. existing files are only
+// replaced if -f (or --force) is given.
+func exportCommand(options *Options, args []string) *ReturnCode {
+
+	force, pos := false, []string{}
+	for _, arg := range args {
+		switch arg {
+		case "-f", "-force", "--force":
+			force = true
+		default:
+			pos = append(pos, arg)
+		}
+	}
+	if len(pos) < 2 || len(pos) > 3 {
+		return rcInvalidArgs.spec("export: expected kodi LIBRARY [DIR] [-f|--force]")
+	}
+	if "kodi" != strings.ToLower(pos[0]) {
+		return rcInvalidArgs.specf("export: unknown format: %q (expected kodi)", pos[0])
+	}
+	abs, err := libraryPath(pos[1])
+	if nil != err {
+		return rcInvalidLibrary.specf("export(%q): libraryPath(): %s", pos[1], err)
+	}
+	root := ""
+	if 3 == len(pos) {
+		if root, err = libraryPath(pos[2]); nil != err {
+			return rcInvalidPath.specf("export(%q): libraryPath(): %s", pos[2], err)
+		}
+	}
+
+	_, _, path := libraryDatabasePath(options, abs)
+	if exists, _ := goutil.PathExists(path); !exists {
+		return rcInvalidLibrary.specf("export(%q): library has never been scanned", abs)
+	}
+	if ret := lockDatabase(path); nil != ret {
+		return ret
+	}
+	defer unlockDatabase(path)
+
+	cache := newArtworkCache(options)
+	store, err := openStore(path, storeEngine(path))
+	if nil != err {
+		return rcDatabaseError.specf("export(%q): openStore(%q): %s", abs, path, err)
+	}
+	defer store.Close()
+
+	name := entityColName[ecMedia][mkVideo]
+	if !store.ColExists(name) {
+		rawLog.logf("%s: no videos to export", abs)
+		return nil
+	}
+	video := []*VideoMedia{}
+	store.Use(name).ForEachDoc(
+		func(id int, data []byte) (willMoveOn bool) {
+			v := &VideoMedia{}
+			if ret := v.fromRecord(data); nil != ret {
+				warnLog.tracef("export(%q): skipping corrupt record %d: %s", name, id, ret)
+				return true
+			}
+			video = append(video, v)
+			return true
+		})
+
+	files := 0
+	for _, v := range video {
+		dir := v.AbsDir
+		if "" != root {
+			dir = filepath.Join(root, filepath.Dir(v.RelPath))
+		}
+		count, ret := exportKodi(cache, v, dir, force)
+		files += count
+		if nil != ret {
+			return ret
+		}
+	}
+	rawLog.logf("%s: exported %d videos, %d files written", abs, len(video), files)
+	return nil
+}
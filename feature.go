@@ -0,0 +1,122 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: feature.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the feature flags, which gate optional subsystems so that they
+//    may ship incrementally in every build: experimental subsystems are
+//    disabled until the user opts in with -enable, and established ones may
+//    be turned off with -disable.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// type Feature is an enum identifying the subsystems gated by feature flags.
+type Feature int
+
+const (
+	ffUnknown  Feature = iota - 1 // = -1
+	ffPoll                        // = 0
+	ffSuspend                     // = 1
+	ffTargets                     // = 2
+	ffSpectrum                    // = 3
+	ffCOUNT                       // = 4
+)
+
+// type FeatureFlag describes a subsystem gated by a feature flag.
+type FeatureFlag struct {
+	name         string
+	experimental bool // disabled unless enabled by the user
+	desc         string
+}
+
+var (
+	// variable featureFlag maps the Feature enum values to their description.
+	featureFlag = [ffCOUNT]FeatureFlag{
+		{"poll", false, "polling watchers (see -poll)"},
+		{"suspend", false, "revalidating libraries on resume from system suspend"},
+		{"targets", true, "remote playback targets (see -target)"},
+		{"spectrum", true, "audio spectrum pane (see -spectrum)"},
+	}
+
+	// variable featureEnabled indicates whether each feature is enabled.
+	featureEnabled [ffCOUNT]bool
+)
+
+// function init() enables every feature that isn't experimental.
+func init() {
+	for f := range featureFlag {
+		featureEnabled[f] = !featureFlag[f].experimental
+	}
+}
+
+// function featureNames() returns a description of each feature flag for the
+// usage synopsis, noting which are experimental.
+func featureNames() string {
+	name := make([]string, ffCOUNT)
+	for f, ff := range featureFlag {
+		name[f] = ff.name
+		if ff.experimental {
+			name[f] += " (experimental)"
+		}
+	}
+	return strings.Join(name, ", ")
+}
+
+// function parseFeature() returns the feature with the given name.
+func parseFeature(name string) (Feature, error) {
+	for f, ff := range featureFlag {
+		if strings.EqualFold(ff.name, strings.TrimSpace(name)) {
+			return Feature(f), nil
+		}
+	}
+	return ffUnknown, fmt.Errorf("unknown feature %q (expected one of: %s)", name, featureNames())
+}
+
+// function setFeatures() enables and disables the features named by the given
+// options, which must have already been validated. disabling a feature takes
+// precedence over enabling it.
+func setFeatures(opt *Options) {
+	for _, name := range opt.FeatureEnable.StringList {
+		if f, err := parseFeature(name); nil == err {
+			featureEnabled[f] = true
+		}
+	}
+	for _, name := range opt.FeatureDisable.StringList {
+		if f, err := parseFeature(name); nil == err {
+			featureEnabled[f] = false
+		}
+	}
+	for f, ff := range featureFlag {
+		if featureEnabled[f] && ff.experimental {
+			infoLog.verbosef("experimental feature enabled: %s", ff.desc)
+		}
+	}
+}
+
+// function isEnabled() returns true if the feature is enabled.
+func (f Feature) isEnabled() bool {
+	return featureEnabled[f]
+}
+
+// function gate() returns true if the feature is enabled. otherwise, it warns
+// that the given option is ignored (if it was provided) and returns false.
+func (f Feature) gate(opt *Options, o *Option) bool {
+	if featureEnabled[f] {
+		return true
+	}
+	if opt.isProvided(o.name) {
+		warnLog.logf("(ignored) option -%s requires feature %q (see -%s)",
+			o.name, featureFlag[f].name, opt.FeatureEnable.name)
+	}
+	return false
+}
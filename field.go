@@ -0,0 +1,563 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: field.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines types and functions for user-defined metadata fields, which are
+//    declared per library, stored in the media records alongside the built-in
+//    fields, and indexed for searching. the fields of the media selected in
+//    the media browser are edited with the field editor (opened with 'e'),
+//    media are searched by field as "NAME=VALUE", and a layout preset may sort
+//    the media browser by field ("sort=field:NAME").
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// type FieldType is an enum identifying the type of value stored in a
+// user-defined metadata field.
+type FieldType int
+
+const (
+	ftUnknown FieldType = iota - 1 // = -1
+	ftString                       // =  0
+	ftInt                          // =  1
+	ftFloat                        // =  2
+	ftBool                         // =  3
+	ftDate                         // =  4
+	ftCOUNT                        // =  5
+)
+
+var (
+	// variable fieldTypeName maps the FieldType enum values to the names used
+	// to declare them on the command line.
+	fieldTypeName = [ftCOUNT]string{
+		"string", // 0 = ftString
+		"int",    // 1 = ftInt
+		"float",  // 2 = ftFloat
+		"bool",   // 3 = ftBool
+		"date",   // 4 = ftDate
+	}
+)
+
+// local unexported constants for user-defined metadata fields.
+const (
+	// name of the record field (in all media records) containing the map of
+	// user-defined field names to their values.
+	customFieldRecordKey = "Fields"
+
+	// layout used to parse and format values of fields with type ftDate.
+	customFieldDateLayout = "2006-01-02"
+
+	// width of each input field in the field editor.
+	fieldEditorWidth = 40
+)
+
+// type CustomField describes a user-defined metadata field declared with the
+// command line option "-field NAME:TYPE[@LIBRARY]".
+type CustomField struct {
+	Name  string        // key of the field in each media record's Fields map
+	Type  FieldType     // type of value stored in the field
+	Scope *LibraryScope // only library using this field (empty = all)
+}
+
+// function parseCustomField() parses a field declaration of the form
+// "NAME:TYPE[@LIBRARY]", where TYPE is one of the names in fieldTypeName.
+func parseCustomField(spec string) (*CustomField, error) {
+
+	decl, scope := parseLibraryScope(spec)
+	field := &CustomField{Scope: scope}
+
+	part := strings.SplitN(decl, ":", 2)
+	if len(part) != 2 {
+		return nil, fmt.Errorf("field %q: expected NAME:TYPE[@LIBRARY]", spec)
+	}
+
+	field.Name = strings.TrimSpace(part[0])
+	if "" == field.Name || strings.ContainsAny(field.Name, ".@:") {
+		return nil, fmt.Errorf("field %q: invalid name: %q", spec, field.Name)
+	}
+
+	field.Type = ftUnknown
+	typeName := strings.ToLower(strings.TrimSpace(part[1]))
+	for t, name := range fieldTypeName {
+		if name == typeName {
+			field.Type = FieldType(t)
+			break
+		}
+	}
+	if ftUnknown == field.Type {
+		return nil, fmt.Errorf("field %q: unknown type: %q (expected one of: %s)",
+			spec, typeName, strings.Join(fieldTypeName[:], ", "))
+	}
+
+	return field, nil
+}
+
+// function String() creates a string representation of the CustomField in the
+// same form used to declare it.
+func (f *CustomField) String() string {
+	s := fmt.Sprintf("%s:%s", f.Name, fieldTypeName[f.Type])
+	if "" != f.Scope.library {
+		s = fmt.Sprintf("%s@%s", s, f.Scope)
+	}
+	return s
+}
+
+// function appliesTo() returns true if this field was declared for the given
+// library, either by name or by path, or if it was declared for all libraries.
+func (f *CustomField) appliesTo(lib *Library) bool {
+	return f.Scope.appliesTo(lib)
+}
+
+// function index() returns the database index path of this field.
+func (f *CustomField) index() EntityIndex {
+	return EntityIndex{customFieldRecordKey, f.Name}
+}
+
+// function parse() converts the given string into a value of this field's
+// type, suitable for storing in a media record.
+func (f *CustomField) parse(raw string) (interface{}, *ReturnCode) {
+
+	raw = strings.TrimSpace(raw)
+
+	var (
+		val interface{}
+		err error
+	)
+	switch f.Type {
+	case ftString:
+		val = raw
+	case ftInt:
+		val, err = strconv.ParseInt(raw, 10, 64)
+	case ftFloat:
+		val, err = strconv.ParseFloat(raw, 64)
+	case ftBool:
+		val, err = strconv.ParseBool(raw)
+	case ftDate:
+		var date time.Time
+		if date, err = time.Parse(customFieldDateLayout, raw); nil == err {
+			// store dates in their textual form so that they sort and compare
+			// the same way in the database as they do on screen.
+			val = date.Format(customFieldDateLayout)
+		}
+	default:
+		err = fmt.Errorf("unknown type")
+	}
+	if nil != err {
+		return nil, rcInvalidArgs.specf(
+			"parse(%s, %q): cannot convert to %s: %s", f, raw, fieldTypeName[f.Type], err)
+	}
+	return val, nil
+}
+
+// function customField() returns the user-defined metadata field with the
+// given name declared for this library, or nil if there is no such field.
+func (l *Library) customField(name string) *CustomField {
+	for _, f := range l.fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// function setField() sets the value of the user-defined metadata field with
+// the given name, converting the given string to the field's declared type. an
+// empty string removes the field from the media. the caller is responsible for
+// updating the media's database record.
+func (m *Media) setField(lib *Library, name, raw string) *ReturnCode {
+
+	field := lib.customField(name)
+	if nil == field {
+		return rcInvalidArgs.specf(
+			"setField(%q): no such field declared for library: %q", name, lib.name)
+	}
+
+	if nil == m.Fields {
+		m.Fields = map[string]interface{}{}
+	}
+	if "" == strings.TrimSpace(raw) {
+		delete(m.Fields, name)
+		return nil
+	}
+
+	val, ret := field.parse(raw)
+	if nil != ret {
+		return ret
+	}
+	m.Fields[name] = val
+	return nil
+}
+
+// function findByField() searches the given kind of media in this library for
+// records whose user-defined metadata field with the given name is equal to
+// the given value, returning the record IDs of all matches.
+func (l *Library) findByField(kind MediaKind, name, raw string) ([]int, *ReturnCode) {
+
+	field := l.customField(name)
+	if nil == field {
+		return nil, rcInvalidArgs.specf(
+			"findByField(%q): no such field declared for library: %q", name, l.name)
+	}
+	val, ret := field.parse(raw)
+	if nil != ret {
+		return nil, ret
+	}
+	// numbers are read back from the records as float64, and the index is
+	// matched by their textual form, so integers must be given the same way.
+	if i, ok := val.(int64); ok {
+		val = float64(i)
+	}
+
+	result := make(map[int]struct{})
+	if ret := l.db.query(ecMedia, int(kind), field.index(), val, &result); nil != ret {
+		return nil, ret
+	}
+
+	found := []int{}
+	for id := range result {
+		found = append(found, id)
+	}
+	return found, nil
+}
+
+// function fieldText() formats the given value of a user-defined field as it
+// would be typed to set it.
+func fieldText(val interface{}) string {
+	if f, ok := val.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(val)
+}
+
+// function fieldSortKey() returns a string by which values of user-defined
+// fields sort in their natural order: numbers by value, and everything else
+// case-insensitively by its text (dates are stored as "YYYY-MM-DD").
+func fieldSortKey(val interface{}) string {
+	var num float64
+	switch v := val.(type) {
+	case float64:
+		num = v
+	case int64:
+		num = float64(v)
+	default:
+		return strings.ToUpper(fieldText(val))
+	}
+	// the bits of a float64 sort in the order of their values once the sign
+	// bit of positive numbers is set, and all bits of negative numbers flipped.
+	bits := math.Float64bits(num)
+	if 0 != bits&(1<<63) {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return fmt.Sprintf("%016x", bits)
+}
+
+// function sortedFieldNames() returns the names of the given media's
+// user-defined fields which have a value, in sorted order.
+func (m *Media) sortedFieldNames() []string {
+	name := []string{}
+	for n := range m.Fields {
+		name = append(name, n)
+	}
+	sort.Strings(name)
+	return name
+}
+
+// type FieldQuery is a search for the media whose user-defined field with the
+// given name equals the given value, typed as "NAME=VALUE".
+type FieldQuery struct {
+	name string
+	raw  string
+}
+
+// function parseFieldQuery() parses a search query of the form "NAME=VALUE",
+// where NAME is a user-defined field declared for any of the given libraries.
+// returns nil if the query is not of that form, so that it is searched as
+// text instead.
+func parseFieldQuery(query string, lib []*Library) *FieldQuery {
+	i := strings.Index(query, "=")
+	if i <= 0 {
+		return nil
+	}
+	name := strings.TrimSpace(query[:i])
+	for _, l := range lib {
+		if nil != l && nil != l.customField(name) {
+			return &FieldQuery{name: name, raw: query[i+1:]}
+		}
+	}
+	return nil
+}
+
+// function find() returns the record IDs of the media in the given library
+// matching the query, by kind of media. the library is not searched if the
+// field isn't declared for it, or if the value can't be converted to the
+// field's type (e.g. while it is being typed).
+func (q *FieldQuery) find(lib *Library) [mkCOUNT]map[int]bool {
+	found := [mkCOUNT]map[int]bool{}
+	for k := range found {
+		found[k] = map[int]bool{}
+		if nil == lib.customField(q.name) {
+			continue
+		}
+		id, ret := lib.findByField(MediaKind(k), q.name, q.raw)
+		if nil != ret {
+			infoLog.tracef("search %s=%q: %s", q.name, q.raw, ret)
+			continue
+		}
+		for _, i := range id {
+			found[k][i] = true
+		}
+	}
+	return found
+}
+
+// function matches() returns true if the given media of the given library
+// matches the query. used for media discovered after the query was searched.
+func (q *FieldQuery) matches(lib *Library, media *Media) bool {
+	field := lib.customField(q.name)
+	if nil == field {
+		return false
+	}
+	val, ret := field.parse(q.raw)
+	if nil != ret {
+		return false
+	}
+	curr, ok := media.Fields[q.name]
+	return ok && fieldSortKey(curr) == fieldSortKey(val)
+}
+
+// function applyFieldSearch() lists only the items whose user-defined field
+// matches the given query, in sorted order.
+func (l *Browser) applyFieldSearch(library *Library, text string, query *FieldQuery) {
+
+	if nil != l.search {
+		library = l.search.library
+	}
+	l.search = &BrowserSearch{query: text, library: library, field: query, match: map[*Media]*SearchMatch{}}
+
+	found := map[*Library][mkCOUNT]map[int]bool{}
+	all := append(append([]*mediaItem{}, l.visibleItem...), l.hiddenItem...)
+	visible, hidden := []*mediaItem{}, []*mediaItem{}
+	for _, m := range all {
+		record, known := l.record[m.Media]
+		match := known && nil == m.CollapsedIn && nil != m.SourceLibrary &&
+			(nil == library || m.SourceLibrary == library) &&
+			m.Kind >= 0 && m.Kind < mkCOUNT
+		if match {
+			id, ok := found[m.SourceLibrary]
+			if !ok {
+				id = query.find(m.SourceLibrary)
+				found[m.SourceLibrary] = id
+			}
+			match = id[m.Kind][record.id]
+		}
+		if match {
+			visible = append(visible, m)
+		} else {
+			hidden = append(hidden, m)
+		}
+	}
+	l.sortItems(visible)
+	l.visibleItem, l.hiddenItem = visible, hidden
+	l.currentItem, l.viewOffset = 0, 0
+	if len(l.visibleItem) > 0 && nil != l.changed {
+		it := l.visibleItem[0]
+		l.changed(0, it.MainText, it.SecondaryText)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// type FieldEditorView is the dialog used to edit the user-defined fields of
+// the media item currently selected in the media browser. each field declared
+// for the item's library is edited in its own input field, and clearing it
+// removes the field from the media.
+type FieldEditorView struct {
+	*tview.Form
+	item      *mediaItem
+	record    *RecordID
+	field     []*CustomField
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// function newFieldEditorView() allocates and initializes the dialog widgets.
+func newFieldEditorView(ui *tview.Application, page string, lib []*Library) *FieldEditorView {
+
+	v := FieldEditorView{
+		Form:      nil,
+		item:      nil,
+		record:    nil,
+		field:     nil,
+		layout:    nil,
+		focusPage: page,
+		focusNext: nil,
+		focusPrev: nil,
+	}
+
+	form := tview.NewForm().
+		SetLabelColor(colorScheme.inactiveMenuText).
+		SetFieldTextColor(colorScheme.inactiveMenuText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	form.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.Form = form
+
+	return &v
+}
+
+func (v *FieldEditorView) desc() string { return "" }
+func (v *FieldEditorView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *FieldEditorView) page() string         { return v.focusPage }
+func (v *FieldEditorView) next() FocusDelegator { return v.focusNext }
+func (v *FieldEditorView) prev() FocusDelegator { return v.focusPrev }
+func (v *FieldEditorView) focus() {
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.Form)
+}
+func (v *FieldEditorView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function setMedia() populates the dialog with the current values of the
+// fields declared for the library of the given media item.
+func (v *FieldEditorView) setMedia(item *mediaItem, record *RecordID) {
+
+	v.item = item
+	v.record = record
+	v.field = item.SourceLibrary.fields
+
+	v.Clear(true)
+	for _, f := range v.field {
+		text := ""
+		if val, ok := item.Fields[f.Name]; ok {
+			text = fieldText(val)
+		}
+		v.AddInputField(fmt.Sprintf("%s (%s):", f.Name, fieldTypeName[f.Type]),
+			text, fieldEditorWidth, nil, nil)
+	}
+	v.AddButton("Save", v.save).
+		AddButton("Cancel", v.cancel).
+		SetFocus(0)
+
+	v.SetTitle(fmt.Sprintf(" Fields: [#%06x]%s ",
+		colorScheme.highlightPrimary.Hex(), item.Name))
+}
+
+// function save() stores the dialog's field values in the media item's
+// database record. nothing is changed if any value can't be converted to the
+// type of its field, and the dialog remains open to correct it.
+func (v *FieldEditorView) save() {
+
+	entity, ok := v.record.rec.(StorableEntity)
+	if !ok {
+		warnLog.logf("(ignored) media is not storable: %s", v.item.AbsName)
+		v.layout.focusQueue <- v.layout.focusBase
+		return
+	}
+
+	prev := map[string]interface{}{}
+	for n, val := range v.item.Fields {
+		prev[n] = val
+	}
+	for i, f := range v.field {
+		input, ok := v.GetFormItem(i).(*tview.InputField)
+		if !ok {
+			continue
+		}
+		if ret := v.item.setField(v.item.SourceLibrary, f.Name, input.GetText()); nil != ret {
+			v.item.Fields = prev
+			warnLog.log(ret)
+			return
+		}
+	}
+
+	rec, ret := entity.toRecord()
+	if nil == ret {
+		col := v.item.SourceLibrary.db.col[ecMedia][v.item.Kind]
+		if err := col.Update(v.record.id, *rec); nil != err {
+			ret = rcDatabaseError.specf(
+				"save(%q, %d): failed to update record: %s", v.item.AbsName, v.record.id, err)
+		}
+	}
+	if nil != ret {
+		v.item.Fields = prev
+		warnLog.log(ret)
+	} else {
+		infoLog.logf("saved fields: %s", v.item.Name)
+		// keep the media browser sorted if it is sorted by a field.
+		browser := v.layout.browseView.Browser
+		if bsField == browser.sortOrder && !browser.isSearching() {
+			browser.sortItems(browser.visibleItem)
+			browser.selectPath(v.item.SourceLibrary, v.item.AbsPath)
+		}
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function cancel() closes the dialog without modifying anything.
+func (v *FieldEditorView) cancel() {
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function fieldEvent() handles the key opening the field editor for the media
+// item currently selected in the media browser. returns true if the key was
+// handled.
+func (l *Layout) fieldEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'e' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("edit fields"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item {
+		warnLog.logf("(ignored) no item selected")
+		return true
+	}
+	if nil == item.SourceLibrary || 0 == len(item.SourceLibrary.fields) {
+		warnLog.logf("(ignored) no fields are declared for the library of %s (see option -field)", item.Name)
+		return true
+	}
+	record, ok := l.browseView.record[item.Media]
+	if !ok {
+		warnLog.logf("(ignored) database record unknown: %s", item.AbsName)
+		return true
+	}
+	l.fieldEditor.setMedia(item, record)
+	l.focusQueue <- l.fieldEditor
+	return true
+}
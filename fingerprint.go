@@ -0,0 +1,322 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: fingerprint.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "identify" command, which identifies the audio of a library
+//    by its acoustic fingerprint, so that untagged or mis-tagged files get the
+//    correct title, artist, and album. each file is fingerprinted with
+//    Chromaprint's fpcalc, and the fingerprint is looked up with the AcoustID
+//    web service (which requires an API key, see option -acoustid). lookups
+//    are subject to the shared network rate limits, and their results (even
+//    when nothing matched) are cached in the data directory, so that a file is
+//    never looked up twice. audio already identified is skipped unless forced.
+//
+// =============================================================================
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for fingerprinting.
+const (
+	fpcalcCommand         = "fpcalc"
+	acoustIDLookupURL     = "https://api.acoustid.org/v2/lookup"
+	acoustIDCacheFileName = "acoustid.json"
+	acoustIDMinScore      = 0.8 // min score of a match trusted to replace the tags
+)
+
+// type AcoustIDMatch is the recording matching a fingerprint, as cached. an
+// empty ID means nothing matched.
+type AcoustIDMatch struct {
+	ID     string  `json:"id"`
+	Score  float64 `json:"score"`
+	Title  string  `json:"title"`
+	Artist string  `json:"artist"`
+	Album  string  `json:"album"`
+}
+
+// type AcoustIDResponse is the response of the AcoustID lookup web service.
+type AcoustIDResponse struct {
+	Status string
+	Error  struct{ Message string }
+	Result []struct {
+		ID         string
+		Score      float64
+		Recordings []struct {
+			Title   string
+			Artists []struct{ Name string }
+			// the release groups (e.g. albums) on which the recording appears.
+			ReleaseGroups []struct{ Title, Type string } `json:"releasegroups"`
+		}
+	} `json:"results"`
+}
+
+// type AcoustIDCache is the cache of AcoustID lookups of a data directory,
+// keyed by fingerprint and duration.
+type AcoustIDCache struct {
+	path  string
+	match map[string]*AcoustIDMatch
+}
+
+// function loadAcoustIDCache() reads the AcoustID cache of the given data
+// directory, which is empty if it does not exist yet.
+func loadAcoustIDCache(dat string) (*AcoustIDCache, *ReturnCode) {
+
+	c := &AcoustIDCache{
+		path:  filepath.Join(dat, acoustIDCacheFileName),
+		match: map[string]*AcoustIDMatch{},
+	}
+	data, err := ioutil.ReadFile(c.path)
+	if nil != err {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, rcInvalidFile.specf("loadAcoustIDCache(%q): %s", c.path, err)
+	}
+	if err := json.Unmarshal(data, &c.match); nil != err {
+		return nil, rcInvalidJSONData.specf("loadAcoustIDCache(%q): %s", c.path, err)
+	}
+	return c, nil
+}
+
+// function save() writes the AcoustID cache, first to a temporary file so that
+// an interrupted write never corrupts it.
+func (c *AcoustIDCache) save() *ReturnCode {
+
+	data, err := json.MarshalIndent(c.match, "", "  ")
+	if nil != err {
+		return rcInvalidJSONData.specf("save(%q): %s", c.path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); nil != err {
+		return rcInvalidPath.specf("save(%q): os.MkdirAll(): %s", c.path, err)
+	}
+	tmp := c.path + cacheTempSuffix
+	if err := ioutil.WriteFile(tmp, data, 0644); nil != err {
+		os.Remove(tmp)
+		return rcInvalidFile.specf("save(%q): ioutil.WriteFile(): %s", c.path, err)
+	}
+	if err := os.Rename(tmp, c.path); nil != err {
+		os.Remove(tmp)
+		return rcInvalidFile.specf("save(%q): os.Rename(): %s", c.path, err)
+	}
+	return nil
+}
+
+// function acoustIDKey() returns the key of the given fingerprint and duration
+// in the AcoustID cache.
+func acoustIDKey(fingerprint string, duration int) string {
+	sum := sha1.Sum([]byte(fingerprint))
+	return fmt.Sprintf("%s:%d", hex.EncodeToString(sum[:]), duration)
+}
+
+// function fingerprint() computes the Chromaprint fingerprint of the given
+// audio file. returns the fingerprint and the duration in seconds.
+func fingerprint(path string) (string, int, *ReturnCode) {
+
+	out, err := exec.Command(fpcalcCommand, "-json", path).Output()
+	if nil != err {
+		return "", 0, rcInvalidFile.specf("fingerprint(%q): %s: %s", path, fpcalcCommand, err)
+	}
+	var fp struct {
+		Duration    float64
+		Fingerprint string
+	}
+	if err := json.Unmarshal(out, &fp); nil != err || "" == fp.Fingerprint {
+		return "", 0, rcInvalidJSONData.specf("fingerprint(%q): unexpected %s output: %s",
+			path, fpcalcCommand, strings.TrimSpace(string(out)))
+	}
+	return fp.Fingerprint, int(fp.Duration), nil
+}
+
+// function parseAcoustIDResponse() returns the best match of the given lookup
+// response, which has an empty ID if nothing matched.
+func parseAcoustIDResponse(data []byte) (*AcoustIDMatch, error) {
+
+	var resp AcoustIDResponse
+	if err := json.Unmarshal(data, &resp); nil != err {
+		return nil, err
+	}
+	if "ok" != resp.Status {
+		return nil, fmt.Errorf("lookup failed: %s", resp.Error.Message)
+	}
+	best := &AcoustIDMatch{}
+	for _, r := range resp.Result {
+		if r.Score <= best.Score || 0 == len(r.Recordings) {
+			continue
+		}
+		rec := r.Recordings[0]
+		best = &AcoustIDMatch{ID: r.ID, Score: r.Score, Title: rec.Title}
+		artist := []string{}
+		for _, a := range rec.Artists {
+			artist = append(artist, a.Name)
+		}
+		best.Artist = strings.Join(artist, ", ")
+		for _, g := range rec.ReleaseGroups {
+			if "" == best.Album || "Album" == g.Type {
+				best.Album = g.Title
+			}
+		}
+	}
+	return best, nil
+}
+
+// function lookupAcoustID() looks up the recording matching the given
+// fingerprint and duration with the AcoustID web service.
+func lookupAcoustID(key, fingerprint string, duration int) (*AcoustIDMatch, *ReturnCode) {
+
+	form := url.Values{
+		"client":      {key},
+		"meta":        {"recordings releasegroups"},
+		"duration":    {fmt.Sprint(duration)},
+		"fingerprint": {fingerprint},
+	}
+	req, err := http.NewRequest(http.MethodPost, acoustIDLookupURL, strings.NewReader(form.Encode()))
+	if nil != err {
+		return nil, rcInvalidArgs.specf("lookupAcoustID(): %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := netDo(req)
+	if nil != err {
+		return nil, returnCodeOf(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, rcNoNetwork.wrap(err, "lookupAcoustID()")
+	}
+	match, err := parseAcoustIDResponse(data)
+	if nil != err {
+		return nil, rcInvalidJSONData.specf("lookupAcoustID(): %s", err)
+	}
+	return match, nil
+}
+
+// function identifyCommand() implements the "identify" command, updating the
+// title, artist, and album of the audio in the given library from the
+// recordings matching their fingerprints. only confident matches are applied.
+func identifyCommand(options *Options, args []string) *ReturnCode {
+
+	force, pos := false, []string{}
+	for _, arg := range args {
+		switch arg {
+		case "-f", "-force", "--force":
+			force = true
+		default:
+			pos = append(pos, arg)
+		}
+	}
+	if 1 != len(pos) {
+		return rcInvalidArgs.spec("identify: expected LIBRARY [-f|--force]")
+	}
+	key := options.AcoustIDKey.string
+	if "" == key {
+		return rcInvalidArgs.specf("identify: no AcoustID API key given (see -%s)", options.AcoustIDKey.name)
+	}
+	if _, err := exec.LookPath(fpcalcCommand); nil != err {
+		return rcInvalidArgs.specf("identify: %s not found (install Chromaprint)", fpcalcCommand)
+	}
+	abs, err := libraryPath(pos[0])
+	if nil != err {
+		return rcInvalidLibrary.specf("identify(%q): libraryPath(): %s", pos[0], err)
+	}
+
+	_, _, path := libraryDatabasePath(options, abs)
+	if exists, _ := goutil.PathExists(path); !exists {
+		return rcInvalidLibrary.specf("identify(%q): library has never been scanned", abs)
+	}
+	if ret := lockDatabase(path); nil != ret {
+		return ret
+	}
+	defer unlockDatabase(path)
+
+	cache, ret := loadAcoustIDCache(options.LibData.string)
+	if nil != ret {
+		return ret
+	}
+	store, err := openStore(path, storeEngine(path))
+	if nil != err {
+		return rcDatabaseError.specf("identify(%q): openStore(%q): %s", abs, path, err)
+	}
+	defer store.Close()
+
+	name := entityColName[ecMedia][mkAudio]
+	if !store.ColExists(name) {
+		rawLog.logf("%s: no audio to identify", abs)
+		return nil
+	}
+	col := store.Use(name)
+	audio := map[int]*AudioMedia{}
+	col.ForEachDoc(
+		func(id int, data []byte) (willMoveOn bool) {
+			a := &AudioMedia{}
+			if ret := a.fromRecord(data); nil != ret {
+				warnLog.tracef("identify(%q): skipping corrupt record %d: %s", name, id, ret)
+			} else if force || "" == a.AcoustID {
+				audio[id] = a
+			}
+			return true
+		})
+
+	identified, failed := 0, 0
+	for id, a := range audio {
+		fp, duration, ret := fingerprint(a.AbsPath)
+		if nil != ret {
+			warnLog.verbose(ret)
+			failed++
+			continue
+		}
+		k := acoustIDKey(fp, duration)
+		match, cached := cache.match[k]
+		if !cached {
+			if match, ret = lookupAcoustID(key, fp, duration); nil != ret {
+				// keep whatever was looked up so far.
+				if r := cache.save(); nil != r {
+					warnLog.log(r)
+				}
+				return ret
+			}
+			cache.match[k] = match
+		}
+		if "" == match.ID || match.Score < acoustIDMinScore {
+			infoLog.verbosef("identify: no confident match: %q", a.AbsPath)
+			continue
+		}
+		a.AcoustID, a.Title = match.ID, match.Title
+		if "" != match.Artist {
+			a.Artist = match.Artist
+		}
+		if "" != match.Album {
+			a.Album = match.Album
+		}
+		if ret := a.updateRecord(col, id); nil != ret {
+			return ret
+		}
+		infoLog.verbosef("identify: %q is %q by %q", a.AbsPath, a.Title, a.Artist)
+		identified++
+	}
+	if ret := cache.save(); nil != ret {
+		return ret
+	}
+
+	rawLog.logf("%s: %d audio fingerprinted, %d identified, %d failed",
+		abs, len(audio)-failed, identified, failed)
+	return nil
+}
@@ -0,0 +1,521 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: fixture.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "fixture" and "golden" commands. the former generates a
+//    synthetic library tree with a configurable mix of audio, video, subtitles
+//    and junk files, along with a golden file recording how the scanner and
+//    the subtitles heuristics classify and associate each of them. the latter
+//    repeats the classification and reports every difference from the golden
+//    file, so that changes to the scanner or association logic can be checked
+//    for regressions against the same tree. the fixtures committed in
+//    testdata/fixture are checked this way by "go test" (see fixture_test.go).
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for the fixture generator.
+const (
+	fixtureLibraryDir     = "library"     // subdirectory of the fixture containing the library tree
+	fixtureGoldenFileName = "golden.json" // file in the fixture containing the golden output
+	fixtureDirPerms       = 0755
+	fixtureFilePerms      = 0644
+)
+
+var (
+	// variable fixtureTitleASCII and fixtureTitleUnicode are the titles from
+	// which the names of generated files and directories are composed.
+	fixtureTitleASCII = []string{
+		"Aurora", "Black Harbor", "Copper Hill", "Dead Reckoning", "Echo Park",
+		"Far Meadow", "Glass Tide", "Hollow Point", "Iron Bridge", "Juniper",
+	}
+	fixtureTitleUnicode = []string{
+		"Amélie", "Crème Brûlée", "Ærøskøbing", "Señor Niño", "Straße",
+		"Москва", "Ελλάδα", "千と千尋", "서울의 밤", "Ünïcödé ☂",
+	}
+
+	fixtureVideoExt = []string{".mkv", ".mp4", ".avi", ".m4v"}
+	fixtureAudioExt = []string{".mp3", ".flac", ".m4a", ".ogg"}
+	fixtureSubsExt  = []string{".srt", ".ass", ".sub", ".ssa"}
+	fixtureJunkExt  = []string{".nfo", ".txt", ".jpg", ".url", ""}
+)
+
+// type FixtureSpec describes the synthetic library tree generated by the
+// "fixture" command. the same spec always generates the same tree.
+type FixtureSpec struct {
+	Audio   uint  `json:"audio"`   // number of audio files (some are commentary tracks)
+	Video   uint  `json:"video"`   // number of video files
+	Subs    uint  `json:"subs"`    // number of subtitles files
+	Junk    uint  `json:"junk"`    // number of files of no known kind
+	Depth   uint  `json:"depth"`   // max directory nesting depth
+	Unicode bool  `json:"unicode"` // use non-ASCII titles for some names
+	Seed    int64 `json:"seed"`    // seed of the pseudo-random generator
+}
+
+// function newFixtureSpec() creates a FixtureSpec with the default values,
+// modified by the given KEY=VALUE arguments.
+func newFixtureSpec(args []string) (*FixtureSpec, *ReturnCode) {
+
+	spec := &FixtureSpec{
+		Audio:   8,
+		Video:   12,
+		Subs:    10,
+		Junk:    6,
+		Depth:   3,
+		Unicode: true,
+		Seed:    1,
+	}
+
+	count := map[string]*uint{
+		"audio": &spec.Audio,
+		"video": &spec.Video,
+		"subs":  &spec.Subs,
+		"junk":  &spec.Junk,
+		"depth": &spec.Depth,
+	}
+
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if 2 != len(kv) {
+			return nil, rcInvalidArgs.specf("fixture: expected KEY=VALUE: %q", arg)
+		}
+		key, val := strings.ToLower(kv[0]), kv[1]
+		if n, ok := count[key]; ok {
+			u, err := strconv.ParseUint(val, 10, 32)
+			if nil != err {
+				return nil, rcInvalidArgs.specf("fixture: invalid %s: %q", key, val)
+			}
+			*n = uint(u)
+			continue
+		}
+		switch key {
+		case "unicode":
+			b, err := strconv.ParseBool(val)
+			if nil != err {
+				return nil, rcInvalidArgs.specf("fixture: invalid %s: %q", key, val)
+			}
+			spec.Unicode = b
+		case "seed":
+			s, err := strconv.ParseInt(val, 10, 64)
+			if nil != err {
+				return nil, rcInvalidArgs.specf("fixture: invalid %s: %q", key, val)
+			}
+			spec.Seed = s
+		default:
+			return nil, rcInvalidArgs.specf("fixture: unknown key: %q", key)
+		}
+	}
+
+	if 0 == spec.Depth {
+		spec.Depth = 1
+	}
+	return spec, nil
+}
+
+// type fixtureGen holds the state of a fixture being generated.
+type fixtureGen struct {
+	spec  *FixtureSpec
+	rand  *rand.Rand
+	root  string          // absolute path of the library tree
+	title []string        // titles from which names are composed
+	dir   []string        // library-relative paths of all directories
+	video []string        // library-relative paths of all videos
+	used  map[string]bool // library-relative paths of all files
+}
+
+// function pick() returns a pseudo-random element of the given list.
+func (g *fixtureGen) pick(list []string) string {
+	return list[g.rand.Intn(len(list))]
+}
+
+// function mkdir() creates the given library-relative directory.
+func (g *fixtureGen) mkdir(rel string) *ReturnCode {
+	abs := filepath.Join(g.root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(abs, fixtureDirPerms); nil != err {
+		return rcInvalidPath.wrap(err, "fixture: os.MkdirAll(%q)", abs)
+	}
+	return nil
+}
+
+// function create() creates a file at the given library-relative path, adding
+// a numeric suffix to its base name if the path is already used. the content
+// of the file is its own path, so that no two files are identical. returns the
+// library-relative path of the file created.
+func (g *fixtureGen) create(dir, base, ext string) (string, *ReturnCode) {
+
+	rel := path.Join(dir, base+ext)
+	for n := 2; g.used[rel]; n++ {
+		rel = path.Join(dir, fmt.Sprintf("%s (%d)%s", base, n, ext))
+	}
+	g.used[rel] = true
+
+	if ret := g.mkdir(dir); nil != ret {
+		return "", ret
+	}
+	abs := filepath.Join(g.root, filepath.FromSlash(rel))
+	if err := ioutil.WriteFile(abs, []byte(rel+"\n"), fixtureFilePerms); nil != err {
+		return "", rcInvalidFile.wrap(err, "fixture: ioutil.WriteFile(%q)", abs)
+	}
+	return rel, nil
+}
+
+// function generate() creates the library tree described by the spec.
+func (g *fixtureGen) generate() *ReturnCode {
+
+	// directories: the root, plus two more at each level of nesting, each
+	// beneath some directory of the level above.
+	level := []string{"."}
+	g.dir = append(g.dir, ".")
+	for d := uint(1); d < g.spec.Depth; d++ {
+		next := []string{}
+		for i := 0; i < 2; i++ {
+			sub := path.Join(g.pick(level), g.pick(g.title))
+			if !g.used[sub] {
+				g.used[sub] = true
+				next = append(next, sub)
+				g.dir = append(g.dir, sub)
+			}
+		}
+		if 0 == len(next) {
+			break
+		}
+		level = next
+	}
+	for _, d := range g.dir {
+		if ret := g.mkdir(d); nil != ret {
+			return ret
+		}
+	}
+
+	// videos: alternate between episodes of a series and movies.
+	for i := uint(0); i < g.spec.Video; i++ {
+		base := g.pick(g.title)
+		if 0 == i%2 {
+			base = fmt.Sprintf("%s S%02dE%02d", base, 1+g.rand.Intn(3), 1+g.rand.Intn(12))
+		} else {
+			base = fmt.Sprintf("%s (%d)", base, 1970+g.rand.Intn(50))
+		}
+		rel, ret := g.create(g.pick(g.dir), base, g.pick(fixtureVideoExt))
+		if nil != ret {
+			return ret
+		}
+		g.video = append(g.video, rel)
+	}
+
+	// subtitles: cycle through the placements recognized by each of the
+	// subtitles heuristics, plus orphans which match none of them.
+	for i := uint(0); i < g.spec.Subs; i++ {
+		ext := g.pick(fixtureSubsExt)
+		if 0 == len(g.video) || 3 == i%4 {
+			if _, ret := g.create(g.pick(g.dir), "Orphan "+g.pick(g.title), ext); nil != ret {
+				return ret
+			}
+			continue
+		}
+		video := g.pick(g.video)
+		dir, base := path.Dir(video), strings.TrimSuffix(path.Base(video), path.Ext(video))
+		switch i % 4 {
+		case 0: // same base name, same directory
+		case 1: // same base name, subtitles subdirectory
+			dir = path.Join(dir, "Subs")
+		case 2: // language-tagged name, subdirectory named after the video
+			dir = path.Join(dir, base)
+			base = base + ".en"
+		}
+		if _, ret := g.create(dir, base, ext); nil != ret {
+			return ret
+		}
+	}
+
+	// audio: every fourth is a commentary track of some video.
+	for i := uint(0); i < g.spec.Audio; i++ {
+		ext := g.pick(fixtureAudioExt)
+		if 0 < len(g.video) && 3 == i%4 {
+			video := g.pick(g.video)
+			base := strings.TrimSuffix(path.Base(video), path.Ext(video)) + ".commentary"
+			if _, ret := g.create(path.Dir(video), base, ext); nil != ret {
+				return ret
+			}
+			continue
+		}
+		base := fmt.Sprintf("%s - %02d %s", g.pick(g.title), 1+i, g.pick(g.title))
+		if _, ret := g.create(g.pick(g.dir), base, ext); nil != ret {
+			return ret
+		}
+	}
+
+	// junk: files of no known kind, some without any extension.
+	for i := uint(0); i < g.spec.Junk; i++ {
+		if _, ret := g.create(g.pick(g.dir), g.pick(g.title), g.pick(fixtureJunkExt)); nil != ret {
+			return ret
+		}
+	}
+
+	return nil
+}
+
+// function generateFixture() creates the library tree described by the given
+// spec in the given directory.
+func generateFixture(spec *FixtureSpec, root string) (*fixtureGen, *ReturnCode) {
+
+	title := fixtureTitleASCII
+	if spec.Unicode {
+		title = append(append([]string{}, title...), fixtureTitleUnicode...)
+	}
+	gen := &fixtureGen{
+		spec:  spec,
+		rand:  rand.New(rand.NewSource(spec.Seed)),
+		root:  root,
+		title: title,
+		dir:   []string{},
+		video: []string{},
+		used:  map[string]bool{},
+	}
+	if ret := gen.generate(); nil != ret {
+		return nil, ret
+	}
+	return gen, nil
+}
+
+// type GoldenEntry records how a single file of a fixture is classified.
+type GoldenEntry struct {
+	Path    string   `json:"path"`              // library-relative, slash-separated
+	Class   string   `json:"class"`             // media/support kind, or "ignored"
+	Episode string   `json:"episode,omitempty"` // season and episode, if parsed
+	Assoc   []string `json:"assoc,omitempty"`   // associated files, with heuristic
+}
+
+// type GoldenOutput records how every file of a fixture is classified, along
+// with the totals a scan dry-run reports.
+type GoldenOutput struct {
+	Spec    *FixtureSpec    `json:"spec"`
+	Summary map[string]uint `json:"summary"`
+	File    []*GoldenEntry  `json:"file"`
+}
+
+// function goldenOutput() classifies every file in the given library tree,
+// configured by the given options, using the given subtitles association
+// heuristics.
+func goldenOutput(opt *Options, spec *FixtureSpec, assoc *SubsAssoc, root string) (*GoldenOutput, *ReturnCode) {
+
+	// the files of a fixture were just generated (or checked out), so they are
+	// never held as still being written; only those named like downloads in
+	// progress are.
+	lib := configureLibrary(opt, nil, root, root, depthUnlimited)
+	lib.quarantine = newQuarantine(0, lib.quarantine.torrent)
+	report := lib.dryRun("")
+
+	out := &GoldenOutput{
+		Spec:    spec,
+		Summary: map[string]uint{},
+		File:    []*GoldenEntry{},
+	}
+	for kind, n := range report.numMedia {
+		out.Summary[strings.ToLower(mediaColName[kind])] = n
+	}
+	for kind, n := range report.numSupport {
+		out.Summary[strings.ToLower(supportColName[kind])] = n
+	}
+	out.Summary["ignored"] = report.numIgnored
+	out.Summary["skipped"] = report.numSkipped
+
+	rel := func(p string) string {
+		if r, err := filepath.Rel(root, p); nil == err {
+			return filepath.ToSlash(r)
+		}
+		return p
+	}
+	assocList := func(match []classifyMatch) []string {
+		list := []string{}
+		for _, m := range match {
+			list = append(list, fmt.Sprintf("%s [%s]", rel(m.path), m.heuristic))
+		}
+		sort.Strings(list)
+		return list
+	}
+
+	filepath.Walk(root,
+		func(p string, info os.FileInfo, err error) error {
+			if nil != err || !info.Mode().IsRegular() {
+				return nil
+			}
+			ext := path.Ext(p)
+			entry := &GoldenEntry{Path: rel(p), Class: "ignored"}
+			if kind, _ := mediaKindOfFile(p, ext); mkUnknown != kind {
+				entry.Class = strings.ToLower(mediaColName[kind])
+				if season, episode, ok := parseEpisode(fileBase(p)); ok {
+					entry.Episode = fmt.Sprintf("S%02dE%02d", season, episode)
+				}
+				if mkVideo == kind {
+					for _, sk := range []SupportKind{skSubtitles, skAudioTrack} {
+						entry.Assoc = append(entry.Assoc, assocList(supportCandidates(assoc, sk, p))...)
+					}
+				}
+			} else if kind, _ := supportKindOfFile(p, ext); skUnknown != kind {
+				entry.Class = strings.ToLower(supportColName[kind])
+				entry.Assoc = assocList(videoCandidates(assoc, p))
+			}
+			out.File = append(out.File, entry)
+			return nil
+		})
+
+	return out, nil
+}
+
+// function diff() returns a description of each difference between the
+// receiver, which is the expected output, and the given actual output.
+func (g *GoldenOutput) diff(actual *GoldenOutput) []string {
+
+	diff := []string{}
+
+	key := []string{}
+	for k := range g.Summary {
+		key = append(key, k)
+	}
+	for k := range actual.Summary {
+		if _, ok := g.Summary[k]; !ok {
+			key = append(key, k)
+		}
+	}
+	sort.Strings(key)
+	for _, k := range key {
+		if g.Summary[k] != actual.Summary[k] {
+			diff = append(diff, fmt.Sprintf("summary: %s: expected %d, got %d",
+				k, g.Summary[k], actual.Summary[k]))
+		}
+	}
+
+	found := map[string]*GoldenEntry{}
+	for _, e := range actual.File {
+		found[e.Path] = e
+	}
+	for _, e := range g.File {
+		a, ok := found[e.Path]
+		if !ok {
+			diff = append(diff, fmt.Sprintf("%s: missing", e.Path))
+			continue
+		}
+		delete(found, e.Path)
+		if e.Class != a.Class {
+			diff = append(diff, fmt.Sprintf("%s: class: expected %q, got %q", e.Path, e.Class, a.Class))
+		}
+		if e.Episode != a.Episode {
+			diff = append(diff, fmt.Sprintf("%s: episode: expected %q, got %q", e.Path, e.Episode, a.Episode))
+		}
+		if strings.Join(e.Assoc, "\n") != strings.Join(a.Assoc, "\n") {
+			diff = append(diff, fmt.Sprintf("%s: assoc: expected %q, got %q", e.Path, e.Assoc, a.Assoc))
+		}
+	}
+	for _, a := range actual.File {
+		if _, ok := found[a.Path]; ok {
+			diff = append(diff, fmt.Sprintf("%s: unexpected", a.Path))
+		}
+	}
+
+	return diff
+}
+
+// function fixtureCommand() implements the "fixture" command. the fixture is
+// created in a new (or empty) directory, containing the library tree and the
+// golden output of classifying it with the current subtitles heuristics.
+func fixtureCommand(options *Options, args []string) *ReturnCode {
+
+	if len(args) < 1 {
+		return rcInvalidArgs.spec("fixture: expected DIR [KEY=VALUE...]")
+	}
+	spec, ret := newFixtureSpec(args[1:])
+	if nil != ret {
+		return ret
+	}
+	abs, err := filepath.Abs(args[0])
+	if nil != err {
+		return rcInvalidPath.specf("fixture(%q): filepath.Abs(): %s", args[0], err)
+	}
+	if name, err := ioutil.ReadDir(abs); nil == err && len(name) > 0 {
+		return rcInvalidPath.specf("fixture(%q): directory is not empty", abs)
+	}
+
+	gen, ret := generateFixture(spec, filepath.Join(abs, fixtureLibraryDir))
+	if nil != ret {
+		return ret
+	}
+
+	golden, ret := goldenOutput(options, spec, newSubsAssoc(options, nil), gen.root)
+	if nil != ret {
+		return ret
+	}
+	data, err := json.MarshalIndent(golden, "", "  ")
+	if nil != err {
+		return rcInvalidJSONData.specf("fixture(%q): json.MarshalIndent(): %s", abs, err)
+	}
+	goldenPath := filepath.Join(abs, fixtureGoldenFileName)
+	if err := ioutil.WriteFile(goldenPath, append(data, '\n'), fixtureFilePerms); nil != err {
+		return rcInvalidFile.specf("fixture(%q): ioutil.WriteFile(): %s", goldenPath, err)
+	}
+
+	rawLog.logf("%s", abs)
+	rawLog.logf("  library: %s (%d files in %d directories)", gen.root, len(golden.File), len(gen.dir))
+	rawLog.logf("  golden: %s", goldenPath)
+	return nil
+}
+
+// function goldenCommand() implements the "golden" command. the library tree of
+// the given fixture is classified again, and compared with its golden output.
+// the subtitles heuristics must be configured the same as when the fixture was
+// generated.
+func goldenCommand(options *Options, args []string) *ReturnCode {
+
+	if 1 != len(args) {
+		return rcInvalidArgs.spec("golden: expected DIR")
+	}
+	abs, err := filepath.Abs(args[0])
+	if nil != err {
+		return rcInvalidPath.specf("golden(%q): filepath.Abs(): %s", args[0], err)
+	}
+	root := filepath.Join(abs, fixtureLibraryDir)
+	if exists, _ := goutil.PathExists(root); !exists {
+		return rcInvalidPath.specf("golden(%q): not a fixture (see command \"fixture\")", abs)
+	}
+
+	expected := &GoldenOutput{}
+	if ok, ret := readJSONFile(filepath.Join(abs, fixtureGoldenFileName), expected); nil != ret {
+		return ret
+	} else if !ok {
+		return rcInvalidPath.specf("golden(%q): no golden output: %q", abs, fixtureGoldenFileName)
+	}
+	actual, ret := goldenOutput(options, expected.Spec, newSubsAssoc(options, nil), root)
+	if nil != ret {
+		return ret
+	}
+
+	rawLog.logf("%s", abs)
+	diff := expected.diff(actual)
+	for _, d := range diff {
+		rawLog.logf("  %s", d)
+	}
+	if len(diff) > 0 {
+		return rcGoldenMismatch.specf("golden(%q): %d differences from golden output", abs, len(diff))
+	}
+	rawLog.logf("  ok: %d files match the golden output", len(actual.File))
+	return nil
+}
@@ -0,0 +1,155 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: fixture_test.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    regression tests of the scanner and subtitles heuristics against the
+//    golden fixtures committed in testdata/fixture, each generated by the
+//    "fixture" command. run "go test -update" to rewrite their golden output
+//    after an intended change to classification or association.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// local unexported constants for the golden fixture tests.
+const (
+	fixtureTestDir = "testdata/fixture" // directory containing the golden fixtures
+)
+
+var (
+	// variable updateGolden rewrites the golden output of every fixture with
+	// the actual output, rather than comparing them.
+	updateGolden = flag.Bool("update", false, "rewrite the golden output of each fixture")
+)
+
+// function fixtureTestOptions() returns the default options, with the
+// configuration directory isolated from the user's.
+func fixtureTestOptions(t *testing.T) *Options {
+	t.Helper()
+	config := filepath.Join(t.TempDir(), defaultConfigName)
+	options, ret := initOptions([]string{"-config", config})
+	if nil != ret {
+		t.Fatalf("initOptions(): %s", ret)
+	}
+	return options
+}
+
+// function fixtureTestDirs() returns the path of every golden fixture.
+func fixtureTestDirs(t *testing.T) []string {
+	t.Helper()
+	dir, err := filepath.Glob(filepath.Join(fixtureTestDir, "*", fixtureGoldenFileName))
+	if nil != err || 0 == len(dir) {
+		t.Fatalf("no golden fixtures found in %q", fixtureTestDir)
+	}
+	for i, d := range dir {
+		dir[i] = filepath.Dir(d)
+	}
+	return dir
+}
+
+// function readGolden() reads the golden output of the given fixture.
+func readGolden(t *testing.T, dir string) *GoldenOutput {
+	t.Helper()
+	golden := &GoldenOutput{}
+	if ok, ret := readJSONFile(filepath.Join(dir, fixtureGoldenFileName), golden); nil != ret || !ok {
+		t.Fatalf("readJSONFile(%q): %v", dir, ret)
+	}
+	return golden
+}
+
+// function treeFiles() returns the slash-separated path, relative to the given
+// root, of every regular file beneath it, sorted.
+func treeFiles(t *testing.T, root string) []string {
+	t.Helper()
+	list := []string{}
+	err := filepath.Walk(root,
+		func(p string, info os.FileInfo, err error) error {
+			if nil != err {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				rel, _ := filepath.Rel(root, p)
+				list = append(list, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+	if nil != err {
+		t.Fatalf("filepath.Walk(%q): %s", root, err)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// function TestGoldenFixtures() classifies the library tree of each fixture
+// and compares it with the fixture's golden output.
+func TestGoldenFixtures(t *testing.T) {
+
+	options := fixtureTestOptions(t)
+	for _, dir := range fixtureTestDirs(t) {
+		dir := dir
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+
+			expected := readGolden(t, dir)
+			root, _ := filepath.Abs(filepath.Join(dir, fixtureLibraryDir))
+			actual, ret := goldenOutput(options, expected.Spec, newSubsAssoc(options, nil), root)
+			if nil != ret {
+				t.Fatalf("goldenOutput(%q): %s", dir, ret)
+			}
+
+			if *updateGolden {
+				data, err := json.MarshalIndent(actual, "", "  ")
+				if nil != err {
+					t.Fatalf("json.MarshalIndent(): %s", err)
+				}
+				path := filepath.Join(dir, fixtureGoldenFileName)
+				if err := ioutil.WriteFile(path, append(data, '\n'), fixtureFilePerms); nil != err {
+					t.Fatalf("ioutil.WriteFile(%q): %s", path, err)
+				}
+				return
+			}
+
+			for _, d := range expected.diff(actual) {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+// function TestFixtureGenerator() generates the library tree of each fixture
+// again from its spec, and compares it with the tree committed.
+func TestFixtureGenerator(t *testing.T) {
+
+	for _, dir := range fixtureTestDirs(t) {
+		dir := dir
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+
+			golden := readGolden(t, dir)
+			root := filepath.Join(t.TempDir(), fixtureLibraryDir)
+			if _, ret := generateFixture(golden.Spec, root); nil != ret {
+				t.Fatalf("generateFixture(%q): %s", dir, ret)
+			}
+
+			expected := treeFiles(t, filepath.Join(dir, fixtureLibraryDir))
+			actual := treeFiles(t, root)
+			if strings.Join(expected, "\n") != strings.Join(actual, "\n") {
+				t.Errorf("generated tree differs from %q:\nexpected: %q\ngot:      %q",
+					dir, expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: gc.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "gc" command, which removes every database directory of the
+//    data directory that is not referenced by a library: those whose library
+//    no longer exists, and registry entries whose database no longer exists.
+//    stale databases otherwise accumulate forever. database directories not in
+//    the registry are only removed when named, since those with a legacy name
+//    are registered only once their library is next opened.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// function dirSize() returns the total size of all regular files in the
+// directory tree at the given path.
+func dirSize(p string) uint64 {
+	size := uint64(0)
+	filepath.Walk(p,
+		func(_ string, info os.FileInfo, err error) error {
+			if nil == err && info.Mode().IsRegular() {
+				size += uint64(info.Size())
+			}
+			return nil
+		})
+	return size
+}
+
+// function confirm() writes the given prompt to the raw logger and returns true
+// if the user answers yes on standard input.
+func confirm(prompt string) bool {
+	rawLog.logf("%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// function configuredLegacyNames() returns the legacy database name of each
+// library given in the config file, whose database may not be registered yet.
+func configuredLegacyNames(options *Options) map[string]bool {
+	legacy := map[string]bool{}
+	for _, lib := range options.ConfigLibraries {
+		if abs, err := libraryPath(lib); nil == err {
+			legacy[legacyDatabaseName(abs)] = true
+		}
+	}
+	return legacy
+}
+
+// function gcCommand() implements the "gc" command. the databases to remove are
+// listed along with their size, and removed once the user confirms, or right
+// away if -f (or --force) is given. unregistered database directories are
+// removed only if given by name, and never if they belong to a library given
+// in the config file. databases in use by another process are never removed.
+// note that a library on a drive that is not mounted appears to no longer
+// exist.
+func gcCommand(options *Options, args []string) *ReturnCode {
+
+	force := false
+	named := map[string]bool{}
+	for _, arg := range args {
+		switch {
+		case "-f" == arg || "-force" == arg || "--force" == arg:
+			force = true
+		case strings.HasPrefix(arg, "-"):
+			return rcInvalidArgs.specf("gc: unknown argument: %q", arg)
+		default:
+			named[strings.ToLower(arg)] = true
+		}
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	dat := options.LibData.string
+	r, list, ret := listDatabases(dat)
+	if nil != ret {
+		return ret
+	}
+	legacy := configuredLegacyNames(options)
+
+	garbage := []*DatabaseInfo{}
+	size := map[*DatabaseInfo]uint64{}
+	total := uint64(0)
+	kept := 0
+	for _, d := range list {
+		if dsLinked == d.state {
+			continue
+		}
+		if dsUnregistered == d.state {
+			if legacy[d.name] {
+				continue
+			}
+			if !named[strings.ToLower(d.name)] {
+				kept++
+				continue
+			}
+		}
+		delete(named, strings.ToLower(d.name))
+		garbage = append(garbage, d)
+		size[d] = dirSize(filepath.Join(dat, d.name))
+		total += size[d]
+	}
+	for name := range named {
+		return rcInvalidArgs.specf("gc(%q): no such database, or its library exists", name)
+	}
+	for _, d := range garbage {
+		rawLog.logf("  %10s  %s", formatSizeApprox(size[d]), d)
+	}
+	if kept > 0 {
+		rawLog.logf("%s: %d unregistered databases kept (give their names to remove)", dat, kept)
+	}
+	if 0 == len(garbage) {
+		rawLog.logf("%s: no unreferenced databases", dat)
+		return nil
+	}
+
+	if !force && !confirm(fmt.Sprintf("remove %d databases (%s)?",
+		len(garbage), formatSizeApprox(total))) {
+		rawLog.log("nothing removed")
+		return nil
+	}
+
+	count, reclaimed := 0, uint64(0)
+	for _, d := range garbage {
+		if ret := r.remove(d); nil != ret {
+			warnLog.log(ret)
+			continue
+		}
+		count++
+		reclaimed += size[d]
+	}
+	if ret := r.save(); nil != ret {
+		return ret
+	}
+	rawLog.logf("%s: removed %d of %d databases, reclaimed %s",
+		dat, count, len(garbage), formatSizeApprox(reclaimed))
+	return nil
+}
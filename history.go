@@ -0,0 +1,134 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: history.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the reader of listening histories for the "import" command (see
+//    import.go), which seeds the playback statistics (play count and last
+//    played date) of audio media. a listening history is a CSV file with one
+//    row per play, such as a Last.fm scrobble export. if its first row is a
+//    header naming columns "artist", "album", "title" (or "track"), and
+//    "date" (or "time", "timestamp", "uts"), the columns may be in any order;
+//    otherwise they are taken to be artist, album, title, and date, as in
+//    Last.fm exports. the plays of each track are counted, and the latest
+//    date is its last played date.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// variable historyColumn lists the accepted header names of each column of
+	// a listening history, in the order assumed when there is no header.
+	historyColumn = [][]string{
+		{"artist"},
+		{"album"},
+		{"title", "track", "name"},
+		{"date", "time", "timestamp", "uts", "played"},
+	}
+
+	// variable historyDateFormat lists the accepted formats of the date of a
+	// play, besides Unix time in seconds.
+	historyDateFormat = []string{
+		"02 Jan 2006 15:04", // Last.fm
+		"02 Jan 2006, 15:04",
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+)
+
+// function parseHistoryDate() parses the date of a play.
+func parseHistoryDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if sec, err := strconv.ParseInt(s, 10, 64); nil == err {
+		return time.Unix(sec, 0), nil
+	}
+	for _, f := range historyDateFormat {
+		if t, err := time.Parse(f, s); nil == err {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date: %q", s)
+}
+
+// function readListeningHistory() reads the plays of a listening history,
+// returning one item per track with its number of plays and last played date.
+func readListeningHistory(data []byte) ([]*ImportItem, error) {
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	row, err := r.ReadAll()
+	if nil != err {
+		return nil, err
+	}
+
+	// locate each column from the header, if there is one.
+	col := []int{0, 1, 2, 3}
+	if len(row) > 0 {
+		header := false
+		for c, names := range historyColumn {
+			for i, cell := range row[0] {
+				cell = strings.ToLower(strings.TrimSpace(cell))
+				if containsString(names, cell) {
+					col[c], header = i, true
+				}
+			}
+		}
+		if header {
+			row = row[1:]
+		}
+	}
+
+	byKey := map[string]*ImportItem{}
+	item := []*ImportItem{}
+	for n, cell := range row {
+		field := make([]string, len(col))
+		for c, i := range col {
+			if i < len(cell) {
+				field[c] = strings.TrimSpace(cell[i])
+			}
+		}
+		if "" == field[2] {
+			continue
+		}
+		key := strings.ToLower(strings.Join(field[:3], "\x00"))
+		i, ok := byKey[key]
+		if !ok {
+			i = &ImportItem{
+				artist:   field[0],
+				album:    field[1],
+				title:    field[2],
+				playlist: []string{},
+				artwork:  map[ArtworkKind]string{},
+			}
+			byKey[key] = i
+			item = append(item, i)
+		}
+		i.plays++
+		if "" != field[3] {
+			t, err := parseHistoryDate(field[3])
+			if nil != err {
+				warnLog.tracef("import: row %d: %s", n+1, err)
+			} else if t.After(i.lastPlayed) {
+				i.lastPlayed = t
+			}
+		}
+	}
+	return item, nil
+}
@@ -0,0 +1,98 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: host.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the per-host player arguments, which are appended to every
+//    playback command launched on a given host. libraries (and the playback
+//    commands stored in their databases) are often shared by machines with
+//    different hardware, e.g. "--hwdec=vaapi" suits the HTPC but not the
+//    laptop, so these arguments are chosen by the host's name when pimm
+//    starts rather than stored with the media.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// local unexported constants for per-host player arguments.
+const (
+	hostArgsAnyHost = "*" // host name matching any host without its own arguments
+)
+
+var (
+	// variable hostArgs maps each host name (lower-case) to its declared player
+	// arguments.
+	hostArgs = map[string]string{}
+)
+
+// function parseHostArgs() parses a player arguments declaration of the form
+// HOST=ARGS.
+func parseHostArgs(spec string) (string, string, error) {
+	part := strings.SplitN(spec, "=", 2)
+	if 2 != len(part) || "" == strings.TrimSpace(part[0]) {
+		return "", "", fmt.Errorf("host arguments %q: expected HOST=ARGS", spec)
+	}
+	return strings.TrimSpace(part[0]), strings.TrimSpace(part[1]), nil
+}
+
+// function setHostArgs() records the player arguments declared by the given
+// options, which must have already been validated.
+func setHostArgs(opt *Options) {
+	declared := map[string]string{}
+	for _, spec := range opt.HostArgs.StringList {
+		if name, args, err := parseHostArgs(spec); nil == err {
+			declared[strings.ToLower(name)] = args
+		}
+	}
+	liveMutex.Lock()
+	hostArgs = declared
+	liveMutex.Unlock()
+	if args := argsForHost(localHostName()); "" != args {
+		infoLog.verbosef("player arguments for this host: %s", args)
+	}
+}
+
+// function localHostName() returns the name of this host, or an empty string if
+// it cannot be determined.
+func localHostName() string {
+	host, err := os.Hostname()
+	if nil != err {
+		warnLog.tracef("cannot determine host name: %s", err)
+		return ""
+	}
+	return host
+}
+
+// function argsForHost() returns the player arguments declared for the given
+// host, which is matched by its full name or by its name without a domain, and
+// falls back on the arguments declared for any host ("*").
+func argsForHost(host string) string {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	host = strings.ToLower(host)
+	if args, ok := hostArgs[host]; ok && "" != host {
+		return args
+	}
+	if args, ok := hostArgs[strings.SplitN(host, ".", 2)[0]]; ok && "" != host {
+		return args
+	}
+	return hostArgs[hostArgsAnyHost]
+}
+
+// function withHostArgs() appends the player arguments of the given host (if
+// any) to the given playback command.
+func withHostArgs(cmd, host string) string {
+	if args := argsForHost(host); "" != args {
+		return fmt.Sprintf("%s %s", cmd, args)
+	}
+	return cmd
+}
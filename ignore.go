@@ -0,0 +1,84 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: ignore.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the files and directories always ignored by the scanner: the
+//    metadata left behind by operating systems and NAS devices (e.g. Finder's
+//    .DS_Store, Windows' Thumbs.db, Synology's @eaDir) and their trash
+//    directories. these are never examined, nor reported as other files, and
+//    the contents of such directories are never scanned. more names may be
+//    ignored with option -ignore.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// variable defaultIgnoreName lists the name patterns (lower case) of the
+	// operating system metadata files and directories always ignored.
+	defaultIgnoreName = []string{
+		// macOS
+		".ds_store", "._*", ".appledouble", ".apdisk", ".spotlight-v100",
+		".fseventsd", ".temporaryitems", ".trashes", ".documentrevisions-v100",
+		// Windows
+		"thumbs.db", "ehthumbs.db", "desktop.ini", "$recycle.bin",
+		"system volume information",
+		// Linux desktops
+		".trash", ".trash-*", ".directory",
+		// NAS devices (Synology, QNAP, etc.)
+		"@eadir", "#recycle", "#snapshot", "@recycle", ".@__thumb",
+	}
+)
+
+// function parseIgnoreName() parses a name pattern given by option -ignore.
+func parseIgnoreName(spec string) (string, error) {
+	pattern := strings.ToLower(strings.TrimSpace(spec))
+	if "" == pattern || strings.ContainsAny(pattern, `/\`) {
+		return "", fmt.Errorf("ignore %q: expected a file or directory name pattern", spec)
+	}
+	if _, err := filepath.Match(pattern, ""); nil != err {
+		return "", fmt.Errorf("ignore %q: %s", spec, err)
+	}
+	return pattern, nil
+}
+
+// function ignoreNames() returns the name patterns ignored by the scanner: the
+// defaults, followed by those given with option -ignore, which must have
+// already been validated.
+func ignoreNames(opt *Options) []string {
+	name := append([]string{}, defaultIgnoreName...)
+	for _, spec := range opt.IgnoreName.StringList {
+		if pattern, err := parseIgnoreName(spec); nil == err {
+			name = append(name, pattern)
+		}
+	}
+	return name
+}
+
+// function matchIgnored() returns true if the name of the file or directory at
+// the given path matches any of the given name patterns (case-insensitive).
+func matchIgnored(pattern []string, absPath string) bool {
+	base := strings.ToLower(filepath.Base(absPath))
+	for _, p := range pattern {
+		if ok, _ := filepath.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// function isIgnored() returns true if the file or directory at the given path
+// must be ignored by the scanner.
+func (l *Library) isIgnored(absPath string) bool {
+	return matchIgnored(l.ignoreName, absPath)
+}
@@ -0,0 +1,470 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: import.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "import" command, which seeds the metadata of a library's
+//    media from an export of another media server, easing migration. the
+//    supported exports are:
+//
+//      jellyfin: the JSON response of Jellyfin's items API, requested with
+//                Fields=Path,Overview,Genres,Tags and user data; the image
+//                list of each item (as from its images API) may be included
+//                as field "ImageInfos".
+//      plex:     the XML response of Plex's library section contents, e.g.
+//                /library/sections/N/all.
+//
+//    each exported item whose file path (after applying the path remap rules
+//    of option -remap) matches a media file in the library updates that
+//    media's title, description, release date, and tags. the item's genres
+//    and collections are added as tags, as is tag "watched" if it was played.
+//    artwork found on the local file system is copied to the artwork cache.
+//    note that Jellyfin's collections (box sets) are not part of its item
+//    export, so only its tags and genres are imported. the playlists and
+//    ratings of music players are imported too (see playlist.go).
+//
+//    an exported item whose path matches no media file (e.g. the library was
+//    moved since) is matched by file name instead, then by title and album,
+//    and then by a file name (without extension) of "TITLE" or "ARTIST -
+//    TITLE", as long as only one media file matches. exported items that
+//    still match nothing are reported.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for the import command.
+const (
+	importWatchedTag  = "watched"   // tag added to media that were played
+	importPlaylistTag = "playlist:" // prefix of the tags naming a playlist
+	importRatingTag   = "rating:"   // prefix of the tag giving a rating
+)
+
+// type ImportItem is the metadata of a single exported item, independent of
+// the media server it was exported from.
+type ImportItem struct {
+	path        string                 // absolute path of the media file
+	title       string                 // official name of media
+	description string                 // synopsis/summary of media content
+	released    time.Time              // date media was produced/released
+	artist      string                 // name of the artist performing the track
+	album       string                 // name of the album on which the track appears
+	tags        []string               // tags, genres, and collections
+	watched     bool                   // media was played at least once
+	rating      int                    // stars from 1 to 5 (0 = unrated)
+	playlist    []string               // names of the playlists containing the media
+	plays       int                    // number of times media was played
+	lastPlayed  time.Time              // date media was most recently played
+	artwork     map[ArtworkKind]string // local paths of images by kind
+}
+
+// function String() returns the path of the exported item, or its artist and
+// title if it has no path.
+func (i *ImportItem) String() string {
+	if "" != i.path {
+		return i.path
+	}
+	if "" != i.artist {
+		return fmt.Sprintf("%s - %s", i.artist, i.title)
+	}
+	return i.title
+}
+
+// type ImportTarget is a media record of the library, along with the exported
+// item matched to it (if any).
+type ImportTarget struct {
+	col  Collection
+	id   int
+	rec  map[string]interface{}
+	item *ImportItem
+}
+
+// type JellyfinExport is the JSON response of Jellyfin's items API.
+type JellyfinExport struct {
+	Items []struct {
+		Name         string
+		Path         string
+		Album        string
+		Overview     string
+		PremiereDate string
+		Tags         []string
+		Genres       []string
+		UserData     struct{ Played bool }
+		ImageInfos   []struct{ ImageType, Path string }
+	}
+}
+
+// type PlexExport is the XML response of Plex's library section contents.
+// every element of the container (e.g. Video, Track) is an item.
+type PlexExport struct {
+	Item []struct {
+		XMLName    xml.Name
+		Title      string     `xml:"title,attr"`
+		Parent     string     `xml:"parentTitle,attr"`
+		Summary    string     `xml:"summary,attr"`
+		ViewCount  int        `xml:"viewCount,attr"`
+		Released   string     `xml:"originallyAvailableAt,attr"`
+		Thumb      string     `xml:"thumb,attr"`
+		Part       []PlexPart `xml:"Media>Part"`
+		Genre      []PlexTag  `xml:"Genre"`
+		Collection []PlexTag  `xml:"Collection"`
+	} `xml:",any"`
+}
+
+// type PlexPart is a file of an item in a Plex export.
+type PlexPart struct {
+	File string `xml:"file,attr"`
+}
+
+// type PlexTag is a genre or collection of an item in a Plex export.
+type PlexTag struct {
+	Tag string `xml:"tag,attr"`
+}
+
+// function readJellyfinExport() reads the items of a Jellyfin export.
+func readJellyfinExport(data []byte) ([]*ImportItem, error) {
+
+	var export JellyfinExport
+	if err := json.Unmarshal(data, &export); nil != err {
+		return nil, err
+	}
+	item := []*ImportItem{}
+	for _, e := range export.Items {
+		if "" == e.Path {
+			continue // e.g. a folder or box set
+		}
+		i := &ImportItem{
+			path:        e.Path,
+			title:       e.Name,
+			description: e.Overview,
+			album:       e.Album,
+			tags:        append(append([]string{}, e.Tags...), e.Genres...),
+			watched:     e.UserData.Played,
+			artwork:     map[ArtworkKind]string{},
+		}
+		if t, err := time.Parse(time.RFC3339Nano, e.PremiereDate); nil == err {
+			i.released = t
+		}
+		for _, img := range e.ImageInfos {
+			switch img.ImageType {
+			case "Primary":
+				i.artwork[akPoster] = img.Path
+			case "Thumb":
+				i.artwork[akThumbnail] = img.Path
+			}
+		}
+		item = append(item, i)
+	}
+	return item, nil
+}
+
+// function readPlexExport() reads the items of a Plex export. an item with
+// several files (e.g. a movie in several versions) is imported for each file.
+func readPlexExport(data []byte) ([]*ImportItem, error) {
+
+	var export PlexExport
+	if err := xml.Unmarshal(data, &export); nil != err {
+		return nil, err
+	}
+	item := []*ImportItem{}
+	for _, e := range export.Item {
+		tags := []string{}
+		for _, t := range e.Genre {
+			tags = append(tags, t.Tag)
+		}
+		for _, t := range e.Collection {
+			tags = append(tags, t.Tag)
+		}
+		released, _ := time.Parse("2006-01-02", e.Released)
+		album := ""
+		if "Track" == e.XMLName.Local {
+			album = e.Parent // the parent of a video is its season
+		}
+		for _, part := range e.Part {
+			item = append(item, &ImportItem{
+				path:        part.File,
+				title:       e.Title,
+				description: e.Summary,
+				released:    released,
+				album:       album,
+				tags:        tags,
+				watched:     e.ViewCount > 0,
+				artwork:     map[ArtworkKind]string{akPoster: e.Thumb},
+			})
+		}
+	}
+	return item, nil
+}
+
+// function importRecord() updates the given media record with the metadata of
+// the given item. returns true if anything changed.
+func importRecord(rec map[string]interface{}, item *ImportItem) bool {
+
+	changed := false
+	set := func(key string, val interface{}) {
+		if rec[key] != val {
+			rec[key] = val
+			changed = true
+		}
+	}
+	if "" != item.title {
+		set("Title", item.title)
+	}
+	if "" != item.description {
+		set("Description", item.description)
+	}
+	if !item.released.IsZero() {
+		set("ReleaseDate", item.released.Format(time.RFC3339Nano))
+	}
+	// the statistics are only ever raised, so that importing the same history
+	// twice does not count the same plays twice.
+	if count, _ := rec["PlayCount"].(float64); float64(item.plays) > count {
+		set("PlayCount", float64(item.plays))
+	}
+	if !item.lastPlayed.IsZero() {
+		last, _ := rec["LastPlayed"].(string)
+		if t, err := time.Parse(time.RFC3339Nano, last); nil != err || item.lastPlayed.After(t) {
+			set("LastPlayed", item.lastPlayed.Format(time.RFC3339Nano))
+		}
+	}
+
+	tags := append([]string{}, item.tags...)
+	if item.watched {
+		tags = append(tags, importWatchedTag)
+	}
+	for _, p := range item.playlist {
+		tags = append(tags, importPlaylistTag+p)
+	}
+	have := []interface{}{}
+	if t, ok := rec["Tags"].([]interface{}); ok {
+		have = t
+	}
+	if item.rating > 0 {
+		// a media has a single rating, which replaces any other.
+		rating := fmt.Sprintf("%s%d", importRatingTag, item.rating)
+		keep := []interface{}{}
+		for _, t := range have {
+			if s, ok := t.(string); ok && strings.HasPrefix(s, importRatingTag) && s != rating {
+				changed = true
+				continue
+			}
+			keep = append(keep, t)
+		}
+		have = keep
+		tags = append(tags, rating)
+	}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		found := "" == tag
+		for _, t := range have {
+			if s, ok := t.(string); ok && s == tag {
+				found = true
+			}
+		}
+		if !found {
+			have = append(have, tag)
+			changed = true
+		}
+	}
+	rec["Tags"] = have
+	return changed
+}
+
+// function importArtwork() copies the local images of the given item to the
+// given artwork cache, keyed by the path of its media file. returns the number
+// of images copied.
+func importArtwork(cache *ArtworkCache, item *ImportItem, path string) int {
+	count := 0
+	for kind, src := range item.artwork {
+		if exists, _ := goutil.PathExists(src); "" == src || !exists {
+			continue // e.g. a URL of the media server
+		}
+		data, err := ioutil.ReadFile(src)
+		if nil != err {
+			warnLog.tracef("import: cannot read artwork: %s", err)
+			continue
+		}
+		if _, ret := cache.put(kind, path, filepath.Ext(src), data); nil != ret {
+			warnLog.trace(ret)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// function importKey() returns the key by which an exported item is matched to
+// a media record by title and album, or an empty key if there is no album
+// (since titles alone are rarely unique).
+func importKey(title, album string) string {
+	if "" == strings.TrimSpace(album) || "" == strings.TrimSpace(title) {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(title)) + "\x00" +
+		strings.ToLower(strings.TrimSpace(album))
+}
+
+// function importCommand() implements the "import" command, updating the media
+// of the given library with the metadata of the items in the given export.
+func importCommand(options *Options, args []string) *ReturnCode {
+
+	if 3 != len(args) {
+		return rcInvalidArgs.spec("import: expected jellyfin|plex|itunes|rekordbox|history EXPORT LIBRARY")
+	}
+	data, err := ioutil.ReadFile(args[1])
+	if nil != err {
+		return rcInvalidFile.specf("import(%q): %s", args[1], err)
+	}
+	var item []*ImportItem
+	switch strings.ToLower(args[0]) {
+	case "jellyfin":
+		item, err = readJellyfinExport(data)
+	case "plex":
+		item, err = readPlexExport(data)
+	case "itunes":
+		item, err = readITunesLibrary(data)
+	case "rekordbox":
+		item, err = readRekordboxExport(data)
+	case "history":
+		item, err = readListeningHistory(data)
+	default:
+		return rcInvalidArgs.specf(
+			"import: unknown source: %q (expected jellyfin, plex, itunes, rekordbox, or history)", args[0])
+	}
+	if nil != err {
+		return rcInvalidJSONData.specf("import(%q): cannot read %s export: %s", args[1], args[0], err)
+	}
+
+	abs, err := libraryPath(args[2])
+	if nil != err {
+		return rcInvalidLibrary.specf("import(%q): libraryPath(): %s", args[2], err)
+	}
+
+	// exported paths are those of the media server, which may have the files
+	// mounted elsewhere (see option -remap).
+	for _, i := range item {
+		if "" == i.path {
+			continue // e.g. a listening history
+		}
+		i.path = filepath.Clean(i.path)
+		for _, spec := range options.PathRemap.StringList {
+			if rule, err := parseRemapRule(spec); nil == err {
+				if p, ok := rule.forward(i.path); ok {
+					i.path = p
+					break
+				}
+			}
+		}
+	}
+
+	_, _, path := libraryDatabasePath(options, abs)
+	if exists, _ := goutil.PathExists(path); !exists {
+		return rcInvalidLibrary.specf("import(%q): library has never been scanned", abs)
+	}
+	if ret := lockDatabase(path); nil != ret {
+		return ret
+	}
+	defer unlockDatabase(path)
+
+	cache := newArtworkCache(options)
+	store, err := openStore(path, storeEngine(path))
+	if nil != err {
+		return rcDatabaseError.specf("import(%q): openStore(%q): %s", abs, path, err)
+	}
+	defer store.Close()
+
+	// tiedot holds the collection's lock while iterating, so the records are
+	// first collected and then updated once the iteration has finished. the
+	// heuristic keys map to nil if more than one record has the same key.
+	byPath := map[string]*ImportTarget{}
+	byName := map[string]*ImportTarget{}
+	byTitle := map[string]*ImportTarget{}
+	byStem := map[string]*ImportTarget{}
+	index := func(m map[string]*ImportTarget, key string, t *ImportTarget) {
+		if _, dup := m[key]; dup {
+			m[key] = nil
+		} else if "" != key {
+			m[key] = t
+		}
+	}
+	for _, name := range entityColName[ecMedia] {
+		if !store.ColExists(name) {
+			continue
+		}
+		col := store.Use(name)
+		col.ForEachDoc(
+			func(id int, data []byte) (willMoveOn bool) {
+				rec := map[string]interface{}{}
+				if err := json.Unmarshal(data, &rec); nil != err {
+					warnLog.tracef("import(%q): skipping corrupt record %d: %s", name, id, err)
+					return true
+				}
+				t := &ImportTarget{col: col, id: id, rec: rec}
+				p, _ := rec["AbsPath"].(string)
+				title, _ := rec["Title"].(string)
+				album, _ := rec["Album"].(string)
+				byPath[p] = t
+				index(byName, strings.ToLower(filepath.Base(p)), t)
+				index(byTitle, importKey(title, album), t)
+				index(byStem, strings.ToLower(strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))), t)
+				return true
+			})
+	}
+
+	unmatched, updated, images := []*ImportItem{}, 0, 0
+	for _, i := range item {
+		var t *ImportTarget
+		if "" != i.path {
+			t = byPath[i.path]
+			if nil == t {
+				t = byName[strings.ToLower(filepath.Base(i.path))]
+			}
+		}
+		if nil == t {
+			t = byTitle[importKey(i.title, i.album)]
+		}
+		if nil == t && "" != i.title {
+			t = byStem[strings.ToLower(i.title)]
+			if nil == t && "" != i.artist {
+				t = byStem[strings.ToLower(i.artist+" - "+i.title)]
+			}
+		}
+		if nil == t || nil != t.item {
+			unmatched = append(unmatched, i)
+			continue
+		}
+		t.item = i
+		p, _ := t.rec["AbsPath"].(string)
+		images += importArtwork(cache, i, p)
+		if importRecord(t.rec, i) {
+			if err := t.col.Update(t.id, t.rec); nil != err {
+				return rcDatabaseError.specf("import(%q): Update(%d): %s", p, t.id, err)
+			}
+			updated++
+		}
+	}
+
+	rawLog.logf("%s: %d of %d exported items matched, %d media updated, %d images cached",
+		abs, len(item)-len(unmatched), len(item), updated, images)
+	// an export often covers more than this one library, so unmatched items
+	// are reported but are not an error.
+	for _, i := range unmatched {
+		rawLog.logf("  unmatched: %s", i)
+	}
+	return nil
+}
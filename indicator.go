@@ -0,0 +1,122 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: indicator.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the indicators of item and library states in the user interface.
+//    by default, most states are distinguished by color alone. with option
+//    -glyphs, each state is also marked by a distinct glyph in front of the
+//    item, so that no state relies on color (for color-blind users, or for
+//    terminals with few colors). the glyph of each state may be changed with
+//    option -glyph.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// type IndicatorKind identifies a state shown by an indicator.
+type IndicatorKind int
+
+const (
+	ikUnknown IndicatorKind = iota - 1 // = -1
+	ikCurrent                          // =  0, item under the cursor
+	ikMarked                           // =  1, item marked for batch operations
+	ikUnique                           // =  2, item found in only one of the compared libraries
+	ikOffline                          // =  3, library whose root could not be read
+	ikError                            // =  4, library with issues or over its size budget, or media whose file is missing
+	ikCOUNT                            // =  5
+)
+
+var (
+	// variable indicatorName maps the IndicatorKind enum values to the names
+	// used by option -glyph.
+	indicatorName = [ikCOUNT]string{
+		"current", "marked", "unique", "offline", "error",
+	}
+
+	// variable defaultIndicatorGlyph maps the IndicatorKind enum values to the
+	// glyph shown in front of items in that state, unless overridden by option
+	// -glyph.
+	defaultIndicatorGlyph = [ikCOUNT]string{
+		">", "*", "+", "~", "!",
+	}
+
+	// variable indicatorGlyph maps the IndicatorKind enum values to the glyph
+	// shown in front of items in that state. only the marked glyph is shown
+	// unless option -glyphs is given.
+	indicatorGlyph = defaultIndicatorGlyph
+
+	// variable isGlyphIndicators is true if states are marked by glyphs rather
+	// than by color alone (see option -glyphs).
+	isGlyphIndicators bool
+)
+
+// function parseIndicatorGlyph() parses a glyph override of the form
+// "STATE=GLYPH", where STATE is a name in indicatorName and GLYPH is a single
+// printable character.
+func parseIndicatorGlyph(spec string) (IndicatorKind, string, error) {
+
+	part := strings.SplitN(spec, "=", 2)
+	if len(part) != 2 {
+		return ikUnknown, "", fmt.Errorf("glyph %q: expected STATE=GLYPH", spec)
+	}
+	name := strings.ToLower(strings.TrimSpace(part[0]))
+	kind := ikUnknown
+	for k, n := range indicatorName {
+		if n == name {
+			kind = IndicatorKind(k)
+		}
+	}
+	if ikUnknown == kind {
+		return ikUnknown, "", fmt.Errorf("glyph %q: unknown state: %q (expected one of: %s)",
+			spec, name, strings.Join(indicatorName[:], ", "))
+	}
+	glyph := strings.TrimSpace(part[1])
+	if 1 != utf8.RuneCountInString(glyph) || strings.ContainsAny(glyph, "[]") {
+		return ikUnknown, "", fmt.Errorf("glyph %q: expected a single character other than '[' or ']'", spec)
+	}
+	return kind, glyph, nil
+}
+
+// function setIndicators() configures the indicators from the given options,
+// which must have already been validated. glyphs no longer overridden revert to
+// their defaults (e.g., when the config file is reloaded).
+func setIndicators(opt *Options) {
+	glyph := defaultIndicatorGlyph
+	for _, spec := range opt.IndicatorGlyph.StringList {
+		if kind, g, err := parseIndicatorGlyph(spec); nil == err {
+			glyph[kind] = g
+		}
+	}
+	liveMutex.Lock()
+	isGlyphIndicators = opt.GlyphIndicators.bool
+	indicatorGlyph = glyph
+	liveMutex.Unlock()
+}
+
+// function indicators() returns whether or not states are marked by glyphs,
+// along with the glyph of each state.
+func indicators() (bool, [ikCOUNT]string) {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	return isGlyphIndicators, indicatorGlyph
+}
+
+// function indicator() returns the prefix marking the given state in front of
+// an item, which is empty unless states are marked by glyphs.
+func indicator(kind IndicatorKind) string {
+	isGlyph, glyph := indicators()
+	if !isGlyph || kind <= ikUnknown || kind >= ikCOUNT {
+		return ""
+	}
+	return glyph[kind] + " "
+}
@@ -0,0 +1,537 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: bolt.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "bolt" storage engine of library databases, an embedded
+//    key/value store kept in a single file. unlike tiedot, it needs no sizing
+//    parameters fixed when the database is created, and its indices are
+//    searched case-insensitively as well as exactly. each collection is a
+//    bucket holding a bucket of JSON documents keyed by ID, and a bucket of
+//    indices, each mapping the values found at its path in the documents to
+//    the IDs of those documents.
+//
+// =============================================================================
+
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// exported constants of the bolt storage engine.
+const (
+	BoltFileName = "store.bolt" // name of the database file in the database directory
+)
+
+// local unexported constants for the bolt storage engine.
+const (
+	boltFilePerms = 0644
+	boltTimeout   = 5 * time.Second // max time waiting for another process to release the file
+	boltDocs      = "docs"          // bucket of a collection holding its documents
+	boltIndex     = "index"         // bucket of a collection holding its indices
+	boltBatchSize = 1024            // number of documents read at once by ForEachDoc()
+)
+
+// type BoltStore is a database using the bolt storage engine.
+type BoltStore struct {
+	bolt *bolt.DB
+}
+
+// type BoltCol is a collection of a BoltStore.
+type BoltCol struct {
+	store *BoltStore
+	name  []byte
+}
+
+// function openBoltStore() opens the bolt database in the given directory,
+// creating it if it doesn't exist.
+func openBoltStore(dir string) (*BoltStore, error) {
+	b, err := bolt.Open(filepath.Join(dir, BoltFileName), boltFilePerms,
+		&bolt.Options{Timeout: boltTimeout})
+	if nil != err {
+		return nil, err
+	}
+	return &BoltStore{bolt: b}, nil
+}
+
+func (s *BoltStore) Create(name string) error {
+	return s.bolt.Update(func(tx *bolt.Tx) error {
+		if nil != tx.Bucket([]byte(name)) {
+			return fmt.Errorf("collection %q already exists", name)
+		}
+		col, err := tx.CreateBucket([]byte(name))
+		if nil != err {
+			return err
+		}
+		if _, err := col.CreateBucket([]byte(boltDocs)); nil != err {
+			return err
+		}
+		_, err = col.CreateBucket([]byte(boltIndex))
+		return err
+	})
+}
+
+func (s *BoltStore) ColExists(name string) bool {
+	exists := false
+	s.bolt.View(func(tx *bolt.Tx) error {
+		exists = nil != tx.Bucket([]byte(name))
+		return nil
+	})
+	return exists
+}
+
+func (s *BoltStore) Use(name string) Collection {
+	return &BoltCol{store: s, name: []byte(name)}
+}
+
+func (s *BoltStore) AllCols() []string {
+	name := []string{}
+	s.bolt.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(n []byte, _ *bolt.Bucket) error {
+			name = append(name, string(n))
+			return nil
+		})
+	})
+	return name
+}
+
+// function Scrub() removes the documents of the named collection which cannot
+// be decoded, along with their index entries.
+func (s *BoltStore) Scrub(name string) error {
+	return s.bolt.Update(func(tx *bolt.Tx) error {
+		docs, index, err := boltBuckets(tx, []byte(name))
+		if nil != err {
+			return err
+		}
+		corrupt := [][]byte{}
+		docs.ForEach(func(k, v []byte) error {
+			var doc map[string]interface{}
+			if nil != json.Unmarshal(v, &doc) {
+				corrupt = append(corrupt, append([]byte{}, k...))
+			}
+			return nil
+		})
+		for _, k := range corrupt {
+			if err := docs.Delete(k); nil != err {
+				return err
+			}
+			id := int(binary.BigEndian.Uint64(k))
+			index.ForEach(func(path, _ []byte) error {
+				return boltUnindexID(index.Bucket(path), id)
+			})
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.bolt.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// function boltBuckets() returns the documents and indices buckets of the named
+// collection.
+func boltBuckets(tx *bolt.Tx, name []byte) (*bolt.Bucket, *bolt.Bucket, error) {
+	col := tx.Bucket(name)
+	if nil == col {
+		return nil, nil, fmt.Errorf("collection %q does not exist", name)
+	}
+	return col.Bucket([]byte(boltDocs)), col.Bucket([]byte(boltIndex)), nil
+}
+
+// function boltKey() returns the key of the document with the given ID.
+func boltKey(id int) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(id))
+	return k
+}
+
+// function boltIndexValues() returns the string form of every value found at
+// the given path in the given document. lists found along the path contribute
+// each of their elements, as with tiedot.
+func boltIndexValues(doc interface{}, path []string) []string {
+	switch t := doc.(type) {
+	case []interface{}:
+		val := []string{}
+		for _, e := range t {
+			val = append(val, boltIndexValues(e, path)...)
+		}
+		return val
+	case map[string]interface{}:
+		if 0 == len(path) {
+			break
+		}
+		if v, ok := t[path[0]]; ok {
+			return boltIndexValues(v, path[1:])
+		}
+		return nil
+	case nil:
+		return nil
+	}
+	if len(path) > 0 {
+		return nil
+	}
+	return []string{fmt.Sprint(doc)}
+}
+
+// function boltIndexKey() returns the key of an index entry, which begins with
+// the case-folded value so that lookups of either kind are prefix searches,
+// followed by the document's ID and the exact value.
+func boltIndexKey(val string, id int) []byte {
+	k := append([]byte(strings.ToLower(val)), 0)
+	k = append(k, boltKey(id)...)
+	return append(k, val...)
+}
+
+// function boltIndexDoc() adds (or removes) the index entries of the given
+// document to (or from) the given index.
+func boltIndexDoc(idx *bolt.Bucket, path []string, id int, doc map[string]interface{}, remove bool) error {
+	for _, val := range boltIndexValues(doc, path) {
+		var err error
+		if remove {
+			err = idx.Delete(boltIndexKey(val, id))
+		} else {
+			err = idx.Put(boltIndexKey(val, id), []byte{})
+		}
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// function boltUnindexID() removes every entry of the document with the given
+// ID from the given index, without knowing the document's content.
+func boltUnindexID(idx *bolt.Bucket, id int) error {
+	key := boltKey(id)
+	stale := [][]byte{}
+	idx.ForEach(func(k, _ []byte) error {
+		if z := bytes.IndexByte(k, 0); z >= 0 && len(k) >= z+9 && bytes.Equal(k[z+1:z+9], key) {
+			stale = append(stale, append([]byte{}, k...))
+		}
+		return nil
+	})
+	for _, k := range stale {
+		if err := idx.Delete(k); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// function boltIndexAll() adds (or removes) the index entries of the given
+// document to (or from) every index of its collection.
+func boltIndexAll(index *bolt.Bucket, id int, doc map[string]interface{}, remove bool) error {
+	return index.ForEach(func(path, _ []byte) error {
+		return boltIndexDoc(index.Bucket(path), strings.Split(string(path), IndexPathSep), id, doc, remove)
+	})
+}
+
+//------------------------------------------------------------------------------
+
+func (c *BoltCol) Insert(doc map[string]interface{}) (int, error) {
+	id := 0
+	err := c.store.bolt.Update(func(tx *bolt.Tx) error {
+		docs, _, err := boltBuckets(tx, c.name)
+		if nil != err {
+			return err
+		}
+		seq, err := docs.NextSequence()
+		if nil != err {
+			return err
+		}
+		id = int(seq)
+		return c.put(tx, id, doc)
+	})
+	return id, err
+}
+
+func (c *BoltCol) InsertRecovery(id int, doc map[string]interface{}) error {
+	if id < 0 {
+		return fmt.Errorf("invalid document ID: %d", id)
+	}
+	return c.store.bolt.Update(func(tx *bolt.Tx) error {
+		docs, _, err := boltBuckets(tx, c.name)
+		if nil != err {
+			return err
+		}
+		if nil != docs.Get(boltKey(id)) {
+			return fmt.Errorf("document %d already exists", id)
+		}
+		// IDs given by Insert() must never collide with those recovered.
+		if uint64(id) > docs.Sequence() {
+			if err := docs.SetSequence(uint64(id)); nil != err {
+				return err
+			}
+		}
+		return c.put(tx, id, doc)
+	})
+}
+
+// function put() stores the given document with the given ID, which must not
+// already exist, and indexes it.
+func (c *BoltCol) put(tx *bolt.Tx, id int, doc map[string]interface{}) error {
+	docs, index, err := boltBuckets(tx, c.name)
+	if nil != err {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if nil != err {
+		return err
+	}
+	if err := docs.Put(boltKey(id), data); nil != err {
+		return err
+	}
+	return boltIndexAll(index, id, doc, false)
+}
+
+// function get() reads the document with the given ID.
+func (c *BoltCol) get(tx *bolt.Tx, id int) (map[string]interface{}, error) {
+	docs, _, err := boltBuckets(tx, c.name)
+	if nil != err {
+		return nil, err
+	}
+	data := docs.Get(boltKey(id))
+	if nil == data {
+		return nil, fmt.Errorf("document %d does not exist", id)
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); nil != err {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (c *BoltCol) Read(id int) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	err := c.store.bolt.View(func(tx *bolt.Tx) error {
+		var err error
+		doc, err = c.get(tx, id)
+		return err
+	})
+	return doc, err
+}
+
+// function unput() removes the document with the given ID, which must exist,
+// and its index entries.
+func (c *BoltCol) unput(tx *bolt.Tx, id int) error {
+	docs, index, err := boltBuckets(tx, c.name)
+	if nil != err {
+		return err
+	}
+	data := docs.Get(boltKey(id))
+	if nil == data {
+		return fmt.Errorf("document %d does not exist", id)
+	}
+	prev := map[string]interface{}{}
+	if nil == json.Unmarshal(data, &prev) {
+		err = boltIndexAll(index, id, prev, true)
+	} else {
+		err = index.ForEach(func(path, _ []byte) error {
+			return boltUnindexID(index.Bucket(path), id)
+		})
+	}
+	if nil != err {
+		return err
+	}
+	return docs.Delete(boltKey(id))
+}
+
+func (c *BoltCol) Update(id int, doc map[string]interface{}) error {
+	return c.store.bolt.Update(func(tx *bolt.Tx) error {
+		if err := c.unput(tx, id); nil != err {
+			return err
+		}
+		return c.put(tx, id, doc)
+	})
+}
+
+func (c *BoltCol) Delete(id int) error {
+	return c.store.bolt.Update(func(tx *bolt.Tx) error {
+		return c.unput(tx, id)
+	})
+}
+
+func (c *BoltCol) Index(path []string) error {
+	return c.store.bolt.Update(func(tx *bolt.Tx) error {
+		docs, index, err := boltBuckets(tx, c.name)
+		if nil != err {
+			return err
+		}
+		name := []byte(strings.Join(path, IndexPathSep))
+		if nil != index.Bucket(name) {
+			return fmt.Errorf("path %q is already indexed", name)
+		}
+		idx, err := index.CreateBucket(name)
+		if nil != err {
+			return err
+		}
+		return docs.ForEach(func(k, v []byte) error {
+			doc := map[string]interface{}{}
+			if nil != json.Unmarshal(v, &doc) {
+				return nil // corrupt documents are left for Scrub()
+			}
+			return boltIndexDoc(idx, path, int(binary.BigEndian.Uint64(k)), doc, false)
+		})
+	})
+}
+
+func (c *BoltCol) Unindex(path []string) error {
+	return c.store.bolt.Update(func(tx *bolt.Tx) error {
+		_, index, err := boltBuckets(tx, c.name)
+		if nil != err {
+			return err
+		}
+		return index.DeleteBucket([]byte(strings.Join(path, IndexPathSep)))
+	})
+}
+
+func (c *BoltCol) AllIndexes() [][]string {
+	path := [][]string{}
+	c.store.bolt.View(func(tx *bolt.Tx) error {
+		_, index, err := boltBuckets(tx, c.name)
+		if nil != err {
+			return err
+		}
+		return index.ForEach(func(name, _ []byte) error {
+			path = append(path, strings.Split(string(name), IndexPathSep))
+			return nil
+		})
+	})
+	return path
+}
+
+// function ForEachDoc() calls the given function with each document of the
+// collection until it returns false. documents are read in batches, and the
+// function is called outside of any transaction, so it may modify the
+// database (unlike with tiedot, whose collection remains locked).
+func (c *BoltCol) ForEachDoc(fun func(id int, doc []byte) (moveOn bool)) {
+
+	type entry struct {
+		id  int
+		doc []byte
+	}
+	var next []byte
+	for {
+		batch := []entry{}
+		c.store.bolt.View(func(tx *bolt.Tx) error {
+			docs, _, err := boltBuckets(tx, c.name)
+			if nil != err {
+				return err
+			}
+			cur := docs.Cursor()
+			k, v := cur.First()
+			if nil != next {
+				k, v = cur.Seek(next)
+			}
+			for ; nil != k && len(batch) < boltBatchSize; k, v = cur.Next() {
+				batch = append(batch, entry{int(binary.BigEndian.Uint64(k)), append([]byte{}, v...)})
+			}
+			next = nil
+			if nil != k {
+				next = append([]byte{}, k...)
+			}
+			return nil
+		})
+		for _, e := range batch {
+			if !fun(e.id, e.doc) {
+				return
+			}
+		}
+		if nil == next {
+			return
+		}
+	}
+}
+
+func (c *BoltCol) ApproxDocCount() int {
+	count := 0
+	c.store.bolt.View(func(tx *bolt.Tx) error {
+		if docs, _, err := boltBuckets(tx, c.name); nil == err {
+			count = docs.Stats().KeyN
+		}
+		return nil
+	})
+	return count
+}
+
+func (c *BoltCol) Query(q interface{}, result *map[int]struct{}) error {
+	return c.store.bolt.View(func(tx *bolt.Tx) error {
+		docs, index, err := boltBuckets(tx, c.name)
+		if nil != err {
+			return err
+		}
+		match, err := boltEval(q, docs, index)
+		if nil != err {
+			return err
+		}
+		for id := range match {
+			(*result)[id] = struct{}{}
+		}
+		return nil
+	})
+}
+
+// function boltEval() evaluates the given query (see method Query() of type
+// Collection) on the given collection buckets, returning the matching IDs.
+func boltEval(q interface{}, docs, index *bolt.Bucket) (map[int]struct{}, error) {
+	return queryEval(q, func(path []string, val string, exact bool) map[int]struct{} {
+		return boltLookup(path, val, exact, docs, index)
+	})
+}
+
+// function boltLookup() returns the IDs of the documents whose value at the
+// given path equals the given value, either exactly or case-insensitively.
+// paths which are not indexed are searched by reading every document.
+func boltLookup(path []string, want string, exact bool, docs, index *bolt.Bucket) map[int]struct{} {
+
+	equal := func(s string) bool {
+		if exact {
+			return s == want
+		}
+		return strings.EqualFold(s, want)
+	}
+
+	match := map[int]struct{}{}
+	idx := index.Bucket([]byte(strings.Join(path, IndexPathSep)))
+	if nil == idx {
+		docs.ForEach(func(k, v []byte) error {
+			doc := map[string]interface{}{}
+			if nil != json.Unmarshal(v, &doc) {
+				return nil
+			}
+			for _, s := range boltIndexValues(doc, path) {
+				if equal(s) {
+					match[int(binary.BigEndian.Uint64(k))] = struct{}{}
+					break
+				}
+			}
+			return nil
+		})
+		return match
+	}
+
+	prefix := append([]byte(strings.ToLower(want)), 0)
+	cur := idx.Cursor()
+	for k, _ := cur.Seek(prefix); nil != k && bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		rest := k[len(prefix):]
+		if len(rest) < 8 || !equal(string(rest[8:])) {
+			continue
+		}
+		match[int(binary.BigEndian.Uint64(rest[:8]))] = struct{}{}
+	}
+	return match
+}
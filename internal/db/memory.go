@@ -0,0 +1,401 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: memory.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the ephemeral "memory" storage engine of library databases, whose
+//    records are kept in memory and discarded when the database is closed.
+//
+//    each collection of the engine holds its documents encoded as JSON, as
+//    the other engines do (so that no caller may modify a stored document),
+//    and its indices, each mapping the case-folded values found at its path
+//    in the documents to the IDs of those documents.
+//
+// =============================================================================
+
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// type MemoryStore is a database using the memory storage engine.
+type MemoryStore struct {
+	sync.RWMutex
+	col map[string]*memoryColData
+}
+
+// type MemoryCol is a collection of a MemoryStore.
+type MemoryCol struct {
+	store *MemoryStore
+	name  string
+}
+
+// type memoryColData holds the documents and indices of a collection.
+type memoryColData struct {
+	seq   int                                    // greatest ID of any document inserted
+	doc   map[int][]byte                         // JSON documents keyed by ID
+	index map[string]map[string]map[int][]string // path => case-folded value => ID => exact values
+}
+
+// function NewMemoryStore() creates a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{col: map[string]*memoryColData{}}
+}
+
+func (s *MemoryStore) Create(name string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.col[name]; ok {
+		return fmt.Errorf("collection %q already exists", name)
+	}
+	s.col[name] = &memoryColData{
+		seq:   0,
+		doc:   map[int][]byte{},
+		index: map[string]map[string]map[int][]string{},
+	}
+	return nil
+}
+
+func (s *MemoryStore) ColExists(name string) bool {
+	s.RLock()
+	defer s.RUnlock()
+	_, ok := s.col[name]
+	return ok
+}
+
+func (s *MemoryStore) Use(name string) Collection {
+	return &MemoryCol{store: s, name: name}
+}
+
+func (s *MemoryStore) AllCols() []string {
+	s.RLock()
+	defer s.RUnlock()
+	name := []string{}
+	for n := range s.col {
+		name = append(name, n)
+	}
+	sort.Strings(name)
+	return name
+}
+
+// function Scrub() removes the documents of the named collection which cannot
+// be decoded, along with their index entries.
+func (s *MemoryStore) Scrub(name string) error {
+	s.Lock()
+	defer s.Unlock()
+	c, ok := s.col[name]
+	if !ok {
+		return fmt.Errorf("collection %q does not exist", name)
+	}
+	for id, data := range c.doc {
+		var doc map[string]interface{}
+		if nil != json.Unmarshal(data, &doc) {
+			c.unput(id)
+		}
+	}
+	return nil
+}
+
+// function Close() discards every collection of the database.
+func (s *MemoryStore) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	s.col = map[string]*memoryColData{}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// function indexDoc() adds (or removes) the index entries of the given document
+// to (or from) the index of the given path.
+func (c *memoryColData) indexDoc(path string, id int, doc map[string]interface{}, remove bool) {
+	idx := c.index[path]
+	for _, val := range boltIndexValues(doc, strings.Split(path, IndexPathSep)) {
+		key := strings.ToLower(val)
+		if remove {
+			delete(idx[key], id)
+			if 0 == len(idx[key]) {
+				delete(idx, key)
+			}
+			continue
+		}
+		if nil == idx[key] {
+			idx[key] = map[int][]string{}
+		}
+		idx[key][id] = append(idx[key][id], val)
+	}
+}
+
+// function put() stores the given document with the given ID, which must not
+// already exist, and indexes it.
+func (c *memoryColData) put(id int, doc map[string]interface{}) error {
+	data, err := json.Marshal(doc)
+	if nil != err {
+		return err
+	}
+	c.doc[id] = data
+	for path := range c.index {
+		c.indexDoc(path, id, doc, false)
+	}
+	return nil
+}
+
+// function unput() removes the document with the given ID, which must exist,
+// and its index entries.
+func (c *memoryColData) unput(id int) error {
+	data, ok := c.doc[id]
+	if !ok {
+		return fmt.Errorf("document %d does not exist", id)
+	}
+	prev := map[string]interface{}{}
+	if nil == json.Unmarshal(data, &prev) {
+		for path := range c.index {
+			c.indexDoc(path, id, prev, true)
+		}
+	} else {
+		for _, idx := range c.index {
+			for key, ids := range idx {
+				delete(ids, id)
+				if 0 == len(ids) {
+					delete(idx, key)
+				}
+			}
+		}
+	}
+	delete(c.doc, id)
+	return nil
+}
+
+// function data() returns the documents and indices of the collection. the
+// caller must hold the lock of its store.
+func (c *MemoryCol) data() (*memoryColData, error) {
+	d, ok := c.store.col[c.name]
+	if !ok {
+		return nil, fmt.Errorf("collection %q does not exist", c.name)
+	}
+	return d, nil
+}
+
+func (c *MemoryCol) Insert(doc map[string]interface{}) (int, error) {
+	c.store.Lock()
+	defer c.store.Unlock()
+	d, err := c.data()
+	if nil != err {
+		return 0, err
+	}
+	d.seq++
+	return d.seq, d.put(d.seq, doc)
+}
+
+func (c *MemoryCol) InsertRecovery(id int, doc map[string]interface{}) error {
+	if id < 0 {
+		return fmt.Errorf("invalid document ID: %d", id)
+	}
+	c.store.Lock()
+	defer c.store.Unlock()
+	d, err := c.data()
+	if nil != err {
+		return err
+	}
+	if _, ok := d.doc[id]; ok {
+		return fmt.Errorf("document %d already exists", id)
+	}
+	// IDs given by Insert() must never collide with those recovered.
+	if id > d.seq {
+		d.seq = id
+	}
+	return d.put(id, doc)
+}
+
+func (c *MemoryCol) Read(id int) (map[string]interface{}, error) {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	d, err := c.data()
+	if nil != err {
+		return nil, err
+	}
+	data, ok := d.doc[id]
+	if !ok {
+		return nil, fmt.Errorf("document %d does not exist", id)
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); nil != err {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (c *MemoryCol) Update(id int, doc map[string]interface{}) error {
+	c.store.Lock()
+	defer c.store.Unlock()
+	d, err := c.data()
+	if nil != err {
+		return err
+	}
+	if err := d.unput(id); nil != err {
+		return err
+	}
+	return d.put(id, doc)
+}
+
+func (c *MemoryCol) Delete(id int) error {
+	c.store.Lock()
+	defer c.store.Unlock()
+	d, err := c.data()
+	if nil != err {
+		return err
+	}
+	return d.unput(id)
+}
+
+func (c *MemoryCol) Index(path []string) error {
+	c.store.Lock()
+	defer c.store.Unlock()
+	d, err := c.data()
+	if nil != err {
+		return err
+	}
+	name := strings.Join(path, IndexPathSep)
+	if _, ok := d.index[name]; ok {
+		return fmt.Errorf("path %q is already indexed", name)
+	}
+	d.index[name] = map[string]map[int][]string{}
+	for id, data := range d.doc {
+		doc := map[string]interface{}{}
+		if nil != json.Unmarshal(data, &doc) {
+			continue // corrupt documents are left for Scrub()
+		}
+		d.indexDoc(name, id, doc, false)
+	}
+	return nil
+}
+
+func (c *MemoryCol) Unindex(path []string) error {
+	c.store.Lock()
+	defer c.store.Unlock()
+	d, err := c.data()
+	if nil != err {
+		return err
+	}
+	name := strings.Join(path, IndexPathSep)
+	if _, ok := d.index[name]; !ok {
+		return fmt.Errorf("path %q is not indexed", name)
+	}
+	delete(d.index, name)
+	return nil
+}
+
+func (c *MemoryCol) AllIndexes() [][]string {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	path := [][]string{}
+	if d, err := c.data(); nil == err {
+		for name := range d.index {
+			path = append(path, strings.Split(name, IndexPathSep))
+		}
+	}
+	return path
+}
+
+// function ForEachDoc() calls the given function with each document of the
+// collection, in order of ID, until it returns false. the function is called
+// without holding the lock of the store, so it may modify the database (as
+// with bolt).
+func (c *MemoryCol) ForEachDoc(fun func(id int, doc []byte) (moveOn bool)) {
+
+	c.store.RLock()
+	d, err := c.data()
+	if nil != err {
+		c.store.RUnlock()
+		return
+	}
+	id := make([]int, 0, len(d.doc))
+	doc := make(map[int][]byte, len(d.doc))
+	for i, data := range d.doc {
+		id = append(id, i)
+		doc[i] = data // never modified, only replaced
+	}
+	c.store.RUnlock()
+
+	sort.Ints(id)
+	for _, i := range id {
+		if !fun(i, doc[i]) {
+			return
+		}
+	}
+}
+
+func (c *MemoryCol) ApproxDocCount() int {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	if d, err := c.data(); nil == err {
+		return len(d.doc)
+	}
+	return 0
+}
+
+func (c *MemoryCol) Query(q interface{}, result *map[int]struct{}) error {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	d, err := c.data()
+	if nil != err {
+		return err
+	}
+	match, err := queryEval(q, d.lookup)
+	if nil != err {
+		return err
+	}
+	for id := range match {
+		(*result)[id] = struct{}{}
+	}
+	return nil
+}
+
+// function lookup() returns the IDs of the documents whose value at the given
+// path equals the given value, either exactly or case-insensitively. paths
+// which are not indexed are searched by reading every document.
+func (c *memoryColData) lookup(path []string, want string, exact bool) map[int]struct{} {
+
+	equal := func(s string) bool {
+		if exact {
+			return s == want
+		}
+		return strings.EqualFold(s, want)
+	}
+
+	match := map[int]struct{}{}
+	idx, ok := c.index[strings.Join(path, IndexPathSep)]
+	if !ok {
+		for id, data := range c.doc {
+			doc := map[string]interface{}{}
+			if nil != json.Unmarshal(data, &doc) {
+				continue
+			}
+			for _, s := range boltIndexValues(doc, path) {
+				if equal(s) {
+					match[id] = struct{}{}
+					break
+				}
+			}
+		}
+		return match
+	}
+
+	for id, val := range idx[strings.ToLower(want)] {
+		for _, s := range val {
+			if equal(s) {
+				match[id] = struct{}{}
+				break
+			}
+		}
+	}
+	return match
+}
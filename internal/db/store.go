@@ -0,0 +1,160 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: store.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    package db defines the storage engines of the library databases, behind
+//    the interfaces Store and Collection: tiedot, bolt, and the ephemeral
+//    memory engine. the engines know nothing of media or libraries; they only
+//    hold named collections of JSON documents and their indices. the library
+//    databases built on them are defined by package main (see database.go).
+//
+// =============================================================================
+
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"ardnew.com/goutil"
+	tiedot "github.com/HouzuoGuo/tiedot/db"
+)
+
+// exported constants identifying the storage engines.
+const (
+	EngineTiedot = "tiedot" // one directory per collection, configured by data-config.json
+	EngineBolt   = "bolt"   // a single file, see bolt.go
+	EngineMemory = "memory" // nothing persists, see memory.go
+
+	// separator of the field names of an index path, when joined to name it.
+	IndexPathSep = tiedot.INDEX_PATH_SEP
+)
+
+// type Store is the storage engine of a library database, holding any number of
+// named collections of JSON documents. its methods follow those of tiedot's
+// db.DB, which was the only engine for a long time.
+type Store interface {
+	Create(name string) error   // creates the named collection
+	ColExists(name string) bool // returns true if the named collection exists
+	Use(name string) Collection // returns the named collection
+	AllCols() []string          // returns the name of every collection
+	Scrub(name string) error    // removes corrupt documents of the named collection
+	Close() error
+}
+
+// type Collection is a collection of JSON documents in a Store, each identified
+// by an integer ID, and the indices on the paths of its documents' fields.
+type Collection interface {
+	Insert(doc map[string]interface{}) (int, error)
+	InsertRecovery(id int, doc map[string]interface{}) error // inserts a document with the given ID
+	Read(id int) (map[string]interface{}, error)
+	Update(id int, doc map[string]interface{}) error
+	Delete(id int) error
+	Index(path []string) error
+	Unindex(path []string) error
+	AllIndexes() [][]string
+	ForEachDoc(fun func(id int, doc []byte) (moveOn bool))
+	ApproxDocCount() int
+	// evaluates a query in tiedot's query language, adding the ID of each
+	// matching document to the result set. only "eq" lookups combined by union
+	// (a list) and intersection ("n") are used, along with "eqfold" lookups,
+	// which are case-insensitive where the engine supports it.
+	Query(q interface{}, result *map[int]struct{}) error
+}
+
+// function Engine() returns the storage engine of the existing database at the
+// given path.
+func Engine(path string) string {
+	if exists, _ := goutil.PathExists(filepath.Join(path, BoltFileName)); exists {
+		return EngineBolt
+	}
+	return EngineTiedot
+}
+
+// function Open() opens the database at the given path with the given storage
+// engine, creating it if it doesn't exist. the memory engine has no path, see
+// function NewMemoryStore().
+func Open(path, engine string) (Store, error) {
+	switch engine {
+	case EngineBolt:
+		return openBoltStore(path)
+	case EngineTiedot:
+		return openTiedotStore(path)
+	}
+	return nil, fmt.Errorf("unknown storage engine: %q", engine)
+}
+
+// function queryEval() evaluates the given query (see method Query() of type
+// Collection) for engines which cannot evaluate it themselves, returning the
+// matching IDs. the given function looks up the IDs of the documents whose
+// value at the given path equals the given value, either exactly or
+// case-insensitively.
+func queryEval(q interface{}, lookup func(path []string, val string, exact bool) map[int]struct{}) (map[int]struct{}, error) {
+
+	switch t := q.(type) {
+	case []interface{}:
+		// union of each subquery.
+		union := map[int]struct{}{}
+		for _, sub := range t {
+			match, err := queryEval(sub, lookup)
+			if nil != err {
+				return nil, err
+			}
+			for id := range match {
+				union[id] = struct{}{}
+			}
+		}
+		return union, nil
+
+	case map[string]interface{}:
+		if sub, ok := t["n"]; ok {
+			// intersection of each subquery.
+			list, ok := sub.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expecting a list of subqueries: %v", sub)
+			}
+			var inter map[int]struct{}
+			for _, s := range list {
+				match, err := queryEval(s, lookup)
+				if nil != err {
+					return nil, err
+				}
+				if nil == inter {
+					inter = match
+					continue
+				}
+				for id := range inter {
+					if _, ok := match[id]; !ok {
+						delete(inter, id)
+					}
+				}
+			}
+			if nil == inter {
+				inter = map[int]struct{}{}
+			}
+			return inter, nil
+		}
+		val, exact := t["eq"]
+		if !exact {
+			fold, ok := t["eqfold"]
+			if !ok {
+				return nil, fmt.Errorf("unsupported query: %v", q)
+			}
+			val = fold
+		}
+		list, ok := t["in"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expecting a path as a list of strings: %v", t["in"])
+		}
+		path := make([]string, len(list))
+		for i, p := range list {
+			path[i] = fmt.Sprint(p)
+		}
+		return lookup(path, fmt.Sprint(val), exact), nil
+	}
+	return nil, fmt.Errorf("unsupported query: %v", q)
+}
@@ -0,0 +1,149 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: store_test.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    tests of the storage engines implemented by this package, each of which
+//    must behave the same through the interfaces Store and Collection. tiedot
+//    is tested by its own package; only its adapter is tested here.
+//
+// =============================================================================
+
+package db
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// function testStores() returns a new, empty store of each storage engine
+// implemented by this package.
+func testStores(t *testing.T) map[string]Store {
+	bolt, err := Open(t.TempDir(), EngineBolt)
+	if nil != err {
+		t.Fatalf("Open(%q): %s", EngineBolt, err)
+	}
+	return map[string]Store{EngineMemory: NewMemoryStore(), EngineBolt: bolt}
+}
+
+// function queryIDs() returns the sorted IDs of the documents of the given
+// collection matching the given query.
+func queryIDs(t *testing.T, col Collection, q interface{}) []int {
+	result := map[int]struct{}{}
+	if err := col.Query(q, &result); nil != err {
+		t.Fatalf("Query(%v): %s", q, err)
+	}
+	id := []int{}
+	for i := range result {
+		id = append(id, i)
+	}
+	sort.Ints(id)
+	return id
+}
+
+// function TestStoreEngines() stores, indexes, queries, updates and deletes the
+// same documents with each storage engine.
+func TestStoreEngines(t *testing.T) {
+
+	for engine, store := range testStores(t) {
+		t.Run(engine, func(t *testing.T) {
+			defer store.Close()
+
+			if err := store.Create("media"); nil != err {
+				t.Fatalf("Create(): %s", err)
+			}
+			if !store.ColExists("media") || store.ColExists("other") {
+				t.Fatalf("ColExists(): unexpected result: %v", store.AllCols())
+			}
+			col := store.Use("media")
+			if err := col.Index([]string{"Name"}); nil != err {
+				t.Fatalf("Index(): %s", err)
+			}
+
+			id := []int{}
+			for _, name := range []string{"Alpha", "beta", "Beta"} {
+				i, err := col.Insert(map[string]interface{}{"Name": name, "Tags": []interface{}{"a", name}})
+				if nil != err {
+					t.Fatalf("Insert(%q): %s", name, err)
+				}
+				id = append(id, i)
+			}
+			if n := col.ApproxDocCount(); n != len(id) {
+				t.Errorf("ApproxDocCount(): expected %d, got %d", len(id), n)
+			}
+
+			// exact lookups, on indexed and unindexed paths alike.
+			eq := func(val string, path ...interface{}) map[string]interface{} {
+				return map[string]interface{}{"eq": val, "in": path}
+			}
+			if got := queryIDs(t, col, eq("beta", "Name")); 1 != len(got) || id[1] != got[0] {
+				t.Errorf("eq Name: expected [%d], got %v", id[1], got)
+			}
+			if got := queryIDs(t, col, eq("Beta", "Tags")); 1 != len(got) || id[2] != got[0] {
+				t.Errorf("eq Tags: expected [%d], got %v", id[2], got)
+			}
+			// union and intersection.
+			if got := queryIDs(t, col, []interface{}{eq("Alpha", "Name"), eq("beta", "Name")}); 2 != len(got) {
+				t.Errorf("union: expected 2 matches, got %v", got)
+			}
+			inter := map[string]interface{}{"n": []interface{}{eq("a", "Tags"), eq("Alpha", "Name")}}
+			if got := queryIDs(t, col, inter); 1 != len(got) || id[0] != got[0] {
+				t.Errorf("intersection: expected [%d], got %v", id[0], got)
+			}
+			// case-insensitive lookups.
+			fold := map[string]interface{}{"eqfold": "BETA", "in": []interface{}{"Name"}}
+			if got := queryIDs(t, col, fold); 2 != len(got) {
+				t.Errorf("eqfold: expected 2 matches, got %v", got)
+			}
+
+			// updates replace the index entries of the document.
+			if err := col.Update(id[0], map[string]interface{}{"Name": "Gamma"}); nil != err {
+				t.Fatalf("Update(): %s", err)
+			}
+			if got := queryIDs(t, col, eq("Alpha", "Name")); 0 != len(got) {
+				t.Errorf("eq after Update(): expected no matches, got %v", got)
+			}
+			if doc, err := col.Read(id[0]); nil != err || "Gamma" != doc["Name"] {
+				t.Errorf("Read() after Update(): got %v (%v)", doc, err)
+			}
+
+			if err := col.Delete(id[1]); nil != err {
+				t.Fatalf("Delete(): %s", err)
+			}
+			if _, err := col.Read(id[1]); nil == err {
+				t.Errorf("Read() after Delete(): expected an error")
+			}
+			count := 0
+			col.ForEachDoc(func(int, []byte) bool { count++; return true })
+			if 2 != count {
+				t.Errorf("ForEachDoc(): expected 2 documents, got %d", count)
+			}
+		})
+	}
+}
+
+// function TestCaseQuery() verifies the queries given to tiedot replace every
+// case-insensitive lookup with an exact one, leaving the query given intact.
+func TestCaseQuery(t *testing.T) {
+
+	fold := map[string]interface{}{"eqfold": "x", "in": []interface{}{"Name"}}
+	q := []interface{}{
+		fold,
+		map[string]interface{}{"n": []interface{}{fold}},
+	}
+	eq := map[string]interface{}{"eq": "x", "in": []interface{}{"Name"}}
+	expected := []interface{}{
+		eq,
+		map[string]interface{}{"n": []interface{}{eq}},
+	}
+	if got := caseQuery(q); !reflect.DeepEqual(expected, got) {
+		t.Errorf("caseQuery(): expected %v, got %v", expected, got)
+	}
+	if _, ok := fold["eqfold"]; !ok {
+		t.Errorf("caseQuery(): modified the given query: %v", fold)
+	}
+}
@@ -0,0 +1,76 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: tiedot.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    adapts the "tiedot" storage engine of library databases to the Store and
+//    Collection interfaces. tiedot keeps one directory per collection, sized by
+//    parameters fixed when the database is created, and its hash indices can
+//    only be searched case-sensitively.
+//
+// =============================================================================
+
+package db
+
+import (
+	tiedot "github.com/HouzuoGuo/tiedot/db"
+)
+
+// type tiedotStore adapts a tiedot database to the Store interface.
+type tiedotStore struct {
+	*tiedot.DB
+}
+
+// function openTiedotStore() opens the tiedot database in the given directory,
+// creating it if it doesn't exist.
+func openTiedotStore(dir string) (*tiedotStore, error) {
+	store, err := tiedot.OpenDB(dir)
+	if nil != err {
+		return nil, err
+	}
+	return &tiedotStore{store}, nil
+}
+
+func (s *tiedotStore) Use(name string) Collection {
+	return &tiedotCol{s.DB.Use(name)}
+}
+
+// type tiedotCol adapts a tiedot collection to the Collection interface.
+type tiedotCol struct {
+	*tiedot.Col
+}
+
+func (c *tiedotCol) Query(q interface{}, result *map[int]struct{}) error {
+	return tiedot.EvalQuery(caseQuery(q), c.Col, result)
+}
+
+// function caseQuery() returns a copy of the given query with every "eqfold"
+// lookup replaced by a (case-sensitive) "eq" lookup, since tiedot's hash
+// indices cannot be searched case-insensitively.
+func caseQuery(q interface{}) interface{} {
+	switch t := q.(type) {
+	case []interface{}:
+		sub := make([]interface{}, len(t))
+		for i, e := range t {
+			sub[i] = caseQuery(e)
+		}
+		return sub
+	case map[string]interface{}:
+		sub := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			switch k {
+			case "eqfold":
+				sub["eq"] = v
+			case "n":
+				sub[k] = caseQuery(v)
+			default:
+				sub[k] = v
+			}
+		}
+		return sub
+	}
+	return q
+}
@@ -0,0 +1,232 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: interrupt.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the interruption of loads and scans in progress, by the user or
+//    on exit, and the checkpoint which lets an interrupted scan resume where
+//    it left off. the directories completed by a scan (i.e., all of their
+//    contents examined) are recorded when it is interrupted, alongside the
+//    library database, and are skipped by the next scan of the library, even
+//    after a restart. the checkpoint is removed once a scan completes.
+//
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+// local unexported constants for the interruption of loads and scans.
+const (
+	checkpointFileName = "checkpoint.json"
+	interruptTimeout   = 5 * time.Second // max time to wait on exit for scans to checkpoint
+)
+
+// type Interrupt holds the cancellation functions of the loads and scans of a
+// library in progress.
+type Interrupt struct {
+	sync.Mutex
+	cancel  map[int]context.CancelFunc
+	next    int
+	running sync.WaitGroup
+}
+
+// function newInterrupt() creates a new Interrupt with nothing in progress.
+func newInterrupt() *Interrupt {
+	return &Interrupt{cancel: map[int]context.CancelFunc{}}
+}
+
+// function interruptible() returns a context, derived from the given context,
+// which is cancelled when the library's loads and scans are interrupted. the
+// returned function must be called once the operation has finished.
+func (l *Library) interruptible(parent context.Context) (context.Context, func()) {
+
+	ctx, cancel := context.WithCancel(parent)
+	in := l.interrupt
+	if nil == in {
+		return ctx, cancel
+	}
+	in.Lock()
+	id := in.next
+	in.next++
+	in.cancel[id] = cancel
+	in.running.Add(1)
+	in.Unlock()
+
+	return ctx, func() {
+		in.Lock()
+		delete(in.cancel, id)
+		in.Unlock()
+		cancel()
+		in.running.Done()
+	}
+}
+
+// function interruptAll() cancels every load and scan of the library in
+// progress. returns true if anything was in progress.
+func (l *Library) interruptAll() bool {
+
+	if nil == l.interrupt {
+		return false
+	}
+	l.interrupt.Lock()
+	defer l.interrupt.Unlock()
+	for _, cancel := range l.interrupt.cancel {
+		cancel()
+	}
+	return len(l.interrupt.cancel) > 0
+}
+
+// function awaitInterrupted() waits until every load and scan of the given
+// libraries has returned, or until the given timeout elapses. returns false if
+// the timeout elapsed first.
+func awaitInterrupted(library []*Library, timeout time.Duration) bool {
+
+	done := make(chan bool)
+	go func() {
+		for _, l := range library {
+			if nil != l.interrupt {
+				l.interrupt.running.Wait()
+			}
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// type ScanCheckpoint is the record of an interrupted scan, stored alongside
+// the library database.
+type ScanCheckpoint struct {
+	Time time.Time `json:"time"` // when the scan was interrupted
+	Last string    `json:"last"` // directory most recently completed
+	Done []string  `json:"done"` // all directories completed (relative to library root)
+}
+
+// type Checkpoint tracks the directories completed by the current scan, and
+// those completed by the interrupted scan it resumes, keyed by path relative to
+// the library root.
+type Checkpoint struct {
+	sync.Mutex
+	resumed map[string]bool
+	done    map[string]bool
+	last    string
+}
+
+// function openCheckpoint() reads the checkpoint of the library's most recent
+// scan, if it was interrupted, and returns the Checkpoint of a new scan which
+// resumes it.
+func (l *Library) openCheckpoint() *Checkpoint {
+
+	cp := &Checkpoint{
+		resumed: map[string]bool{},
+		done:    map[string]bool{},
+	}
+	var prev ScanCheckpoint
+	found, ret := readJSONFile(filepath.Join(l.db.absPath, checkpointFileName), &prev)
+	if nil != ret {
+		// a corrupt checkpoint only costs us the work already done.
+		warnLog.trace(ret)
+		return cp
+	}
+	if !found {
+		return cp
+	}
+	for _, rel := range prev.Done {
+		cp.resumed[rel] = true
+	}
+	cp.last = prev.Last
+	infoLog.logf("resuming scan of %q interrupted %s after %q (%d folders already scanned)",
+		l.name, prev.Time.Format("2006-01-02 15:04"), prev.Last, len(prev.Done))
+	return cp
+}
+
+// function saveCheckpoint() records the directories completed by the current
+// scan and by the scan it resumed, so that the next scan resumes from there.
+func (l *Library) saveCheckpoint(cp *Checkpoint) *ReturnCode {
+
+	cp.Lock()
+	rec := ScanCheckpoint{Time: time.Now(), Last: cp.last, Done: []string{}}
+	for rel := range cp.resumed {
+		rec.Done = append(rec.Done, rel)
+	}
+	for rel := range cp.done {
+		if !cp.resumed[rel] {
+			rec.Done = append(rec.Done, rel)
+		}
+	}
+	cp.Unlock()
+	sort.Strings(rec.Done)
+
+	return writeJSONFile(filepath.Join(l.db.absPath, checkpointFileName), rec)
+}
+
+// function removeCheckpoint() removes the checkpoint of an interrupted scan,
+// once a scan has completed.
+func (l *Library) removeCheckpoint() {
+	if err := os.Remove(filepath.Join(l.db.absPath, checkpointFileName)); nil != err && !os.IsNotExist(err) {
+		warnLog.trace(rcInvalidFile.wrap(err, "removeCheckpoint(): %q", l.name))
+	}
+}
+
+// function skip() returns true if the given directory was completed by the
+// interrupted scan being resumed.
+func (cp *Checkpoint) skip(rel string) bool {
+
+	if nil == cp {
+		return false
+	}
+	cp.Lock()
+	defer cp.Unlock()
+	return cp.resumed[rel]
+}
+
+// function complete() records that all contents of the given directory have
+// been scanned.
+func (cp *Checkpoint) complete(rel string) {
+
+	if nil == cp {
+		return
+	}
+	cp.Lock()
+	defer cp.Unlock()
+	cp.done[rel] = true
+	cp.last = rel
+}
+
+// function interruptEvent() handles the key interrupting the scans of all
+// libraries in progress. returns true if the key was handled.
+func (l *Layout) interruptEvent(ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'K' != er {
+		return false
+	}
+	interrupted := false
+	for _, lib := range l.lib {
+		if lib.isScanning() && lib.interruptAll() {
+			interrupted = true
+		}
+	}
+	if !interrupted {
+		warnLog.logf("(ignored) no scan in progress")
+		return true
+	}
+	infoLog.logf("interrupting scans (they will resume where they left off on the next scan)")
+	return true
+}
@@ -0,0 +1,383 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: issues.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the issues panel, which collects the problems encountered while
+//    scanning each library (unreadable directories, skipped files, etc.) so
+//    that they can be reviewed once the scan has finished, rather than only
+//    scrolling by in the log. issues are grouped by kind and by directory, and
+//    each may be jumped to in the media browser or retried.
+//
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// type ScanIssue is a single problem encountered while scanning some path.
+type ScanIssue struct {
+	kind    string    // general description of the problem (of the ReturnCode)
+	absPath string    // absolute path of the file or directory
+	relPath string    // library-relative path of the file or directory
+	reason  string    // detailed description of the problem
+	time    time.Time // time at which the problem was (most recently) encountered
+	sidecar string    // checksum file listing the file (checksum issues only, see checksum.go)
+}
+
+// type IssueLog holds the issues encountered by a library's most recent scan,
+// along with the handler used by that scan so that issues may be retried. it is
+// safe for concurrent use.
+type IssueLog struct {
+	*sync.Mutex
+	issue   []*ScanIssue // issues in order of discovery
+	handler *PathHandler // handler of the most recent scan (nil if never scanned)
+	retried uint         // number of issues retried since the scan began
+	cleared uint         // number of retried issues which no longer occur
+}
+
+// function newIssueLog() creates an empty IssueLog.
+func newIssueLog() *IssueLog {
+	return &IssueLog{
+		Mutex:   new(sync.Mutex),
+		issue:   []*ScanIssue{},
+		handler: nil,
+		retried: 0,
+		cleared: 0,
+	}
+}
+
+// function begin() discards the issues of the previous scan. it is called each
+// time a scan of the library begins.
+func (g *IssueLog) begin(handler *PathHandler) {
+	g.Lock()
+	defer g.Unlock()
+	g.issue = []*ScanIssue{}
+	g.handler = handler
+	g.retried = 0
+	g.cleared = 0
+}
+
+// function recordIssue() adds an issue for the given path of the library.
+// exceeding the max traversal depth is intentional, so it is not an issue.
+func (l *Library) recordIssue(absPath string, ret *ReturnCode) {
+	l.recordChecksumIssue("", absPath, ret)
+}
+
+// function recordChecksumIssue() adds an issue for the given path of the
+// library, listed by the given checksum file (if not empty), which is verified
+// again rather than scanned when the issue is retried.
+func (l *Library) recordChecksumIssue(sidecar, absPath string, ret *ReturnCode) {
+
+	if nil == ret || ret.is(rcDirDepth) {
+		return
+	}
+	rel, err := filepath.Rel(l.absPath, absPath)
+	if nil != err {
+		rel = absPath
+	}
+
+	g := l.issues
+	g.Lock()
+	defer g.Unlock()
+	g.issue = append(g.issue, &ScanIssue{
+		kind:    ret.desc,
+		absPath: absPath,
+		relPath: rel,
+		reason:  ret.Error(),
+		time:    time.Now(),
+		sidecar: sidecar,
+	})
+}
+
+// function issueList() returns a copy of the library's current issues, sorted
+// by kind, then by directory, then by path.
+func (l *Library) issueList() []*ScanIssue {
+
+	g := l.issues
+	g.Lock()
+	list := make([]*ScanIssue, len(g.issue))
+	copy(list, g.issue)
+	g.Unlock()
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].kind != list[j].kind {
+			return list[i].kind < list[j].kind
+		}
+		if di, dj := filepath.Dir(list[i].relPath), filepath.Dir(list[j].relPath); di != dj {
+			return di < dj
+		}
+		return list[i].relPath < list[j].relPath
+	})
+	return list
+}
+
+// function retryIssue() scans the path of the given issue again, using the
+// handler of the library's most recent scan (or verifies its checksum again,
+// if it is a checksum issue). the issue is removed if the path is scanned
+// without error, otherwise it is updated with the new error.
+func (l *Library) retryIssue(issue *ScanIssue) *ReturnCode {
+
+	// occupy the scanner semaphore, since this is a (very small) scan.
+	select {
+	case l.scanStart <- time.Now():
+		defer func() { <-l.scanStart }()
+	default:
+		return rcLibraryBusy.specf("retryIssue(): library is scanning: %q", l.name)
+	}
+
+	// forget the issues beneath a directory being retried, since any that
+	// still occur will be recorded again.
+	g := l.issues
+	g.Lock()
+	handler := g.handler
+	dir := strings.TrimSuffix(issue.absPath, "/") + "/"
+	keep := []*ScanIssue{}
+	for _, is := range g.issue {
+		if !strings.HasPrefix(is.absPath, dir) {
+			keep = append(keep, is)
+		}
+	}
+	g.issue = keep
+	g.Unlock()
+
+	var ret *ReturnCode
+	if "" != issue.sidecar {
+		ret = verifyChecksumEntry(issue.sidecar, issue.absPath)
+	} else {
+		// the library root is at depth 1, each of its entries at depth 2, etc.
+		depth := uint(1)
+		if "." != issue.relPath {
+			depth += uint(len(strings.Split(filepath.ToSlash(issue.relPath), "/")))
+		}
+		ret = l.scanDive(context.Background(), handler, issue.absPath, depth)
+	}
+
+	g.Lock()
+	defer g.Unlock()
+	g.retried++
+	for i, is := range g.issue {
+		if is != issue {
+			continue
+		}
+		if nil == ret || ret.is(rcDirDepth) {
+			g.issue = append(g.issue[:i], g.issue[i+1:]...)
+			g.cleared++
+		} else {
+			// replace rather than modify the issue, since views may hold it.
+			retry := *is
+			retry.reason = ret.Error()
+			retry.time = time.Now()
+			g.issue[i] = &retry
+		}
+		break
+	}
+	infoLog.tracef("retried %d scan issues, %d resolved: %q", g.retried, g.cleared, l.name)
+	return ret
+}
+
+// type IssuesView is the panel listing the issues of every library, grouped by
+// library, kind, and directory.
+type IssuesView struct {
+	*tview.TreeView
+	lib       []*Library
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// type issueRef identifies the issue referenced by a leaf of the IssuesView.
+type issueRef struct {
+	lib   *Library
+	issue *ScanIssue
+}
+
+// function newIssuesView() allocates and initializes the issues panel.
+func newIssuesView(ui *tview.Application, page string, lib []*Library) *IssuesView {
+
+	v := IssuesView{
+		TreeView:  tview.NewTreeView(),
+		lib:       lib,
+		layout:    nil,
+		focusPage: page,
+		focusNext: nil,
+		focusPrev: nil,
+	}
+
+	v.TreeView.
+		SetGraphicsColor(colorScheme.inactiveText).
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitle(" Issues (enter: jump, r: retry, v: verify checksums, R: refresh) ").
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.SetSelectedFunc(v.jump)
+	v.SetInputCapture(v.inputEvent)
+
+	return &v
+}
+
+func (v *IssuesView) desc() string { return "" }
+func (v *IssuesView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *IssuesView) page() string         { return v.focusPage }
+func (v *IssuesView) next() FocusDelegator { return v.focusNext }
+func (v *IssuesView) prev() FocusDelegator { return v.focusPrev }
+func (v *IssuesView) focus() {
+	page := v.page()
+	v.refresh()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.TreeView)
+}
+func (v *IssuesView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function refresh() rebuilds the tree of issues from each library's most
+// recent scan.
+func (v *IssuesView) refresh() {
+
+	total := 0
+	root := tview.NewTreeNode("Libraries").
+		SetColor(colorScheme.activeText).
+		SetSelectable(false)
+
+	for _, lib := range v.lib {
+		list := lib.issueList()
+		total += len(list)
+		mark := ""
+		if len(list) > 0 {
+			mark = indicator(ikError)
+		}
+		libNode := tview.NewTreeNode(fmt.Sprintf("%s%s (%d)", mark, lib.name, len(list))).
+			SetColor(colorScheme.highlightPrimary)
+		root.AddChild(libNode)
+
+		var kindNode, dirNode *tview.TreeNode
+		kind, dir := "", ""
+		for _, is := range list {
+			if nil == kindNode || is.kind != kind {
+				kind, dir = is.kind, ""
+				kindNode = tview.NewTreeNode(kind).
+					SetColor(colorScheme.highlightSecondary)
+				libNode.AddChild(kindNode)
+			}
+			if d := filepath.Dir(is.relPath); nil == dirNode || d != dir {
+				dir = d
+				dirNode = tview.NewTreeNode(dir + string(filepath.Separator)).
+					SetColor(colorScheme.activeText)
+				kindNode.AddChild(dirNode)
+			}
+			dirNode.AddChild(
+				tview.NewTreeNode(fmt.Sprintf("%s  (%s)",
+					filepath.Base(is.relPath), formatTime(is.time))).
+					SetReference(&issueRef{lib: lib, issue: is}).
+					SetColor(colorScheme.inactiveMenuText))
+		}
+		// keep large lists of issues collapsed until requested.
+		libNode.SetExpanded(len(list) < 100)
+	}
+
+	v.SetRoot(root).SetCurrentNode(root)
+	if nil != root.GetChildren() {
+		v.SetCurrentNode(root.GetChildren()[0])
+	}
+	v.SetTitle(fmt.Sprintf(" Issues: %d (enter: jump, r: retry, v: verify checksums, R: refresh) ", total))
+}
+
+// function selectedIssue() returns the issue of the currently selected tree
+// node, or nil if the node is not an issue (e.g. a group).
+func (v *IssuesView) selectedIssue() *issueRef {
+	if node := v.GetCurrentNode(); nil != node {
+		if ref, ok := node.GetReference().(*issueRef); ok {
+			return ref
+		}
+	}
+	return nil
+}
+
+// function jump() is the event handler for selecting a tree node. groups are
+// expanded or collapsed, and issues are located in the media browser.
+func (v *IssuesView) jump(node *tview.TreeNode) {
+
+	ref, ok := node.GetReference().(*issueRef)
+	if !ok {
+		node.SetExpanded(!node.IsExpanded())
+		return
+	}
+	infoLog.logf("%s", ref.issue.reason)
+	if v.layout.browseView.selectPath(ref.lib, ref.issue.absPath) {
+		v.layout.focusQueue <- v.layout.browseView
+	} else {
+		warnLog.logf("no media in the browser at or near: %q", ref.issue.absPath)
+	}
+}
+
+// function inputEvent() handles the keys specific to the issues panel.
+func (v *IssuesView) inputEvent(event *tcell.EventKey) *tcell.EventKey {
+
+	if tcell.KeyRune != event.Key() {
+		return event
+	}
+	switch event.Rune() {
+	case 'r':
+		ref := v.selectedIssue()
+		if nil == ref {
+			return nil
+		}
+		if v.layout.busy.count() > 0 {
+			warnLog.logf(busyMessage("retry"))
+			return nil
+		}
+		go func() {
+			if ret := ref.lib.retryIssue(ref.issue); nil != ret {
+				warnLog.log(ret)
+			} else {
+				infoLog.logf("issue resolved: %q", ref.issue.absPath)
+			}
+			v.layout.ui.QueueUpdateDraw(v.refresh)
+		}()
+		return nil
+	case 'v':
+		if v.layout.busy.count() > 0 {
+			warnLog.logf(busyMessage("verify checksums"))
+			return nil
+		}
+		go func() {
+			for _, lib := range v.lib {
+				if lib.db.isSimulated() {
+					continue
+				}
+				if _, ret := lib.verifyChecksums(); nil != ret {
+					warnLog.log(ret)
+				}
+			}
+			v.layout.ui.QueueUpdateDraw(v.refresh)
+		}()
+		return nil
+	case 'R':
+		v.refresh()
+		return nil
+	}
+	return event
+}
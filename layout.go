@@ -16,6 +16,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -101,13 +102,41 @@ type Layout struct {
 	pages     *tview.Pages
 	pagesRoot string
 
-	root *tview.Grid
-
-	quitModal  *QuitDialog
-	helpInfo   *HelpInfoView
-	libSelect  *LibSelectView
-	browseView *BrowseView
-	logView    *LogView
+	root   *tview.Grid
+	header *tview.Box
+	footer *tview.Box
+
+	preset     []*LayoutPreset
+	currPreset int
+
+	macro *MacroRecorder
+
+	quitModal   *QuitDialog
+	helpInfo    *HelpInfoView
+	libSelect   *LibSelectView
+	browseView  *BrowseView
+	logView     *LogView
+	logPump     *LogPump
+	compareView *CompareView
+	batchEdit   *BatchEditView
+	trackPicker *TrackPickerView
+	notesEditor *NotesEditorView
+	bookmarks   *BookmarkView
+	fieldEditor *FieldEditorView
+	relations   *RelationsView
+	settings    *SettingsView
+	issues      *IssuesView
+	calendar    *CalendarView
+	targets     *TargetPickerView
+	searchView  *SearchView
+	spectrum    *SpectrumView
+
+	seriesMarkers *SeriesMarkersView
+
+	searchIndex *SearchIndex
+	queue       *PlayQueue
+
+	lastBatch *BatchEdit
 
 	focusQueue chan FocusDelegator
 	focusLock  sync.Mutex
@@ -116,6 +145,10 @@ type Layout struct {
 
 	eventQueue chan func()
 
+	// work deferred until the screen was drawn for the first time, so that
+	// the user interface appears before it begins (see function onFirstDraw()).
+	firstDraw []func()
+
 	// NOTE: this vars below won't get set until one of the draw routines which
 	// uses a tcell.Screen is called, so be careful when accessing them -- make
 	// sure they're actually available.
@@ -125,6 +158,14 @@ type Layout struct {
 // function show() starts drawing the user interface.
 func (l *Layout) show() *ReturnCode {
 
+	// associate the loggers with the navigable log viewer, through its pump so
+	// that logging never waits on the UI to draw, for as long as it is shown.
+	detach, ret := l.attachLog(l.option)
+	if nil != ret {
+		return ret
+	}
+	defer detach()
+
 	// zeroized Time is some time in the distant past.
 	lastUpdate := time.Time{}
 
@@ -262,12 +303,44 @@ func (l *Layout) show() *ReturnCode {
 
 	l.logView.ScrollToEnd()
 
+	// the libraries began discovering media long before we were shown, so
+	// backfill the media browser with everything discovered so far. this
+	// writes to the event queue, so it must not block the UI from starting.
+	go func(l *Layout) {
+		for _, lib := range l.lib {
+			lib.attach(l.addDiscovery)
+		}
+	}(l)
+
+	l.spectrum.listen(l.ui)
+
+	// start the deferred work once the screen was drawn for the first time,
+	// without holding up the draw.
+	var drawn sync.Once
+	l.ui.SetAfterDrawFunc(func(screen tcell.Screen) {
+		drawn.Do(func() {
+			for _, fn := range l.firstDraw {
+				go fn()
+			}
+		})
+	})
+
+	// do not leave the player running once the user interface exits.
+	defer stopPlayback()
+
 	if err := l.ui.Run(); err != nil {
 		return rcTUIError.specf("show(): ui.Run(): %s", err)
 	}
 	return nil
 }
 
+// function onFirstDraw() defers the given function until the user interface
+// was drawn for the first time, when it is called in its own goroutine. it
+// must be called before the user interface is shown.
+func (l *Layout) onFirstDraw(fn func()) {
+	l.firstDraw = append(l.firstDraw, fn)
+}
+
 func stop(ui *tview.Application) {
 	if nil != ui {
 		ui.Stop()
@@ -288,6 +361,7 @@ func newLayout(opt *Options, busy *BusyState, lib ...*Library) *Layout {
 
 	browseView := newBrowseView(ui, "root", lib)
 	logView := newLogView(ui, "root", lib)
+	logView.SetMaxLines(int(opt.LogLines.uint))
 
 	footer := tview.NewBox().
 		SetBorder(false)
@@ -311,12 +385,43 @@ func newLayout(opt *Options, busy *BusyState, lib ...*Library) *Layout {
 	quitModal := newQuitDialog(ui, "quitModal", lib)
 	libSelect := newLibSelectView(ui, "libSelect", lib)
 	helpInfo := newHelpInfoView(ui, "helpInfo", lib)
+	compareView := newCompareView(ui, "compareView", lib)
+	batchEdit := newBatchEditView(ui, "batchEdit", lib)
+	trackPicker := newTrackPickerView(ui, "trackPicker", lib)
+	notesEditor := newNotesEditorView(ui, "notesEditor", lib)
+	bookmarks := newBookmarkView(ui, "bookmarks", lib)
+	fieldEditor := newFieldEditorView(ui, "fieldEditor", lib)
+	relations := newRelationsView(ui, "relations", lib)
+	seriesMarkers := newSeriesMarkersView(ui, "seriesMarkers", lib)
+	settings := newSettingsView(ui, "settings", lib)
+	issues := newIssuesView(ui, "issues", lib)
+	calendar := newCalendarView(ui, "calendar", lib)
+	targets := newTargetPickerView(ui, "targets", lib)
+	searchView := newSearchView(ui, "searchView", lib)
+	spectrumFifo := ""
+	if ffSpectrum.gate(opt, opt.SpectrumFifo) {
+		spectrumFifo = opt.SpectrumFifo.string
+	}
+	spectrum := newSpectrumView(spectrumFifo)
 
 	pages := tview.NewPages().
 		AddPage("root", root, true, true).
 		AddPage(quitModal.page(), quitModal, false, true).
 		AddPage(libSelect.page(), libSelect, false, true).
-		AddPage(helpInfo.page(), helpInfo, false, true)
+		AddPage(helpInfo.page(), helpInfo, false, true).
+		AddPage(compareView.page(), compareView, true, false).
+		AddPage(batchEdit.page(), batchEdit, true, false).
+		AddPage(trackPicker.page(), trackPicker, true, false).
+		AddPage(notesEditor.page(), notesEditor, true, false).
+		AddPage(bookmarks.page(), bookmarks, true, false).
+		AddPage(fieldEditor.page(), fieldEditor, true, false).
+		AddPage(relations.page(), relations, true, false).
+		AddPage(seriesMarkers.page(), seriesMarkers, true, false).
+		AddPage(settings.page(), settings, true, false).
+		AddPage(issues.page(), issues, true, false).
+		AddPage(calendar.page(), calendar, true, false).
+		AddPage(targets.page(), targets, true, false).
+		AddPage(searchView.page(), searchView, false, false)
 
 	header. // register the header bar screen drawing callback
 		SetDrawFunc(layout.drawMenuBar)
@@ -330,6 +435,19 @@ func newLayout(opt *Options, busy *BusyState, lib ...*Library) *Layout {
 	quitModal.setDelegates(&layout, nil, nil)
 	libSelect.setDelegates(&layout, nil, nil)
 	helpInfo.setDelegates(&layout, nil, nil)
+	compareView.setDelegates(&layout, nil, nil)
+	batchEdit.setDelegates(&layout, nil, nil)
+	trackPicker.setDelegates(&layout, nil, nil)
+	notesEditor.setDelegates(&layout, nil, nil)
+	bookmarks.setDelegates(&layout, nil, nil)
+	fieldEditor.setDelegates(&layout, nil, nil)
+	relations.setDelegates(&layout, nil, nil)
+	seriesMarkers.setDelegates(&layout, nil, nil)
+	settings.setDelegates(&layout, nil, nil)
+	issues.setDelegates(&layout, nil, nil)
+	calendar.setDelegates(&layout, nil, nil)
+	targets.setDelegates(&layout, nil, nil)
+	searchView.setDelegates(&layout, nil, nil)
 
 	// and finally initialize our actual Layout object to be returned
 	layout = Layout{
@@ -341,13 +459,41 @@ func newLayout(opt *Options, busy *BusyState, lib ...*Library) *Layout {
 		pages:     pages,
 		pagesRoot: "root",
 
-		root: root,
-
-		quitModal:  quitModal,
-		helpInfo:   helpInfo,
-		libSelect:  libSelect,
-		browseView: browseView,
-		logView:    logView,
+		root:   root,
+		header: header,
+		footer: footer,
+
+		preset:     layoutPresets(opt),
+		currPreset: 0,
+
+		macro: newMacroRecorder(opt),
+
+		quitModal:   quitModal,
+		helpInfo:    helpInfo,
+		libSelect:   libSelect,
+		browseView:  browseView,
+		logView:     logView,
+		logPump:     newLogPump(logView),
+		compareView: compareView,
+		batchEdit:   batchEdit,
+		trackPicker: trackPicker,
+		notesEditor: notesEditor,
+		bookmarks:   bookmarks,
+		fieldEditor: fieldEditor,
+		relations:   relations,
+		settings:    settings,
+		issues:      issues,
+		calendar:    calendar,
+		targets:     targets,
+		searchView:  searchView,
+		spectrum:    spectrum,
+
+		seriesMarkers: seriesMarkers,
+
+		searchIndex: newSearchIndex(),
+		queue:       loadPlayQueue(lib),
+
+		lastBatch: nil,
 
 		focusQueue: make(chan FocusDelegator),
 		focusLock:  sync.Mutex{},
@@ -356,6 +502,8 @@ func newLayout(opt *Options, busy *BusyState, lib ...*Library) *Layout {
 
 		eventQueue: make(chan func()),
 
+		firstDraw: []func(){},
+
 		screen: nil,
 	}
 
@@ -376,6 +524,18 @@ func newLayout(opt *Options, busy *BusyState, lib ...*Library) *Layout {
 	libSelect.
 		selectedLibDropDown(selectedLibraryAllOption, selectedLibraryAll)
 
+	// arrange the widgets according to the user's selected preset, falling
+	// back on the default preset if it doesn't exist.
+	preset := layout.findPreset(opt.LayoutPreset.string)
+	if preset < 0 {
+		warnLog.logf("unknown layout preset (using %q): %q", defaultLayoutPreset, opt.LayoutPreset.string)
+		preset = layout.findPreset(defaultLayoutPreset)
+	}
+	layout.applyPreset(preset)
+
+	// update the key bindings and presets whenever the config file is reloaded.
+	onConfigReload(layout.applyConfig)
+
 	return &layout
 }
 
@@ -410,6 +570,11 @@ func (l *Layout) inputEvent(event *tcell.EventKey) *tcell.EventKey {
 		'L': l.libSelect,
 		'H': l.helpInfo,
 		'V': l.logView,
+		'C': l.compareView,
+		'D': l.settings,
+		'I': l.issues,
+		'M': l.calendar,
+		'O': l.targets,
 	}
 
 	fwdEvent := event
@@ -433,6 +598,10 @@ func (l *Layout) inputEvent(event *tcell.EventKey) *tcell.EventKey {
 		fwdEvent = nil
 		warnLog.logf("(ignored) please use '%c' key to terminate the "+
 			"application. ctrl keys are swallowed to prevent choking.", 'q')
+	case tcell.KeyCtrlR == evKey:
+		// reload the config file, without blocking the user interface.
+		fwdEvent = nil
+		go reloadConfig().report()
 	}
 
 	navigationEvent := func(lo *Layout, busy bool, ek tcell.Key, er rune, em tcell.ModMask, et time.Time) bool {
@@ -446,6 +615,11 @@ func (l *Layout) inputEvent(event *tcell.EventKey) *tcell.EventKey {
 					warnLog.logf(busyMessage("navigate or open a submenu"))
 					return false
 				}
+				// comparing libraries requires at least two of them.
+				if widget == l.compareView && len(l.lib) < 2 {
+					warnLog.logf("(ignored) at least two libraries are required to compare")
+					return false
+				}
 				lo.focusQueue <- widget
 				return true
 			}
@@ -494,11 +668,68 @@ func (l *Layout) inputEvent(event *tcell.EventKey) *tcell.EventKey {
 			case tcell.KeyEsc:
 				l.focusQueue <- l.focusBase
 			}
+			// keys resolving to recordable actions are performed here rather
+			// than by the Browser itself, so that they may be recorded.
+			// media may be browsed while libraries are still loading, but not
+			// modified until the load has finished.
+			isEditBusy := isBusy || l.isLoading()
+			if l.batchEvent(isEditBusy, evKey, evRune) || l.trackEvent(isEditBusy, evKey, evRune) ||
+				l.seriesEvent(isEditBusy, evKey, evRune) || l.notesEvent(isEditBusy, evKey, evRune) ||
+				l.relationsEvent(isEditBusy, evKey, evRune) || l.collectionEvent(isEditBusy, evKey, evRune) ||
+				l.macroEvent(isEditBusy, evKey, evRune) || l.subtreeEvent(isEditBusy, evKey, evRune) ||
+				l.queueEvent(isEditBusy, evKey, evRune) || l.spectrumEvent(evKey, evRune) ||
+				l.interruptEvent(evKey, evRune) || l.pruneEvent(isEditBusy, evKey, evRune) ||
+				l.undoRemovalEvent(isEditBusy, evKey, evRune) || l.bookmarkEvent(isEditBusy, evKey, evRune) ||
+				l.fieldEvent(isEditBusy, evKey, evRune) || l.searchEvent(evKey, evRune) {
+				fwdEvent = nil
+			}
 			if exitEvent(l, evKey, evRune, evMod, evTime) {
 				l.focusQueue <- l.quitModal
 			}
 		}
 
+	case *BatchEditView:
+		switch evKey {
+		case tcell.KeyEsc:
+			l.focusQueue <- l.focusBase
+		}
+
+	case *TrackPickerView, *NotesEditorView, *BookmarkView, *FieldEditorView, *RelationsView,
+		*SeriesMarkersView, *SettingsView, *IssuesView, *CalendarView, *TargetPickerView:
+		switch evKey {
+		case tcell.KeyEsc:
+			l.focusQueue <- l.focusBase
+		}
+
+	case *CompareView:
+		// the keys answer the confirmation of a move while it is shown.
+		if l.compareView.isConfirming() {
+			break
+		}
+		switch evKey {
+		case tcell.KeyEsc:
+			l.focusQueue <- l.focusBase
+		case tcell.KeyTab, tcell.KeyBacktab:
+			// switch panes rather than navigating the list items.
+			l.compareView.swapActive()
+			fwdEvent = nil
+		case tcell.KeyRune:
+			switch evRune {
+			case 'c', 'm':
+				// copy or move the selected item into the opposite library.
+				l.compareView.transferSelected('m' == evRune)
+			case 'r':
+				// reload both panes, e.g. after copying or moving items.
+				l.compareView.refresh()
+			default:
+				if !navigationEvent(l, isBusy, evKey, evRune, evMod, evTime) {
+					if exitEvent(l, evKey, evRune, evMod, evTime) {
+						l.focusQueue <- l.quitModal
+					}
+				}
+			}
+		}
+
 	case *LogView:
 		if !navigationEvent(l, isBusy, evKey, evRune, evMod, evTime) {
 			switch evKey {
@@ -524,10 +755,11 @@ func (l *Layout) inputEvent(event *tcell.EventKey) *tcell.EventKey {
 func (l *Layout) drawMenuBar(screen tcell.Screen, x int, y int, width int, height int) (int, int, int, int) {
 
 	const (
-		libDimWidth   = 40 // library selection window width
-		libDimHeight  = 20 // ^----------------------- height
-		helpDimWidth  = 40 // help info window width
-		helpDimHeight = 10 // ^--------------- height
+		libDimWidth     = 40 // library selection window width
+		libDimHeight    = 20 // ^----------------------- height
+		helpDimWidth    = 40 // help info window width
+		helpDimHeight   = 10 // ^--------------- height
+		searchDimHeight = 3  // search overlay height (its width spans the screen)
 	)
 
 	// update the layout's associated screen field. note that you must be very
@@ -543,6 +775,9 @@ func (l *Layout) drawMenuBar(screen tcell.Screen, x int, y int, width int, heigh
 	l.helpInfo.
 		SetRect(width-helpDimWidth, 1, helpDimWidth, helpDimHeight)
 
+	l.searchView.
+		SetRect(2, 1, width-4, searchDimHeight)
+
 	libName := l.libSelect.selectedName
 	library := fmt.Sprintf("[::bu]%s[::-]%s: [#%06x]%s", "L", "ibrary", colorScheme.highlightPrimary.Hex(), libName)
 	help := fmt.Sprintf("[::bu]%s[::-]%s", "H", "elp")
@@ -571,12 +806,47 @@ func (l *Layout) drawStatusBar(screen tcell.Screen, x int, y int, width int, hei
 		l.screen = &screen
 	}
 
-	//dateTime := time.Now().Format("[15:04:05] Monday, January 02, 2006")
-	dateTime := time.Now().Format("2006/01/02 03:04 PM")
+	dateTime := formatClock(time.Now())
 
 	// Write some text along the horizontal line.
 	tview.Print(screen, dateTime, x+3, y, width, tview.AlignLeft, colorScheme.highlightSecondary)
 
+	// list the name of each library that has exceeded one of its size budgets
+	// (indexed media quota or file system free space) next to the clock.
+	overBudget := []string{}
+	for _, lib := range l.lib {
+		if lib.isOverBudget() {
+			overBudget = append(overBudget, lib.name)
+		}
+	}
+	if len(overBudget) > 0 {
+		budget := fmt.Sprintf("%sover budget: %s", indicator(ikError), strings.Join(overBudget, ", "))
+		tview.Print(screen, budget, x+3+len(dateTime)+3, y, width, tview.AlignLeft, colorScheme.highlightPrimary)
+	}
+
+	// show the progress of each library still loading from its database, and
+	// through any huge directory being scanned, right-aligned just left of the
+	// busy indicator.
+	loading, scanning := []string{}, []string{}
+	for _, lib := range l.lib {
+		if count, total, ok := lib.loadProgress(); ok {
+			loading = append(loading, fmt.Sprintf("%s %d/%d", lib.name, count, total))
+		}
+		if dir, ok := lib.dirProgress.current(); ok {
+			scanning = append(scanning, fmt.Sprintf("%s %d/%d", path.Join(lib.name, dir.path), dir.count, dir.total))
+		}
+	}
+	progress := []string{}
+	if len(loading) > 0 {
+		progress = append(progress, fmt.Sprintf("loading… %s", strings.Join(loading, ", ")))
+	}
+	if len(scanning) > 0 {
+		progress = append(progress, fmt.Sprintf("scanning… %s", strings.Join(scanning, ", ")))
+	}
+	if len(progress) > 0 {
+		tview.Print(screen, strings.Join(progress, "  "), x-ellipses-len("working")-4, y, width, tview.AlignRight, colorScheme.highlightSecondary)
+	}
+
 	// update the busy indicator if we have any active worker threads
 	count := l.busy.count()
 	if count > 0 {
@@ -597,6 +867,17 @@ func (l *Layout) drawStatusBar(screen tcell.Screen, x int, y int, width int, hei
 	return 0, 0, 0, 0
 }
 
+// function isLoading() returns true if any library is still loading media
+// from its database.
+func (l *Layout) isLoading() bool {
+	for _, lib := range l.lib {
+		if _, _, ok := lib.loadProgress(); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *Layout) addDiscovery(lib *Library, disco *Discovery) *ReturnCode {
 
 	var media *Media = nil
@@ -610,12 +891,27 @@ func (l *Layout) addDiscovery(lib *Library, disco *Discovery) *ReturnCode {
 		media = video.Media
 	case *Subtitles:
 		_ = disco.data[0].(*Subtitles) // TBD: unused currently
+	case *AudioTrack:
+		_ = disco.data[0].(*AudioTrack) // TBD: unused currently
 	}
 
 	if nil != media {
 		l.eventQueue <- func() {
+			// keep the record ID so that the media can be modified later (and
+			// sorted by the fields of its record).
+			if len(disco.data) > 1 {
+				if id, ok := disco.data[1].(int); ok {
+					l.browseView.setRecord(media, &RecordID{id: id, rec: disco.data[0]})
+				}
+			}
 			position, primary, secondary := l.browseView.positionForMediaItem(media)
 			l.browseView.insertMediaItem(lib, media, position, primary, secondary, nil)
+			// index the media for searches, and list it among the results of
+			// the search currently filtering the media browser (if any).
+			l.searchIndex.add(media)
+			if l.browseView.isSearching() {
+				l.browseView.searchInsert(l.searchIndex, media)
+			}
 		}
 	}
 
@@ -989,7 +1285,7 @@ func (v *LibSelectView) drawLibSelectView(screen tcell.Screen, x int, y int, wid
 	for i, s := range []string{
 		fmtInfoRow("Video", strconv.FormatUint(uint64(v.numVideo), 10)),
 		fmtInfoRow("Audio", strconv.FormatUint(uint64(v.numAudio), 10)),
-		fmtInfoRow("Last scan", lastScan.Format("2006/01/02 15:04:05")),
+		fmtInfoRow("Last scan", formatTime(lastScan)),
 	} {
 		tview.Print(screen, s, ddX+3, ddY+2+i, width, tview.AlignLeft, colorScheme.inactiveMenuText)
 	}
@@ -998,6 +1294,12 @@ func (v *LibSelectView) drawLibSelectView(screen tcell.Screen, x int, y int, wid
 }
 func (v *LibSelectView) selectedLibDropDown(option string, optionIndex int) {
 
+	// the drop-down may report its initial option while it is created, before
+	// the view belongs to a layout.
+	if nil == v.layout {
+		return
+	}
+
 	// do not handle any dropdown selection if we are preoccupied handling some
 	// other event or request.
 	if isBusy := v.layout.busy.count() > 0; isBusy {
@@ -1030,10 +1332,13 @@ func (v *LibSelectView) selectedLibDropDown(option string, optionIndex int) {
 	// of selected libraries.
 	v.updateMediaCount(includedLib...)
 	v.selectedName = strings.TrimSpace(option)
+	termTitle.setActive(selected)
+	preferLibrary(selected)
 	go func() {
 		// protect the libraries from being modified while we are updating the
 		// media browser and library selection.
 		v.layout.busy.inc()
+		v.layout.browseView.endSearch()
 		v.layout.browseView.showLibrary(selected)
 		v.layout.busy.dec()
 	}()
@@ -1107,6 +1412,7 @@ func (v *BrowseView) setDelegates(layout *Layout, prev, next FocusDelegator) {
 	v.layout = layout
 	v.focusPrev = prev
 	v.focusNext = next
+	v.setItemTextFunc(layout.queueText)
 }
 func (v *BrowseView) page() string         { return v.focusPage }
 func (v *BrowseView) next() FocusDelegator { return v.focusNext }
@@ -1116,8 +1422,15 @@ func (v *BrowseView) focus() {
 	v.layout.pages.ShowPage(page)
 	v.layout.ui.SetFocus(v.Browser)
 }
-func (v *BrowseView) blur()                                                {}
-func (v *BrowseView) selectItem(index int, mainText, secondaryText string) {}
+func (v *BrowseView) blur() {}
+
+// function selectItem() plays the item selected (with Enter) in the external
+// media player.
+func (v *BrowseView) selectItem(index int, mainText, secondaryText string) {
+	if item := v.currentMediaItem(); nil != item {
+		v.layout.play(item)
+	}
+}
 
 //------------------------------------------------------------------------------
 
@@ -14,13 +14,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/HouzuoGuo/tiedot/db"
 	//"github.com/davecgh/go-spew/spew"
 )
 
@@ -28,6 +32,18 @@ import (
 // together with a rooted search path from which all media file discovery
 // is performed.
 type Library struct {
+	// 64-bit atomic ops must be performed on 8-byte boundaries (see go1.10
+	// sync/atomic bugs), so keep these as the first fields in the struct.
+	sizeIndexed uint64 // cumulative size (bytes) of all media indexed in this library
+	loadedCount uint64 // number of records loaded so far by the current load
+	loadedTotal uint64 // approx number of records being loaded (0 = not loading)
+	ignored     uint64 // number of files ignored (of no known kind) by the current scan
+	sizeAlerted uint32 // nonzero if the user has already been warned about exceeding budgets
+	lowOnSpace  uint32 // nonzero if the file system was low on free space when last checked
+	offline     uint32 // nonzero if the library root could not be read when last revalidated
+	rescan      uint32 // nonzero if the library should be rescanned once the current scan finishes
+	polling     uint32 // nonzero while the polling watcher of this library is running
+
 	workingDir string // current working directory
 	absPath    string // absolute path to library
 	name       string // library name (default: basename of path)
@@ -49,6 +65,47 @@ type Library struct {
 	scanElapsed  time.Duration    // measures time elapsed for scan to complete (use internally, not thread-safe!)
 
 	lastScan time.Time // the datetime at which this library was last scanned
+
+	quota   uint64 // size (bytes) budget of all media indexed in this library (0 = unlimited)
+	minFree uint64 // size (bytes) of free space below which the file system is considered full (0 = unchecked)
+
+	fields []*CustomField // user-defined metadata fields declared for this library
+	assoc  *SubsAssoc     // subtitles association heuristics in effect for this library
+
+	pollFreq   time.Duration     // interval at which the file system is polled for changes (0 = never)
+	archiveExt map[string]string // archive format of each file name extension whose archives are listed
+	sniffKind  map[string]bool   // kinds of files of unknown extensions classified by content
+
+	pollReset chan time.Duration // new poll intervals given by reloading the config file
+
+	fullScan    bool         // examine every file when scanning, ignoring the directory cache
+	dirCache    *DirCache    // directory states recorded by the most recent scan (nil if never scanned)
+	dirProgress *DirProgress // progress of the current scan through huge directories
+	quarantine  *Quarantine  // files found in progress, held back until they are stable
+
+	followLinks bool            // scan symlinks as the files or directories they point to
+	linkTarget  map[string]bool // directories reached through symlinks by the current scan
+	linkMutex   sync.Mutex      // guards linkTarget against the workers of the current scan
+	ignoreName  []string        // name patterns of files and directories never scanned
+
+	dirWorkers uint      // max number of workers scanning the directories of each scan
+	scanPool   *ScanPool // workers scanning the directories of the current scan (nil if sequential)
+
+	interrupt  *Interrupt  // cancels the loads and scans in progress
+	checkpoint *Checkpoint // directories completed by the current scan (nil if not scanning)
+
+	prune      bool          // remove the records of media whose files no longer exist when loading
+	missing    *MissingSet   // records whose files were found missing by the most recent load
+	tombstones *TombstoneLog // records removed, which may be restored
+
+	fsRetries  uint        // max number of retries of transiently failing file system operations
+	checkAudio bool        // verify the header of each new audio file while scanning
+	retryStats *RetryStats // retries performed by the most recent scan
+
+	feed   *DiscoveryFeed // history of discoveries, replayed to views attached later
+	issues *IssueLog      // problems encountered by the most recent scan
+
+	failed *ReturnCode // reason the most recent load or scan failed (nil if neither failed)
 }
 
 // type PathHandlerFunc represents a function that accepts a Library, file path,
@@ -120,6 +177,139 @@ const (
 // locally and globally
 func init() {}
 
+// function libraryPath() returns the canonical absolute path of the given
+// library path argument: a leading "~" is expanded to the user's home dir, the
+// path is made absolute and cleaned of any trailing separators, and symlinks
+// are resolved. thus "media/", "./media", and "/home/me/media" all refer to the
+// same library (and same database). symlinks are not resolved if the path does
+// not exist, e.g. the old path of a library that was moved.
+func libraryPath(lib string) (string, error) {
+
+	if "~" == lib || strings.HasPrefix(lib, "~/") ||
+		strings.HasPrefix(lib, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if nil != err {
+			return "", err
+		}
+		lib = filepath.Join(home, lib[1:])
+	}
+
+	abs, err := filepath.Abs(lib)
+	if nil != err {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(abs); nil == err {
+		abs = real
+	}
+	return abs, nil
+}
+
+// function configureLibrary() creates a Library at the given absolute path,
+// configured by the given options, without opening (or creating) its database.
+// the scan dry-run classifies files with a library created this way, so the
+// database must be assigned before it is scanned (see function newLibrary()).
+func configureLibrary(opt *Options, busy *BusyState, abs, dir string, lim uint) *Library {
+
+	// the settle time was already validated along with the other options.
+	settle, _ := parseSettle(opt.Settle.string)
+	retain, _ := parseTombstoneRetention(opt.Tombstones.string)
+
+	base := &Library{
+		workingDir: dir,
+		absPath:    abs,
+		name:       path.Base(abs),
+		maxDepth:   lim,
+
+		// path to the library database directory, assigned once the database
+		// is opened (see function newLibrary()).
+		dataDir: "",
+		db:      nil,
+
+		// mutex which controls interaction by the various goroutines to limited
+		// system resources such as database tables, UI primitives, etc.
+		busyState: busy,
+
+		// we will not necessarily have a TUI reference in the event that the
+		// user is running in CLI mode, so keep nil by default.
+		tui: nil,
+
+		loadComplete: make(chan interface{}),
+		loadStart:    make(chan time.Time, maxLibraryScanners),
+		loadElapsed:  0,
+
+		scanComplete: make(chan interface{}),
+		scanStart:    make(chan time.Time, maxLibraryScanners),
+		scanElapsed:  0,
+
+		lastScan: time.Time{},
+
+		quota:   opt.LibraryQuota.uint64,
+		minFree: opt.MinFreeSpace.uint64,
+
+		fields: []*CustomField{},
+
+		fullScan:    opt.FullScan.bool,
+		followLinks: opt.FollowLinks.bool,
+		linkTarget:  map[string]bool{},
+		ignoreName:  ignoreNames(opt),
+
+		dirWorkers: opt.DirWorkers.uint,
+		scanPool:   nil,
+
+		dirCache:    nil,
+		dirProgress: &DirProgress{},
+		quarantine:  newQuarantine(settle, newTorrentIndex(abs, opt.TorrentResume.StringList)),
+		interrupt:   newInterrupt(),
+		checkpoint:  nil,
+
+		prune:      opt.Prune.bool,
+		missing:    newMissingSet(),
+		tombstones: newTombstoneLog(retain),
+
+		fsRetries:  opt.FSRetries.uint,
+		checkAudio: opt.CheckAudio.bool,
+		retryStats: &RetryStats{},
+
+		feed:   newDiscoveryFeed(),
+		issues: newIssueLog(),
+	}
+
+	// configure the subtitles association heuristics, which may have been
+	// disabled for this library by name or by path.
+	base.assoc = newSubsAssoc(opt, base)
+
+	// enable the polling watcher if requested for this library. the last rule
+	// applying to the library wins.
+	base.pollFreq = base.pollFreqOf(opt)
+	base.pollReset = make(chan time.Duration, 1)
+
+	// enable the introspection of archives of the formats requested for this
+	// library.
+	base.archiveExt = map[string]string{}
+	for _, spec := range opt.Archives.StringList {
+		if rule, err := parseArchiveRule(spec); nil == err && rule.appliesTo(base) {
+			for _, format := range rule.format {
+				for _, ext := range archiveFormat[format] {
+					base.archiveExt[ext] = format
+				}
+			}
+		}
+	}
+
+	// classify the files of unknown extensions by content, if requested for
+	// this library.
+	base.sniffKind = map[string]bool{}
+	for _, spec := range opt.Sniff.StringList {
+		if rule, err := parseSniffRule(spec); nil == err && rule.appliesTo(base) {
+			for _, kind := range rule.kind {
+				base.sniffKind[kind] = true
+			}
+		}
+	}
+
+	return base
+}
+
 // function newLibrary() creates and initializes a new Library ready to scan.
 // the library database is also created if one doesn't already exist, otherwise
 // it is opened for business.
@@ -136,10 +326,10 @@ func newLibrary(opt *Options, busy *BusyState, lib string, lim uint, curr []*Lib
 	}
 
 	// determine the absolute path to the directory tree containing media.
-	abs, err := filepath.Abs(lib)
+	abs, err := libraryPath(lib)
 	if nil != err {
 		return nil, rcInvalidLibrary.specf(
-			"newLibrary(%q, %q): filepath.Abs(): %s", dat, lib, err)
+			"newLibrary(%q, %q): libraryPath(): %s", dat, lib, err)
 	}
 
 	// verify we haven't already seen this path in our library list.
@@ -165,40 +355,44 @@ func newLibrary(opt *Options, busy *BusyState, lib string, lim uint, curr []*Lib
 			"newLibrary(%q, %q): Readdir(): %s", dat, lib, err)
 	}
 
+	// if the library was moved, carry its database over from the old path.
+	if !opt.Portable.bool && !opt.isMemoryBackend() {
+		if ret := remapDatabase(opt, abs); nil != ret {
+			return nil, ret
+		}
+	}
+
 	// open or create the library database if it doesn't exist.
-	db, ret := newDatabase(opt, abs, dat)
+	db, ret := newDatabase(opt, abs)
 	if nil != ret {
 		return nil, ret
 	}
 
-	return &Library{
-		workingDir: dir,
-		absPath:    abs,
-		name:       path.Base(abs),
-		maxDepth:   lim,
-
-		// path to the library database directory.
-		dataDir: dat,
-		db:      db,
-
-		// mutex which controls interaction by the various goroutines to limited
-		// system resources such as database tables, UI primitives, etc.
-		busyState: busy,
-
-		// we will not necessarily have a TUI reference in the event that the
-		// user is running in CLI mode, so keep nil by default.
-		tui: nil,
-
-		loadComplete: make(chan interface{}),
-		loadStart:    make(chan time.Time, maxLibraryScanners),
-		loadElapsed:  0,
+	// a portable library may have been opened from a different mount point
+	// than last time, so make sure its records point at the current one.
+	if opt.Portable.bool {
+		if ret := db.anchor(); nil != ret {
+			return nil, ret
+		}
+	}
 
-		scanComplete: make(chan interface{}),
-		scanStart:    make(chan time.Time, maxLibraryScanners),
-		scanElapsed:  0,
+	base := configureLibrary(opt, busy, abs, dir, lim)
+	base.dataDir = db.dataDir
+	base.db = db
+
+	// install an index for each of the user-defined metadata fields declared
+	// for this library. the declarations were already verified when parsing
+	// the command line options.
+	for _, spec := range opt.CustomFields.StringList {
+		if field, err := parseCustomField(spec); nil == err && field.appliesTo(base) {
+			if ret := db.ensureIndex(ecMedia, field.index()); nil != ret {
+				return nil, ret
+			}
+			base.fields = append(base.fields, field)
+		}
+	}
 
-		lastScan: time.Time{},
-	}, nil
+	return base, nil
 }
 
 // function String() creates a string representation of the Library for easy
@@ -207,6 +401,128 @@ func (l *Library) String() string {
 	return fmt.Sprintf("{%q,%q,%s}", l.name, l.absPath, l.db)
 }
 
+// function addIndexedSize() safely increments the cumulative size of all media
+// indexed in this library by the given number of bytes.
+func (l *Library) addIndexedSize(size int64) uint64 {
+	if size < 0 {
+		return atomic.LoadUint64(&l.sizeIndexed)
+	}
+	return atomic.AddUint64(&l.sizeIndexed, uint64(size))
+}
+
+// function removeIndexedSize() safely decrements the cumulative size of all
+// media indexed in this library by the given number of bytes, e.g. once the
+// record of a media is removed. the size never falls below zero.
+func (l *Library) removeIndexedSize(size int64) uint64 {
+	for {
+		curr := atomic.LoadUint64(&l.sizeIndexed)
+		if size <= 0 {
+			return curr
+		}
+		next := uint64(0)
+		if uint64(size) < curr {
+			next = curr - uint64(size)
+		}
+		if atomic.CompareAndSwapUint64(&l.sizeIndexed, curr, next) {
+			return next
+		}
+	}
+}
+
+// function indexedSize() safely returns the cumulative size of all media
+// indexed in this library.
+func (l *Library) indexedSize() uint64 {
+	return atomic.LoadUint64(&l.sizeIndexed)
+}
+
+// function overQuota() returns true if the cumulative size of all media
+// indexed in this library exceeds the user-defined size budget. always returns
+// false if no budget was defined.
+func (l *Library) overQuota() bool {
+	return l.quota > 0 && l.indexedSize() > l.quota
+}
+
+// function underMinFree() returns true if the free space remaining on the file
+// system containing this library has fallen below the user-defined threshold,
+// along with the number of bytes currently available. always returns false if
+// no threshold was defined or if the free space cannot be determined.
+func (l *Library) underMinFree() (bool, uint64) {
+	if 0 == l.minFree {
+		return false, 0
+	}
+	free, err := diskFree(l.absPath)
+	if nil != err {
+		warnLog.tracef("cannot determine free space: %q: %s", l.absPath, err)
+		return false, 0
+	}
+	return free < l.minFree, free
+}
+
+// function loadProgress() returns the number of records loaded so far and the
+// approximate number of records being loaded from the library's database, and
+// true if the library is currently loading. this is intended for polling by UI
+// status indicators.
+func (l *Library) loadProgress() (uint64, uint64, bool) {
+	total := atomic.LoadUint64(&l.loadedTotal)
+	if 0 == total {
+		return 0, 0, false
+	}
+	total-- // offset by one so that an empty database still reads as loading
+	count := atomic.LoadUint64(&l.loadedCount)
+	// the total is only approximate, so don't report more loaded than total.
+	if count > total {
+		total = count
+	}
+	return count, total, true
+}
+
+// function isOverBudget() returns true if either of the library's size budgets
+// (indexed media quota or file system free space) have been exceeded. this is
+// intended for polling by UI status indicators and does not log anything. the
+// free space is not queried here, which would occur on every redraw; rather,
+// the result of the most recent call to checkBudget() is used.
+func (l *Library) isOverBudget() bool {
+	if l.overQuota() {
+		return true
+	}
+	return 0 != atomic.LoadUint32(&l.lowOnSpace)
+}
+
+// function checkBudget() verifies the library's size budgets and issues a
+// warning for each one that has been exceeded. once warned, the user will not
+// be warned again until the library has returned to within its budgets.
+func (l *Library) checkBudget() bool {
+
+	over := false
+
+	if l.overQuota() {
+		over = true
+		if 0 == atomic.LoadUint32(&l.sizeAlerted) {
+			warnLog.logf("library exceeds its size budget: %q (%d of %d bytes indexed)",
+				l.name, l.indexedSize(), l.quota)
+		}
+	}
+
+	low, free := l.underMinFree()
+	if low {
+		atomic.StoreUint32(&l.lowOnSpace, 1)
+		over = true
+		if 0 == atomic.LoadUint32(&l.sizeAlerted) {
+			warnLog.logf("library file system is low on free space: %q (%d bytes free, threshold %d bytes)",
+				l.name, free, l.minFree)
+		}
+	} else {
+		atomic.StoreUint32(&l.lowOnSpace, 0)
+	}
+
+	if over {
+		atomic.StoreUint32(&l.sizeAlerted, 1)
+	} else {
+		atomic.StoreUint32(&l.sizeAlerted, 0)
+	}
+	return over
+}
+
 // function recandidateSubtitles() attempts to find candidate VideoMedia in the
 // library for all Subtitles that are currently unassociated with any VideoMedia
 // objects. if force is true, then it attempts to find candidate VideoMedia for
@@ -246,10 +562,48 @@ func (l *Library) recandidateSubtitles(force bool) *ReturnCode {
 	return nil
 }
 
+// function recandidateAudioTracks() attempts to find candidate VideoMedia in
+// the library for all external AudioTracks that are currently unassociated with
+// any VideoMedia objects, exactly as recandidateSubtitles() does for subtitles.
+func (l *Library) recandidateAudioTracks(force bool) *ReturnCode {
+
+	orphan := []RecordID{}
+	remain := []RecordID{}
+
+	l.db.col[ecSupport][skAudioTrack].ForEachDoc(
+		func(id int, data []byte) (willMoveOn bool) {
+			track := &AudioTrack{}
+			track.fromRecord(data)
+			if force || 0 >= len(track.KnownVideoMedia) {
+				orphan = append(orphan, RecordID{id: id, rec: track})
+			}
+			return true // move on to next record
+		})
+
+	numOrphan := len(orphan)
+	if numOrphan > 0 {
+		warnLog.tracef("identified %d orphan audio tracks in \"%s\" (unassociated with any media)", numOrphan, l.name)
+		for _, o := range orphan {
+			track := o.rec.(*AudioTrack)
+			infoLog.tracef("scanning media for audio track: %s", track)
+			vid, err := track.findCandidates(l, true, o.id)
+			if nil != err {
+				return err
+			}
+			if 0 == len(vid) {
+				remain = append(remain, o)
+			}
+		}
+		warnLog.tracef("still unable to associate %d orphan audio tracks with any media. consider renaming or moving the files to something more conventional.", len(remain))
+	}
+
+	return nil
+}
+
 // function loadDive() performs the actual iterated loading of all objects in
 // this Library. as each object is instantiated using the data from the data
 // store, it is handed off to the load handler for handling by all subscribers.
-func (l *Library) loadDive(ph *PathHandler, class EntityClass, kind int) (uint, *ReturnCode) {
+func (l *Library) loadDive(ctx context.Context, ph *PathHandler, class EntityClass, kind int) (uint, *ReturnCode) {
 
 	var count uint = 0
 	var ret *ReturnCode = nil
@@ -259,20 +613,37 @@ func (l *Library) loadDive(ph *PathHandler, class EntityClass, kind int) (uint,
 	// before notifying the handler of what we found.
 	l.db.col[class][kind].ForEachDoc(
 		func(id int, data []byte) (willMoveOn bool) {
+			// stop loading once interrupted.
+			if nil != ctx.Err() {
+				ret = rcInterrupted.specf("loadDive(%d, %d): %q", class, kind, l.name)
+				return false
+			}
 			switch class {
 			case ecMedia:
 				switch MediaKind(kind) {
 				case mkAudio:
 					audio := &AudioMedia{}
 					audio.fromRecord(data)
-					infoLog.tracef("loaded audio (ID={%q,%X}): %s", l.name, id, audio)
+					// the record is indexed until removed, even if its file is
+					// missing (see function buryRecord()).
+					l.addIndexedSize(audio.Size)
+					if !l.verifyRecord(class, kind, id, audio.AbsPath) {
+						return true // not loaded, pruned once the load has finished
+					}
+					infoLog.in(ctx).tracef("loaded audio (ID={%q,%X}): %s", l.name, id, audio)
 					if nil != ph && nil != ph.handleMedia {
 						ph.handleMedia(l, audio.AbsPath, audio, id)
 					}
 				case mkVideo:
 					video := &VideoMedia{}
 					video.fromRecord(data)
-					infoLog.tracef("loaded video (ID={%q,%X}): %s", l.name, id, video)
+					// the record is indexed until removed, even if its file is
+					// missing (see function buryRecord()).
+					l.addIndexedSize(video.Size)
+					if !l.verifyRecord(class, kind, id, video.AbsPath) {
+						return true // not loaded, pruned once the load has finished
+					}
+					infoLog.in(ctx).tracef("loaded video (ID={%q,%X}): %s", l.name, id, video)
 					if nil != ph && nil != ph.handleMedia {
 						ph.handleMedia(l, video.AbsPath, video, id)
 					}
@@ -283,15 +654,29 @@ func (l *Library) loadDive(ph *PathHandler, class EntityClass, kind int) (uint,
 				case skSubtitles:
 					subs := &Subtitles{}
 					subs.fromRecord(data)
-					infoLog.tracef("loaded subtitles (ID={%q,%X}): %s", l.name, id, subs)
+					if !l.verifyRecord(class, kind, id, subs.AbsPath) {
+						return true // not loaded, pruned once the load has finished
+					}
+					infoLog.in(ctx).tracef("loaded subtitles (ID={%q,%X}): %s", l.name, id, subs)
 					if nil != ph && nil != ph.handleSupport {
 						ph.handleSupport(l, subs.AbsPath, subs, id)
 					}
+				case skAudioTrack:
+					track := &AudioTrack{}
+					track.fromRecord(data)
+					if !l.verifyRecord(class, kind, id, track.AbsPath) {
+						return true // not loaded, pruned once the load has finished
+					}
+					infoLog.in(ctx).tracef("loaded audio track (ID={%q,%X}): %s", l.name, id, track)
+					if nil != ph && nil != ph.handleSupport {
+						ph.handleSupport(l, track.AbsPath, track, id)
+					}
 				default:
 				}
 			default:
 			}
 			count++
+			atomic.AddUint64(&l.loadedCount, 1)
 			return true // move on to next record
 		})
 
@@ -299,9 +684,9 @@ func (l *Library) loadDive(ph *PathHandler, class EntityClass, kind int) (uint,
 }
 
 // function load() is the entry point for initiating a load on the library's
-// backing data store. currently, the load is dispatched and cannot be safely
-// interrupted. you must wait for the load to finish before restarting.
-func (l *Library) load(handler *PathHandler) (uint, *ReturnCode) {
+// backing data store. the load stops early (with error rcInterrupted) if the
+// given context is cancelled or the library is interrupted.
+func (l *Library) load(ctx context.Context, handler *PathHandler) (uint, *ReturnCode) {
 
 	var (
 		numLoad uint = 0 // number of known files loaded from database
@@ -323,47 +708,63 @@ func (l *Library) load(handler *PathHandler) (uint, *ReturnCode) {
 	select {
 	case l.loadStart <- time.Now():
 
-		// notify the user that a potentially time-intensive operation has
-		// begun and user interactions will be limited.
-		if !isCLIMode {
-			l.busyState.inc()
+		ctx, end := l.beginOperation(ctx, "load")
+		defer end()
+
+		ctx, done := l.interruptible(ctx)
+		defer done()
+
+		// rather than declaring ourselves busy (which limits user interactions
+		// until we finish), publish our progress so that the media already
+		// loaded can be browsed while the rest is still loading.
+		approx := uint64(0)
+		for class := range l.db.col {
+			for _, col := range l.db.col[class] {
+				approx += uint64(col.ApproxDocCount())
+			}
 		}
+		atomic.StoreUint64(&l.loadedCount, 0)
+		atomic.StoreUint64(&l.loadedTotal, approx+1) // nonzero while loading
 
 		// the write succeeded, so we can initiate loading. keep track of the
 		// time at which we began so that the time elapsed can be calculated and
 		// notified to the user.
-		infoLog.verbosef("loading: %q", l.name)
+		infoLog.in(ctx).verbosef("loading: %q", l.name)
+		// verify the file of each record loaded still exists, unless the
+		// library root cannot be read (see prune.go).
+		l.missing.begin(nil == l.revalidate())
 		// multi-dimensional numRecordsLoad contains fixed outer-array dimension
 		// equal to number of collections (i.e. classes) equal to ecCOUNT
 		for classID, count := range l.db.numRecordsLoad {
 			class := EntityClass(classID)
 			for kind := range count {
-				if count[kind], err = l.loadDive(handler, class, kind); nil != err {
+				if count[kind], err = l.loadDive(ctx, handler, class, kind); nil != err {
+					atomic.StoreUint64(&l.loadedTotal, 0)
+					<-l.loadStart
 					return numLoad, err
 				}
 			}
 		}
 
-		// we've finished the loading operations, so remove the busy indicator
-		// to indicate that normal user interactions may resume (if no other
-		// event has the semaphore still incremented).
+		// we've finished the loading operations, so remove the progress
+		// indicator.
 		l.loadElapsed = time.Since(<-l.loadStart)
-		if !isCLIMode {
-			l.busyState.dec()
-		}
+		atomic.StoreUint64(&l.loadedTotal, 0)
 
 		// construct a summary message for the load operation.
 		total, summary := l.db.totalRecordsString(dmLoad, -1, -1)
 		if total > 0 {
-			infoLog.verbosef(
+			infoLog.in(ctx).verbosef(
 				"finished loading: %q (%s loaded in %s)",
 				l.name, summary, l.loadElapsed.Round(time.Millisecond))
 		} else {
-			infoLog.verbosef(
+			infoLog.in(ctx).verbosef(
 				"finished loading: %q (no media loaded in %s)",
 				l.name, l.loadElapsed.Round(time.Millisecond))
 		}
 		numLoad = total
+		l.reportMissing()
+		l.expireTombstones()
 
 	default:
 		// if the write failed, we fall back to this default case. the only
@@ -381,8 +782,16 @@ func (l *Library) load(handler *PathHandler) (uint, *ReturnCode) {
 
 // function scanDive() is the recursive step for the file system traversal,
 // invoked initially by function scan(). error codes generated in this routine
-// will be returned to the caller of scanDive() -and- the caller of scan().
-func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnCode {
+// will be returned to the caller of scanDive() -and- the caller of scan(). the
+// traversal stops (with error rcInterrupted) once the given context is
+// cancelled. the entries of a directory may be scanned concurrently by the
+// workers of the scan (see scanpool.go), so everything updated here must be
+// safe for concurrent use.
+func (l *Library) scanDive(ctx context.Context, ph *PathHandler, absPath string, depth uint) *ReturnCode {
+
+	if nil != ctx.Err() {
+		return rcInterrupted.specf("scanDive(%q, %d): %s", absPath, depth, ctx.Err())
+	}
 
 	// get a path to the file relative to the library root dir (useful for
 	// displaying diagnostic info to the user).
@@ -395,54 +804,174 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 	// for concision, show the relative path by default in any diagnostics/logs.
 	dispPath := relPath
 
+	// operating system metadata and trash are never examined (see ignore.go).
+	if absPath != l.absPath && l.isIgnored(absPath) {
+		infoLog.in(ctx).tracef("ignoring: %s", dispPath)
+		return nil
+	}
+
 	// read fs attributes to determine how we handle the file.
-	fileInfo, err := os.Lstat(absPath)
+	var fileInfo os.FileInfo
+	err = l.retryFS(func() (err error) {
+		fileInfo, err = os.Lstat(absPath)
+		return err
+	})
 	if nil != err {
-		return rcInvalidStat.specf(
-			"scanDive(%q, %d): os.Lstat(): %s", dispPath, depth, err)
+		return rcInvalidStat.wrap(err,
+			"scanDive(%q, %d): os.Lstat()", dispPath, depth).
+			at("scanDive", l.name, absPath)
 	}
 	mode := fileInfo.Mode()
 
+	// scan symlinks as the file or directory they point to, if enabled (see
+	// symlink.go).
+	if 0 != mode&os.ModeSymlink && l.followLinks {
+		target, ret := l.followSymlink(absPath)
+		if nil != ret {
+			return ret.at("scanDive", l.name, absPath)
+		}
+		if nil == target {
+			return nil
+		}
+		fileInfo, mode = target, target.Mode()
+	}
+
 	// operate on the file based on its file mode.
 	switch {
 	case (mode & os.ModeDir) > 0:
+		// never index the library's own database (e.g. a portable library).
+		if filepath.Clean(absPath) == l.db.absPath {
+			return nil
+		}
 		// file is directory, scanDive its contents unless we are at max depth.
 		if depthUnlimited != l.maxDepth && depth > l.maxDepth {
 			return rcDirDepth.specf(
-				"scanDive(%q, %d): limit = %d", dispPath, depth, l.maxDepth)
+				"scanDive(%q, %d): limit = %d", dispPath, depth, l.maxDepth).
+				at("scanDive", l.name, absPath)
 		}
-		dir, err := os.Open(absPath)
-		if nil != err {
-			return rcDirOpen.specf(
-				"scanDive(%q, %d): os.Open(): %s", dispPath, depth, err)
+		// directories completed by an interrupted scan are not scanned again
+		// when resuming it (see interrupt.go).
+		if l.checkpoint.skip(relPath) {
+			l.dirCache.carry(relPath)
+			return nil
 		}
-		dirName, err := dir.Readdirnames(0)
-		dir.Close()
+		// the names of a huge directory are not read here (see dirstream.go).
+		var dirName []string
+		var dirCount int
+		err := l.retryFS(func() (err error) {
+			dirName, dirCount, err = countDir(absPath)
+			return err
+		})
 		if nil != err {
-			return rcDirOpen.specf(
-				"scanDive(%q, %d): dir.Readdirnames(): %s", dispPath, depth, err)
+			return rcDirOpen.wrap(err,
+				"scanDive(%q, %d)", dispPath, depth).
+				at("scanDive", l.name, absPath)
+		}
+
+		// if neither the directory's modification time nor its number of
+		// entries changed since the last scan, then no file was added, removed,
+		// or renamed in it. only its subdirectories need to be scanned.
+		// the entries are scanned in parallel by the workers of this scan's
+		// pool, if any are idle (see scanpool.go).
+		if cached, ok := l.dirCache.unchanged(relPath, fileInfo, dirCount); ok {
+			complete := true
+			group := l.scanPool.group()
+			for _, name := range cached.Subdir {
+				if nil != group.stopped() {
+					break
+				}
+				entryPath := path.Join(absPath, name)
+				group.dive(func() *ReturnCode {
+					return l.scanDive(ctx, ph, entryPath, depth+1)
+				}, func(scanErr *ReturnCode) {
+					warnLog.in(ctx).trace(scanErr)
+					l.recordIssue(entryPath, scanErr)
+					complete = false
+				})
+			}
+			if interrupted := group.finish(); nil != interrupted {
+				return interrupted
+			}
+			if complete {
+				l.checkpoint.complete(relPath)
+			}
+			return nil
 		}
 
 		// recursively scan all of this subdirectory's contents.
-		var scanErr *ReturnCode
-		for _, name := range dirName {
-			scanErr = l.scanDive(ph, path.Join(absPath, name), depth+1)
-			if nil != scanErr {
-				// a file/subdir of the current directory threw an error.
-				warnLog.trace(scanErr)
+		cacheable := true
+		subdir := []string{}
+		var interrupted *ReturnCode
+		scanChunk := func(chunk []string) {
+			if nil != interrupted {
+				return
+			}
+			group := l.scanPool.group()
+			for _, name := range chunk {
+				if nil != group.stopped() {
+					// the remaining entries are left for the next scan.
+					break
+				}
+				entryPath := path.Join(absPath, name)
+				group.dive(func() *ReturnCode {
+					return l.scanDive(ctx, ph, entryPath, depth+1)
+				}, func(scanErr *ReturnCode) {
+					// a file/subdir of the current directory threw an error.
+					warnLog.in(ctx).trace(scanErr)
+					l.recordIssue(entryPath, scanErr)
+					// don't skip this directory next time if any of its entries
+					// could not be examined or stored.
+					if scanErr.is(rcInvalidStat, rcDirOpen, rcDatabaseError, rcQueryError) {
+						cacheable = false
+					}
+				})
+			}
+			// the subdirectories are only recorded once they have been scanned.
+			if interrupted = group.finish(); nil != interrupted {
+				return
+			}
+			subdir = append(subdir, l.dirCache.subdirs(relPath, chunk)...)
+		}
+		if dirCount <= dirChunkSize {
+			scanChunk(dirName)
+		} else {
+			// scan a huge directory as its entries are read, yielding to the
+			// scheduler between chunks and publishing our progress.
+			infoLog.in(ctx).verbosef("scanning large directory: %q (%d entries)", dispPath, dirCount)
+			l.dirProgress.begin(dispPath, dirCount)
+			dirCount, err = l.streamDir(absPath, func(chunk []string) {
+				scanChunk(chunk)
+				l.dirProgress.advance(dispPath, len(chunk))
+				runtime.Gosched()
+			})
+			l.dirProgress.end(dispPath)
+			if nil == interrupted && nil != err {
+				return rcDirOpen.wrap(err,
+					"scanDive(%q, %d)", dispPath, depth).
+					at("scanDive", l.name, absPath)
 			}
 		}
+		if nil != interrupted {
+			return interrupted
+		}
+		// the files held in quarantine must be examined again next time.
+		if cacheable && !l.quarantine.holdsIn(absPath) {
+			l.dirCache.store(relPath, fileInfo, dirCount, subdir)
+			l.checkpoint.complete(relPath)
+		}
 		return nil
 
 	case (mode & os.ModeSymlink) > 0:
-		// symlinks currently unhandled.
+		// symlinks are only followed if enabled.
 		return rcInvalidFile.specf(
-			"scanDive(%q, %d): symlinks not supported! (skipping)", dispPath, depth)
+			"scanDive(%q, %d): symlinks not followed without -followsymlinks (skipping)", dispPath, depth).
+			at("scanDive", l.name, absPath)
 
 	case (mode & (os.ModeDevice | os.ModeNamedPipe | os.ModeSocket | os.ModeCharDevice)) > 0:
 		// file is not a regular file, not supported.
 		return rcInvalidFile.specf(
-			"scanDive(%q, %d): not a regular file (skipping)", dispPath, depth)
+			"scanDive(%q, %d): not a regular file (skipping)", dispPath, depth).
+			at("scanDive", l.name, absPath)
 
 	default:
 		// function seenFile() checks if the file specified by path and kind of
@@ -465,7 +994,7 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 			// perform a simple database query on the appropriate table to check
 			// if we've ever seen this file before based on its absolute path.
 			result := make(map[int]struct{})
-			if err := db.EvalQuery(map[string]interface{}{
+			if err := evalQuery(map[string]interface{}{
 				"eq": path,
 				"in": []interface{}{(*lib.db.index[class][index])[0]},
 			}, lib.db.col[class][kind], &result); nil != err {
@@ -479,26 +1008,50 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 		// files (~my~ media files, at least).
 		ext := path.Ext(absPath)
 
+		// hold back files still being downloaded or written until they are
+		// stable (see quarantine.go).
+		if reason, held := l.quarantine.check(absPath, ext, fileInfo); held {
+			infoLog.in(ctx).tracef("holding file in progress (%s): %s", reason, dispPath)
+			return nil
+		}
+
+		// list the media inside archives, if enabled for this library.
+		if format, ok := l.archiveExt[strings.ToLower(ext)]; ok {
+			return l.scanArchive(ph, format, absPath, relPath, fileInfo)
+		}
+
+		// files of unknown extensions may be classified by their content
+		// instead (see sniff.go).
+		kindExt := l.sniffExt(absPath, ext)
+
 		// check if it looks like a regular media file.
-		switch kind, extName := mediaKindOfFileExt(ext); kind {
+		switch kind, extName := mediaKindOfFile(absPath, kindExt); kind {
 		case mkAudio:
 
-			// select the audio database collection to determine if this is a
+			// query the audio database collection to determine if this is a
 			// previously-known file or if we need to insert a new entity.
-			ac := l.db.col[ecMedia][mkAudio]
 			seen, err := seenFile(l, ecMedia, int(kind), absPath)
 			if err != nil {
 				return rcInvalidFile.specf(
 					"scanDive(%q, %d): failed to evaluate query: %s (skipping)", dispPath, depth, err)
 			}
 			if !seen {
+				// junk merely named like audio is flagged rather than indexed
+				// (see audiocheck.go).
+				if l.checkAudio {
+					if ret := checkAudioHeader(absPath, kindExt); nil != ret {
+						return ret.at("scanDive", l.name, absPath)
+					}
+				}
 				// this is a legitimately unknown file, create a new AudioMedia
 				// entity and insert it into the database.
 				audio := newAudioMedia(l, absPath, relPath, ext, extName, fileInfo)
+				audio.readTags()
 				if rec, recErr := audio.toRecord(); nil == recErr {
-					if id, insErr := ac.Insert(*rec); nil == insErr {
-						l.db.numRecordsScan[ecMedia][kind]++
-						infoLog.tracef("discovered audio (ID={%q,%X}): %s", l.name, id, audio)
+					if id, insErr := l.insertRecord(ecMedia, int(kind), rec, audio); nil == insErr {
+						l.db.countScanned(ecMedia, int(kind))
+						l.addIndexedSize(audio.Size)
+						infoLog.in(ctx).tracef("discovered audio (ID={%q,%X}): %s", l.name, id, audio)
 						if nil != ph && nil != ph.handleMedia {
 							// notify the callback handler of a new AudioMedia.
 							ph.handleMedia(l, absPath, audio, id)
@@ -515,9 +1068,8 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 
 		case mkVideo:
 
-			// select the video database collection to determine if this is a
+			// query the video database collection to determine if this is a
 			// previously-known file or if we need to insert a new entity.
-			vc := l.db.col[ecMedia][mkVideo]
 			seen, err := seenFile(l, ecMedia, int(kind), absPath)
 			if err != nil {
 				return rcInvalidFile.specf(
@@ -528,9 +1080,10 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 				// entity and insert it into the database.
 				video := newVideoMedia(l, absPath, relPath, ext, extName, fileInfo)
 				if rec, recErr := video.toRecord(); nil == recErr {
-					if id, insErr := vc.Insert(*rec); nil == insErr {
-						l.db.numRecordsScan[ecMedia][kind]++
-						infoLog.tracef("discovered video (ID={%q,%X}): %s", l.name, id, video)
+					if id, insErr := l.insertRecord(ecMedia, int(kind), rec, video); nil == insErr {
+						l.db.countScanned(ecMedia, int(kind))
+						l.addIndexedSize(video.Size)
+						infoLog.in(ctx).tracef("discovered video (ID={%q,%X}): %s", l.name, id, video)
 						if nil != ph && nil != ph.handleMedia {
 							// notify the callback handler of a new VideoMedia.
 							ph.handleMedia(l, absPath, video, id)
@@ -549,12 +1102,11 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 
 			// doesn't have an extension typically associated with media files.
 			// check if it is a media-supporting file.
-			switch kind, extName := supportKindOfFileExt(ext); kind {
+			switch kind, extName := supportKindOfFile(absPath, kindExt); kind {
 			case skSubtitles:
-				// select the media support database collection to determine if
+				// query the media support database collection to determine if
 				// this is a previously-known file or if we need to insert a new
 				// entity.
-				sc := l.db.col[ecSupport][skSubtitles]
 				seen, err := seenFile(l, ecSupport, int(kind), absPath)
 				if err != nil {
 					return rcInvalidFile.specf(
@@ -565,9 +1117,9 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 					// support entity and insert it into the database.
 					subs := newSubtitles(l, absPath, relPath, ext, extName, fileInfo)
 					if rec, recErr := subs.toRecord(); nil == recErr {
-						if id, insErr := sc.Insert(*rec); nil == insErr {
-							l.db.numRecordsScan[ecSupport][kind]++
-							infoLog.tracef("discovered subtitles (ID={%q,%X}): %s", l.name, id, subs)
+						if id, insErr := l.insertRecord(ecSupport, int(kind), rec, subs); nil == insErr {
+							l.db.countScanned(ecSupport, int(kind))
+							infoLog.in(ctx).tracef("discovered subtitles (ID={%q,%X}): %s", l.name, id, subs)
 							// notify the callback handler of a new Subtitles.
 							if nil != ph && nil != ph.handleSupport {
 								ph.handleSupport(l, absPath, subs, id)
@@ -582,9 +1134,37 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 					}
 				}
 
+			case skAudioTrack:
+				// same as subtitles above, but with the audio track collection.
+				seen, err := seenFile(l, ecSupport, int(kind), absPath)
+				if err != nil {
+					return rcInvalidFile.specf(
+						"scanDive(%q, %d): failed to evaluate query: %s (skipping)", dispPath, depth, err)
+				}
+				if !seen {
+					track := newAudioTrack(l, absPath, relPath, ext, extName, fileInfo)
+					if rec, recErr := track.toRecord(); nil == recErr {
+						if id, insErr := l.insertRecord(ecSupport, int(kind), rec, track); nil == insErr {
+							l.db.countScanned(ecSupport, int(kind))
+							infoLog.in(ctx).tracef("discovered audio track (ID={%q,%X}): %s", l.name, id, track)
+							// notify the callback handler of a new AudioTrack.
+							if nil != ph && nil != ph.handleSupport {
+								ph.handleSupport(l, absPath, track, id)
+							}
+						} else {
+							return rcDatabaseError.specf(
+								"scanDive(%q, %d): failed to insert record: %s (skipping)", dispPath, depth, insErr)
+						}
+					} else {
+						// failed to construct a new AudioTrack object.
+						return recErr
+					}
+				}
+
 			default:
 				// cannot identify the file, probably an undesirable piece of
 				// trash. well-suited for being ignored.
+				atomic.AddUint64(&l.ignored, 1)
 				if nil != ph && nil != ph.handleOther {
 					ph.handleOther(l, absPath)
 				}
@@ -595,9 +1175,10 @@ func (l *Library) scanDive(ph *PathHandler, absPath string, depth uint) *ReturnC
 }
 
 // function scan() is the entry point for initiating a scan on the library's
-// root file system. currently, the scan is dispatched and cannot be safely
-// interrupted. you must wait for the scan to finish before restarting.
-func (l *Library) scan(handler *PathHandler) (uint, *ReturnCode) {
+// root file system. the scan stops early (with error rcInterrupted) if the
+// given context is cancelled or the library is interrupted, recording a
+// checkpoint from which the next scan resumes (see interrupt.go).
+func (l *Library) scan(ctx context.Context, handler *PathHandler) (uint, *ReturnCode) {
 
 	var (
 		numScan uint = 0 // number of -new- files discovered on file system
@@ -616,9 +1197,18 @@ func (l *Library) scan(handler *PathHandler) (uint, *ReturnCode) {
 
 	// try writing to the buffered channel. this will succeed if and only if it
 	// isn't already filled to capacity.
+	if nil != ctx.Err() {
+		return 0, rcInterrupted.specf("scan(): %q: %s", l.name, ctx.Err())
+	}
 	select {
 	case l.scanStart <- time.Now():
 
+		ctx, end := l.beginOperation(ctx, "scan")
+		defer end()
+
+		ctx, done := l.interruptible(ctx)
+		defer done()
+
 		// notify the user that a potentially time-intensive operation has
 		// begun and user interactions will be limited.
 		if !isCLIMode {
@@ -628,11 +1218,49 @@ func (l *Library) scan(handler *PathHandler) (uint, *ReturnCode) {
 		// the write succeeded, so we can initiate scanning. keep track of the
 		// time at which we began so that the time elapsed can be calculated and
 		// notified to the user.
-		infoLog.verbosef("scanning: %q", l.name)
-		err = l.scanDive(handler, l.absPath, 1)
+		// wait for a scan worker, since scanning many libraries on the same
+		// disk concurrently is usually slower than scanning them in turn.
+		scanWorkers.acquire(l)
+		infoLog.in(ctx).verbosef("scanning: %q", l.name)
+		l.issues.begin(handler)
+		l.retryStats.reset()
+		atomic.StoreUint64(&l.ignored, 0)
+		l.dirCache = l.openDirCache()
+		l.checkpoint = l.openCheckpoint()
+		l.resetSymlinks()
+		l.quarantine.torrent.refresh()
+		l.scanPool = newScanPool(l.dirWorkers)
+		err = l.scanDive(ctx, handler, l.absPath, 1)
+		l.scanPool = nil
+		if nil != err && err.is(rcInterrupted) {
+			// keep what is known of the directories not reached this time,
+			// and record where to resume.
+			l.dirCache.merge()
+			if ret := l.saveDirCache(l.dirCache); nil != ret {
+				warnLog.in(ctx).verbose(ret)
+			}
+			if ret := l.saveCheckpoint(l.checkpoint); nil != ret {
+				warnLog.in(ctx).verbose(ret)
+			} else {
+				infoLog.in(ctx).logf("scan interrupted, will resume on next scan: %q", l.name)
+			}
+		} else {
+			l.recordIssue(l.absPath, err)
+		}
 		if nil == err {
+			l.removeCheckpoint()
+			if ret := l.saveDirCache(l.dirCache); nil != ret {
+				warnLog.in(ctx).verbose(ret)
+			}
 			l.recandidateSubtitles(false)
+			l.recandidateAudioTracks(false)
+			// record what changed on the file system since the last scan.
+			if ret := l.recordSnapshot(); nil != ret {
+				warnLog.in(ctx).verbose(ret)
+			}
 		}
+		l.checkpoint = nil
+		scanWorkers.release()
 
 		// we've finished the scanning operations, so remove the busy indicator
 		// to indicate that normal user interactions may resume (if no other
@@ -646,16 +1274,29 @@ func (l *Library) scan(handler *PathHandler) (uint, *ReturnCode) {
 		// construct a summary message for the load operation.
 		total, summary := l.db.totalRecordsString(dmScan, -1, -1)
 		if total > 0 {
-			infoLog.verbosef(
+			infoLog.in(ctx).verbosef(
 				"finished scanning: %q (%s found in %s)",
 				l.name, summary, l.scanElapsed.Round(time.Millisecond))
 		} else {
-			infoLog.verbosef(
+			infoLog.in(ctx).verbosef(
 				"finished scanning: %q (no new media found in %s)",
 				l.name, l.scanElapsed.Round(time.Millisecond))
 		}
 		numScan = total
 
+		// transient errors are otherwise only visible in the trace log.
+		if atomic.LoadUint64(&l.retryStats.retries) > 0 {
+			infoLog.in(ctx).verbosef("file system retries while scanning: %q (%s)",
+				l.name, l.retryStats)
+		}
+
+		// now that we know the total size of everything indexed, verify the
+		// library hasn't outgrown any of its user-defined size budgets.
+		l.checkBudget()
+
+		// examine the files found in progress again once they have settled.
+		l.scheduleRescan()
+
 	default:
 		// if the write failed, we fall back to this default case. the only
 		// reason it should fail is if the buffer is already filled to capacity,
@@ -0,0 +1,709 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: linemode.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the line-oriented interactive mode (option -accessible), an
+//    alternative to the textual user interface for screen readers and other
+//    assistive technology. everything is written as plain lines of text: no
+//    cursor addressing, colors, or redrawing. the user browses, searches, and
+//    plays media by typing commands at a prompt and choosing items by number
+//    from numbered menus.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// local unexported constants for the line-oriented mode.
+const (
+	linePrompt   = "pimm> "
+	linePageSize = 10 // number of items listed per page
+)
+
+// type LineItem is a media item listed in the line-oriented mode.
+type LineItem struct {
+	library *Library
+	media   *Media
+	object  interface{} // the *AudioMedia or *VideoMedia
+	id      int         // ID of the database record (-1 = unknown)
+}
+
+// type LineMode is the state of the line-oriented interactive mode.
+type LineMode struct {
+	sync.Mutex
+	in      *bufio.Reader
+	out     io.Writer
+	busy    *BusyState  // busy indicator of scanning libraries
+	library []*Library  // all open libraries
+	active  *Library    // library whose items are listed (nil = all)
+	item    []*LineItem // every item discovered so far
+	query   string      // search text the listed items must contain
+	view    []*LineItem // items currently listed, numbered from 1
+	seen    int         // number of items discovered when last listed
+	page    int         // index of the first item of the current page
+}
+
+// function newLineMode() creates the line-oriented mode for the given
+// libraries, reading commands from standard input.
+func newLineMode(busyState *BusyState, library []*Library) *LineMode {
+	return &LineMode{
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+		busy:    busyState,
+		library: library,
+		active:  nil,
+		item:    []*LineItem{},
+		query:   "",
+		view:    []*LineItem{},
+		seen:    0,
+		page:    0,
+	}
+}
+
+// function addDiscovery() is the DiscoverySink of the line-oriented mode. new
+// items are not listed until the next "list" or "search" command, so that the
+// numbers of the items already listed do not change under the user.
+func (m *LineMode) addDiscovery(lib *Library, disco *Discovery) *ReturnCode {
+
+	item := &LineItem{library: lib, object: disco.data[0], id: -1}
+	if len(disco.data) > 1 {
+		if id, ok := disco.data[1].(int); ok {
+			item.id = id
+		}
+	}
+	switch disco.data[0].(type) {
+	case *AudioMedia:
+		item.media = disco.data[0].(*AudioMedia).Media
+	case *VideoMedia:
+		item.media = disco.data[0].(*VideoMedia).Media
+	default:
+		return nil // only media are listed
+	}
+	m.Lock()
+	m.item = append(m.item, item)
+	m.Unlock()
+	return nil
+}
+
+// function say() writes a single line of text.
+func (m *LineMode) say(format string, arg ...interface{}) {
+	fmt.Fprintf(m.out, format+newLine, arg...)
+}
+
+// function show() runs the line-oriented mode until the user quits or closes
+// standard input.
+func (m *LineMode) show() *ReturnCode {
+
+	for _, l := range m.library {
+		l.attach(m.addDiscovery)
+	}
+	m.say("line mode. type \"help\" for a list of commands.")
+	m.filter()
+	m.say("%d items in %d libraries.", len(m.view), len(m.library))
+	go m.watchBusy()
+
+	for {
+		fmt.Fprint(m.out, linePrompt)
+		line, err := m.in.ReadString('\n')
+		if nil != err && "" == line {
+			m.say("")
+			return nil // end of input
+		}
+		field := strings.Fields(line)
+		if 0 == len(field) {
+			continue
+		}
+		cmd, arg := strings.ToLower(field[0]), strings.Join(field[1:], " ")
+		switch cmd {
+		case "h", "help", "?":
+			m.help()
+		case "q", "quit", "exit":
+			return nil
+		case "lib", "libraries":
+			m.selectLibrary(arg)
+		case "l", "list":
+			m.filter()
+			m.list()
+		case "n", "next":
+			m.turn(+1)
+		case "p", "prev", "previous":
+			m.turn(-1)
+		case "s", "search", "find":
+			m.query = arg
+			m.filter()
+			m.list()
+		case "i", "info":
+			if item := m.choose(arg); nil != item {
+				m.info(item)
+			}
+		case "play":
+			if item := m.choose(arg); nil != item {
+				m.play(item)
+			}
+		case "bookmark", "unbookmark", "resume":
+			m.bookmark(cmd, field[1:])
+		case "field":
+			m.setField(field[1:])
+		case "target":
+			m.selectTarget(arg)
+		case "scan":
+			m.scanFolder(arg)
+		case "stop":
+			m.stopScan()
+		case "reload":
+			reloadConfig().report()
+		case "prune":
+			m.removeMissing()
+		case "undelete":
+			m.undoRemoval()
+		default:
+			m.say("unknown command: %q. type \"help\" for a list of commands.", cmd)
+		}
+	}
+}
+
+// function watchBusy() receives every change of the busy indicator, which would
+// otherwise block the scanners, and announces the items found once no library
+// is busy.
+func (m *LineMode) watchBusy() {
+	for count := range m.busy.changed {
+		if 0 != count {
+			continue
+		}
+		m.Lock()
+		found := len(m.item) - m.seen
+		m.Unlock()
+		if found > 0 {
+			m.say("%d items found since last listed. type \"list\" to see them.", found)
+		}
+	}
+}
+
+// function help() lists the commands of the line-oriented mode.
+func (m *LineMode) help() {
+	m.say("commands:")
+	m.say("  libraries        list the libraries, numbered")
+	m.say("  libraries N      show only library number N (0 = all libraries)")
+	m.say("  list             list the items, %d per page", linePageSize)
+	m.say("  next, prev       list the next or previous page of items")
+	m.say("  search TEXT      list only the items whose name, path, or notes contain TEXT (no TEXT = all items)")
+	m.say("  search NAME=VALUE  list only the items whose user-defined field NAME equals VALUE")
+	m.say("  info N           describe item number N")
+	m.say("  play N           play item number N")
+	m.say("  bookmark N POS NAME  save position POS (H:MM:SS) of audiobook number N as bookmark NAME")
+	m.say("  unbookmark N NAME    remove bookmark NAME of item number N")
+	m.say("  resume N NAME    play item number N from its bookmark NAME")
+	m.say("  field N NAME VALUE  set user-defined field NAME of item number N to VALUE (no VALUE = remove it)")
+	m.say("  target           list the playback targets, numbered")
+	m.say("  target N         play on target number N")
+	m.say("  scan N           scan the folder of item number N now")
+	m.say("  scan PATH        scan the folder PATH now (relative to the selected library, or absolute)")
+	m.say("  stop             interrupt the scans of the selected library (resumed on the next scan)")
+	m.say("  reload           reload the config file, applying the changes safe to apply while running")
+	m.say("  prune            remove the items whose files no longer exist from the selected library")
+	m.say("  undelete         restore the items removed by the most recent prune of the selected library")
+	m.say("  help             show this list")
+	m.say("  quit             exit")
+}
+
+// function selectLibrary() lists the libraries, or selects the one with the
+// given number.
+func (m *LineMode) selectLibrary(arg string) {
+
+	if "" == arg {
+		m.say("%d libraries:", len(m.library))
+		m.say("  0. all libraries")
+		for i, l := range m.library {
+			state := ""
+			if l == m.active {
+				state = " (selected)"
+			}
+			m.say("  %d. %s%s", i+1, l.name, state)
+		}
+		return
+	}
+	n, err := strconv.Atoi(arg)
+	if nil != err || n < 0 || n > len(m.library) {
+		m.say("no such library: %q. choose a number from 0 to %d.", arg, len(m.library))
+		return
+	}
+	if 0 == n {
+		m.active = nil
+		m.say("selected all libraries.")
+	} else {
+		m.active = m.library[n-1]
+		m.say("selected library %s.", m.active.name)
+	}
+	termTitle.setActive(m.active)
+	preferLibrary(m.active)
+	m.filter()
+	m.list()
+}
+
+// function scanFolder() scans the folder of the item with the given number, or
+// the folder with the given path, waiting for the scan to finish.
+func (m *LineMode) scanFolder(arg string) {
+
+	if "" == arg {
+		m.say("scan which folder? give an item number or a path.")
+		return
+	}
+	var lib *Library
+	var dir string
+	if _, err := strconv.Atoi(arg); nil == err {
+		item := m.choose(arg)
+		if nil == item {
+			return
+		}
+		lib, dir = item.library, filepath.Dir(item.media.AbsPath)
+	} else {
+		dir = arg
+		if !filepath.IsAbs(dir) {
+			if nil == m.active {
+				m.say("select a library first, or give an absolute path.")
+				return
+			}
+			dir = filepath.Join(m.active.absPath, dir)
+		}
+		dir = filepath.Clean(dir)
+		for _, l := range m.library {
+			if rel, err := filepath.Rel(l.absPath, dir); nil == err &&
+				".." != rel && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				lib = l
+				break
+			}
+		}
+		if nil == lib {
+			m.say("%s is not in any library.", dir)
+			return
+		}
+	}
+	m.say("scanning %s.", dir)
+	found, ret := lib.scanSubtree(dir)
+	if nil != ret {
+		m.say("scan failed: %s", ret)
+		return
+	}
+	m.say("finished scanning %s: %d new found.", dir, found)
+}
+
+// function stopScan() interrupts the scans in progress of the selected library,
+// or of all libraries if none is selected.
+func (m *LineMode) stopScan() {
+
+	stopped := 0
+	for _, l := range m.library {
+		if (nil == m.active || l == m.active) && l.isScanning() && l.interruptAll() {
+			stopped++
+		}
+	}
+	if 0 == stopped {
+		m.say("no scan in progress.")
+		return
+	}
+	m.say("interrupted %d scans. they will resume where they left off on the next scan.", stopped)
+}
+
+// function removeMissing() removes the items whose files were found missing
+// from the selected library, or from all libraries if none is selected, along
+// with their database records.
+func (m *LineMode) removeMissing() {
+
+	total := 0
+	for _, l := range m.library {
+		if nil != m.active && l != m.active {
+			continue
+		}
+		if _, _, loading := l.loadProgress(); loading || l.isScanning() {
+			m.say("library %s is busy. try again once it is ready.", l.name)
+			continue
+		}
+		removed, ret := l.removeMissing()
+		if nil != ret {
+			m.say("cannot remove missing items of %s: %s", l.name, ret)
+		}
+		if 0 == len(removed) {
+			continue
+		}
+		total += len(removed)
+		m.Lock()
+		item := []*LineItem{}
+		for _, i := range m.item {
+			if i.library != l || !removed[i.media.AbsPath] {
+				item = append(item, i)
+			}
+		}
+		m.item = item
+		m.Unlock()
+	}
+	if 0 == total {
+		m.say("no items with missing files.")
+		return
+	}
+	m.filter()
+	m.say("removed %d items whose files no longer exist.", total)
+}
+
+// function undoRemoval() restores the items removed by the most recent removal
+// of the selected library, or of all libraries if none is selected.
+func (m *LineMode) undoRemoval() {
+
+	total := 0
+	for _, l := range m.library {
+		if nil != m.active && l != m.active {
+			continue
+		}
+		count, ret := l.undoRemoval(&PathHandler{
+			handleMedia: func(l *Library, p string, v ...interface{}) {
+				m.addDiscovery(l, newDiscovery(v...))
+			},
+		})
+		if nil != ret {
+			m.say("cannot restore the items removed from %s: %s", l.name, ret)
+		}
+		total += count
+	}
+	if 0 == total {
+		m.say("no removed items to restore.")
+		return
+	}
+	m.filter()
+	m.say("restored %d removed items.", total)
+}
+
+// function selectTarget() lists the playback targets, or selects the one with
+// the given number (or name).
+func (m *LineMode) selectTarget(arg string) {
+
+	known, curr := playbackTargets()
+	if "" == arg {
+		m.say("%d playback targets:", len(known))
+		for i, t := range known {
+			state := ""
+			if t == curr {
+				state = " (selected)"
+			}
+			m.say("  %d. %s%s", i+1, t.desc(), state)
+		}
+		return
+	}
+	target := findTarget(known, arg)
+	if n, err := strconv.Atoi(arg); nil == err && n > 0 && n <= len(known) {
+		target = known[n-1]
+	}
+	if nil == target {
+		m.say("no such playback target: %q. choose a number from 1 to %d.", arg, len(known))
+		return
+	}
+	if ret := selectTarget(target); nil != ret {
+		m.say("cannot remember playback target: %s", ret)
+	}
+	m.say("playing on %s.", target.desc())
+}
+
+// function filter() rebuilds the list of items in the selected library that
+// contain the search text, starting over from the first page.
+func (m *LineMode) filter() {
+
+	m.Lock()
+	defer m.Unlock()
+
+	// a query of the form NAME=VALUE searches the user-defined field NAME.
+	field := parseFieldQuery(m.query, m.library)
+	found := map[*Library][mkCOUNT]map[int]bool{}
+	query := strings.ToLower(m.query)
+
+	m.view = []*LineItem{}
+	for _, item := range m.item {
+		if nil != m.active && item.library != m.active {
+			continue
+		}
+		if nil != field {
+			id, ok := found[item.library]
+			if !ok {
+				id = field.find(item.library)
+				found[item.library] = id
+			}
+			if item.id < 0 || !id[item.media.Kind][item.id] {
+				continue
+			}
+		} else if "" != query &&
+			!strings.Contains(strings.ToLower(item.media.Name), query) &&
+			!strings.Contains(strings.ToLower(item.media.Title), query) &&
+			!strings.Contains(strings.ToLower(item.media.RelPath), query) &&
+			!strings.Contains(strings.ToLower(item.media.Notes), query) {
+			continue
+		}
+		m.view = append(m.view, item)
+	}
+	m.seen = len(m.item)
+	m.page = 0
+}
+
+// function list() lists the current page of items.
+func (m *LineMode) list() {
+
+	if 0 == len(m.view) {
+		if "" != m.query {
+			m.say("no items contain %q.", m.query)
+		} else {
+			m.say("no items.")
+		}
+		return
+	}
+	last := m.page + linePageSize
+	if last > len(m.view) {
+		last = len(m.view)
+	}
+	m.say("items %d to %d of %d:", m.page+1, last, len(m.view))
+	for i := m.page; i < last; i++ {
+		m.say("  %d. %s", i+1, m.view[i].media.Name)
+	}
+	if last < len(m.view) {
+		m.say("type \"next\" for more.")
+	}
+}
+
+// function turn() lists the next (dir > 0) or previous (dir < 0) page of items.
+func (m *LineMode) turn(dir int) {
+
+	page := m.page + dir*linePageSize
+	if page < 0 || page >= len(m.view) {
+		if dir > 0 {
+			m.say("no more items.")
+		} else {
+			m.say("already at the first page.")
+		}
+		return
+	}
+	m.page = page
+	m.list()
+}
+
+// function choose() returns the listed item with the given number, or nil if
+// there is no such item.
+func (m *LineMode) choose(arg string) *LineItem {
+
+	n, err := strconv.Atoi(arg)
+	if nil != err || n < 1 || n > len(m.view) {
+		if 0 == len(m.view) {
+			m.say("no items are listed. type \"list\" first.")
+		} else {
+			m.say("no such item: %q. choose a number from 1 to %d.", arg, len(m.view))
+		}
+		return nil
+	}
+	return m.view[n-1]
+}
+
+// function info() describes the given item, one property per line.
+func (m *LineMode) info(item *LineItem) {
+
+	media := item.media
+	m.say("name: %s", media.Name)
+	if "" != media.Title && media.Name != media.Title {
+		m.say("title: %s", media.Title)
+	}
+	m.say("kind: %s", strings.ToLower(mediaColName[media.Kind]))
+	m.say("library: %s", item.library.name)
+	m.say("path: %s", media.RelPath)
+	if item.library.isMissing(media.AbsPath) {
+		m.say("file missing: %s", media.AbsPath)
+	}
+	m.say("size: %s", formatSizeApprox(uint64(media.Size)))
+	m.say("added: %s", formatTime(media.TimeAdded))
+	if audio, ok := item.object.(*AudioMedia); ok {
+		if "" != audio.Album {
+			m.say("album: %s, track %d", audio.Album, audio.Track)
+		}
+		for _, b := range audio.Bookmarks {
+			m.say("bookmark: %s at %s", b.Name, b.timestamp())
+		}
+	}
+	if "" != strings.Trim(media.Description, "- ") {
+		m.say("description: %s", media.Description)
+	}
+	if len(media.Tags) > 0 {
+		m.say("tags: %s", strings.Join(media.Tags, ", "))
+	}
+	for _, name := range media.sortedFieldNames() {
+		m.say("%s: %s", name, fieldText(media.Fields[name]))
+	}
+	for _, line := range strings.Split(media.Notes, "\n") {
+		if "" != line {
+			m.say("notes: %s", line)
+		}
+	}
+}
+
+// function play() runs the playback command of the given item, waiting for it
+// to finish. the player shares our terminal.
+func (m *LineMode) play(item *LineItem) {
+
+	cmd := item.media.baseCommand()
+	if video, ok := item.object.(*VideoMedia); ok {
+		cmd = item.library.playbackCommand(video)
+	}
+	m.run(item, cmd)
+}
+
+// function bookmark() performs the given bookmark command ("bookmark",
+// "unbookmark", or "resume") with the given arguments, the first of which is
+// the number of an audio item.
+func (m *LineMode) bookmark(cmd string, arg []string) {
+
+	usage := map[string]string{
+		"bookmark":   "bookmark N POS NAME",
+		"unbookmark": "unbookmark N NAME",
+		"resume":     "resume N NAME",
+	}
+	count := 2
+	if "bookmark" == cmd {
+		count = 3
+	}
+	if len(arg) < count {
+		m.say("usage: %s", usage[cmd])
+		return
+	}
+	item := m.choose(arg[0])
+	if nil == item {
+		return
+	}
+	audio, ok := item.object.(*AudioMedia)
+	if !ok {
+		m.say("bookmarks may only be kept for audio.")
+		return
+	}
+	if "resume" == cmd {
+		resume, ret := audio.resumeCommand(strings.Join(arg[1:], " "))
+		if nil != ret {
+			m.say("cannot resume %s: %s", audio.Name, ret)
+			return
+		}
+		m.run(item, resume)
+		return
+	}
+	if item.id < 0 {
+		m.say("cannot change bookmarks of %s: its database record is unknown.", audio.Name)
+		return
+	}
+	col := item.library.db.col[ecMedia][mkAudio]
+	if "unbookmark" == cmd {
+		name := strings.Join(arg[1:], " ")
+		if found, ret := audio.removeBookmark(col, item.id, true, name); nil != ret {
+			m.say("cannot remove bookmark: %s", ret)
+		} else if !found {
+			m.say("no such bookmark: %q. type \"info %s\" to list them.", name, arg[0])
+		} else {
+			m.say("removed bookmark %q of %s.", name, audio.Name)
+		}
+		return
+	}
+	// bookmarks are only useful for long-form audio, but any already saved
+	// remain available even if the audio no longer looks like an audiobook.
+	if !audio.isAudiobook() && 0 == len(audio.Bookmarks) {
+		m.say("bookmarks may only be kept for audiobooks (%s files, or at least %s).",
+			strings.Join(audiobookExt, ", "), formatSizeApprox(uint64(minAudiobookSize)))
+		return
+	}
+	pos, err := parsePosition(arg[1])
+	if nil != err {
+		m.say("cannot save bookmark: %s", err)
+		return
+	}
+	mark, ret := audio.addBookmark(col, item.id, true, strings.Join(arg[2:], " "), pos)
+	if nil != ret {
+		m.say("cannot save bookmark: %s", ret)
+		return
+	}
+	m.say("saved bookmark %s of %s.", mark, audio.Name)
+}
+
+// function setField() sets the user-defined field with the given name of the
+// item with the given number, or removes it if no value is given. the first
+// argument is the item number, and the second the field name.
+func (m *LineMode) setField(arg []string) {
+
+	if len(arg) < 2 {
+		m.say("usage: field N NAME [VALUE]")
+		return
+	}
+	item := m.choose(arg[0])
+	if nil == item {
+		return
+	}
+	if 0 == len(item.library.fields) {
+		m.say("no fields are declared for library %s (see option -field).", item.library.name)
+		return
+	}
+	if item.id < 0 {
+		m.say("cannot change fields of %s: its database record is unknown.", item.media.Name)
+		return
+	}
+	entity, ok := item.object.(StorableEntity)
+	if !ok {
+		m.say("cannot change fields of %s: media is not storable.", item.media.Name)
+		return
+	}
+	name, value := arg[1], strings.Join(arg[2:], " ")
+	prev := map[string]interface{}{}
+	for n, val := range item.media.Fields {
+		prev[n] = val
+	}
+	if ret := item.media.setField(item.library, name, value); nil != ret {
+		m.say("cannot set field: %s", ret)
+		return
+	}
+	rec, ret := entity.toRecord()
+	if nil == ret {
+		col := item.library.db.col[ecMedia][item.media.Kind]
+		if err := col.Update(item.id, *rec); nil != err {
+			ret = rcDatabaseError.specf(
+				"setField(%q, %d): failed to update record: %s", item.media.AbsName, item.id, err)
+		}
+	}
+	if nil != ret {
+		item.media.Fields = prev
+		m.say("cannot set field: %s", ret)
+	} else if "" == value {
+		m.say("removed field %s of %s.", name, item.media.Name)
+	} else {
+		m.say("set field %s of %s to %s.", name, item.media.Name, fieldText(item.media.Fields[name]))
+	}
+}
+
+// function run() runs the given playback command of the given item, waiting
+// for it to finish. the player shares our terminal.
+func (m *LineMode) run(item *LineItem, cmd string) {
+
+	if "" == cmd {
+		m.say("cannot play %s: no playback command is configured.", item.media.Name)
+		return
+	}
+	if ret := extractMedia(item.media.AbsPath); nil != ret {
+		m.say("cannot play %s: %s", item.media.Name, ret)
+		return
+	}
+	m.say("playing %s.", item.media.Name)
+	run := playerCommand(cmd)
+	run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := run.Run(); nil != err {
+		m.say("playback failed: %s", err)
+		return
+	}
+	m.say("finished playing %s.", item.media.Name)
+}
@@ -0,0 +1,118 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: lock.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the lock file held in each library database directory while the
+//    database is open, so that two processes never open the same database at
+//    once (which would corrupt it). a lock left behind by a process which is no
+//    longer running is stale, and is taken over.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// local unexported constants for the database lock files.
+const (
+	databaseLockFileName  = "db.lock" // contains the ID of the process holding the lock
+	databaseLockFilePerms = 0644
+)
+
+var (
+	// variable heldLocks are the paths of all lock files created by this
+	// process which have not yet been removed.
+	heldLocks = struct {
+		sync.Mutex
+		path map[string]bool
+	}{path: map[string]bool{}}
+)
+
+// function lockOwner() returns the ID of the process which created the lock
+// file at the given path, or false if it cannot be read.
+func lockOwner(p string) (int, bool) {
+	data, err := ioutil.ReadFile(p)
+	if nil != err {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if nil != err {
+		return 0, false
+	}
+	return pid, true
+}
+
+// function lockDatabase() creates the lock file in the given database
+// directory. returns rcLockHeld if some running process already holds it.
+func lockDatabase(dir string) *ReturnCode {
+
+	p := filepath.Join(dir, databaseLockFileName)
+	for {
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, databaseLockFilePerms)
+		if nil == err {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			heldLocks.Lock()
+			heldLocks.path[p] = true
+			heldLocks.Unlock()
+			return nil
+		}
+		if !os.IsExist(err) {
+			return rcInvalidDatabase.wrap(err, "lockDatabase(%q)", dir).
+				at("lockDatabase", "", dir)
+		}
+
+		// a lock file that cannot be read was probably being written by a
+		// process that just created it, so assume it is held.
+		pid, ok := lockOwner(p)
+		if !ok || pid == os.Getpid() || processExists(pid) {
+			return rcLockHeld.specf("lockDatabase(%q): database is in use by process %d (or remove %q)",
+				dir, pid, p).at("lockDatabase", "", dir)
+		}
+		warnLog.logf("removing stale database lock of process %d (no longer running): %q", pid, p)
+		if err := os.Remove(p); nil != err && !os.IsNotExist(err) {
+			return rcLockHeld.wrap(err, "lockDatabase(%q): cannot remove stale lock", dir).
+				at("lockDatabase", "", dir)
+		}
+	}
+}
+
+// function unlockDatabase() removes the lock file from the given database
+// directory, if it was created by this process.
+func unlockDatabase(dir string) {
+	p := filepath.Join(dir, databaseLockFileName)
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	if heldLocks.path[p] {
+		delete(heldLocks.path, p)
+		if err := os.Remove(p); nil != err && !os.IsNotExist(err) {
+			warnLog.logf("failed to remove database lock: %q: %s", p, err)
+		}
+	}
+}
+
+// function unlockAll() removes every lock file created by this process. it is
+// called once the program is ready to exit.
+func unlockAll() {
+	heldLocks.Lock()
+	held := []string{}
+	for p := range heldLocks.path {
+		held = append(held, filepath.Dir(p))
+	}
+	heldLocks.Unlock()
+	for _, dir := range held {
+		unlockDatabase(dir)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"regexp"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 )
 
 // type ConsoleLog represents an object that logs data to one of the output
@@ -30,6 +31,9 @@ type ConsoleLog struct {
 	prefix  string
 	console io.Writer
 	writer  io.Writer
+	repeat  *LogRepeat
+	tag     string      // prefix of each line (see function in())
+	base    *ConsoleLog // logger outputting the tagged lines (nil if untagged)
 	*log.Logger
 	*sync.Mutex
 }
@@ -95,9 +99,11 @@ var consoleLog = [liCOUNT]*ConsoleLog{
 // single instantiation of each of the loggers for all goroutines to share
 // indirectly through use of the exported subroutines below.
 var (
-	// flags used by loggers -only- for determining verbosity
-	isVerboseLog bool
-	isTraceLog   bool
+	// flags used by loggers -only- for determining verbosity. the verbosity
+	// changes if the config file is reloaded, so these flags are only accessed
+	// atomically (see functions isVerbose() and isTrace()).
+	isVerboseLog uint32
+	isTraceLog   uint32
 	isCLIMode    bool
 
 	rawLog  *ConsoleLog = consoleLog[liRaw]
@@ -113,6 +119,7 @@ func newConsoleLog(prefix string, writer io.Writer, logger *log.Logger) *Console
 		prefix:  prefix,
 		console: writer, // retain this as a fallback, don't ever overwrite.
 		writer:  writer,
+		repeat:  newLogRepeat(),
 		Logger:  logger,
 		Mutex:   new(sync.Mutex),
 	}
@@ -157,6 +164,10 @@ func resetWriterAll() {
 // stop in the call stack for all of the logging subroutines exported by this
 // unit, so any global formatting or handling should be performed here.
 func (l *ConsoleLog) output(d, s string) {
+	if nil != l.base {
+		l.base.output(d, l.tag+s)
+		return
+	}
 	if true /* toggles printing globally */ {
 		if l != rawLog {
 			if d == "" {
@@ -168,53 +179,94 @@ func (l *ConsoleLog) output(d, s string) {
 	}
 }
 
+// function isVerbose() returns true if verbose logging is enabled.
+func isVerbose() bool {
+	return 0 != atomic.LoadUint32(&isVerboseLog)
+}
+
+// function isTrace() returns true if trace logging is enabled.
+func isTrace() bool {
+	return 0 != atomic.LoadUint32(&isTraceLog)
+}
+
+// function describe() returns the given log arguments, replacing each
+// ReturnCode with a description that includes its operation, library, and path
+// while trace logging is enabled.
+func describe(v []interface{}) []interface{} {
+	if !isTrace() {
+		return v
+	}
+	d := make([]interface{}, len(v))
+	for i, a := range v {
+		d[i] = a
+		if c, ok := a.(*ReturnCode); ok && nil != c {
+			if f := c.fields(); "" != f {
+				d[i] = fmt.Sprintf("%s {%s}", c, f)
+			}
+		}
+	}
+	return d
+}
+
 // function log() outputs a given string using the current properties of the
 // logger and each of the variable-number-of arguments.
 func (l *ConsoleLog) log(v ...interface{}) {
-	s := fmt.Sprint(v...)
-	l.output(logDelimNormal, s)
+	s := fmt.Sprint(describe(v)...)
+	if !l.suppress("", v, logDelimNormal, s) {
+		l.output(logDelimNormal, s)
+	}
 }
 
 // function logf() outputs a given string using the current properties of the
 // logger and any specified printf-style format string + arguments.
 func (l *ConsoleLog) logf(format string, v ...interface{}) {
 	s := fmt.Sprintf(format, v...)
-	l.output(logDelimNormal, s)
+	if !l.suppress(format, nil, logDelimNormal, s) {
+		l.output(logDelimNormal, s)
+	}
 }
 
 // function verbose() is a wrapper for function log() that will prevent the
 // data from being output unless the verbose or trace flags are set.
 func (l *ConsoleLog) verbose(v ...interface{}) {
-	if isVerboseLog || isTraceLog || !areOptionsParsed {
-		s := fmt.Sprint(v...)
-		l.output(logDelimVerbose, s)
+	if isVerbose() || isTrace() || !areOptionsParsed {
+		s := fmt.Sprint(describe(v)...)
+		if !l.suppress("", v, logDelimVerbose, s) {
+			l.output(logDelimVerbose, s)
+		}
 	}
 }
 
 // function verbosef() is a wrapper for function logf() that will prevent the
 // data from being output unless the verbose or trace flags are set.
 func (l *ConsoleLog) verbosef(format string, v ...interface{}) {
-	if isVerboseLog || isTraceLog || !areOptionsParsed {
+	if isVerbose() || isTrace() || !areOptionsParsed {
 		s := fmt.Sprintf(format, v...)
-		l.output(logDelimVerbose, s)
+		if !l.suppress(format, nil, logDelimVerbose, s) {
+			l.output(logDelimVerbose, s)
+		}
 	}
 }
 
 // function trace() is a wrapper for function log() that will prevent the
 // data from being output unless the trace flag is set.
 func (l *ConsoleLog) trace(v ...interface{}) {
-	if isTraceLog || !areOptionsParsed {
-		s := fmt.Sprint(v...)
-		l.output(logDelimTrace, s)
+	if isTrace() || !areOptionsParsed {
+		s := fmt.Sprint(describe(v)...)
+		if !l.suppress("", v, logDelimTrace, s) {
+			l.output(logDelimTrace, s)
+		}
 	}
 }
 
 // function tracef() is a wrapper for function logf() that will prevent the
 // data from being output unless the trace flag is set.
 func (l *ConsoleLog) tracef(format string, v ...interface{}) {
-	if isTraceLog || !areOptionsParsed {
+	if isTrace() || !areOptionsParsed {
 		s := fmt.Sprintf(format, v...)
-		l.output(logDelimTrace, s)
+		if !l.suppress(format, nil, logDelimTrace, s) {
+			l.output(logDelimTrace, s)
+		}
 	}
 }
 
@@ -229,17 +281,18 @@ func (l *ConsoleLog) logStackTrace() {
 	}
 }
 
-// function die() outputs the details of a given ReturnCode object, and then
-// terminates program execution with the ReturnCode object's return value. the
-// output from this method is always printed to the terminal regardless of
-// whichever io.Writer was defined for the logger.
-func (l *ConsoleLog) die(c *ReturnCode, trace bool) {
+// function die() outputs the details of a given error, and then terminates
+// program execution with the return value of the ReturnCode it wraps (see
+// function returnCodeOf()). the output from this method is always printed to
+// the terminal regardless of whichever io.Writer was defined for the logger.
+func (l *ConsoleLog) die(err error, trace bool) {
 	l.resetWriter()
 	isCLIMode = true
-	if rcUsage != c {
-		s := fmt.Sprintf("%s", error(c))
+	c := returnCodeOf(err)
+	if !c.is(rcUsage) {
+		s := fmt.Sprint(describe([]interface{}{c})...)
 		l.output("", s)
-		if trace && isTraceLog {
+		if trace && isTrace() {
 			l.logStackTrace()
 		}
 	}
@@ -0,0 +1,202 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: logpump.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the log pump between the loggers and the log view of the user
+//    interface. writing each log line straight into the view contends with its
+//    draw loop, slowing down whichever goroutine is logging (e.g. a scanner).
+//    instead, the loggers write into the pump, which never blocks: lines are
+//    held in a bounded buffer and written into the view in batches by a single
+//    goroutine. if the buffer fills faster than it drains, the oldest lines are
+//    dropped (and the number dropped is reported in the view).
+//
+//    the log view itself only retains the most recent lines (option -loglines),
+//    so the pump also writes every line, as it is written, to a file with the
+//    complete log: the file given with option -log, or else the full log file
+//    in the configuration directory, created when the log view is attached.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// local unexported constants for the log pump.
+const (
+	logPumpCapacity     = 4096                  // max lines buffered before dropping
+	logPumpInterval     = 50 * time.Millisecond // min period between batches
+	defaultLogViewLines = 10000                 // max lines retained by the log view
+)
+
+// type LogPump is a non-blocking io.Writer that forwards each line written to
+// another io.Writer from a single goroutine.
+type LogPump struct {
+	sync.Mutex
+	target  io.Writer     // where the lines are forwarded
+	full    io.Writer     // where every line is written right away (optional)
+	line    [][]byte      // lines pending, oldest first
+	dropped int           // number of lines dropped since the last batch
+	wake    chan struct{} // signals lines are pending
+	stop    chan struct{} // closed to stop forwarding
+	done    chan struct{} // closed once stopped
+}
+
+// function newLogPump() creates a new LogPump forwarding to the given target,
+// and starts forwarding.
+func newLogPump(target io.Writer) *LogPump {
+	p := &LogPump{
+		target:  target,
+		full:    nil,
+		line:    [][]byte{},
+		dropped: 0,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.pump()
+	return p
+}
+
+// function tee() writes every line, from now on, to the given io.Writer as it
+// is written to the pump, before it is forwarded (and possibly dropped).
+func (p *LogPump) tee(w io.Writer) {
+	p.Lock()
+	defer p.Unlock()
+	p.full = w
+}
+
+// function Write() buffers the given line to be forwarded, dropping the oldest
+// line buffered if full. it never blocks on the target.
+func (p *LogPump) Write(b []byte) (int, error) {
+
+	// the log.Logger reuses its buffer, so the line must be copied.
+	line := append([]byte{}, b...)
+
+	p.Lock()
+	if nil != p.full {
+		p.full.Write(b)
+	}
+	if len(p.line) >= logPumpCapacity {
+		n := len(p.line) - logPumpCapacity + 1
+		p.line = p.line[n:]
+		p.dropped += n
+	}
+	p.line = append(p.line, line)
+	p.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default: // already signaled
+	}
+	return len(b), nil
+}
+
+// function flush() forwards every line pending in a single write.
+func (p *LogPump) flush() {
+
+	p.Lock()
+	line, dropped := p.line, p.dropped
+	p.line, p.dropped = [][]byte{}, 0
+	p.Unlock()
+
+	if 0 == len(line) {
+		return
+	}
+	var batch bytes.Buffer
+	if dropped > 0 {
+		fmt.Fprintf(&batch, "%s(dropped %d log lines)\n", consoleLogPrefix[liWarn], dropped)
+	}
+	for _, b := range line {
+		batch.Write(b)
+	}
+	p.target.Write(batch.Bytes())
+}
+
+// function pump() forwards the lines written in batches, until stopped.
+func (p *LogPump) pump() {
+
+	defer close(p.done)
+	for {
+		select {
+		case <-p.stop:
+			p.flush()
+			return
+		case <-p.wake:
+			p.flush()
+			// let lines accumulate, so that a flood of them is forwarded in
+			// a few large writes rather than many small ones.
+			select {
+			case <-p.stop:
+				p.flush()
+				return
+			case <-time.After(logPumpInterval):
+			}
+		}
+	}
+}
+
+// function close() stops forwarding once every line pending is forwarded.
+func (p *LogPump) close() {
+	close(p.stop)
+	<-p.done
+}
+
+// function createFullLog() creates (or truncates) the full log file in the
+// configuration directory.
+func createFullLog(opt *Options) (*os.File, *ReturnCode) {
+	path := filepath.Join(opt.configDir(), defaultFullLogName)
+	f, err := os.Create(path)
+	if nil != err {
+		return nil, rcInvalidPath.wrap(err, "could not create full log file: %q", path)
+	}
+	return f, nil
+}
+
+// function attachLog() redirects all loggers to the log view, through its log
+// pump, which also writes the complete log to the log file given with option
+// -log, if provided, or else to the full log file. returns the function
+// detaching the loggers from the log view, restoring their writers.
+func (l *Layout) attachLog(opt *Options) (func(), *ReturnCode) {
+
+	var full io.WriteCloser
+	if opt.isProvided(opt.LogPath.name) {
+		// the loggers are already writing to the log file.
+		l.logPump.tee(infoLog.writer)
+	} else {
+		f, ret := createFullLog(opt)
+		if nil != ret {
+			return func() {}, ret
+		}
+		full = f
+		l.logPump.tee(full)
+		infoLog.verbosef("writing complete log to: %q", f.Name())
+	}
+
+	writer := make([]io.Writer, len(consoleLog))
+	for i, c := range consoleLog {
+		writer[i] = c.writer
+	}
+	setWriterAll(l.logPump)
+
+	return func() {
+		for i, c := range consoleLog {
+			c.setWriter(writer[i])
+		}
+		l.logPump.close()
+		if nil != full {
+			full.Close()
+		}
+	}, nil
+}
@@ -0,0 +1,168 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: logrepeat.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the suppression of repeated log messages. when a logger outputs
+//    the same message over and over (e.g. the scanner being denied permission
+//    to each file of a subtree), only the first few are output, and the rest
+//    are counted and summarized periodically ("last message repeated N times")
+//    until the repetition stops, rather than flooding the log.
+//
+//    warnings and errors are repeated if they have the same format, since they
+//    usually differ only in the path of a file. likewise, a ReturnCode logged
+//    by itself (e.g. the failure to scan a file) is repeated by any other
+//    derived from the same general purpose return code by the same operation.
+//    other messages are repeated only if they are identical.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// local unexported constants for repeated log messages.
+const (
+	logRepeatShown   = 3               // repeated messages output before suppressing
+	logRepeatSummary = 5 * time.Second // period of summaries while suppressing
+)
+
+// type LogRepeat tracks the repetition of the most recent message of a logger.
+type LogRepeat struct {
+	sync.Mutex
+	key    string      // identifies the repeated message (its format, origin or text)
+	delim  string      // delimiter of the repeated message
+	text   string      // most recent message output
+	shown  int         // number of repetitions output
+	count  int         // number of repetitions suppressed since last summary
+	same   bool        // all suppressed are identical to the message output
+	last   string      // most recent message suppressed
+	period *time.Timer // summarizes the repetitions suppressed periodically
+}
+
+// function newLogRepeat() creates a new LogRepeat with no message repeated.
+func newLogRepeat() *LogRepeat {
+	return &LogRepeat{}
+}
+
+// function repeatKey() returns the key identifying a message of this logger
+// with the given format (empty if none), arguments (nil if formatted) and text:
+// a lone ReturnCode is keyed by its origin and operation, and warnings and
+// errors with the same format are considered repetitions of each other.
+func (l *ConsoleLog) repeatKey(format string, v []interface{}, s string) string {
+	if 1 == len(v) {
+		if c, ok := v[0].(*ReturnCode); ok && nil != c {
+			return fmt.Sprintf("\x00%d\x00%s", c.origin().code, c.op)
+		}
+	}
+	if "" != format && (l == warnLog || l == errLog) {
+		return "\x00" + format
+	}
+	return s
+}
+
+// function suppress() returns true if the given message repeats the previous
+// message of this logger too many times to be output. the repetitions already
+// suppressed are summarized first if the given message does not repeat them.
+func (l *ConsoleLog) suppress(format string, v []interface{}, d, s string) bool {
+
+	if nil != l.base {
+		return l.base.suppress(format, v, d, s)
+	}
+	if l == rawLog || nil == l.repeat {
+		return false
+	}
+	r := l.repeat
+	r.Lock()
+	defer r.Unlock()
+
+	key := l.repeatKey(format, v, s)
+	if key != r.key {
+		l.summarize()
+		if nil != r.period {
+			r.period.Stop()
+			r.period = nil
+		}
+		r.key, r.delim, r.text, r.shown = key, d, s, 1
+		return false
+	}
+	if r.shown < logRepeatShown {
+		r.text = s
+		r.shown++
+		return false
+	}
+	if 0 == r.count {
+		r.same = true
+	}
+	r.count++
+	r.same = r.same && s == r.text
+	r.last = s
+	if nil == r.period {
+		l.schedule()
+	}
+	return true
+}
+
+// function schedule() schedules the next summary of the repetitions suppressed.
+// the caller must hold the lock of this logger's LogRepeat.
+func (l *ConsoleLog) schedule() {
+	var t *time.Timer
+	t = time.AfterFunc(logRepeatSummary, func() { l.summarizePeriod(t) })
+	l.repeat.period = t
+}
+
+// function summarizePeriod() summarizes the repetitions suppressed during the
+// last period. once a period passes with no repetition, the next message is
+// output regardless of what it repeats.
+func (l *ConsoleLog) summarizePeriod(t *time.Timer) {
+
+	r := l.repeat
+	r.Lock()
+	defer r.Unlock()
+	if t != r.period {
+		return // stopped while waiting for the lock
+	}
+	r.period = nil
+	if 0 == r.count {
+		r.key, r.shown = "", 0
+		return
+	}
+	l.summarize()
+	l.schedule()
+}
+
+// function summarize() outputs the number of repetitions suppressed, if any.
+// the caller must hold the lock of this logger's LogRepeat.
+func (l *ConsoleLog) summarize() {
+
+	r := l.repeat
+	if 0 == r.count {
+		return
+	}
+	summary := fmt.Sprintf("last message repeated %d times", r.count)
+	if !r.same {
+		summary = fmt.Sprintf("%d similar messages suppressed (most recent: %s)", r.count, r.last)
+	}
+	r.count = 0
+	l.Print(fmt.Sprintf("%s%s", r.delim, summary))
+}
+
+// function summarizeAll() outputs the number of repetitions suppressed by each
+// logger, so that none are left unreported when exiting.
+func summarizeAll() {
+	for _, c := range consoleLog {
+		if nil == c.repeat {
+			continue
+		}
+		c.repeat.Lock()
+		c.summarize()
+		c.repeat.Unlock()
+	}
+}
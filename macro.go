@@ -0,0 +1,276 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: macro.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the named actions performed by the media browser and the macros
+//    built from them. a macro is recorded as the sequence of actions resolved
+//    from the user's keypresses (not the raw keys themselves), so that it can
+//    be replayed on demand or bound to a function key.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// type LayoutAction is a function performing a single named action on the
+// user interface.
+type LayoutAction func(*Layout)
+
+// type Macro is a sequence of named actions.
+type Macro []string
+
+// type MacroRecorder holds the state of macro recording and all macros that
+// have been recorded or bound to function keys.
+type MacroRecorder struct {
+	recording bool                // actions performed are being appended to current
+	replaying bool                // a macro is currently being replayed
+	current   Macro               // actions recorded since recording began
+	last      Macro               // the most recently recorded macro
+	bound     map[tcell.Key]Macro // macros bound to function keys
+}
+
+// local unexported constants for macro recording and replay.
+const (
+	macroMaxFuncKey = 12 // function keys F1 through F12 may be bound to macros
+)
+
+var (
+	// variable layoutAction maps the name of each action that may be recorded
+	// in a macro to the function performing it.
+	layoutAction = map[string]LayoutAction{
+		"next":     func(l *Layout) { l.browseView.moveCurrentItem(1) },
+		"prev":     func(l *Layout) { l.browseView.moveCurrentItem(-1) },
+		"pagedown": func(l *Layout) { l.browseView.moveCurrentItem(5) },
+		"pageup":   func(l *Layout) { l.browseView.moveCurrentItem(-5) },
+		"first":    func(l *Layout) { l.browseView.setCurrentItem(0) },
+		"last":     func(l *Layout) { l.browseView.setCurrentItem(l.browseView.getItemCount() - 1) },
+		"select":   func(l *Layout) { l.browseView.activateCurrentItem() },
+		"preset":   func(l *Layout) { l.cyclePreset() },
+		"mark":     func(l *Layout) { l.browseView.toggleMarkCurrentItem().moveCurrentItem(1) },
+		"unmark":   func(l *Layout) { l.browseView.unmarkAll() },
+	}
+
+	// variable browseKeyAction maps the keys handled by the media browser to
+	// the name of the action they perform.
+	browseKeyAction = map[tcell.Key]string{
+		tcell.KeyDown:  "next",
+		tcell.KeyTab:   "next",
+		tcell.KeyRight: "next",
+		tcell.KeyUp:    "prev",
+		tcell.KeyLeft:  "prev",
+		tcell.KeyPgDn:  "pagedown",
+		tcell.KeyPgUp:  "pageup",
+		tcell.KeyHome:  "first",
+		tcell.KeyEnd:   "last",
+		tcell.KeyEnter: "select",
+	}
+
+	// variable browseRuneAction maps the character keys handled by the media
+	// browser to the name of the action they perform.
+	browseRuneAction = map[rune]string{
+		'p': "preset",
+		'P': "preset",
+		' ': "mark",
+		'u': "unmark",
+		'U': "unmark",
+	}
+)
+
+// function newMacroRecorder() creates a new MacroRecorder with the macros bound
+// to function keys by the user on the command line.
+func newMacroRecorder(opt *Options) *MacroRecorder {
+
+	rec := &MacroRecorder{
+		recording: false,
+		replaying: false,
+		current:   Macro{},
+		last:      Macro{},
+		bound:     map[tcell.Key]Macro{},
+	}
+
+	for _, spec := range opt.MacroBinding.StringList {
+		if key, macro, err := parseMacroBinding(spec); nil == err {
+			rec.bound[key] = macro
+		}
+	}
+	return rec
+}
+
+// function macroActionNames() returns the sorted names of all actions that may
+// be recorded in a macro.
+func macroActionNames() []string {
+	name := []string{}
+	for n := range layoutAction {
+		name = append(name, n)
+	}
+	sort.Strings(name)
+	return name
+}
+
+// function funcKey() returns the tcell.Key for the function key with the given
+// number (1 = F1), or tcell.KeyNUL if there is no such function key.
+func funcKey(n int) tcell.Key {
+	if n < 1 || n > macroMaxFuncKey {
+		return tcell.KeyNUL
+	}
+	return tcell.KeyF1 + tcell.Key(n-1)
+}
+
+// function funcKeyNumber() returns the number of the given function key (F1 =
+// 1), or 0 if the given key is not a function key that may be bound.
+func funcKeyNumber(key tcell.Key) int {
+	if key < tcell.KeyF1 || key > funcKey(macroMaxFuncKey) {
+		return 0
+	}
+	return int(key-tcell.KeyF1) + 1
+}
+
+// function parseMacroBinding() parses a macro binding of the form
+// "FN=ACTION[,ACTION...]", where FN is a function key (e.g. "F5") and each
+// ACTION is a name in layoutAction.
+func parseMacroBinding(spec string) (tcell.Key, Macro, error) {
+
+	part := strings.SplitN(spec, "=", 2)
+	if len(part) != 2 {
+		return tcell.KeyNUL, nil, fmt.Errorf("macro %q: expected FN=ACTION[,ACTION...]", spec)
+	}
+
+	name := strings.ToUpper(strings.TrimSpace(part[0]))
+	num, err := strconv.Atoi(strings.TrimPrefix(name, "F"))
+	key := funcKey(num)
+	if !strings.HasPrefix(name, "F") || nil != err || tcell.KeyNUL == key {
+		return tcell.KeyNUL, nil, fmt.Errorf("macro %q: invalid function key: %q (expected F1-F%d)",
+			spec, name, macroMaxFuncKey)
+	}
+
+	macro := Macro{}
+	for _, action := range strings.Split(part[1], ",") {
+		action = strings.ToLower(strings.TrimSpace(action))
+		if _, ok := layoutAction[action]; !ok {
+			return tcell.KeyNUL, nil, fmt.Errorf("macro %q: unknown action: %q", spec, action)
+		}
+		macro = append(macro, action)
+	}
+	return key, macro, nil
+}
+
+// function String() creates a string representation of the Macro in the same
+// form used to bind it.
+func (m Macro) String() string {
+	return strings.Join(m, ",")
+}
+
+// function perform() performs the named action, appending it to the macro
+// being recorded (if any).
+func (l *Layout) perform(name string) {
+	action, ok := layoutAction[name]
+	if !ok {
+		return
+	}
+	if l.macro.recording && !l.macro.replaying {
+		l.macro.current = append(l.macro.current, name)
+	}
+	action(l)
+}
+
+// function toggleRecording() begins recording a new macro, or stops recording
+// the current macro and keeps it as the most recently recorded macro.
+func (l *Layout) toggleRecording() {
+	if l.macro.recording {
+		l.stopRecording(tcell.KeyNUL)
+		return
+	}
+	l.macro.recording = true
+	l.macro.current = Macro{}
+	infoLog.logf("recording macro (press 'R' to stop, or F1-F%d to stop and bind it)", macroMaxFuncKey)
+}
+
+// function stopRecording() stops recording the current macro and keeps it as
+// the most recently recorded macro. if a function key is given, the macro is
+// also bound to that key.
+func (l *Layout) stopRecording(key tcell.Key) {
+	l.macro.recording = false
+	if 0 == len(l.macro.current) {
+		infoLog.log("macro recording cancelled (no actions recorded)")
+		return
+	}
+	l.macro.last = l.macro.current
+	if n := funcKeyNumber(key); n > 0 {
+		l.macro.bound[key] = l.macro.current
+		infoLog.logf("macro bound to F%d: %s", n, l.macro.current)
+	} else {
+		infoLog.logf("macro recorded (press '@' to replay): %s", l.macro.current)
+	}
+	l.macro.current = Macro{}
+}
+
+// function replayMacro() performs each action of the given macro in order.
+func (l *Layout) replayMacro(macro Macro) {
+	if 0 == len(macro) {
+		return
+	}
+	l.macro.replaying = true
+	for _, name := range macro {
+		l.perform(name)
+	}
+	l.macro.replaying = false
+}
+
+// function macroEvent() handles the keys controlling macro recording and replay
+// as well as the keys resolving to recordable actions in the media browser.
+// returns true if the key was handled.
+func (l *Layout) macroEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if n := funcKeyNumber(ek); n > 0 {
+		if l.macro.recording {
+			l.stopRecording(ek)
+		} else if busy {
+			warnLog.logf(busyMessage("replay a macro"))
+		} else if macro, ok := l.macro.bound[ek]; ok {
+			l.replayMacro(macro)
+		}
+		return true
+	}
+
+	if tcell.KeyRune == ek {
+		switch er {
+		case 'R':
+			l.toggleRecording()
+			return true
+		case '@':
+			if busy {
+				warnLog.logf(busyMessage("replay a macro"))
+			} else {
+				l.replayMacro(l.macro.last)
+			}
+			return true
+		}
+		if name, ok := browseRuneAction[er]; ok {
+			if busy {
+				warnLog.logf(busyMessage("perform that action"))
+			} else {
+				l.perform(name)
+			}
+			return true
+		}
+		return false
+	}
+
+	if name, ok := browseKeyAction[ek]; ok {
+		l.perform(name)
+		return true
+	}
+	return false
+}
@@ -14,6 +14,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -22,6 +23,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -34,6 +36,7 @@ const (
 	defaultMEMProfileName = "mem.prof"
 	defaultConfigName     = "config"
 	defaultLibDataName    = "library.db"
+	defaultFullLogName    = "full.log"
 )
 
 // versioning information defined by compiler switches in Makefile.
@@ -153,14 +156,36 @@ var (
 // type Option struct can contain any possible individual option configuration
 // including its command line flag identifier and usage info..
 type Option struct {
-	name  string
-	usage string
+	name     string
+	usage    string
+	kind     OptionKind          // which of the value fields below is used
+	choice   []string            // allowed values of okEnum options
+	validate func(*Option) error // verifies the value once all options are parsed (may be nil)
 	bool
 	int
 	uint
+	uint64
 	float64
 	string
 	time.Duration
+	StringList
+}
+
+// type StringList is a list of strings implementing the flag.Value interface,
+// used for options which may be provided more than once on the command line.
+type StringList []string
+
+// function String() creates a string representation of the StringList as
+// required by the flag.Value interface.
+func (s *StringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+// function Set() appends the given string to the StringList as required by the
+// flag.Value interface.
+func (s *StringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
 // type NamedOption is intended to map the name of an option to the actual
@@ -171,7 +196,12 @@ type NamedOption map[string]*Option
 type Options struct {
 	*flag.FlagSet // the builtin command-line parser
 
-	Provided NamedOption // which options were provided by the user at runtime
+	Provided   NamedOption // which options were provided by the user at runtime
+	Configured NamedOption // which of the options provided were given in the config file
+	Known      NamedOption // all options understood, by name
+
+	ConfigErrors    []error  // problems found in the config file
+	ConfigLibraries []string // library paths given in the config file
 
 	CPUProfile     *Option // flag indicating CPU profiling should be performed
 	CPUProfileName *Option // name of file to store pprof data of CPU profiler
@@ -184,10 +214,86 @@ type Options struct {
 	Config    *Option // defines path to config file
 	LibData   *Option // defines data directory path (where to store databases)
 	CLIMode   *Option // defines the type of UI to use: CLI or TUI
+	LineMode  *Option // uses the line-oriented interactive mode instead of the TUI
 	LogPath   *Option // file path where to write all log data
+	LogLines  *Option // max number of lines retained by the TUI log view (0 = unlimited)
+
+	Quiet       *Option // suppresses decorative output (startup banner and random greeting on exit)
+	ExitMessage *Option // message printed on exit in place of the random greeting
 
 	DiskBufferSize *Option // size (bytes) of each collection's pre-allocated buffers on disk. num buffers = num CPU cores
 	HashBufferSize *Option // size (bytes) by which each hash table will grow once individual capacity is exceeded.
+
+	LibraryQuota *Option // size (bytes) budget of all media indexed in each library (0 = unlimited)
+	MinFreeSpace *Option // size (bytes) of free space below which a library's file system is considered full (0 = unchecked)
+
+	CacheSize *Option // max size (bytes) of all images in the artwork cache (0 = unlimited)
+
+	NetBandwidth *Option // max number of bytes per second transferred by all online integrations (0 = unlimited)
+	NetRequests  *Option // max number of requests per minute issued by all online integrations (0 = unlimited)
+	AcoustIDKey  *Option // AcoustID API client key used to identify audio by its fingerprint
+
+	CustomFields *Option // user-defined metadata fields declared as NAME:TYPE[@LIBRARY]
+
+	LayoutPreset    *Option // name of the layout preset initially applied to the TUI
+	LayoutPresetDef *Option // user-defined layout presets declared as NAME:KEY=VALUE[,...]
+
+	MacroBinding *Option // macros bound to function keys declared as FN=ACTION[,...]
+
+	ScanDryRun *Option // classify library files and report what would be indexed, writing nothing
+
+	SubsMaxMedia *Option // max number of media in a dir with subtitles to associate them all
+	SubsSubdir   *Option // names of directories recognized as subtitles subdirectories
+	SubsDisable  *Option // subtitles association heuristics disabled declared as HEURISTIC[@LIBRARY]
+
+	ReleaseTags *Option // release tags ending the title in video file names, in addition to the bundled ones
+
+	Player   *Option // external media player playing media without their own playback command
+	HostArgs *Option // player arguments appended to playback commands on a given host declared as HOST=ARGS
+	Target   *Option // remote playback targets declared as NAME=[USER@]HOST
+
+	SpectrumFifo *Option // FIFO of raw PCM audio drawn in the spectrum pane
+
+	Portable  *Option // store each library's database at its root, anchored at its current path
+	PathRemap *Option // path remap rules for moved libraries declared as OLD=NEW
+
+	PollFreq *Option // polling watcher intervals declared as INTERVAL[@LIBRARY]
+	FullScan *Option // examine every file when scanning, ignoring the directory cache
+	Archives *Option // archive formats listed when scanning declared as FORMAT[,FORMAT...][@LIBRARY]
+
+	FSRetries *Option // max number of retries of transiently failing file system operations
+	Settle    *Option // time since its last modification after which a file is presumed complete
+
+	TorrentResume *Option // directories of torrent clients' resume data
+	CheckAudio    *Option // verify the header of each new audio file while scanning
+	Sniff         *Option // content classification declared as KIND[,KIND...][@LIBRARY]
+	FollowLinks   *Option // scan symlinks as the files or directories they point to
+	Prune         *Option // remove the records of media whose files no longer exist when loading
+	IgnoreName    *Option // name patterns of files and directories ignored by the scanner
+	Tombstones    *Option // time the records removed from a library database are kept
+
+	MaxProcs    *Option // max number of OS threads executing goroutines simultaneously (0 = number of CPUs)
+	ScanWorkers *Option // max number of libraries scanned concurrently
+	DirWorkers  *Option // max number of directories of each library scanned concurrently
+
+	DBBackend *Option // where library databases are stored: on disk, or discarded on exit
+	Engine    *Option // storage engine of newly created library databases
+
+	Simulate *Option // replace the libraries with simulated ones discovering fake media
+	SimRate  *Option // number of fake media discovered per second per simulated library (0 = unlimited)
+	SimCount *Option // number of fake media discovered per simulated library
+
+	TermTitle  *Option // set the terminal title to the current context, restoring it on exit
+	TmuxStatus *Option // also publish the current context as a tmux window option
+
+	GlyphIndicators *Option // mark item and library states with glyphs rather than color alone
+	IndicatorGlyph  *Option // glyphs overriding the defaults declared as STATE=GLYPH
+
+	FeatureEnable  *Option // names of optional subsystems enabled
+	FeatureDisable *Option // names of optional subsystems disabled
+
+	TimeFormat *Option // format of displayed timestamps: a named style, relative, or a Go time layout
+	TimeZone   *Option // time zone of displayed timestamps
 }
 
 // type TimeInterval struct contains a start and end time (together with a
@@ -243,42 +349,74 @@ func greeting() string {
 	return fmt.Sprintf("quitting, have %s %s!", s, t)
 }
 
+// function exitMessage() returns the message printed on a normal exit, which is
+// the user's message if one was given, or else a random greeting. decorative
+// output is suppressed entirely in quiet mode.
+func exitMessage(opt *Options) string {
+	liveMutex.RLock()
+	quiet, message := opt.Quiet.bool, opt.ExitMessage.string
+	liveMutex.RUnlock()
+	if quiet {
+		return ""
+	}
+	if opt.isProvided(opt.ExitMessage.name) {
+		return message
+	}
+	return greeting()
+}
+
+// function banner() returns the program's identity and version information.
+func banner() string {
+	return fmt.Sprintf("%s v%s (%s@%s) [%s]", identity, version, branch, revision, buildtime)
+}
+
 // function main() is the program entry point, obviously :)
 func main() {
 
-	// primary panic handler for the main thread. this is the last point at
-	// which we can recover before terminating the entire process. the normal
-	// exit case is also handled here for completeness. see the ReturnCode
-	// switch cases to see how special case exit cleanup is implemented.
+	// primary panic handler for the main thread. nothing is expected to panic,
+	// so this is the last point at which we can report a bug before
+	// terminating the entire process.
 	defer func() {
 		if r := recover(); nil != r {
-			switch r.(type) {
-			case *ReturnCode:
-				c := r.(*ReturnCode)
-				switch c {
-				// non-errors, normal cleanup and exit
-				case rcOK, rcUsage:
-					infoLog.die(c, false)
-				// common errors, not unusual enough reason for stack trace
-				case rcInvalidConfig:
-					errLog.die(c, false)
-				// all other errors not specifically handled above
-				default:
-					errLog.die(c, true)
-				}
+			if c, ok := r.(*ReturnCode); ok {
+				errLog.die(c, true)
 			}
+			errLog.die(rcUnknown.specf("%v", r), true)
 		}
 	}()
 
+	// the normal exit case is handled here as well as errors. see the
+	// ReturnCode switch cases to see how special case exit cleanup is
+	// implemented.
+	c := run(os.Args[1:])
+	switch {
+	// non-errors, normal cleanup and exit
+	case c.is(rcOK, rcUsage):
+		infoLog.die(c, false)
+	// common errors, not unusual enough reason for stack trace
+	case c.is(rcInvalidConfig, rcScanErrors, rcPartialSuccess, rcLockHeld, rcNoNetwork,
+		rcDoctorIssues):
+		errLog.die(c, false)
+	// all other errors not specifically handled above
+	default:
+		errLog.die(c, true)
+	}
+}
+
+// function run() runs the program with the given command line arguments until
+// it is ready to exit, returning the reason for exiting (rcOK if there were no
+// errors). it never panics, so that it may be embedded by other entry points.
+func run(args []string) (ret *ReturnCode) {
+
 	var busyState *BusyState = newBusyState()
-	var initComplete chan bool = make(chan bool)
+	var initComplete chan bool = make(chan bool, 1)
 
 	// first things first, parse options and command line arguments which can
 	// influence the operating modes of the program from a very high level.
-	options, err := initOptions()
+	options, err := initOptions(args)
 	if nil != err {
 		// immediately terminate if we don't understand the runtime options.
-		panic(err)
+		return err
 	}
 
 	// if the user provided a log file, redirect all output to that file instead
@@ -287,81 +425,82 @@ func main() {
 	if isLogPathProvided {
 		of, err := os.Create(logPath.string)
 		if err != nil {
-			panic(rcInvalidPath.specf("could not create log file: %s", err))
+			return rcInvalidPath.wrap(err, "could not create log file")
 		}
+		ow := bufio.NewWriter(of)
 		defer func() {
-			if err := of.Close(); err != nil {
-				panic(rcInvalidPath.specf("could not close log file: %s", err))
+			ow.Flush()
+			resetWriterAll()
+			if err := of.Close(); err != nil && ret.is(rcOK) {
+				ret = rcInvalidPath.wrap(err, "could not close log file")
 			}
 		}()
-		ow := bufio.NewWriter(of)
 		setWriterAll(ow)
 	}
+	// report any repeated log messages still suppressed before exiting (and
+	// before the log file above is flushed).
+	defer summarizeAll()
 
 	// create the CPU profiler output if requested.
 	if options.CPUProfile.bool && "" != options.CPUProfileName.string {
 		infoLog.verbosef("writing CPU profile: %q", options.CPUProfileName.string)
 		f, err := os.Create(options.CPUProfileName.string)
 		if err != nil {
-			panic(rcInvalidFile.specf("could not create CPU profile: %s", err))
+			return rcInvalidFile.wrap(err, "could not create CPU profile")
 		}
 		if err := pprof.StartCPUProfile(f); err != nil {
-			panic(rcInvalidFile.specf("could not start CPU profile: %s", err))
+			return rcInvalidFile.wrap(err, "could not start CPU profile")
 		}
 		defer pprof.StopCPUProfile()
 	}
 
-	// if no options were provided and no config file exists, then we are
-	// totally lost and confused. display usage and bail out.
-	config := options.Config.string
-	configExists, _ := goutil.PathExists(config)
-	if !configExists && len(os.Args) <= 1 {
-		options.Usage()
-		panic(rcUsage)
+	// a scan dry-run only classifies the files in each library path. it must
+	// not create any configuration or database, so handle it before anything
+	// is written to disk.
+	if options.ScanDryRun.bool {
+		if ret := dryRun(options); nil != ret {
+			return ret
+		}
+		return rcOK.spec("")
 	}
 
-	// create the directory hierarchy that will store our configuration data
-	// permanently on disk.
-	configDir := options.configDir()
-	if !configExists {
-		if dirExists, _ := goutil.PathExists(configDir); !dirExists {
-			if err := os.MkdirAll(configDir, os.ModePerm); nil != err {
-				panic(rcInvalidConfig.specf(
-					"cannot create configuration directory: %q: %s", configDir, err))
-			}
-			infoLog.tracef("created configuration directory: %q", configDir)
+	// a subcommand performs its one-off task and exits. like the dry-run, it
+	// must not create any configuration or database.
+	if cmd, args := options.command(); nil != cmd {
+		if ret := cmd.run(options, args); nil != ret {
+			return ret
 		}
+		return rcOK.spec("")
+	}
 
-		// TODO: create configuration file
-		infoLog.tracef("(TBD) -- created configuration: %q", config)
+	// introduce ourselves before the libraries are opened, unless the user
+	// prefers we get on with it.
+	if !options.Quiet.bool {
+		rawLog.log(banner())
 	}
 
-	// if we haven't died yet, then config dir/file exists. load it.
-	// NOTE: be careful not to overwrite any config options that were already
-	//       provided via command line as those should always take precedence!
-	infoLog.tracef("(TBD) -- loading configuration: %q", config)
+	// all images generated or downloaded for media are kept in one cache
+	// shared by every library.
+	artworkCache = newArtworkCache(options)
 
-	// create the directory hierarchy that will store our libraries' backing
-	// data stores permanently on disk.
-	libData := options.LibData.string
-	if exists, _ := goutil.PathExists(libData); !exists {
-		if err := os.MkdirAll(libData, os.ModePerm); nil != err {
-			panic(rcInvalidConfig.specf(
-				"cannot create shared data directory: %q: %s", libData, err))
-		}
-		infoLog.tracef("created shared data directory: %q", libData)
-	} else {
-		infoLog.tracef("(TBD) -- loading shared data directory: %q", libData)
+	// a simulation replaces the libraries with synthetic ones, which need
+	// neither configuration nor databases. the ephemeral databases of the
+	// memory backend, if any are created, are removed once we return, as are
+	// the lock files of all databases still open.
+	defer removeMemoryData()
+	defer unlockAll()
+	var library []*Library
+	if options.Simulate.bool {
+		library = newSimLibrary(options, busyState)
+	} else if library, err = openLibrary(options, busyState, args); nil != err {
+		return err
 	}
 
-	// runtime environment defined, begin preparing the libs and databases.
-	infoLog.log("initializing library databases ...")
-
-	// remaining arguments are considered paths to libraries; verify the paths
-	// before assuming valid ones exist for traversal.
-	library := initLibrary(options, busyState)
-	if 0 == len(library) {
-		panic(rcInvalidConfig.spec("no valid libraries provided"))
+	// unattended runs are summarized one library at a time, as soon as each
+	// has been loaded and scanned, rather than once all of them have.
+	var summary *SummaryTable
+	if isCLIMode {
+		summary = newSummaryTable(library)
 	}
 
 	// dispatch a goroutine that will listen for the database and file system
@@ -370,58 +509,106 @@ func main() {
 	scanStart := time.Now()
 	go func(lib []*Library, start time.Time) {
 
-		var numFound uint = 0
+		var numFound uint64 = 0
+		done := make(chan *Library)
 		for _, l := range lib {
-			// block this goroutine until each library has written to their
-			// respective channel. the order in which we receive this channel
-			// data is irrelevant because they -all- must complete.
-			numFound += (<-l.scanComplete).(uint)
+			go func(l *Library) {
+				atomic.AddUint64(&numFound, uint64((<-l.scanComplete).(uint)))
+				done <- l
+			}(l)
+		}
+		for range lib {
+			// block this goroutine until each library has completed. the
+			// order in which they complete is irrelevant because they -all-
+			// must complete.
+			if l := <-done; nil != summary {
+				summary.add(l)
+			}
 		}
 		scanElapsed := time.Since(start)
 		infoLog.logf("initialization complete (%d ~things~ found in %s)",
-			numFound, scanElapsed.Round(time.Millisecond))
-
-		// the only purpose of this channel is to safely handle the transition
-		// from the initial CLI mode to the ncurses TUI mode by displaying
-		// status information to the appropriate interface. if this channel is
-		// still empty by the time we have our ncurses interface shown, then all
-		// prior log/status messages have been obscured by the UI. so we want to
-		// give an update to convince the user we are still working on it. this
-		// determination is made by the select-true block below with a default
-		// case that is selected when initComplete is empty.
+			atomic.LoadUint64(&numFound), scanElapsed.Round(time.Millisecond))
+
+		// signal everything has been loaded and scanned. the channel is
+		// buffered, since only CLI mode waits on it.
 		initComplete <- true
 
 	}(library, scanStart)
 
-	// libraries ready, spool up the library scanners.
-	populateLibrary(options, library)
+	// the loads and scans still in progress once we return are interrupted,
+	// and the scans given a moment to record where they left off so that the
+	// next launch resumes them (see interrupt.go).
+	ctx, interrupt := context.WithCancel(context.Background())
+	defer func() {
+		interrupt()
+		if !awaitInterrupted(library, interruptTimeout) {
+			warnLog.logf("gave up waiting for scans to stop after %s", interruptTimeout)
+		}
+	}()
+
+	// libraries ready, spool up the library scanners (or the simulators).
+	populate := func() *ReturnCode {
+		if options.Simulate.bool {
+			return simulateLibrary(options, library)
+		}
+		if err := populateLibrary(ctx, options, library); nil != err {
+			return err
+		}
+		// keep an eye out for system suspend/resume so that we can revalidate
+		// the libraries once we wake up.
+		if ffSuspend.isEnabled() {
+			go watchSuspend(library)
+		}
+		return nil
+	}
+
+	// the UI is created before anything is loaded, so that all log output is
+	// shown in it rather than on the terminal beneath it, and the libraries
+	// are only loaded and scanned once it has been drawn, so that it appears
+	// right away.
+	var layout *Layout
+	if !isCLIMode && !options.LineMode.bool {
+		layout = newLayout(options, busyState, library...)
+		layout.onFirstDraw(func() {
+			if err := populate(); nil != err {
+				errLog.log(err)
+			}
+		})
+	} else if err := populate(); nil != err {
+		return err
+	}
+
+	// apply the changes made to the config file while we run, whenever it is
+	// reloaded (see reload.go).
+	if !isCLIMode {
+		watchConfig(ctx, options, library)
+	}
+
+	// reflect the current context in the terminal title until we return.
+	termTitle = newTermTitle(options, library)
+	termTitle.start()
+	defer termTitle.stop()
+
+	var failed *ReturnCode // non-nil if any library had errors (CLI mode only)
 
 	// we don't wait for the scanning to finish. go ahead and launch the UI for
 	// progress indicators and anything else the user can get away with while
 	// the scanners/loaders work.
-	if !isCLIMode {
-		//layout := newLayout(options, busyState, library...)
-		// associate the loggers with the navigable log viewer.
-		if !isLogPathProvided {
-			//setWriterAll(layout.logView)
+	if nil != layout {
+		if errCode := layout.show(); nil != errCode {
+			return errCode
 		}
-		select {
-		case <-initComplete:
-			// if there exists something in this channel, then we have already
-			// printed "initialization complete ..." above. in which case, just
-			// carry on and display the UI with no other status to the user.
-			// we will hit this case block which is a NOOP.
-		default:
-			// otherwise, nothing exists in that channel and we are still
-			// scanning. don't sit around like a deadbeat -- tell the user we're
-			// working on it.
-			infoLog.logf("still initializing library databases ...")
+	} else if !isCLIMode {
+		// the line-oriented mode takes the place of the UI, listing items as
+		// they are discovered.
+		if ret := newLineMode(busyState, library).show(); nil != ret {
+			return ret
 		}
-		//if errCode := layout.show(); nil != errCode {
-		//	panic(errCode)
-		//}
 	} else {
+		// unattended runs fail if any library had errors (once the memory
+		// profile below has been written).
 		<-initComplete
+		failed = summary.result()
 	}
 
 	// create the memory profiler output if requested
@@ -429,18 +616,38 @@ func main() {
 		infoLog.verbosef("writing memory profile: %q", options.MEMProfileName.string)
 		f, err := os.Create(options.MEMProfileName.string)
 		if err != nil {
-			panic(rcInvalidFile.specf("could not create memory profile: %s", err))
+			return rcInvalidFile.wrap(err, "could not create memory profile")
 		}
 		runtime.GC() // get up-to-date statistics
 		if err := pprof.WriteHeapProfile(f); err != nil {
-			panic(rcInvalidFile.specf("could not write memory profile: %s", err))
+			f.Close()
+			return rcInvalidFile.wrap(err, "could not write memory profile")
 		}
 		f.Close()
 	}
 
+	if nil != failed {
+		return failed
+	}
+	if !options.Simulate.bool {
+		// a library given more than once was not a failure to open it.
+		given := map[string]bool{}
+		for _, arg := range options.Args() {
+			if abs, err := libraryPath(arg); nil == err {
+				given[abs] = true
+			} else {
+				given[arg] = true
+			}
+		}
+		if n := len(given) - len(library); n > 0 {
+			return rcPartialSuccess.specf("%d of %d libraries could not be opened",
+				n, len(given))
+		}
+	}
+
 	// exit cleanly but explicitly so that we have some control on exit codes
 	// and resource cleanup.
-	panic(rcOK.spec(greeting()))
+	return rcOK.spec(exitMessage(options))
 }
 
 // function configDir() constructs the full path to the directory containing all
@@ -458,6 +665,16 @@ func (o *Options) configDir() string {
 	}
 }
 
+// function isProvided() returns true if the option with the given name was
+// provided by the user. the options provided may change when the config file
+// is reloaded, so they are read while holding liveMutex.
+func (o *Options) isProvided(name string) bool {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	_, ok := o.Provided[name]
+	return ok
+}
+
 // function providedDBConfig() checks the "Provided" hash of the Options struct
 // for any of the options related to initial database configuration. this is
 // necessary to decide how to initialize the database. furthermore, a []string
@@ -479,30 +696,16 @@ func (o *Options) providedDBConfig() (bool, []string) {
 	return provided, list
 }
 
-// function initOptions() parses all command line arguments and prepares the
-// environment.
-func initOptions() (options *Options, err *ReturnCode) {
+// function initOptions() parses the given command line arguments and prepares
+// the environment.
+func initOptions(args []string) (*Options, *ReturnCode) {
 
-	defer func() {
-		// without options parsed, we cannot know where to print any status or
-		// other info, so we always print everything to the console until they
-		// are. this flag controls that state change.
-		areOptionsParsed = true
-		// panic handler
-		if recovered := recover(); nil != recovered {
-			options = nil
-			if flag.ErrHelp == recovered {
-				// hide the flag.flagSet's default output status message,
-				// because we will print our own.
-				err = rcUsage
-				return
-			}
-			// at this point we encountered an actual error, capture it and show
-			// it with our error logger. (NOTE: this "err" is a named output
-			// paramater of function initOptions()).
-			err = rcInvalidArgs.specf("%s", recovered)
-		}
-	}()
+	// without options parsed, we cannot know where to print any status or
+	// other info, so we always print everything to the console until they
+	// are. this flag controls that state change.
+	defer func() { areOptionsParsed = true }()
+
+	var options *Options
 
 	// by default,
 	configPath := filepath.Join(options.configDir(), defaultConfigName)
@@ -510,76 +713,435 @@ func initOptions() (options *Options, err *ReturnCode) {
 
 	// define the option properties that the command line parser recognizes.
 	options = &Options{
-		// PanicOnError gets trapped by the anon defer'd func() above. the
-		// recover()'d  value will be set to flag.ErrHelp, which we want to
-		// override by printing with our error logger.
-		FlagSet:  flag.NewFlagSet(identity, flag.PanicOnError),
-		Provided: NamedOption{},
+		// ContinueOnError returns parse errors from Parse(), where an error
+		// flag.ErrHelp is overridden by printing with our error logger.
+		FlagSet:    flag.NewFlagSet(identity, flag.ContinueOnError),
+		Provided:   NamedOption{},
+		Configured: NamedOption{},
+		Known:      NamedOption{},
+
+		ConfigErrors:    nil,
+		ConfigLibraries: nil,
 
 		CPUProfile: &Option{
 			name:  "cpuprofile",
+			kind:  okBool,
 			usage: "flag indicating CPU profiling should be performed",
 			bool:  false,
 		},
 		CPUProfileName: &Option{
 			name:   "cpuprofilename",
+			kind:   okString,
 			usage:  "path to file to store pprof data of CPU profiler",
 			string: filepath.Join(os.Getenv("PWD"), defaultCPUProfileName),
 		},
 		MEMProfile: &Option{
 			name:  "memprofile",
+			kind:  okBool,
 			usage: "flag indicating MEM profiling should be performed",
 			bool:  false,
 		},
 		MEMProfileName: &Option{
 			name:   "memprofilename",
+			kind:   okString,
 			usage:  "path to file to store pprof data of MEM profiler",
 			string: filepath.Join(os.Getenv("PWD"), defaultMEMProfileName),
 		},
 		UsageHelp: &Option{
 			name:  "help",
+			kind:  okBool,
 			usage: "display this helpful usage synopsis!",
 			bool:  false,
 		},
 		Verbose: &Option{
 			name:  "verbose",
+			kind:  okBool,
 			usage: "display additional status information",
 			bool:  false,
 		},
 		Trace: &Option{
 			name:  "trace",
+			kind:  okBool,
 			usage: "display additional status information (maximum verbosity)",
 			bool:  false,
 		},
 		CLIMode: &Option{
 			name:  "cli",
+			kind:  okBool,
 			usage: "disables the curses-style textual user interface, falling back to basic terminal I/O. useful when deugging.",
 			bool:  false,
 		},
+		LineMode: &Option{
+			name:  "accessible",
+			kind:  okBool,
+			usage: "replaces the textual user interface with a line-oriented interactive mode (prompts and numbered menus) suited to screen readers",
+			bool:  false,
+		},
 		LogPath: &Option{
 			name:   "log",
+			kind:   okString,
 			usage:  "file path to where all normal and verbose log messages will be redirected",
 			string: "",
 		},
+		LogLines: &Option{
+			name:  "loglines",
+			kind:  okUint,
+			usage: "max number of lines retained by the log view of the textual user interface, trimming the oldest (0 = unlimited)\n  (while the log view is shown, the complete log is written to the file given with -log, or else to \"" + defaultFullLogName + "\" in the configuration directory)",
+			uint:  defaultLogViewLines,
+		},
+		Quiet: &Option{
+			name:  "quiet",
+			kind:  okBool,
+			usage: "suppresses decorative output, i.e. the startup banner and the random greeting on exit",
+			bool:  false,
+		},
+		ExitMessage: &Option{
+			name:   "exitmsg",
+			kind:   okString,
+			usage:  "message printed on exit in place of the random greeting (an empty message disables it)",
+			string: "",
+		},
 		Config: &Option{
 			name:   "config",
-			usage:  "path to config file",
+			kind:   okString,
+			usage:  "path to config file (TOML), which gives the library paths scanned when none are given and the default value of any option",
 			string: configPath,
 		},
 		LibData: &Option{
 			name:   "libdata",
+			kind:   okString,
 			usage:  "path to library data directory (database storage location)",
 			string: libDataPath,
 		},
 		DiskBufferSize: &Option{
-			name:  "diskbuffersize",
-			usage: "size (in bytes) of each library's preallocated on-disk buffers (number of buffers = number of CPU cores)\n  (NOTE: this may not be changed after the corresponding library's database has been created)",
-			int:   defaultDiskBufferSize,
+			name:     "diskbuffersize",
+			kind:     okSize,
+			usage:    "`size` (bytes, or with units such as 256KiB) of each library's preallocated on-disk buffers (number of buffers = number of CPU cores)\n  (NOTE: this may not be changed after the corresponding library's database has been created)",
+			uint64:   uint64(defaultDiskBufferSize),
+			validate: validateMinSize(minBufferSize),
 		},
 		HashBufferSize: &Option{
-			name:  "hashbuffersize",
-			usage: "size (in bytes) by which each hash table will grow to make room once it reaches capacity\n  (NOTE: this may not be changed after the corresponding library's database has been created)",
-			int:   defaultHashBufferSize,
+			name:     "hashbuffersize",
+			kind:     okSize,
+			usage:    "`size` (bytes, or with units such as 64KiB) by which each hash table will grow to make room once it reaches capacity\n  (NOTE: this may not be changed after the corresponding library's database has been created)",
+			uint64:   uint64(defaultHashBufferSize),
+			validate: validateMinSize(minBufferSize),
+		},
+		LibraryQuota: &Option{
+			name:   "quota",
+			kind:   okSize,
+			usage:  "`size` budget (bytes, or with units such as 500GiB) of all media indexed in each library, a warning is issued when exceeded (0 = unlimited)",
+			uint64: 0,
+		},
+		MinFreeSpace: &Option{
+			name:   "minfree",
+			kind:   okSize,
+			usage:  "`size` of free space (bytes, or with units such as 10GiB) remaining on a library's file system below which a warning is issued (0 = unchecked)",
+			uint64: 0,
+		},
+		CacheSize: &Option{
+			name:   "cachesize",
+			kind:   okSize,
+			usage:  "max `size` (bytes, or with units such as 512MiB) of all generated or downloaded images (thumbnails, posters, waveforms) in the artwork cache, the least recently used are evicted when exceeded (0 = unlimited)",
+			uint64: 256 << 20,
+		},
+		NetBandwidth: &Option{
+			name:   "netrate",
+			kind:   okSize,
+			usage:  "max `size` (bytes, or with units such as 512KiB) transferred per second by all online integrations combined (0 = unlimited)",
+			uint64: 0,
+		},
+		NetRequests: &Option{
+			name:   "netrequests",
+			kind:   okUint64,
+			usage:  "max number of requests per minute issued by all online integrations combined (0 = unlimited)",
+			uint64: 0,
+		},
+		AcoustIDKey: &Option{
+			name:   "acoustid",
+			kind:   okString,
+			usage:  "AcoustID API client `key` (see https://acoustid.org/new-application) used by command \"identify\" to look up audio by its Chromaprint fingerprint",
+			string: "",
+		},
+		CustomFields: &Option{
+			name:       "field",
+			kind:       okStringList,
+			usage:      "declares a user-defined metadata field of the form NAME:TYPE[@LIBRARY], where TYPE is one of: " + strings.Join(fieldTypeName[:], ", ") + "\n  (may be given multiple times; if LIBRARY is omitted, the field applies to all libraries; edit with 'e' in the media browser, search as NAME=VALUE)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parseCustomField(spec); return err }),
+		},
+		LayoutPreset: &Option{
+			name:   "preset",
+			kind:   okString,
+			usage:  "name of the layout preset initially applied to the user interface (press 'P' in the media browser to cycle presets)",
+			string: defaultLayoutPreset,
+		},
+		LayoutPresetDef: &Option{
+			name:       "presetdef",
+			kind:       okStringList,
+			usage:      "declares a layout preset of the form NAME:KEY=VALUE[,KEY=VALUE...], where KEY is one of: log (rows, 0 = hidden), sort (" + strings.Join(browseSortName[:], ", ") + ":NAME of a -field), library (name)\n  (may be given multiple times; replaces any built-in preset with the same NAME)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parseLayoutPreset(spec); return err }),
+		},
+		MacroBinding: &Option{
+			name:       "macro",
+			kind:       okStringList,
+			usage:      "binds a macro to a function key, of the form FN=ACTION[,ACTION...], where FN is F1-F12 and ACTION is one of: " + strings.Join(macroActionNames(), ", ") + "\n  (may be given multiple times; macros may also be recorded in the media browser with 'R')",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, _, err := parseMacroBinding(spec); return err }),
+		},
+		ScanDryRun: &Option{
+			name:  "scan-dry-run",
+			kind:  okBool,
+			usage: "traverse each library path and report what would be indexed or ignored, without creating or modifying any database",
+			bool:  false,
+		},
+		SubsMaxMedia: &Option{
+			name:  "subsmaxmedia",
+			kind:  okUint,
+			usage: "max number of media in a directory containing subtitles for the subtitles to be associated with all of them, when no other heuristic matched",
+			uint:  uint(maxNumMediaAssocSubs),
+		},
+		SubsSubdir: &Option{
+			name:       "subsdir",
+			kind:       okStringList,
+			usage:      "name of a directory recognized as a subtitles subdirectory of the directory containing its videos (case-insensitive)\n  (may be given multiple times; replaces the default names: " + strings.Join(defaultSubsSubdir, ", ") + ")",
+			StringList: StringList{},
+		},
+		SubsDisable: &Option{
+			name:       "subsdisable",
+			kind:       okStringList,
+			usage:      "disables a subtitles association heuristic, of the form HEURISTIC[@LIBRARY], where HEURISTIC is one of: " + strings.Join(subsHeuristicName[:], ", ") + "\n  (may be given multiple times; if LIBRARY is omitted, the heuristic is disabled for all libraries)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parseSubsDisable(spec); return err }),
+		},
+		ReleaseTags: &Option{
+			name:       "releasetag",
+			kind:       okStringList,
+			usage:      "release tag (source, codec, group, etc., e.g. \"WEB-DL\") at which the title in a video's file name ends, in addition to the common tags bundled and those in file \"" + releaseTagsFileName + "\" of the configuration directory\n  (may be given multiple times)",
+			StringList: StringList{},
+			validate:   validateEach(parseReleaseTag),
+		},
+		Player: &Option{
+			name:   "player",
+			kind:   okString,
+			usage:  "external media player launched with the path of the media selected (with Enter) in the media browser, for media without their own playback command, e.g. \"mpv --fs\" or \"vlc\"\n  (an empty player plays only media with their own playback command)",
+			string: playerDefault,
+		},
+		HostArgs: &Option{
+			name:       "hostargs",
+			kind:       okStringList,
+			usage:      "player arguments appended to every playback command launched on a given host, of the form HOST=ARGS (e.g. htpc=--hwdec=vaapi), where HOST is a host name with or without its domain, or " + hostArgsAnyHost + " for any host without its own\n  (may be given multiple times; useful when libraries are shared by machines with different hardware)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, _, err := parseHostArgs(spec); return err }),
+		},
+		Target: &Option{
+			name:       "target",
+			kind:       okStringList,
+			usage:      "remote playback target, of the form NAME=[USER@]HOST, on which media are played by running the playback command over " + targetSSH + " (the host must see the libraries at the same paths)\n  (may be given multiple times; target \"" + targetLocal + "\" plays on this host, and the last target picked is remembered in the data directory; requires -enable " + featureFlag[ffTargets].name + ")",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parsePlaybackTarget(spec); return err }),
+		},
+		SpectrumFifo: &Option{
+			name:   "spectrum",
+			kind:   okString,
+			usage:  "FIFO of raw PCM audio (signed 16-bit little-endian stereo at 44.1 kHz) whose spectrum is drawn in a pane beside the media browser, toggled with key 'W'\n  (e.g. written by MPD's fifo output, or by mpv with --ao=pcm --ao-pcm-file=FIFO --ao-pcm-waveheader=no --audio-format=s16 --audio-samplerate=44100 --audio-channels=stereo; requires -enable " + featureFlag[ffSpectrum].name + ")",
+			string: "",
+		},
+		Portable: &Option{
+			name:  "portable",
+			kind:  okBool,
+			usage: "store each library's database in directory \"" + portableDataDirName + "\" at the library root instead of the data directory, so it travels with the library\n  (e.g. on an external drive; the file paths in the database are re-anchored when the library is opened from a different path)",
+			bool:  false,
+		},
+		PathRemap: &Option{
+			name:       "remap",
+			kind:       okStringList,
+			usage:      "declares a path remap rule of the form OLD=NEW for libraries that were moved, migrating the database of each library under path OLD to its new path under NEW instead of rescanning it\n  (may be given multiple times; only applies to libraries without a database at their new path)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parseRemapRule(spec); return err }),
+		},
+		PollFreq: &Option{
+			name:       "poll",
+			kind:       okStringList,
+			usage:      "polls a library's directories for changes at the given interval, of the form INTERVAL[@LIBRARY] (e.g. 5m), rescanning the library when any changed; useful on network shares\n  (may be given multiple times; if LIBRARY is omitted, all libraries are polled)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parsePollRule(spec); return err }),
+		},
+		Archives: &Option{
+			name:       "archive",
+			kind:       okStringList,
+			usage:      "lists the media inside archives when scanning a library as if they were files, of the form FORMAT[,FORMAT...][@LIBRARY] where FORMAT is zip (.zip, .cbz) or rar (.rar, .cbr; requires unrar), extracting each to a temporary directory when it is played\n  (may be given multiple times; if LIBRARY is omitted, applies to all libraries; listing archives can be slow)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parseArchiveRule(spec); return err }),
+		},
+		FullScan: &Option{
+			name:  "fullscan",
+			kind:  okBool,
+			usage: "examine every file when scanning, including those in directories unchanged since the last scan (which are skipped by default)",
+			bool:  false,
+		},
+		FSRetries: &Option{
+			name:  "fsretries",
+			kind:  okUint,
+			usage: "max number of times a file system operation failing with a transient error (e.g. a busy file or network share) is retried while scanning, waiting longer before each retry (0 = never retry)",
+			uint:  defaultFSRetries,
+		},
+		Settle: &Option{
+			name:     "settle",
+			kind:     okString,
+			usage:    "time since its last modification after which a file is presumed complete (e.g. 30s); files modified more recently, or beside a download in progress (e.g. *.part), are not added to a library until their size stops changing (0 = only check for downloads in progress)",
+			string:   defaultSettle,
+			validate: func(o *Option) error { _, err := parseSettle(o.string); return err },
+		},
+		TorrentResume: &Option{
+			name:       "torrentresume",
+			kind:       okStringList,
+			usage:      "directory of a torrent client's resume data (e.g. qBittorrent's BT_backup, or Transmission's resume), whose torrents' files are not added to a library until they are completely downloaded, so that libraries may be pointed at download and seeding directories\n  (may be given multiple times; replaces the default directories: " + strings.Join(defaultTorrentResume, ", ") + ")",
+			StringList: StringList{},
+		},
+		CheckAudio: &Option{
+			name:  "checkaudio",
+			kind:  okBool,
+			usage: "verify while scanning that each new audio file begins with the header of the format of its file name extension (e.g. an MPEG frame for .mp3), flagging those that do not (e.g. an HTML page saved as .mp3) as issues instead of adding them to the library",
+			bool:  false,
+		},
+		Sniff: &Option{
+			name:       "sniff",
+			kind:       okStringList,
+			usage:      "classifies the files of a library without a file name extension, or with an unknown one, by their content (magic numbers), of the form KIND[,KIND...][@LIBRARY] where KIND is one of: " + strings.Join(sniffKindName, ", ") + "\n  (may be given multiple times; if LIBRARY is omitted, applies to all libraries; files of known extensions are never read)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parseSniffRule(spec); return err }),
+		},
+		FollowLinks: &Option{
+			name:  "followsymlinks",
+			kind:  okBool,
+			usage: "scan symlinks as the files or directories they point to (e.g. libraries assembled from symlinked mount points), instead of skipping them\n  (symlinked directories containing the symlink, already part of the library, or already reached through another symlink are skipped)",
+			bool:  false,
+		},
+		Prune: &Option{
+			name:  "prune",
+			kind:  okBool,
+			usage: "remove from each library's database, when loading it, the records of media whose files no longer exist (e.g. deleted or moved outside of pimm), instead of only flagging them\n  (never performed if the library's root directory cannot be read, e.g. a drive that is not mounted)",
+			bool:  false,
+		},
+		IgnoreName: &Option{
+			name:       "ignore",
+			kind:       okStringList,
+			usage:      "name `pattern` of files and directories ignored by the scanner (e.g. \"*.nfo\" or \".sync\"), in addition to the operating system metadata always ignored: " + strings.Join(defaultIgnoreName, ", ") + "\n  (may be given multiple times; patterns are matched against names, case-insensitive, and the contents of directories ignored are never scanned)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, err := parseIgnoreName(spec); return err }),
+		},
+		Tombstones: &Option{
+			name:     "tombstones",
+			kind:     okString,
+			usage:    "`duration` for which the records removed from a library database (e.g. of missing files) are kept as tombstones, from which the removal may be undone and moved files inherit their records, such as \"168h\"\n  (0 removes records outright)",
+			string:   defaultTombstoneRetention,
+			validate: func(o *Option) error { _, err := parseTombstoneRetention(o.string); return err },
+		},
+		MaxProcs: &Option{
+			name:  "maxprocs",
+			kind:  okInt,
+			usage: "max number of OS threads executing simultaneously (0 = number of CPUs)",
+			int:   0,
+		},
+		ScanWorkers: &Option{
+			name:  "scanworkers",
+			kind:  okUint,
+			usage: "max number of libraries whose file systems are scanned concurrently (defaults to the number of CPUs; use 1 when all libraries share a single spinning disk)",
+			uint:  defaultScanWorkers,
+		},
+		DirWorkers: &Option{
+			name:  "dirworkers",
+			kind:  okUint,
+			usage: "max number of directories of each library scanned concurrently (defaults to the number of CPUs; use 1 to scan them in turn, e.g. on a spinning disk)",
+			uint:  defaultDirWorkers,
+		},
+		DBBackend: &Option{
+			name:     "db",
+			kind:     okEnum,
+			usage:    "library database `backend`, one of: " + strings.Join(dbBackendName, ", ") + "\n  (\"" + dbBackendMemory + "\" keeps databases in memory, discarded on exit; nothing is written to the data directory)",
+			string:   dbBackendDisk,
+			choice:   dbBackendName,
+			validate: func(*Option) error { return validateBackend(options) },
+		},
+		Engine: &Option{
+			name:   "engine",
+			kind:   okEnum,
+			usage:  "storage `engine` of newly created library databases, one of: " + strings.Join(dbEngineName, ", ") + "\n  (existing databases keep their engine until migrated from the database settings page; \"" + dbEngineBolt + "\" ignores -diskbuffersize and -hashbuffersize, and matches subtitles to videos case-insensitively)",
+			string: dbEngineTiedot,
+			choice: dbEngineName,
+		},
+		Simulate: &Option{
+			name:  "simulate",
+			kind:  okBool,
+			usage: "replace the libraries with simulated ones which discover fake media at a fixed rate, without touching any file system or database; the positional arguments only name the libraries\n  (the same media are discovered on every run; useful for developing and profiling the user interface)",
+			bool:  false,
+		},
+		SimRate: &Option{
+			name:  "simrate",
+			kind:  okUint,
+			usage: "number of fake media discovered per second by each simulated library (0 = unlimited; see -simulate)",
+			uint:  defaultSimRate,
+		},
+		SimCount: &Option{
+			name:  "simcount",
+			kind:  okUint,
+			usage: "number of fake media discovered by each simulated library (see -simulate)",
+			uint:  defaultSimCount,
+		},
+		TermTitle: &Option{
+			name:  "title",
+			kind:  okBool,
+			usage: "set the terminal title to the current context (active library and its load or scan progress), restoring the original title on exit",
+			bool:  true,
+		},
+		TmuxStatus: &Option{
+			name:  "tmux",
+			kind:  okBool,
+			usage: "when running in tmux, also set window option \"" + tmuxStatusName + "\" to the terminal title (see -title)\n  (e.g. show it with: set -g status-right '#{" + tmuxStatusName + "}')",
+			bool:  false,
+		},
+		GlyphIndicators: &Option{
+			name:  "glyphs",
+			kind:  okBool,
+			usage: "mark the state of items and libraries (" + strings.Join(indicatorName[:], ", ") + ") with distinct glyphs rather than by color alone\n  (color-blind friendly; see -glyph)",
+			bool:  false,
+		},
+		IndicatorGlyph: &Option{
+			name:       "glyph",
+			kind:       okStringList,
+			usage:      "changes the glyph marking a state, of the form STATE=GLYPH, where STATE is one of: " + strings.Join(indicatorName[:], ", ") + "\n  (may be given multiple times; see -glyphs)",
+			StringList: StringList{},
+			validate:   validateEach(func(spec string) error { _, _, err := parseIndicatorGlyph(spec); return err }),
+		},
+		FeatureEnable: &Option{
+			name:       "enable",
+			kind:       okStringList,
+			usage:      "enables an optional subsystem, one of: " + featureNames() + "\n  (may be given multiple times; experimental subsystems are disabled unless enabled)",
+			StringList: StringList{},
+			validate:   validateEach(func(name string) error { _, err := parseFeature(name); return err }),
+		},
+		FeatureDisable: &Option{
+			name:       "disable",
+			kind:       okStringList,
+			usage:      "disables an optional subsystem (see -enable)\n  (may be given multiple times; takes precedence over -enable)",
+			StringList: StringList{},
+			validate:   validateEach(func(name string) error { _, err := parseFeature(name); return err }),
+		},
+		TimeFormat: &Option{
+			name:     "timefmt",
+			kind:     okString,
+			usage:    "`format` of displayed timestamps, one of: " + strings.Join(timeStyleNames(), ", ") + ", " + timeStyleRelative + " (e.g. \"2 days ago\"), or a Go time layout (e.g. \"Jan 2 15:04\")",
+			string:   timeDefaultStyle,
+			validate: func(o *Option) error { _, err := parseTimeFormat(o.string); return err },
+		},
+		TimeZone: &Option{
+			name:     "tz",
+			kind:     okString,
+			usage:    "time zone of displayed timestamps, an IANA name (e.g. \"America/Chicago\") or UTC (defaults to the local time zone)",
+			string:   "",
+			validate: func(o *Option) error { _, err := parseTimeZone(o.string); return err },
 		},
 	}
 	knownOptions := NamedOption{
@@ -591,27 +1153,72 @@ func initOptions() (options *Options, err *ReturnCode) {
 		"verbose":        options.Verbose,
 		"trace":          options.Trace,
 		"cli":            options.CLIMode,
+		"accessible":     options.LineMode,
 		"log":            options.LogPath,
+		"loglines":       options.LogLines,
+		"quiet":          options.Quiet,
+		"exitmsg":        options.ExitMessage,
 		"config":         options.Config,
 		"libdata":        options.LibData,
 		"diskbuffersize": options.DiskBufferSize,
 		"hashbuffersize": options.HashBufferSize,
+		"quota":          options.LibraryQuota,
+		"minfree":        options.MinFreeSpace,
+		"cachesize":      options.CacheSize,
+		"netrate":        options.NetBandwidth,
+		"netrequests":    options.NetRequests,
+		"acoustid":       options.AcoustIDKey,
+		"field":          options.CustomFields,
+		"preset":         options.LayoutPreset,
+		"presetdef":      options.LayoutPresetDef,
+		"macro":          options.MacroBinding,
+		"scan-dry-run":   options.ScanDryRun,
+		"subsmaxmedia":   options.SubsMaxMedia,
+		"subsdir":        options.SubsSubdir,
+		"subsdisable":    options.SubsDisable,
+		"releasetag":     options.ReleaseTags,
+		"player":         options.Player,
+		"hostargs":       options.HostArgs,
+		"target":         options.Target,
+		"spectrum":       options.SpectrumFifo,
+		"portable":       options.Portable,
+		"remap":          options.PathRemap,
+		"poll":           options.PollFreq,
+		"fullscan":       options.FullScan,
+		"archive":        options.Archives,
+		"fsretries":      options.FSRetries,
+		"settle":         options.Settle,
+		"torrentresume":  options.TorrentResume,
+		"checkaudio":     options.CheckAudio,
+		"sniff":          options.Sniff,
+		"followsymlinks": options.FollowLinks,
+		"prune":          options.Prune,
+		"ignore":         options.IgnoreName,
+		"tombstones":     options.Tombstones,
+		"maxprocs":       options.MaxProcs,
+		"scanworkers":    options.ScanWorkers,
+		"dirworkers":     options.DirWorkers,
+		"db":             options.DBBackend,
+		"engine":         options.Engine,
+		"simulate":       options.Simulate,
+		"simrate":        options.SimRate,
+		"simcount":       options.SimCount,
+		"title":          options.TermTitle,
+		"tmux":           options.TmuxStatus,
+		"glyphs":         options.GlyphIndicators,
+		"glyph":          options.IndicatorGlyph,
+		"enable":         options.FeatureEnable,
+		"disable":        options.FeatureDisable,
+		"timefmt":        options.TimeFormat,
+		"tz":             options.TimeZone,
 	}
 
+	options.Known = knownOptions
+
 	// register the command line options we want to handle.
-	options.BoolVar(&options.CPUProfile.bool, options.CPUProfile.name, options.CPUProfile.bool, options.CPUProfile.usage)
-	options.StringVar(&options.CPUProfileName.string, options.CPUProfileName.name, options.CPUProfileName.string, options.CPUProfileName.usage)
-	options.BoolVar(&options.MEMProfile.bool, options.MEMProfile.name, options.MEMProfile.bool, options.MEMProfile.usage)
-	options.StringVar(&options.MEMProfileName.string, options.MEMProfileName.name, options.MEMProfileName.string, options.MEMProfileName.usage)
-	options.BoolVar(&options.UsageHelp.bool, options.UsageHelp.name, options.UsageHelp.bool, options.UsageHelp.usage)
-	options.BoolVar(&options.Verbose.bool, options.Verbose.name, options.Verbose.bool, options.Verbose.usage)
-	options.BoolVar(&options.Trace.bool, options.Trace.name, options.Trace.bool, options.Trace.usage)
-	options.BoolVar(&options.CLIMode.bool, options.CLIMode.name, options.CLIMode.bool, options.CLIMode.usage)
-	options.StringVar(&options.LogPath.string, options.LogPath.name, options.LogPath.string, options.LogPath.usage)
-	options.StringVar(&options.Config.string, options.Config.name, options.Config.string, options.Config.usage)
-	options.StringVar(&options.LibData.string, options.LibData.name, options.LibData.string, options.LibData.usage)
-	options.IntVar(&options.DiskBufferSize.int, options.DiskBufferSize.name, options.DiskBufferSize.int, options.DiskBufferSize.usage)
-	options.IntVar(&options.HashBufferSize.int, options.HashBufferSize.name, options.HashBufferSize.int, options.HashBufferSize.usage)
+	for _, o := range knownOptions {
+		o.bind(options.FlagSet)
+	}
 
 	// hide the flag.flagSet's default output error message, because we will
 	// display our own.
@@ -619,23 +1226,91 @@ func initOptions() (options *Options, err *ReturnCode) {
 
 	// the output provided with -help or when a option parse error occurred.
 	options.Usage = func() {
-		rawLog.logf("%s v%s (%s@%s) [%s]", identity, version, branch, revision, buildtime)
+		rawLog.log(banner())
 		rawLog.log()
 		options.SetOutput(os.Stdout)
 		options.PrintDefaults()
 		rawLog.log()
+		rawLog.logf("each option may also be provided by environment variable %s<OPTION> (e.g. %sVERBOSE=1);",
+			optionEnvPrefix, optionEnvPrefix)
+		rawLog.logf("list options take multiple values separated by %q. the command line takes precedence.",
+			optionEnvListSep)
+		rawLog.logf("options may also be given in the config file (see -%s); the environment and command line take precedence.",
+			options.Config.name)
+		rawLog.log()
+		printCommands()
+		rawLog.log()
+	}
+
+	// options provided via environment variables act as defaults for those
+	// provided on the command line.
+	fromEnv, envErr := bindEnv(options.FlagSet, knownOptions)
+	if nil != envErr {
+		return nil, rcInvalidArgs.wrap(envErr, "")
+	}
+	for name, o := range fromEnv {
+		options.Provided[name] = o
 	}
 
 	// yeaaaaaaah, now we do it!
-	options.Parse(os.Args[1:])
+	if err := options.Parse(args); nil != err {
+		if flag.ErrHelp == err {
+			// hide the flag.flagSet's default output status message,
+			// because we will print our own.
+			return nil, rcUsage
+		}
+		return nil, rcInvalidArgs.wrap(err, "")
+	}
 	options.Visit(
 		func(f *flag.Flag) { options.Provided[f.Name] = knownOptions[f.Name] })
 
+	// options given in the config file act as defaults for those provided on
+	// the command line or in the environment. the configuration check reports
+	// every problem in the file itself.
+	options.ConfigErrors = loadConfig(options)
+	if len(options.ConfigErrors) > 0 {
+		if cmd, _ := options.command(); nil == cmd || checkConfigCommandName != cmd.name {
+			return nil, rcInvalidConfig.wrap(options.ConfigErrors[0], "")
+		}
+	}
+
 	// update the loggers' verbosity settings.
-	isVerboseLog = options.Verbose.bool
-	isTraceLog = options.Trace.bool
+	setLogVerbosity(options)
 	isCLIMode = options.CLIMode.bool
 
+	// configure the rate limiter shared by all online integrations.
+	setNetLimits(options)
+
+	// configure the thread and worker limits.
+	setPerformance(options)
+
+	// verify all options requiring more than a syntax check. the configuration
+	// check reports every invalid option itself.
+	if err := validateOptions(knownOptions); nil != err {
+		if cmd, _ := options.command(); nil == cmd || checkConfigCommandName != cmd.name {
+			return nil, rcInvalidArgs.wrap(err, "")
+		}
+	}
+
+	// enable or disable the optional subsystems.
+	setFeatures(options)
+
+	// configure how item and library states are indicated in the UI.
+	setIndicators(options)
+
+	// extend the release tags recognized in video file names.
+	setReleaseHints(options)
+
+	// select the external media player and its arguments on this host.
+	setPlayer(options)
+	setHostArgs(options)
+
+	// select how timestamps are displayed.
+	setTimeFormat(options)
+
+	// declare the playback targets and select the last one used.
+	setPlaybackTargets(options)
+
 	var parseError *ReturnCode = nil
 
 	// update program state for global optons.
@@ -647,11 +1322,72 @@ func initOptions() (options *Options, err *ReturnCode) {
 	return options, parseError
 }
 
+// function openLibrary() loads the configuration and prepares the data
+// directory, and then opens the libraries given on the command line.
+func openLibrary(options *Options, busyState *BusyState, args []string) ([]*Library, *ReturnCode) {
+
+	// if no options were provided and no config file exists, then we are
+	// totally lost and confused. display usage and bail out.
+	config := options.Config.string
+	configExists, _ := goutil.PathExists(config)
+	if !configExists && 0 == len(args) {
+		options.Usage()
+		return nil, rcUsage
+	}
+
+	// create the directory hierarchy that will store our configuration data
+	// permanently on disk.
+	configDir := options.configDir()
+	if !configExists {
+		if dirExists, _ := goutil.PathExists(configDir); !dirExists {
+			if err := os.MkdirAll(configDir, os.ModePerm); nil != err {
+				return nil, rcInvalidConfig.wrap(err,
+					"cannot create configuration directory: %q", configDir)
+			}
+			infoLog.tracef("created configuration directory: %q", configDir)
+		}
+
+		// the configuration itself was already loaded (if it existed) along
+		// with the command line options (see function loadConfig()).
+		if ret := createConfig(options); nil != ret {
+			return nil, ret
+		}
+		infoLog.verbosef("created configuration: %q", config)
+	}
+
+	// create the directory hierarchy that will store our libraries' backing
+	// data stores permanently on disk -- unless they are ephemeral, in which
+	// case they are removed once function run() returns.
+	libData := options.LibData.string
+	if options.isMemoryBackend() {
+		if _, ret := memoryDataDir(); nil != ret {
+			return nil, ret
+		}
+	} else if exists, _ := goutil.PathExists(libData); !exists {
+		if err := os.MkdirAll(libData, os.ModePerm); nil != err {
+			return nil, rcInvalidConfig.wrap(err,
+				"cannot create shared data directory: %q", libData)
+		}
+		infoLog.tracef("created shared data directory: %q", libData)
+	} else {
+		infoLog.tracef("(TBD) -- loading shared data directory: %q", libData)
+	}
+
+	// runtime environment defined, begin preparing the libs and databases.
+	infoLog.log("initializing library databases ...")
+
+	// remaining arguments are considered paths to libraries; verify the paths
+	// before assuming valid ones exist for traversal.
+	return initLibrary(options, busyState)
+}
+
 // function initLibrary() validates all library paths provided, returning a list
-// of the valid ones.
-func initLibrary(options *Options, busyState *BusyState) []*Library {
+// of the valid ones. returns rcInvalidConfig if none of them are valid, or
+// rcLockHeld if every one of them is in use by another process.
+func initLibrary(options *Options, busyState *BusyState) ([]*Library, *ReturnCode) {
 
 	var library []*Library
+	var held int // number of libraries whose database is in use
 
 	// any remaining args were not handled by the options parser. they are then
 	// considered to be file paths of libraries to scan.
@@ -666,9 +1402,12 @@ func initLibrary(options *Options, busyState *BusyState) []*Library {
 		// of valid libraries, and continue. if it is truly a fatal error, then
 		// all user-provided libraries will fail for the same reason; the list
 		// of valid libraries will be empty on return, and the program will
-		// terminate with error "no libraries found".
+		// terminate with error "no valid libraries provided".
 		if nil != err {
 			warnLog.log(err)
+			if err.is(rcLockHeld) {
+				held++
+			}
 		} else {
 			// no error encountered, so the library is considered valid. add it
 			// to the queue.
@@ -677,12 +1416,28 @@ func initLibrary(options *Options, busyState *BusyState) []*Library {
 		}
 	}
 
-	return library
+	if 0 == len(library) {
+		if held > 0 && held == len(libArgs) {
+			return nil, rcLockHeld.spec("all libraries are in use by other processes")
+		}
+		return nil, rcInvalidConfig.spec("no valid libraries provided")
+	}
+	reportUnknownScopes(options, library)
+	return library, nil
 }
 
 // function populateLibrary() spawns goroutines to scan each library
-// concurrently.
-func populateLibrary(options *Options, library []*Library) {
+// concurrently, until the given context is cancelled. returns rcInvalidArgs if
+// no libraries are given, since nothing would ever signal their completion.
+func populateLibrary(ctx context.Context, options *Options, library []*Library) *ReturnCode {
+
+	if 0 == len(library) {
+		return rcInvalidArgs.spec("populateLibrary(): no libraries provided")
+	}
+	// polling only keeps the UI up to date, so read the mode once, here,
+	// rather than in each goroutine, since function die() may change it
+	// meanwhile.
+	isPolling := !isCLIMode && ffPoll.gate(options, options.PollFreq)
 
 	// for each library, dispatch a pair (2) of goroutines in order:
 	//   1. dump all of the content from the library's database, verifying it
@@ -698,23 +1453,21 @@ func populateLibrary(options *Options, library []*Library) {
 		go func(l *Library) {
 			var numMedia uint = 0
 			if !l.db.isFirstAppearance() {
-				loadCount, loadErr := l.load(
+				loadCount, loadErr := l.load(ctx,
 					&PathHandler{
 						// the loader identified some file in a subdirectory of
 						// the library's file system as a media file.
 						handleMedia: func(l *Library, p string, v ...interface{}) {
-							//disco := newDiscovery(v...)
 							if !isCLIMode {
-								//l.layout.addDiscovery(l, disco)
+								l.discover(newDiscovery(v...))
 							}
 						},
 						// the loader identified some file in a subdirectory of
 						// the library's file system as a supporting auxiliary
 						// file to a known or as-of-yet unknown media file.
 						handleSupport: func(l *Library, p string, v ...interface{}) {
-							//disco := newDiscovery(v...)
 							if !isCLIMode {
-								//l.layout.addDiscovery(l, disco)
+								l.discover(newDiscovery(v...))
 							}
 						},
 						// the loader identified some file in a subdirectory of
@@ -724,8 +1477,9 @@ func populateLibrary(options *Options, library []*Library) {
 						},
 					})
 				numMedia += loadCount
-				if nil != loadErr {
+				if nil != loadErr && !loadErr.is(rcInterrupted) {
 					errLog.verbose(loadErr)
+					l.failed = loadErr
 				}
 			}
 			l.loadComplete <- numMedia
@@ -736,42 +1490,56 @@ func populateLibrary(options *Options, library []*Library) {
 		go func(l *Library) {
 			// postpone the scanning until the load routine has completed.
 			var numMedia uint = (<-l.loadComplete).(uint)
-			scanCount, scanErr := l.scan(
-				&PathHandler{
-					// the scanner identified some file in a subdirectory of the
-					// library's file system as a media file.
-					handleMedia: func(l *Library, p string, v ...interface{}) {
-						//disco := newDiscovery(v...)
-						if !isCLIMode {
-							//l.layout.addDiscovery(l, disco)
-						}
-					},
-					// the scanner identified some file in a subdirectory of the
-					// library's file system as a supporting auxiliary file to a
-					// known or as-of-yet unknown media file.
-					handleSupport: func(l *Library, p string, v ...interface{}) {
-						//disco := newDiscovery(v...)
-						if !isCLIMode {
-							//l.layout.addDiscovery(l, disco)
-						}
-					},
-					// the scanner identified some file in a subdirectory of the
-					// library's file system as an undesirable piece of trash.
-					handleOther: func(l *Library, p string, v ...interface{}) {
-					},
-				})
+			handler := &PathHandler{
+				// the scanner identified some file in a subdirectory of the
+				// library's file system as a media file.
+				handleMedia: func(l *Library, p string, v ...interface{}) {
+					if !isCLIMode {
+						l.discover(newDiscovery(v...))
+					}
+				},
+				// the scanner identified some file in a subdirectory of the
+				// library's file system as a supporting auxiliary file to a
+				// known or as-of-yet unknown media file.
+				handleSupport: func(l *Library, p string, v ...interface{}) {
+					if !isCLIMode {
+						l.discover(newDiscovery(v...))
+					}
+				},
+				// the scanner identified some file in a subdirectory of the
+				// library's file system as an undesirable piece of trash.
+				handleOther: func(l *Library, p string, v ...interface{}) {
+				},
+			}
+			scanCount, scanErr := l.scan(ctx, handler)
+			// if the system was suspended while we were scanning, the library's
+			// file system may have disappeared from under us (e.g. a network
+			// share or USB drive that was unmounted), so scan it again. the
+			// scan counters are cumulative, so the latest count is the total.
+			for nil == scanErr && l.takeRescanRequest() {
+				infoLog.logf("rescanning library interrupted by system suspend: %q", l.name)
+				scanCount, scanErr = l.scan(ctx, handler)
+			}
 			numMedia += scanCount
-			if nil != scanErr {
+			if nil != scanErr && !scanErr.is(rcInterrupted) {
 				errLog.verbose(scanErr)
+				l.failed = scanErr
 			}
-			if 0 == numMedia {
+			if 0 == numMedia && !scanErr.is(rcInterrupted) {
 				warnLog.logf("no media in %q: library is empty!", l.name)
-				if !isVerboseLog && !isTraceLog {
+				if !isVerbose() && !isTrace() {
 					warnLog.logf("try using program options -%s or -%s for more info",
 						options.Verbose.name, options.Trace.name)
 				}
 			}
 			l.scanComplete <- numMedia
+
+			// keep the library up to date for as long as the UI is running
+			// on file systems where it was requested.
+			if isPolling {
+				l.poll(ctx, handler)
+			}
 		}(lib)
 	}
+	return nil
 }
@@ -17,10 +17,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"strings"
 	"time"
 
-	"github.com/HouzuoGuo/tiedot/db"
 	//"github.com/davecgh/go-spew/spew"
 )
 
@@ -58,22 +58,57 @@ type Media struct {
 	Title       string    // official name of media
 	Description string    // synopsis/summary of media content
 	ReleaseDate time.Time // date media was produced/released
+	Tags        []string  // user-defined labels
+	Notes       string    // free-form user notes (e.g. known defects), may span lines
+	// playback statistics
+	PlayCount  int       // number of times media was played
+	LastPlayed time.Time // date media was most recently played
+	// user-defined media info
+	Fields map[string]interface{} // values of the library's user-defined metadata fields
 }
 
 // type AudioMedia is a specialized type of media containing struct fields
 // relevant only to video.
 type AudioMedia struct {
-	*Media        // common media info
-	Album  string // name of the album on which the track appears
-	Track  int64  // numbered index of where track is located on album
+	*Media               // common media info
+	Artist    string     // name of the artist performing the track
+	Album     string     // name of the album on which the track appears
+	Track     int64      // numbered index of where track is located on album
+	Bookmarks []Bookmark // named playback positions saved by the user
+	AcoustID  string     // AcoustID of the recording identified by fingerprint
 }
 
+// type Bookmark represents a named position within a media file from which
+// playback can be resumed, e.g. the beginning of a chapter in an audiobook.
+type Bookmark struct {
+	Name        string        // user-defined label
+	Position    time.Duration // offset from the beginning of the media
+	TimeCreated time.Time     // date the bookmark was saved
+}
+
+// local unexported constants for identifying long-form audio.
+const (
+	// audio files at least this large are treated as audiobook-ish content
+	// even if their file name extension isn't typical of audiobooks.
+	minAudiobookSize int64 = 128 * mebiBytes
+)
+
+var (
+	// var audiobookExt lists the file name extensions used almost exclusively
+	// by audiobooks.
+	audiobookExt = []string{".m4b", ".aa", ".aax"}
+)
+
 // type VideoMedia is a specialized type of media containing struct fields
 // relevant only to audio.
 type VideoMedia struct {
-	*Media                     // common media info
-	KnownSubtitles []Subtitles // absolute path to all associated subtitles
-	Subtitles      Subtitles   // absolute path to selected subtitles
+	*Media                        // common media info
+	KnownSubtitles   []Subtitles  // absolute path to all associated subtitles
+	Subtitles        Subtitles    // absolute path to selected subtitles
+	KnownAudioTracks []AudioTrack // absolute path to all associated external audio tracks
+	AudioTrack       AudioTrack   // absolute path to selected external audio track
+	SubtitleStream   int          // selected embedded subtitles stream (1-based, 0 = player default)
+	AudioStream      int          // selected embedded audio stream (1-based, 0 = player default)
 }
 
 type MediaIndexID int
@@ -111,6 +146,11 @@ func newMedia(lib *Library, kind MediaKind, absPath, relPath, ext, extName strin
 		Title:           info.Name(), // (string)    official name of media
 		Description:     "--",        // (string)    synopsis/summary of media content
 		ReleaseDate:     time.Time{}, // (time.Time) date media was produced/released
+		Tags:            []string{},
+		Notes:           "",          // (string)    free-form user notes
+		PlayCount:       0,           // (int)       number of times media was played
+		LastPlayed:      time.Time{}, // (time.Time) date media was most recently played
+		Fields:          map[string]interface{}{},
 	}
 }
 
@@ -122,10 +162,136 @@ func newAudioMedia(lib *Library, absPath, relPath, ext, extName string, info os.
 	media := newMedia(lib, mkAudio, absPath, relPath, ext, extName, info)
 
 	return &AudioMedia{
-		Media: media, // common media info
-		Album: "",    // name of the album on which the track appears
-		Track: -1,    // numbered index of where track is located on album
+		Media:     media,        // common media info
+		Artist:    "",           // name of the artist performing the track
+		Album:     "",           // name of the album on which the track appears
+		Track:     -1,           // numbered index of where track is located on album
+		Bookmarks: []Bookmark{}, // named playback positions saved by the user
+		AcoustID:  "",           // AcoustID of the recording identified by fingerprint
+	}
+}
+
+// function String() creates a string representation of the Bookmark for easy
+// identification in logs and lists.
+func (b *Bookmark) String() string {
+	return fmt.Sprintf("%s (%s)", b.Name, b.timestamp())
+}
+
+// function timestamp() formats the bookmark's position as "HH:MM:SS", which is
+// the position format accepted by most media players (omxplayer, mpv, vlc).
+func (b *Bookmark) timestamp() string {
+	sec := int64(b.Position.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", sec/3600, (sec/60)%60, sec%60)
+}
+
+// function isAudiobook() determines if this audio appears to be long-form
+// content (audiobook, podcast, lecture, etc.) for which bookmarks are useful.
+func (m *AudioMedia) isAudiobook() bool {
+	ext := strings.ToLower(m.Ext)
+	for _, e := range audiobookExt {
+		if e == ext {
+			return true
+		}
 	}
+	return m.Size >= minAudiobookSize
+}
+
+// function findBookmark() returns the index of the bookmark with the given
+// name in this AudioMedia object's list of bookmarks, or -1 if not found.
+func (m *AudioMedia) findBookmark(name string) int {
+	for i, b := range m.Bookmarks {
+		if b.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// function addBookmark() saves the given playback position with the given
+// name, replacing any existing bookmark with the same name. the bookmarks are
+// kept sorted by position so that they list in playback order. the database
+// record of this audio is also optionally updated.
+func (m *AudioMedia) addBookmark(col Collection, id int, update bool, name string, pos time.Duration) (*Bookmark, *ReturnCode) {
+
+	if pos < 0 {
+		return nil, rcInvalidArgs.specf(
+			"addBookmark(%q, %s): position must not be negative", name, pos)
+	}
+
+	mark := Bookmark{Name: name, Position: pos, TimeCreated: time.Now()}
+
+	// replace an existing bookmark of the same name, or insert the new one at
+	// its sorted position.
+	if i := m.findBookmark(name); i >= 0 {
+		m.Bookmarks = append(m.Bookmarks[:i], m.Bookmarks[i+1:]...)
+	}
+	i := 0
+	for i < len(m.Bookmarks) && m.Bookmarks[i].Position <= pos {
+		i++
+	}
+	m.Bookmarks = append(m.Bookmarks, Bookmark{})
+	copy(m.Bookmarks[i+1:], m.Bookmarks[i:])
+	m.Bookmarks[i] = mark
+
+	if update {
+		if ret := m.updateRecord(col, id); nil != ret {
+			return nil, ret
+		}
+	}
+	return &m.Bookmarks[i], nil
+}
+
+// function removeBookmark() deletes the bookmark with the given name, returning
+// true if it existed. the database record of this audio is also optionally
+// updated.
+func (m *AudioMedia) removeBookmark(col Collection, id int, update bool, name string) (bool, *ReturnCode) {
+
+	i := m.findBookmark(name)
+	if i < 0 {
+		return false, nil
+	}
+	m.Bookmarks = append(m.Bookmarks[:i], m.Bookmarks[i+1:]...)
+
+	if update {
+		if ret := m.updateRecord(col, id); nil != ret {
+			return false, ret
+		}
+	}
+	return true, nil
+}
+
+// function resumeCommand() constructs the playback command used to resume
+// playback from the bookmark with the given name. the bookmark position is
+// appended to the configured playback command using omxplayer's "--pos"
+// option, the default player on the target platform.
+func (m *AudioMedia) resumeCommand(name string) (string, *ReturnCode) {
+
+	i := m.findBookmark(name)
+	if i < 0 {
+		return "", rcInvalidArgs.specf(
+			"resumeCommand(%q): no such bookmark: %s", name, m.AbsName)
+	}
+	cmd := m.baseCommand()
+	if "" == cmd {
+		return "", rcInvalidArgs.specf(
+			"resumeCommand(%q): no playback command is configured: %s", name, m.AbsName)
+	}
+	return fmt.Sprintf("%s --pos %s", cmd, m.Bookmarks[i].timestamp()), nil
+}
+
+// function updateRecord() writes the current state of this AudioMedia object
+// to its record in the given collection with the given hash key id.
+func (m *AudioMedia) updateRecord(col Collection, id int) *ReturnCode {
+
+	rec, ret := m.toRecord()
+	if nil != ret {
+		return ret
+	}
+	if err := col.Update(id, *rec); nil != err {
+		return rcDatabaseError.specf(
+			"updateRecord(%v, %d): failed to update record: %s", col, id, err)
+	}
+	return nil
 }
 
 // function newVideoMedia() creates and initializes a new VideoMedia object
@@ -135,13 +301,39 @@ func newVideoMedia(lib *Library, absPath, relPath, ext, extName string, info os.
 
 	media := newMedia(lib, mkVideo, absPath, relPath, ext, extName, info)
 
+	// scene and P2P release names carry much more than the title.
+	title, year := parseTitle(media.AbsBase)
+	media.Title = title
+	if year > 0 {
+		media.ReleaseDate = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
 	return &VideoMedia{
-		Media:          media,         // common media info
-		KnownSubtitles: []Subtitles{}, // absolute path to all associated subtitles
-		Subtitles:      Subtitles{},   // absolute path to selected subtitles
+		Media:            media,          // common media info
+		KnownSubtitles:   []Subtitles{},  // absolute path to all associated subtitles
+		Subtitles:        Subtitles{},    // absolute path to selected subtitles
+		KnownAudioTracks: []AudioTrack{}, // absolute path to all associated external audio tracks
+		AudioTrack:       AudioTrack{},   // absolute path to selected external audio track
+		SubtitleStream:   0,              // selected embedded subtitles stream (player default)
+		AudioStream:      0,              // selected embedded audio stream (player default)
 	}
 }
 
+// function updateRecord() writes the current state of this VideoMedia object
+// to its record in the given collection with the given hash key id.
+func (m *VideoMedia) updateRecord(col Collection, id int) *ReturnCode {
+
+	rec, ret := m.toRecord()
+	if nil != ret {
+		return ret
+	}
+	if err := col.Update(id, *rec); nil != err {
+		return rcDatabaseError.specf(
+			"updateRecord(%v, %d): failed to update record: %s", col, id, err)
+	}
+	return nil
+}
+
 func (m *VideoMedia) String() string {
 	s := m.Entity.String()
 	if len(m.KnownSubtitles) > 0 {
@@ -154,6 +346,16 @@ func (m *VideoMedia) String() string {
 		}
 		s = fmt.Sprintf("%s Subtitles:{%s}", s, t)
 	}
+	if len(m.KnownAudioTracks) > 0 {
+		t := ""
+		for i, u := range m.KnownAudioTracks {
+			if i > 0 {
+				t = fmt.Sprintf("%s, ", t)
+			}
+			t = fmt.Sprintf("%s[%d:\"%s\"]", t, i, u.RelPath)
+		}
+		s = fmt.Sprintf("%s AudioTracks:{%s}", s, t)
+	}
 	return s
 }
 
@@ -162,7 +364,7 @@ func (m *VideoMedia) String() string {
 // subtitles. additionally, the subs are optionally set as the preferred subs to
 // be used during playback; the database record of this video is also optionally
 // updated to store the subs in the list of known subtitles.
-func (m *VideoMedia) addSubtitles(vidCol, subCol *db.Col, vidID, subID int, update, preferred bool, subs *Subtitles) (bool, *ReturnCode) {
+func (m *VideoMedia) addSubtitles(vidCol, subCol Collection, vidID, subID int, update, preferred bool, subs *Subtitles) (bool, *ReturnCode) {
 
 	var (
 		rec     *EntityRecord
@@ -209,6 +411,109 @@ func (m *VideoMedia) addSubtitles(vidCol, subCol *db.Col, vidID, subID int, upda
 	return !subSeen, nil
 }
 
+// function addAudioTrack() adds the given AudioTrack to this VideoMedia object
+// if and only if the track does not already exist in the object's list of known
+// audio tracks. see function addSubtitles() for discussion of the remaining
+// arguments, which are handled identically.
+func (m *VideoMedia) addAudioTrack(vidCol, audCol Collection, vidID, audID int, update, preferred bool, track *AudioTrack) (bool, *ReturnCode) {
+
+	audSeen := false
+	for _, a := range m.KnownAudioTracks {
+		if a.AbsPath == track.AbsPath {
+			audSeen = true
+			break
+		}
+	}
+	if !audSeen {
+		m.KnownAudioTracks = append(m.KnownAudioTracks, *track)
+	}
+	if preferred {
+		m.AudioTrack = *track
+	}
+
+	if update {
+		if ret := m.updateRecord(vidCol, vidID); nil != ret {
+			return false, ret
+		}
+	}
+
+	if ok, err := track.addVideoMedia(audCol, audID, update, m); !ok {
+		return false, err
+	}
+	return !audSeen, nil
+}
+
+// function selectTracks() selects the subtitles and external audio track to be
+// used during playback, identified by index in the lists of known subtitles and
+// audio tracks. a negative index deselects the corresponding track, so that the
+// video is played without subtitles or with its own embedded audio. the
+// database record of this video is also optionally updated.
+func (m *VideoMedia) selectTracks(col Collection, id int, update bool, subs, audio int) *ReturnCode {
+
+	if subs >= len(m.KnownSubtitles) || audio >= len(m.KnownAudioTracks) {
+		return rcInvalidArgs.specf(
+			"selectTracks(%d, %d): no such track: %s", subs, audio, m.AbsName)
+	}
+	m.Subtitles = Subtitles{}
+	if subs >= 0 {
+		m.Subtitles = m.KnownSubtitles[subs]
+	}
+	m.AudioTrack = AudioTrack{}
+	if audio >= 0 {
+		m.AudioTrack = m.KnownAudioTracks[audio]
+	}
+	if update {
+		return m.updateRecord(col, id)
+	}
+	return nil
+}
+
+// function selectStreams() selects the embedded subtitles and audio streams to
+// be used during playback, numbered from 1 as most players list them. a zero
+// stream number leaves the choice to the player. the database record of this
+// video is also optionally updated.
+func (m *VideoMedia) selectStreams(col Collection, id int, update bool, subs, audio int) *ReturnCode {
+
+	if subs < 0 || audio < 0 {
+		return rcInvalidArgs.specf(
+			"selectStreams(%d, %d): stream numbers must not be negative: %s", subs, audio, m.AbsName)
+	}
+	m.SubtitleStream = subs
+	m.AudioStream = audio
+	if update {
+		return m.updateRecord(col, id)
+	}
+	return nil
+}
+
+// function playbackCommand() constructs the playback command for this video,
+// appending the selected subtitles, external audio track, and embedded streams
+// (if any) to the configured playback command (see function baseCommand()),
+// or returns an empty string if none is configured. these selections are stored in
+// the video's database record, so they are reused for every playback until
+// changed. omxplayer, the default player on the target platform, has no
+// option for loading an external audio track, so the track is given using
+// mpv's "--audio-file" option (and the streams using mpv's "--sid"/"--aid");
+// the user's configured player must accept them for the selection to take
+// effect.
+func (m *VideoMedia) playbackCommand() string {
+	cmd := m.baseCommand()
+	if "" == cmd {
+		return ""
+	}
+	if nil != m.Subtitles.Support && "" != m.Subtitles.AbsPath {
+		cmd = fmt.Sprintf("%s --subtitles %s", cmd, quoteArg(m.Subtitles.AbsPath))
+	} else if m.SubtitleStream > 0 {
+		cmd = fmt.Sprintf("%s --sid=%d", cmd, m.SubtitleStream)
+	}
+	if nil != m.AudioTrack.Support && "" != m.AudioTrack.AbsPath {
+		cmd = fmt.Sprintf("%s --audio-file %s", cmd, quoteArg(m.AudioTrack.AbsPath))
+	} else if m.AudioStream > 0 {
+		cmd = fmt.Sprintf("%s --aid=%d", cmd, m.AudioStream)
+	}
+	return cmd
+}
+
 // type MediaExt is a struct pairing MediaKind values to their corresponding
 // ExtTable map.
 type MediaExt struct {
@@ -313,6 +618,19 @@ func mediaKindOfFileExt(ext string) (MediaKind, string) {
 	return mkUnknown, ""
 }
 
+// function mediaKindOfFile() determines the MediaKind of the file with the
+// given absolute path and file name extension. this is the same as function
+// mediaKindOfFileExt(), except that audio files whose name identifies them as
+// a commentary track are not considered media (see supportKindOfFile()).
+func mediaKindOfFile(absPath, ext string) (MediaKind, string) {
+
+	kind, extName := mediaKindOfFileExt(ext)
+	if mkAudio == kind && isCommentaryName(strings.TrimSuffix(path.Base(absPath), ext)) {
+		return mkUnknown, ""
+	}
+	return kind, extName
+}
+
 // function toRecord() creates a struct capable of being stored in the database.
 // defines type AudioMedia's implementation of the StorableEntity interface.
 func (m *AudioMedia) toRecord() (*EntityRecord, *ReturnCode) {
@@ -360,7 +678,7 @@ func (m *AudioMedia) fromRecord(data []byte) *ReturnCode {
 
 // function fromID() creates a concrete AudioMedia struct using the record
 // stored in the given collection with the given hash key id.
-func (m *AudioMedia) fromID(col *db.Col, id int) *ReturnCode {
+func (m *AudioMedia) fromID(col Collection, id int) *ReturnCode {
 
 	read, readErr := col.Read(id)
 	if nil != readErr {
@@ -433,7 +751,7 @@ func (m *VideoMedia) fromRecord(data []byte) *ReturnCode {
 
 // function fromID() creates a concrete VideoMedia struct using the record
 // stored in the given collection with the given hash key id.
-func (m *VideoMedia) fromID(col *db.Col, id int) *ReturnCode {
+func (m *VideoMedia) fromID(col Collection, id int) *ReturnCode {
 
 	read, readErr := col.Read(id)
 	if nil != readErr {
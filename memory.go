@@ -0,0 +1,98 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: memory.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the ephemeral "memory" database backend. the records of all
+//    libraries are kept in memory by the memory storage engine (see package
+//    internal/db), and are discarded when the program exits. the few small
+//    files kept beside each database (its configuration, lock, etc.) are
+//    written to a private directory on a RAM-backed file system where one is
+//    available, which is removed when the program exits. nothing is written to the data directory,
+//    which is useful for browsing a folder just once, for demos, and for tests.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"ardnew.com/pimmp/internal/db"
+)
+
+// local unexported constants for the database backends.
+const (
+	dbBackendDisk   = "disk"   // databases persist in the data directory
+	dbBackendMemory = "memory" // databases are discarded on exit
+
+	// storage engine of every database while using the memory backend,
+	// which cannot be selected with the -engine option.
+	dbEngineMemory = db.EngineMemory
+)
+
+var (
+	// variable dbBackendName lists the choices of the -db option.
+	dbBackendName = []string{dbBackendDisk, dbBackendMemory}
+
+	// variable memoryData is the directory containing the databases of all
+	// libraries while using the memory backend. it is created on first use.
+	memoryData struct {
+		sync.Once
+		path string
+		err  error
+	}
+)
+
+// function isMemoryBackend() returns true if the databases are discarded on
+// exit rather than stored in the data directory.
+func (o *Options) isMemoryBackend() bool {
+	return dbBackendMemory == o.DBBackend.string
+}
+
+// function memoryDataDir() returns the directory containing the databases of
+// all libraries while using the memory backend, creating it if necessary.
+func memoryDataDir() (string, *ReturnCode) {
+	memoryData.Do(func() {
+		memoryData.path, memoryData.err = ioutil.TempDir(
+			ramDir(), fmt.Sprintf("%s-%d-", identity, os.Getpid()))
+		if nil == memoryData.err {
+			infoLog.tracef("created ephemeral data directory: %q", memoryData.path)
+		}
+	})
+	if nil != memoryData.err {
+		return "", rcInvalidDatabase.wrap(memoryData.err,
+			"memoryDataDir(): ioutil.TempDir(%q)", ramDir())
+	}
+	return memoryData.path, nil
+}
+
+// function removeMemoryData() removes the databases of all libraries created
+// while using the memory backend, if any were created. the databases must be
+// closed or no longer in use.
+func removeMemoryData() {
+	if "" == memoryData.path {
+		return
+	}
+	if err := os.RemoveAll(memoryData.path); nil != err {
+		warnLog.logf("failed to remove ephemeral data directory: %q: %s", memoryData.path, err)
+		return
+	}
+	infoLog.tracef("removed ephemeral data directory: %q", memoryData.path)
+}
+
+// function validateBackend() verifies the -db option is compatible with the
+// other options selecting where databases are stored.
+func validateBackend(opt *Options) error {
+	if opt.isMemoryBackend() && opt.Portable.bool {
+		return fmt.Errorf("-%s %s cannot be combined with -%s",
+			opt.DBBackend.name, dbBackendMemory, opt.Portable.name)
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: merge.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the "merge" command, which imports the library databases of
+//    another data directory into the current one. the merge is conflict-safe:
+//    nothing already in the current databases is overwritten or removed; user
+//    data found only in the other databases (tags, field values, bookmarks,
+//    track selections, skip markers) is added to the matching records.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ardnew.com/goutil"
+)
+
+// type MergeReport counts the outcome of merging a library database.
+type MergeReport struct {
+	imported  uint // entire databases copied (library unknown to the target)
+	inserted  uint // records added to the target
+	merged    uint // existing records updated with user data from the source
+	unchanged uint // existing records already containing all of the source's data
+}
+
+// function String() creates a string representation of the MergeReport for
+// easy identification in logs.
+func (r *MergeReport) String() string {
+	return fmt.Sprintf("%d databases imported, %d records inserted, %d merged, %d unchanged",
+		r.imported, r.inserted, r.merged, r.unchanged)
+}
+
+// function copyDir() recursively copies the directory at srcPath to dstPath,
+// which must not exist. database lock files are not copied, since they belong
+// to the process holding the original database open.
+func copyDir(srcPath, dstPath string) *ReturnCode {
+	var ret *ReturnCode
+	filepath.Walk(srcPath,
+		func(p string, info os.FileInfo, err error) error {
+			if nil != err {
+				ret = rcInvalidFile.specf("copyDir(%q): %s", p, err)
+				return err
+			}
+			rel, _ := filepath.Rel(srcPath, p)
+			target := filepath.Join(dstPath, rel)
+			if info.IsDir() {
+				if err := os.MkdirAll(target, os.ModePerm); nil != err {
+					ret = rcInvalidFile.specf("copyDir(%q): os.MkdirAll(): %s", target, err)
+					return err
+				}
+				return nil
+			}
+			if databaseLockFileName == info.Name() {
+				return nil
+			}
+			if ret = copyFile(p, target); nil != ret {
+				return fmt.Errorf("%s", ret)
+			}
+			return nil
+		})
+	return ret
+}
+
+// function recordString() returns the string value of the given record key.
+func recordString(rec EntityRecord, key string) string {
+	s, _ := rec[key].(string)
+	return s
+}
+
+// function mergeList() appends to dst each element of src not already in dst,
+// where elements are considered equal if they have the same identity as given
+// by the identify function. returns the merged list and whether it changed.
+func mergeList(dst, src interface{}, identify func(interface{}) string) ([]interface{}, bool) {
+	d, _ := dst.([]interface{})
+	s, _ := src.([]interface{})
+	seen := map[string]bool{}
+	for _, v := range d {
+		seen[identify(v)] = true
+	}
+	changed := false
+	for _, v := range s {
+		if id := identify(v); !seen[id] {
+			seen[id] = true
+			d = append(d, v)
+			changed = true
+		}
+	}
+	return d, changed
+}
+
+// function isZeroValue() returns true if the given record value is unset:
+// nil, empty, zero, or a struct (map) with no path.
+func isZeroValue(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return "" == t
+	case float64:
+		return 0 == t
+	case bool:
+		return !t
+	case []interface{}:
+		return 0 == len(t)
+	case map[string]interface{}:
+		p, _ := t["AbsPath"].(string)
+		return "" == p
+	}
+	return false
+}
+
+// function mergeRecord() adds the user data of the src record that is missing
+// from the dst record. returns true if dst was changed.
+func mergeRecord(dst, src EntityRecord) bool {
+
+	changed := false
+
+	// tags and bookmarks are merged as sets.
+	if v, ok := mergeList(dst["Tags"], src["Tags"],
+		func(t interface{}) string { return fmt.Sprint(t) }); ok {
+		dst["Tags"] = v
+		changed = true
+	}
+	if v, ok := mergeList(dst["Bookmarks"], src["Bookmarks"],
+		func(b interface{}) string {
+			m, _ := b.(map[string]interface{})
+			return fmt.Sprint(m["Name"])
+		}); ok {
+		dst["Bookmarks"] = v
+		changed = true
+	}
+
+	// field values are only added, never replaced.
+	if s, ok := src["Fields"].(map[string]interface{}); ok {
+		d, _ := dst["Fields"].(map[string]interface{})
+		if nil == d {
+			d = map[string]interface{}{}
+		}
+		for k, v := range s {
+			if _, exists := d[k]; !exists {
+				d[k] = v
+				changed = true
+			}
+		}
+		dst["Fields"] = d
+	}
+
+	// selections are only taken from the source if none was made in dst.
+	for _, key := range []string{"Subtitles", "AudioTrack", "SubtitleStream", "AudioStream"} {
+		if v, ok := src[key]; ok && !isZeroValue(v) && isZeroValue(dst[key]) {
+			dst[key] = v
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// function findMatch() finds the record in the given collection corresponding
+// to the given source record: first by path, and then (for files that were
+// moved when reorganizing drives) by file name, size, and modification time.
+// returns a negative ID if there is no match.
+func findMatch(col Collection, src EntityRecord) (int, EntityRecord, *ReturnCode) {
+
+	lookup := func(key string, val interface{}) (map[int]struct{}, *ReturnCode) {
+		result := map[int]struct{}{}
+		if err := evalQuery(map[string]interface{}{
+			"eq": val,
+			"in": []interface{}{key},
+		}, col, &result); nil != err {
+			return nil, rcQueryError.specf("findMatch(%q = %v): %s", key, val, err)
+		}
+		return result, nil
+	}
+	read := func(id int) EntityRecord {
+		doc, err := col.Read(id)
+		if nil != err {
+			return nil
+		}
+		return EntityRecord(doc)
+	}
+
+	result, ret := lookup("AbsPath", recordString(src, "AbsPath"))
+	if nil != ret {
+		return -1, nil, ret
+	}
+	for id := range result {
+		if rec := read(id); nil != rec {
+			return id, rec, nil
+		}
+	}
+
+	result, ret = lookup("AbsName", recordString(src, "AbsName"))
+	if nil != ret {
+		return -1, nil, ret
+	}
+	for id := range result {
+		rec := read(id)
+		if nil != rec &&
+			fmt.Sprint(rec["Size"]) == fmt.Sprint(src["Size"]) &&
+			fmt.Sprint(rec["TimeModified"]) == fmt.Sprint(src["TimeModified"]) {
+			return id, rec, nil
+		}
+	}
+	return -1, nil, nil
+}
+
+// function mergeCollection() merges every record of the named collection in
+// the src store into the same collection of the dst store.
+func mergeCollection(src, dst Store, name string, report *MergeReport) *ReturnCode {
+
+	if !src.ColExists(name) || !dst.ColExists(name) {
+		return nil
+	}
+	srcCol := src.Use(name)
+	dstCol := dst.Use(name)
+
+	var ret *ReturnCode
+	srcCol.ForEachDoc(
+		func(id int, data []byte) (willMoveOn bool) {
+			rec := EntityRecord{}
+			if err := json.Unmarshal(data, &rec); nil != err {
+				warnLog.tracef("mergeCollection(%q): skipping corrupt record %d: %s", name, id, err)
+				return true
+			}
+
+			var (
+				dstID  int
+				dstRec EntityRecord
+			)
+			if seriesColName == name {
+				// skip markers are matched by their series/season key only.
+				result := map[int]struct{}{}
+				if err := evalQuery(map[string]interface{}{
+					"eq": recordString(rec, "Key"),
+					"in": []interface{}{seriesIndex[0]},
+				}, dstCol, &result); nil != err {
+					ret = rcQueryError.specf("mergeCollection(%q): %s", name, err)
+					return false
+				}
+				dstID = -1
+				for i := range result {
+					dstID = i
+				}
+			} else if dstID, dstRec, ret = findMatch(dstCol, rec); nil != ret {
+				return false
+			}
+
+			switch {
+			case dstID < 0:
+				if _, err := dstCol.Insert(rec); nil != err {
+					ret = rcDatabaseError.specf("mergeCollection(%q): Insert(): %s", name, err)
+					return false
+				}
+				report.inserted++
+			case nil != dstRec && mergeRecord(dstRec, rec):
+				if err := dstCol.Update(dstID, dstRec); nil != err {
+					ret = rcDatabaseError.specf("mergeCollection(%q): Update(%d): %s", name, dstID, err)
+					return false
+				}
+				report.merged++
+			default:
+				report.unchanged++
+			}
+			return true
+		})
+	return ret
+}
+
+// function mergeDatabase() merges all records of the library database at
+// srcPath into the library database at dstPath.
+func mergeDatabase(srcPath, dstPath string, report *MergeReport) *ReturnCode {
+
+	for _, p := range []string{srcPath, dstPath} {
+		if ret := lockDatabase(p); nil != ret {
+			return ret
+		}
+		defer unlockDatabase(p)
+	}
+
+	src, err := openStore(srcPath, storeEngine(srcPath))
+	if nil != err {
+		return rcDatabaseError.specf("mergeDatabase(%q): openStore(): %s", srcPath, err)
+	}
+	defer src.Close()
+	dst, err := openStore(dstPath, storeEngine(dstPath))
+	if nil != err {
+		return rcDatabaseError.specf("mergeDatabase(%q): openStore(): %s", dstPath, err)
+	}
+	defer dst.Close()
+
+	for class := EntityClass(0); class < ecCOUNT; class++ {
+		for _, name := range entityColName[class] {
+			if ret := mergeCollection(src, dst, name, report); nil != ret {
+				return ret
+			}
+		}
+	}
+	// relationships are not merged, since they refer to media by the record IDs
+	// of the src store, which differ from those of the dst store.
+	return mergeCollection(src, dst, seriesColName, report)
+}
+
+// function mergeCommand() implements the "merge" command, merging the library
+// databases of the given data directory into the current data directory (see
+// command line option "-libdata"). pimm must not be running on either data
+// directory during the merge.
+func mergeCommand(options *Options, args []string) *ReturnCode {
+
+	if 1 != len(args) {
+		return rcInvalidArgs.spec("merge: expected SRC_LIBDATA")
+	}
+	srcDir, err := filepath.Abs(args[0])
+	if nil != err {
+		return rcInvalidPath.specf("merge(%q): filepath.Abs(): %s", args[0], err)
+	}
+	dstDir, err := filepath.Abs(options.LibData.string)
+	if nil != err {
+		return rcInvalidPath.specf("merge(%q): filepath.Abs(): %s", options.LibData.string, err)
+	}
+	if srcDir == dstDir {
+		return rcInvalidArgs.specf("merge(%q): cannot merge a data directory into itself", srcDir)
+	}
+
+	fds, err := os.Open(srcDir)
+	if nil != err {
+		return rcInvalidPath.specf("merge(%q): os.Open(): %s", srcDir, err)
+	}
+	info, err := fds.Readdir(0)
+	fds.Close()
+	if nil != err {
+		return rcInvalidPath.specf("merge(%q): Readdir(): %s", srcDir, err)
+	}
+	if err := os.MkdirAll(dstDir, os.ModePerm); nil != err {
+		return rcInvalidPath.specf("merge(%q): os.MkdirAll(): %s", dstDir, err)
+	}
+
+	// the databases of the same library are named differently in each data
+	// directory, so they are matched by library path using the registries.
+	// unregistered (legacy) databases are matched by name.
+	srcReg, ret := loadRegistry(srcDir)
+	if nil != ret {
+		return ret
+	}
+
+	start := time.Now()
+	report := &MergeReport{}
+	for _, i := range info {
+		srcPath := filepath.Join(srcDir, i.Name())
+		// each library database directory contains a data configuration file.
+		if exists, _ := goutil.PathExists(filepath.Join(srcPath, dataConfigFileName)); !i.IsDir() || !exists {
+			continue
+		}
+		dstPath := filepath.Join(dstDir, i.Name())
+		if e := srcReg.byUUID(i.Name()); nil != e {
+			if _, dstPath, ret = registerDatabase(e.Path, dstDir); nil != ret {
+				return ret
+			}
+		}
+		if exists, _ := goutil.PathExists(dstPath); !exists {
+			// the library is unknown to the target, so take its database as-is.
+			if ret := copyDir(srcPath, dstPath); nil != ret {
+				return ret
+			}
+			report.imported++
+			infoLog.verbosef("imported library database: %s", i.Name())
+			continue
+		}
+		if ret := mergeDatabase(srcPath, dstPath, report); nil != ret {
+			return ret
+		}
+		infoLog.verbosef("merged library database: %s", i.Name())
+	}
+
+	rawLog.logf("merged %q into %q: %s (%s)",
+		srcDir, dstDir, report, time.Since(start).Round(time.Millisecond))
+	return nil
+}
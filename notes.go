@@ -0,0 +1,201 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: notes.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the notes editor, a dialog used to write free-form notes about a
+//    single media item (e.g. "re-rip this, audio desync at 1:02:00"). the
+//    notes are stored in the item's database record, matched by searches in
+//    line mode, and included in exports.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for the notes editor.
+const (
+	notesEditorLines = 6  // min number of lines shown in the editor
+	notesEditorWidth = 60 // width of each line in the editor
+)
+
+// type NotesEditorView is the dialog used to edit the notes of the media item
+// currently selected in the media browser. each line of the notes is edited in
+// its own field, and there is always at least one empty line following them,
+// so that notes may be extended by reopening the dialog.
+type NotesEditorView struct {
+	*tview.Form
+	item      *mediaItem
+	record    *RecordID
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// function newNotesEditorView() allocates and initializes the dialog widgets.
+func newNotesEditorView(ui *tview.Application, page string, lib []*Library) *NotesEditorView {
+
+	v := NotesEditorView{
+		Form:      nil,
+		item:      nil,
+		record:    nil,
+		layout:    nil,
+		focusPage: page,
+		focusNext: nil,
+		focusPrev: nil,
+	}
+
+	form := tview.NewForm().
+		SetLabelColor(colorScheme.inactiveMenuText).
+		SetFieldTextColor(colorScheme.inactiveMenuText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	form.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.Form = form
+
+	return &v
+}
+
+func (v *NotesEditorView) desc() string { return "" }
+func (v *NotesEditorView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *NotesEditorView) page() string         { return v.focusPage }
+func (v *NotesEditorView) next() FocusDelegator { return v.focusNext }
+func (v *NotesEditorView) prev() FocusDelegator { return v.focusPrev }
+func (v *NotesEditorView) focus() {
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.Form)
+}
+func (v *NotesEditorView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function setMedia() populates the dialog with the current notes of the given
+// media item.
+func (v *NotesEditorView) setMedia(item *mediaItem, record *RecordID) {
+
+	v.item = item
+	v.record = record
+
+	line := []string{}
+	if "" != item.Notes {
+		line = strings.Split(item.Notes, "\n")
+	}
+	count := len(line) + 1
+	if count < notesEditorLines {
+		count = notesEditorLines
+	}
+
+	v.Clear(true)
+	for i := 0; i < count; i++ {
+		text := ""
+		if i < len(line) {
+			text = line[i]
+		}
+		v.AddInputField(fmt.Sprintf("%2d:", i+1), text, notesEditorWidth, nil, nil)
+	}
+	v.AddButton("Save", v.save).
+		AddButton("Cancel", v.cancel).
+		SetFocus(0)
+
+	v.SetTitle(fmt.Sprintf(" Notes: [#%06x]%s ",
+		colorScheme.highlightPrimary.Hex(), item.Name))
+}
+
+// function text() returns the notes entered in the dialog, without trailing
+// empty lines.
+func (v *NotesEditorView) text() string {
+
+	line := []string{}
+	for i := 0; i < v.GetFormItemCount(); i++ {
+		if field, ok := v.GetFormItem(i).(*tview.InputField); ok {
+			line = append(line, strings.TrimRight(field.GetText(), " \t"))
+		}
+	}
+	return strings.TrimRight(strings.Join(line, "\n"), "\n")
+}
+
+// function save() stores the dialog's notes in the media item's database
+// record.
+func (v *NotesEditorView) save() {
+
+	entity, ok := v.record.rec.(StorableEntity)
+	if !ok {
+		warnLog.logf("(ignored) media is not storable: %s", v.item.AbsName)
+		v.layout.focusQueue <- v.layout.focusBase
+		return
+	}
+
+	prev := v.item.Notes
+	v.item.Notes = v.text()
+	rec, ret := entity.toRecord()
+	if nil == ret {
+		col := v.item.SourceLibrary.db.col[ecMedia][v.item.Kind]
+		if err := col.Update(v.record.id, *rec); nil != err {
+			ret = rcDatabaseError.specf(
+				"save(%q, %d): failed to update record: %s", v.item.AbsName, v.record.id, err)
+		}
+	}
+	if nil != ret {
+		v.item.Notes = prev
+		warnLog.log(ret)
+	} else if "" == v.item.Notes {
+		infoLog.logf("removed notes: %s", v.item.Name)
+	} else {
+		infoLog.logf("saved notes: %s", v.item.Name)
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function cancel() closes the dialog without modifying anything.
+func (v *NotesEditorView) cancel() {
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function notesEvent() handles the key opening the notes editor for the media
+// item currently selected in the media browser. returns true if the key was
+// handled.
+func (l *Layout) notesEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'N' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("edit notes"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item {
+		warnLog.logf("(ignored) no item selected")
+		return true
+	}
+	record, ok := l.browseView.record[item.Media]
+	if !ok {
+		warnLog.logf("(ignored) database record unknown: %s", item.AbsName)
+		return true
+	}
+	l.notesEditor.setMedia(item, record)
+	l.focusQueue <- l.notesEditor
+	return true
+}
@@ -0,0 +1,268 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: option.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the kinds of values held by command line options, how each kind
+//    is bound to the command line parser and to the environment, and how they
+//    are validated once parsed.
+//
+// =============================================================================
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// type OptionKind is an enum identifying which of the Option struct's value
+// fields holds the option's value, and how it is parsed.
+type OptionKind int
+
+const (
+	okUnknown    OptionKind = iota - 1 // = -1
+	okBool                             // =  0 (bool)
+	okInt                              // =  1 (int)
+	okUint                             // =  2 (uint)
+	okUint64                           // =  3 (uint64)
+	okFloat64                          // =  4 (float64)
+	okString                           // =  5 (string)
+	okDuration                         // =  6 (time.Duration)
+	okSize                             // =  7 (uint64, number of bytes with optional units)
+	okEnum                             // =  8 (string, one of Option.choice)
+	okStringList                       // =  9 (StringList)
+	okCOUNT                            // = 10
+)
+
+// local unexported constants for binding options to the environment.
+const (
+	// options may also be provided via environment variables named with this
+	// prefix followed by the option name in upper case, e.g. PIMMP_VERBOSE.
+	optionEnvPrefix = "PIMMP_"
+
+	// separates the elements of list options provided via the environment.
+	optionEnvListSep = ";"
+)
+
+var (
+	// variable sizeUnit maps the recognized (case-insensitive) suffixes of
+	// size values to their multipliers. all units are binary (powers of 1024).
+	sizeUnit = map[string]uint64{
+		"": 1, "b": 1,
+		"k": kibiBytes, "kb": kibiBytes, "kib": kibiBytes,
+		"m": mebiBytes, "mb": mebiBytes, "mib": mebiBytes,
+		"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+		"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+	}
+)
+
+// function parseSize() parses a number of bytes with an optional unit suffix,
+// such as "4096", "256k", or "64MiB".
+func parseSize(s string) (uint64, error) {
+
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && '.' != r })
+	if i < 0 {
+		i = len(s)
+	}
+	mult, ok := sizeUnit[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, s[i:])
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if nil != err {
+		return 0, fmt.Errorf("invalid size %q: %s", s, err)
+	}
+	size := n * float64(mult)
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return uint64(size), nil
+}
+
+// function formatSize() formats a number of bytes using the largest unit that
+// represents it exactly, such as "64MiB" (or "4097" if there is none).
+func formatSize(size uint64) string {
+	for _, u := range []struct {
+		name string
+		mult uint64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", mebiBytes}, {"KiB", kibiBytes}} {
+		if size >= u.mult && 0 == size%u.mult {
+			return fmt.Sprintf("%d%s", size/u.mult, u.name)
+		}
+	}
+	return strconv.FormatUint(size, 10)
+}
+
+// function formatSizeApprox() formats a number of bytes using the largest unit
+// not exceeding it, rounded to one decimal place, such as "1.5GiB".
+func formatSizeApprox(size uint64) string {
+	for _, u := range []struct {
+		name string
+		mult uint64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", mebiBytes}, {"KiB", kibiBytes}} {
+		if size >= u.mult {
+			return fmt.Sprintf("%.1f%s", float64(size)/float64(u.mult), u.name)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// type sizeValue is the flag.Value of an okSize option.
+type sizeValue struct{ *Option }
+
+func (v sizeValue) String() string {
+	if nil == v.Option || 0 == v.uint64 {
+		return ""
+	}
+	return formatSize(v.uint64)
+}
+func (v sizeValue) Set(s string) error {
+	size, err := parseSize(s)
+	if nil == err {
+		v.uint64 = size
+	}
+	return err
+}
+
+// type enumValue is the flag.Value of an okEnum option.
+type enumValue struct{ *Option }
+
+func (v enumValue) String() string {
+	if nil == v.Option {
+		return ""
+	}
+	return v.string
+}
+func (v enumValue) Set(s string) error {
+	for _, c := range v.choice {
+		if strings.EqualFold(c, s) {
+			v.string = c
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q (expected one of: %s)", s, strings.Join(v.choice, ", "))
+}
+
+// function bind() registers the option with the given command line parser,
+// according to its kind.
+func (o *Option) bind(fs *flag.FlagSet) {
+	switch o.kind {
+	case okBool:
+		fs.BoolVar(&o.bool, o.name, o.bool, o.usage)
+	case okInt:
+		fs.IntVar(&o.int, o.name, o.int, o.usage)
+	case okUint:
+		fs.UintVar(&o.uint, o.name, o.uint, o.usage)
+	case okUint64:
+		fs.Uint64Var(&o.uint64, o.name, o.uint64, o.usage)
+	case okFloat64:
+		fs.Float64Var(&o.float64, o.name, o.float64, o.usage)
+	case okString:
+		fs.StringVar(&o.string, o.name, o.string, o.usage)
+	case okDuration:
+		fs.DurationVar(&o.Duration, o.name, o.Duration, o.usage)
+	case okSize:
+		fs.Var(sizeValue{o}, o.name, o.usage)
+	case okEnum:
+		fs.Var(enumValue{o}, o.name, o.usage)
+	case okStringList:
+		fs.Var(&o.StringList, o.name, o.usage)
+	}
+}
+
+// function envName() returns the name of the environment variable which may
+// provide the option's value.
+func (o *Option) envName() string {
+	return optionEnvPrefix + strings.ToUpper(strings.Replace(o.name, "-", "_", -1))
+}
+
+// function bindEnv() sets each of the given options whose environment variable
+// is defined, using the given command line parser so that values are parsed
+// identically. this must be called before parsing the command line, so that
+// command line arguments take precedence. returns the options that were set.
+func bindEnv(fs *flag.FlagSet, known NamedOption) (NamedOption, error) {
+
+	provided := NamedOption{}
+	for name, o := range known {
+		env, ok := os.LookupEnv(o.envName())
+		if !ok {
+			continue
+		}
+		value := []string{env}
+		if okStringList == o.kind {
+			value = strings.Split(env, optionEnvListSep)
+		}
+		for _, v := range value {
+			if err := fs.Set(name, v); nil != err {
+				return nil, fmt.Errorf("environment variable %s: %s", o.envName(), err)
+			}
+		}
+		provided[name] = o
+	}
+	return provided, nil
+}
+
+// function validateOptions() calls the validation hook of each of the given
+// options having one, in order of option name. returns the first error.
+func validateOptions(known NamedOption) error {
+	if err := optionErrors(known); len(err) > 0 {
+		return err[0]
+	}
+	return nil
+}
+
+// function optionErrors() calls the validation hook of each of the given
+// options having one, in order of option name. returns every error.
+func optionErrors(known NamedOption) []error {
+
+	name := make([]string, 0, len(known))
+	for n := range known {
+		name = append(name, n)
+	}
+	sort.Strings(name)
+
+	failed := []error{}
+	for _, n := range name {
+		if o := known[n]; nil != o.validate {
+			if err := o.validate(o); nil != err {
+				failed = append(failed, err)
+			}
+		}
+	}
+	return failed
+}
+
+// function validateEach() creates a validation hook for okStringList options
+// which verifies each of the option's elements with the given parse function.
+func validateEach(parse func(string) error) func(*Option) error {
+	return func(o *Option) error {
+		for _, spec := range o.StringList {
+			if err := parse(spec); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// function validateMinSize() creates a validation hook for okSize options
+// which verifies the option's size is at least the given number of bytes.
+func validateMinSize(min uint64) func(*Option) error {
+	return func(o *Option) error {
+		if o.uint64 < min {
+			return fmt.Errorf("-%s: size %s is too small (minimum %s)",
+				o.name, formatSize(o.uint64), formatSize(min))
+		}
+		return nil
+	}
+}
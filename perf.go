@@ -0,0 +1,131 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: perf.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the performance tuning knobs: the number of OS threads executing
+//    goroutines, and the number of libraries whose file systems are scanned
+//    concurrently. defaults are derived from the number of CPUs. libraries
+//    waiting to be scanned are admitted in turn, except the library visible
+//    in the UI, which is scanned first so that what the user is looking at
+//    fills in first.
+//
+// =============================================================================
+
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+var (
+	// variable defaultNumCPU is the number of logical CPUs usable by this
+	// process, from which all performance-related defaults are derived.
+	defaultNumCPU = runtime.NumCPU()
+
+	// variable defaultScanWorkers is the default max number of libraries
+	// scanned concurrently. scanning is mostly bound by disk I/O rather than
+	// CPU, so more workers than CPUs rarely helps.
+	defaultScanWorkers = uint(defaultNumCPU)
+
+	// variable defaultDirWorkers is the default max number of directories of
+	// each library scanned concurrently (see scanpool.go). unlike libraries
+	// sharing a disk, the directories of a library on fast storage are
+	// scanned several times faster in parallel.
+	defaultDirWorkers = uint(defaultNumCPU)
+
+	// variable scanWorkers limits the number of libraries scanned concurrently.
+	// it is configured once the command line options have been parsed (see
+	// function initOptions()).
+	scanWorkers = newWorkerPool(defaultScanWorkers)
+
+	// variable hashWorkers limits the number of files hashed concurrently
+	// (e.g. when verifying checksum files). like scanning, hashing is mostly
+	// bound by disk I/O, so it is sized like the scan workers.
+	hashWorkers = newWorkerPool(defaultScanWorkers)
+)
+
+// type WorkerPool is a counting semaphore limiting the number of goroutines
+// concurrently performing some kind of work on behalf of some owner (e.g. a
+// Library). workers of the preferred owner are admitted before all others.
+type WorkerPool struct {
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	size      uint
+	busy      uint
+	waiting   map[interface{}]uint // number of workers waiting, by owner
+	preferred interface{}
+}
+
+// function newWorkerPool() creates a new WorkerPool admitting at most n
+// concurrent workers (at least 1).
+func newWorkerPool(n uint) *WorkerPool {
+	if n < 1 {
+		n = 1
+	}
+	p := &WorkerPool{
+		size:      n,
+		busy:      0,
+		waiting:   map[interface{}]uint{},
+		preferred: nil,
+	}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+// function acquire() blocks until a worker slot is available and no worker of
+// the preferred owner is waiting for one (unless the given owner is preferred),
+// then occupies it.
+func (p *WorkerPool) acquire(owner interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.waiting[owner]++
+	for p.busy >= p.size || (owner != p.preferred && p.waiting[p.preferred] > 0) {
+		p.cond.Wait()
+	}
+	if p.waiting[owner]--; 0 == p.waiting[owner] {
+		delete(p.waiting, owner)
+	}
+	p.busy++
+}
+
+// function release() frees a worker slot occupied by function acquire().
+func (p *WorkerPool) release() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.busy--
+	p.cond.Broadcast()
+}
+
+// function prefer() admits the workers of the given owner (or none, if nil)
+// before all others waiting for a worker slot.
+func (p *WorkerPool) prefer(owner interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.preferred = owner
+	p.cond.Broadcast()
+}
+
+// function preferLibrary() scans the given library (if not nil), the one visible
+// in the UI, before any other library waiting to be scanned.
+func preferLibrary(lib *Library) {
+	scanWorkers.prefer(lib)
+}
+
+// function setPerformance() applies the performance tuning options. it must be
+// called before any libraries are loaded or scanned.
+func setPerformance(opt *Options) {
+
+	if opt.MaxProcs.int > 0 {
+		runtime.GOMAXPROCS(opt.MaxProcs.int)
+	}
+	scanWorkers = newWorkerPool(opt.ScanWorkers.uint)
+	hashWorkers = newWorkerPool(opt.ScanWorkers.uint)
+
+	infoLog.tracef("performance: %d CPUs, %d threads, %d scan workers",
+		defaultNumCPU, runtime.GOMAXPROCS(0), scanWorkers.size)
+}
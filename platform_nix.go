@@ -23,6 +23,8 @@ package main
 
 import (
 	"os"
+	"os/exec"
+	"syscall"
 )
 
 const (
@@ -31,8 +33,61 @@ const (
 	currDir = "."
 )
 
+var (
+	// variable transientErrno lists the error numbers of file system
+	// operations which are worth retrying (see function isTransientError()).
+	transientErrno = []syscall.Errno{
+		syscall.EAGAIN, syscall.EBUSY, syscall.EINTR, syscall.EIO,
+		syscall.ETIMEDOUT, syscall.ESTALE, syscall.ECONNRESET,
+		syscall.ECONNABORTED, syscall.EHOSTDOWN, syscall.EHOSTUNREACH,
+		syscall.ENETDOWN, syscall.ENETUNREACH, syscall.ENETRESET,
+	}
+
+	// variable reloadSignal lists the signals requesting the config file be
+	// reloaded.
+	reloadSignal = []os.Signal{syscall.SIGHUP}
+)
+
 // function homeDir() returns the path to the user's home directory as defined
 // by the user's current HOME environment variable.
 func homeDir() string {
 	return os.Getenv("HOME")
 }
+
+// function ramDir() returns a directory on a RAM-backed file system if one is
+// available (Linux), otherwise the default directory for temporary files.
+func ramDir() string {
+	if info, err := os.Stat("/dev/shm"); nil == err && info.IsDir() {
+		return "/dev/shm"
+	}
+	return os.TempDir()
+}
+
+// function diskFree() returns the number of bytes available to unprivileged
+// users on the file system containing the given path.
+func diskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); nil != err {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// function processExists() returns true if a process with the given ID is
+// running, regardless of whether or not it may be signalled by this process.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return nil == err || syscall.EPERM == err
+}
+
+// function shellQuote() quotes the given argument for the system shell (see
+// function shellCommand()), so that it is passed to the command as-is.
+func shellQuote(arg string) string {
+	return posixQuote(arg)
+}
+
+// function shellCommand() returns a command running the given command line in
+// the system shell.
+func shellCommand(line string) *exec.Cmd {
+	return exec.Command("/bin/sh", "-c", line)
+}
@@ -23,6 +23,10 @@ package main
 
 import (
 	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
 )
 
 const (
@@ -31,6 +35,25 @@ const (
 	currDir = "."
 )
 
+var (
+	// variable transientErrno lists the error numbers of file system
+	// operations which are worth retrying (see function isTransientError()).
+	transientErrno = []syscall.Errno{
+		32,   // ERROR_SHARING_VIOLATION
+		33,   // ERROR_LOCK_VIOLATION
+		51,   // ERROR_REM_NOT_LIST
+		59,   // ERROR_UNEXP_NET_ERR
+		64,   // ERROR_NETNAME_DELETED
+		121,  // ERROR_SEM_TIMEOUT
+		1231, // ERROR_NETWORK_UNREACHABLE
+	}
+
+	// variable reloadSignal lists the signals requesting the config file be
+	// reloaded. there are none on windows, where it is reloaded only from the
+	// user interface.
+	reloadSignal = []os.Signal{}
+)
+
 // function homeDir() returns the path to the user's home directory as defined
 // by several of the user's current environment variables.
 func homeDir() string {
@@ -40,3 +63,56 @@ func homeDir() string {
 	}
 	return home
 }
+
+// function ramDir() returns the default directory for temporary files, since
+// Windows has no RAM-backed file system available by default.
+func ramDir() string {
+	return os.TempDir()
+}
+
+// function diskFree() returns the number of bytes available to the calling
+// user on the volume containing the given path.
+func diskFree(path string) (uint64, error) {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getDiskFreeSpaceEx := kernel32.NewProc("GetDiskFreeSpaceExW")
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if nil != err {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	ret, _, err := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&free)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)))
+	if 0 == ret {
+		return 0, err
+	}
+	return free, nil
+}
+
+// function processExists() returns true if a process with the given ID is
+// running. on Windows, os.FindProcess() fails if there is no such process.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if nil != err {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// function shellQuote() quotes the given argument for the system shell (see
+// function shellCommand()), so that it is passed to the command as-is. file
+// names cannot contain double quotes, but cmd expands environment variables
+// (e.g. %PATH%) even inside them, so each percent sign is escaped outside of
+// the quotes.
+func shellQuote(arg string) string {
+	return `"` + strings.Replace(arg, "%", `"^%"`, -1) + `"`
+}
+
+// function shellCommand() returns a command running the given command line in
+// the system shell.
+func shellCommand(line string) *exec.Cmd {
+	return exec.Command("cmd", "/C", line)
+}
@@ -0,0 +1,242 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: playback.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the playback of media selected (with Enter) in the media browser
+//    by an external media player, e.g. mpv or vlc. the player runs beside the
+//    user interface in its own window, so it must not draw in our terminal;
+//    its error output is kept and logged if it fails. only one player is
+//    tracked at a time: selecting other media stops the current player.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// local unexported constants for the external media player.
+const (
+	playerDefault = "mpv" // player used by media without their own playback command
+	playerTail    = 3     // number of lines of error output logged when the player fails
+)
+
+var (
+	// variable mediaPlayer is the command of the external media player, or
+	// empty if media are only played by their own playback command.
+	mediaPlayer = playerDefault
+
+	// variable currPlayback is the player currently running, or nil if none.
+	currPlayback  *Playback
+	playbackMutex sync.Mutex
+)
+
+// function setPlayer() selects the external media player given by the options.
+func setPlayer(opt *Options) {
+	liveMutex.Lock()
+	mediaPlayer = strings.TrimSpace(opt.Player.string)
+	liveMutex.Unlock()
+}
+
+// function mediaPlayerCommand() returns the command of the external media player, or an
+// empty string if there is none.
+func mediaPlayerCommand() string {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	return mediaPlayer
+}
+
+// type Playback is an external media player launched to play some media.
+type Playback struct {
+	name    string        // name of the media being played
+	cmd     *exec.Cmd     // the running player
+	start   time.Time     // time at which the player was launched
+	output  *PlayerOutput // the last lines of the player's error output
+	stopped bool          // whether or not the player was stopped by us
+	done    func()        // called if the player finished playing (may be nil)
+}
+
+// type PlayerOutput is an io.Writer keeping only the last few lines written.
+type PlayerOutput struct {
+	line []string
+	part string
+}
+
+// function Write() appends the given data, discarding all but the last few
+// complete lines. status lines redrawn with carriage returns are discarded.
+func (o *PlayerOutput) Write(p []byte) (int, error) {
+	text := o.part + string(p)
+	for {
+		i := strings.IndexAny(text, "\r\n")
+		if i < 0 {
+			break
+		}
+		if '\n' == text[i] {
+			if line := strings.TrimSpace(text[:i]); "" != line {
+				o.line = append(o.line, line)
+				if len(o.line) > playerTail {
+					o.line = o.line[len(o.line)-playerTail:]
+				}
+			}
+		}
+		text = text[i+1:]
+	}
+	o.part = text
+	return len(p), nil
+}
+
+// function mediaCommand() returns the command playing the given item, with its
+// selected tracks and series markers, or an empty string if no command or
+// player is configured.
+func (l *Layout) mediaCommand(item *mediaItem) string {
+	if record, known := l.browseView.record[item.Media]; known {
+		if video, isVideo := record.rec.(*VideoMedia); isVideo {
+			return item.SourceLibrary.playbackCommand(video)
+		}
+	}
+	return item.baseCommand()
+}
+
+// function play() launches the external media player playing the given item,
+// stopping the player currently running (if any). the player is waited on in
+// a separate goroutine, which logs its exit status.
+func (l *Layout) play(item *mediaItem) {
+	l.playCommand(item, l.mediaCommand(item))
+}
+
+// function resume() launches the external media player playing the given
+// audio from the position of its bookmark with the given name.
+func (l *Layout) resume(item *mediaItem, audio *AudioMedia, name string) {
+
+	cmd, ret := audio.resumeCommand(name)
+	if nil != ret {
+		warnLog.log(ret)
+		return
+	}
+	l.playCommand(item, cmd)
+}
+
+// function playCommand() launches the external media player with the given
+// command playing the given item, extracting it first if it is archived.
+func (l *Layout) playCommand(item *mediaItem, cmd string) {
+
+	if "" == cmd {
+		warnLog.logf("(ignored) no playback command is configured: %s (see option -%s)",
+			item.Name, l.option.Player.name)
+		return
+	}
+	if _, _, archived := splitArchivePath(item.AbsPath); archived {
+		// extracting from the archive may take a while, so the player is
+		// launched without holding up the UI.
+		go func() {
+			if ret := extractMedia(item.AbsPath); nil != ret {
+				errLog.log(ret)
+				return
+			}
+			launchPlayer(item.Name, cmd, func() { l.advanceQueue(item) })
+		}()
+		return
+	}
+	launchPlayer(item.Name, cmd, func() { l.advanceQueue(item) })
+}
+
+// function launchPlayer() launches the external media player with the given
+// command, stopping the player currently running (if any). the given function
+// is called once the player finished playing, unless it failed or was stopped.
+func launchPlayer(name string, cmd string, done func()) {
+
+	stopPlayback()
+
+	p := &Playback{
+		name:    name,
+		cmd:     playerCommand(cmd),
+		start:   time.Now(),
+		output:  &PlayerOutput{},
+		stopped: false,
+		done:    done,
+	}
+	// the player must not read from or draw in the terminal of the UI.
+	p.cmd.Stdin, p.cmd.Stdout, p.cmd.Stderr = nil, nil, p.output
+	if err := p.cmd.Start(); nil != err {
+		errLog.logf("playback failed: %s: %s", name, err)
+		return
+	}
+	infoLog.logf("playing %s on %s", name, currentTarget().desc())
+	infoLog.verbosef("playback command: %s", cmd)
+
+	playbackMutex.Lock()
+	currPlayback = p
+	playbackMutex.Unlock()
+
+	go p.wait()
+}
+
+// function wait() waits for the player to exit and logs its exit status, along
+// with its last lines of error output if it failed.
+func (p *Playback) wait() {
+
+	err := p.cmd.Wait()
+
+	playbackMutex.Lock()
+	stopped := p.stopped
+	if p == currPlayback {
+		currPlayback = nil
+	}
+	playbackMutex.Unlock()
+
+	elapsed := time.Since(p.start).Round(time.Second)
+	switch {
+	case stopped:
+		infoLog.logf("stopped playing %s (after %s)", p.name, elapsed)
+	case nil == err:
+		infoLog.logf("finished playing %s (after %s)", p.name, elapsed)
+		if nil != p.done {
+			p.done()
+		}
+	default:
+		errLog.logf("playback failed: %s: %s", p.name, err)
+		for _, line := range p.output.line {
+			errLog.logf("  %s", line)
+		}
+	}
+}
+
+// function stopPlayback() kills the player currently running, if any.
+func stopPlayback() {
+
+	playbackMutex.Lock()
+	defer playbackMutex.Unlock()
+	if nil == currPlayback {
+		return
+	}
+	currPlayback.stopped = true
+	if err := currPlayback.cmd.Process.Kill(); nil != err {
+		warnLog.tracef("stopPlayback(): Kill(): %s", err)
+	}
+	currPlayback = nil
+}
+
+// function baseCommand() returns the command playing this media: its own
+// playback command if one is configured, or else the external media player
+// followed by the media's path (of its extracted copy, if it is inside an
+// archive). returns an empty string if neither exists.
+func (m *Media) baseCommand() string {
+	// the placeholder "--" means no command was configured.
+	if "" != strings.Trim(m.PlaybackCommand, "- ") {
+		return m.PlaybackCommand
+	}
+	cmd := mediaPlayerCommand()
+	if "" == cmd {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", cmd, quoteArg(playablePath(m.AbsPath)))
+}
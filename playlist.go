@@ -0,0 +1,253 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: playlist.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the readers of the libraries exported by music players for the
+//    "import" command (see import.go), which carry the playlists and ratings
+//    of each track:
+//
+//      itunes:    the iTunes (or Music) "Library.xml" property list.
+//      rekordbox: the XML collection exported by Rekordbox.
+//
+//    each playlist containing a track is imported as tag "playlist:NAME", and
+//    its rating as tag "rating:N" with N stars from 1 to 5. built-in iTunes
+//    playlists (e.g. "Music") are not imported, and nested playlists are
+//    named by their folders, e.g. "playlist:Sets/Friday".
+//
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// type RekordboxExport is the XML collection exported by Rekordbox.
+type RekordboxExport struct {
+	Track []struct {
+		ID        string `xml:"TrackID,attr"`
+		Name      string `xml:"Name,attr"`
+		Album     string `xml:"Album,attr"`
+		Location  string `xml:"Location,attr"`
+		Rating    int    `xml:"Rating,attr"` // 0 to 255, in steps of 51 per star
+		PlayCount int    `xml:"PlayCount,attr"`
+	} `xml:"COLLECTION>TRACK"`
+	Node []RekordboxNode `xml:"PLAYLISTS>NODE"`
+}
+
+// type RekordboxNode is a folder (Type 0) or playlist (Type 1) in a Rekordbox
+// export. the tracks of a playlist are identified by their TrackID (KeyType 0)
+// or their Location (KeyType 1).
+type RekordboxNode struct {
+	Name    string          `xml:"Name,attr"`
+	Type    int             `xml:"Type,attr"`
+	KeyType int             `xml:"KeyType,attr"`
+	Node    []RekordboxNode `xml:"NODE"`
+	Track   []struct {
+		Key string `xml:"Key,attr"`
+	} `xml:"TRACK"`
+}
+
+// function fileURLPath() returns the local file path of the given file URL, as
+// used by music players to locate tracks. any other string is returned as is.
+func fileURLPath(loc string) string {
+	u, err := url.Parse(loc)
+	if nil != err || "file" != u.Scheme {
+		return loc
+	}
+	p := u.Path
+	if len(p) > 2 && '/' == p[0] && ':' == p[2] {
+		p = p[1:] // e.g. "/C:/Music" on Windows
+	}
+	return filepath.FromSlash(p)
+}
+
+// function plistValue() decodes the property list value starting with the
+// given element: a dict (map[string]interface{}), an array ([]interface{}),
+// an integer (int64), a boolean, or any other value as its text.
+func plistValue(d *xml.Decoder, start xml.StartElement) (interface{}, error) {
+
+	switch start.Name.Local {
+	case "dict", "array":
+		dict, array, key := map[string]interface{}{}, []interface{}{}, ""
+		for {
+			tok, err := d.Token()
+			if nil != err {
+				return nil, err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if "key" == t.Name.Local {
+					if err := d.DecodeElement(&key, &t); nil != err {
+						return nil, err
+					}
+					continue
+				}
+				v, err := plistValue(d, t)
+				if nil != err {
+					return nil, err
+				}
+				if "dict" == start.Name.Local {
+					dict[key] = v
+				} else {
+					array = append(array, v)
+				}
+			case xml.EndElement:
+				if "dict" == start.Name.Local {
+					return dict, nil
+				}
+				return array, nil
+			}
+		}
+	case "true", "false":
+		return "true" == start.Name.Local, d.Skip()
+	}
+	var text string
+	if err := d.DecodeElement(&text, &start); nil != err {
+		return nil, err
+	}
+	if "integer" == start.Name.Local {
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	}
+	return text, nil
+}
+
+// function parsePlist() decodes the top-level value of the given XML property
+// list (see function plistValue()).
+func parsePlist(data []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if nil != err {
+			return nil, err
+		}
+		if t, ok := tok.(xml.StartElement); ok && "plist" != t.Name.Local {
+			return plistValue(d, t)
+		}
+	}
+}
+
+// function readITunesLibrary() reads the tracks of an iTunes library, along
+// with their ratings and the playlists containing them.
+func readITunesLibrary(data []byte) ([]*ImportItem, error) {
+
+	plist, err := parsePlist(data)
+	if nil != err {
+		return nil, err
+	}
+	root, _ := plist.(map[string]interface{})
+	tracks, _ := root["Tracks"].(map[string]interface{})
+
+	byID := map[int64]*ImportItem{}
+	item := []*ImportItem{}
+	for _, v := range tracks {
+		track, _ := v.(map[string]interface{})
+		id, _ := track["Track ID"].(int64)
+		loc, _ := track["Location"].(string)
+		if "" == loc {
+			continue // e.g. a stream
+		}
+		name, _ := track["Name"].(string)
+		album, _ := track["Album"].(string)
+		rating, _ := track["Rating"].(int64) // 0 to 100, in steps of 20 per star
+		plays, _ := track["Play Count"].(int64)
+		i := &ImportItem{
+			path:     fileURLPath(loc),
+			title:    name,
+			album:    album,
+			watched:  plays > 0,
+			rating:   int(rating / 20),
+			playlist: []string{},
+			artwork:  map[ArtworkKind]string{},
+		}
+		byID[id] = i
+		item = append(item, i)
+	}
+
+	playlists, _ := root["Playlists"].([]interface{})
+	for _, v := range playlists {
+		list, _ := v.(map[string]interface{})
+		name, _ := list["Name"].(string)
+		// skip the library itself and the built-in playlists.
+		_, master := list["Master"]
+		_, builtin := list["Distinguished Kind"]
+		if master || builtin || "" == name {
+			continue
+		}
+		entries, _ := list["Playlist Items"].([]interface{})
+		for _, e := range entries {
+			entry, _ := e.(map[string]interface{})
+			id, _ := entry["Track ID"].(int64)
+			if i, ok := byID[id]; ok {
+				i.playlist = append(i.playlist, name)
+			}
+		}
+	}
+	return item, nil
+}
+
+// function addRekordboxPlaylists() adds the given playlists (and those in the
+// given folders) to the tracks they contain.
+func addRekordboxPlaylists(node []RekordboxNode, folder string, byID, byLoc map[string]*ImportItem) {
+	for _, n := range node {
+		name := n.Name
+		if "" != folder {
+			name = folder + "/" + n.Name
+		}
+		if 0 == n.Type {
+			if "ROOT" == n.Name && "" == folder {
+				name = "" // the root folder is not part of the names
+			}
+			addRekordboxPlaylists(n.Node, name, byID, byLoc)
+			continue
+		}
+		for _, t := range n.Track {
+			i, ok := byID[t.Key]
+			if 1 == n.KeyType {
+				i, ok = byLoc[t.Key]
+			}
+			if ok {
+				i.playlist = append(i.playlist, name)
+			}
+		}
+	}
+}
+
+// function readRekordboxExport() reads the tracks of a Rekordbox collection,
+// along with their ratings and the playlists containing them.
+func readRekordboxExport(data []byte) ([]*ImportItem, error) {
+
+	var export RekordboxExport
+	if err := xml.Unmarshal(data, &export); nil != err {
+		return nil, err
+	}
+	byID, byLoc := map[string]*ImportItem{}, map[string]*ImportItem{}
+	item := []*ImportItem{}
+	for _, t := range export.Track {
+		if "" == t.Location {
+			continue
+		}
+		i := &ImportItem{
+			path:     fileURLPath(t.Location),
+			title:    t.Name,
+			album:    t.Album,
+			watched:  t.PlayCount > 0,
+			rating:   t.Rating / 51,
+			playlist: []string{},
+			artwork:  map[ArtworkKind]string{},
+		}
+		byID[t.ID], byLoc[t.Location] = i, i
+		item = append(item, i)
+	}
+	addRekordboxPlaylists(export.Node, "", byID, byLoc)
+	return item, nil
+}
@@ -0,0 +1,202 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: poll.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the polling watcher, which periodically compares the modification
+//    times of every directory in a library and rescans the library when any of
+//    them changed. this keeps libraries up to date on file systems that don't
+//    deliver change notifications reliably (e.g. NFS, SMB network shares).
+//
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// local unexported constants for the polling watcher.
+const (
+	// shortest poll interval accepted, since each poll reads every directory
+	// in the library (which may be slow on a network share).
+	minPollFreq = 10 * time.Second
+)
+
+// type PollRule is a parsed polling declaration of the form
+// "INTERVAL[@LIBRARY]".
+type PollRule struct {
+	freq  time.Duration // interval between polls
+	scope *LibraryScope // only library affected (empty = all)
+}
+
+// function parsePollRule() parses a polling declaration of the form
+// "INTERVAL[@LIBRARY]", where INTERVAL is a duration such as "30s" or "5m".
+func parsePollRule(spec string) (*PollRule, error) {
+
+	decl, scope := parseLibraryScope(spec)
+	rule := &PollRule{scope: scope}
+
+	freq, err := time.ParseDuration(strings.TrimSpace(decl))
+	if nil != err {
+		return nil, fmt.Errorf("poll %q: invalid interval: %s", spec, err)
+	}
+	if freq < minPollFreq {
+		return nil, fmt.Errorf("poll %q: interval must be at least %s", spec, minPollFreq)
+	}
+	rule.freq = freq
+
+	return rule, nil
+}
+
+// function appliesTo() returns true if this rule was declared for the given
+// library, either by name or by path, or if it was declared for all libraries.
+func (r *PollRule) appliesTo(lib *Library) bool {
+	return r.scope.appliesTo(lib)
+}
+
+// function pollFreqOf() returns the poll interval of this library given by the
+// poll rules of the given options (0 = never). the last rule applying to the
+// library wins.
+func (l *Library) pollFreqOf(opt *Options) time.Duration {
+
+	freq := time.Duration(0)
+	for _, spec := range opt.PollFreq.StringList {
+		if rule, err := parsePollRule(spec); nil == err && rule.appliesTo(l) {
+			freq = rule.freq
+		}
+	}
+	return freq
+}
+
+// function pollState() reads the modification time of every directory in the
+// library, following the same traversal rules as function scanDive(). the
+// returned map is keyed by directory path.
+func (l *Library) pollState() map[string]time.Time {
+
+	state := map[string]time.Time{}
+
+	var dive func(dir string, depth uint)
+	dive = func(dir string, depth uint) {
+		if dir == l.db.absPath {
+			return
+		}
+		if depthUnlimited != l.maxDepth && depth > l.maxDepth {
+			return
+		}
+		fds, err := os.Open(dir)
+		if nil != err {
+			return
+		}
+		info, err := fds.Stat()
+		if nil != err {
+			fds.Close()
+			return
+		}
+		state[dir] = info.ModTime()
+		child, _ := fds.Readdir(0)
+		fds.Close()
+		for _, c := range child {
+			// Readdir() uses lstat, so symlinked directories are not followed.
+			if c.IsDir() {
+				dive(path.Join(dir, c.Name()), depth+1)
+			}
+		}
+	}
+	dive(l.absPath, 1)
+
+	return state
+}
+
+// function pollChanged() returns true if the two given poll states differ in
+// any way: a directory was added, removed, or modified.
+func pollChanged(prev, curr map[string]time.Time) bool {
+	if len(prev) != len(curr) {
+		return true
+	}
+	for dir, mod := range curr {
+		if p, ok := prev[dir]; !ok || !p.Equal(mod) {
+			return true
+		}
+	}
+	return false
+}
+
+// function poll() polls the library's file system indefinitely at the
+// library's poll interval, rescanning the library using the given handler each
+// time a change is detected, until the given context is cancelled. while the
+// library's poll interval is 0, its file system is not polled until a new
+// interval is given by reloading the config file.
+func (l *Library) poll(ctx context.Context, handler *PathHandler) {
+
+	// the interval is changed by reloading the config file only while polling,
+	// and then given through l.pollReset, so read it beforehand.
+	freq := l.pollFreq
+	atomic.StoreUint32(&l.polling, 1)
+	defer atomic.StoreUint32(&l.polling, 0)
+
+	var tick *time.Ticker // nil while not polling
+	var prev map[string]time.Time
+	reset := func(freq time.Duration) {
+		if nil != tick {
+			tick.Stop()
+			tick = nil
+		}
+		if 0 == freq {
+			return
+		}
+		infoLog.verbosef("polling library for changes every %s: %q", freq, l.name)
+		tick = time.NewTicker(freq)
+		prev = l.pollState()
+	}
+	reset(freq)
+	defer reset(0)
+
+	for {
+		var tickC <-chan time.Time
+		if nil != tick {
+			tickC = tick.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case freq := <-l.pollReset:
+			if 0 == freq && nil != tick {
+				infoLog.verbosef("stopped polling library for changes: %q", l.name)
+			}
+			reset(freq)
+			continue
+		case <-tickC:
+		}
+		// don't mistake an unmounted share for a library that was emptied.
+		if err := l.revalidate(); nil != err {
+			continue
+		}
+		curr := l.pollState()
+		if !pollChanged(prev, curr) {
+			continue
+		}
+		prev = curr
+		infoLog.verbosef("changes detected by polling, rescanning: %q", l.name)
+		if _, ret := l.scan(ctx, handler); nil != ret {
+			if ret.is(rcInterrupted) {
+				continue
+			}
+			if l.isScanning() {
+				// a scan is already running, have it start over once finished.
+				l.requestRescan()
+			} else {
+				warnLog.verbose(ret)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: portable.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines portable libraries, whose database is stored at the library root
+//    so that it travels with the library (e.g. on an external drive). every
+//    record already stores its path relative to the library root, so when the
+//    library is opened from a different mount point than the one its records
+//    were anchored at, the absolute paths are rebuilt from the relative ones.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// type DatabaseAnchor records the library root at which the absolute paths of
+// a portable library's records were last anchored.
+type DatabaseAnchor struct {
+	Root string    // absolute path to the library root
+	Time time.Time // time at which the records were anchored at Root
+}
+
+// function reanchorRecord() rebuilds the absolute paths of every entity found
+// in the given record value (including entities nested in other entities, e.g.
+// a video's subtitles) from their library-relative paths and the given root.
+// returns true if any path was changed.
+func reanchorRecord(v interface{}, root string) bool {
+
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		rel, isRel := t["RelPath"].(string)
+		abs, isAbs := t["AbsPath"].(string)
+		if isRel && isAbs && "" != rel {
+			if p := path.Join(root, filepath.ToSlash(rel)); p != abs {
+				t["AbsPath"] = p
+				t["AbsDir"] = path.Dir(p)
+				changed = true
+			}
+		}
+		for _, e := range t {
+			if reanchorRecord(e, root) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			if reanchorRecord(e, root) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// function reanchorCol() rebuilds the absolute paths of every record in the
+// given collection from the given library root. returns the number of records
+// changed.
+func reanchorCol(col Collection, name string, root string) (uint, *ReturnCode) {
+
+	// tiedot holds the collection's lock while iterating, so the records are
+	// first collected and then updated once the iteration has finished.
+	update := map[int]map[string]interface{}{}
+	col.ForEachDoc(
+		func(id int, data []byte) (willMoveOn bool) {
+			rec := map[string]interface{}{}
+			if err := json.Unmarshal(data, &rec); nil != err {
+				warnLog.tracef("reanchor(%q): skipping corrupt record %d: %s", name, id, err)
+				return true
+			}
+			if reanchorRecord(rec, root) {
+				update[id] = rec
+			}
+			return true
+		})
+
+	for id, rec := range update {
+		if err := col.Update(id, rec); nil != err {
+			return 0, rcDatabaseError.specf(
+				"reanchor(%q): Update(%d): %s", name, id, err)
+		}
+	}
+	return uint(len(update)), nil
+}
+
+// function reanchor() rebuilds the absolute paths of every record in all of
+// the database's entity collections from the given library root. returns the
+// number of records changed.
+func (d *Database) reanchor(root string) (uint, *ReturnCode) {
+
+	total := uint(0)
+	for class := range d.col {
+		for kind, col := range d.col[class] {
+			count, ret := reanchorCol(col, d.colName[class][kind], root)
+			if nil != ret {
+				return total, ret
+			}
+			total += count
+		}
+	}
+	return total, nil
+}
+
+// function anchor() verifies the records of a portable library's database are
+// anchored at the library's current root, re-anchoring them if the library was
+// opened from somewhere else (e.g. a different mount point) since last time.
+func (d *Database) anchor() *ReturnCode {
+
+	anchorPath := filepath.Join(d.absPath, anchorFileName)
+
+	prev := &DatabaseAnchor{}
+	found, ret := readJSONFile(anchorPath, prev)
+	if nil != ret {
+		// without an anchor, we can't tell if anything moved; re-anchor anyway.
+		warnLog.trace(ret)
+		found = false
+	}
+	if found && prev.Root == d.libPath {
+		return nil
+	}
+
+	count, ret := d.reanchor(d.libPath)
+	if nil != ret {
+		return ret
+	}
+	if found {
+		infoLog.verbosef("re-anchored portable library: %q -> %q (%d records updated)",
+			prev.Root, d.libPath, count)
+	}
+
+	return writeJSONFile(anchorPath, &DatabaseAnchor{Root: d.libPath, Time: time.Now()})
+}
@@ -0,0 +1,244 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: preset.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines named layout presets, each of which describes which panes of the
+//    user interface are visible, their proportions, and the default sort order
+//    and library filter of the media browser -- so that the user can quickly
+//    switch between workflows (e.g. browsing music, triaging new downloads).
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// type BrowseSort is an enum identifying the order in which media items are
+// listed in the media browser.
+type BrowseSort int
+
+const (
+	bsUnknown BrowseSort = iota - 1 // = -1
+	bsName                          // =  0
+	bsPath                          // =  1
+	bsAlbum                         // =  2
+	bsField                         // =  3
+	bsCOUNT                         // =  4
+)
+
+var (
+	// variable browseSortName maps the BrowseSort enum values to the names used
+	// to select them in a layout preset.
+	browseSortName = [bsCOUNT]string{
+		"name",  // 0 = bsName
+		"path",  // 1 = bsPath
+		"album", // 2 = bsAlbum
+		"field", // 3 = bsField (followed by ":NAME" of a user-defined field)
+	}
+)
+
+// type LayoutPreset describes a named arrangement of the user interface.
+type LayoutPreset struct {
+	Name    string     // identifier used to select the preset
+	LogRows int        // height of the log view (0 = hidden)
+	Sort    BrowseSort // sort order of the media browser
+	Field   string     // name of the user-defined field sorted by (bsField only)
+	Library string     // name of the only library shown in the media browser (empty = all)
+}
+
+var (
+	// variable defaultLayoutPreset is the preset selected when none has been
+	// requested by the user.
+	defaultLayoutPreset = "default"
+
+	// variable builtinLayoutPreset defines the presets always available. any
+	// user-defined preset with the same name replaces the built-in preset.
+	builtinLayoutPreset = []LayoutPreset{
+		{Name: "default", LogRows: logRowsHeight, Sort: bsName, Library: ""},
+		{Name: "browse", LogRows: 0, Sort: bsName, Library: ""},
+		{Name: "triage", LogRows: 3 * logRowsHeight, Sort: bsPath, Library: ""},
+		{Name: "albums", LogRows: logRowsHeight, Sort: bsAlbum, Library: ""},
+	}
+)
+
+// function parseLayoutPreset() parses a preset definition of the form
+// "NAME:KEY=VALUE[,KEY=VALUE...]", where KEY is one of "log" (rows), "sort"
+// (one of the names in browseSortName, with "field" followed by ":NAME" of a
+// user-defined field), or "library" (name of library). any keys not provided
+// are copied from the built-in "default" preset.
+func parseLayoutPreset(spec string) (*LayoutPreset, error) {
+
+	part := strings.SplitN(spec, ":", 2)
+	name := strings.TrimSpace(part[0])
+	if "" == name {
+		return nil, fmt.Errorf("preset %q: missing name", spec)
+	}
+
+	preset := builtinLayoutPreset[0]
+	preset.Name = name
+
+	if len(part) < 2 {
+		return &preset, nil
+	}
+
+	for _, setting := range strings.Split(part[1], ",") {
+		if "" == strings.TrimSpace(setting) {
+			continue
+		}
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("preset %q: expected KEY=VALUE: %q", spec, setting)
+		}
+		key, val := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		switch key {
+		case "log":
+			rows, err := strconv.Atoi(val)
+			if nil != err || rows < 0 {
+				return nil, fmt.Errorf("preset %q: invalid number of log rows: %q", spec, val)
+			}
+			preset.LogRows = rows
+		case "sort":
+			order := strings.SplitN(val, ":", 2)
+			preset.Sort, preset.Field = bsUnknown, ""
+			for s, n := range browseSortName {
+				if n == strings.ToLower(strings.TrimSpace(order[0])) {
+					preset.Sort = BrowseSort(s)
+					break
+				}
+			}
+			if bsUnknown == preset.Sort {
+				return nil, fmt.Errorf("preset %q: unknown sort order: %q (expected one of: %s)",
+					spec, val, strings.Join(browseSortName[:], ", "))
+			}
+			if len(order) > 1 {
+				preset.Field = strings.TrimSpace(order[1])
+			}
+			if (bsField == preset.Sort) != ("" != preset.Field) {
+				return nil, fmt.Errorf("preset %q: invalid sort order: %q (expected %s:NAME to sort by a user-defined field)",
+					spec, val, browseSortName[bsField])
+			}
+		case "library":
+			preset.Library = val
+		default:
+			return nil, fmt.Errorf("preset %q: unknown setting: %q", spec, key)
+		}
+	}
+	return &preset, nil
+}
+
+// function layoutPresets() returns the list of all available presets, which is
+// the built-in presets followed by all user-defined presets. user-defined
+// presets with the same name as a built-in preset replace it in the list.
+func layoutPresets(opt *Options) []*LayoutPreset {
+
+	preset := []*LayoutPreset{}
+	for i := range builtinLayoutPreset {
+		p := builtinLayoutPreset[i]
+		preset = append(preset, &p)
+	}
+
+	for _, spec := range opt.LayoutPresetDef.StringList {
+		p, err := parseLayoutPreset(spec)
+		if nil != err {
+			continue // already verified when parsing the command line options
+		}
+		replaced := false
+		for i, q := range preset {
+			if q.Name == p.Name {
+				preset[i], replaced = p, true
+				break
+			}
+		}
+		if !replaced {
+			preset = append(preset, p)
+		}
+	}
+	return preset
+}
+
+// function findPreset() returns the index of the available preset with the
+// given name, or -1 if no such preset exists.
+func (l *Layout) findPreset(name string) int {
+	for i, p := range l.preset {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// function applyPreset() rearranges the user interface according to the
+// available preset at the given index.
+func (l *Layout) applyPreset(index int) {
+
+	if index < 0 || index >= len(l.preset) {
+		return
+	}
+	p := l.preset[index]
+	l.currPreset = index
+
+	l.arrangeRoot(p.LogRows)
+	l.browseView.setSortOrder(p.Sort, p.Field)
+
+	// select the preset's library in the library selection view, which in turn
+	// filters the media browser.
+	selected := selectedLibraryAll
+	if "" != p.Library {
+		for i, lib := range l.libSelect.library {
+			if nil != lib && (lib.name == p.Library || lib.absPath == p.Library) {
+				selected = i
+				break
+			}
+		}
+	}
+	if selected != l.libSelect.selectedLibrary {
+		l.libSelect.libDropDown.SetCurrentOption(selected)
+	}
+
+	infoLog.verbosef("using layout preset: %q", p.Name)
+}
+
+// function arrangeRoot() rebuilds the primary layout grid with the given height
+// of the log view, omitting the log view entirely if it should be hidden (a
+// grid row can't have zero height). the media browser shares its row with the
+// spectrum pane if the pane is visible.
+func (l *Layout) arrangeRoot(logRows int) {
+
+	l.root.Clear()
+	if logRows > 0 {
+		l.root.
+			SetRows(1, 0, logRows, 1).
+			AddItem(l.header /******/, 0, 0, 1, 3, 0, 0, false).
+			AddItem(l.logView /*****/, 2, 0, 1, 3, 0, 0, false).
+			AddItem(l.footer /******/, 3, 0, 1, 3, 0, 0, false)
+	} else {
+		l.root.
+			SetRows(1, 0, 1).
+			AddItem(l.header /******/, 0, 0, 1, 3, 0, 0, false).
+			AddItem(l.footer /******/, 2, 0, 1, 3, 0, 0, false)
+	}
+	if l.spectrum.isVisible() {
+		l.root.
+			AddItem(l.browseView /**/, 1, 0, 1, 2, 0, 0, false).
+			AddItem(l.spectrum /****/, 1, 2, 1, 1, 0, 0, false)
+	} else {
+		l.root.
+			AddItem(l.browseView /**/, 1, 0, 1, 3, 0, 0, false)
+	}
+}
+
+// function cyclePreset() applies the next available preset, wrapping around to
+// the first once the last has been applied.
+func (l *Layout) cyclePreset() {
+	if len(l.preset) > 0 {
+		l.applyPreset((l.currPreset + 1) % len(l.preset))
+	}
+}
@@ -0,0 +1,265 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: prune.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the verification of the records loaded from a library database:
+//    the file of each record must still exist. records whose file is missing
+//    (e.g. deleted or moved outside of pimm) are flagged, and may be removed
+//    from the database on request, or right away when loading if enabled with
+//    option -prune. nothing is verified while the library's root directory
+//    cannot be read (e.g. a drive that is not mounted), since every file would
+//    appear to be missing.
+//
+// =============================================================================
+
+package main
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/gdamore/tcell"
+)
+
+// type MissingRecord is a database record whose file was found missing.
+type MissingRecord struct {
+	class   EntityClass
+	kind    int
+	id      int
+	absPath string
+}
+
+// type MissingSet holds the records whose files were found missing by the most
+// recent load of a library, keyed by file path.
+type MissingSet struct {
+	sync.Mutex
+	verify bool                      // files are verified by the load in progress
+	record map[string]*MissingRecord // records whose files are missing
+}
+
+// function newMissingSet() creates a new MissingSet with no records missing.
+func newMissingSet() *MissingSet {
+	return &MissingSet{verify: false, record: map[string]*MissingRecord{}}
+}
+
+// function begin() forgets the records found missing by the previous load, at
+// the beginning of each load. files are only verified if the library's root
+// directory could be read.
+func (s *MissingSet) begin(verify bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.verify = verify
+	s.record = map[string]*MissingRecord{}
+}
+
+// function count() returns the number of records found missing.
+func (s *MissingSet) count() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.record)
+}
+
+// function verifyRecord() verifies the file of the given record still exists,
+// flagging the record as missing if it does not. returns false if the record
+// must not be loaded, i.e. if it is missing and will be pruned once the load
+// has finished.
+func (l *Library) verifyRecord(class EntityClass, kind, id int, absPath string) bool {
+
+	if nil == l.missing {
+		return true
+	}
+	l.missing.Lock()
+	defer l.missing.Unlock()
+	if !l.missing.verify {
+		return true
+	}
+	// only a file that certainly does not exist is missing; any other error
+	// may be transient.
+	if _, err := os.Stat(absPath); nil == err || !os.IsNotExist(err) {
+		return true
+	}
+	l.missing.record[absPath] = &MissingRecord{class: class, kind: kind, id: id, absPath: absPath}
+	infoLog.tracef("file of record missing (ID={%q,%X}): %q", l.name, id, absPath)
+	return !l.prune
+}
+
+// function isMissing() returns true if the file at the given path was found
+// missing by the most recent load of this library.
+func (l *Library) isMissing(absPath string) bool {
+
+	if nil == l.missing {
+		return false
+	}
+	l.missing.Lock()
+	defer l.missing.Unlock()
+	_, ok := l.missing.record[absPath]
+	return ok
+}
+
+// function removeMissing() removes from this library's database the records
+// whose files were found missing by the most recent load, leaving tombstones
+// from which they may be restored (see tombstone.go). records whose files have
+// since reappeared are kept. returns the paths of the files whose records were
+// removed.
+func (l *Library) removeMissing() (map[string]bool, *ReturnCode) {
+
+	removed := map[string]bool{}
+	if nil == l.missing || 0 == l.missing.count() {
+		return removed, nil
+	}
+	if err := l.revalidate(); nil != err {
+		return removed, rcInvalidLibrary.wrap(err,
+			"removeMissing(): library root cannot be read (nothing removed): %q", l.name)
+	}
+
+	l.missing.Lock()
+	defer l.missing.Unlock()
+	path := []string{}
+	for p := range l.missing.record {
+		path = append(path, p)
+	}
+	sort.Strings(path)
+	buried := []int{}
+	defer func() {
+		// the removal may be undone (see tombstone.go).
+		l.tombstones.Lock()
+		l.tombstones.last = buried
+		l.tombstones.Unlock()
+	}()
+	for _, p := range path {
+		if _, err := os.Stat(p); nil == err || !os.IsNotExist(err) {
+			infoLog.tracef("file of record no longer missing (keeping): %q", p)
+			delete(l.missing.record, p)
+			continue
+		}
+		rec := l.missing.record[p]
+		tid, ret := l.buryRecord(rec.class, rec.kind, rec.id, tombstoneMissing)
+		if nil != ret {
+			return removed, ret
+		}
+		if tid >= 0 {
+			buried = append(buried, tid)
+		}
+		delete(l.missing.record, p)
+		removed[p] = true
+		infoLog.verbosef("removed record of missing file: %q", p)
+	}
+	// the library may have returned to within its size budget.
+	if len(removed) > 0 {
+		l.checkBudget()
+	}
+	return removed, nil
+}
+
+// function reportMissing() logs the outcome of verifying the records loaded
+// from this library's database, once the load has finished.
+func (l *Library) reportMissing() {
+
+	count := l.missing.count()
+	if 0 == count {
+		return
+	}
+	if !l.prune {
+		warnLog.logf("flagged %d records of %q whose files no longer exist (remove them from the user interface, or with option -prune)",
+			count, l.name)
+		return
+	}
+	removed, ret := l.removeMissing()
+	if nil != ret {
+		warnLog.log(ret)
+	}
+	infoLog.logf("pruned %d records of %q whose files no longer exist", len(removed), l.name)
+}
+
+// function removeMedia() removes the items of the given library's media whose
+// files are at the given paths from the media browser. returns the media of
+// the items removed.
+func (l *Browser) removeMedia(library *Library, removed map[string]bool) []*Media {
+
+	media, reveal := []*Media{}, []*mediaItem{}
+	drop := func(item *mediaItem) bool {
+		return item.SourceLibrary == library && removed[item.AbsPath]
+	}
+	forget := func(item *mediaItem) {
+		// the films collapsed beneath an item removed are no longer hidden by
+		// it, and an item collapsed beneath another is no longer listed there.
+		for _, c := range item.Collapsed {
+			c.CollapsedIn = nil
+			if !drop(c) {
+				reveal = append(reveal, c)
+			}
+		}
+		if head := item.CollapsedIn; nil != head {
+			if i, ok := item.findItem(head.Collapsed); ok {
+				head.Collapsed = append(head.Collapsed[:i], head.Collapsed[i+1:]...)
+			}
+		}
+		delete(l.record, item.Media)
+		media = append(media, item.Media)
+	}
+
+	for i := len(l.visibleItem) - 1; i >= 0; i-- {
+		if item := l.visibleItem[i]; drop(item) {
+			l.removeItem(i)
+			forget(item)
+		}
+	}
+	hidden := []*mediaItem{}
+	for _, item := range l.hiddenItem {
+		if drop(item) {
+			forget(item)
+		} else {
+			hidden = append(hidden, item)
+		}
+	}
+	l.hiddenItem = hidden
+	for _, c := range reveal {
+		c.showItem()
+	}
+	return media
+}
+
+// function pruneEvent() handles the key removing the records of missing files
+// of all libraries, along with their items. returns true if the key was
+// handled.
+func (l *Layout) pruneEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'Y' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("remove missing media"))
+		return true
+	}
+	go func() {
+		total := 0
+		for _, lib := range l.lib {
+			removed, ret := lib.removeMissing()
+			if nil != ret {
+				warnLog.log(ret)
+			}
+			if 0 == len(removed) {
+				continue
+			}
+			total += len(removed)
+			l.eventQueue <- func(lib *Library) func() {
+				return func() {
+					for _, m := range l.browseView.removeMedia(lib, removed) {
+						l.searchIndex.remove(m)
+					}
+				}
+			}(lib)
+		}
+		if 0 == total {
+			warnLog.logf("(ignored) no records of missing files")
+			return
+		}
+		infoLog.logf("removed %d records of missing files", total)
+	}()
+	return true
+}
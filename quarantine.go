@@ -0,0 +1,233 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: quarantine.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the quarantine of files still being downloaded or written, which
+//    are held back from the library until they are stable, so that half-
+//    downloaded files never enter the database (and later appear corrupt).
+//    a file is held if it is an in-progress download (e.g. "*.part"), if a
+//    download marker exists beside it (e.g. the empty file created by web
+//    browsers beside "*.part"), or if it was modified recently and its size
+//    or time changed between two stats. the folders of held files are
+//    rescanned once they have had time to settle. the files of torrents still
+//    downloading are held too (see torrent.go), until a later scan finds them
+//    complete.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// local unexported constants for the quarantine of in-progress files.
+const (
+	defaultSettle = "30s" // time since its last modification after which a file is presumed complete
+)
+
+var (
+	// variable quarantineExt lists the file name extensions (lower case) of
+	// downloads in progress, as created by web browsers, download managers
+	// and torrent clients.
+	quarantineExt = []string{
+		".part", ".partial", ".crdownload", ".download", ".opdownload",
+		".tmp", ".temp", ".filepart", ".!qb", ".!ut", ".!bt", ".bc!", ".xltd",
+		".aria2",
+	}
+)
+
+// function parseSettle() parses the settle time given by option -settle.
+func parseSettle(spec string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(spec))
+	if nil != err {
+		return 0, fmt.Errorf("settle %q: %s", spec, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("settle %q: must not be negative", spec)
+	}
+	return d, nil
+}
+
+// type Quarantine holds the files of a library found in progress while
+// scanning, along with the state in which each was last seen.
+type Quarantine struct {
+	mutex   sync.Mutex
+	settle  time.Duration       // time since its last modification after which a file is presumed complete
+	held    map[string]HeldFile // files held, by absolute path
+	timer   *time.Timer         // pending rescan of the folders of the files held (nil if none)
+	torrent *TorrentIndex       // files of torrents still downloading
+}
+
+// type HeldFile is the state of a file held in quarantine when it was last
+// seen.
+type HeldFile struct {
+	size    int64
+	mod     time.Time
+	reason  string
+	settles bool // whether the file is expected to be stable within the settle time
+}
+
+// function newQuarantine() creates an empty quarantine with the given settle
+// time, holding the incomplete files of the torrents of the given index.
+func newQuarantine(settle time.Duration, torrent *TorrentIndex) *Quarantine {
+	return &Quarantine{
+		mutex:   sync.Mutex{},
+		settle:  settle,
+		held:    map[string]HeldFile{},
+		timer:   nil,
+		torrent: torrent,
+	}
+}
+
+// function isDownloadExt() returns true if the given file name extension is
+// that of a download in progress.
+func isDownloadExt(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, e := range quarantineExt {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// function check() returns true, along with the reason, if the given file is
+// in progress and must be held back; otherwise, the file is released from the
+// quarantine (if it was held). only files modified within the settle time, or
+// empty ones, are examined further than their name, so that stable libraries
+// are not slowed down.
+func (q *Quarantine) check(absPath, ext string, info os.FileInfo) (string, bool) {
+
+	// the settle time changes if the config file is reloaded.
+	q.mutex.Lock()
+	settle := q.settle
+	q.mutex.Unlock()
+
+	recent := time.Since(info.ModTime()) < settle
+	reason := ""
+	settles := true
+	switch {
+	case isDownloadExt(ext):
+		// abandoned downloads are never completed, so are not held forever.
+		if recent {
+			reason = "incomplete download"
+		}
+	case q.torrent.incomplete(absPath):
+		// torrents may take days to complete, and their files are allocated
+		// at full size, so they are held for as long as they are incomplete.
+		reason = "incomplete torrent"
+		settles = false
+	case recent || 0 == info.Size():
+		for _, e := range quarantineExt {
+			if _, err := os.Stat(absPath + e); nil == err {
+				reason = "download marker " + e + " exists"
+				break
+			}
+		}
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	prev, wasHeld := q.held[absPath]
+	if "" == reason && recent {
+		// a recently modified file is stable once its size and modification
+		// time agree between two stats, taken a settle time apart.
+		if wasHeld && prev.size == info.Size() && prev.mod.Equal(info.ModTime()) {
+			delete(q.held, absPath)
+			return "", false
+		}
+		reason = "still being written"
+	}
+	if "" == reason {
+		delete(q.held, absPath)
+		return "", false
+	}
+	q.held[absPath] = HeldFile{size: info.Size(), mod: info.ModTime(), reason: reason, settles: settles}
+	return reason, true
+}
+
+// function holdsIn() returns true if any file held is in the given directory.
+// such directories must be examined again by the next scan.
+func (q *Quarantine) holdsIn(dir string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for p := range q.held {
+		if filepath.Dir(p) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// function dirs() returns the directories of the files held which are expected
+// to settle.
+func (q *Quarantine) dirs() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	seen := map[string]bool{}
+	dir := []string{}
+	for p, h := range q.held {
+		if d := filepath.Dir(p); h.settles && !seen[d] {
+			seen[d] = true
+			dir = append(dir, d)
+		}
+	}
+	return dir
+}
+
+// function scheduleRescan() rescans the folders of the files held in the
+// library's quarantine once they have had time to settle, unless a rescan is
+// already pending. files still in progress then are held again. the files of
+// incomplete torrents are left for the next scan of the library.
+func (l *Library) scheduleRescan() {
+
+	q := l.quarantine
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	settling := 0
+	for _, h := range q.held {
+		if h.settles {
+			settling++
+		}
+	}
+	if len(q.held) > settling {
+		infoLog.verbosef("holding %d file(s) of incomplete torrents: %q",
+			len(q.held)-settling, l.name)
+	}
+	if 0 == settling || nil != q.timer {
+		return
+	}
+	infoLog.verbosef("holding %d file(s) in progress: %q (rescanning in %s)",
+		settling, l.name, q.settle)
+	// wait at least a second between the two stats of a file, even if the
+	// settle time is shorter.
+	wait := q.settle
+	if wait < time.Second {
+		wait = time.Second
+	}
+	q.timer = time.AfterFunc(wait, func() {
+		q.mutex.Lock()
+		q.timer = nil
+		q.mutex.Unlock()
+		for _, dir := range q.dirs() {
+			if _, ret := l.scanSubtree(dir); nil != ret {
+				if ret.is(rcLibraryBusy) {
+					// a full scan is in progress, which reschedules on its own.
+					return
+				}
+				warnLog.log(ret)
+			}
+		}
+	})
+}
@@ -0,0 +1,396 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: queue.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines playlists, ordered lists of media records stored in each library's
+//    database, and the play queue built on them: media are added, removed and
+//    reordered from the media browser, and once the media played from the
+//    queue finishes, the next media in the queue is played. since the queue
+//    spans libraries, each library stores its own part of it, along with the
+//    position of each of its media in the whole queue.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gdamore/tcell"
+)
+
+// local unexported constants for playlists.
+const (
+	// name of the database collection containing all playlists.
+	playlistColName = "Playlists"
+	// name of the playlist storing each library's part of the play queue.
+	playQueueName = "queue"
+)
+
+var (
+	// variable playlistIndex lists the indices on the playlists collection,
+	// used to find a playlist by its name.
+	playlistIndex = [][]string{{"Name"}}
+)
+
+// type Playlist is a named, ordered list of media records of a library, each
+// referenced by its kind and record ID (see function mediaRef()). if the
+// playlist is part of a list spanning libraries, the position of each media in
+// the whole list is stored too.
+type Playlist struct {
+	Name  string
+	Media []string
+	Pos   []int
+}
+
+// function toRecord() creates a struct capable of being stored in the database.
+func (p *Playlist) toRecord() (*EntityRecord, *ReturnCode) {
+
+	record := &EntityRecord{}
+	data, err := json.Marshal(p)
+	if nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Marshal(%v): cannot marshal Playlist struct into JSON object: %s", p, err)
+	}
+	if err = json.Unmarshal(data, record); nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Unmarshal(%s): cannot unmarshal JSON object into EntityRecord struct: %s", string(data), err)
+	}
+	return record, nil
+}
+
+// function findPlaylist() returns the hash key ID and content of the playlist
+// with the given name, or -1 and an empty playlist if it does not exist.
+func (d *Database) findPlaylist(name string) (int, *Playlist, *ReturnCode) {
+
+	result := map[int]struct{}{}
+	if err := evalQuery(map[string]interface{}{
+		"eq": name,
+		"in": []interface{}{playlistIndex[0][0]},
+	}, d.playlists, &result); nil != err {
+		return -1, nil, rcQueryError.specf(
+			"findPlaylist(%q): %s: EvalQuery(): %s", name, d, err)
+	}
+	for id := range result {
+		read, err := d.playlists.Read(id)
+		if nil != err {
+			return -1, nil, rcDatabaseError.specf(
+				"findPlaylist(%q): %s: Read(%d): %s", name, d, id, err)
+		}
+		data, _ := json.Marshal(read)
+		list := &Playlist{}
+		if err := json.Unmarshal(data, list); nil != err {
+			return -1, nil, rcInvalidJSONData.specf(
+				"findPlaylist(%q): cannot unmarshal JSON object into Playlist struct: %s", name, err)
+		}
+		return id, list, nil
+	}
+	return -1, &Playlist{Name: name, Media: []string{}, Pos: []int{}}, nil
+}
+
+// function savePlaylist() stores the given playlist, replacing the playlist of
+// the same name (if any).
+func (d *Database) savePlaylist(list *Playlist) *ReturnCode {
+
+	id, _, ret := d.findPlaylist(list.Name)
+	if nil != ret {
+		return ret
+	}
+	rec, ret := list.toRecord()
+	if nil != ret {
+		return ret
+	}
+	if id < 0 {
+		if _, err := d.playlists.Insert(*rec); nil != err {
+			return rcDatabaseError.specf(
+				"savePlaylist(%q): %s: Insert(): %s", list.Name, d, err)
+		}
+	} else if err := d.playlists.Update(id, *rec); nil != err {
+		return rcDatabaseError.specf(
+			"savePlaylist(%q): %s: Update(%d): %s", list.Name, d, id, err)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// type PlayQueue is the queue of media played one after another.
+type PlayQueue struct {
+	mutex sync.Mutex
+	entry []*QueueEntry
+}
+
+// type QueueEntry is a media record of a library in the play queue.
+type QueueEntry struct {
+	lib *Library
+	ref string
+}
+
+// function loadPlayQueue() restores the play queue from the given libraries'
+// databases.
+func loadPlayQueue(lib []*Library) *PlayQueue {
+
+	type stored struct {
+		entry *QueueEntry
+		pos   int
+	}
+	all := []stored{}
+	for _, l := range lib {
+		if nil == l.db {
+			continue
+		}
+		_, list, ret := l.db.findPlaylist(playQueueName)
+		if nil != ret {
+			warnLog.log(ret)
+			continue
+		}
+		for i, ref := range list.Media {
+			pos := i
+			if i < len(list.Pos) {
+				pos = list.Pos[i]
+			}
+			all = append(all, stored{&QueueEntry{lib: l, ref: ref}, pos})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].pos < all[j].pos })
+
+	q := &PlayQueue{mutex: sync.Mutex{}, entry: []*QueueEntry{}}
+	for _, s := range all {
+		q.entry = append(q.entry, s.entry)
+	}
+	return q
+}
+
+// function save() stores the part of the queue of each given library in its
+// database.
+func (q *PlayQueue) save(lib ...*Library) {
+
+	for _, l := range lib {
+		if nil == l.db {
+			continue
+		}
+		list := &Playlist{Name: playQueueName, Media: []string{}, Pos: []int{}}
+		q.mutex.Lock()
+		for i, e := range q.entry {
+			if e.lib == l {
+				list.Media = append(list.Media, e.ref)
+				list.Pos = append(list.Pos, i)
+			}
+		}
+		q.mutex.Unlock()
+		if ret := l.db.savePlaylist(list); nil != ret {
+			warnLog.log(ret)
+		}
+	}
+}
+
+// function find() returns the index of the given media record in the queue,
+// or -1 if it is not queued. the caller must hold the queue's mutex.
+func (q *PlayQueue) find(lib *Library, ref string) int {
+	for i, e := range q.entry {
+		if e.lib == lib && e.ref == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// function position() returns the position (from 1) of the given media record
+// in the queue, or 0 if it is not queued.
+func (q *PlayQueue) position(lib *Library, ref string) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.find(lib, ref) + 1
+}
+
+// function add() appends the given media record to the queue, unless it is
+// already queued. returns its position (from 1) and true if it was added.
+func (q *PlayQueue) add(lib *Library, ref string) (int, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if i := q.find(lib, ref); i >= 0 {
+		return i + 1, false
+	}
+	q.entry = append(q.entry, &QueueEntry{lib: lib, ref: ref})
+	return len(q.entry), true
+}
+
+// function remove() removes the given media record from the queue. returns
+// false if it was not queued.
+func (q *PlayQueue) remove(lib *Library, ref string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	i := q.find(lib, ref)
+	if i < 0 {
+		return false
+	}
+	q.entry = append(q.entry[:i], q.entry[i+1:]...)
+	return true
+}
+
+// function move() moves the given media record the given number of positions
+// later (or earlier, if negative) in the queue, stopping at either end.
+// returns its new position (from 1), or 0 if it is not queued.
+func (q *PlayQueue) move(lib *Library, ref string, delta int) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	i := q.find(lib, ref)
+	if i < 0 {
+		return 0
+	}
+	j := i + delta
+	if j < 0 {
+		j = 0
+	} else if j >= len(q.entry) {
+		j = len(q.entry) - 1
+	}
+	e := q.entry[i]
+	q.entry = append(q.entry[:i], q.entry[i+1:]...)
+	q.entry = append(q.entry[:j], append([]*QueueEntry{e}, q.entry[j:]...)...)
+	return j + 1
+}
+
+// function next() returns the media record following the given one in the
+// queue, or nil if it is last or not queued.
+func (q *PlayQueue) next(lib *Library, ref string) *QueueEntry {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if i := q.find(lib, ref); i >= 0 && i+1 < len(q.entry) {
+		return q.entry[i+1]
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// function itemRef() returns the reference to the database record of the given
+// media item, and false if its record is unknown.
+func (l *Layout) itemRef(item *mediaItem) (string, bool) {
+	record, ok := l.browseView.record[item.Media]
+	if !ok {
+		return "", false
+	}
+	return mediaRef(item.Kind, record.id), true
+}
+
+// function queuePosition() returns the position (from 1) of the given media
+// item in the play queue, or 0 if it is not queued.
+func (l *Layout) queuePosition(item *mediaItem) int {
+	if ref, ok := l.itemRef(item); ok {
+		return l.queue.position(item.SourceLibrary, ref)
+	}
+	return 0
+}
+
+// function queueText() returns the text following the name of the given media
+// item in the media browser if it is in the play queue.
+func (l *Layout) queueText(item *mediaItem) string {
+	if pos := l.queuePosition(item); pos > 0 {
+		return fmt.Sprintf(" [#%06x](queued #%d)[-]", colorScheme.highlightPrimary.Hex(), pos)
+	}
+	return ""
+}
+
+// function queuedItem() returns the media item of the given queue entry, or
+// nil if it is not in the media browser.
+func (l *Layout) queuedItem(e *QueueEntry) *mediaItem {
+	all := append(append([]*mediaItem{}, l.browseView.visibleItem...), l.browseView.hiddenItem...)
+	for _, item := range all {
+		if item.SourceLibrary == e.lib {
+			if ref, ok := l.itemRef(item); ok && ref == e.ref {
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+// function advanceQueue() is called when the given media item finished playing.
+// if it was queued, it is removed from the queue and the media following it is
+// played.
+func (l *Layout) advanceQueue(item *mediaItem) {
+
+	l.eventQueue <- func() {
+		ref, ok := l.itemRef(item)
+		if !ok {
+			return
+		}
+		next := l.queue.next(item.SourceLibrary, ref)
+		if !l.queue.remove(item.SourceLibrary, ref) {
+			return
+		}
+		l.queue.save(l.lib...)
+		if nil == next {
+			infoLog.logf("finished playing the queue")
+			return
+		}
+		if nextItem := l.queuedItem(next); nil != nextItem {
+			l.play(nextItem)
+		} else {
+			warnLog.logf("(stopped) next media in the queue is not listed: %s", next.ref)
+		}
+	}
+}
+
+// function queueEvent() handles the keys adding the media item currently
+// selected in the media browser to the play queue ('+'), removing it ('-'),
+// and moving it earlier ('<') or later ('>') in the queue. returns true if the
+// key was handled.
+func (l *Layout) queueEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || ('+' != er && '-' != er && '<' != er && '>' != er) {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("change the queue"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item {
+		warnLog.logf("(ignored) no item selected")
+		return true
+	}
+	ref, ok := l.itemRef(item)
+	if !ok {
+		warnLog.logf("(ignored) database record unknown: %s", item.AbsName)
+		return true
+	}
+
+	lib := item.SourceLibrary
+	switch er {
+	case '+':
+		if pos, added := l.queue.add(lib, ref); added {
+			infoLog.logf("queued %s (#%d)", item.Name, pos)
+		} else {
+			warnLog.logf("(ignored) already queued (#%d): %s", pos, item.Name)
+			return true
+		}
+	case '-':
+		if !l.queue.remove(lib, ref) {
+			warnLog.logf("(ignored) not queued: %s", item.Name)
+			return true
+		}
+		infoLog.logf("removed from the queue: %s", item.Name)
+	case '<', '>':
+		delta := 1
+		if '<' == er {
+			delta = -1
+		}
+		pos := l.queue.move(lib, ref, delta)
+		if 0 == pos {
+			warnLog.logf("(ignored) not queued: %s", item.Name)
+			return true
+		}
+		infoLog.verbosef("moved in the queue (#%d): %s", pos, item.Name)
+	}
+	// the positions of the other libraries' media may have changed too.
+	l.queue.save(l.lib...)
+	return true
+}
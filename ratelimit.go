@@ -0,0 +1,194 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: ratelimit.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines a rate limiter shared by all online integrations (subtitle
+//    downloads, metadata lookups, artwork fetches, etc.) so that together they
+//    never exceed the user's configured bandwidth and request rate limits.
+//
+// =============================================================================
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// type RateLimiter is a pair of token buckets limiting both the number of bytes
+// transferred per second and the number of requests issued per minute. a limit
+// of zero disables the respective bucket. it is safe for concurrent use.
+type RateLimiter struct {
+	*sync.Mutex
+
+	bytesPerSec    uint64 // max number of bytes transferred per second (0 = unlimited)
+	requestsPerMin uint64 // max number of requests issued per minute (0 = unlimited)
+
+	bytesAvail    float64   // bytes currently available in the bucket
+	requestsAvail float64   // requests currently available in the bucket
+	lastFill      time.Time // moment the buckets were last refilled
+}
+
+// local unexported constants for the rate limiter.
+const (
+	// the largest single read performed by a rate-limited reader, so that a
+	// slow connection trickles data rather than bursting and then stalling.
+	maxLimitedRead = 16 * kibiBytes
+)
+
+var (
+	// var netLimiter is the single rate limiter shared by every goroutine that
+	// communicates with the network. it is configured once the command line
+	// options have been parsed (see function initOptions()).
+	netLimiter = newRateLimiter(0, 0)
+)
+
+// function newRateLimiter() creates a new RateLimiter with the given limits,
+// starting with full buckets.
+func newRateLimiter(bytesPerSec, requestsPerMin uint64) *RateLimiter {
+	return &RateLimiter{
+		Mutex:          new(sync.Mutex),
+		bytesPerSec:    bytesPerSec,
+		requestsPerMin: requestsPerMin,
+		bytesAvail:     float64(bytesPerSec),
+		requestsAvail:  float64(requestsPerMin),
+		lastFill:       time.Now(),
+	}
+}
+
+// function setLimits() changes the limits of an existing RateLimiter. any
+// goroutines currently waiting on the limiter will observe the new limits the
+// next time they check the buckets.
+func (r *RateLimiter) setLimits(bytesPerSec, requestsPerMin uint64) {
+	r.Lock()
+	r.bytesPerSec = bytesPerSec
+	r.requestsPerMin = requestsPerMin
+	r.bytesAvail = float64(bytesPerSec)
+	r.requestsAvail = float64(requestsPerMin)
+	r.lastFill = time.Now()
+	r.Unlock()
+}
+
+// function refill() adds tokens to both buckets proportional to the time
+// elapsed since they were last refilled, capped at each bucket's capacity (one
+// second's worth of bytes, one minute's worth of requests). the caller must
+// hold the lock.
+func (r *RateLimiter) refill() {
+	now := time.Now()
+	elapsed := now.Sub(r.lastFill)
+	r.lastFill = now
+
+	if r.bytesPerSec > 0 {
+		r.bytesAvail += elapsed.Seconds() * float64(r.bytesPerSec)
+		if r.bytesAvail > float64(r.bytesPerSec) {
+			r.bytesAvail = float64(r.bytesPerSec)
+		}
+	}
+	if r.requestsPerMin > 0 {
+		r.requestsAvail += elapsed.Minutes() * float64(r.requestsPerMin)
+		if r.requestsAvail > float64(r.requestsPerMin) {
+			r.requestsAvail = float64(r.requestsPerMin)
+		}
+	}
+}
+
+// function waitRequest() blocks until the request rate limit permits another
+// request to be issued, and then consumes it.
+func (r *RateLimiter) waitRequest() {
+	for {
+		r.Lock()
+		if 0 == r.requestsPerMin {
+			r.Unlock()
+			return
+		}
+		r.refill()
+		if r.requestsAvail >= 1 {
+			r.requestsAvail--
+			r.Unlock()
+			return
+		}
+		wait := time.Duration((1 - r.requestsAvail) / float64(r.requestsPerMin) * float64(time.Minute))
+		r.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// function waitBytes() blocks until the bandwidth limit permits at least one
+// byte to be transferred, returning the number of bytes (up to n) the caller
+// may transfer. the bytes are not consumed until the caller reports how many it
+// actually transferred (see function useBytes()).
+func (r *RateLimiter) waitBytes(n int) int {
+	for {
+		r.Lock()
+		if 0 == r.bytesPerSec {
+			r.Unlock()
+			return n
+		}
+		r.refill()
+		if r.bytesAvail >= 1 {
+			if avail := int(r.bytesAvail); avail < n {
+				n = avail
+			}
+			r.Unlock()
+			return n
+		}
+		wait := time.Duration((1 - r.bytesAvail) / float64(r.bytesPerSec) * float64(time.Second))
+		r.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// function useBytes() consumes the given number of bytes transferred from the
+// bandwidth bucket. concurrent transfers may overdraw the bucket, in which case
+// the following transfers wait until it has been refilled.
+func (r *RateLimiter) useBytes(n int) {
+	r.Lock()
+	if r.bytesPerSec > 0 {
+		r.bytesAvail -= float64(n)
+	}
+	r.Unlock()
+}
+
+// type limitedReader wraps an io.Reader so that every read is throttled by a
+// RateLimiter's bandwidth limit.
+type limitedReader struct {
+	io.ReadCloser
+	limiter *RateLimiter
+}
+
+// function Read() implements the io.Reader interface, reading no more bytes
+// than currently permitted by the bandwidth limit.
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > maxLimitedRead {
+		p = p[:maxLimitedRead]
+	}
+	if 0 == len(p) {
+		return r.ReadCloser.Read(p)
+	}
+	n, err := r.ReadCloser.Read(p[:r.limiter.waitBytes(len(p))])
+	// only the bytes actually read count toward the limit, since a read may
+	// return fewer bytes than requested.
+	r.limiter.useBytes(n)
+	return n, err
+}
+
+// function netDo() issues the given HTTP request once permitted by the shared
+// network rate limiter, throttling the body of the response returned. all
+// online integrations should use this rather than the net/http package
+// directly. returns rcNoNetwork if the request could not be sent or no
+// response was received.
+func netDo(req *http.Request) (*http.Response, error) {
+	netLimiter.waitRequest()
+	resp, err := http.DefaultClient.Do(req)
+	if nil != err {
+		return nil, rcNoNetwork.wrap(err, "netDo(%q)", req.URL)
+	}
+	resp.Body = &limitedReader{ReadCloser: resp.Body, limiter: netLimiter}
+	return resp, nil
+}
@@ -0,0 +1,505 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: registry.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the registry of library databases kept in each data directory,
+//    which maps the absolute path of each library to the UUID naming its
+//    database directory. since the name no longer depends on the path, a
+//    library that was moved can be relinked to its database rather than
+//    orphaning it. databases named by the MD5 checksum of the library path (as
+//    they were before the registry existed) are renamed and registered the
+//    next time their library is opened.
+//
+//    also defines the "databases", "relink", and "prune" commands, which list,
+//    relink, and remove the database directories of the data directory.
+//
+// =============================================================================
+
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for the database registry.
+const (
+	registryFileName   = "libraries.json"
+	registryTempSuffix = ".tmp"
+	maxUUIDAttempts    = 16 // max number of UUIDs generated to avoid a collision
+)
+
+var (
+	// variable registryMutex serializes all updates of the registry files made
+	// by this process.
+	registryMutex sync.Mutex
+)
+
+// type RegistryEntry associates a library with its database directory.
+type RegistryEntry struct {
+	UUID    string    `json:"uuid"`    // name of the database directory
+	Path    string    `json:"path"`    // absolute path of the library
+	Created time.Time `json:"created"` // time the library was first registered
+}
+
+// type Registry lists every library with a database in some data directory.
+type Registry struct {
+	dataDir string
+	Library []*RegistryEntry `json:"library"`
+}
+
+// function legacyDatabaseName() returns the name of the database directory of
+// the library with the given absolute path, from before the registry existed.
+func legacyDatabaseName(abs string) string {
+	return strings.ToLower(goutil.MD5(abs))
+}
+
+// function newUUID() generates a random (version 4) UUID.
+func newUUID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); nil != err {
+		return "", err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]), nil
+}
+
+// function loadRegistry() reads the registry of the given data directory. the
+// registry is empty if its file does not exist yet.
+func loadRegistry(dat string) (*Registry, *ReturnCode) {
+	r := &Registry{dataDir: dat, Library: []*RegistryEntry{}}
+	if _, ret := readJSONFile(filepath.Join(dat, registryFileName), r); nil != ret {
+		return nil, ret
+	}
+	return r, nil
+}
+
+// function save() writes the registry to its data directory. the file is
+// replaced only once completely written, so it is never left truncated.
+func (r *Registry) save() *ReturnCode {
+	p := filepath.Join(r.dataDir, registryFileName)
+	if ret := writeJSONFile(p+registryTempSuffix, r); nil != ret {
+		return ret
+	}
+	if err := os.Rename(p+registryTempSuffix, p); nil != err {
+		return rcInvalidFile.wrap(err, "save(%q): os.Rename()", p)
+	}
+	return nil
+}
+
+// function byPath() returns the entry of the library with the given absolute
+// path, or nil if it is not registered.
+func (r *Registry) byPath(abs string) *RegistryEntry {
+	for _, e := range r.Library {
+		if e.Path == abs {
+			return e
+		}
+	}
+	return nil
+}
+
+// function byUUID() returns the entry of the database with the given UUID, or
+// nil if there is no such database.
+func (r *Registry) byUUID(id string) *RegistryEntry {
+	id = strings.ToLower(id)
+	for _, e := range r.Library {
+		if e.UUID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// function register() adds an entry for the library with the given absolute
+// path, named by a new UUID which is not used by any other entry or directory
+// in the data directory.
+func (r *Registry) register(abs string) (*RegistryEntry, *ReturnCode) {
+	for i := 0; i < maxUUIDAttempts; i++ {
+		id, err := newUUID()
+		if nil != err {
+			return nil, rcInvalidDatabase.wrap(err, "register(%q): newUUID()", abs)
+		}
+		if nil != r.byUUID(id) {
+			continue
+		}
+		if exists, _ := goutil.PathExists(filepath.Join(r.dataDir, id)); exists {
+			continue
+		}
+		e := &RegistryEntry{UUID: id, Path: abs, Created: time.Now()}
+		r.Library = append(r.Library, e)
+		return e, nil
+	}
+	return nil, rcInvalidDatabase.specf(
+		"register(%q): no unique UUID after %d attempts", abs, maxUUIDAttempts)
+}
+
+// function unregister() removes the given entry from the registry.
+func (r *Registry) unregister(entry *RegistryEntry) {
+	for i, e := range r.Library {
+		if e == entry {
+			r.Library = append(r.Library[:i], r.Library[i+1:]...)
+			return
+		}
+	}
+}
+
+// function databasePath() returns the name of the database directory of the
+// library with the given absolute path, along with its full path in the given
+// data directory. the name is only looked up, never registered; a library that
+// is not registered has its legacy name (whether or not that exists).
+func databasePath(abs string, dat string) (string, string) {
+	name := legacyDatabaseName(abs)
+	if r, ret := loadRegistry(dat); nil != ret {
+		warnLog.trace(ret)
+	} else if e := r.byPath(abs); nil != e {
+		name = e.UUID
+	}
+	return name, filepath.Join(dat, name)
+}
+
+// function registerDatabase() returns the name and full path of the database
+// directory of the library with the given absolute path in the given data
+// directory, registering the library if it is not already. a database with
+// the library's legacy name is renamed to the new UUID.
+func registerDatabase(abs string, dat string) (string, string, *ReturnCode) {
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	r, ret := loadRegistry(dat)
+	if nil != ret {
+		return "", "", ret
+	}
+	if e := r.byPath(abs); nil != e {
+		return e.UUID, filepath.Join(dat, e.UUID), nil
+	}
+
+	e, ret := r.register(abs)
+	if nil != ret {
+		return "", "", ret
+	}
+	path := filepath.Join(dat, e.UUID)
+	legacy := filepath.Join(dat, legacyDatabaseName(abs))
+	if exists, _ := goutil.PathExists(legacy); exists {
+		// the lock is renamed along with the directory, so it is removed
+		// from the new path rather than released.
+		if ret := lockDatabase(legacy); nil != ret {
+			return "", "", ret
+		}
+		err := os.Rename(legacy, path)
+		unlockDatabase(legacy)
+		os.Remove(filepath.Join(path, databaseLockFileName))
+		if nil != err {
+			return "", "", rcInvalidDatabase.wrap(err,
+				"registerDatabase(%q): os.Rename(%q)", abs, legacy)
+		}
+		infoLog.verbosef("renamed library database: %q (%s -> %s)",
+			abs, filepath.Base(legacy), e.UUID)
+	}
+	if ret := r.save(); nil != ret {
+		return "", "", ret
+	}
+	return e.UUID, path, nil
+}
+
+// function unregisterDatabase() removes the library with the given absolute
+// path from the registry of the given data directory, if it is registered. its
+// database directory is not removed.
+func unregisterDatabase(abs string, dat string) *ReturnCode {
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	r, ret := loadRegistry(dat)
+	if nil != ret {
+		return ret
+	}
+	if e := r.byPath(abs); nil != e {
+		r.unregister(e)
+		return r.save()
+	}
+	return nil
+}
+
+// type DatabaseState identifies the condition of a database directory.
+type DatabaseState int
+
+const (
+	dsUnknown      DatabaseState = iota - 1 // = -1
+	dsLinked                                // =  0, library and database both exist
+	dsOrphaned                              // =  1, library does not exist (moved or removed)
+	dsMissing                               // =  2, registered, but database does not exist
+	dsUnregistered                          // =  3, database not in the registry (legacy name)
+	dsCOUNT                                 // =  4
+)
+
+var (
+	// variable databaseStateName maps the DatabaseState enum values to their
+	// names shown by the "databases" and "prune" commands.
+	databaseStateName = [dsCOUNT]string{
+		"linked", "orphaned", "missing", "unregistered",
+	}
+)
+
+// function String() returns the name of the DatabaseState.
+func (s DatabaseState) String() string {
+	if s > dsUnknown && s < dsCOUNT {
+		return databaseStateName[s]
+	}
+	return "unknown"
+}
+
+// type DatabaseInfo describes a database directory of the data directory.
+type DatabaseInfo struct {
+	name  string         // name of the database directory
+	path  string         // absolute path of the library (empty if unregistered)
+	state DatabaseState  // condition of the database directory
+	entry *RegistryEntry // registry entry (nil if unregistered)
+}
+
+// function String() creates a row of the table of databases.
+func (d *DatabaseInfo) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%-36s  %-12s  %s", d.name, d.state, d.path))
+}
+
+// function listDatabases() returns every registered database of the given data
+// directory along with every unregistered database directory found in it.
+func listDatabases(dat string) (*Registry, []*DatabaseInfo, *ReturnCode) {
+
+	r, ret := loadRegistry(dat)
+	if nil != ret {
+		return nil, nil, ret
+	}
+
+	list := []*DatabaseInfo{}
+	known := map[string]bool{}
+	for _, e := range r.Library {
+		known[e.UUID] = true
+		info := &DatabaseInfo{name: e.UUID, path: e.Path, state: dsLinked, entry: e}
+		if exists, _ := goutil.PathExists(filepath.Join(dat, e.UUID)); !exists {
+			info.state = dsMissing
+		} else if exists, _ := goutil.PathExists(e.Path); !exists {
+			info.state = dsOrphaned
+		}
+		list = append(list, info)
+	}
+
+	fds, err := os.Open(dat)
+	if nil != err {
+		if os.IsNotExist(err) {
+			return r, list, nil
+		}
+		return nil, nil, rcInvalidPath.specf("listDatabases(%q): os.Open(): %s", dat, err)
+	}
+	dir, err := fds.Readdir(0)
+	fds.Close()
+	if nil != err {
+		return nil, nil, rcInvalidPath.specf("listDatabases(%q): Readdir(): %s", dat, err)
+	}
+	for _, i := range dir {
+		// each library database directory contains a data configuration file.
+		p := filepath.Join(dat, i.Name())
+		if exists, _ := goutil.PathExists(filepath.Join(p, dataConfigFileName)); !i.IsDir() || !exists {
+			continue
+		}
+		if !known[i.Name()] {
+			list = append(list, &DatabaseInfo{name: i.Name(), state: dsUnregistered})
+		}
+	}
+	return r, list, nil
+}
+
+// function remove() removes the given database directory, unless it is in use,
+// along with its registry entry. the registry must be saved afterwards.
+func (r *Registry) remove(d *DatabaseInfo) *ReturnCode {
+	path := filepath.Join(r.dataDir, d.name)
+	if dsMissing != d.state {
+		if ret := lockDatabase(path); nil != ret {
+			return ret
+		}
+		err := os.RemoveAll(path)
+		unlockDatabase(path)
+		if nil != err {
+			return rcInvalidDatabase.wrap(err, "remove(%q): os.RemoveAll()", path)
+		}
+	}
+	if nil != d.entry {
+		r.unregister(d.entry)
+	}
+	return nil
+}
+
+// function databasesCommand() implements the "databases" command, listing the
+// database directories of the data directory (see command line option
+// "-libdata") and the library of each.
+func databasesCommand(options *Options, args []string) *ReturnCode {
+
+	if 0 != len(args) {
+		return rcInvalidArgs.spec("databases: expected no arguments")
+	}
+	dat := options.LibData.string
+	_, list, ret := listDatabases(dat)
+	if nil != ret {
+		return ret
+	}
+
+	rawLog.logf("%s", dat)
+	for _, d := range list {
+		rawLog.logf("  %s", d)
+	}
+	return nil
+}
+
+// function relinkCommand() implements the "relink" command, which associates a
+// database (given by its UUID or by its library's old path) with the new path
+// of the library, rewriting the paths of all records in the database.
+func relinkCommand(options *Options, args []string) *ReturnCode {
+
+	if 2 != len(args) {
+		return rcInvalidArgs.spec("relink: expected UUID|OLD_PATH NEW_PATH")
+	}
+	abs, err := libraryPath(args[1])
+	if nil != err {
+		return rcInvalidPath.specf("relink(%q): libraryPath(): %s", args[1], err)
+	}
+	if info, err := os.Stat(abs); nil != err || !info.IsDir() {
+		return rcInvalidLibrary.specf("relink(%q): not a directory", abs)
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	dat := options.LibData.string
+	r, ret := loadRegistry(dat)
+	if nil != ret {
+		return ret
+	}
+	entry := r.byUUID(args[0])
+	if nil == entry {
+		if old, err := libraryPath(args[0]); nil == err {
+			entry = r.byPath(old)
+		}
+	}
+	if nil == entry {
+		return rcInvalidArgs.specf("relink(%q): no such database", args[0])
+	}
+	if e := r.byPath(abs); nil != e && e != entry {
+		return rcDuplicateLibrary.specf(
+			"relink(%q): library already has database %s (see command \"prune\")", abs, e.UUID)
+	}
+
+	path := filepath.Join(dat, entry.UUID)
+	if ret := lockDatabase(path); nil != ret {
+		return ret
+	}
+	defer unlockDatabase(path)
+
+	store, err := openStore(path, storeEngine(path))
+	if nil != err {
+		return rcDatabaseError.specf("relink(%q): openStore(%q): %s", abs, path, err)
+	}
+	total := uint(0)
+	for class := EntityClass(0); class < ecCOUNT; class++ {
+		for _, name := range entityColName[class] {
+			if !store.ColExists(name) {
+				continue
+			}
+			count, ret := reanchorCol(store.Use(name), name, abs)
+			if nil != ret {
+				store.Close()
+				return ret
+			}
+			total += count
+		}
+	}
+	if err := store.Close(); nil != err {
+		return rcDatabaseError.specf("relink(%q): Close(%q): %s", abs, path, err)
+	}
+
+	old := entry.Path
+	entry.Path = abs
+	if ret := r.save(); nil != ret {
+		return ret
+	}
+	rawLog.logf("relinked database %s: %q -> %q (%d records updated)", entry.UUID, old, abs, total)
+	return nil
+}
+
+// function pruneCommand() implements the "prune" command. without arguments,
+// it lists the databases which may be removed: those whose library no longer
+// exists, those not in the registry, and registry entries whose database no
+// longer exists. nothing is removed unless the databases are given by UUID (or
+// directory name), or "all" is given to remove every orphaned and missing one.
+// unregistered databases are only removed by name, since their library may
+// still exist. a library on a drive that is not mounted appears orphaned, so
+// make sure all drives are mounted before using "all".
+func pruneCommand(options *Options, args []string) *ReturnCode {
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	dat := options.LibData.string
+	r, list, ret := listDatabases(dat)
+	if nil != ret {
+		return ret
+	}
+
+	prunable := []*DatabaseInfo{}
+	for _, d := range list {
+		if dsLinked != d.state {
+			prunable = append(prunable, d)
+		}
+	}
+	if 0 == len(args) {
+		for _, d := range prunable {
+			rawLog.logf("  %s", d)
+		}
+		rawLog.logf("%d databases may be pruned (give their names, or \"all\", to remove)", len(prunable))
+		return nil
+	}
+
+	remove := []*DatabaseInfo{}
+	chosen := map[*DatabaseInfo]bool{}
+	for _, arg := range args {
+		found := "all" == arg
+		for _, d := range prunable {
+			if ("all" == arg && dsUnregistered != d.state) || strings.EqualFold(arg, d.name) {
+				if !chosen[d] {
+					chosen[d] = true
+					remove = append(remove, d)
+				}
+				found = true
+			}
+		}
+		if !found {
+			return rcInvalidArgs.specf("prune(%q): no such database, or its library exists", arg)
+		}
+	}
+
+	count := 0
+	for _, d := range remove {
+		if ret := r.remove(d); nil != ret {
+			warnLog.log(ret)
+			continue
+		}
+		rawLog.logf("  removed %s", d)
+		count++
+	}
+	if ret := r.save(); nil != ret {
+		return ret
+	}
+	rawLog.logf("%d databases pruned", count)
+	return nil
+}
@@ -0,0 +1,432 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: relation.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the typed relationships between media of the same library, e.g.
+//    a film and its sequel, or two recordings of the same concert on different
+//    nights. relationships are stored in each library's database as pairs of
+//    record IDs, and are listed (and followed) from the media browser.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for media relationships.
+const (
+	// name of the database collection containing all relationships.
+	relationColName = "Relations"
+)
+
+// type RelationKind is an enum identifying the kind of a relationship.
+type RelationKind int
+
+const (
+	rkUnknown     RelationKind = iota - 1 // = -1
+	rkSequel                              // = 0
+	rkRemake                              // = 1
+	rkPerformance                         // = 2
+	rkRelated                             // = 3
+	rkCOUNT                               // = 4
+)
+
+var (
+	// variable relationKindName maps the RelationKind enum values to the name
+	// by which they are stored.
+	relationKindName = [rkCOUNT]string{
+		"sequel", "remake", "performance", "related",
+	}
+
+	// variable relationDesc maps the RelationKind enum values to how the media
+	// at each end of a relationship is described from the other end: the first
+	// describes the media it is to, the second the media it is from. e.g. if B
+	// is a sequel to A, then B is A's "sequel", and A is B's "prequel".
+	relationDesc = [rkCOUNT][2]string{
+		{"sequel", "prequel"},
+		{"remake", "original"},
+		{"other performance", "other performance"},
+		{"related", "related"},
+	}
+
+	// variable relationIndex lists the indices on the relations collection,
+	// used to find the relationships from and to a media record.
+	relationIndex = [][]string{{"From"}, {"To"}}
+)
+
+// type Relation is a relationship between two media records of a library,
+// each referenced by its kind and record ID (see function mediaRef()).
+type Relation struct {
+	Kind string // name of the RelationKind
+	From string // media the relationship is from
+	To   string // media the relationship is to
+}
+
+// type RelationEnd is one of the relationships of a media record as seen from
+// that record, i.e. the media at its other end.
+type RelationEnd struct {
+	id   int          // hash key ID of the relationship's record
+	kind RelationKind // kind of relationship
+	desc string       // description of the other end
+	ref  string       // reference to the other end
+}
+
+// function parseRelationKind() returns the RelationKind with the given name.
+func parseRelationKind(name string) RelationKind {
+	for k, n := range relationKindName {
+		if strings.EqualFold(n, name) {
+			return RelationKind(k)
+		}
+	}
+	return rkUnknown
+}
+
+// function mediaRef() returns the reference to the media record of the given
+// kind with the given hash key ID, e.g. "Video#42".
+func mediaRef(kind MediaKind, id int) string {
+	return fmt.Sprintf("%s#%d", mediaColName[kind], id)
+}
+
+// function parseMediaRef() returns the kind and hash key ID of the media record
+// with the given reference (see function mediaRef()).
+func parseMediaRef(ref string) (MediaKind, int, bool) {
+	part := strings.SplitN(ref, "#", 2)
+	if 2 != len(part) {
+		return mkUnknown, -1, false
+	}
+	id, err := strconv.Atoi(part[1])
+	if nil != err {
+		return mkUnknown, -1, false
+	}
+	for k, n := range mediaColName {
+		if n == part[0] {
+			return MediaKind(k), id, true
+		}
+	}
+	return mkUnknown, -1, false
+}
+
+// function toRecord() creates a struct capable of being stored in the database.
+func (r *Relation) toRecord() (*EntityRecord, *ReturnCode) {
+
+	record := &EntityRecord{}
+	data, err := json.Marshal(r)
+	if nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Marshal(%v): cannot marshal Relation struct into JSON object: %s", r, err)
+	}
+	if err = json.Unmarshal(data, record); nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Unmarshal(%s): cannot unmarshal JSON object into EntityRecord struct: %s", string(data), err)
+	}
+	return record, nil
+}
+
+// function findRelations() returns the relationships from and to the given
+// media record, as seen from that record.
+func (d *Database) findRelations(ref string) ([]*RelationEnd, *ReturnCode) {
+
+	end := []*RelationEnd{}
+	for _, idx := range relationIndex {
+		result := map[int]struct{}{}
+		if err := evalQuery(map[string]interface{}{
+			"eq": ref,
+			"in": []interface{}{idx[0]},
+		}, d.relations, &result); nil != err {
+			return nil, rcQueryError.specf(
+				"findRelations(%q): %s: EvalQuery(): %s", ref, d, err)
+		}
+		for id := range result {
+			read, err := d.relations.Read(id)
+			if nil != err {
+				return nil, rcDatabaseError.specf(
+					"findRelations(%q): %s: Read(%d): %s", ref, d, id, err)
+			}
+			data, _ := json.Marshal(read)
+			rel := &Relation{}
+			if err := json.Unmarshal(data, rel); nil != err {
+				return nil, rcInvalidJSONData.specf(
+					"findRelations(%q): cannot unmarshal JSON object into Relation struct: %s", ref, err)
+			}
+			kind := parseRelationKind(rel.Kind)
+			if rkUnknown == kind {
+				warnLog.tracef("findRelations(%q): skipping unknown relationship %d: %q", ref, id, rel.Kind)
+				continue
+			}
+			// the relationship is seen from whichever end was queried.
+			if ref == rel.From {
+				end = append(end, &RelationEnd{id, kind, relationDesc[kind][0], rel.To})
+			} else {
+				end = append(end, &RelationEnd{id, kind, relationDesc[kind][1], rel.From})
+			}
+		}
+	}
+	return end, nil
+}
+
+// function addRelation() stores a relationship of the given kind from one media
+// record to another. returns false if the relationship already exists.
+func (d *Database) addRelation(kind RelationKind, from, to string) (bool, *ReturnCode) {
+
+	if from == to {
+		return false, rcInvalidArgs.specf(
+			"addRelation(%q): media cannot be related to itself", from)
+	}
+	end, ret := d.findRelations(from)
+	if nil != ret {
+		return false, ret
+	}
+	for _, e := range end {
+		if e.kind == kind && e.ref == to {
+			return false, nil
+		}
+	}
+	rec, ret := (&Relation{relationKindName[kind], from, to}).toRecord()
+	if nil != ret {
+		return false, ret
+	}
+	if _, err := d.relations.Insert(*rec); nil != err {
+		return false, rcDatabaseError.specf(
+			"addRelation(%q, %q): %s: Insert(): %s", from, to, d, err)
+	}
+	return true, nil
+}
+
+// function removeRelation() removes the relationship with the given hash key
+// ID.
+func (d *Database) removeRelation(id int) *ReturnCode {
+	if err := d.relations.Delete(id); nil != err {
+		return rcDatabaseError.specf(
+			"removeRelation(%d): %s: Delete(): %s", id, d, err)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// type RelationsView is the dialog listing the relationships of the media item
+// currently selected in the media browser. selecting a relationship jumps to
+// the media at its other end, and the items marked in the media browser may be
+// related to the selected item.
+type RelationsView struct {
+	*tview.List
+	item      *mediaItem
+	record    *RecordID
+	end       []*RelationEnd
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// function newRelationsView() allocates and initializes the dialog widgets.
+func newRelationsView(ui *tview.Application, page string, lib []*Library) *RelationsView {
+
+	v := RelationsView{
+		List:      nil,
+		item:      nil,
+		record:    nil,
+		end:       []*RelationEnd{},
+		layout:    nil,
+		focusPage: page,
+		focusNext: nil,
+		focusPrev: nil,
+	}
+
+	list := tview.NewList().
+		SetMainTextColor(colorScheme.inactiveMenuText).
+		SetSecondaryTextColor(colorScheme.inactiveMenuText).
+		SetSelectedTextColor(colorScheme.activeMenuText).
+		SetSelectedBackgroundColor(colorScheme.backgroundSecondary)
+
+	list.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	list.SetInputCapture(v.listInput)
+
+	v.List = list
+
+	return &v
+}
+
+func (v *RelationsView) desc() string { return "" }
+func (v *RelationsView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *RelationsView) page() string         { return v.focusPage }
+func (v *RelationsView) next() FocusDelegator { return v.focusNext }
+func (v *RelationsView) prev() FocusDelegator { return v.focusPrev }
+func (v *RelationsView) focus() {
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.List)
+}
+func (v *RelationsView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function findMedia() returns the browser's item for the media record with
+// the given reference in the given library, or nil if it is not listed.
+func (l *Browser) findMedia(library *Library, ref string) *mediaItem {
+
+	kind, id, ok := parseMediaRef(ref)
+	if !ok {
+		return nil
+	}
+	for _, list := range [][]*mediaItem{l.visibleItem, l.hiddenItem} {
+		for _, item := range list {
+			if item.SourceLibrary != library || item.Kind != kind {
+				continue
+			}
+			if rec, known := l.record[item.Media]; known && rec.id == id {
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+// function setMedia() populates the dialog with the relationships of the given
+// media item, followed by the choices relating the marked items to it.
+func (v *RelationsView) setMedia(item *mediaItem, record *RecordID) *ReturnCode {
+
+	end, ret := item.SourceLibrary.db.findRelations(mediaRef(item.Kind, record.id))
+	if nil != ret {
+		return ret
+	}
+
+	v.item = item
+	v.record = record
+	v.end = end
+
+	v.Clear()
+	for _, e := range end {
+		target := v.layout.browseView.findMedia(item.SourceLibrary, e.ref)
+		if nil == target {
+			v.AddItem(fmt.Sprintf("%s: (not found: %s)", e.desc, e.ref), "", 0, nil)
+			continue
+		}
+		v.AddItem(fmt.Sprintf("%s: %s", e.desc, target.Name), target.RelPath, 0, v.jump(target))
+	}
+	if marked := v.layout.browseView.markedItems(); len(marked) > 0 {
+		for k := RelationKind(0); k < rkCOUNT; k++ {
+			v.AddItem(fmt.Sprintf("+ relate %d marked item(s) as %s", len(marked), relationDesc[k][0]),
+				"", 0, v.relate(k, marked))
+		}
+	} else if 0 == len(end) {
+		v.AddItem("(no relationships; mark items with space to relate them to this one)", "", 0, nil)
+	}
+
+	v.SetTitle(fmt.Sprintf(" Related: [#%06x]%s ",
+		colorScheme.highlightPrimary.Hex(), item.Name))
+	return nil
+}
+
+// function jump() returns a function which closes the dialog and selects the
+// given item in the media browser.
+func (v *RelationsView) jump(target *mediaItem) func() {
+	return func() {
+		if !v.layout.browseView.selectPath(target.SourceLibrary, target.AbsPath) {
+			warnLog.logf("(ignored) related item is hidden by the library selection: %s", target.Name)
+		}
+		v.layout.focusQueue <- v.layout.focusBase
+	}
+}
+
+// function relate() returns a function which relates each of the given items
+// to the dialog's item with the given kind of relationship.
+func (v *RelationsView) relate(kind RelationKind, marked []*mediaItem) func() {
+	return func() {
+		from := mediaRef(v.item.Kind, v.record.id)
+		count := 0
+		for _, m := range marked {
+			rec, known := v.layout.browseView.record[m.Media]
+			if m.SourceLibrary != v.item.SourceLibrary || !known {
+				warnLog.logf("(ignored) only items of the same library may be related: %s", m.Name)
+				continue
+			}
+			added, ret := v.item.SourceLibrary.db.addRelation(kind, from, mediaRef(m.Kind, rec.id))
+			if nil != ret {
+				warnLog.log(ret)
+				continue
+			}
+			if added {
+				count++
+			}
+		}
+		infoLog.logf("related %d item(s) to %s as %s", count, v.item.Name, relationDesc[kind][0])
+		if ret := v.setMedia(v.item, v.record); nil != ret {
+			warnLog.log(ret)
+		}
+	}
+}
+
+// function listInput() removes the selected relationship when the Delete key
+// (or 'd') is pressed.
+func (v *RelationsView) listInput(event *tcell.EventKey) *tcell.EventKey {
+
+	if tcell.KeyDelete != event.Key() && !(tcell.KeyRune == event.Key() && 'd' == event.Rune()) {
+		return event
+	}
+	index := v.GetCurrentItem()
+	if index < 0 || index >= len(v.end) {
+		return nil
+	}
+	if ret := v.item.SourceLibrary.db.removeRelation(v.end[index].id); nil != ret {
+		warnLog.log(ret)
+		return nil
+	}
+	infoLog.logf("removed relationship: %s (%s)", v.item.Name, v.end[index].desc)
+	if ret := v.setMedia(v.item, v.record); nil != ret {
+		warnLog.log(ret)
+	}
+	return nil
+}
+
+// function relationsEvent() handles the key opening the relationships of the
+// media item currently selected in the media browser. returns true if the key
+// was handled.
+func (l *Layout) relationsEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'G' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("show related items"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item {
+		warnLog.logf("(ignored) no item selected")
+		return true
+	}
+	record, ok := l.browseView.record[item.Media]
+	if !ok {
+		warnLog.logf("(ignored) database record unknown: %s", item.AbsName)
+		return true
+	}
+	if ret := l.relations.setMedia(item, record); nil != ret {
+		warnLog.log(ret)
+		return true
+	}
+	l.focusQueue <- l.relations
+	return true
+}
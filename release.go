@@ -0,0 +1,182 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: release.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the release-name hints used to extract the title of a video from
+//    its file name. scene and P2P release names append tags for the source,
+//    resolution, codecs, and release group to the title, e.g.
+//    "The.Matrix.1999.1080p.BluRay.x264-GROUP". the title ends at the first
+//    of these tags (or at the release year), and everything after it is
+//    discarded. a small set of common tags is bundled below; tags may be
+//    added one per line to file "release-tags.txt" in the configuration
+//    directory (lines starting with '#' are comments), or with option
+//    -releasetag.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// local unexported constants for the release-name hints.
+const (
+	releaseTagsFileName = "release-tags.txt"
+)
+
+var (
+	// variable bundledReleaseTags lists the common release tags recognized by
+	// default, normalized (see function normalizeReleaseTag()). words that are
+	// also common in titles (e.g. "web", "cam", "complete") are left out.
+	bundledReleaseTags = []string{
+		// sources
+		"bluray", "bdrip", "brrip", "bdremux", "remux", "dvdrip", "dvdscr",
+		"dvd", "dvd5", "dvd9", "hddvd", "hdtv", "pdtv", "sdtv", "webdl",
+		"webrip", "hdrip", "hdcam", "telesync", "vhsrip", "amzn", "dsnp",
+		"hmax",
+		// video
+		"x264", "x265", "h264", "h265", "hevc", "avc", "xvid", "divx", "vp9",
+		"av1", "10bit", "8bit", "hdr", "hdr10", "sdr", "uhd", "4k",
+		// audio
+		"aac", "aac2", "ac3", "dts", "dtshd", "dtsx", "truehd", "atmos",
+		"dd51", "ddp51", "dd2", "ddp2", "eac3",
+		// editions and other markers
+		"proper", "repack", "rerip", "internal", "unrated", "remastered",
+		"dubbed", "subbed",
+	}
+
+	// variable releaseTag is the set of recognized release tags, normalized.
+	releaseTag = map[string]bool{}
+
+	// variable releaseTokenSep matches the separators between the words of a
+	// release name.
+	releaseTokenSep = regexp.MustCompile(`[\s._\-()\[\]{}]+`)
+
+	// variable releaseResolution matches a video resolution, e.g. "1080p".
+	releaseResolution = regexp.MustCompile(`^\d{3,4}[pi]$`)
+
+	// variable releasePrefix matches a bracketed release group preceding the
+	// title, e.g. "[Group] Title - 01".
+	releasePrefix = regexp.MustCompile(`^\s*\[[^\]]*\]\s*`)
+)
+
+// function init() initializes the recognized release tags with the bundled
+// ones.
+func init() {
+	for _, t := range bundledReleaseTags {
+		releaseTag[t] = true
+	}
+}
+
+// function normalizeReleaseTag() reduces a release tag to lower-case letters
+// and digits, so that e.g. "WEB-DL" and "web.dl" are the same tag.
+func normalizeReleaseTag(tag string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(tag) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// function parseReleaseTag() parses a release tag given with option
+// -releasetag.
+func parseReleaseTag(tag string) error {
+	if "" == normalizeReleaseTag(tag) {
+		return fmt.Errorf("release tag %q: expected at least one letter or digit", tag)
+	}
+	return nil
+}
+
+// function setReleaseHints() adds the release tags of the user's tags file and
+// of the given options, which must have already been validated, to the
+// bundled ones.
+func setReleaseHints(opt *Options) {
+
+	path := filepath.Join(opt.configDir(), releaseTagsFileName)
+	if f, err := os.Open(path); nil == err {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if "" == line || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if tag := normalizeReleaseTag(line); "" != tag {
+				releaseTag[tag] = true
+			}
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		warnLog.logf("cannot read release tags: %s", err)
+	}
+
+	for _, t := range opt.ReleaseTags.StringList {
+		releaseTag[normalizeReleaseTag(t)] = true
+	}
+}
+
+// function isReleaseTag() returns true if the given word (or the given word
+// joined with the next, as in "WEB-DL" or "H.264") is a release tag.
+func isReleaseTag(word, next string) bool {
+	w := normalizeReleaseTag(word)
+	return releaseResolution.MatchString(w) || releaseTag[w] ||
+		("" != next && releaseTag[w+normalizeReleaseTag(next)])
+}
+
+// function yearOf() returns the year given by the given word, or 0 if it
+// is not a plausible release year.
+func yearOf(word string) int {
+	if 4 != len(word) || !(strings.HasPrefix(word, "19") || strings.HasPrefix(word, "20")) {
+		return 0
+	}
+	y, err := strconv.Atoi(word)
+	if nil != err {
+		return 0
+	}
+	return y
+}
+
+// function parseTitle() extracts the title and release year (0 if unknown)
+// from the given file base name of a video. the title of an episode keeps its
+// season and episode marker, along with any episode title that follows.
+func parseTitle(base string) (string, int) {
+
+	word := releaseTokenSep.Split(releasePrefix.ReplaceAllString(base, ""), -1)
+	title, year := []string{}, 0
+	for i, w := range word {
+		if "" == w {
+			continue
+		}
+		next := ""
+		if i+1 < len(word) {
+			next = word[i+1]
+		}
+		// a year ends the title, unless it is part of the title (e.g. "1917",
+		// or "Blade Runner 2049 2017").
+		if y := yearOf(w); y > 0 && len(title) > 0 && 0 == yearOf(next) {
+			year = y
+			break
+		}
+		if len(title) > 0 && isReleaseTag(w, next) {
+			break
+		}
+		title = append(title, w)
+	}
+	if 0 == len(title) {
+		return base, year
+	}
+	return strings.Join(title, " "), year
+}
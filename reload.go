@@ -0,0 +1,416 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: reload.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the live reload of the config file, on signal SIGHUP or when
+//    requested from the user interface. the file is read again, and the
+//    options whose value changed are applied right away if that is safe while
+//    running (indicators, time format, key bindings, poll intervals, player
+//    commands, etc.). the others keep their current value until restarted,
+//    and are reported as such. nothing is applied if the file has problems.
+//
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// type ReloadGroup is a group of options which may be changed while running,
+// along with the function applying their new values.
+type ReloadGroup struct {
+	option []string       // names of the options
+	apply  func(*Options) // applies their values (nil if read whenever used, or by a reload hook)
+}
+
+// type ReloadHook applies the options of the reloaded config file which concern
+// some component (e.g., a library or the user interface), given the names of
+// the options changed. returns the names of the options changed which require a
+// restart to take effect for the component.
+type ReloadHook func(opt *Options, changed map[string]bool) []string
+
+// type ConfigReload is the outcome of reloading the config file.
+type ConfigReload struct {
+	applied []string // options changed whose new values were applied
+	restart []string // options changed which require a restart to take effect
+	failed  []error  // problems found in the config file (nothing applied if any)
+}
+
+var (
+	// variable reloadGroup lists the options which may be changed while
+	// running. all other options require a restart.
+	reloadGroup = []ReloadGroup{
+		{[]string{"verbose", "trace"}, setLogVerbosity},
+		{[]string{"exitmsg", "quiet", "acoustid"}, nil},
+		{[]string{"netrate", "netrequests"}, setNetLimits},
+		{[]string{"glyphs", "glyph"}, setIndicators},
+		{[]string{"timefmt", "tz"}, setTimeFormat},
+		{[]string{"player"}, setPlayer},
+		{[]string{"hostargs"}, setHostArgs},
+		{[]string{"target"}, setPlaybackTargets},
+		{[]string{"macro", "presetdef"}, nil}, // applied by the user interface
+		{[]string{"poll", "settle"}, nil},     // applied by each library
+	}
+
+	// variable liveConfig holds the options in effect, which are updated when
+	// the config file is reloaded (nil until the libraries are opened).
+	liveConfig *Options
+
+	// variable reloadHook holds the hooks called each time the config file is
+	// reloaded.
+	reloadHook = struct {
+		sync.Mutex
+		hook []ReloadHook
+	}{}
+
+	// variable reloadLock serializes the reloads of the config file.
+	reloadLock sync.Mutex
+
+	// variable liveMutex guards the values changed by reloading the config
+	// file while they may be read by other goroutines: the options of
+	// liveConfig (including its sets of options provided and configured), and
+	// the settings applied from them (see each function of reloadGroup).
+	liveMutex sync.RWMutex
+)
+
+// function setLogVerbosity() updates the loggers' verbosity settings from the
+// given options.
+func setLogVerbosity(opt *Options) {
+	verbose, trace := uint32(0), uint32(0)
+	if opt.Verbose.bool {
+		verbose = 1
+	}
+	if opt.Trace.bool {
+		trace = 1
+	}
+	atomic.StoreUint32(&isVerboseLog, verbose)
+	atomic.StoreUint32(&isTraceLog, trace)
+}
+
+// function setNetLimits() configures the rate limiter shared by all online
+// integrations from the given options.
+func setNetLimits(opt *Options) {
+	netLimiter.setLimits(opt.NetBandwidth.uint64, opt.NetRequests.uint64)
+}
+
+// function isReloadable() returns true if the option with the given name may be
+// changed while running.
+func isReloadable(name string) bool {
+	for _, g := range reloadGroup {
+		for _, n := range g.option {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// function onConfigReload() registers the given hook, called each time the
+// config file is reloaded.
+func onConfigReload(hook ReloadHook) {
+	reloadHook.Lock()
+	defer reloadHook.Unlock()
+	reloadHook.hook = append(reloadHook.hook, hook)
+}
+
+// function watchConfig() makes the given options those updated by reloading the
+// config file, reloads it whenever signal SIGHUP is received (on platforms
+// supporting it) until the given context is cancelled, and registers the
+// hooks applying changes to the given libraries.
+func watchConfig(ctx context.Context, options *Options, library []*Library) {
+
+	liveConfig = options
+	for _, l := range library {
+		onConfigReload(l.applyConfig)
+	}
+	if 0 == len(reloadSignal) {
+		return
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, reloadSignal...)
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case s := <-sig:
+				infoLog.logf("reloading config file on signal %s: %q", s, options.Config.string)
+				reloadConfig().report()
+			}
+		}
+	}()
+}
+
+// function resetOption() restores the default value of the given option (i.e.
+// before any was provided).
+func resetOption(options *Options, o *Option) error {
+
+	def := ""
+	if f := options.Lookup(o.name); nil != f {
+		def = f.DefValue
+	}
+	switch {
+	case okStringList == o.kind:
+		o.StringList = nil
+		if "" != def {
+			o.StringList = strings.Split(def, ", ")
+		}
+		return nil
+	case okSize == o.kind && "" == def:
+		o.uint64 = 0
+		return nil
+	}
+	return options.Set(o.name, def)
+}
+
+// function scratchOptions() returns a copy of every option of the given options
+// by name, bound to a new command line parser, so that their values may be
+// changed without affecting those in use. the default value of each option is
+// that of the given options.
+func scratchOptions(options *Options) *Options {
+
+	scratch := &Options{
+		FlagSet:    flag.NewFlagSet(options.Name(), flag.ContinueOnError),
+		Provided:   NamedOption{},
+		Configured: NamedOption{},
+		Known:      NamedOption{},
+	}
+	scratch.SetOutput(ioutil.Discard)
+	for n, o := range options.Known {
+		c := *o
+		c.StringList = append(StringList(nil), o.StringList...)
+		c.bind(scratch.FlagSet)
+		if f, d := scratch.Lookup(n), options.Lookup(n); nil != f && nil != d {
+			f.DefValue = d.DefValue
+		}
+		scratch.Known[n] = &c
+	}
+	for n := range options.Provided {
+		scratch.Provided[n] = scratch.Known[n]
+	}
+	for n := range options.Configured {
+		scratch.Configured[n] = scratch.Known[n]
+	}
+	return scratch
+}
+
+// function reloadConfig() reads the config file again, and applies the options
+// whose value changed, if they may be changed while running. options given on
+// the command line or in the environment still take precedence. the new values
+// are parsed into a copy of the options in use, which are only updated once
+// the whole file is known to be valid.
+func reloadConfig() *ConfigReload {
+
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	r := &ConfigReload{applied: []string{}, restart: []string{}, failed: []error{}}
+	live := liveConfig
+	if nil == live {
+		r.failed = append(r.failed, fmt.Errorf("no configuration in use"))
+		return r
+	}
+	path := live.Config.string
+	f, err := os.Open(path)
+	if nil != err {
+		r.failed = append(r.failed, fmt.Errorf("config file %q: %s", path, err))
+		return r
+	}
+	entry, failed := parseConfig(f, path)
+	f.Close()
+	options := scratchOptions(live)
+	valid, library, invalid := configOptions(options, entry, path)
+	if r.failed = append(failed, invalid...); len(r.failed) > 0 {
+		return r
+	}
+	given := map[string]*ConfigEntry{}
+	for _, e := range valid {
+		given[e.key] = e
+	}
+
+	name := []string{}
+	for n := range options.Known {
+		name = append(name, n)
+	}
+	sort.Strings(name)
+
+	// give each option its new value, keeping only those changed which may be
+	// changed while running.
+	changed := map[string]bool{}
+	for _, n := range name {
+		o := options.Known[n]
+		_, provided := options.Provided[n]
+		_, configured := options.Configured[n]
+		if configExcluded[n] || (provided && !configured) {
+			continue
+		}
+		before := options.Lookup(n).Value.String()
+		if err := resetOption(options, o); nil != err {
+			r.failed = append(r.failed, fmt.Errorf("option %q: cannot restore default: %s", n, err))
+		}
+		if e, ok := given[n]; ok {
+			for _, v := range e.value {
+				if err := options.Set(n, v); nil != err {
+					r.failed = append(r.failed, fmt.Errorf("%s:%d: option %q: %s", path, e.line, n, err))
+				}
+			}
+		}
+		if options.Lookup(n).Value.String() == before {
+			continue
+		}
+		if !isReloadable(n) {
+			r.restart = append(r.restart, n)
+			continue
+		}
+		if nil != o.validate {
+			if err := o.validate(o); nil != err {
+				r.failed = append(r.failed, err)
+			}
+		}
+		changed[n] = true
+	}
+	if len(r.failed) > 0 {
+		r.restart = nil
+		return r
+	}
+
+	// the options changed are now given in the config file, or else restored
+	// to their defaults.
+	liveMutex.Lock()
+	for n := range changed {
+		*live.Known[n] = *options.Known[n]
+		if _, ok := given[n]; ok {
+			live.Provided[n] = live.Known[n]
+			live.Configured[n] = live.Known[n]
+		} else {
+			delete(live.Provided, n)
+			delete(live.Configured, n)
+		}
+		r.applied = append(r.applied, n)
+	}
+	liveMutex.Unlock()
+	sort.Strings(r.applied)
+
+	// the options in use are only changed by this goroutine (while holding
+	// reloadLock), so they may be read without liveMutex from here on.
+	for _, g := range reloadGroup {
+		for _, n := range g.option {
+			if changed[n] && nil != g.apply {
+				g.apply(live)
+				break
+			}
+		}
+	}
+	if len(changed) > 0 {
+		reloadHook.Lock()
+		for _, hook := range reloadHook.hook {
+			r.restart = append(r.restart, hook(live, changed)...)
+		}
+		reloadHook.Unlock()
+	}
+
+	// the libraries given in the config file are only opened at startup.
+	if strings.Join(library, "\n") != strings.Join(live.ConfigLibraries, "\n") {
+		r.restart = append(r.restart, configLibraries)
+	}
+	return r
+}
+
+// function report() logs the outcome of reloading the config file.
+func (r *ConfigReload) report() {
+
+	if len(r.failed) > 0 {
+		for _, err := range r.failed {
+			warnLog.logf("config file not reloaded: %s", err)
+		}
+		return
+	}
+	if 0 == len(r.applied) && 0 == len(r.restart) {
+		infoLog.logf("config file reloaded: no changes")
+		return
+	}
+	if len(r.applied) > 0 {
+		infoLog.logf("config file reloaded: applied %s", strings.Join(r.applied, ", "))
+	}
+	if len(r.restart) > 0 {
+		warnLog.logf("config file reloaded: changes to %s take effect after a restart",
+			strings.Join(r.restart, ", "))
+	}
+}
+
+// function applyConfig() applies the options of the reloaded config file which
+// concern this library.
+func (l *Library) applyConfig(opt *Options, changed map[string]bool) []string {
+
+	restart := []string{}
+	if changed[opt.Settle.name] && nil != l.quarantine {
+		if settle, err := parseSettle(opt.Settle.string); nil == err {
+			l.quarantine.mutex.Lock()
+			l.quarantine.settle = settle
+			l.quarantine.mutex.Unlock()
+		}
+	}
+	if changed[opt.PollFreq.name] {
+		if freq := l.pollFreqOf(opt); freq != l.pollFreq {
+			if 0 == atomic.LoadUint32(&l.polling) {
+				// the polling watcher of this library is not running.
+				restart = append(restart, fmt.Sprintf("%s (%s)", opt.PollFreq.name, l.name))
+			} else {
+				l.pollFreq = freq
+				// only the most recent interval matters.
+				select {
+				case <-l.pollReset:
+				default:
+				}
+				l.pollReset <- freq
+			}
+		}
+	}
+	return restart
+}
+
+// function applyConfig() applies the options of the reloaded config file which
+// concern the user interface: the macros bound to function keys, and the
+// user-defined layout presets.
+func (l *Layout) applyConfig(opt *Options, changed map[string]bool) []string {
+
+	if !changed[opt.MacroBinding.name] && !changed[opt.LayoutPresetDef.name] {
+		return nil
+	}
+	// the options are read here rather than in the UI goroutine, since they
+	// may change again by the time it runs.
+	bound := newMacroRecorder(opt).bound
+	preset := layoutPresets(opt)
+	l.ui.QueueUpdateDraw(func() {
+		if changed[opt.MacroBinding.name] {
+			l.macro.bound = bound
+		}
+		if changed[opt.LayoutPresetDef.name] {
+			// keep the current preset selected, if it still exists.
+			name := l.preset[l.currPreset].Name
+			l.preset = preset
+			if i := l.findPreset(name); i >= 0 {
+				l.currPreset = i
+			} else {
+				l.applyPreset(l.findPreset(defaultLayoutPreset))
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,161 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: reload_test.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    tests of the live reload of the config file while libraries are in use.
+//    run with "go test -race" to verify the values changed by a reload are
+//    never read by other goroutines without synchronization.
+//
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// local unexported constants for the config reload tests.
+const (
+	reloadTestCount = 10 // number of times the library is scanned while reloading
+)
+
+// function reloadTestConfig() returns the contents of a config file giving
+// every option which may be changed while running, with values depending on
+// the given iteration, so that each reload changes all of them.
+func reloadTestConfig(i int) string {
+	on := 0 == i%2
+	style := "iso"
+	if on {
+		style = "us"
+	}
+	return fmt.Sprintf(`[options]
+verbose = %t
+trace = %t
+exitmsg = "bye %d"
+poll = ["%ds"]
+settle = "%ds"
+
+[ui]
+glyphs = %t
+glyph = ["marked=%c"]
+timefmt = %q
+
+[player]
+player = "player-%d"
+hostargs = ["*=--arg-%d"]
+`, on, !on, i, 60+i, 1+i, on, 'a'+rune(i%26), style, i, i)
+}
+
+// function TestReloadConfigDuringScan() reloads the config file repeatedly
+// while a library is scanned and loaded, and reads the settings it changes meanwhile, as
+// the loggers and the user interface would.
+func TestReloadConfigDuringScan(t *testing.T) {
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, defaultConfigName)
+	if err := ioutil.WriteFile(config, []byte(reloadTestConfig(0)), 0600); nil != err {
+		t.Fatalf("ioutil.WriteFile(%q): %s", config, err)
+	}
+	spec, ret := newFixtureSpec(nil)
+	if nil != ret {
+		t.Fatalf("newFixtureSpec(): %s", ret)
+	}
+	root := filepath.Join(dir, fixtureLibraryDir)
+	if _, ret := generateFixture(spec, root); nil != ret {
+		t.Fatalf("generateFixture(%q): %s", root, ret)
+	}
+	data := filepath.Join(dir, "data")
+	if err := os.MkdirAll(data, os.ModePerm); nil != err {
+		t.Fatalf("os.MkdirAll(%q): %s", data, err)
+	}
+
+	options, ret := initOptions([]string{"-config", config, "-libdata", data, root})
+	if nil != ret {
+		t.Fatalf("initOptions(): %s", ret)
+	}
+	busy := newBusyState()
+	go func() {
+		for range busy.changed {
+		}
+	}()
+	lib, ret := newLibrary(options, busy, root, depthUnlimited, nil)
+	if nil != ret {
+		t.Fatalf("newLibrary(%q): %s", root, ret)
+	}
+	defer lib.db.close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchConfig(ctx, options, []*Library{lib})
+	defer func() { liveConfig = nil }()
+
+	// the handlers read every setting changed by the reloads.
+	read := func(l *Library, p string, v ...interface{}) {
+		formatTime(time.Now())
+		indicator(ikMarked)
+		argsForHost(localHostName())
+		mediaPlayerCommand()
+		currentTarget()
+		exitMessage(options)
+	}
+	handler := &PathHandler{handleMedia: read, handleSupport: read, handleOther: read}
+
+	// scan the library a number of times, reloading the config file over and
+	// over meanwhile. only new files are handled by a scan, so the library is
+	// also loaded each time.
+	done := make(chan *ReturnCode, 1)
+	go func() {
+		for i := 0; i < reloadTestCount; i++ {
+			if _, ret := lib.scan(ctx, handler); nil != ret {
+				done <- ret
+				return
+			}
+			if _, ret := lib.load(ctx, handler); nil != ret {
+				done <- ret
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	reload := 0
+	for scanning := true; scanning; {
+		select {
+		case ret := <-done:
+			if nil != ret {
+				t.Fatalf("scan(): %s", ret)
+			}
+			scanning = false
+			continue
+		default:
+		}
+		reload++
+		if err := ioutil.WriteFile(config, []byte(reloadTestConfig(reload)), 0600); nil != err {
+			t.Fatalf("ioutil.WriteFile(%q): %s", config, err)
+		}
+		r := reloadConfig()
+		if len(r.failed) > 0 {
+			t.Fatalf("reloadConfig(): %v", r.failed)
+		}
+		if 0 == len(r.applied) {
+			t.Errorf("reloadConfig(): no options applied on reload %d", reload)
+		}
+	}
+
+	if expected := fmt.Sprintf("player-%d", reload); mediaPlayerCommand() != expected {
+		t.Errorf("media player: expected %q, got %q", expected, mediaPlayerCommand())
+	}
+	if expected := fmt.Sprintf("bye %d", reload); exitMessage(options) != expected {
+		t.Errorf("exit message: expected %q, got %q", expected, exitMessage(options))
+	}
+}
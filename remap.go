@@ -0,0 +1,165 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: remap.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines path remap rules for libraries that were moved. a library database
+//    is identified by the library's absolute path, so a moved library would
+//    otherwise be rescanned into a new, empty database, losing all of its user
+//    data. instead, the old database is migrated to the new path.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for path remapping.
+const (
+	// suffix of the database directory being migrated, until it is complete.
+	remapTempSuffix = ".remap"
+)
+
+// type RemapRule is a parsed path remap rule of the form "OLD=NEW", declaring
+// that everything previously found under path prefix OLD is now under NEW.
+type RemapRule struct {
+	from string // absolute path prefix stored in existing databases
+	to   string // absolute path prefix replacing it
+}
+
+// function parseRemapRule() parses a path remap rule of the form "OLD=NEW".
+func parseRemapRule(spec string) (*RemapRule, error) {
+
+	pair := strings.SplitN(spec, "=", 2)
+	if 2 != len(pair) || "" == strings.TrimSpace(pair[0]) || "" == strings.TrimSpace(pair[1]) {
+		return nil, fmt.Errorf("path remap %q: expected OLD=NEW", spec)
+	}
+	from, err := libraryPath(strings.TrimSpace(pair[0]))
+	if nil != err {
+		return nil, fmt.Errorf("path remap %q: %s", spec, err)
+	}
+	to, err := libraryPath(strings.TrimSpace(pair[1]))
+	if nil != err {
+		return nil, fmt.Errorf("path remap %q: %s", spec, err)
+	}
+	if from == to {
+		return nil, fmt.Errorf("path remap %q: OLD and NEW are the same path", spec)
+	}
+	return &RemapRule{from: from, to: to}, nil
+}
+
+// function apply() returns the path that the given path had before the rule's
+// move, and true if the given path is affected by the rule at all.
+func (r *RemapRule) apply(abs string) (string, bool) {
+	if abs == r.to {
+		return r.from, true
+	}
+	if strings.HasPrefix(abs, r.to+pathSep) {
+		return r.from + abs[len(r.to):], true
+	}
+	return "", false
+}
+
+// function forward() returns the path that the given path has after the rule's
+// move, and true if the given path is affected by the rule at all.
+func (r *RemapRule) forward(abs string) (string, bool) {
+	if abs == r.from {
+		return r.to, true
+	}
+	if strings.HasPrefix(abs, r.from+pathSep) {
+		return r.to + abs[len(r.from):], true
+	}
+	return "", false
+}
+
+// function remapDatabase() migrates the database of a library that was moved
+// to the given absolute path, if one of the given path remap rules applies to
+// it, the library has a database at its old path, and no database at its new
+// path. the database is copied and rewritten aside, and only then moved into
+// place, so an interrupted migration leaves the old database untouched.
+func remapDatabase(opt *Options, abs string) *ReturnCode {
+
+	_, _, newPath := libraryDatabasePath(opt, abs)
+	if exists, _ := goutil.PathExists(newPath); exists {
+		return nil
+	}
+
+	for _, spec := range opt.PathRemap.StringList {
+		rule, err := parseRemapRule(spec)
+		if nil != err {
+			continue
+		}
+		old, ok := rule.apply(abs)
+		if !ok {
+			continue
+		}
+		_, oldPath := databasePath(old, opt.LibData.string)
+		if exists, _ := goutil.PathExists(oldPath); !exists {
+			continue
+		}
+
+		// the database must not be in use by another process while it is
+		// copied, or the copy may be inconsistent.
+		if ret := lockDatabase(oldPath); nil != ret {
+			return ret
+		}
+		defer unlockDatabase(oldPath)
+
+		tmpPath := newPath + remapTempSuffix
+		if err := os.RemoveAll(tmpPath); nil != err {
+			return rcInvalidDatabase.specf(
+				"remapDatabase(%q): os.RemoveAll(%q): %s", abs, tmpPath, err)
+		}
+		if ret := copyDir(oldPath, tmpPath); nil != ret {
+			return ret
+		}
+
+		store, err := openStore(tmpPath, storeEngine(tmpPath))
+		if nil != err {
+			return rcDatabaseError.specf(
+				"remapDatabase(%q): openStore(%q): %s", abs, tmpPath, err)
+		}
+		total := uint(0)
+		for class := EntityClass(0); class < ecCOUNT; class++ {
+			for _, name := range entityColName[class] {
+				if !store.ColExists(name) {
+					continue
+				}
+				count, ret := reanchorCol(store.Use(name), name, abs)
+				if nil != ret {
+					store.Close()
+					return ret
+				}
+				total += count
+			}
+		}
+		if err := store.Close(); nil != err {
+			return rcDatabaseError.specf(
+				"remapDatabase(%q): Close(%q): %s", abs, tmpPath, err)
+		}
+
+		if err := os.Rename(tmpPath, newPath); nil != err {
+			return rcInvalidDatabase.specf(
+				"remapDatabase(%q): os.Rename(%q): %s", abs, tmpPath, err)
+		}
+		if err := os.RemoveAll(oldPath); nil != err {
+			warnLog.tracef("remapDatabase(%q): os.RemoveAll(%q): %s", abs, oldPath, err)
+		}
+		if ret := unregisterDatabase(old, opt.LibData.string); nil != ret {
+			warnLog.trace(ret)
+		}
+		infoLog.logf("migrated moved library database: %q -> %q (%d records updated)",
+			old, abs, total)
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: retry.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the retry policy for file system operations performed while
+//    scanning. errors which are usually transient (a busy file, a network
+//    share that hiccuped) are retried a bounded number of times with
+//    exponential backoff before they are reported as failures.
+//
+// =============================================================================
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// local unexported constants for the retry policy.
+const (
+	// default max number of times a transiently failing operation is retried.
+	defaultFSRetries = 3
+
+	// delay before the first retry, doubled before each subsequent retry.
+	fsRetryDelay = 25 * time.Millisecond
+)
+
+// type RetryStats counts the retries performed by a single scan. it is safe
+// for concurrent use.
+type RetryStats struct {
+	retries   uint64 // number of retries performed
+	recovered uint64 // number of operations which succeeded after retrying
+	exhausted uint64 // number of operations which failed after all retries
+}
+
+// function reset() zeroizes all counters.
+func (s *RetryStats) reset() {
+	atomic.StoreUint64(&s.retries, 0)
+	atomic.StoreUint64(&s.recovered, 0)
+	atomic.StoreUint64(&s.exhausted, 0)
+}
+
+// function String() creates a human-readable summary of the counters.
+func (s *RetryStats) String() string {
+	return fmt.Sprintf("%d retries, %d recovered, %d failed",
+		atomic.LoadUint64(&s.retries),
+		atomic.LoadUint64(&s.recovered),
+		atomic.LoadUint64(&s.exhausted))
+}
+
+// function isTransientError() returns true if the given error is of a kind
+// that may not occur again if the operation is retried shortly after.
+func isTransientError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	for _, e := range transientErrno {
+		if e == errno {
+			return true
+		}
+	}
+	return false
+}
+
+// function retryFS() performs the given file system operation, retrying it
+// with exponential backoff for as long as it fails with a transient error, up
+// to the library's max number of retries. returns the error of the last
+// attempt.
+func (l *Library) retryFS(op func() error) error {
+
+	err := op()
+	if nil == err || !isTransientError(err) {
+		return err
+	}
+
+	delay := fsRetryDelay
+	for n := uint(0); n < l.fsRetries; n++ {
+		atomic.AddUint64(&l.retryStats.retries, 1)
+		warnLog.tracef("retrying in %s (%d/%d): %s", delay, n+1, l.fsRetries, err)
+		time.Sleep(delay)
+		delay *= 2
+		if err = op(); nil == err {
+			atomic.AddUint64(&l.retryStats.recovered, 1)
+			return nil
+		}
+		if !isTransientError(err) {
+			return err
+		}
+	}
+	atomic.AddUint64(&l.retryStats.exhausted, 1)
+	return err
+}
@@ -0,0 +1,115 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: scanpool.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the worker pool scanning the entries of a library's directories
+//    in parallel. each scan of a library has its own pool, with a bounded
+//    number of workers (option -dirworkers), and the goroutine performing the
+//    scan is always one of them. an entry is handed to a worker only if one is
+//    idle; otherwise, the goroutine scanning its directory scans it too, as it
+//    always did. the traversal thus never waits for a worker (nor deadlocks
+//    while every worker waits on the subdirectories it handed out), and every
+//    worker keeps busy for as long as there are directories left to scan.
+//
+//    this is independent of the limit on concurrent scans of a library (see
+//    maxLibraryScanners), and of the limit on libraries scanned concurrently
+//    (option -scanworkers); a pool only scans the directories of one scan.
+//
+// =============================================================================
+
+package main
+
+import (
+	"sync"
+)
+
+// type ScanPool is the pool of workers scanning the entries of a library's
+// directories in parallel, for a single scan.
+type ScanPool struct {
+	slot chan struct{} // counting semaphore of the workers besides the scanner
+}
+
+// type ScanGroup is the set of entries of a single directory scanned by the
+// workers of a pool, which must all be scanned before the directory is
+// complete.
+type ScanGroup struct {
+	sync.Mutex
+	pool        *ScanPool
+	wait        sync.WaitGroup
+	interrupted *ReturnCode // the first entry whose scan was interrupted
+}
+
+// function newScanPool() creates a new ScanPool with at most n workers,
+// including the goroutine performing the scan. the pool is nil (i.e., every
+// entry is scanned in turn) if n is less than 2.
+func newScanPool(n uint) *ScanPool {
+	if n < 2 {
+		return nil
+	}
+	return &ScanPool{
+		slot: make(chan struct{}, n-1),
+	}
+}
+
+// function group() creates a new ScanGroup of the entries of a directory.
+func (p *ScanPool) group() *ScanGroup {
+	return &ScanGroup{pool: p, interrupted: nil}
+}
+
+// function dive() scans an entry of the directory with the given function,
+// using an idle worker of the pool, if any, or else the calling goroutine.
+// once scanned, the given function done is called with the error returned, if
+// any, while holding the group's lock, unless the scan was interrupted.
+func (g *ScanGroup) dive(scan func() *ReturnCode, done func(*ReturnCode)) {
+
+	finish := func(ret *ReturnCode) {
+		g.Lock()
+		defer g.Unlock()
+		switch {
+		case nil == ret:
+		case ret.is(rcInterrupted):
+			if nil == g.interrupted {
+				g.interrupted = ret
+			}
+		default:
+			done(ret)
+		}
+	}
+
+	if nil != g.pool {
+		select {
+		case g.pool.slot <- struct{}{}:
+			g.wait.Add(1)
+			go func() {
+				defer g.wait.Done()
+				defer func() { <-g.pool.slot }()
+				finish(scan())
+			}()
+			return
+		default:
+			// every worker is busy, so scan it ourselves.
+		}
+	}
+	finish(scan())
+}
+
+// function stopped() returns the error of the first entry whose scan was
+// interrupted, if any, in which case the remaining entries must not be
+// scanned.
+func (g *ScanGroup) stopped() *ReturnCode {
+	g.Lock()
+	defer g.Unlock()
+	return g.interrupted
+}
+
+// function finish() waits until every entry of the directory handed to a
+// worker has been scanned. returns the error of the first entry whose scan was
+// interrupted, if any.
+func (g *ScanGroup) finish() *ReturnCode {
+	g.wait.Wait()
+	return g.stopped()
+}
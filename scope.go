@@ -0,0 +1,126 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: scope.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the library scope of declarations of the form "DECL[@LIBRARY]"
+//    given with command line options (e.g. -poll, -field, -archives), which
+//    restricts a declaration to the single library identified by LIBRARY,
+//    either by name or by path. paths are normalized the same as the library
+//    paths given on the command line (see function libraryPath()), so that
+//    "@~/media", "@media/" and "@/mnt/media" all identify the same library.
+//
+// =============================================================================
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// type LibraryScope is the scope of a declaration, identifying the only
+// library affected (or every library if empty).
+type LibraryScope struct {
+	library string // name or path of the only library affected (empty = all)
+	name    string // library name, without any trailing path separator
+	path    string // canonical absolute path (empty if it cannot be resolved)
+}
+
+// function parseLibraryScope() splits the given declaration of the form
+// "DECL[@LIBRARY]" into DECL and the scope identified by LIBRARY.
+func parseLibraryScope(spec string) (string, *LibraryScope) {
+
+	scope := &LibraryScope{}
+
+	decl := spec
+	if at := strings.LastIndex(decl, "@"); at >= 0 {
+		scope.library = strings.TrimSpace(decl[at+1:])
+		decl = decl[:at]
+	}
+	if "" != scope.library {
+		scope.name = strings.TrimRight(scope.library, "/"+string(filepath.Separator))
+		if abs, err := libraryPath(scope.library); nil == err {
+			scope.path = abs
+		}
+	}
+	return decl, scope
+}
+
+// function String() returns the LIBRARY of the scope, as it was declared.
+func (s *LibraryScope) String() string {
+	return s.library
+}
+
+// function appliesTo() returns true if the scope includes the given library,
+// i.e. if it identifies the library by name or by path, or if it is empty.
+func (s *LibraryScope) appliesTo(lib *Library) bool {
+	return "" == s.library || s.name == lib.name || s.path == lib.absPath
+}
+
+// function reportUnknownScopes() warns of each declaration given with the
+// command line options whose scope identifies none of the given libraries, and
+// which is thus never applied.
+func reportUnknownScopes(opt *Options, library []*Library) {
+
+	scoped := []struct {
+		option *Option
+		parse  func(string) (*LibraryScope, error)
+	}{
+		{opt.Archives, func(spec string) (*LibraryScope, error) {
+			rule, err := parseArchiveRule(spec)
+			if nil != err {
+				return nil, err
+			}
+			return rule.scope, nil
+		}},
+		{opt.CustomFields, func(spec string) (*LibraryScope, error) {
+			field, err := parseCustomField(spec)
+			if nil != err {
+				return nil, err
+			}
+			return field.Scope, nil
+		}},
+		{opt.PollFreq, func(spec string) (*LibraryScope, error) {
+			rule, err := parsePollRule(spec)
+			if nil != err {
+				return nil, err
+			}
+			return rule.scope, nil
+		}},
+		{opt.Sniff, func(spec string) (*LibraryScope, error) {
+			rule, err := parseSniffRule(spec)
+			if nil != err {
+				return nil, err
+			}
+			return rule.scope, nil
+		}},
+		{opt.SubsDisable, func(spec string) (*LibraryScope, error) {
+			dis, err := parseSubsDisable(spec)
+			if nil != err {
+				return nil, err
+			}
+			return dis.scope, nil
+		}},
+	}
+
+	for _, o := range scoped {
+		for _, spec := range o.option.StringList {
+			scope, err := o.parse(spec)
+			if nil != err || "" == scope.library {
+				continue
+			}
+			known := false
+			for _, lib := range library {
+				known = known || scope.appliesTo(lib)
+			}
+			if !known {
+				warnLog.log(rcInvalidLibrary.specf(
+					"-%s %q: no such library: %q (ignored)", o.option.name, spec, scope))
+			}
+		}
+	}
+}
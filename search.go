@@ -0,0 +1,531 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: search.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the full-text fuzzy search of the media browser (opened with '/'),
+//    matching the title, file name, base name and description of the media of
+//    all libraries. the text searched is kept in an in-memory index, filled as
+//    media are discovered while the libraries are loaded and scanned, so that
+//    the media browser can be refined with every key typed. the characters
+//    matched are highlighted in each result. a query of the form NAME=VALUE,
+//    where NAME is a user-defined field, instead lists the media whose field
+//    equals VALUE (see field.go).
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for the fuzzy search.
+const (
+	searchMatchScore   = 1  // score of each character matched
+	searchAdjacentMore = 4  // score added for each character matched right after the previous one
+	searchWordStart    = 3  // score added for each character matched at the start of a word
+	searchGapPenalty   = 1  // score removed for each gap between the characters matched
+	searchContextRunes = 24 // number of characters shown around a match in a field not listed
+)
+
+// type SearchField identifies the text of media matched by the search.
+type SearchField int
+
+const (
+	sfUnknown SearchField = iota - 1
+	sfTitle
+	sfName
+	sfBase
+	sfDescription
+	sfCOUNT
+)
+
+var (
+	searchFieldName = [sfCOUNT]string{"title", "name", "base", "description"}
+)
+
+// function text() returns the given media's text for this field.
+func (f SearchField) text(m *Media) string {
+	switch f {
+	case sfTitle:
+		return m.Title
+	case sfName:
+		return m.AbsName
+	case sfBase:
+		return m.AbsBase
+	case sfDescription:
+		// the placeholder "--" means no description was provided.
+		if "" == strings.Trim(m.Description, "- ") {
+			return ""
+		}
+		return m.Description
+	}
+	return ""
+}
+
+// type SearchIndex holds the text searched of all media discovered.
+type SearchIndex struct {
+	mutex sync.Mutex
+	entry []*SearchEntry
+}
+
+// type SearchEntry holds the text searched of a single media.
+type SearchEntry struct {
+	media *Media
+	orig  [sfCOUNT]string // text from which each field was folded, to detect edits
+	fold  [sfCOUNT][]rune // lower-case text of each field
+}
+
+// function newSearchIndex() creates an empty search index.
+func newSearchIndex() *SearchIndex {
+	return &SearchIndex{mutex: sync.Mutex{}, entry: []*SearchEntry{}}
+}
+
+// function add() adds the given media to the index.
+func (x *SearchIndex) add(m *Media) {
+	e := &SearchEntry{media: m}
+	e.refresh()
+	x.mutex.Lock()
+	x.entry = append(x.entry, e)
+	x.mutex.Unlock()
+}
+
+// function remove() removes the given media from the index.
+func (x *SearchIndex) remove(m *Media) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	for i, e := range x.entry {
+		if e.media == m {
+			x.entry = append(x.entry[:i], x.entry[i+1:]...)
+			return
+		}
+	}
+}
+
+// function refresh() folds each field of the entry's media whose text was
+// edited since it was last folded.
+func (e *SearchEntry) refresh() {
+	for f := SearchField(0); f < sfCOUNT; f++ {
+		if t := f.text(e.media); t != e.orig[f] || nil == e.fold[f] {
+			e.orig[f] = t
+			e.fold[f] = []rune(strings.ToLower(t))
+		}
+	}
+}
+
+// type SearchMatch is a media matched by the search, along with the field it
+// was best matched in and the positions (in runes) of the characters matched.
+type SearchMatch struct {
+	media *Media
+	field SearchField
+	score int
+	pos   []int
+}
+
+// function match() returns the best match of the given query (folded to lower
+// case, without spaces) among the fields of the entry, or nil if none match.
+func (e *SearchEntry) match(query []rune) *SearchMatch {
+	e.refresh()
+	var best *SearchMatch
+	for f := SearchField(0); f < sfCOUNT; f++ {
+		if score, pos, ok := fuzzyMatch(e.fold[f], query); ok {
+			if nil == best || score > best.score {
+				best = &SearchMatch{media: e.media, field: f, score: score, pos: pos}
+			}
+		}
+	}
+	return best
+}
+
+// function search() returns the media in the index matching the given query,
+// best matches first. if only is non-nil, only the media it contains are
+// considered, which is used to refine the results of a previous query.
+func (x *SearchIndex) search(query string, only map[*Media]*SearchMatch) []*SearchMatch {
+
+	q := []rune(strings.Join(strings.Fields(strings.ToLower(query)), ""))
+
+	x.mutex.Lock()
+	entry := x.entry
+	x.mutex.Unlock()
+
+	result := []*SearchMatch{}
+	for _, e := range entry {
+		if nil != only {
+			if _, ok := only[e.media]; !ok {
+				continue
+			}
+		}
+		if m := e.match(q); nil != m {
+			result = append(result, m)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].score > result[j].score
+	})
+	return result
+}
+
+// function fuzzyMatch() returns the score and positions of the characters of
+// the given query matched, in order, in the given text. each occurrence of the
+// query's first character is tried as the start of the match, and the best is
+// kept; the rest of the query is then matched greedily.
+func fuzzyMatch(text, query []rune) (int, []int, bool) {
+
+	if 0 == len(query) {
+		return 0, []int{}, true
+	}
+
+	bestScore, bestPos := 0, []int(nil)
+	for start := range text {
+		if text[start] != query[0] {
+			continue
+		}
+		pos := make([]int, 0, len(query))
+		for i, q := start, 0; i < len(text) && q < len(query); i++ {
+			if text[i] == query[q] {
+				pos = append(pos, i)
+				q++
+			}
+		}
+		if len(pos) < len(query) {
+			// no later start can match more of the query.
+			break
+		}
+		if score := fuzzyScore(text, pos); nil == bestPos || score > bestScore {
+			bestScore, bestPos = score, pos
+		}
+	}
+	return bestScore, bestPos, nil != bestPos
+}
+
+// function fuzzyScore() scores the characters matched at the given positions
+// of the given text: characters matched adjacently and at the start of words
+// score higher, and gaps between them score lower.
+func fuzzyScore(text []rune, pos []int) int {
+	score := 0
+	for i, p := range pos {
+		score += searchMatchScore
+		if p == 0 || !unicode.IsLetter(text[p-1]) && !unicode.IsDigit(text[p-1]) {
+			score += searchWordStart
+		}
+		if i > 0 {
+			if pos[i-1] == p-1 {
+				score += searchAdjacentMore
+			} else {
+				score -= searchGapPenalty
+			}
+		}
+	}
+	return score
+}
+
+// function highlight() returns the given text with the characters at the
+// given positions highlighted, escaping everything else from tview's color
+// tags.
+func highlight(text []rune, pos []int, from, to int) string {
+	var b strings.Builder
+	tag := fmt.Sprintf("[#%06x::b]", colorScheme.highlightPrimary.Hex())
+	p := 0
+	for p < len(pos) && pos[p] < from {
+		p++
+	}
+	plain := from
+	for i := from; i < to; i++ {
+		if p < len(pos) && pos[p] == i {
+			b.WriteString(tview.Escape(string(text[plain:i])))
+			b.WriteString(tag)
+			b.WriteString(tview.Escape(string(text[i])))
+			b.WriteString("[-::-]")
+			plain = i + 1
+			p++
+		}
+	}
+	b.WriteString(tview.Escape(string(text[plain:to])))
+	return b.String()
+}
+
+// function mainText() returns the text listed for a media item matched by the
+// search: its name with the characters matched highlighted, or, if matched in
+// another field, its name followed by the fragment of that field matched.
+func (m *SearchMatch) mainText(item *mediaItem) string {
+
+	if sfName == m.field && item.MainText == m.media.AbsName {
+		text := []rune(item.MainText)
+		return highlight(text, m.pos, 0, len(text))
+	}
+
+	text := []rune(m.field.text(m.media))
+	from, to := 0, len(text)
+	if len(m.pos) > 0 {
+		if from = m.pos[0] - searchContextRunes; from < 0 {
+			from = 0
+		}
+		if to = m.pos[len(m.pos)-1] + searchContextRunes; to > len(text) {
+			to = len(text)
+		}
+	}
+	fragment := highlight(text, m.pos, from, to)
+	if from > 0 {
+		fragment = "…" + fragment
+	}
+	if to < len(text) {
+		fragment = fragment + "…"
+	}
+	return fmt.Sprintf("%s  [#%06x]%s:[-] %s", tview.Escape(item.MainText),
+		colorScheme.inactiveText.Hex(), searchFieldName[m.field], fragment)
+}
+
+// type BrowserSearch is the search currently filtering a Browser.
+type BrowserSearch struct {
+	query   string
+	library *Library    // the library the Browser was filtered by before searching
+	field   *FieldQuery // the user-defined field searched, if any (see field.go)
+	match   map[*Media]*SearchMatch
+}
+
+// function isSearching() returns true if the Browser is filtered by a search.
+func (l *Browser) isSearching() bool {
+	return nil != l.search
+}
+
+// function applySearch() lists only the items matching the given query, best
+// matches first. if the query extends the previous one, only the previous
+// results are searched again.
+func (l *Browser) applySearch(index *SearchIndex, library *Library, query string) {
+
+	var only map[*Media]*SearchMatch
+	if nil != l.search {
+		library = l.search.library
+		if nil == l.search.field && strings.HasPrefix(query, l.search.query) {
+			only = l.search.match
+		}
+	}
+
+	result := index.search(query, only)
+	match := map[*Media]*SearchMatch{}
+	for _, m := range result {
+		match[m.media] = m
+	}
+	l.search = &BrowserSearch{query: query, library: library, field: nil, match: match}
+
+	// collapsed films are never listed on their own, and the results are
+	// limited to the library the Browser was filtered by.
+	item := map[*Media]*mediaItem{}
+	all := append(append([]*mediaItem{}, l.visibleItem...), l.hiddenItem...)
+	for _, m := range all {
+		item[m.Media] = m
+	}
+	visible, hidden := []*mediaItem{}, []*mediaItem{}
+	for _, m := range result {
+		if it, ok := item[m.media]; ok && nil == it.CollapsedIn &&
+			(nil == library || it.SourceLibrary == library) {
+			visible = append(visible, it)
+			delete(item, m.media)
+		}
+	}
+	for _, m := range all {
+		if _, ok := item[m.Media]; ok {
+			hidden = append(hidden, m)
+		}
+	}
+	l.visibleItem, l.hiddenItem = visible, hidden
+	l.currentItem, l.viewOffset = 0, 0
+	if len(l.visibleItem) > 0 && nil != l.changed {
+		it := l.visibleItem[0]
+		l.changed(0, it.MainText, it.SecondaryText)
+	}
+}
+
+// function searchInsert() moves the item of the given media, which was just
+// inserted in sorted position, to its position among the search results if it
+// matches the current search, or hides it otherwise.
+func (l *Browser) searchInsert(index *SearchIndex, media *Media) {
+
+	at, found := invalidIndex, false
+	for i, it := range l.visibleItem {
+		if it.Media == media {
+			at, found = i, true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	it := l.visibleItem[at]
+	if nil != l.search.field {
+		// media matching a user-defined field are listed in sorted order, so
+		// the item is already where it belongs if it matches.
+		if !l.search.field.matches(it.SourceLibrary, media) ||
+			(nil != l.search.library && it.SourceLibrary != l.search.library) {
+			l.visibleItem = append(l.visibleItem[:at], l.visibleItem[at+1:]...)
+			l.hiddenItem = append(l.hiddenItem, it)
+		}
+		return
+	}
+	l.visibleItem = append(l.visibleItem[:at], l.visibleItem[at+1:]...)
+
+	q := []rune(strings.Join(strings.Fields(strings.ToLower(l.search.query)), ""))
+	e := &SearchEntry{media: media}
+	m := e.match(q)
+	if nil == m || (nil != l.search.library && it.SourceLibrary != l.search.library) {
+		l.hiddenItem = append(l.hiddenItem, it)
+		return
+	}
+	l.search.match[media] = m
+	pos := len(l.visibleItem)
+	for i, v := range l.visibleItem {
+		if r, ok := l.search.match[v.Media]; ok && r.score < m.score {
+			pos = i
+			break
+		}
+	}
+	l.visibleItem = append(l.visibleItem, nil)
+	copy(l.visibleItem[pos+1:], l.visibleItem[pos:])
+	l.visibleItem[pos] = it
+}
+
+// function endSearch() lists every item of the library the Browser was filtered
+// by before searching, in sorted order.
+func (l *Browser) endSearch() {
+
+	if nil == l.search {
+		return
+	}
+	library := l.search.library
+	l.search = nil
+
+	all := append(append([]*mediaItem{}, l.visibleItem...), l.hiddenItem...)
+	visible, hidden := []*mediaItem{}, []*mediaItem{}
+	for _, m := range all {
+		if nil == m.CollapsedIn && (nil == library || m.SourceLibrary == library) {
+			visible = append(visible, m)
+		} else {
+			hidden = append(hidden, m)
+		}
+	}
+	l.sortItems(visible)
+	l.visibleItem, l.hiddenItem = visible, hidden
+	l.currentItem, l.viewOffset = 0, 0
+	if len(l.visibleItem) > 0 && nil != l.changed {
+		it := l.visibleItem[0]
+		l.changed(0, it.MainText, it.SecondaryText)
+	}
+}
+
+// type SearchView is the overlay in which the search query is typed. the media
+// browser is refined with every key typed; Enter returns to the media browser,
+// keeping the results, and Esc ends the search.
+type SearchView struct {
+	*tview.InputField
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// function newSearchView() allocates and initializes the overlay widgets.
+func newSearchView(ui *tview.Application, page string, lib []*Library) *SearchView {
+
+	v := SearchView{
+		InputField: nil,
+		layout:     nil,
+		focusPage:  page,
+		focusNext:  nil,
+		focusPrev:  nil,
+	}
+
+	input := tview.NewInputField().
+		SetLabel("/").
+		SetLabelColor(colorScheme.activeMenuText).
+		SetFieldTextColor(colorScheme.activeText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	input.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitle(" Search ").
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	input.SetChangedFunc(v.changed)
+	input.SetDoneFunc(v.done)
+
+	v.InputField = input
+
+	return &v
+}
+
+func (v *SearchView) desc() string { return "" }
+func (v *SearchView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *SearchView) page() string         { return v.focusPage }
+func (v *SearchView) next() FocusDelegator { return v.focusNext }
+func (v *SearchView) prev() FocusDelegator { return v.focusPrev }
+func (v *SearchView) focus() {
+	// the search may have ended elsewhere, e.g. by selecting a library.
+	if !v.layout.browseView.isSearching() {
+		v.SetText("")
+	}
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.InputField)
+}
+func (v *SearchView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function changed() refines the media browser with the query typed so far.
+func (v *SearchView) changed(text string) {
+	browser := v.layout.browseView.Browser
+	if "" == strings.TrimSpace(text) {
+		browser.endSearch()
+		return
+	}
+	library := v.layout.libSelect.library[v.layout.libSelect.selectedLibrary]
+	if query := parseFieldQuery(text, v.layout.lib); nil != query {
+		browser.applyFieldSearch(library, text, query)
+		return
+	}
+	browser.applySearch(v.layout.searchIndex, library, text)
+}
+
+// function done() returns to the media browser, ending the search if Esc was
+// pressed.
+func (v *SearchView) done(key tcell.Key) {
+	switch key {
+	case tcell.KeyEscape:
+		v.SetText("")
+		v.layout.browseView.endSearch()
+	case tcell.KeyEnter:
+		if n := len(v.layout.browseView.visibleItem); v.layout.browseView.isSearching() {
+			infoLog.logf("search %q: %d found", v.GetText(), n)
+		}
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function searchEvent() handles the key opening the search overlay. returns
+// true if the key was handled.
+func (l *Layout) searchEvent(ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || '/' != er {
+		return false
+	}
+	l.focusQueue <- l.searchView
+	return true
+}
@@ -0,0 +1,502 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: series.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the skip markers (intro, outro, recap, etc.) shared by all episodes
+//    of a TV series or of one of its seasons. the markers are stored in each
+//    library's database, edited from the media browser, and passed to the
+//    player as chapters so that the marked ranges can be skipped.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for series skip markers.
+const (
+	// name of the database collection containing all series skip markers.
+	seriesColName = "Series"
+
+	// permissions of the chapters files generated for the player.
+	seriesChaptersFilePerms = 0644
+)
+
+var (
+	// variable seriesIndex is the index on the series collection used to find
+	// the markers of a series/season.
+	seriesIndex = []string{"Key"}
+
+	// variable seriesNameSep matches the runs of punctuation commonly used in
+	// place of spaces in the file names of TV series, e.g. "Foo.Bar_Baz".
+	seriesNameSep = regexp.MustCompile(`[\s._-]+`)
+)
+
+// type SkipRange is a single named range of an episode that may be skipped. the
+// range is given either as a pair of positions or as a chapter number.
+type SkipRange struct {
+	Name    string        // e.g. "intro", "outro", "recap"
+	Start   time.Duration // position at which the range begins
+	End     time.Duration // position at which the range ends
+	Chapter int           // chapter comprising the range (1-based, 0 = use Start/End)
+}
+
+// type SeriesMarkers contains the skip ranges of all episodes of a series, or
+// of all episodes of one season of a series.
+type SeriesMarkers struct {
+	Key    string      // indexed key identifying the series and season
+	Series string      // normalized name of the series
+	Season int         // season number (0 = all seasons)
+	Skip   []SkipRange // ranges skipped in every episode
+}
+
+// function seriesOf() parses the series name and season number from the given
+// file base name, e.g. "Foo.Bar.S02E05.720p" is season 2 of "foo bar". returns
+// false if the name does not look like an episode of a series.
+func seriesOf(base string) (string, int, bool) {
+	for _, re := range episodePattern {
+		if loc := re.FindStringSubmatchIndex(base); nil != loc {
+			name := strings.ToLower(seriesNameSep.ReplaceAllString(base[:loc[0]], " "))
+			name = strings.TrimSpace(name)
+			if "" == name {
+				return "", 0, false
+			}
+			season, _ := strconv.Atoi(base[loc[2]:loc[3]])
+			return name, season, true
+		}
+	}
+	return "", 0, false
+}
+
+// function seriesKey() returns the indexed key identifying the given series
+// and season.
+func seriesKey(series string, season int) string {
+	return fmt.Sprintf("%s#%d", series, season)
+}
+
+// function formatPosition() formats the given position as "[H:]MM:SS".
+func formatPosition(pos time.Duration) string {
+	sec := int64(pos.Round(time.Second) / time.Second)
+	if sec >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", sec/3600, (sec/60)%60, sec%60)
+	}
+	return fmt.Sprintf("%02d:%02d", sec/60, sec%60)
+}
+
+// function parsePosition() parses a position given as seconds, "MM:SS", or
+// "H:MM:SS".
+func parsePosition(s string) (time.Duration, error) {
+	pos := time.Duration(0)
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if nil != err {
+			return 0, fmt.Errorf("invalid position: %q", s)
+		}
+		pos = pos*60 + time.Duration(n)*time.Second
+	}
+	return pos, nil
+}
+
+// function String() creates a string representation of the SkipRange in the
+// same form used to declare it.
+func (r SkipRange) String() string {
+	if r.Chapter > 0 {
+		return fmt.Sprintf("%s=ch%d", r.Name, r.Chapter)
+	}
+	return fmt.Sprintf("%s=%s-%s", r.Name, formatPosition(r.Start), formatPosition(r.End))
+}
+
+// function parseSkipRanges() parses a comma-separated list of skip ranges of
+// the form "NAME=START-END" or "NAME=chN", e.g. "intro=0:00-1:30,outro=ch5".
+func parseSkipRanges(spec string) ([]SkipRange, error) {
+
+	skip := []SkipRange{}
+	for _, decl := range strings.Split(spec, ",") {
+		if decl = strings.TrimSpace(decl); "" == decl {
+			continue
+		}
+		part := strings.SplitN(decl, "=", 2)
+		if len(part) != 2 || "" == strings.TrimSpace(part[0]) {
+			return nil, fmt.Errorf("skip range %q: expected NAME=START-END or NAME=chN", decl)
+		}
+		r := SkipRange{Name: strings.ToLower(strings.TrimSpace(part[0]))}
+		value := strings.ToLower(strings.TrimSpace(part[1]))
+		if strings.HasPrefix(value, "ch") {
+			n, err := strconv.Atoi(strings.TrimPrefix(value, "ch"))
+			if nil != err || n < 1 {
+				return nil, fmt.Errorf("skip range %q: invalid chapter: %q", decl, value)
+			}
+			r.Chapter = n
+		} else {
+			bound := strings.SplitN(value, "-", 2)
+			if len(bound) != 2 {
+				return nil, fmt.Errorf("skip range %q: expected START-END", decl)
+			}
+			var err error
+			if r.Start, err = parsePosition(bound[0]); nil != err {
+				return nil, fmt.Errorf("skip range %q: %s", decl, err)
+			}
+			if r.End, err = parsePosition(bound[1]); nil != err {
+				return nil, fmt.Errorf("skip range %q: %s", decl, err)
+			}
+			if r.End <= r.Start {
+				return nil, fmt.Errorf("skip range %q: end must follow start", decl)
+			}
+		}
+		skip = append(skip, r)
+	}
+	return skip, nil
+}
+
+// function String() creates a string representation of the SeriesMarkers in
+// the same form used to edit them.
+func (m *SeriesMarkers) String() string {
+	desc := make([]string, len(m.Skip))
+	for i, r := range m.Skip {
+		desc[i] = r.String()
+	}
+	return strings.Join(desc, ",")
+}
+
+// function toRecord() creates a struct capable of being stored in the database.
+func (m *SeriesMarkers) toRecord() (*EntityRecord, *ReturnCode) {
+
+	record := &EntityRecord{}
+	data, err := json.Marshal(m)
+	if nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Marshal(%s): cannot marshal SeriesMarkers struct into JSON object: %s", m, err)
+	}
+	if err = json.Unmarshal(data, record); nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Unmarshal(%s): cannot unmarshal JSON object into EntityRecord struct: %s", string(data), err)
+	}
+	return record, nil
+}
+
+// function findSeriesMarkers() returns the skip markers stored for the given
+// series and season, along with the hash key ID of their record. if none are
+// stored, returns nil and a negative ID.
+func (d *Database) findSeriesMarkers(series string, season int) (*SeriesMarkers, int, *ReturnCode) {
+
+	result := map[int]struct{}{}
+	key := seriesKey(series, season)
+	if err := evalQuery(map[string]interface{}{
+		"eq": key,
+		"in": []interface{}{seriesIndex[0]},
+	}, d.series, &result); nil != err {
+		return nil, -1, rcDatabaseError.specf(
+			"findSeriesMarkers(%q): %s: EvalQuery(): %s", key, d, err)
+	}
+	for id := range result {
+		read, err := d.series.Read(id)
+		if nil != err {
+			return nil, -1, rcDatabaseError.specf(
+				"findSeriesMarkers(%q): %s: Read(%d): %s", key, d, id, err)
+		}
+		data, _ := json.Marshal(read)
+		markers := &SeriesMarkers{}
+		if err := json.Unmarshal(data, markers); nil != err {
+			return nil, -1, rcInvalidJSONData.specf(
+				"findSeriesMarkers(%q): cannot unmarshal JSON object into SeriesMarkers struct: %s", key, err)
+		}
+		return markers, id, nil
+	}
+	return nil, -1, nil
+}
+
+// function seriesMarkers() returns the skip markers in effect for the given
+// series and season. markers stored for the specific season take precedence
+// over those stored for all seasons. returns nil if there are none.
+func (d *Database) seriesMarkers(series string, season int) (*SeriesMarkers, *ReturnCode) {
+
+	if season > 0 {
+		markers, _, ret := d.findSeriesMarkers(series, season)
+		if nil != ret || nil != markers {
+			return markers, ret
+		}
+	}
+	markers, _, ret := d.findSeriesMarkers(series, 0)
+	return markers, ret
+}
+
+// function saveSeriesMarkers() stores the given skip markers, replacing any
+// previously stored for the same series and season. if there are no skip
+// ranges, the stored markers are removed instead.
+func (d *Database) saveSeriesMarkers(markers *SeriesMarkers) *ReturnCode {
+
+	markers.Key = seriesKey(markers.Series, markers.Season)
+	_, id, ret := d.findSeriesMarkers(markers.Series, markers.Season)
+	if nil != ret {
+		return ret
+	}
+
+	if 0 == len(markers.Skip) {
+		if id >= 0 {
+			if err := d.series.Delete(id); nil != err {
+				return rcDatabaseError.specf(
+					"saveSeriesMarkers(%q): %s: Delete(%d): %s", markers.Key, d, id, err)
+			}
+		}
+		return nil
+	}
+
+	rec, ret := markers.toRecord()
+	if nil != ret {
+		return ret
+	}
+	if id >= 0 {
+		if err := d.series.Update(id, *rec); nil != err {
+			return rcDatabaseError.specf(
+				"saveSeriesMarkers(%q): %s: Update(%d): %s", markers.Key, d, id, err)
+		}
+	} else if _, err := d.series.Insert(*rec); nil != err {
+		return rcDatabaseError.specf(
+			"saveSeriesMarkers(%q): %s: Insert(): %s", markers.Key, d, err)
+	}
+	return nil
+}
+
+// function chaptersFile() writes the skip ranges given by position to a
+// chapters file (in ffmpeg's metadata format, which mpv reads with its option
+// "--chapters-file") in the given directory, returning the path to the file.
+// each range becomes a chapter named after it, so that the player can skip it.
+// returns an empty path if no ranges are given by position.
+func (m *SeriesMarkers) chaptersFile(dir string) (string, *ReturnCode) {
+
+	lines := []string{";FFMETADATA1"}
+	for _, r := range m.Skip {
+		if r.Chapter > 0 {
+			continue
+		}
+		lines = append(lines,
+			"[CHAPTER]",
+			"TIMEBASE=1/1000",
+			fmt.Sprintf("START=%d", r.Start/time.Millisecond),
+			fmt.Sprintf("END=%d", r.End/time.Millisecond),
+			fmt.Sprintf("title=%s", r.Name))
+	}
+	if 1 == len(lines) {
+		return "", nil
+	}
+
+	name := fmt.Sprintf("chapters-%s-%d.txt",
+		strings.Replace(m.Series, " ", "_", -1), m.Season)
+	path := filepath.Join(dir, name)
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if err := ioutil.WriteFile(path, data, seriesChaptersFilePerms); nil != err {
+		return "", rcInvalidFile.specf(
+			"chaptersFile(%q): ioutil.WriteFile(): %s", path, err)
+	}
+	return path, nil
+}
+
+// function playbackCommand() constructs the playback command for the given
+// video, appending the skip markers of its series (if any) to the command
+// constructed by function (*VideoMedia).playbackCommand(). an intro range
+// starting at the very beginning is skipped by starting playback at its end;
+// all other ranges are passed as chapters (see function chaptersFile()).
+func (l *Library) playbackCommand(video *VideoMedia) string {
+
+	cmd := video.playbackCommand()
+	series, season, ok := seriesOf(video.AbsBase)
+	if "" == cmd || !ok {
+		return cmd
+	}
+	markers, ret := l.db.seriesMarkers(series, season)
+	if nil != ret {
+		warnLog.log(ret)
+		return cmd
+	}
+	if nil == markers {
+		return cmd
+	}
+	for _, r := range markers.Skip {
+		if 0 == r.Chapter && 0 == r.Start {
+			cmd = fmt.Sprintf("%s --start=%s", cmd, formatPosition(r.End))
+			break
+		}
+	}
+	if path, ret := markers.chaptersFile(l.db.absPath); nil != ret {
+		warnLog.log(ret)
+	} else if "" != path {
+		cmd = fmt.Sprintf("%s --chapters-file=%s", cmd, quoteArg(path))
+	}
+	return cmd
+}
+
+//------------------------------------------------------------------------------
+
+// type SeriesMarkersView is the dialog used to edit the skip markers of the
+// series of the video currently selected in the media browser.
+type SeriesMarkersView struct {
+	*tview.Form
+	seasonInput *tview.InputField
+	skipInput   *tview.InputField
+	library     *Library
+	video       *VideoMedia
+	series      string
+	layout      *Layout
+	focusPage   string
+	focusNext   FocusDelegator
+	focusPrev   FocusDelegator
+}
+
+// function newSeriesMarkersView() allocates and initializes the dialog widgets.
+func newSeriesMarkersView(ui *tview.Application, page string, lib []*Library) *SeriesMarkersView {
+
+	v := SeriesMarkersView{
+		Form:        nil,
+		seasonInput: nil,
+		skipInput:   nil,
+		library:     nil,
+		video:       nil,
+		series:      "",
+		layout:      nil,
+		focusPage:   page,
+		focusNext:   nil,
+		focusPrev:   nil,
+	}
+
+	form := tview.NewForm().
+		AddInputField("Season (0 = all):", "", 4, tview.InputFieldInteger, nil).
+		AddInputField("Skip:", "", 50, nil, nil).
+		AddButton("Save", v.save).
+		AddButton("Cancel", v.cancel).
+		SetLabelColor(colorScheme.inactiveMenuText).
+		SetFieldTextColor(colorScheme.inactiveMenuText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	form.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.Form = form
+	v.seasonInput = form.GetFormItem(0).(*tview.InputField)
+	v.skipInput = form.GetFormItem(1).(*tview.InputField)
+
+	return &v
+}
+
+func (v *SeriesMarkersView) desc() string { return "" }
+func (v *SeriesMarkersView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *SeriesMarkersView) page() string         { return v.focusPage }
+func (v *SeriesMarkersView) next() FocusDelegator { return v.focusNext }
+func (v *SeriesMarkersView) prev() FocusDelegator { return v.focusPrev }
+func (v *SeriesMarkersView) focus() {
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.Form)
+}
+func (v *SeriesMarkersView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function setVideo() populates the dialog with the skip markers currently in
+// effect for the series of the given video.
+func (v *SeriesMarkersView) setVideo(lib *Library, video *VideoMedia, series string, season int) *ReturnCode {
+
+	markers, ret := lib.db.seriesMarkers(series, season)
+	if nil != ret {
+		return ret
+	}
+
+	v.library = lib
+	v.video = video
+	v.series = series
+
+	if nil == markers {
+		v.seasonInput.SetText(strconv.Itoa(season))
+		v.skipInput.SetText("")
+	} else {
+		v.seasonInput.SetText(strconv.Itoa(markers.Season))
+		v.skipInput.SetText(markers.String())
+	}
+	v.SetTitle(fmt.Sprintf(" Skip markers: [#%06x]%s ",
+		colorScheme.highlightPrimary.Hex(), series))
+	return nil
+}
+
+// function save() stores the dialog's skip markers in the library database.
+func (v *SeriesMarkersView) save() {
+
+	skip, err := parseSkipRanges(v.skipInput.GetText())
+	if nil != err {
+		warnLog.logf("(ignored) %s", err)
+		return
+	}
+	season, _ := strconv.Atoi(strings.TrimSpace(v.seasonInput.GetText()))
+	markers := &SeriesMarkers{Series: v.series, Season: season, Skip: skip}
+	if ret := v.library.db.saveSeriesMarkers(markers); nil != ret {
+		warnLog.log(ret)
+	} else {
+		infoLog.logf("saved skip markers: %s", v.library.playbackCommand(v.video))
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function cancel() closes the dialog without modifying anything.
+func (v *SeriesMarkersView) cancel() {
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function seriesEvent() handles the key opening the skip markers dialog for
+// the series of the video currently selected in the media browser. returns
+// true if the key was handled.
+func (l *Layout) seriesEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'S' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("edit skip markers"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item || mkVideo != item.Kind {
+		warnLog.logf("(ignored) skip markers may only be edited for video")
+		return true
+	}
+	series, season, ok := seriesOf(item.AbsBase)
+	if !ok {
+		warnLog.logf("(ignored) not an episode of a series (expected e.g. S01E02): %s", item.AbsName)
+		return true
+	}
+	// the full video (with its selected tracks) is only needed to show the
+	// resulting playback command, so fall back on the browser's media.
+	video := &VideoMedia{Media: item.Media}
+	if record, known := l.browseView.record[item.Media]; known {
+		if v, isVideo := record.rec.(*VideoMedia); isVideo {
+			video = v
+		}
+	}
+	if ret := l.seriesMarkers.setVideo(item.SourceLibrary, video, series, season); nil != ret {
+		warnLog.log(ret)
+		return true
+	}
+	l.focusQueue <- l.seriesMarkers
+	return true
+}
@@ -0,0 +1,338 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: settings.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the database settings page, which shows the database
+//    configuration in effect for each library along with the size of its
+//    database directory, and offers the database maintenance actions. the
+//    database configuration cannot be changed once a database is created, so
+//    only the settings which are safe to change at runtime may be edited.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// type DatabaseAction identifies one of the database maintenance actions.
+type DatabaseAction int
+
+const (
+	daUnknown DatabaseAction = iota - 1 // = -1
+	daScrub                             // =  0
+	daRebuild                           // =  1
+	daVerify                            // =  2
+	daMigrate                           // =  3
+	daCOUNT                             // =  4
+)
+
+var (
+	databaseActionName = [daCOUNT]string{
+		"Scrub", "Rebuild", "Verify", "Migrate",
+	}
+	databaseActionDesc = [daCOUNT]string{
+		"remove corrupt records and reclaim unused space",
+		"remove and reinstall all indices",
+		"check that every record can be read",
+		"copy all records into the other storage engine (" + dbEngineTiedot + " or " + dbEngineBolt + ")",
+	}
+)
+
+// function maintain() performs the given maintenance action on the library's
+// database. the library may not be loaded or scanned while it is maintained,
+// so the action is refused with rcLibraryBusy if either is in progress.
+func (l *Library) maintain(action DatabaseAction) *ReturnCode {
+
+	if l.db.isSimulated() {
+		return rcInvalidDatabase.specf("maintain(): library is simulated: %q", l.name)
+	}
+	if _, _, loading := l.loadProgress(); loading {
+		return rcLibraryBusy.specf("maintain(): library is loading: %q", l.name)
+	}
+
+	// occupy the scanner semaphore, so that no scan may begin until finished.
+	select {
+	case l.scanStart <- time.Now():
+		defer func() { <-l.scanStart }()
+	default:
+		return rcLibraryBusy.specf("maintain(): library is scanning: %q", l.name)
+	}
+
+	if !isCLIMode {
+		l.busyState.inc()
+		defer l.busyState.dec()
+	}
+
+	start := time.Now()
+	infoLog.logf("%s database: %q", strings.ToLower(databaseActionName[action]), l.name)
+
+	switch action {
+	case daScrub:
+		l.db.scrub()
+	case daRebuild:
+		if ret := l.db.rebuild(); nil != ret {
+			return ret
+		}
+	case daVerify:
+		total, corrupt := l.db.verify()
+		if corrupt > 0 {
+			warnLog.logf("%d of %d database records are corrupt (scrub the database to remove them): %q",
+				corrupt, total, l.name)
+		} else {
+			infoLog.logf("all %d database records are intact: %q", total, l.name)
+		}
+	case daMigrate:
+		engine := dbEngineBolt
+		if dbEngineBolt == l.db.engine {
+			engine = dbEngineTiedot
+		}
+		count, ret := l.db.migrate(engine)
+		if nil != ret {
+			return ret
+		}
+		infoLog.logf("migrated %d database records to the %s engine: %q", count, engine, l.name)
+	default:
+		return rcInvalidArgs.specf("maintain(%d): unknown action: %q", action, l.name)
+	}
+
+	infoLog.logf("finished %s database: %q (%s)",
+		strings.ToLower(databaseActionName[action]), l.name,
+		time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// type SettingsView is the page showing the database configuration of a
+// selected library, along with its maintenance actions.
+type SettingsView struct {
+	*tview.Pages
+	info      *tview.TextView
+	form      *tview.Form
+	confirm   *tview.Modal
+	lib       []*Library
+	name      []string
+	selected  int
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// function newSettingsView() allocates and initializes the settings page.
+func newSettingsView(ui *tview.Application, page string, lib []*Library) *SettingsView {
+
+	v := SettingsView{
+		Pages:     tview.NewPages(),
+		info:      tview.NewTextView(),
+		form:      tview.NewForm(),
+		confirm:   tview.NewModal(),
+		lib:       lib,
+		name:      makeUniqueLibraryNames(lib),
+		selected:  0,
+		layout:    nil,
+		focusPage: page,
+		focusNext: nil,
+		focusPrev: nil,
+	}
+
+	v.info.
+		SetDynamicColors(true).
+		SetWrap(true).
+		SetTextColor(colorScheme.activeText)
+
+	v.form.
+		AddDropDown("Library:", v.name, 0, v.selectLibrary).
+		AddCheckbox("Full scan:", false, v.setFullScan)
+	for action := DatabaseAction(0); action < daCOUNT; action++ {
+		a := action
+		v.form.AddButton(databaseActionName[a], func() { v.ask(a) })
+	}
+	v.form.
+		AddButton("Close", v.close).
+		SetLabelColor(colorScheme.inactiveMenuText).
+		SetFieldTextColor(colorScheme.inactiveMenuText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	body := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(v.info, 0, 1, false).
+		AddItem(v.form, 7, 0, true)
+
+	body.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitle(" Database Settings ").
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.
+		AddPage("body", body, true, true).
+		AddPage("confirm", v.confirm, false, false)
+
+	return &v
+}
+
+func (v *SettingsView) desc() string { return "" }
+func (v *SettingsView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *SettingsView) page() string         { return v.focusPage }
+func (v *SettingsView) next() FocusDelegator { return v.focusNext }
+func (v *SettingsView) prev() FocusDelegator { return v.focusPrev }
+func (v *SettingsView) focus() {
+	page := v.page()
+	v.refresh()
+	v.HidePage("confirm")
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.form)
+}
+func (v *SettingsView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function library() returns the library currently selected, or nil if there
+// are no libraries.
+func (v *SettingsView) library() *Library {
+	if v.selected < 0 || v.selected >= len(v.lib) {
+		return nil
+	}
+	return v.lib[v.selected]
+}
+
+// function selectLibrary() is the event handler for the library drop-down.
+func (v *SettingsView) selectLibrary(option string, optionIndex int) {
+	v.selected = optionIndex
+	// the drop-down may report its initial option while it is created, before
+	// the checkbox following it was added to the form.
+	if v.form.GetFormItemCount() < 2 {
+		return
+	}
+	if lib := v.library(); nil != lib {
+		v.form.GetFormItem(1).(*tview.Checkbox).SetChecked(lib.fullScan)
+	}
+	v.refresh()
+}
+
+// function setFullScan() is the event handler for the full scan checkbox. it
+// only affects scans of the selected library started from now on.
+func (v *SettingsView) setFullScan(checked bool) {
+	if lib := v.library(); nil != lib {
+		lib.fullScan = checked
+	}
+}
+
+// function refresh() rewrites the description of the selected library's
+// database.
+func (v *SettingsView) refresh() {
+
+	lib := v.library()
+	if nil == lib {
+		v.info.SetText("no libraries")
+		return
+	}
+
+	label := func(s string) string {
+		return fmt.Sprintf("[#%06x]%-18s[-]", colorScheme.inactiveText.Hex(), s)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", label("Library:"), tview.Escape(lib.absPath))
+	if lib.db.isSimulated() {
+		fmt.Fprintf(&b, "%s (none, simulated library)\n", label("Database:"))
+		v.info.SetText(b.String()).ScrollToBeginning()
+		return
+	}
+	fmt.Fprintf(&b, "%s %s\n", label("Database:"), tview.Escape(lib.db.absPath))
+	fmt.Fprintf(&b, "%s %s\n", label("Storage engine:"), lib.db.engine)
+	if size, ret := lib.db.diskUsage(); nil != ret {
+		warnLog.trace(ret)
+		fmt.Fprintf(&b, "%s (unknown)\n", label("Size on disk:"))
+	} else {
+		fmt.Fprintf(&b, "%s %s\n", label("Size on disk:"), formatSize(size))
+	}
+
+	// the buffer sizes are read from the database itself, since they cannot
+	// be changed after it was created (see function newDatabase()). they only
+	// apply to tiedot.
+	if dbEngineTiedot != lib.db.engine {
+		// nothing to configure.
+	} else if jdc, ret := lib.db.config(); nil != ret {
+		warnLog.trace(ret)
+		fmt.Fprintf(&b, "%s (unknown)\n", label("Configuration:"))
+	} else {
+		fmt.Fprintf(&b, "%s %s\n", label("Max record size:"), formatSize(uint64(jdc.MaxRecordSize)))
+		fmt.Fprintf(&b, "%s %s (read-only)\n", label("Disk buffer size:"), formatSize(uint64(jdc.DiskBufferSize)))
+		fmt.Fprintf(&b, "%s %s (read-only)\n", label("Hash buffer size:"), formatSize(uint64(jdc.HashBufferSize)))
+		fmt.Fprintf(&b, "%s %d\n", label("Hash bucket size:"), jdc.HashBucketSize)
+		fmt.Fprintf(&b, "%s %d\n", label("Hashed bits:"), jdc.HashedBitsSize)
+		fmt.Fprintf(&b, "%s %d\n", label("Hash buckets:"), jdc.NumHashBuckets)
+	}
+
+	records := uint64(0)
+	name, col := lib.db.allCols()
+	for _, c := range col {
+		records += uint64(c.ApproxDocCount())
+	}
+	fmt.Fprintf(&b, "%s ~%d in %d collections\n", label("Records:"), records, len(name))
+
+	v.info.SetText(b.String()).ScrollToBeginning()
+}
+
+// function ask() asks the user to confirm the given maintenance action on the
+// selected library's database before performing it.
+func (v *SettingsView) ask(action DatabaseAction) {
+
+	lib := v.library()
+	if nil == lib {
+		return
+	}
+	if v.layout.busy.count() > 0 {
+		warnLog.logf(busyMessage("maintain the database"))
+		return
+	}
+
+	button := []string{databaseActionName[action], "Cancel"}
+	v.confirm.
+		ClearButtons().
+		SetText(fmt.Sprintf("%s the database of %q?\n(%s)",
+			databaseActionName[action], lib.name, databaseActionDesc[action])).
+		AddButtons(button).
+		SetDoneFunc(
+			func(buttonIndex int, buttonLabel string) {
+				v.HidePage("confirm")
+				v.layout.ui.SetFocus(v.form)
+				if button[0] == buttonLabel {
+					go v.perform(lib, action)
+				}
+			})
+
+	v.ShowPage("confirm")
+	v.layout.ui.SetFocus(v.confirm)
+}
+
+// function perform() performs the given maintenance action, and then updates
+// the page. this must not be called from the UI goroutine, since the action
+// may take a long time to complete.
+func (v *SettingsView) perform(lib *Library, action DatabaseAction) {
+	if ret := lib.maintain(action); nil != ret {
+		warnLog.log(ret)
+	}
+	v.layout.ui.QueueUpdateDraw(v.refresh)
+}
+
+// function close() closes the settings page.
+func (v *SettingsView) close() {
+	v.layout.focusQueue <- v.layout.focusBase
+}
@@ -0,0 +1,244 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: simulate.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the simulation mode, which replaces the libraries with synthetic
+//    ones whose loader and scanner discover fake media at a fixed rate. no
+//    file system is traversed and no database is opened, and the media
+//    discovered are the same on every run, so the behavior of the UI under
+//    load (drawing, flicker, busy indicators) can be developed and profiled
+//    reproducibly.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"sync/atomic"
+	"time"
+)
+
+// local unexported constants for the simulation mode.
+const (
+	defaultSimRate  = 200  // default number of discoveries per second per library
+	defaultSimCount = 5000 // default number of discoveries per library
+	simLibraryRoot  = "/simulated"
+)
+
+var (
+	// variable simTitle are the titles from which the names of simulated
+	// media are composed.
+	simTitle = []string{
+		"Aurora", "Black Harbor", "Copper Hill", "Dead Reckoning", "Echo Park",
+		"Far Meadow", "Glass Tide", "Hollow Point", "Iron Bridge", "Juniper",
+		"Kingfisher", "Lantern", "Marigold", "Northbound", "Overture", "Paper Moon",
+	}
+)
+
+// type simFileInfo is the os.FileInfo of a simulated media file, which does
+// not exist on any file system.
+type simFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i *simFileInfo) Name() string       { return i.name }
+func (i *simFileInfo) Size() int64        { return i.size }
+func (i *simFileInfo) Mode() os.FileMode  { return 0644 }
+func (i *simFileInfo) ModTime() time.Time { return i.modTime }
+func (i *simFileInfo) IsDir() bool        { return false }
+func (i *simFileInfo) Sys() interface{}   { return nil }
+
+// function newSimDatabase() creates a Database with no backing data store. only
+// its record counters are used, by the views summarizing each library.
+func newSimDatabase(abs string) *Database {
+	d := &Database{
+		absPath: "",
+		libPath: abs,
+		name:    path.Base(abs),
+		dataDir: "",
+		store:   nil,
+	}
+	for class := EntityClass(0); class < ecCOUNT; class++ {
+		numCol := len(entityColName[class])
+		d.col[class] = make([]Collection, numCol)
+		d.colName[class] = make([]string, numCol)
+		d.numRecordsLoad[class] = make([]uint, numCol)
+		d.numRecordsScan[class] = make([]uint, numCol)
+		copy(d.colName[class], entityColName[class])
+	}
+	return d
+}
+
+// function isSimulated() returns true if the database has no backing data
+// store, i.e. it belongs to a simulated library.
+func (d *Database) isSimulated() bool {
+	return nil == d.store
+}
+
+// function newSimLibrary() creates a simulated library for each positional
+// argument on the command line, which are only used as library names. a single
+// library is created if none were given.
+func newSimLibrary(opt *Options, busy *BusyState) []*Library {
+
+	name := opt.Args()
+	if 0 == len(name) {
+		name = []string{"simulated"}
+	}
+
+	library := []*Library{}
+	for _, n := range name {
+		abs := path.Join(simLibraryRoot, path.Base(n))
+		lib := &Library{
+			workingDir: simLibraryRoot,
+			absPath:    abs,
+			name:       path.Base(abs),
+			maxDepth:   depthUnlimited,
+
+			dataDir: "",
+			db:      newSimDatabase(abs),
+
+			busyState: busy,
+			tui:       nil,
+
+			loadComplete: make(chan interface{}),
+			loadStart:    make(chan time.Time, maxLibraryScanners),
+			scanComplete: make(chan interface{}),
+			scanStart:    make(chan time.Time, maxLibraryScanners),
+
+			fields:     []*CustomField{},
+			retryStats: &RetryStats{},
+
+			feed:   newDiscoveryFeed(),
+			issues: newIssueLog(),
+		}
+		lib.assoc = newSubsAssoc(opt, lib)
+		infoLog.verbosef("using simulated library: %s", lib)
+		library = append(library, lib)
+	}
+	return library
+}
+
+// type simFeeder generates the fake media of a single simulated library.
+type simFeeder struct {
+	lib  *Library
+	rand *rand.Rand
+	tick <-chan time.Time // nil if discoveries are not rate limited
+	seq  uint
+}
+
+// function next() waits until the next discovery is due, and then returns a
+// new fake media object.
+func (f *simFeeder) next() (*Media, interface{}) {
+
+	if nil != f.tick {
+		<-f.tick
+	}
+	f.seq++
+
+	title := simTitle[f.rand.Intn(len(simTitle))]
+	info := &simFileInfo{
+		size:    1<<20 + f.rand.Int63n(1<<32),
+		modTime: time.Date(2000+f.rand.Intn(26), time.Month(1+f.rand.Intn(12)), 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	if f.rand.Intn(5) < 2 {
+		info.name = fmt.Sprintf("%02d %s %d.mp3", 1+f.rand.Intn(20), title, f.seq)
+		rel := path.Join("Audio", title, info.name)
+		audio := newAudioMedia(f.lib, path.Join(f.lib.absPath, rel), rel, ".mp3", "MPEG Layer III", info)
+		return audio.Media, audio
+	}
+	info.name = fmt.Sprintf("%s S%02dE%02d %d.mkv", title, 1+f.rand.Intn(5), 1+f.rand.Intn(24), f.seq)
+	rel := path.Join("Video", title, info.name)
+	video := newVideoMedia(f.lib, path.Join(f.lib.absPath, rel), rel, ".mkv", "Matroska", info)
+	return video.Media, video
+}
+
+// function simulateLibrary() spawns a goroutine per simulated library which
+// feeds its discoveries as a real library's loader and scanner would: the
+// first half is "loaded" with a progress count, and the second half is
+// "scanned" while the library is busy. the library's scanComplete channel is
+// written to once all discoveries were made.
+func simulateLibrary(options *Options, library []*Library) *ReturnCode {
+
+	if 0 == len(library) {
+		return rcInvalidArgs.spec("simulateLibrary(): no libraries provided")
+	}
+
+	rate, count := options.SimRate.uint, options.SimCount.uint
+	infoLog.logf("simulating %d discoveries per library at %s", count, simRateString(rate))
+
+	for i, lib := range library {
+		go func(l *Library, seed int64) {
+
+			f := &simFeeder{lib: l, rand: rand.New(rand.NewSource(seed))}
+			if rate > 0 {
+				ticker := time.NewTicker(time.Second / time.Duration(rate))
+				defer ticker.Stop()
+				f.tick = ticker.C
+			}
+			feed := func(m DiscoveryMethod, n uint) {
+				for ; n > 0; n-- {
+					media, obj := f.next()
+					if dmLoad == m {
+						l.db.numRecordsLoad[ecMedia][media.Kind]++
+						atomic.AddUint64(&l.loadedCount, 1)
+					} else {
+						l.db.numRecordsScan[ecMedia][media.Kind]++
+					}
+					l.addIndexedSize(media.Size)
+					// no record ID is given, since there is no record to
+					// modify; the media are shown but cannot be edited.
+					if !isCLIMode {
+						l.discover(newDiscovery(obj))
+					}
+				}
+			}
+
+			// load
+			start := time.Now()
+			atomic.StoreUint64(&l.loadedCount, 0)
+			atomic.StoreUint64(&l.loadedTotal, uint64(count/2)+1)
+			feed(dmLoad, count/2)
+			atomic.StoreUint64(&l.loadedTotal, 0)
+			l.loadElapsed = time.Since(start)
+			_, summary := l.db.totalRecordsString(dmLoad, -1, -1)
+			infoLog.verbosef("simulated load: %q: %s (%s)", l.name, summary, l.loadElapsed.Round(time.Millisecond))
+
+			// scan
+			l.scanStart <- time.Now()
+			if !isCLIMode {
+				l.busyState.inc()
+			}
+			feed(dmScan, count-count/2)
+			l.lastScan = time.Now()
+			l.scanElapsed = time.Since(<-l.scanStart)
+			if !isCLIMode {
+				l.busyState.dec()
+			}
+			_, summary = l.db.totalRecordsString(dmScan, -1, -1)
+			infoLog.verbosef("simulated scan: %q: %s (%s)", l.name, summary, l.scanElapsed.Round(time.Millisecond))
+
+			l.scanComplete <- count
+		}(lib, int64(1+i))
+	}
+	return nil
+}
+
+// function simRateString() creates a human-readable description of the given
+// simulated discovery rate.
+func simRateString(rate uint) string {
+	if 0 == rate {
+		return "an unlimited rate"
+	}
+	return fmt.Sprintf("%d per second", rate)
+}
@@ -0,0 +1,349 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: snapshot.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines library snapshots and the change log between them. after each
+//    scan, a compact snapshot of every indexable file in the library is stored
+//    alongside the library database and compared with the previous snapshot,
+//    recording what was added, removed, moved, or modified since then.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ardnew.com/goutil"
+)
+
+// local unexported constants for library snapshots.
+const (
+	snapshotFileName  = "snapshot.json"
+	changeLogFileName = "changelog.json"
+	snapshotFilePerms = 0644
+
+	// max number of change logs retained per library (oldest are discarded).
+	maxChangeLogs = 20
+
+	// max number of file paths listed per category when printing a change log.
+	changeLogListSize = 25
+)
+
+// type SnapshotEntry is the compact state of a single file in a snapshot.
+type SnapshotEntry struct {
+	Size    int64 `json:"s"` // length in bytes
+	ModTime int64 `json:"m"` // modification time (unix seconds)
+}
+
+// type LibrarySnapshot is the state of every indexable file in a library at the
+// time it was scanned, keyed by path relative to the library root.
+type LibrarySnapshot struct {
+	Time  time.Time
+	Entry map[string]SnapshotEntry
+}
+
+// type SnapshotMove describes a file that was moved or renamed.
+type SnapshotMove struct {
+	From string
+	To   string
+}
+
+// type ChangeLog lists the differences between two consecutive snapshots.
+type ChangeLog struct {
+	From     time.Time
+	To       time.Time
+	Added    []string
+	Removed  []string
+	Moved    []SnapshotMove
+	Modified []string
+}
+
+// function takeSnapshot() traverses the library's file system, recording the
+// state of every file that a scan would index. the traversal follows the same
+// rules as function scanDive(): symlinks and special files are skipped, and
+// directories deeper than the library's max depth are not traversed.
+func (l *Library) takeSnapshot() *LibrarySnapshot {
+
+	snap := &LibrarySnapshot{
+		Time:  time.Now(),
+		Entry: map[string]SnapshotEntry{},
+	}
+
+	root := strings.Count(l.absPath, pathSep)
+	filepath.Walk(l.absPath,
+		func(p string, info os.FileInfo, err error) error {
+			if nil != err {
+				if nil != info && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// operating system metadata is never scanned (see ignore.go).
+			if p != l.absPath && l.isIgnored(p) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			mode := info.Mode()
+			switch {
+			case (mode & os.ModeDir) > 0:
+				if p == l.db.absPath {
+					return filepath.SkipDir
+				}
+				depth := uint(strings.Count(p, pathSep)-root) + 1
+				if depthUnlimited != l.maxDepth && depth > l.maxDepth {
+					return filepath.SkipDir
+				}
+				return nil
+			case (mode & (os.ModeSymlink | os.ModeDevice | os.ModeNamedPipe | os.ModeSocket | os.ModeCharDevice)) > 0:
+				return nil
+			}
+			ext := path.Ext(p)
+			mk, _ := mediaKindOfFile(p, ext)
+			sk, _ := supportKindOfFile(p, ext)
+			if mkUnknown == mk && skUnknown == sk {
+				return nil
+			}
+			if rel, err := filepath.Rel(l.absPath, p); nil == err {
+				snap.Entry[rel] = SnapshotEntry{
+					Size:    info.Size(),
+					ModTime: info.ModTime().Unix(),
+				}
+			}
+			return nil
+		})
+
+	return snap
+}
+
+// function diffSnapshot() compares two snapshots, returning the changes made
+// to get from the previous snapshot to the current one. a file removed from
+// one path and added at another with the same name, size, and modification
+// time is considered moved.
+func diffSnapshot(prev, curr *LibrarySnapshot) *ChangeLog {
+
+	change := &ChangeLog{
+		From:     prev.Time,
+		To:       curr.Time,
+		Added:    []string{},
+		Removed:  []string{},
+		Moved:    []SnapshotMove{},
+		Modified: []string{},
+	}
+
+	for p, c := range curr.Entry {
+		if e, ok := prev.Entry[p]; !ok {
+			change.Added = append(change.Added, p)
+		} else if e != c {
+			change.Modified = append(change.Modified, p)
+		}
+	}
+	for p := range prev.Entry {
+		if _, ok := curr.Entry[p]; !ok {
+			change.Removed = append(change.Removed, p)
+		}
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	sort.Strings(change.Modified)
+
+	// pair each removed file with an added file of the same identity.
+	added := []string{}
+	for _, a := range change.Added {
+		moved := false
+		for i, r := range change.Removed {
+			if filepath.Base(a) == filepath.Base(r) && curr.Entry[a] == prev.Entry[r] {
+				change.Moved = append(change.Moved, SnapshotMove{From: r, To: a})
+				change.Removed = append(change.Removed[:i], change.Removed[i+1:]...)
+				moved = true
+				break
+			}
+		}
+		if !moved {
+			added = append(added, a)
+		}
+	}
+	change.Added = added
+
+	return change
+}
+
+// function isEmpty() returns true if the change log contains no changes.
+func (c *ChangeLog) isEmpty() bool {
+	return 0 == len(c.Added)+len(c.Removed)+len(c.Moved)+len(c.Modified)
+}
+
+// function String() creates a string summarizing the number of changes of each
+// kind in the change log.
+func (c *ChangeLog) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d moved, %d modified",
+		len(c.Added), len(c.Removed), len(c.Moved), len(c.Modified))
+}
+
+// function readJSONFile() unmarshals the JSON file at the given path into the
+// given value. returns false if the file does not exist.
+func readJSONFile(p string, v interface{}) (bool, *ReturnCode) {
+
+	if exists, _ := goutil.PathExists(p); !exists {
+		return false, nil
+	}
+	data, err := ioutil.ReadFile(p)
+	if nil != err {
+		return false, rcInvalidFile.specf(
+			"readJSONFile(%q): ioutil.ReadFile(): %s", p, err)
+	}
+	if err := json.Unmarshal(data, v); nil != err {
+		return false, rcInvalidJSONData.specf(
+			"readJSONFile(%q): json.Unmarshal(): %s", p, err)
+	}
+	return true, nil
+}
+
+// function writeJSONFile() marshals the given value into the JSON file at the
+// given path.
+func writeJSONFile(p string, v interface{}) *ReturnCode {
+
+	data, err := json.Marshal(v)
+	if nil != err {
+		return rcInvalidJSONData.specf(
+			"writeJSONFile(%q): json.Marshal(): %s", p, err)
+	}
+	if err := ioutil.WriteFile(p, data, snapshotFilePerms); nil != err {
+		return rcInvalidFile.specf(
+			"writeJSONFile(%q): ioutil.WriteFile(): %s", p, err)
+	}
+	return nil
+}
+
+// function readChangeLogs() reads all change logs retained in the given
+// library database directory, oldest first.
+func readChangeLogs(dbPath string) ([]*ChangeLog, *ReturnCode) {
+	logs := []*ChangeLog{}
+	if _, ret := readJSONFile(filepath.Join(dbPath, changeLogFileName), &logs); nil != ret {
+		return nil, ret
+	}
+	return logs, nil
+}
+
+// function recordSnapshot() takes a new snapshot of the library, appends the
+// changes since the previous snapshot (if any) to the library's change logs,
+// and then replaces the previous snapshot with the new one.
+func (l *Library) recordSnapshot() *ReturnCode {
+
+	snapPath := filepath.Join(l.db.absPath, snapshotFileName)
+	logPath := filepath.Join(l.db.absPath, changeLogFileName)
+
+	curr := l.takeSnapshot()
+	prev := &LibrarySnapshot{}
+	found, ret := readJSONFile(snapPath, prev)
+	if nil != ret {
+		// a corrupt snapshot only costs us one change log; start over.
+		warnLog.trace(ret)
+		found = false
+	}
+
+	if found {
+		change := diffSnapshot(prev, curr)
+		l.recordMoves(change)
+		if !change.isEmpty() {
+			logs, ret := readChangeLogs(l.db.absPath)
+			if nil != ret {
+				warnLog.trace(ret)
+				logs = []*ChangeLog{}
+			}
+			logs = append(logs, change)
+			if len(logs) > maxChangeLogs {
+				logs = logs[len(logs)-maxChangeLogs:]
+			}
+			if ret := writeJSONFile(logPath, logs); nil != ret {
+				return ret
+			}
+		}
+		infoLog.verbosef("changes since last scan: %q (%s)", l.name, change)
+	}
+
+	return writeJSONFile(snapPath, curr)
+}
+
+// function print() writes the change log to the raw logger, listing at most
+// changeLogListSize paths of each kind of change.
+func (c *ChangeLog) print() {
+
+	rawLog.logf("%s -> %s: %s",
+		formatTime(c.From), formatTime(c.To), c)
+
+	list := func(desc string, p []string) {
+		for i, s := range p {
+			if i == changeLogListSize {
+				rawLog.logf("    (%d more %s)", len(p)-i, desc)
+				break
+			}
+			rawLog.logf("    %s: %s", desc, s)
+		}
+	}
+	list("added", c.Added)
+	list("removed", c.Removed)
+	moved := make([]string, len(c.Moved))
+	for i, m := range c.Moved {
+		moved[i] = fmt.Sprintf("%s -> %s", m.From, m.To)
+	}
+	list("moved", moved)
+	list("modified", c.Modified)
+}
+
+// function changesCommand() implements the "changes" command, printing the
+// most recent change logs of a library (all that are retained by default).
+func changesCommand(options *Options, args []string) *ReturnCode {
+
+	if len(args) < 1 || len(args) > 2 {
+		return rcInvalidArgs.spec("changes: expected LIBRARY [COUNT]")
+	}
+	abs, err := libraryPath(args[0])
+	if nil != err {
+		return rcInvalidLibrary.specf("changes(%q): libraryPath(): %s", args[0], err)
+	}
+	count := maxChangeLogs
+	if 2 == len(args) {
+		if count, err = strconv.Atoi(args[1]); nil != err || count < 1 {
+			return rcInvalidArgs.specf("changes: invalid COUNT: %q", args[1])
+		}
+	}
+
+	_, _, dbPath := libraryDatabasePath(options, abs)
+	if exists, _ := goutil.PathExists(dbPath); !exists {
+		return rcInvalidLibrary.specf("changes(%q): library has never been scanned", abs)
+	}
+	logs, ret := readChangeLogs(dbPath)
+	if nil != ret {
+		return ret
+	}
+
+	rawLog.logf("%s", abs)
+	if 0 == len(logs) {
+		rawLog.log("  no changes recorded (changes are recorded from the second scan onward)")
+		return nil
+	}
+	if len(logs) > count {
+		logs = logs[len(logs)-count:]
+	}
+	// newest first, since that answers "what changed recently?"
+	for i := len(logs) - 1; i >= 0; i-- {
+		logs[i].print()
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: sniff.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the classification of files by their content, for files without
+//    a file name extension or with an unknown one (e.g. files saved from a web
+//    page, or downloaded under their ID). if enabled for a library (with
+//    option -sniff), the first bytes of such files are compared with the
+//    signatures (magic numbers) of the audio, video and subtitles formats
+//    known, and files matching are classified as if they had the extension
+//    of the format matched, instead of being ignored.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// local unexported constants for the classification of files by content.
+const (
+	sniffAudio     = "audio"
+	sniffVideo     = "video"
+	sniffSubtitles = "subtitles"
+	sniffProbeSize = 4096 // bytes read from the beginning of each file
+)
+
+var (
+	// variable sniffKindName lists the kinds of files which may be classified
+	// by their content.
+	sniffKindName = []string{sniffAudio, sniffVideo, sniffSubtitles}
+
+	// variable sniffSRT matches the beginning of a SubRip subtitles file.
+	sniffSRT = regexp.MustCompile(`^\s*\d+\s*\r?\n\d{1,2}:\d{2}:\d{2}[,.]\d{1,3}\s*-->`)
+
+	// variable sniffMicroDVD matches the beginning of a MicroDVD subtitles file.
+	sniffMicroDVD = regexp.MustCompile(`^\{\d+\}\{\d*\}`)
+)
+
+// type SniffRule is a parsed content classification declaration of the form
+// "KIND[,KIND...][@LIBRARY]".
+type SniffRule struct {
+	kind  []string      // kinds of files classified by content
+	scope *LibraryScope // only library affected (empty = all)
+}
+
+// function parseSniffRule() parses a content classification declaration of the
+// form "KIND[,KIND...][@LIBRARY]", where KIND is audio, video or subtitles.
+func parseSniffRule(spec string) (*SniffRule, error) {
+
+	decl, scope := parseLibraryScope(spec)
+	rule := &SniffRule{kind: []string{}, scope: scope}
+
+	for _, k := range strings.Split(decl, ",") {
+		k = strings.ToLower(strings.TrimSpace(k))
+		known := false
+		for _, n := range sniffKindName {
+			known = known || n == k
+		}
+		if !known {
+			return nil, fmt.Errorf("sniff %q: unknown kind %q (expected one of: %s)",
+				spec, k, strings.Join(sniffKindName, ", "))
+		}
+		rule.kind = append(rule.kind, k)
+	}
+	return rule, nil
+}
+
+// function appliesTo() returns true if this rule was declared for the given
+// library.
+func (r *SniffRule) appliesTo(lib *Library) bool {
+	return r.scope.appliesTo(lib)
+}
+
+// type SniffSignature identifies the files of a format by their first bytes,
+// along with the kind of the format and the file name extension under which
+// files of the format are classified.
+type SniffSignature struct {
+	kind  string
+	ext   string
+	match ContentMagic
+}
+
+// function isText() returns true if the given bytes appear to be text (and not
+// binary data), i.e. if they contain no control characters other than
+// whitespace.
+func isText(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 && '\t' != c && '\n' != c && '\r' != c && '\f' != c {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+// function textMagic() returns a check of the beginning of a text file (after
+// any byte order mark and leading whitespace) with the given function.
+func textMagic(fn func(s string) bool) ContentMagic {
+	return func(b []byte) bool {
+		b = bytes.TrimPrefix(b, []byte("\ufeff"))
+		return isText(b) && fn(string(b))
+	}
+}
+
+// function textPrefix() returns a check that a text file begins with any of
+// the given prefixes (case-insensitive).
+func textPrefix(prefix ...string) ContentMagic {
+	return textMagic(func(s string) bool {
+		s = strings.ToUpper(strings.TrimLeft(s, " \t\r\n"))
+		for _, p := range prefix {
+			if strings.HasPrefix(s, strings.ToUpper(p)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// function ebmlDocType() returns a check that the given bytes are the header of
+// an EBML (Matroska) file with the given document type.
+func ebmlDocType(docType string) ContentMagic {
+	return func(b []byte) bool {
+		element := append([]byte{0x42, 0x82, byte(0x80 | len(docType))}, docType...)
+		return magicAt(0, "\x1A\x45\xDF\xA3")(b) && bytes.Contains(b[:minInt(len(b), 64)], element)
+	}
+}
+
+// function minInt() returns the lesser of the given integers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var (
+	// variable sniffSignature lists the signatures of the formats recognized,
+	// in the order they are compared with a file.
+	sniffSignature = []SniffSignature{
+		// containers identified by their brand or content.
+		{sniffAudio, ".m4b", magicAll(magicAt(4, "ftyp"), magicAt(8, "M4B "))},
+		{sniffAudio, ".m4a", magicAll(magicAt(4, "ftyp"), magicAt(8, "M4A "))},
+		{sniffVideo, ".mov", magicAll(magicAt(4, "ftyp"), magicAt(8, "qt  "))},
+		{sniffVideo, ".3gp", magicAll(magicAt(4, "ftyp"), magicAt(8, "3gp"))},
+		{sniffVideo, ".3g2", magicAll(magicAt(4, "ftyp"), magicAt(8, "3g2"))},
+		{sniffVideo, ".mp4", magicAt(4, "ftyp")},
+		{sniffVideo, ".mov", magicAt(4, "moov", "mdat", "wide")},
+		{sniffVideo, ".webm", ebmlDocType("webm")},
+		{sniffVideo, ".mkv", ebmlDocType("matroska")},
+		{sniffAudio, ".opus", magicAll(magicAt(0, "OggS"), magicAt(28, "OpusHead"))},
+		{sniffAudio, ".oga", magicAll(magicAt(0, "OggS"), magicAt(28, "\x01vorbis", "\x7FFLAC", "Speex"))},
+		{sniffVideo, ".ogv", magicAt(0, "OggS")},
+		{sniffAudio, ".wav", magicAll(magicAt(0, "RIFF", "RF64"), magicAt(8, "WAVE"))},
+		{sniffVideo, ".avi", magicAll(magicAt(0, "RIFF"), magicAt(8, "AVI "))},
+		{sniffAudio, ".aiff", magicAll(magicAt(0, "FORM"), magicAt(8, "AIFF", "AIFC"))},
+		{sniffVideo, ".wmv", magicAll(magicAt(0, "\x30\x26\xB2\x75\x8E\x66\xCF\x11"), func(b []byte) bool {
+			// ASF video stream type GUID.
+			return bytes.Contains(b, []byte("\xC0\xEF\x19\xBC\x4D\x5B\xCF\x11\xA8\xFD\x00\x80\x5F\x5C\x44\x2B"))
+		})},
+		{sniffAudio, ".wma", magicAt(0, "\x30\x26\xB2\x75\x8E\x66\xCF\x11")},
+
+		// audio.
+		{sniffAudio, ".flac", magicAt(0, "fLaC")},
+		{sniffAudio, ".ape", magicAt(0, "MAC ")},
+		{sniffAudio, ".wv", magicAt(0, "wvpk")},
+		{sniffAudio, ".tta", magicAt(0, "TTA1")},
+		{sniffAudio, ".au", magicAt(0, ".snd")},
+		{sniffAudio, ".mpc", magicAt(0, "MPCK", "MP+")},
+		{sniffAudio, ".awb", magicAt(0, "#!AMR-WB")},
+		{sniffAudio, ".amr", magicAt(0, "#!AMR")},
+		{sniffAudio, ".aac", func(b []byte) bool { return adtsSync(b) || magicAt(0, "ADIF")(b) }},
+		// unlike when checking audio files, the frame header must be first, since
+		// the sync word is common among binary data.
+		{sniffAudio, ".mp3", func(b []byte) bool { return len(b) >= 3 && mpegAudioSync(b[:3]) }},
+
+		// video.
+		{sniffVideo, ".flv", magicAt(0, "FLV\x01")},
+		{sniffVideo, ".rm", magicAt(0, ".RMF")},
+		{sniffVideo, ".mpg", magicAt(0, "\x00\x00\x01\xBA", "\x00\x00\x01\xB3")},
+		{sniffVideo, ".mts", func(b []byte) bool { return len(b) > 376 && 0x47 == b[0] && 0x47 == b[188] && 0x47 == b[376] }},
+		{sniffVideo, ".m2ts", func(b []byte) bool { return len(b) > 388 && 0x47 == b[4] && 0x47 == b[196] && 0x47 == b[388] }},
+
+		// subtitles.
+		{sniffSubtitles, ".ass", textPrefix("[Script Info]")},
+		{sniffSubtitles, ".smi", textPrefix("<SAMI")},
+		{sniffSubtitles, ".idx", textPrefix("# VobSub index file")},
+		{sniffSubtitles, ".srt", textMagic(sniffSRT.MatchString)},
+		{sniffSubtitles, ".sub", textMagic(sniffMicroDVD.MatchString)},
+	}
+)
+
+// function sniffFile() compares the beginning of the given file with the known
+// signatures, and returns the kind and file name extension of the format
+// matched. returns false if none matched. an ID3v2 tag at the beginning of the
+// file implies MP3 audio, unless FLAC audio follows it.
+func sniffFile(absPath string) (string, string, bool) {
+
+	f, err := os.Open(absPath)
+	if nil != err {
+		return "", "", false
+	}
+	defer f.Close()
+
+	b := make([]byte, sniffProbeSize)
+	n, err := io.ReadFull(f, b)
+	if nil != err && io.ErrUnexpectedEOF != err {
+		return "", "", false
+	}
+	b = b[:n]
+
+	if bytes.HasPrefix(b, []byte("ID3")) && len(b) >= 10 {
+		if size := 10 + syncsafe(b[6:10]); size < int64(len(b)) && bytes.HasPrefix(b[size:], []byte("fLaC")) {
+			return sniffAudio, ".flac", true
+		}
+		return sniffAudio, ".mp3", true
+	}
+	for _, sig := range sniffSignature {
+		if sig.match(b) {
+			return sig.kind, sig.ext, true
+		}
+	}
+	return "", "", false
+}
+
+// function sniffExt() returns the file name extension under which the given
+// file is classified. if the file's own extension is unknown and the library
+// classifies files by content, this is the extension of the format of its
+// content (if of a kind classified for the library); otherwise, it is the
+// file's own extension.
+func (l *Library) sniffExt(absPath, ext string) string {
+
+	if 0 == len(l.sniffKind) {
+		return ext
+	}
+	if kind, _ := mediaKindOfFileExt(ext); mkUnknown != kind {
+		return ext
+	}
+	if kind, _ := supportKindOfFileExt(ext); skUnknown != kind {
+		return ext
+	}
+	// other files commonly found beside media are not worth reading.
+	if isChecksumFile(ext) || isDownloadExt(ext) || "" != archiveFormatOf(ext) {
+		return ext
+	}
+	kind, sniffed, ok := sniffFile(absPath)
+	if !ok || !l.sniffKind[kind] {
+		return ext
+	}
+	infoLog.tracef("classified by content as %s (%s): %q", kind, sniffed, absPath)
+	return sniffed
+}
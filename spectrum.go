@@ -0,0 +1,268 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: spectrum.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the (purely cosmetic) audio spectrum pane, which draws the
+//    frequency spectrum of the audio currently playing as bars of block
+//    characters beside the media browser. the audio is read from a FIFO of
+//    raw PCM samples (signed 16-bit little-endian stereo at 44.1 kHz), e.g.
+//    written by MPD's "fifo" output, or by mpv with options:
+//      --ao=pcm --ao-pcm-file=FIFO --ao-pcm-waveheader=no
+//      --audio-format=s16 --audio-samplerate=44100 --audio-channels=stereo
+//    the pane is only available when the FIFO is given on the command line,
+//    and it may be toggled at any time.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/cmplx"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for the audio spectrum pane.
+const (
+	spectrumWindow    = 1024                  // number of samples per transform (power of 2)
+	spectrumRate      = 44100                 // sample rate of the PCM FIFO (Hz)
+	spectrumMinFreq   = 40.0                  // lowest frequency drawn (Hz)
+	spectrumMaxFreq   = 16000.0               // highest frequency drawn (Hz)
+	spectrumFloor     = -60.0                 // level drawn as an empty bar (dB)
+	spectrumDecay     = 0.85                  // fraction of each bar's level kept per frame while falling
+	spectrumFrameTime = 50 * time.Millisecond // min time between redraws
+	spectrumRetry     = 1 * time.Second       // delay before reopening the FIFO after an error
+)
+
+var (
+	// variable spectrumBlock contains the block characters drawing the top of
+	// a bar, in eighths of a row.
+	spectrumBlock = []rune{' ', '▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+)
+
+// type SpectrumView is the pane drawing the audio spectrum.
+type SpectrumView struct {
+	*tview.Box
+	path    string    // FIFO from which PCM samples are read
+	level   []float64 // level of each frequency band (0 = floor, 1 = full scale)
+	bands   int       // number of frequency bands, i.e. width of the pane when last drawn
+	visible bool
+	mutex   sync.Mutex
+}
+
+// function newSpectrumView() allocates and initializes the spectrum pane. the
+// pane is visible initially if a FIFO is given.
+func newSpectrumView(path string) *SpectrumView {
+
+	v := SpectrumView{
+		Box:     tview.NewBox(),
+		path:    path,
+		level:   nil,
+		bands:   1,
+		visible: "" != path,
+		mutex:   sync.Mutex{},
+	}
+
+	v.Box.
+		SetBorder(false)
+
+	return &v
+}
+
+// function listen() dispatches a goroutine reading PCM samples from the FIFO
+// (if any) and redrawing the pane as they arrive, until the application exits.
+func (v *SpectrumView) listen(ui *tview.Application) {
+
+	if "" == v.path {
+		return
+	}
+	go func() {
+		for {
+			// opening a FIFO blocks until a writer (the player) opens it, and
+			// reading reaches EOF once the writer closes it.
+			fifo, err := os.Open(v.path)
+			if nil != err {
+				warnLog.tracef("spectrum: cannot open %q: %s", v.path, err)
+				time.Sleep(spectrumRetry)
+				continue
+			}
+			v.read(ui, fifo)
+			fifo.Close()
+			v.mutex.Lock()
+			v.level = nil
+			v.mutex.Unlock()
+			ui.QueueUpdateDraw(func() {})
+		}
+	}()
+}
+
+// function read() computes the spectrum of every window of samples read from
+// the given reader, redrawing the pane no more often than spectrumFrameTime.
+func (v *SpectrumView) read(ui *tview.Application, r io.Reader) {
+
+	frame := make([]byte, 4*spectrumWindow) // 2 channels of 2 bytes per sample
+	sample := make([]float64, spectrumWindow)
+	last := time.Time{}
+	for {
+		if _, err := io.ReadFull(r, frame); nil != err {
+			return
+		}
+		if time.Since(last) < spectrumFrameTime {
+			continue
+		}
+		for i := range sample {
+			left := int16(binary.LittleEndian.Uint16(frame[4*i:]))
+			right := int16(binary.LittleEndian.Uint16(frame[4*i+2:]))
+			sample[i] = (float64(left) + float64(right)) / (2 * math.MaxInt16)
+		}
+		v.update(spectrumOf(sample))
+		if v.isVisible() {
+			ui.QueueUpdateDraw(func() {})
+		}
+		last = time.Now()
+	}
+}
+
+// function spectrumOf() returns the magnitude of each frequency (in bins of
+// spectrumRate/len(sample) Hz) of the given samples, whose number must be a
+// power of 2.
+func spectrumOf(sample []float64) []float64 {
+
+	n := len(sample)
+	x := make([]complex128, n)
+	for i, s := range sample {
+		// Hann window, which reduces the leakage between frequency bins.
+		w := 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(n-1)))
+		x[i] = complex(s*w, 0)
+	}
+	fft(x)
+
+	mag := make([]float64, n/2)
+	for i := range mag {
+		mag[i] = 2 * cmplx.Abs(x[i]) / float64(n)
+	}
+	return mag
+}
+
+// function fft() computes in place the discrete Fourier transform of the given
+// values, whose number must be a power of 2 (iterative radix-2 Cooley-Tukey).
+func fft(x []complex128) {
+
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; 0 != j&bit; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// function update() folds the given magnitudes into the level of each band.
+// the bands are spaced logarithmically, as pitch is perceived, and the number
+// of bands is the width of the pane. bars rise immediately but fall gradually.
+func (v *SpectrumView) update(mag []float64) {
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if len(v.level) != v.bands {
+		v.level = make([]float64, v.bands)
+	}
+	binFreq := float64(spectrumRate) / float64(2*len(mag))
+	ratio := math.Pow(spectrumMaxFreq/spectrumMinFreq, 1/float64(v.bands))
+
+	lo := spectrumMinFreq
+	for b := range v.level {
+		hi := lo * ratio
+		peak := 0.0
+		for i := int(lo / binFreq); i <= int(hi/binFreq) && i < len(mag); i++ {
+			peak = math.Max(peak, mag[i])
+		}
+		level := 0.0
+		if peak > 0 {
+			level = (20*math.Log10(peak) - spectrumFloor) / -spectrumFloor
+		}
+		level = math.Max(0, math.Min(1, level))
+		v.level[b] = math.Max(level, v.level[b]*spectrumDecay)
+		lo = hi
+	}
+}
+
+// function isVisible() returns true if the pane is shown.
+func (v *SpectrumView) isVisible() bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.visible
+}
+
+// function Draw() draws each band as a bar of block characters rising from the
+// bottom of the pane.
+func (v *SpectrumView) Draw(screen tcell.Screen) {
+
+	v.Box.Draw(screen)
+	x, y, width, height := v.GetInnerRect()
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if width > 0 {
+		v.bands = width
+	}
+	style := tcell.StyleDefault.
+		Foreground(colorScheme.highlightSecondary).
+		Background(colorScheme.backgroundPrimary)
+	for b := 0; b < width && b < len(v.level); b++ {
+		eighths := int(v.level[b] * float64(8*height))
+		for row := 0; row < height && eighths > 0; row++ {
+			block := spectrumBlock[len(spectrumBlock)-1]
+			if eighths < 8 {
+				block = spectrumBlock[eighths]
+			}
+			screen.SetContent(x+b, y+height-1-row, block, nil, style)
+			eighths -= 8
+		}
+	}
+}
+
+// function spectrumEvent() handles the key showing or hiding the spectrum
+// pane. returns true if the key was handled.
+func (l *Layout) spectrumEvent(ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'W' != er {
+		return false
+	}
+	if "" == l.spectrum.path {
+		warnLog.logf("(ignored) no audio source for the spectrum (see option -%s)",
+			l.option.SpectrumFifo.name)
+		return true
+	}
+	l.spectrum.mutex.Lock()
+	l.spectrum.visible = !l.spectrum.visible
+	l.spectrum.mutex.Unlock()
+	l.arrangeRoot(l.preset[l.currPreset].LogRows)
+	return true
+}
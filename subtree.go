@@ -0,0 +1,117 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: subtree.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the targeted scan of a single folder of a library (and all of its
+//    subfolders), performed on demand. it inserts what it finds into the
+//    library's database exactly as a full scan would, which is useful for
+//    huge libraries whose full scans are left for when nobody is watching.
+//
+// =============================================================================
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+// function scanSubtree() scans the given directory of the library right away,
+// using the handler of the library's most recent scan. returns the number of
+// new media and support files found.
+func (l *Library) scanSubtree(absPath string) (uint, *ReturnCode) {
+
+	rel, err := filepath.Rel(l.absPath, absPath)
+	if nil != err || ".." == rel || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return 0, rcInvalidPath.specf("scanSubtree(%q): not in library: %q", absPath, l.name)
+	}
+	if info, err := os.Stat(absPath); nil != err || !info.IsDir() {
+		return 0, rcInvalidPath.specf("scanSubtree(%q): not a directory", absPath)
+	}
+
+	// occupy the scanner semaphore, so that a full scan never overlaps.
+	select {
+	case l.scanStart <- time.Now():
+	default:
+		return 0, rcLibraryBusy.specf("scanSubtree(): library is scanning: %q", l.name)
+	}
+	ctx, end := l.beginOperation(context.Background(), "scan")
+	defer end()
+	if !isCLIMode {
+		l.busyState.inc()
+	}
+	ctx, done := l.interruptible(ctx)
+	defer done()
+
+	l.issues.Lock()
+	handler := l.issues.handler
+	l.issues.Unlock()
+
+	before, _ := l.db.totalRecordsString(dmScan, -1, -1)
+
+	// the library root is at depth 1, each of its entries at depth 2, etc.
+	depth := uint(1)
+	if "." != rel {
+		depth += uint(len(strings.Split(filepath.ToSlash(rel), "/")))
+	}
+	infoLog.in(ctx).verbosef("scanning folder: %q of %q", rel, l.name)
+	l.resetSymlinks()
+	l.quarantine.torrent.refresh()
+	l.scanPool = newScanPool(l.dirWorkers)
+	ret := l.scanDive(ctx, handler, absPath, depth)
+	l.scanPool = nil
+	if !ret.is(rcInterrupted) {
+		l.recordIssue(absPath, ret)
+	}
+	if nil == ret {
+		l.recandidateSubtitles(false)
+		l.recandidateAudioTracks(false)
+	}
+
+	elapsed := time.Since(<-l.scanStart)
+	if !isCLIMode {
+		l.busyState.dec()
+	}
+
+	after, _ := l.db.totalRecordsString(dmScan, -1, -1)
+	infoLog.in(ctx).logf("finished scanning folder: %q of %q (%d new found in %s)",
+		rel, l.name, after-before, elapsed.Round(time.Millisecond))
+	l.checkBudget()
+	l.scheduleRescan()
+
+	return after - before, ret
+}
+
+// function subtreeEvent() handles the key scanning the folder of the item
+// currently selected in the media browser. returns true if the key was
+// handled.
+func (l *Layout) subtreeEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'F' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("scan a folder"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item {
+		warnLog.logf("(ignored) no item selected")
+		return true
+	}
+	go func(lib *Library, dir string) {
+		if _, ret := lib.scanSubtree(dir); nil != ret {
+			warnLog.log(ret)
+		}
+	}(item.SourceLibrary, filepath.Dir(item.AbsPath))
+	return true
+}
@@ -0,0 +1,119 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: summary.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the summary printed in CLI mode while the libraries are loaded
+//    and scanned. each library is listed on its own line as soon as it is
+//    done, with the number of items loaded, found, ignored, and failed, so
+//    that unattended runs (e.g. cron jobs) can be reviewed at a glance, and
+//    the exit code reflects whether any library had errors.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// type LibrarySummary is the outcome of loading and scanning a single library.
+type LibrarySummary struct {
+	name    string
+	loaded  uint          // number of records loaded from the database
+	found   uint          // number of new records found by scanning
+	skipped uint64        // number of files ignored or skipped (e.g. symlinks) by the scan
+	errors  uint          // number of other issues encountered, plus failed load or scan
+	elapsed time.Duration // time spent loading and scanning
+}
+
+// function summary() returns the outcome of loading and scanning the library.
+// it must only be called once the library's scan has completed.
+func (l *Library) summary() *LibrarySummary {
+
+	loaded, _ := l.db.totalRecordsString(dmLoad, -1, -1)
+	found, _ := l.db.totalRecordsString(dmScan, -1, -1)
+
+	// files of an unsupported type (symlinks, devices, etc.) are skipped by
+	// design, so they are not errors.
+	skipped, errors := atomic.LoadUint64(&l.ignored), uint(0)
+	l.issues.Lock()
+	for _, is := range l.issues.issue {
+		if rcInvalidFile.desc == is.kind {
+			skipped++
+		} else {
+			errors++
+		}
+	}
+	l.issues.Unlock()
+	if nil != l.failed {
+		errors++
+	}
+
+	return &LibrarySummary{
+		name:    l.name,
+		loaded:  loaded,
+		found:   found,
+		skipped: skipped,
+		errors:  errors,
+		elapsed: l.loadElapsed + l.scanElapsed,
+	}
+}
+
+// type SummaryTable is the table to which the outcome of each library is
+// written as soon as it has been loaded and scanned, with one row per library.
+type SummaryTable struct {
+	row    string   // format of each row, wide enough for every library name
+	count  int      // number of libraries summarized (the header precedes the first)
+	failed []string // names of the libraries which had errors
+}
+
+// function newSummaryTable() creates a table summarizing the given libraries,
+// written to the raw logger.
+func newSummaryTable(library []*Library) *SummaryTable {
+
+	width := len("LIBRARY")
+	for _, l := range library {
+		if n := len(l.name); n > width {
+			width = n
+		}
+	}
+
+	t := &SummaryTable{
+		row:    fmt.Sprintf("  %%-%ds  %%8v  %%8v  %%8v  %%8v  %%10v", width),
+		count:  0,
+		failed: []string{},
+	}
+	return t
+}
+
+// function add() writes the outcome of loading and scanning the given library
+// to the table. it must only be called once the library's scan has completed.
+func (t *SummaryTable) add(l *Library) {
+	s := l.summary()
+	if 0 == t.count {
+		rawLog.log("summary:")
+		rawLog.logf(t.row, "LIBRARY", "NEW", "LOADED", "SKIPPED", "ERRORS", "ELAPSED")
+	}
+	rawLog.logf(t.row, s.name, s.found, s.loaded, s.skipped, s.errors,
+		s.elapsed.Round(time.Millisecond))
+	if s.errors > 0 {
+		t.failed = append(t.failed, s.name)
+	}
+	t.count++
+}
+
+// function result() returns rcScanErrors if any library added to the table had
+// errors.
+func (t *SummaryTable) result() *ReturnCode {
+	if len(t.failed) > 0 {
+		return rcScanErrors.specf("%d of %d libraries had errors: %q",
+			len(t.failed), t.count, t.failed)
+	}
+	return nil
+}
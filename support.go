@@ -19,7 +19,6 @@ import (
 	"path"
 	"strings"
 
-	"github.com/HouzuoGuo/tiedot/db"
 	//"github.com/davecgh/go-spew/spew"
 )
 
@@ -29,16 +28,18 @@ import (
 type SupportKind int
 
 const (
-	skUnknown   SupportKind = iota - 1 // = -1
-	skSubtitles                        // =  0
-	skCOUNT                            // =  1
+	skUnknown    SupportKind = iota - 1 // = -1
+	skSubtitles                         // =  0
+	skAudioTrack                        // =  1
+	skCOUNT                             // =  2
 )
 
 var (
 	// variable supportColName maps the SupportKind enum values to the string
 	// name of their corresponding collection in the database.
 	supportColName = [skCOUNT]string{
-		"Subtitles", // 0 = skSubtitles
+		"Subtitles",   // 0 = skSubtitles
+		"AudioTracks", // 1 = skAudioTrack
 	}
 )
 
@@ -52,13 +53,23 @@ type Support struct {
 // type Subtitles is a specialized type of support containing struct fields
 // relevant only to subtitles.
 type Subtitles struct {
-	*Support        // common support info
-	KnownVideoMedia []VideoMedia
+	*Support                 // common support info
+	KnownVideoMedia []string // absolute path to all associated videos
+}
+
+// type AudioTrack is a specialized type of support containing struct fields
+// relevant only to external audio tracks, e.g. alternate language dubs or
+// commentary, which are played alongside the video's own audio.
+type AudioTrack struct {
+	*Support                 // common support info
+	Commentary      bool     // file name identifies this as a commentary track
+	KnownVideoMedia []string // absolute path to all associated videos
 }
 
 const (
-	// max number of media that can exist in a directory coincidently with a
-	// subtitles file to consider them associated (see findCandidates() case 3).
+	// default max number of media that can exist in a directory coincidently
+	// with a subtitles file to consider them associated (see findCandidates()
+	// case 4). may be changed with command line option "-subsmaxmedia".
 	maxNumMediaAssocSubs int = 2
 )
 
@@ -103,15 +114,17 @@ func newSubtitles(lib *Library, absPath, relPath, ext, extName string, info os.F
 
 	return &Subtitles{
 		Support:         support, // common support info
-		KnownVideoMedia: []VideoMedia{},
+		KnownVideoMedia: []string{},
 	}
 }
 
-// function addVideoMedia() adds the given VideoMedia to this Subtitles object
-// if and only if the video does not already exist in the object's list of known
-// videos. additionally, the database record of these subtitles is also
-// optionally updated to store the video in the list of known VideoMedia.
-func (s *Subtitles) addVideoMedia(col *db.Col, id int, update bool, vid *VideoMedia) (bool, *ReturnCode) {
+// function addVideoMedia() adds the path of the given VideoMedia to this
+// Subtitles object if and only if the video does not already exist in the
+// object's list of known videos. only the path is kept, since the video itself
+// keeps these subtitles (and copying it would copy them, and so on). the
+// database record of these subtitles is also optionally updated to store the
+// video in the list of known VideoMedia.
+func (s *Subtitles) addVideoMedia(col Collection, id int, update bool, vid *VideoMedia) (bool, *ReturnCode) {
 
 	var (
 		rec     *EntityRecord
@@ -122,14 +135,14 @@ func (s *Subtitles) addVideoMedia(col *db.Col, id int, update bool, vid *VideoMe
 	// walk the current list of known videos, setting a flag if we have already
 	// seen this one before.
 	for _, v := range s.KnownVideoMedia {
-		if v.AbsPath == vid.AbsPath {
+		if v == vid.AbsPath {
 			vidSeen = true
 			break
 		}
 	}
 	// append it to the list if we haven't seen it before.
 	if !vidSeen {
-		s.KnownVideoMedia = append(s.KnownVideoMedia, *vid)
+		s.KnownVideoMedia = append(s.KnownVideoMedia, vid.AbsPath)
 	}
 
 	// update the database record of this Subtitles to include the new
@@ -140,7 +153,7 @@ func (s *Subtitles) addVideoMedia(col *db.Col, id int, update bool, vid *VideoMe
 	if update {
 		if err := col.Update(id, *rec); nil != err {
 			return false, rcDatabaseError.specf(
-				"addVideoMedia(%v, %d, %s): failed to update record: %s", col, id, *vid, err)
+				"addVideoMedia(%v, %d, %s): failed to update record: %s", col, id, vid, err)
 		}
 	}
 
@@ -187,6 +200,21 @@ var (
 			"Universal Subtitle Format":  []string{".usf"},
 		},
 	}
+	// var audioTrackExt is a struct defining how skAudioTrack support files will
+	// be identified through file name inspection. see discussion of subsExt
+	// above. these containers/codecs are rarely used for stand-alone audio, so
+	// they are not included in the mkAudio media table. note that any audio
+	// media file whose name identifies it as a commentary track is also treated
+	// as skAudioTrack (see function supportKindOfFile()).
+	audioTrackExt = SupportExt{
+		kind: skAudioTrack,
+		table: &ExtTable{
+			"Matroska Audio":         []string{".mka"},
+			"Dolby Digital":          []string{".ac3", ".eac3"},
+			"Dolby TrueHD":           []string{".thd"},
+			"DTS Coherent Acoustics": []string{".dts"},
+		},
+	}
 )
 
 // function supportKindOfFileExt() searches all SupportExt mappings for a given
@@ -199,7 +227,7 @@ func supportKindOfFileExt(ext string) (SupportKind, string) {
 	extLower := strings.ToLower(ext)
 
 	// iter: all supported kinds of media
-	for _, m := range []SupportExt{subsExt} {
+	for _, m := range []SupportExt{subsExt, audioTrackExt} {
 		if n, ok := kindOfFileExt(m.table, extLower); ok {
 			return m.kind, n
 		}
@@ -207,17 +235,32 @@ func supportKindOfFileExt(ext string) (SupportKind, string) {
 	return skUnknown, ""
 }
 
-// function isInSubtitlesSubdir() inspects this subtitles file's absolute file
+// function supportKindOfFile() determines the SupportKind of the file with the
+// given absolute path and file name extension. this is the same as function
+// supportKindOfFileExt(), except that audio media files whose name identifies
+// them as a commentary track are considered skAudioTrack support files.
+func supportKindOfFile(absPath, ext string) (SupportKind, string) {
+
+	if kind, extName := mediaKindOfFileExt(ext); mkAudio == kind {
+		if isCommentaryName(strings.TrimSuffix(path.Base(absPath), ext)) {
+			return skAudioTrack, extName
+		}
+		return skUnknown, ""
+	}
+	return supportKindOfFileExt(ext)
+}
+
+// function isInSubtitlesSubdir() inspects this support file's absolute file
 // path to determine if one of its parent directories is one of the known,
 // common names typically used to store subtitles in a directory relative to the
-// location of a video media file. if found, an absolute path to the parent of
-// the deepest matching directory found is returned.
-func (s *Subtitles) isInSubtitlesSubdir() (bool, string) {
+// location of a video media file (as configured by the given SubsAssoc). if
+// found, an absolute path to the parent of the deepest matching directory found
+// is returned.
+func (s *Support) isInSubtitlesSubdir(assoc *SubsAssoc) (bool, string) {
 
 	dir := strings.Split(s.AbsDir, pathSep)
 	for i := len(dir) - 1; i >= 0; i-- {
-		switch name := dir[i]; strings.ToLower(name) {
-		case "sub", "subs", "subtitle", "subtitles", "vobsub", "srt":
+		if assoc.isSubsSubdir(dir[i]) {
 			if i > 1 {
 				return true, strings.Join(dir[:i], pathSep)
 			} else {
@@ -279,7 +322,7 @@ func (s *Subtitles) fromRecord(data []byte) *ReturnCode {
 
 // function fromID() creates a concrete Subtitles struct using the record
 // stored in the given collection with the given hash key id.
-func (s *Subtitles) fromID(col *db.Col, id int) *ReturnCode {
+func (s *Subtitles) fromID(col Collection, id int) *ReturnCode {
 
 	read, readErr := col.Read(id)
 	if nil != readErr {
@@ -305,12 +348,112 @@ func (s *Subtitles) fromID(col *db.Col, id int) *ReturnCode {
 	return nil
 }
 
+// function queryCandidates() evaluates the first three association heuristics
+// (those enabled for the given library) for this support file, returning the
+// doc IDs of all video media in the library that appear to be related to it in
+// some nominal/positional way. [NOTE that the base names are compared
+// case-insensitively only by the bolt storage engine. tiedot's hash indices
+// cannot be searched case-insensitively, so with tiedot these remain
+// case-sensitive comparisons (see function caseQuery() of package internal/db).]
+func (s *Support) queryCandidates(lib *Library) (map[int]struct{}, *ReturnCode) {
+
+	vidCol := lib.db.col[ecMedia][mkVideo]
+	idx := lib.db.index[ecMedia]
+
+	queryResult := make(map[int]struct{})
+	query := []interface{}{}
+
+	// first check: does the base name of the support file match exactly with
+	// the base name of any media file?
+	//   e.g., "Foo.avi" <- "Foo.srt"
+	if lib.assoc.enabled(shBaseName) {
+		query = append(query,
+			map[string]interface{}{
+				"eqfold": s.AbsBase,
+				"in":     []interface{}{(*idx[mxBase])[0]},
+			})
+	}
+
+	// second: does the support file exist in a directory whose name matches
+	// exactly with the base name of any media file?
+	//   e.g., "/a/b/Foo/Foo.avi" <- "/a/b/Foo/Bar.srt"
+	if lib.assoc.enabled(shDirName) {
+		query = append(query,
+			map[string]interface{}{
+				"n": []interface{}{
+					map[string]interface{}{
+						"eq": s.AbsDir,
+						"in": []interface{}{(*idx[mxDir])[0]},
+					},
+					map[string]interface{}{
+						"eqfold": path.Base(s.AbsDir),
+						"in":     []interface{}{(*idx[mxBase])[0]},
+					},
+				},
+			})
+	}
+
+	// third: does the support file exist in a directory with a common name for
+	// subtitles dirs and that subdir exists in the same dir as a media file?
+	//   e.g., "/a/b/Foo.avi" <- "/a/b/Subs/Bar.srt"
+	if lib.assoc.enabled(shSubdir) {
+		if found, dir := s.isInSubtitlesSubdir(lib.assoc); found {
+			query = append(query,
+				map[string]interface{}{
+					"eq": dir,
+					"in": []interface{}{(*idx[mxDir])[0]},
+				})
+		}
+	}
+
+	if len(query) > 0 {
+		if err := evalQuery(query, vidCol, &queryResult); nil != err {
+			return nil, rcQueryError.specf(
+				"queryCandidates(%s): EvalQuery({%s, %s}): %s", lib, s.AbsBase, *idx[mxBase], err)
+		}
+	}
+	return queryResult, nil
+}
+
+// function queryNeighbors() evaluates the fourth association heuristic for
+// this support file, returning the doc IDs of all video media in the same
+// directory -- but only if there are few enough of them (as configured for the
+// given library) that they are all plausibly related to it. using a limit is
+// just a heuristic -- it prevents a support file being selected for every video
+// in a directory containing a large number of videos, but it also allows for
+// support files to be selected when they exist in a directory containing very
+// few media files yet don't have a consistent or similar base file name.
+//
+//	e.g. (N=2), {"Foo1.avi","Foo2.avi"} <- "Bar.srt"
+func (s *Support) queryNeighbors(lib *Library) (map[int]struct{}, *ReturnCode) {
+
+	vidCol := lib.db.col[ecMedia][mkVideo]
+	idx := lib.db.index[ecMedia]
+
+	queryResult := make(map[int]struct{})
+	if !lib.assoc.enabled(shFewMedia) {
+		return queryResult, nil
+	}
+
+	query := []interface{}{
+		map[string]interface{}{
+			"eq": s.AbsDir,
+			"in": []interface{}{(*idx[mxDir])[0]},
+		},
+	}
+	if err := evalQuery(query, vidCol, &queryResult); nil != err {
+		return nil, rcQueryError.specf(
+			"queryNeighbors(%s): EvalQuery({%s, %s}): %s", lib, s.AbsBase, *idx[mxDir], err)
+	}
+	if uint(len(queryResult)) > lib.assoc.maxMedia {
+		return make(map[int]struct{}), nil
+	}
+	return queryResult, nil
+}
+
 // function findCandidates() scans the database for video media that appears to
-// be related to this subtitles file in some nominal/positional way. [NOTE that
-// the string evaluations are currently all case-sensitive comparisons. this is
-// a limitation of the database engine being used. if it becomes a significant
-// problem, we can create additional fields in the records that have a fixed,
-// known character case and use those fields for the queries.]
+// be related to this subtitles file (see functions queryCandidates() and
+// queryNeighbors() for the heuristics used).
 // --
 // if argument update is true, then the database is updated to store all of the
 // bi-directional associations discovered between this Subtitles object and its
@@ -319,63 +462,19 @@ func (s *Subtitles) fromID(col *db.Col, id int) *ReturnCode {
 // update is true).
 func (s *Subtitles) findCandidates(lib *Library, update bool, subID int) ([]*VideoMedia, *ReturnCode) {
 
-	var (
-		queryResult map[int]struct{}
-		query       []interface{}
-		addErr      *ReturnCode
-		added       bool
-	)
-
 	vidCol := lib.db.col[ecMedia][mkVideo]
 	subCol := lib.db.col[ecSupport][skSubtitles]
-	idx := lib.db.index[ecMedia]
 	candidate := []*VideoMedia{}
 
-	queryResult = make(map[int]struct{})
-	query = []interface{}{
-		// first check: does the base name of the subtitles file match exactly with
-		// the base name of any media file?
-		//   e.g., "Foo.avi" <- "Foo.srt"
-		map[string]interface{}{
-			"eq": s.AbsBase,
-			"in": []interface{}{(*idx[mxBase])[0]},
-		},
-		// second: does the subtitles file exist in a directory whose name matches
-		// exactly with the base name of any media file?
-		//   e.g., "/a/b/Foo/Foo.avi" <- "/a/b/Foo/Bar.srt"
-		map[string]interface{}{
-			"n": []interface{}{
-				map[string]interface{}{
-					"eq": s.AbsDir,
-					"in": []interface{}{(*idx[mxDir])[0]},
-				},
-				map[string]interface{}{
-					"eq": path.Base(s.AbsDir),
-					"in": []interface{}{(*idx[mxBase])[0]},
-				},
-			},
-		},
-	}
-
-	// third: do the subtitles exist in a directory with a common name for
-	// subtitles dirs and that subdir exists in the same dir as a media file?
-	//   e.g., "/a/b/Foo.avi" <- "/a/b/Subs/Bar.srt"
-	if found, dir := s.isInSubtitlesSubdir(); found {
-		query = append(query,
-			map[string]interface{}{
-				"eq": dir,
-				"in": []interface{}{(*idx[mxDir])[0]},
-			})
-	}
-
-	if err := db.EvalQuery(query, vidCol, &queryResult); nil != err {
-		return nil, rcQueryError.specf(
-			"findCandidates(%s): EvalQuery({%s, %s}): %s", lib, s.AbsBase, *idx[mxBase], err)
+	queryResult, queryErr := s.queryCandidates(lib)
+	if nil != queryErr {
+		return nil, queryErr
 	}
 	for id := range queryResult {
 		video := &VideoMedia{}
 		video.fromID(vidCol, id)
-		if added, addErr = video.addSubtitles(vidCol, subCol, id, subID, update, false, s); nil != addErr {
+		added, addErr := video.addSubtitles(vidCol, subCol, id, subID, update, false, s)
+		if nil != addErr {
 			return nil, addErr
 		}
 		if added {
@@ -389,37 +488,161 @@ func (s *Subtitles) findCandidates(lib *Library, update bool, subID int) ([]*Vid
 	// candidates yet. otherwise, trust that one of the other methods have a far
 	// more likely candidate.
 	if 0 == len(candidate) {
-		// fourth: does the subtitles file exist in a directory that has <= N media
-		// files? using N is just a heuristic -- it prevents a subtitles file
-		// being selected for every video in a directory containing a large number
-		// of videos, but it also allows for subtitles to be selected when they
-		// exist in a directory containing very few media files yet don't have a
-		// consistent or similar base file name.
-		//   e.g. (N=2), {"Foo1.avi","Foo2.avi"} <- "Bar.srt"
-		queryResult = make(map[int]struct{})
-		query = []interface{}{
-			map[string]interface{}{
-				"eq": s.AbsDir,
-				"in": []interface{}{(*idx[mxDir])[0]},
-			},
+		if queryResult, queryErr = s.queryNeighbors(lib); nil != queryErr {
+			return nil, queryErr
 		}
-		if err := db.EvalQuery(query, vidCol, &queryResult); nil != err {
-			return nil, rcQueryError.specf(
-				"findCandidates(%s): EvalQuery({%s, %s}): %s", lib, s.AbsBase, *idx[mxBase], err)
+		for id := range queryResult {
+			video := &VideoMedia{}
+			video.fromID(vidCol, id)
+			added, addErr := video.addSubtitles(vidCol, subCol, id, subID, update, false, s)
+			if nil != addErr {
+				return nil, addErr
+			}
+			if added {
+				infoLog.tracef("associated subtitles (%q, [type-b]) with video: %q",
+					s.AbsName, video.Name)
+				candidate = append(candidate, video)
+			}
 		}
-		if len(queryResult) <= maxNumMediaAssocSubs {
-			for id := range queryResult {
-				video := &VideoMedia{}
-				video.fromID(vidCol, id)
-				if added, addErr = video.addSubtitles(vidCol, subCol, id, subID, update, false, s); nil != addErr {
-					return nil, addErr
-				}
-				if added {
-					infoLog.tracef("associated subtitles (%q, [type-b]) with video: %q",
-						s.AbsName, video.Name)
-					candidate = append(candidate, video)
-				}
+	}
+
+	return candidate, nil
+}
+
+// function newAudioTrack() creates and initializes a new AudioTrack object by
+// invoking the embedded types' constructors and then populating any unique
+// specialization fields.
+func newAudioTrack(lib *Library, absPath, relPath, ext, extName string, info os.FileInfo) *AudioTrack {
+
+	support := newSupport(lib, skAudioTrack, absPath, relPath, ext, extName, info)
+
+	return &AudioTrack{
+		Support:         support, // common support info
+		Commentary:      isCommentaryName(support.AbsBase),
+		KnownVideoMedia: []string{},
+	}
+}
+
+// function isCommentaryName() returns true if the given file base name
+// identifies a commentary track, e.g. "Foo.commentary.mp3" or "Foo
+// (Director's Commentary).mka".
+func isCommentaryName(base string) bool {
+	return strings.Contains(strings.ToLower(base), "commentary")
+}
+
+// function addVideoMedia() adds the path of the given VideoMedia to this
+// AudioTrack object if and only if the video does not already exist in the
+// object's list of known videos, exactly as (*Subtitles).addVideoMedia() does.
+// the database record of this audio track is also optionally updated to store
+// the video in the list of known VideoMedia.
+func (a *AudioTrack) addVideoMedia(col Collection, id int, update bool, vid *VideoMedia) (bool, *ReturnCode) {
+
+	vidSeen := false
+	for _, v := range a.KnownVideoMedia {
+		if v == vid.AbsPath {
+			vidSeen = true
+			break
+		}
+	}
+	if !vidSeen {
+		a.KnownVideoMedia = append(a.KnownVideoMedia, vid.AbsPath)
+	}
+
+	rec, recErr := a.toRecord()
+	if nil != recErr {
+		return false, recErr
+	}
+	if update {
+		if err := col.Update(id, *rec); nil != err {
+			return false, rcDatabaseError.specf(
+				"addVideoMedia(%v, %d, %s): failed to update record: %s", col, id, vid, err)
+		}
+	}
+	return !vidSeen, nil
+}
+
+// function toRecord() creates a struct capable of being stored in the database.
+// defines type AudioTrack's implementation of the StorableEntity interface.
+func (a *AudioTrack) toRecord() (*EntityRecord, *ReturnCode) {
+
+	var (
+		record *EntityRecord = &EntityRecord{}
+		data   []byte
+		err    error
+	)
+
+	if data, err = json.Marshal(a); nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Marshal(%s): cannot marshal AudioTrack struct into JSON object: %s", a, err)
+	}
+
+	if err = json.Unmarshal(data, record); nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Unmarshal(%s): cannot unmarshal JSON object into EntityRecord struct: %s", string(data), err)
+	}
+
+	return record, nil
+}
+
+// function fromRecord() creates a struct using the record stored in the
+// database. defines type AudioTrack's implementation of the StorableEntity
+// interface.
+func (a *AudioTrack) fromRecord(data []byte) *ReturnCode {
+
+	// see the discussion of the embedded Support pointer in function
+	// (*Subtitles).fromRecord().
+	if nil == a.Support {
+		a.Support = &Support{}
+	}
+
+	if err := json.Unmarshal(data, a); nil != err {
+		return rcInvalidJSONData.specf(
+			"fromRecord(): json.Unmarshal(%s): cannot unmarshal JSON object into AudioTrack struct: %s", string(data), err)
+	}
+
+	return nil
+}
+
+// function findCandidates() scans the database for video media that appears to
+// be related to this audio track, using the same heuristics as subtitles (see
+// function (*Subtitles).findCandidates()).
+func (a *AudioTrack) findCandidates(lib *Library, update bool, audID int) ([]*VideoMedia, *ReturnCode) {
+
+	vidCol := lib.db.col[ecMedia][mkVideo]
+	audCol := lib.db.col[ecSupport][skAudioTrack]
+	candidate := []*VideoMedia{}
+
+	associate := func(queryResult map[int]struct{}, heuristic string) *ReturnCode {
+		for id := range queryResult {
+			video := &VideoMedia{}
+			video.fromID(vidCol, id)
+			added, addErr := video.addAudioTrack(vidCol, audCol, id, audID, update, false, a)
+			if nil != addErr {
+				return addErr
 			}
+			if added {
+				infoLog.tracef("associated audio track (%q, [%s]) with video: %q",
+					a.AbsName, heuristic, video.Name)
+				candidate = append(candidate, video)
+			}
+		}
+		return nil
+	}
+
+	queryResult, queryErr := a.queryCandidates(lib)
+	if nil != queryErr {
+		return nil, queryErr
+	}
+	if ret := associate(queryResult, "type-a"); nil != ret {
+		return nil, ret
+	}
+
+	if 0 == len(candidate) {
+		if queryResult, queryErr = a.queryNeighbors(lib); nil != queryErr {
+			return nil, queryErr
+		}
+		if ret := associate(queryResult, "type-b"); nil != ret {
+			return nil, ret
 		}
 	}
 
@@ -0,0 +1,129 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: suspend.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    detects when the host system has been suspended (sleep/hibernate) and
+//    resumed, so that libraries can be revalidated and any scans that were
+//    running at the time can be restarted.
+//
+// =============================================================================
+
+package main
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// local unexported constants controlling the suspend/resume detection.
+const (
+	// how frequently we compare the wall clock against the monotonic clock.
+	suspendPollFreq = 5 * time.Second
+
+	// the wall clock must have advanced at least this much more than the
+	// monotonic clock between two polls before we assume the system was
+	// suspended. keep this generous so that NTP adjustments do not trigger it.
+	suspendMinElapsed = 10 * time.Second
+)
+
+// function watchSuspend() polls the system clocks indefinitely, calling
+// function resumed() for the given libraries each time it determines the
+// system has just woken from suspend.
+//
+// detection relies on the monotonic clock not advancing while the system is
+// suspended -- which is the case on linux (CLOCK_MONOTONIC) and darwin -- while
+// the wall clock continues to track real time. on platforms where both clocks
+// include time spent suspended, a resume will simply never be detected.
+func watchSuspend(library []*Library) {
+
+	tick := time.NewTicker(suspendPollFreq)
+	defer tick.Stop()
+
+	last := time.Now()
+	for range tick.C {
+		now := time.Now()
+		// time.Time.Sub() uses the monotonic clock readings when both times
+		// have them; Round(0) strips the monotonic reading, forcing the wall
+		// clock to be used instead.
+		monotonic := now.Sub(last)
+		wall := now.Round(0).Sub(last.Round(0))
+		if asleep := wall - monotonic; asleep >= suspendMinElapsed {
+			infoLog.logf("system resumed from suspend (asleep for %s)",
+				asleep.Round(time.Second))
+			resumed(library)
+		}
+		last = now
+	}
+}
+
+// function resumed() revalidates each of the given libraries after the system
+// has resumed from suspend. any library whose scan was in progress when the
+// system was suspended is flagged to be rescanned once the current scan has
+// finished, since its results may be incomplete.
+func resumed(library []*Library) {
+	for _, l := range library {
+		wasOffline := l.isOffline()
+		if err := l.revalidate(); nil != err {
+			if !wasOffline {
+				warnLog.logf("library unavailable after resume: %q: %s", l.name, err)
+			}
+			continue
+		}
+		if wasOffline {
+			infoLog.logf("library available again after resume: %q", l.name)
+		}
+		if l.isScanning() {
+			l.requestRescan()
+		}
+	}
+}
+
+// function revalidate() verifies this library's root directory still exists
+// and can be read, e.g. that its file system is still mounted. the library's
+// offline state is updated accordingly.
+func (l *Library) revalidate() error {
+
+	dir, err := os.Open(l.absPath)
+	if nil == err {
+		_, err = dir.Readdirnames(1)
+		dir.Close()
+	}
+	// Readdirnames(n > 0) returns io.EOF for an empty directory, which isn't
+	// a problem with the directory itself.
+	if nil != err && io.EOF != err {
+		atomic.StoreUint32(&l.offline, 1)
+		return err
+	}
+	atomic.StoreUint32(&l.offline, 0)
+	return nil
+}
+
+// function isOffline() returns true if this library's root directory could
+// not be read the last time it was revalidated.
+func (l *Library) isOffline() bool {
+	return 0 != atomic.LoadUint32(&l.offline)
+}
+
+// function isScanning() returns true if any goroutine is currently scanning
+// this library's file system.
+func (l *Library) isScanning() bool {
+	return len(l.scanStart) > 0
+}
+
+// function requestRescan() flags this library to be rescanned by the goroutine
+// currently scanning it once that scan has finished.
+func (l *Library) requestRescan() {
+	atomic.StoreUint32(&l.rescan, 1)
+}
+
+// function takeRescanRequest() clears this library's rescan flag, returning
+// true if it was set.
+func (l *Library) takeRescanRequest() bool {
+	return 0 != atomic.SwapUint32(&l.rescan, 0)
+}
@@ -0,0 +1,73 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: symlink.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the traversal of symlinks while scanning (if enabled with option
+//    -followsymlinks), so that libraries assembled from symlinked mount points
+//    can be indexed. a symlink is scanned as the file or directory it points
+//    to, under the path of the symlink itself. a symlinked directory is never
+//    scanned if it contains the symlink (a loop), if it is already part of the
+//    library, or if it was already reached through another symlink during the
+//    same scan.
+//
+// =============================================================================
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// function resetSymlinks() forgets the directories reached through symlinks,
+// at the beginning of each scan.
+func (l *Library) resetSymlinks() {
+	l.linkMutex.Lock()
+	defer l.linkMutex.Unlock()
+	l.linkTarget = map[string]bool{}
+}
+
+// function followSymlink() returns the file info of the file or directory the
+// given symlink points to. returns nil (and no error) if the symlink must be
+// skipped, i.e. if it points to a directory already scanned.
+func (l *Library) followSymlink(absPath string) (os.FileInfo, *ReturnCode) {
+
+	target, err := filepath.EvalSymlinks(absPath)
+	if nil != err {
+		return nil, rcInvalidFile.wrap(err, "followSymlink(%q): broken symlink (skipping)", absPath)
+	}
+	// stat the symlink's own path, so that the file keeps the symlink's name.
+	info, err := os.Stat(absPath)
+	if nil != err {
+		return nil, rcInvalidStat.wrap(err, "followSymlink(%q): os.Stat()", absPath)
+	}
+	if !info.IsDir() {
+		return info, nil
+	}
+
+	// a directory containing the symlink would be scanned endlessly.
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(absPath)); nil == err && isBeneath(dir, target) {
+		return nil, rcInvalidFile.specf(
+			"followSymlink(%q): symlink loop: points to its own ancestor %q (skipping)", absPath, target)
+	}
+	// directories of the library itself are scanned under their own paths.
+	if isBeneath(target, l.absPath) {
+		infoLog.tracef("skipping symlink to a directory of the library: %q -> %q", absPath, target)
+		return nil, nil
+	}
+	l.linkMutex.Lock()
+	defer l.linkMutex.Unlock()
+	if nil == l.linkTarget {
+		l.linkTarget = map[string]bool{}
+	}
+	if l.linkTarget[target] {
+		infoLog.tracef("skipping symlink to a directory already scanned: %q -> %q", absPath, target)
+		return nil, nil
+	}
+	l.linkTarget[target] = true
+	return info, nil
+}
@@ -0,0 +1,616 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: tags.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the extraction of the tags embedded in audio files while scanning:
+//    ID3v2 (and the older ID3v1) tags of MP3 files, Vorbis comments of FLAC,
+//    Ogg Vorbis and Opus files, and the metadata atoms of MP4 (M4A, M4B) files.
+//    the title, artist, album, track number and release date found are stored
+//    in the audio's record, so that the media browser can list audio by album.
+//
+// =============================================================================
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// local unexported constants for reading embedded audio tags.
+const (
+	maxTagSize  = 16 * mebiBytes // tags larger than this (e.g. with huge cover art) are skipped
+	maxOggProbe = 2 * mebiBytes  // bytes of an Ogg file searched for its comment header
+	id3v1Size   = 128            // size of an ID3v1 tag, at the end of the file
+)
+
+// type AudioTags holds the tags read from an audio file. when several tags of
+// a file provide the same field, the first one found is kept.
+type AudioTags struct {
+	title  string
+	artist string
+	album  string
+	track  int64
+	date   string
+}
+
+// function set() stores the value of the given field, unless it was already
+// found.
+func (t *AudioTags) set(field, value string) {
+
+	value = strings.TrimSpace(strings.TrimRight(value, "\x00"))
+	if "" == value {
+		return
+	}
+	switch field {
+	case "title":
+		if "" == t.title {
+			t.title = value
+		}
+	case "artist":
+		if "" == t.artist {
+			t.artist = value
+		}
+	case "album":
+		if "" == t.album {
+			t.album = value
+		}
+	case "track":
+		// track numbers are often of the form "3/12" (track 3 of 12).
+		if t.track <= 0 {
+			if n, err := strconv.ParseInt(strings.SplitN(value, "/", 2)[0], 10, 64); nil == err && n > 0 {
+				t.track = n
+			}
+		}
+	case "date":
+		if "" == t.date {
+			t.date = value
+		}
+	}
+}
+
+// function complete() returns true if every field has been found.
+func (t *AudioTags) complete() bool {
+	return "" != t.title && "" != t.artist && "" != t.album && t.track > 0 && "" != t.date
+}
+
+// function releaseDate() parses the release date found, which is usually just
+// a year, but may be a full date (e.g. "2006-01-02"). returns false if there
+// is no date, or it cannot be parsed.
+func (t *AudioTags) releaseDate() (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01"} {
+		if d, err := time.Parse(layout, t.date); nil == err {
+			return d, true
+		}
+	}
+	if len(t.date) >= 4 {
+		if year, err := strconv.Atoi(t.date[:4]); nil == err && year > 0 {
+			return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// function readTags() reads the tags embedded in the audio file and stores the
+// fields found in the AudioMedia. files without tags (or unreadable ones) are
+// left as they are.
+func (m *AudioMedia) readTags() {
+
+	tags, err := readAudioTags(m.AbsPath)
+	if nil != err {
+		warnLog.tracef("cannot read tags: %q: %s", m.AbsPath, err)
+		return
+	}
+	if "" != tags.title {
+		m.Title = tags.title
+	}
+	if "" != tags.artist {
+		m.Artist = tags.artist
+	}
+	if "" != tags.album {
+		m.Album = tags.album
+	}
+	if tags.track > 0 {
+		m.Track = tags.track
+	}
+	if date, ok := tags.releaseDate(); ok {
+		m.ReleaseDate = date
+	}
+}
+
+// function readAudioTags() reads the tags embedded in the given audio file,
+// identified by its content rather than its file name extension.
+func readAudioTags(absPath string) (*AudioTags, error) {
+
+	f, err := os.Open(absPath)
+	if nil != err {
+		return nil, err
+	}
+	defer f.Close()
+
+	tags := &AudioTags{}
+	head := make([]byte, 12)
+	n, err := io.ReadFull(f, head)
+	if nil != err && io.ErrUnexpectedEOF != err {
+		return tags, err
+	}
+	head = head[:n]
+
+	// FLAC files may begin with an ID3v2 tag too.
+	offset := int64(0)
+	if bytes.HasPrefix(head, []byte("ID3")) {
+		size, err := readID3v2(f, tags)
+		if nil != err {
+			return tags, err
+		}
+		offset = size
+		head = head[:0]
+		if _, err := f.Seek(offset, io.SeekStart); nil == err {
+			head = make([]byte, 12)
+			n, _ := io.ReadFull(f, head)
+			head = head[:n]
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		err = readFLAC(f, offset+4, tags)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		err = readOgg(f, offset, tags)
+	case len(head) >= 8 && "ftyp" == string(head[4:8]):
+		err = readMP4(f, offset, tags)
+	}
+	if nil != err {
+		return tags, err
+	}
+
+	if !tags.complete() {
+		readID3v1(f, tags)
+	}
+	return tags, nil
+}
+
+//------------------------------------------------------------------------------
+
+// function syncsafe() decodes the 28-bit integers of ID3v2 headers, whose most
+// significant bit of each byte is zero.
+func syncsafe(b []byte) int64 {
+	var n int64
+	for _, c := range b {
+		n = n<<7 | int64(c&0x7F)
+	}
+	return n
+}
+
+// function unsynchronize() reverses the unsynchronization of ID3v2 data, which
+// inserts a zero byte after every 0xFF byte.
+func unsynchronize(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// function decodeID3Text() decodes the content of an ID3v2 text frame, whose
+// first byte is the encoding of the text. only the first of multiple values
+// is returned.
+func decodeID3Text(b []byte) string {
+
+	if 0 == len(b) {
+		return ""
+	}
+	enc, b := b[0], b[1:]
+	switch enc {
+	case 1, 2: // UTF-16 (with BOM), UTF-16BE
+		order := binary.ByteOrder(binary.BigEndian)
+		if 1 == enc && len(b) >= 2 {
+			if 0xFF == b[0] && 0xFE == b[1] {
+				order = binary.LittleEndian
+			}
+			if (0xFF == b[0] && 0xFE == b[1]) || (0xFE == b[0] && 0xFF == b[1]) {
+				b = b[2:]
+			}
+		}
+		u := []uint16{}
+		for i := 0; i+1 < len(b); i += 2 {
+			c := order.Uint16(b[i:])
+			if 0 == c {
+				break
+			}
+			u = append(u, c)
+		}
+		return string(utf16.Decode(u))
+	case 3: // UTF-8
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return string(b)
+	default: // ISO-8859-1
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		return string(r)
+	}
+}
+
+var (
+	// variable id3Field maps the IDs of ID3v2 text frames (v2.2 and v2.3/v2.4)
+	// to the fields of AudioTags.
+	id3Field = map[string]string{
+		"TT2": "title", "TIT2": "title",
+		"TP1": "artist", "TPE1": "artist",
+		"TP2": "artist", "TPE2": "artist", // album artist, if no track artist
+		"TAL": "album", "TALB": "album",
+		"TRK": "track", "TRCK": "track",
+		"TYE": "date", "TYER": "date", "TDRC": "date", "TDRL": "date",
+	}
+)
+
+// function readID3v2() reads the ID3v2 tag at the beginning of the file, and
+// returns its total size (i.e., the offset of the audio following it).
+func readID3v2(f *os.File, tags *AudioTags) (int64, error) {
+
+	header := make([]byte, 10)
+	if _, err := f.ReadAt(header, 0); nil != err {
+		return 0, err
+	}
+	version, flags := header[3], header[5]
+	size := syncsafe(header[6:10])
+	total := 10 + size
+	if 0 != flags&0x10 {
+		total += 10 // footer (v2.4)
+	}
+	if version < 2 || version > 4 || size > maxTagSize {
+		return total, nil
+	}
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 10); nil != err && io.EOF != err {
+		return total, err
+	}
+	if version < 4 && 0 != flags&0x80 {
+		data = unsynchronize(data)
+	}
+	// skip the extended header, if any.
+	if 0 != flags&0x40 && version > 2 && len(data) >= 4 {
+		ext := int64(binary.BigEndian.Uint32(data))
+		if 4 == version {
+			ext = syncsafe(data[:4])
+		} else {
+			ext += 4
+		}
+		if ext > int64(len(data)) {
+			return total, nil
+		}
+		data = data[ext:]
+	}
+
+	idLen, headLen := 4, 10
+	if 2 == version {
+		idLen, headLen = 3, 6
+	}
+	for len(data) >= headLen && 0 != data[0] {
+		id := string(data[:idLen])
+		var frameSize int64
+		var frameFlags uint16
+		switch version {
+		case 2:
+			frameSize = int64(data[3])<<16 | int64(data[4])<<8 | int64(data[5])
+		case 3:
+			frameSize = int64(binary.BigEndian.Uint32(data[4:]))
+			frameFlags = binary.BigEndian.Uint16(data[8:])
+		case 4:
+			frameSize = syncsafe(data[4:8])
+			frameFlags = binary.BigEndian.Uint16(data[8:])
+		}
+		if frameSize > int64(len(data)-headLen) {
+			break
+		}
+		frame := data[headLen : int64(headLen)+frameSize]
+		data = data[int64(headLen)+frameSize:]
+
+		field, ok := id3Field[id]
+		if !ok {
+			continue
+		}
+		// compressed and encrypted frames are skipped.
+		if (3 == version && 0 != frameFlags&0x00C0) || (4 == version && 0 != frameFlags&0x000C) {
+			continue
+		}
+		if 4 == version && 0 != frameFlags&0x0002 {
+			frame = unsynchronize(frame)
+		}
+		if 4 == version && 0 != frameFlags&0x0001 && len(frame) >= 4 {
+			frame = frame[4:] // data length indicator
+		}
+		tags.set(field, decodeID3Text(frame))
+	}
+	return total, nil
+}
+
+// function readID3v1() reads the ID3v1 tag at the end of the file, if any.
+func readID3v1(f *os.File, tags *AudioTags) {
+
+	info, err := f.Stat()
+	if nil != err || info.Size() < id3v1Size {
+		return
+	}
+	b := make([]byte, id3v1Size)
+	if _, err := f.ReadAt(b, info.Size()-id3v1Size); nil != err || "TAG" != string(b[:3]) {
+		return
+	}
+	text := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return decodeID3Text(append([]byte{0}, b...))
+	}
+	tags.set("title", text(b[3:33]))
+	tags.set("artist", text(b[33:63]))
+	tags.set("album", text(b[63:93]))
+	tags.set("date", text(b[93:97]))
+	// ID3v1.1 stores the track number in the last byte of the comment.
+	if 0 == b[125] && 0 != b[126] {
+		tags.set("track", strconv.Itoa(int(b[126])))
+	}
+}
+
+//------------------------------------------------------------------------------
+
+var (
+	// variable vorbisField maps the names of Vorbis comments (upper case) to
+	// the fields of AudioTags.
+	vorbisField = map[string]string{
+		"TITLE":       "title",
+		"ARTIST":      "artist",
+		"ALBUMARTIST": "artist",
+		"ALBUM":       "album",
+		"TRACKNUMBER": "track",
+		"DATE":        "date",
+		"YEAR":        "date",
+	}
+)
+
+// function readVorbisComment() reads the fields of a Vorbis comment block,
+// which is shared by FLAC, Ogg Vorbis and Opus files.
+func readVorbisComment(b []byte, tags *AudioTags) {
+
+	next := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+		n := int64(binary.LittleEndian.Uint32(b))
+		if n > int64(len(b)-4) {
+			return nil, false
+		}
+		s := b[4 : 4+n]
+		b = b[4+n:]
+		return s, true
+	}
+	if _, ok := next(); !ok { // vendor string
+		return
+	}
+	if len(b) < 4 {
+		return
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	for i := uint32(0); i < count; i++ {
+		c, ok := next()
+		if !ok {
+			return
+		}
+		kv := strings.SplitN(string(c), "=", 2)
+		if 2 != len(kv) {
+			continue
+		}
+		if field, ok := vorbisField[strings.ToUpper(kv[0])]; ok {
+			tags.set(field, kv[1])
+		}
+	}
+}
+
+// function readFLAC() reads the Vorbis comment metadata block of a FLAC file,
+// whose metadata blocks begin at the given offset.
+func readFLAC(f *os.File, offset int64, tags *AudioTags) error {
+
+	header := make([]byte, 4)
+	for {
+		if _, err := f.ReadAt(header, offset); nil != err {
+			return err
+		}
+		last, kind := 0 != header[0]&0x80, header[0]&0x7F
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += 4
+		if 4 == kind { // VORBIS_COMMENT
+			block := make([]byte, size)
+			if _, err := f.ReadAt(block, offset); nil != err {
+				return err
+			}
+			readVorbisComment(block, tags)
+			return nil
+		}
+		if last {
+			return nil
+		}
+		offset += size
+	}
+}
+
+// function readOgg() reads the comment header of the first logical stream of
+// an Ogg Vorbis or Opus file, which is its second packet.
+func readOgg(f *os.File, offset int64, tags *AudioTags) error {
+
+	var packet []byte
+	count := 0
+	header := make([]byte, 27)
+	for offset < maxOggProbe {
+		if _, err := f.ReadAt(header, offset); nil != err {
+			return err
+		}
+		if "OggS" != string(header[:4]) {
+			return nil
+		}
+		segment := make([]byte, header[26])
+		if _, err := f.ReadAt(segment, offset+27); nil != err {
+			return err
+		}
+		offset += 27 + int64(len(segment))
+		for _, n := range segment {
+			if 1 == count {
+				b := make([]byte, n)
+				if _, err := f.ReadAt(b, offset); nil != err {
+					return err
+				}
+				packet = append(packet, b...)
+			}
+			offset += int64(n)
+			// a segment shorter than 255 bytes ends the packet.
+			if n < 255 {
+				if 1 == count {
+					switch {
+					case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+						readVorbisComment(packet[7:], tags)
+					case bytes.HasPrefix(packet, []byte("OpusTags")):
+						readVorbisComment(packet[8:], tags)
+					}
+					return nil
+				}
+				count++
+			}
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+var (
+	// variable mp4Field maps the names of MP4 metadata item atoms to the fields
+	// of AudioTags.
+	mp4Field = map[string]string{
+		"\xA9nam": "title",
+		"\xA9ART": "artist",
+		"aART":    "artist",
+		"\xA9alb": "album",
+		"trkn":    "track",
+		"\xA9day": "date",
+	}
+)
+
+// function mp4Atoms() calls the given function with the type and content of
+// each atom contained in the given bytes, until it returns false.
+func mp4Atoms(b []byte, fn func(kind string, body []byte) bool) {
+	for len(b) >= 8 {
+		size, head := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		kind := string(b[4:8])
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return
+			}
+			size, head = binary.BigEndian.Uint64(b[8:]), 16
+		}
+		if size < head || size > uint64(len(b)) {
+			return
+		}
+		if !fn(kind, b[head:size]) {
+			return
+		}
+		b = b[size:]
+	}
+}
+
+// function readMP4() reads the metadata item list (moov.udta.meta.ilst) of an
+// MP4 file, whose top-level atoms begin at the given offset.
+func readMP4(f *os.File, offset int64, tags *AudioTags) error {
+
+	info, err := f.Stat()
+	if nil != err {
+		return err
+	}
+	// find the movie atom among the top-level atoms, without reading the
+	// (usually huge) media data atom.
+	header := make([]byte, 16)
+	for offset+8 <= info.Size() {
+		if _, err := f.ReadAt(header, offset); nil != err && io.EOF != err {
+			return err
+		}
+		size, head := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch size {
+		case 0:
+			size = info.Size() - offset
+		case 1:
+			size, head = int64(binary.BigEndian.Uint64(header[8:])), 16
+		}
+		if size < head {
+			return nil
+		}
+		if "moov" == string(header[4:8]) {
+			if size-head > maxTagSize {
+				return nil
+			}
+			moov := make([]byte, size-head)
+			if _, err := f.ReadAt(moov, offset+head); nil != err && io.EOF != err {
+				return err
+			}
+			readMP4Movie(moov, tags)
+			return nil
+		}
+		offset += size
+	}
+	return nil
+}
+
+// function readMP4Movie() reads the metadata item list of the given movie atom.
+func readMP4Movie(moov []byte, tags *AudioTags) {
+
+	child := func(b []byte, kind string) []byte {
+		var found []byte
+		mp4Atoms(b, func(k string, body []byte) bool {
+			if k == kind {
+				found = body
+				return false
+			}
+			return true
+		})
+		return found
+	}
+	meta := child(child(moov, "udta"), "meta")
+	// the meta atom is a "full" atom (with version and flags) in MP4 files,
+	// but not in QuickTime files.
+	if len(meta) >= 8 && "hdlr" != string(meta[4:8]) {
+		meta = meta[4:]
+	}
+	mp4Atoms(child(meta, "ilst"), func(kind string, body []byte) bool {
+		field, ok := mp4Field[kind]
+		if !ok {
+			return true
+		}
+		data := child(body, "data")
+		if len(data) < 8 {
+			return true
+		}
+		value := data[8:] // type and locale
+		if "track" == field {
+			if len(value) >= 4 {
+				tags.set(field, strconv.Itoa(int(binary.BigEndian.Uint16(value[2:]))))
+			}
+		} else {
+			tags.set(field, string(value))
+		}
+		return true
+	})
+}
@@ -0,0 +1,277 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: target.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the playback targets, i.e. where media are played. besides this
+//    host ("local"), media may be played on another host by running the
+//    playback command there over SSH, e.g. on the HTPC in the living room,
+//    which must see the library at the same path (e.g. a shared mount). the
+//    target is picked in the user interface, and the last one used is
+//    remembered in the data directory, so that each profile (data directory)
+//    keeps its own.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for playback targets.
+const (
+	targetLocal    = "local"           // name of the target playing on this host
+	targetFileName = "playback-target" // file in data dir with last target used
+	targetSSH      = "ssh"             // command used to run players on remote hosts
+)
+
+// type PlaybackTarget is a host on which media are played.
+type PlaybackTarget struct {
+	name string
+	host string // SSH destination [USER@]HOST, or empty for this host
+}
+
+var (
+	// variable playbackTarget contains every playback target, beginning with
+	// this host.
+	playbackTarget = []*PlaybackTarget{{name: targetLocal, host: ""}}
+
+	// variable currTarget is the playback target currently selected.
+	currTarget = playbackTarget[0]
+
+	// variable targetFilePath is the file in which the name of the last target
+	// selected is remembered.
+	targetFilePath = ""
+)
+
+// function parsePlaybackTarget() parses a playback target declaration of the
+// form NAME=[USER@]HOST.
+func parsePlaybackTarget(spec string) (*PlaybackTarget, error) {
+	part := strings.SplitN(spec, "=", 2)
+	if 2 != len(part) {
+		return nil, fmt.Errorf("playback target %q: expected NAME=[USER@]HOST", spec)
+	}
+	name, host := strings.TrimSpace(part[0]), strings.TrimSpace(part[1])
+	if "" == name || "" == host || strings.ContainsAny(host, " \t") {
+		return nil, fmt.Errorf("playback target %q: expected NAME=[USER@]HOST", spec)
+	}
+	if strings.EqualFold(targetLocal, name) {
+		return nil, fmt.Errorf("playback target %q: name %q is reserved for this host", spec, targetLocal)
+	}
+	return &PlaybackTarget{name: name, host: host}, nil
+}
+
+// function setPlaybackTargets() records the playback targets declared by the
+// given options, which must have already been validated, and selects the last
+// target used with the data directory. the targets declared previously are
+// replaced (e.g., when the config file is reloaded).
+func setPlaybackTargets(opt *Options) {
+
+	known, curr := playbackTargets()
+	declared := []*PlaybackTarget{known[0]} // this host is always first
+	if ffTargets.gate(opt, opt.Target) {
+		for _, spec := range opt.Target.StringList {
+			if target, err := parsePlaybackTarget(spec); nil == err {
+				if nil == findTarget(declared, target.name) {
+					declared = append(declared, target)
+				}
+			}
+		}
+	}
+	selected := findTarget(declared, curr.name)
+	if nil == selected {
+		selected = declared[0]
+	}
+
+	path := filepath.Join(opt.LibData.string, targetFileName)
+	if data, err := ioutil.ReadFile(path); nil == err {
+		name := strings.TrimSpace(string(data))
+		if target := findTarget(declared, name); nil != target {
+			selected = target
+			infoLog.verbosef("playback target: %s", target.desc())
+		} else {
+			warnLog.verbosef("(ignored) last playback target is no longer declared: %q", name)
+		}
+	}
+
+	liveMutex.Lock()
+	playbackTarget = declared
+	currTarget = selected
+	targetFilePath = path
+	liveMutex.Unlock()
+}
+
+// function playbackTargets() returns every playback target, along with the
+// playback target currently selected.
+func playbackTargets() ([]*PlaybackTarget, *PlaybackTarget) {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	return playbackTarget, currTarget
+}
+
+// function currentTarget() returns the playback target currently selected.
+func currentTarget() *PlaybackTarget {
+	_, curr := playbackTargets()
+	return curr
+}
+
+// function findTarget() returns the playback target of the given list with the
+// given name (case-insensitive), or nil if there is none.
+func findTarget(list []*PlaybackTarget, name string) *PlaybackTarget {
+	for _, target := range list {
+		if strings.EqualFold(target.name, name) {
+			return target
+		}
+	}
+	return nil
+}
+
+// function selectTarget() selects the given playback target and remembers it
+// in the data directory.
+func selectTarget(target *PlaybackTarget) *ReturnCode {
+	liveMutex.Lock()
+	currTarget = target
+	path := targetFilePath
+	liveMutex.Unlock()
+	if "" == path {
+		return nil
+	}
+	if err := ioutil.WriteFile(path, []byte(target.name+"\n"), 0600); nil != err {
+		return rcInvalidPath.specf("selectTarget(%q): ioutil.WriteFile(): %s", target.name, err)
+	}
+	return nil
+}
+
+// function desc() returns a description of the playback target.
+func (t *PlaybackTarget) desc() string {
+	if "" == t.host {
+		return fmt.Sprintf("%s (this host)", t.name)
+	}
+	return fmt.Sprintf("%s (%s)", t.name, t.host)
+}
+
+// function quoteArg() quotes the given argument of a playback command (e.g. a
+// media path) for the shell running the command on the current playback
+// target, so that it is passed to the player as-is, whatever characters it
+// contains. the shells of remote targets are presumed to be POSIX shells.
+func quoteArg(arg string) string {
+	if "" == currentTarget().host {
+		return shellQuote(arg)
+	}
+	return posixQuote(arg)
+}
+
+// function posixQuote() quotes the given argument for a POSIX shell: enclosed
+// in single quotes, nothing is expanded, and each single quote it contains is
+// closed, escaped, and reopened.
+func posixQuote(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// function playerCommand() returns the command running the given playback
+// command on the current playback target, with the player arguments of the
+// target's host appended.
+func playerCommand(cmd string) *exec.Cmd {
+	target := currentTarget()
+	if "" == target.host {
+		return shellCommand(withHostArgs(cmd, localHostName()))
+	}
+	// the host args are declared by host name, without any user.
+	host := target.host
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	// allocate a terminal so that the player may be controlled from this one.
+	return exec.Command(targetSSH, "-t", target.host, withHostArgs(cmd, host))
+}
+
+// type TargetPickerView is the dialog used to select the playback target.
+type TargetPickerView struct {
+	*tview.List
+	layout    *Layout
+	focusPage string
+	focusNext FocusDelegator
+	focusPrev FocusDelegator
+}
+
+// function newTargetPickerView() allocates and initializes the dialog widgets.
+func newTargetPickerView(ui *tview.Application, page string, lib []*Library) *TargetPickerView {
+
+	v := TargetPickerView{
+		List:      tview.NewList(),
+		layout:    nil,
+		focusPage: page,
+		focusNext: nil,
+		focusPrev: nil,
+	}
+
+	v.List.
+		ShowSecondaryText(false).
+		SetMainTextColor(colorScheme.inactiveMenuText).
+		SetSelectedTextColor(colorScheme.activeMenuText).
+		SetSelectedBackgroundColor(colorScheme.backgroundSecondary).
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft).
+		SetTitle(" Play on ")
+
+	return &v
+}
+
+func (v *TargetPickerView) desc() string { return "" }
+func (v *TargetPickerView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *TargetPickerView) page() string         { return v.focusPage }
+func (v *TargetPickerView) next() FocusDelegator { return v.focusNext }
+func (v *TargetPickerView) prev() FocusDelegator { return v.focusPrev }
+func (v *TargetPickerView) focus() {
+	v.refresh()
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.List)
+}
+func (v *TargetPickerView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function refresh() lists the playback targets, selecting the current one.
+func (v *TargetPickerView) refresh() {
+
+	v.Clear()
+	known, curr := playbackTargets()
+	for i, target := range known {
+		mark := " "
+		if target == curr {
+			mark = "*"
+		}
+		t := target
+		v.AddItem(fmt.Sprintf("%s %s", mark, target.desc()), "", 0, func() { v.pick(t) })
+		if target == curr {
+			v.SetCurrentItem(i)
+		}
+	}
+}
+
+// function pick() selects the given playback target and closes the dialog.
+func (v *TargetPickerView) pick(target *PlaybackTarget) {
+	if ret := selectTarget(target); nil != ret {
+		warnLog.log(ret)
+	}
+	infoLog.logf("playing on %s", target.desc())
+	v.layout.focusQueue <- v.layout.focusBase
+}
@@ -0,0 +1,169 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: timefmt.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines how timestamps are displayed (status bar clock, last scan, date
+//    added, change logs, etc.), so that every view uses the same format and
+//    time zone. the format is either one of the named styles following a
+//    common locale convention, the relative style (e.g. "2 days ago"), or a
+//    custom Go time layout. month and day names are always English.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// local unexported constants for displaying timestamps.
+const (
+	timeStyleRelative = "relative"          // style of relative timestamps
+	timeRelativeLimit = 30 * 24 * time.Hour // max age of a relative timestamp, beyond which the date is shown
+	timeRelativeDate  = "2006-01-02"        // layout of dates too old to be relative
+	timeClockDefault  = "2006-01-02 15:04"  // layout of the clock with relative timestamps
+	timeNever         = "never"             // display of a zero timestamp
+	timeDefaultStyle  = "iso"               // style used when none is given
+)
+
+var (
+	// variable timeStyle maps each named style to its time layout.
+	timeStyle = map[string]string{
+		"iso": "2006-01-02 15:04:05",    // ISO 8601
+		"us":  "01/02/2006 03:04:05 PM", // United States
+		"eu":  "02.01.2006 15:04:05",    // continental Europe
+		"uk":  "02/01/2006 15:04:05",    // United Kingdom
+	}
+
+	// variable timeLayout is the layout of displayed timestamps, or empty if
+	// they are relative.
+	timeLayout = timeStyle[timeDefaultStyle]
+
+	// variable timeZone is the time zone of displayed timestamps.
+	timeZone = time.Local
+
+	// variable timeProbe is a time which differs from the reference time of Go
+	// time layouts in every element, used to verify custom layouts.
+	timeProbe = time.Date(2011, time.November, 12, 13, 14, 15, 0, time.UTC)
+)
+
+// function parseTimeFormat() returns the time layout of the given style name or
+// custom Go time layout, or an empty layout if timestamps are relative.
+func parseTimeFormat(spec string) (string, error) {
+	if strings.EqualFold(timeStyleRelative, spec) {
+		return "", nil
+	}
+	if layout, ok := timeStyle[strings.ToLower(spec)]; ok {
+		return layout, nil
+	}
+	// a custom layout must refer to some element of the reference time.
+	if timeProbe.Format(spec) == spec {
+		return "", fmt.Errorf("time format %q: expected one of %s, %s, or a Go time layout (e.g. %q)",
+			spec, strings.Join(timeStyleNames(), ", "), timeStyleRelative, timeStyle[timeDefaultStyle])
+	}
+	return spec, nil
+}
+
+// function timeStyleNames() returns the names of the time styles, in order.
+func timeStyleNames() []string {
+	return []string{"iso", "us", "eu", "uk"}
+}
+
+// function parseTimeZone() returns the time zone with the given IANA name (e.g.
+// "America/Chicago"), "Local", or "UTC".
+func parseTimeZone(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if nil != err {
+		return nil, fmt.Errorf("time zone %q: %s", name, err)
+	}
+	return loc, nil
+}
+
+// function setTimeFormat() selects the format and time zone of displayed
+// timestamps from the given options, which must have already been validated.
+func setTimeFormat(opt *Options) {
+	liveMutex.Lock()
+	defer liveMutex.Unlock()
+	if layout, err := parseTimeFormat(opt.TimeFormat.string); nil == err {
+		timeLayout = layout
+	}
+	if "" != opt.TimeZone.string {
+		if loc, err := parseTimeZone(opt.TimeZone.string); nil == err {
+			timeZone = loc
+		}
+	} else {
+		timeZone = time.Local
+	}
+}
+
+// function timeFormat() returns the layout and time zone of displayed
+// timestamps.
+func timeFormat() (string, *time.Location) {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	return timeLayout, timeZone
+}
+
+// function formatTime() returns the given timestamp as displayed to the user.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return timeNever
+	}
+	layout, zone := timeFormat()
+	if "" == layout {
+		return formatRelative(t, time.Now())
+	}
+	return t.In(zone).Format(layout)
+}
+
+// function formatClock() returns the given time as displayed by the status bar
+// clock, which is never relative.
+func formatClock(t time.Time) string {
+	layout, zone := timeFormat()
+	if "" == layout {
+		return t.In(zone).Format(timeClockDefault)
+	}
+	return t.In(zone).Format(layout)
+}
+
+// function formatRelative() returns the given timestamp relative to the given
+// current time, e.g. "5 minutes ago" or "in 2 days". timestamps further from
+// the current time than timeRelativeLimit are shown as a date.
+func formatRelative(t, now time.Time) string {
+
+	d := now.Sub(t)
+	ago := d >= 0
+	if !ago {
+		d = -d
+	}
+	if d > timeRelativeLimit {
+		_, zone := timeFormat()
+		return t.In(zone).Format(timeRelativeDate)
+	}
+
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	default:
+		n, unit = int(d/(24*time.Hour)), "day"
+	}
+	if n > 1 {
+		unit += "s"
+	}
+	if ago {
+		return fmt.Sprintf("%d %s ago", n, unit)
+	}
+	return fmt.Sprintf("in %d %s", n, unit)
+}
@@ -0,0 +1,206 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: title.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the terminal title, which reflects the current context: the
+//    active library (or all of them) and its load or scan progress. the
+//    original title is saved when we start and restored on exit. optionally,
+//    the same text is also published to tmux as window option "@pimm_status",
+//    which may be shown in the status line with "#{@pimm_status}".
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// local unexported constants for the terminal title.
+const (
+	titleUpdateFreq = time.Second // how often the title is recomputed
+	tmuxStatusName  = "@pimm_status"
+
+	// xterm control sequences, also understood by most other terminals.
+	titleSave    = "\033[22;0t"  // push the current title onto the title stack
+	titleRestore = "\033[23;0t"  // pop the saved title off of the title stack
+	titleSet     = "\033]2;%s\a" // set the window title
+)
+
+var (
+	// variable termTitle is the terminal title of this process, or nil if the
+	// title is not being set.
+	termTitle *TermTitle
+)
+
+// type TermTitle maintains the terminal title (and tmux status) while the
+// libraries are open.
+type TermTitle struct {
+	sync.Mutex
+	out    io.Writer  // the terminal
+	tmux   string     // tmux pane whose status is set (empty if none)
+	lib    []*Library // all open libraries
+	active *Library   // library selected in the UI (nil = all)
+	last   string     // text most recently set
+	done   chan bool  // closed to stop updating the title
+}
+
+// function isTerminal() returns true if the given file is a terminal capable
+// of handling control sequences.
+func isTerminal(f *os.File) bool {
+	if term := os.Getenv("TERM"); "" == term || "dumb" == term {
+		return false
+	}
+	info, err := f.Stat()
+	return nil == err && 0 != (info.Mode()&os.ModeCharDevice)
+}
+
+// function newTermTitle() creates the terminal title of the given libraries,
+// or returns nil if it was disabled or standard output is not a terminal.
+func newTermTitle(opt *Options, library []*Library) *TermTitle {
+	if !opt.TermTitle.bool || !isTerminal(os.Stdout) {
+		return nil
+	}
+	t := &TermTitle{out: os.Stdout, lib: library, done: make(chan bool)}
+	if opt.TmuxStatus.bool && "" != os.Getenv("TMUX") {
+		t.tmux = os.Getenv("TMUX_PANE")
+	}
+	return t
+}
+
+// function start() saves the current terminal title and spawns a goroutine
+// updating ours until stop() is called.
+func (t *TermTitle) start() {
+	if nil == t {
+		return
+	}
+	fmt.Fprint(t.out, titleSave)
+	go func() {
+		tick := time.NewTicker(titleUpdateFreq)
+		defer tick.Stop()
+		for {
+			t.update()
+			select {
+			case <-tick.C:
+			case <-t.done:
+				return
+			}
+		}
+	}()
+}
+
+// function stop() stops updating the terminal title, restoring the title that
+// was saved by start(), and removes our tmux status.
+func (t *TermTitle) stop() {
+	if nil == t {
+		return
+	}
+	close(t.done)
+	t.Lock()
+	defer t.Unlock()
+	fmt.Fprint(t.out, titleRestore)
+	if "" != t.tmux {
+		exec.Command("tmux", "set-option", "-q", "-w", "-u", "-t", t.tmux, tmuxStatusName).Run()
+	}
+}
+
+// function setActive() changes the library whose context is shown. a nil
+// library shows all libraries.
+func (t *TermTitle) setActive(lib *Library) {
+	if nil == t {
+		return
+	}
+	t.Lock()
+	t.active = lib
+	t.Unlock()
+	t.update()
+}
+
+// function text() describes the current context of the active library, or of
+// all libraries if none is active.
+func (t *TermTitle) text() string {
+
+	lib := []*Library{}
+	for _, l := range t.lib {
+		if nil == t.active || l == t.active {
+			lib = append(lib, l)
+		}
+	}
+	name := "all libraries"
+	if 1 == len(lib) {
+		name = lib[0].name
+	}
+
+	var count, total uint64
+	loading, scanning, media := false, false, uint(0)
+	var dir *DirProgressEntry
+	for _, l := range lib {
+		if c, n, ok := l.loadProgress(); ok {
+			count, total, loading = count+c, total+n, true
+		}
+		if l.isScanning() {
+			scanning = true
+			if d, ok := l.dirProgress.current(); ok {
+				dir = &d
+			}
+		}
+		loaded, _ := l.db.totalRecordsString(dmLoad, -1, -1)
+		found, _ := l.db.totalRecordsString(dmScan, -1, -1)
+		media += loaded + found
+	}
+
+	var status string
+	switch {
+	case loading:
+		status = fmt.Sprintf("loading %d/%d", count, total)
+	case scanning && nil != dir:
+		status = fmt.Sprintf("scanning %s %d/%d (%d found)", dir.path, dir.count, dir.total, media)
+	case scanning:
+		status = fmt.Sprintf("scanning (%d found)", media)
+	default:
+		status = fmt.Sprintf("%d media", media)
+	}
+	return fmt.Sprintf("%s: %s: %s", identity, name, status)
+}
+
+// function update() sets the terminal title (and tmux status) if the context
+// changed since it was last set.
+func (t *TermTitle) update() {
+
+	t.Lock()
+	defer t.Unlock()
+
+	// control characters (e.g. in library names) would end the sequence early.
+	s := strings.Map(func(r rune) rune {
+		if r < ' ' || 0x7f == r {
+			return -1
+		}
+		return r
+	}, t.text())
+	if s == t.last {
+		return
+	}
+	t.last = s
+
+	select {
+	case <-t.done:
+		return // stopped; the original title was already restored
+	default:
+	}
+	fmt.Fprintf(t.out, titleSet, s)
+	if "" != t.tmux {
+		if err := exec.Command("tmux", "set-option", "-q", "-w", "-t", t.tmux, tmuxStatusName, s).Run(); nil != err {
+			warnLog.tracef("cannot set tmux status: %s", err)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: tombstone.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the tombstones of records removed from a library database. a
+//    record is never removed outright (unless option -tombstones is 0): a
+//    tombstone retaining the record, along with the relationships of its
+//    media, is written in its place and kept for the retention period. this
+//    lets the removal be undone, and lets a file reappearing elsewhere under
+//    the same name and size (i.e. moved) inherit its previous record, so that
+//    its notes, tags, playback statistics, etc. survive the move even if it
+//    spans several scans. the moves detected are listed in the change log.
+//
+// =============================================================================
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell"
+)
+
+// local unexported constants for tombstones.
+const (
+	// name of the database collection containing all tombstones.
+	tombstoneColName = "Tombstones"
+
+	defaultTombstoneRetention = "720h" // time tombstones are kept (30 days)
+
+	// reasons for which records are removed.
+	tombstoneMissing = "missing" // file no longer exists
+	tombstoneMoved   = "moved"   // file found elsewhere under the same name
+)
+
+var (
+	// variable tombstoneIndex lists the indices on the tombstones collection,
+	// used to find the records of files which may have been moved.
+	tombstoneIndex = [][]string{{"AbsName"}}
+
+	// variable entityFieldName lists the fields of the records which describe
+	// the file itself (see type Entity), as opposed to the media it contains.
+	// a moved file inherits every field of its previous record but these.
+	entityFieldName = func() []string {
+		name := []string{}
+		t := reflect.TypeOf(Entity{})
+		for i := 0; i < t.NumField(); i++ {
+			name = append(name, t.Field(i).Name)
+		}
+		return name
+	}()
+)
+
+// type Tombstone is the record of a removed database record, retaining the
+// record along with the relationships of its media.
+type Tombstone struct {
+	Class        EntityClass  // class of the record removed
+	Kind         int          // kind of the record removed (MediaKind or SupportKind)
+	ID           int          // hash key ID of the record removed
+	AbsPath      string       // absolute path to the file of the record removed
+	AbsName      string       // file name portion of AbsPath
+	RelPath      string       // library-relative path to the file
+	Size         int64        // length in bytes of the file
+	Reason       string       // why the record was removed
+	Removed      time.Time    // when the record was removed
+	Relationship []Relation   // relationships of the record's media
+	Record       EntityRecord // the record removed
+}
+
+// type TombstoneLog holds the state of a library's tombstones.
+type TombstoneLog struct {
+	sync.Mutex
+	retain time.Duration  // time tombstones are kept (0 = records are removed outright)
+	last   []int          // hash key IDs of the tombstones written by the most recent removal
+	moved  []SnapshotMove // moves detected by the current scan
+
+	// claim is held while a tombstone is found and exhumed, so that each is
+	// claimed once, even by the workers of a scan finding identical files at
+	// the same time (see scanpool.go).
+	claim sync.Mutex
+}
+
+// function parseTombstoneRetention() parses the retention period given by
+// option -tombstones.
+func parseTombstoneRetention(spec string) (time.Duration, error) {
+	d, err := time.ParseDuration(spec)
+	if nil != err {
+		return 0, fmt.Errorf("tombstones %q: %s", spec, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("tombstones %q: must not be negative", spec)
+	}
+	return d, nil
+}
+
+// function newTombstoneLog() creates a new TombstoneLog keeping tombstones for
+// the given retention period.
+func newTombstoneLog(retain time.Duration) *TombstoneLog {
+	return &TombstoneLog{retain: retain, last: []int{}, moved: []SnapshotMove{}}
+}
+
+// function toRecord() creates a struct capable of being stored in the database.
+func (t *Tombstone) toRecord() (*EntityRecord, *ReturnCode) {
+
+	record := &EntityRecord{}
+	data, err := json.Marshal(t)
+	if nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Marshal(%q): cannot marshal Tombstone struct into JSON object: %s", t.AbsPath, err)
+	}
+	if err = json.Unmarshal(data, record); nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"toRecord(): json.Unmarshal(%s): cannot unmarshal JSON object into EntityRecord struct: %s", string(data), err)
+	}
+	return record, nil
+}
+
+// function readTombstone() reads the tombstone with the given hash key ID.
+func (d *Database) readTombstone(id int) (*Tombstone, *ReturnCode) {
+
+	read, err := d.tombstones.Read(id)
+	if nil != err {
+		return nil, rcDatabaseError.specf(
+			"readTombstone(%d): %s: Read(): %s", id, d, err)
+	}
+	data, _ := json.Marshal(read)
+	t := &Tombstone{}
+	if err := json.Unmarshal(data, t); nil != err {
+		return nil, rcInvalidJSONData.specf(
+			"readTombstone(%d): cannot unmarshal JSON object into Tombstone struct: %s", id, err)
+	}
+	return t, nil
+}
+
+// function buryRecord() removes the given record from this library's database,
+// along with the relationships of its media, writing a tombstone in its place
+// (unless tombstones are not kept). the size of a media removed no longer
+// counts toward the library's size budget. returns the hash key ID of the
+// tombstone, or -1 if none was written.
+func (l *Library) buryRecord(class EntityClass, kind, id int, reason string) (int, *ReturnCode) {
+
+	col := l.db.col[class][kind]
+	read, err := col.Read(id)
+	if nil != err {
+		return -1, rcDatabaseError.specf(
+			"buryRecord(%d): %s: Read(): %s", id, l.db, err)
+	}
+	rec := EntityRecord(read)
+	t := &Tombstone{
+		Class:        class,
+		Kind:         kind,
+		ID:           id,
+		AbsPath:      recordString(rec, "AbsPath"),
+		AbsName:      recordString(rec, "AbsName"),
+		RelPath:      recordString(rec, "RelPath"),
+		Reason:       reason,
+		Removed:      time.Now(),
+		Relationship: []Relation{},
+		Record:       rec,
+	}
+	if size, ok := rec["Size"].(float64); ok {
+		t.Size = int64(size)
+	}
+
+	// the relationships of the media are removed with it, but retained by its
+	// tombstone.
+	end := []*RelationEnd{}
+	if ecMedia == class && nil != l.db.relations {
+		ref := mediaRef(MediaKind(kind), id)
+		var ret *ReturnCode
+		if end, ret = l.db.findRelations(ref); nil != ret {
+			return -1, ret
+		}
+		for _, e := range end {
+			if relationDesc[e.kind][0] == e.desc {
+				t.Relationship = append(t.Relationship, Relation{relationKindName[e.kind], ref, e.ref})
+			} else {
+				t.Relationship = append(t.Relationship, Relation{relationKindName[e.kind], e.ref, ref})
+			}
+		}
+	}
+
+	tid := -1
+	if l.tombstones.retain > 0 && nil != l.db.tombstones {
+		stone, ret := t.toRecord()
+		if nil != ret {
+			return -1, ret
+		}
+		if tid, err = l.db.tombstones.Insert(*stone); nil != err {
+			return -1, rcDatabaseError.specf(
+				"buryRecord(%q): %s: Insert(): %s", t.AbsPath, l.db, err)
+		}
+	}
+	if err := col.Delete(id); nil != err {
+		return -1, rcDatabaseError.specf(
+			"buryRecord(%q): %s: Delete(%d): %s", t.AbsPath, l.db, id, err)
+	}
+	if ecMedia == class {
+		l.removeIndexedSize(t.Size)
+	}
+	for _, e := range end {
+		if ret := l.db.removeRelation(e.id); nil != ret {
+			return tid, ret
+		}
+	}
+	infoLog.tracef("removed record (%s, ID={%q,%X}): %q", reason, l.name, id, t.AbsPath)
+	return tid, nil
+}
+
+// function exhumeRecord() restores the record retained by the tombstone with
+// the given hash key ID, along with the relationships of its media, and removes
+// the tombstone. the fields of the given record describing the file (if any)
+// replace those of the record restored, e.g. the new path of a moved file.
+// returns the hash key ID and data of the record restored.
+func (l *Library) exhumeRecord(tid int, t *Tombstone, file EntityRecord) (int, []byte, *ReturnCode) {
+
+	rec := EntityRecord{}
+	for k, v := range t.Record {
+		rec[k] = v
+	}
+	if nil != file {
+		for _, k := range entityFieldName {
+			if v, ok := file[k]; ok {
+				rec[k] = v
+			}
+		}
+	}
+	data, err := json.Marshal(rec)
+	if nil != err {
+		return -1, nil, rcInvalidJSONData.specf(
+			"exhumeRecord(%q): json.Marshal(): %s", t.AbsPath, err)
+	}
+	id, err := l.db.col[t.Class][t.Kind].Insert(rec)
+	if nil != err {
+		return -1, nil, rcDatabaseError.specf(
+			"exhumeRecord(%q): %s: Insert(): %s", t.AbsPath, l.db, err)
+	}
+
+	// the relationships now refer to the record by its new hash key ID.
+	if ecMedia == t.Class {
+		old, ref := mediaRef(MediaKind(t.Kind), t.ID), mediaRef(MediaKind(t.Kind), id)
+		for _, r := range t.Relationship {
+			from, to := r.From, r.To
+			if old == from {
+				from = ref
+			}
+			if old == to {
+				to = ref
+			}
+			if _, ret := l.db.addRelation(parseRelationKind(r.Kind), from, to); nil != ret {
+				warnLog.trace(ret)
+			}
+		}
+	}
+	if err := l.db.tombstones.Delete(tid); nil != err {
+		return id, data, rcDatabaseError.specf(
+			"exhumeRecord(%q): %s: Delete(%d): %s", t.AbsPath, l.db, tid, err)
+	}
+	return id, data, nil
+}
+
+// function findMoved() returns the tombstone of the record of a file which
+// has the same class, kind, name and size as the given new record, and which
+// no longer exists, i.e. which was presumably moved to the new record's path.
+// the record of such a file found missing by the most recent load is first
+// removed, writing its tombstone. returns -1 if there is none.
+func (l *Library) findMoved(class EntityClass, kind int, rec EntityRecord) (int, *Tombstone) {
+
+	if nil == l.db.tombstones || l.tombstones.retain <= 0 {
+		return -1, nil
+	}
+	name := recordString(rec, "AbsName")
+	size, _ := rec["Size"].(float64)
+	gone := func(absPath string) bool {
+		_, err := os.Stat(absPath)
+		return nil != err && os.IsNotExist(err)
+	}
+
+	// the previous record of the file may still exist, if it was only flagged
+	// as missing.
+	if nil != l.missing {
+		var found *MissingRecord
+		l.missing.Lock()
+		for p, m := range l.missing.record {
+			if m.class == class && m.kind == kind && filepath.Base(p) == name && gone(p) {
+				if read, err := l.db.col[class][kind].Read(m.id); nil == err {
+					if s, _ := read["Size"].(float64); s == size {
+						found = m
+						delete(l.missing.record, p)
+						break
+					}
+				}
+			}
+		}
+		l.missing.Unlock()
+		if nil != found {
+			tid, ret := l.buryRecord(found.class, found.kind, found.id, tombstoneMoved)
+			if nil != ret {
+				warnLog.trace(ret)
+			} else if tid >= 0 {
+				if t, ret := l.db.readTombstone(tid); nil == ret {
+					return tid, t
+				}
+			}
+		}
+	}
+
+	result := map[int]struct{}{}
+	if err := evalQuery(map[string]interface{}{
+		"eq": name,
+		"in": []interface{}{tombstoneIndex[0][0]},
+	}, l.db.tombstones, &result); nil != err {
+		warnLog.trace(rcQueryError.specf("findMoved(%q): %s: %s", name, l.db, err))
+		return -1, nil
+	}
+	for tid := range result {
+		t, ret := l.db.readTombstone(tid)
+		if nil != ret {
+			warnLog.trace(ret)
+			continue
+		}
+		if t.Class == class && t.Kind == kind && float64(t.Size) == size && gone(t.AbsPath) {
+			return tid, t
+		}
+	}
+	return -1, nil
+}
+
+// function insertRecord() inserts the given new record of a file found by a
+// scan into this library's database, returning its hash key ID. if the file
+// was presumably moved (see function findMoved()), its previous record is
+// restored at its new path instead, and the given entity is updated from it.
+func (l *Library) insertRecord(class EntityClass, kind int, rec *EntityRecord, entity RecordEntity) (int, error) {
+
+	if id, moved := l.insertMoved(class, kind, rec, entity); moved {
+		return id, nil
+	}
+	return l.db.col[class][kind].Insert(*rec)
+}
+
+// function insertMoved() restores the previous record of the given new record
+// of a file, if the file was presumably moved (see function insertRecord()).
+// returns the hash key ID of the record restored, and true if there was one.
+func (l *Library) insertMoved(class EntityClass, kind int, rec *EntityRecord, entity RecordEntity) (int, bool) {
+
+	l.tombstones.claim.Lock()
+	defer l.tombstones.claim.Unlock()
+
+	tid, t := l.findMoved(class, kind, *rec)
+	if nil == t {
+		return -1, false
+	}
+	id, data, ret := l.exhumeRecord(tid, t, *rec)
+	if nil != ret && id < 0 {
+		warnLog.trace(ret)
+		return -1, false
+	}
+	if nil != ret {
+		warnLog.trace(ret)
+	}
+	entity.fromRecord(data)
+	to := recordString(*rec, "RelPath")
+	l.tombstones.Lock()
+	l.tombstones.moved = append(l.tombstones.moved, SnapshotMove{From: t.RelPath, To: to})
+	l.tombstones.Unlock()
+	infoLog.verbosef("moved: %q -> %q (record kept)", t.RelPath, to)
+	return id, true
+}
+
+// function recordMoves() lists the moves detected by the current scan in the
+// given change log, in place of the corresponding files added and removed.
+// moves detected across several scans appear only as added files otherwise.
+func (l *Library) recordMoves(change *ChangeLog) {
+
+	l.tombstones.Lock()
+	moved := l.tombstones.moved
+	l.tombstones.moved = []SnapshotMove{}
+	l.tombstones.Unlock()
+
+	drop := func(list []string, p string) ([]string, bool) {
+		for i, s := range list {
+			if s == p {
+				return append(list[:i], list[i+1:]...), true
+			}
+		}
+		return list, false
+	}
+	for _, m := range moved {
+		var added bool
+		if change.Added, added = drop(change.Added, m.To); added {
+			change.Removed, _ = drop(change.Removed, m.From)
+			change.Moved = append(change.Moved, m)
+		}
+	}
+}
+
+// function expireTombstones() removes the tombstones kept longer than the
+// retention period.
+func (l *Library) expireTombstones() {
+
+	if nil == l.db.tombstones {
+		return
+	}
+	expired := []int{}
+	l.db.tombstones.ForEachDoc(
+		func(id int, data []byte) (willMoveOn bool) {
+			t := &Tombstone{}
+			if err := json.Unmarshal(data, t); nil != err || time.Since(t.Removed) > l.tombstones.retain {
+				expired = append(expired, id)
+			}
+			return true
+		})
+	for _, id := range expired {
+		if err := l.db.tombstones.Delete(id); nil != err {
+			warnLog.trace(rcDatabaseError.specf(
+				"expireTombstones(%d): %s: Delete(): %s", id, l.db, err))
+		}
+	}
+	if len(expired) > 0 {
+		infoLog.verbosef("expired %d tombstones of %q", len(expired), l.name)
+	}
+}
+
+// type RecordEntity is any entity which may be created from a database record.
+type RecordEntity interface {
+	fromRecord([]byte) *ReturnCode
+}
+
+// function entityOf() returns a new, empty entity of the given class and kind.
+func entityOf(class EntityClass, kind int) RecordEntity {
+	switch {
+	case ecMedia == class && mkAudio == MediaKind(kind):
+		return &AudioMedia{}
+	case ecMedia == class && mkVideo == MediaKind(kind):
+		return &VideoMedia{}
+	case ecSupport == class && skSubtitles == SupportKind(kind):
+		return &Subtitles{}
+	case ecSupport == class && skAudioTrack == SupportKind(kind):
+		return &AudioTrack{}
+	}
+	return nil
+}
+
+// function undoRemoval() restores the records removed by the most recent
+// removal (e.g. of the records of missing files), notifying the given handler
+// of each. returns the number of records restored.
+func (l *Library) undoRemoval(ph *PathHandler) (int, *ReturnCode) {
+
+	l.tombstones.Lock()
+	last := l.tombstones.last
+	l.tombstones.last = []int{}
+	l.tombstones.Unlock()
+
+	sort.Ints(last)
+	count := 0
+	for _, tid := range last {
+		l.tombstones.claim.Lock()
+		t, ret := l.db.readTombstone(tid)
+		if nil != ret {
+			// the tombstone may have been used since by a moved file.
+			l.tombstones.claim.Unlock()
+			warnLog.trace(ret)
+			continue
+		}
+		id, data, ret := l.exhumeRecord(tid, t, nil)
+		l.tombstones.claim.Unlock()
+		if nil != ret && id < 0 {
+			return count, ret
+		}
+		count++
+		if ecMedia == t.Class {
+			l.addIndexedSize(t.Size)
+		}
+		// the file of the record restored may still be missing.
+		l.verifyRecord(t.Class, t.Kind, id, t.AbsPath)
+		entity := entityOf(t.Class, t.Kind)
+		if nil == entity || nil != entity.fromRecord(data) || nil == ph {
+			continue
+		}
+		switch t.Class {
+		case ecMedia:
+			if nil != ph.handleMedia {
+				ph.handleMedia(l, t.AbsPath, entity, id)
+			}
+		case ecSupport:
+			if nil != ph.handleSupport {
+				ph.handleSupport(l, t.AbsPath, entity, id)
+			}
+		}
+	}
+	return count, nil
+}
+
+// function undoRemovalEvent() handles the key restoring the records of all
+// libraries removed by their most recent removal. returns true if the key was
+// handled.
+func (l *Layout) undoRemovalEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'B' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("restore removed media"))
+		return true
+	}
+	go func() {
+		total := 0
+		for _, lib := range l.lib {
+			count, ret := lib.undoRemoval(&PathHandler{
+				handleMedia: func(lib *Library, p string, v ...interface{}) {
+					lib.discover(newDiscovery(v...))
+				},
+			})
+			if nil != ret {
+				warnLog.log(ret)
+			}
+			total += count
+		}
+		if 0 == total {
+			warnLog.logf("(ignored) no removal to undo")
+			return
+		}
+		infoLog.logf("restored %d removed records", total)
+	}()
+	return true
+}
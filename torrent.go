@@ -0,0 +1,439 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: torrent.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the reading of the resume data of torrent clients, so that the
+//    files of torrents still downloading are held in quarantine even though
+//    torrent clients usually allocate them at their full size right away (and
+//    may not touch them for hours). libtorrent-based clients (qBittorrent,
+//    Deluge) and Transmission store the progress of each torrent in a resume
+//    file beside a copy of its .torrent file; a file of a torrent is complete
+//    once every piece overlapping it has been downloaded.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// variable defaultTorrentResume lists the directories in which common
+	// torrent clients store their resume data, used unless option
+	// -torrentresume is given.
+	defaultTorrentResume = []string{
+		"~/.local/share/qBittorrent/BT_backup",
+		"~/.local/share/data/qBittorrent/BT_backup",
+		"~/.config/deluge/state",
+		"~/.config/transmission/resume",
+		"~/.config/transmission-daemon/resume",
+	}
+
+	// variable torrentResumeExt lists the file name extensions of resume files
+	// of libtorrent-based clients and Transmission, respectively.
+	torrentResumeExt = []string{".fastresume", ".resume"}
+)
+
+// type TorrentIndex holds the files of a library belonging to torrents still
+// downloading, according to the resume data of the torrent clients.
+type TorrentIndex struct {
+	mutex  sync.Mutex
+	dir    []string                  // directories containing resume data
+	root   string                    // only files beneath this directory are indexed
+	resume map[string]*TorrentResume // progress of each torrent, by path of resume file
+}
+
+// type TorrentResume is the progress of a torrent read from its resume file.
+type TorrentResume struct {
+	mod        time.Time       // modification time of the resume file when read
+	incomplete map[string]bool // absolute paths of the files not yet completely downloaded
+}
+
+// function newTorrentIndex() creates an index of the torrents with files
+// beneath the given directory, whose resume data is stored in the given
+// directories (or the default directories, if none given). directories which
+// do not exist are ignored.
+func newTorrentIndex(root string, dir []string) *TorrentIndex {
+
+	if 0 == len(dir) {
+		dir = defaultTorrentResume
+	}
+	index := &TorrentIndex{
+		mutex:  sync.Mutex{},
+		dir:    []string{},
+		root:   root,
+		resume: map[string]*TorrentResume{},
+	}
+	for _, d := range dir {
+		if abs, err := libraryPath(d); nil == err {
+			if info, err := os.Stat(abs); nil == err && info.IsDir() {
+				index.dir = append(index.dir, abs)
+			}
+		}
+	}
+	return index
+}
+
+// function refresh() reads the resume files created or modified since they
+// were last read, and forgets those removed.
+func (t *TorrentIndex) refresh() {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	found := map[string]bool{}
+	for _, d := range t.dir {
+		entry, err := ioutil.ReadDir(d)
+		if nil != err {
+			warnLog.tracef("cannot read torrent resume data: %q: %s", d, err)
+			continue
+		}
+		for _, e := range entry {
+			if e.IsDir() || !isTorrentResume(e.Name()) {
+				continue
+			}
+			p := filepath.Join(d, e.Name())
+			found[p] = true
+			if r, ok := t.resume[p]; ok && r.mod.Equal(e.ModTime()) {
+				continue
+			}
+			r, err := t.readResume(p)
+			if nil != err {
+				warnLog.tracef("cannot read torrent resume file: %q: %s", p, err)
+				r = &TorrentResume{incomplete: map[string]bool{}}
+			}
+			r.mod = e.ModTime()
+			t.resume[p] = r
+		}
+	}
+	for p := range t.resume {
+		if !found[p] {
+			delete(t.resume, p)
+		}
+	}
+}
+
+// function incomplete() returns true if the given file belongs to a torrent
+// and has not been completely downloaded.
+func (t *TorrentIndex) incomplete(absPath string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, r := range t.resume {
+		if r.incomplete[absPath] {
+			return true
+		}
+	}
+	return false
+}
+
+// function isTorrentResume() returns true if the given file name is that of a
+// torrent client's resume file.
+func isTorrentResume(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range torrentResumeExt {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// function readResume() reads the given resume file, and returns the files of
+// its torrents beneath the index's root which are not yet completely
+// downloaded. torrents whose progress cannot be determined are presumed
+// complete.
+func (t *TorrentIndex) readResume(resumePath string) (*TorrentResume, error) {
+
+	r := &TorrentResume{incomplete: map[string]bool{}}
+	data, err := ioutil.ReadFile(resumePath)
+	if nil != err {
+		return nil, err
+	}
+	resume, err := bdecodeDict(data)
+	if nil != err {
+		return nil, err
+	}
+	dir := filepath.Dir(resumePath)
+
+	// Deluge stores the resume data of all of its torrents in a single file,
+	// each bencoded again, by info hash.
+	if _, ok := resume["save_path"]; !ok && "torrents.fastresume" == filepath.Base(resumePath) {
+		for hash, v := range resume {
+			if b, ok := v.(string); ok {
+				if each, err := bdecodeDict([]byte(b)); nil == err {
+					if err := t.readTorrent(r, each, dir, hash); nil != err {
+						warnLog.tracef("cannot read torrent resume data: %q (%s): %s", resumePath, hash, err)
+					}
+				}
+			}
+		}
+		return r, nil
+	}
+
+	base := strings.TrimSuffix(filepath.Base(resumePath), filepath.Ext(resumePath))
+	if err := t.readTorrent(r, resume, dir, base); nil != err {
+		return nil, err
+	}
+	return r, nil
+}
+
+// function readTorrent() adds the incomplete files of the torrent with the
+// given resume data to the given TorrentResume. the info dictionary of the
+// torrent is embedded in the resume data of newer libtorrent versions, and
+// otherwise is read from the .torrent file with the given base name in the
+// given directory (or in directory "torrents" beside Transmission's directory
+// "resume").
+func (t *TorrentIndex) readTorrent(r *TorrentResume, resume map[string]interface{}, dir, base string) error {
+
+	info, _ := resume["info"].(map[string]interface{})
+	if nil == info {
+		for _, p := range []string{
+			filepath.Join(dir, base+".torrent"),
+			filepath.Join(dir, "..", "torrents", base+".torrent"),
+		} {
+			if data, err := ioutil.ReadFile(p); nil == err {
+				if torrent, err := bdecodeDict(data); nil == err {
+					info, _ = torrent["info"].(map[string]interface{})
+					break
+				}
+			}
+		}
+	}
+	if nil == info {
+		return nil
+	}
+
+	save := bstring(resume, "save_path")
+	if "" == save {
+		save = bstring(resume, "destination") // Transmission
+	}
+	if "" == save {
+		return nil
+	}
+	if abs, err := libraryPath(save); nil == err {
+		save = abs
+	}
+
+	have, known := resumePieces(resume)
+	if !known {
+		return nil
+	}
+
+	pieceLen, _ := info["piece length"].(int64)
+	if pieceLen <= 0 {
+		return fmt.Errorf("invalid piece length: %d", pieceLen)
+	}
+	name := bstring(info, "name.utf-8")
+	if "" == name {
+		name = bstring(info, "name")
+	}
+
+	// libtorrent stores the paths of renamed files (relative to the save
+	// path) in the resume data.
+	mapped, _ := resume["mapped_files"].([]interface{})
+
+	offset := int64(0)
+	files, multi := info["files"].([]interface{})
+	if !multi {
+		length, _ := info["length"].(int64)
+		files = []interface{}{map[string]interface{}{"length": length}}
+	}
+	for i, f := range files {
+		file, _ := f.(map[string]interface{})
+		length, _ := file["length"].(int64)
+		var rel string
+		if m, ok := bstringAt(mapped, i); ok && "" != m {
+			rel = filepath.FromSlash(m)
+		} else if multi {
+			component, _ := file["path.utf-8"].([]interface{})
+			if 0 == len(component) {
+				component, _ = file["path"].([]interface{})
+			}
+			part := []string{name}
+			for j := range component {
+				s, _ := bstringAt(component, j)
+				part = append(part, s)
+			}
+			rel = filepath.Join(part...)
+		} else {
+			rel = name
+		}
+		abs := filepath.Join(save, rel)
+
+		if length > 0 && isBeneath(abs, t.root) {
+			for p := offset / pieceLen; p <= (offset+length-1)/pieceLen; p++ {
+				if !have(p) {
+					r.incomplete[abs] = true
+					break
+				}
+			}
+		}
+		offset += length
+	}
+	return nil
+}
+
+// function resumePieces() returns a function reporting if the piece with the
+// given index has been downloaded, according to the given resume data. returns
+// false if the progress is not stored in a known form.
+func resumePieces(resume map[string]interface{}) (func(int64) bool, bool) {
+
+	all := func(int64) bool { return true }
+	none := func(int64) bool { return false }
+
+	// libtorrent: one byte per piece, whose lowest bit is set if downloaded.
+	if pieces, ok := resume["pieces"].(string); ok {
+		return func(i int64) bool { return i < int64(len(pieces)) && 0 != pieces[i]&1 }, true
+	}
+	if seed, _ := resume["seed_mode"].(int64); 0 != seed {
+		return all, true
+	}
+
+	// Transmission: "all", "none", or a bit field (most significant bit
+	// first) in the progress dictionary.
+	progress, ok := resume["progress"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if "all" == bstring(progress, "have") {
+		return all, true
+	}
+	for _, key := range []string{"pieces", "bitfield"} {
+		field, ok := progress[key].(string)
+		if !ok {
+			continue
+		}
+		switch field {
+		case "all":
+			return all, true
+		case "none":
+			return none, true
+		}
+		return func(i int64) bool {
+			return i/8 < int64(len(field)) && 0 != field[i/8]&(0x80>>uint(i%8))
+		}, true
+	}
+	return nil, false
+}
+
+// function isBeneath() returns true if the given path is the given directory
+// or any path beneath it.
+func isBeneath(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return nil == err && ".." != rel && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+//------------------------------------------------------------------------------
+
+// function bdecodeDict() decodes the given bencoded data (the encoding of both
+// .torrent files and resume files), which must be a dictionary.
+func bdecodeDict(b []byte) (map[string]interface{}, error) {
+	v, _, err := bdecode(b, 0)
+	if nil != err {
+		return nil, err
+	}
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("bencoded data is not a dictionary")
+	}
+	return dict, nil
+}
+
+// function bdecode() decodes the bencoded value at the given offset, returning
+// it along with the offset following it. integers are decoded as int64, byte
+// strings as string, lists as []interface{} and dictionaries as
+// map[string]interface{}.
+func bdecode(b []byte, i int) (interface{}, int, error) {
+
+	if i >= len(b) {
+		return nil, i, fmt.Errorf("bencoded data truncated")
+	}
+	switch c := b[i]; {
+	case 'i' == c:
+		end := i + 1
+		for end < len(b) && 'e' != b[end] {
+			end++
+		}
+		if end >= len(b) {
+			return nil, i, fmt.Errorf("bencoded integer truncated")
+		}
+		n, err := strconv.ParseInt(string(b[i+1:end]), 10, 64)
+		if nil != err {
+			return nil, i, fmt.Errorf("invalid bencoded integer: %s", err)
+		}
+		return n, end + 1, nil
+
+	case 'l' == c:
+		list := []interface{}{}
+		i++
+		for i < len(b) && 'e' != b[i] {
+			v, next, err := bdecode(b, i)
+			if nil != err {
+				return nil, i, err
+			}
+			list, i = append(list, v), next
+		}
+		return list, i + 1, nil
+
+	case 'd' == c:
+		dict := map[string]interface{}{}
+		i++
+		for i < len(b) && 'e' != b[i] {
+			k, next, err := bdecode(b, i)
+			if nil != err {
+				return nil, i, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, i, fmt.Errorf("bencoded dictionary key is not a string")
+			}
+			v, next, err := bdecode(b, next)
+			if nil != err {
+				return nil, i, err
+			}
+			dict[key], i = v, next
+		}
+		return dict, i + 1, nil
+
+	case c >= '0' && c <= '9':
+		colon := i
+		for colon < len(b) && ':' != b[colon] {
+			colon++
+		}
+		n, err := strconv.Atoi(string(b[i:colon]))
+		if nil != err || colon+1+n > len(b) {
+			return nil, i, fmt.Errorf("invalid bencoded string")
+		}
+		return string(b[colon+1 : colon+1+n]), colon + 1 + n, nil
+	}
+	return nil, i, fmt.Errorf("invalid bencoded data at offset %d", i)
+}
+
+// function bstring() returns the byte string with the given key of the given
+// bencoded dictionary, or an empty string if there is none.
+func bstring(dict map[string]interface{}, key string) string {
+	s, _ := dict[key].(string)
+	return s
+}
+
+// function bstringAt() returns the byte string at the given index of the given
+// bencoded list, and false if there is none.
+func bstringAt(list []interface{}, i int) (string, bool) {
+	if i < 0 || i >= len(list) {
+		return "", false
+	}
+	s, ok := list[i].(string)
+	return s, ok
+}
@@ -0,0 +1,220 @@
+// =============================================================================
+//  PROJ: pimmp
+//  AUTH: ardnew
+//  DATE: 15 Oct 2026
+//  FILE: tracks.go
+// -----------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//    defines the track picker, a dialog used to choose the subtitles and audio
+//    tracks of a single video. the choices are stored in the video's database
+//    record and reused for all future playbacks of that video.
+//
+// =============================================================================
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// local unexported constants for the track picker.
+const (
+	// option listed first in each external track drop-down, deselecting it.
+	trackPickerNone = "(none)"
+)
+
+// type TrackPickerView is the dialog used to choose the subtitles and audio
+// tracks used when playing the video currently selected in the media browser.
+type TrackPickerView struct {
+	*tview.Form
+	subsDropDown  *tview.DropDown
+	subsInput     *tview.InputField
+	audioDropDown *tview.DropDown
+	audioInput    *tview.InputField
+	video         *VideoMedia
+	item          *mediaItem
+	record        *RecordID
+	layout        *Layout
+	focusPage     string
+	focusNext     FocusDelegator
+	focusPrev     FocusDelegator
+}
+
+// function newTrackPickerView() allocates and initializes the dialog widgets.
+func newTrackPickerView(ui *tview.Application, page string, lib []*Library) *TrackPickerView {
+
+	v := TrackPickerView{
+		Form:          nil,
+		subsDropDown:  nil,
+		subsInput:     nil,
+		audioDropDown: nil,
+		audioInput:    nil,
+		video:         nil,
+		item:          nil,
+		record:        nil,
+		layout:        nil,
+		focusPage:     page,
+		focusNext:     nil,
+		focusPrev:     nil,
+	}
+
+	form := tview.NewForm().
+		AddDropDown("Subtitles file:", []string{trackPickerNone}, 0, nil).
+		AddInputField("Subtitles stream:", "", 4, tview.InputFieldInteger, nil).
+		AddDropDown("Audio file:", []string{trackPickerNone}, 0, nil).
+		AddInputField("Audio stream:", "", 4, tview.InputFieldInteger, nil).
+		AddButton("Save", v.save).
+		AddButton("Cancel", v.cancel).
+		SetLabelColor(colorScheme.inactiveMenuText).
+		SetFieldTextColor(colorScheme.inactiveMenuText).
+		SetFieldBackgroundColor(colorScheme.backgroundSecondary)
+
+	form.
+		SetBorder(true).
+		SetBorderColor(colorScheme.activeBorder).
+		SetTitleColor(colorScheme.activeMenuText).
+		SetTitleAlign(tview.AlignLeft)
+
+	v.Form = form
+	v.subsDropDown = form.GetFormItem(0).(*tview.DropDown)
+	v.subsInput = form.GetFormItem(1).(*tview.InputField)
+	v.audioDropDown = form.GetFormItem(2).(*tview.DropDown)
+	v.audioInput = form.GetFormItem(3).(*tview.InputField)
+
+	return &v
+}
+
+func (v *TrackPickerView) desc() string { return "" }
+func (v *TrackPickerView) setDelegates(layout *Layout, prev, next FocusDelegator) {
+	v.layout = layout
+	v.focusPrev = prev
+	v.focusNext = next
+}
+func (v *TrackPickerView) page() string         { return v.focusPage }
+func (v *TrackPickerView) next() FocusDelegator { return v.focusNext }
+func (v *TrackPickerView) prev() FocusDelegator { return v.focusPrev }
+func (v *TrackPickerView) focus() {
+	page := v.page()
+	v.layout.pages.ShowPage(page)
+	v.layout.ui.SetFocus(v.Form)
+}
+func (v *TrackPickerView) blur() {
+	page := v.page()
+	v.layout.pages.HidePage(page)
+}
+
+// function streamText() formats a stream number for an input field, leaving it
+// empty if the choice is left to the player.
+func streamText(stream int) string {
+	if stream <= 0 {
+		return ""
+	}
+	return strconv.Itoa(stream)
+}
+
+// function setVideo() populates the dialog with the tracks known for the given
+// video and its current selections.
+func (v *TrackPickerView) setVideo(item *mediaItem, video *VideoMedia, record *RecordID) {
+
+	v.item = item
+	v.video = video
+	v.record = record
+
+	subs := []string{trackPickerNone}
+	currSubs := 0
+	for i, s := range video.KnownSubtitles {
+		subs = append(subs, s.RelPath)
+		if nil != video.Subtitles.Support && s.AbsPath == video.Subtitles.AbsPath {
+			currSubs = i + 1
+		}
+	}
+	audio := []string{trackPickerNone}
+	currAudio := 0
+	for i, a := range video.KnownAudioTracks {
+		desc := a.RelPath
+		if a.Commentary {
+			desc = fmt.Sprintf("%s (commentary)", desc)
+		}
+		audio = append(audio, desc)
+		if nil != video.AudioTrack.Support && a.AbsPath == video.AudioTrack.AbsPath {
+			currAudio = i + 1
+		}
+	}
+
+	v.subsDropDown.SetOptions(subs, nil).SetCurrentOption(currSubs)
+	v.audioDropDown.SetOptions(audio, nil).SetCurrentOption(currAudio)
+	v.subsInput.SetText(streamText(video.SubtitleStream))
+	v.audioInput.SetText(streamText(video.AudioStream))
+
+	v.SetTitle(fmt.Sprintf(" Tracks: [#%06x]%s ",
+		colorScheme.highlightPrimary.Hex(), video.Name))
+}
+
+// function save() stores the dialog's current selections in the video's
+// database record.
+func (v *TrackPickerView) save() {
+
+	subs, _ := v.subsDropDown.GetCurrentOption()
+	audio, _ := v.audioDropDown.GetCurrentOption()
+	subsStream, _ := strconv.Atoi(strings.TrimSpace(v.subsInput.GetText()))
+	audioStream, _ := strconv.Atoi(strings.TrimSpace(v.audioInput.GetText()))
+
+	col := v.item.SourceLibrary.db.col[ecMedia][mkVideo]
+	if ret := v.video.selectTracks(col, v.record.id, false, subs-1, audio-1); nil != ret {
+		warnLog.log(ret)
+	} else if ret := v.video.selectStreams(col, v.record.id, true, subsStream, audioStream); nil != ret {
+		warnLog.log(ret)
+	} else {
+		infoLog.logf("saved track selection: %s", v.video.playbackCommand())
+	}
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function cancel() closes the dialog without modifying anything.
+func (v *TrackPickerView) cancel() {
+	v.layout.focusQueue <- v.layout.focusBase
+}
+
+// function trackEvent() handles the key opening the track picker for the video
+// currently selected in the media browser. returns true if the key was handled.
+func (l *Layout) trackEvent(busy bool, ek tcell.Key, er rune) bool {
+
+	if tcell.KeyRune != ek || 'T' != er {
+		return false
+	}
+	if busy {
+		warnLog.logf(busyMessage("choose tracks"))
+		return true
+	}
+	item := l.browseView.currentMediaItem()
+	if nil == item || mkVideo != item.Kind {
+		warnLog.logf("(ignored) tracks may only be chosen for video")
+		return true
+	}
+	record, ok := l.browseView.record[item.Media]
+	if !ok {
+		warnLog.logf("(ignored) database record unknown: %s", item.AbsName)
+		return true
+	}
+	video, ok := record.rec.(*VideoMedia)
+	if !ok {
+		warnLog.logf("(ignored) database record is not video: %s", item.AbsName)
+		return true
+	}
+	// reload the record, since the tracks associated with this video may have
+	// changed since it was first discovered. the embedded Media is unmarshalled
+	// in-place, so the browser's item remains consistent with the record.
+	if ret := video.fromID(item.SourceLibrary.db.col[ecMedia][mkVideo], record.id); nil != ret {
+		warnLog.log(ret)
+		return true
+	}
+	l.trackPicker.setVideo(item, video, record)
+	l.focusQueue <- l.trackPicker
+	return true
+}